@@ -202,11 +202,13 @@ func (a *AdminController) UpdateUser(c *gin.Context) {
 	}
 
 	var req struct {
-		Role                   *string   `json:"role"`
-		Permissions            *[]string `json:"permissions"`
-		Nickname               *string   `json:"nickname"`
-		Email                  *string   `json:"email"`
-		ReceiveAIConversations *bool     `json:"receive_ai_conversations"`
+		Role                       *string   `json:"role"`
+		Permissions                *[]string `json:"permissions"`
+		Nickname                   *string   `json:"nickname"`
+		Email                      *string   `json:"email"`
+		ReceiveAIConversations     *bool     `json:"receive_ai_conversations"`
+		Skills                     *[]string `json:"skills"`
+		MaxConcurrentConversations *int      `json:"max_concurrent_conversations"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -215,12 +217,14 @@ func (a *AdminController) UpdateUser(c *gin.Context) {
 	}
 
 	user, err := a.userService.UpdateUser(service.UpdateUserInput{
-		UserID:                 uint(id),
-		Role:                   req.Role,
-		Permissions:            req.Permissions,
-		Nickname:               req.Nickname,
-		Email:                  req.Email,
-		ReceiveAIConversations: req.ReceiveAIConversations,
+		UserID:                     uint(id),
+		Role:                       req.Role,
+		Permissions:                req.Permissions,
+		Nickname:                   req.Nickname,
+		Email:                      req.Email,
+		ReceiveAIConversations:     req.ReceiveAIConversations,
+		Skills:                     req.Skills,
+		MaxConcurrentConversations: req.MaxConcurrentConversations,
 	})
 	if err != nil {
 		if err.Error() == "用户不存在" {
@@ -19,25 +19,37 @@ func NewAIConfigController(aiConfigService *service.AIConfigService, userService
 }
 
 type createAIConfigRequest struct {
-	Provider    string `json:"provider" binding:"required"`
-	APIURL      string `json:"api_url" binding:"required"`
-	APIKey      string `json:"api_key" binding:"required"`
-	Model       string `json:"model" binding:"required"`
-	ModelType   string `json:"model_type"`
-	IsActive    bool   `json:"is_active"`
-	IsPublic    bool   `json:"is_public"` // 是否开放给访客使用
-	Description string `json:"description"`
+	Provider            string  `json:"provider" binding:"required"`
+	APIURL              string  `json:"api_url" binding:"required"`
+	APIKey              string  `json:"api_key" binding:"required"`
+	Model               string  `json:"model" binding:"required"`
+	ModelType           string  `json:"model_type"`
+	Protocol            string  `json:"protocol"` // 接口协议：openai（默认）/ anthropic / gemini
+	IsActive            bool    `json:"is_active"`
+	IsPublic            bool    `json:"is_public"` // 是否开放给访客使用
+	Description         string  `json:"description"`
+	HistoryTokenBudget  int     `json:"history_token_budget"`   // 对话历史 token 预算（0 使用默认值）
+	FallbackConfigIDs   []uint  `json:"fallback_config_ids"`    // 故障转移备用配置 ID（按顺序）
+	MonthlyBudget       float64 `json:"monthly_budget"`         // 每月费用预算（0 不限，超出后自动停用）
+	AllowedToolIDs      []uint  `json:"allowed_tool_ids"`       // 允许模型调用的 HTTP 工具 ID
+	AllowedMCPServerIDs []uint  `json:"allowed_mcp_server_ids"` // 允许模型调用的 MCP 服务 ID
 }
 
 type updateAIConfigRequest struct {
-	Provider    *string `json:"provider"`
-	APIURL      *string `json:"api_url"`
-	APIKey      *string `json:"api_key"`
-	Model       *string `json:"model"`
-	ModelType   *string `json:"model_type"`
-	IsActive    *bool   `json:"is_active"`
-	IsPublic    *bool   `json:"is_public"` // 是否开放给访客使用
-	Description *string `json:"description"`
+	Provider            *string  `json:"provider"`
+	APIURL              *string  `json:"api_url"`
+	APIKey              *string  `json:"api_key"`
+	Model               *string  `json:"model"`
+	ModelType           *string  `json:"model_type"`
+	Protocol            *string  `json:"protocol"`
+	IsActive            *bool    `json:"is_active"`
+	IsPublic            *bool    `json:"is_public"` // 是否开放给访客使用
+	Description         *string  `json:"description"`
+	HistoryTokenBudget  *int     `json:"history_token_budget"`   // 对话历史 token 预算（0 使用默认值）
+	FallbackConfigIDs   *[]uint  `json:"fallback_config_ids"`    // 故障转移备用配置 ID（按顺序；[] 表示清除）
+	MonthlyBudget       *float64 `json:"monthly_budget"`         // 每月费用预算（0 不限，超出后自动停用）
+	AllowedToolIDs      *[]uint  `json:"allowed_tool_ids"`       // 允许模型调用的 HTTP 工具 ID（[] 表示清除）
+	AllowedMCPServerIDs *[]uint  `json:"allowed_mcp_server_ids"` // 允许模型调用的 MCP 服务 ID（[] 表示清除）
 }
 
 // CreateAIConfig 创建 AI 配置。
@@ -58,15 +70,21 @@ func (a *AIConfigController) CreateAIConfig(c *gin.Context) {
 	}
 
 	config, err := a.aiConfigService.CreateAIConfig(service.CreateAIConfigInput{
-		UserID:      uint(userID),
-		Provider:    req.Provider,
-		APIURL:      req.APIURL,
-		APIKey:      req.APIKey,
-		Model:       req.Model,
-		ModelType:   req.ModelType,
-		IsActive:    req.IsActive,
-		IsPublic:    req.IsPublic,
-		Description: req.Description,
+		UserID:              uint(userID),
+		Provider:            req.Provider,
+		APIURL:              req.APIURL,
+		APIKey:              req.APIKey,
+		Model:               req.Model,
+		ModelType:           req.ModelType,
+		Protocol:            req.Protocol,
+		IsActive:            req.IsActive,
+		IsPublic:            req.IsPublic,
+		Description:         req.Description,
+		HistoryTokenBudget:  req.HistoryTokenBudget,
+		FallbackConfigIDs:   req.FallbackConfigIDs,
+		MonthlyBudget:       req.MonthlyBudget,
+		AllowedToolIDs:      req.AllowedToolIDs,
+		AllowedMCPServerIDs: req.AllowedMCPServerIDs,
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -134,15 +152,21 @@ func (a *AIConfigController) UpdateAIConfig(c *gin.Context) {
 	}
 
 	config, err := a.aiConfigService.UpdateAIConfig(service.UpdateAIConfigInput{
-		ID:          uint(id),
-		Provider:    req.Provider,
-		APIURL:      req.APIURL,
-		APIKey:      req.APIKey,
-		Model:       req.Model,
-		ModelType:   req.ModelType,
-		IsActive:    req.IsActive,
-		IsPublic:    req.IsPublic,
-		Description: req.Description,
+		ID:                  uint(id),
+		Provider:            req.Provider,
+		APIURL:              req.APIURL,
+		APIKey:              req.APIKey,
+		Model:               req.Model,
+		ModelType:           req.ModelType,
+		Protocol:            req.Protocol,
+		IsActive:            req.IsActive,
+		IsPublic:            req.IsPublic,
+		Description:         req.Description,
+		HistoryTokenBudget:  req.HistoryTokenBudget,
+		FallbackConfigIDs:   req.FallbackConfigIDs,
+		MonthlyBudget:       req.MonthlyBudget,
+		AllowedToolIDs:      req.AllowedToolIDs,
+		AllowedMCPServerIDs: req.AllowedMCPServerIDs,
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -170,4 +194,3 @@ func (a *AIConfigController) DeleteAIConfig(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
-
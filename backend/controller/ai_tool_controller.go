@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AIToolController 管理员定义的 HTTP 工具（供模型 function calling 调用）。
+type AIToolController struct {
+	toolService *service.AIToolService
+	users       *service.UserService
+}
+
+// NewAIToolController 创建工具控制器。
+func NewAIToolController(toolService *service.AIToolService, users *service.UserService) *AIToolController {
+	return &AIToolController{toolService: toolService, users: users}
+}
+
+type aiToolRequest struct {
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	Parameters     json.RawMessage `json:"parameters"` // JSON Schema（type=object）
+	Method         string          `json:"method"`
+	URLTemplate    string          `json:"url_template"`  // 支持 {{参数名}} 占位符
+	BodyTemplate   string          `json:"body_template"` // 为空时以参数 JSON 作为请求体
+	AuthHeader     string          `json:"auth_header"`
+	AuthValue      *string         `json:"auth_value"` // 修改时不传表示保留原值，"" 表示清除
+	ResponsePath   string          `json:"response_path"`
+	TimeoutSeconds int             `json:"timeout_seconds"`
+	IsActive       *bool           `json:"is_active"`
+}
+
+func (r aiToolRequest) toInput() service.AIToolInput {
+	params := string(r.Parameters)
+	// 兼容以字符串形式提交的 schema
+	var str string
+	if err := json.Unmarshal(r.Parameters, &str); err == nil {
+		params = str
+	}
+	if params == "null" {
+		params = ""
+	}
+	return service.AIToolInput{
+		Name:           r.Name,
+		Description:    r.Description,
+		Parameters:     params,
+		Method:         r.Method,
+		URLTemplate:    r.URLTemplate,
+		BodyTemplate:   r.BodyTemplate,
+		AuthHeader:     r.AuthHeader,
+		AuthValue:      r.AuthValue,
+		ResponsePath:   r.ResponsePath,
+		TimeoutSeconds: r.TimeoutSeconds,
+		IsActive:       r.IsActive,
+	}
+}
+
+// ListTools 列出工具。
+// GET /agent/ai-tools
+func (a *AIToolController) ListTools(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	tools, err := a.toolService.ListTools()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询工具失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tools": tools})
+}
+
+// GetTool 获取单个工具。
+// GET /agent/ai-tools/:id
+func (a *AIToolController) GetTool(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "工具 ID 不合法"})
+		return
+	}
+	tool, err := a.toolService.GetTool(uint(id))
+	if err != nil {
+		writeToolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tool)
+}
+
+// CreateTool 新增工具。
+// POST /agent/ai-tools
+func (a *AIToolController) CreateTool(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	var req aiToolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	tool, err := a.toolService.CreateTool(req.toInput())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tool)
+}
+
+// UpdateTool 修改工具。
+// PUT /agent/ai-tools/:id
+func (a *AIToolController) UpdateTool(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "工具 ID 不合法"})
+		return
+	}
+	var req aiToolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	tool, err := a.toolService.UpdateTool(uint(id), req.toInput())
+	if err != nil {
+		writeToolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tool)
+}
+
+// DeleteTool 删除工具。
+// DELETE /agent/ai-tools/:id
+func (a *AIToolController) DeleteTool(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "工具 ID 不合法"})
+		return
+	}
+	if err := a.toolService.DeleteTool(uint(id)); err != nil {
+		writeToolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// TestTool 以给定参数试调用工具（不经过模型）。
+// POST /agent/ai-tools/:id/test
+func (a *AIToolController) TestTool(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "工具 ID 不合法"})
+		return
+	}
+	var req struct {
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	args, _ := json.Marshal(req.Arguments)
+	result, err := a.toolService.TestTool(c.Request.Context(), uint(id), string(args))
+	if err != nil {
+		writeToolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func writeToolError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrAIToolNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AIUsageController 模型价格表管理（用于 token 用量计费）。
+type AIUsageController struct {
+	usageService *service.AIUsageService
+	users        *service.UserService
+}
+
+// NewAIUsageController 创建模型价格控制器。
+func NewAIUsageController(usageService *service.AIUsageService, users *service.UserService) *AIUsageController {
+	return &AIUsageController{usageService: usageService, users: users}
+}
+
+type modelPriceRequest struct {
+	Model       string  `json:"model"`
+	InputPrice  float64 `json:"input_price"`  // 输入每百万 token 单价
+	OutputPrice float64 `json:"output_price"` // 输出每百万 token 单价
+	ImagePrice  float64 `json:"image_price"`  // 生图每张单价
+	Currency    string  `json:"currency"`
+}
+
+func (r modelPriceRequest) toInput() service.ModelPriceInput {
+	return service.ModelPriceInput{
+		Model:       r.Model,
+		InputPrice:  r.InputPrice,
+		OutputPrice: r.OutputPrice,
+		ImagePrice:  r.ImagePrice,
+		Currency:    r.Currency,
+	}
+}
+
+// ListPrices 列出模型价格表。
+// GET /agent/ai-prices
+func (a *AIUsageController) ListPrices(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	prices, err := a.usageService.ListPrices()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询价格表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"prices": prices})
+}
+
+// CreatePrice 新增模型价格。
+// POST /agent/ai-prices
+func (a *AIUsageController) CreatePrice(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	var req modelPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	price, err := a.usageService.CreatePrice(req.toInput())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, price)
+}
+
+// UpdatePrice 修改模型价格（仅影响之后产生的用量）。
+// PUT /agent/ai-prices/:id
+func (a *AIUsageController) UpdatePrice(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "价格 ID 不合法"})
+		return
+	}
+	var req modelPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	price, err := a.usageService.UpdatePrice(uint(id), req.toInput())
+	if err != nil {
+		writePriceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, price)
+}
+
+// DeletePrice 删除模型价格。
+// DELETE /agent/ai-prices/:id
+func (a *AIUsageController) DeletePrice(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "价格 ID 不合法"})
+		return
+	}
+	if err := a.usageService.DeletePrice(uint(id)); err != nil {
+		writePriceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func writePriceError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrModelPriceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
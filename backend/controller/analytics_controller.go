@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/2930134478/AI-CS/backend/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -40,6 +41,72 @@ func (ac *AnalyticsController) GetSummary(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+// GetAICost GET /agent/analytics/ai-cost?from=YYYY-MM-DD&to=YYYY-MM-DD — 模型 token 用量与费用
+func (ac *AnalyticsController) GetAICost(c *gin.Context) {
+	if !requirePermission(c, ac.users, string(service.PermAnalytics)) {
+		return
+	}
+	from := c.Query("from")
+	to := c.Query("to")
+	if from == "" || to == "" {
+		// 默认本月（含今天）
+		loc, _ := time.LoadLocation("Asia/Shanghai")
+		now := time.Now().In(loc)
+		to = now.Format("2006-01-02")
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc).Format("2006-01-02")
+	}
+	res, err := ac.analytics.GetAICost(from, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// GetCopilotStats GET /agent/analytics/copilot?from=YYYY-MM-DD&to=YYYY-MM-DD — 客服助手草稿采纳率与修改幅度
+func (ac *AnalyticsController) GetCopilotStats(c *gin.Context) {
+	if !requirePermission(c, ac.users, string(service.PermAnalytics)) {
+		return
+	}
+	from := c.Query("from")
+	to := c.Query("to")
+	if from == "" || to == "" {
+		// 默认最近 7 天（含今天）
+		loc, _ := time.LoadLocation("Asia/Shanghai")
+		now := time.Now().In(loc)
+		to = now.Format("2006-01-02")
+		from = now.AddDate(0, 0, -6).Format("2006-01-02")
+	}
+	res, err := ac.analytics.GetCopilotStats(from, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// GetConversationCategories GET /agent/analytics/categories?from=&to=&category=&resolution= — 会话小结分类与解决状态分布
+func (ac *AnalyticsController) GetConversationCategories(c *gin.Context) {
+	if !requirePermission(c, ac.users, string(service.PermAnalytics)) {
+		return
+	}
+	from := c.Query("from")
+	to := c.Query("to")
+	if from == "" || to == "" {
+		// 默认最近 7 天（含今天）
+		loc, _ := time.LoadLocation("Asia/Shanghai")
+		now := time.Now().In(loc)
+		to = now.Format("2006-01-02")
+		from = now.AddDate(0, 0, -6).Format("2006-01-02")
+	}
+	res, err := ac.analytics.GetConversationCategories(from, to, utils.SplitTags(c.Query("category")), utils.SplitTags(c.Query("resolution")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
 type widgetOpenRequest struct {
 	VisitorID uint `json:"visitor_id"`
 }
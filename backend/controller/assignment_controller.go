@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AssignmentController 负责会话分配 / 转接与分配策略相关的 HTTP 请求。
+type AssignmentController struct {
+	assignmentService *service.AssignmentService
+	userService       *service.UserService
+}
+
+// NewAssignmentController 创建 AssignmentController 实例。
+func NewAssignmentController(assignmentService *service.AssignmentService, userService *service.UserService) *AssignmentController {
+	return &AssignmentController{
+		assignmentService: assignmentService,
+		userService:       userService,
+	}
+}
+
+type assignConversationRequest struct {
+	AgentID uint   `json:"agent_id"` // 目标客服；0 表示按当前策略自动选择
+	Note    string `json:"note"`     // 转接备注（可选），推送给接手客服并写入参与记录
+}
+
+// AssignConversation 将会话分配给指定客服（agent_id=0 时自动分配）。
+// POST /conversations/:id/assign
+func (a *AssignmentController) AssignConversation(c *gin.Context) {
+	a.assign(c, "assign")
+}
+
+// TransferConversation 将会话转接给其他客服（agent_id=0 时自动选择除当前客服外的人选）。
+// POST /conversations/:id/transfer
+func (a *AssignmentController) TransferConversation(c *gin.Context) {
+	a.assign(c, "transfer")
+}
+
+func (a *AssignmentController) assign(c *gin.Context, reason string) {
+	if !requirePermission(c, a.userService, string(service.PermChat)) {
+		return
+	}
+	conversationID, err := parseUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id 不合法"})
+		return
+	}
+	var req assignConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	result, err := a.assignmentService.Assign(uint(conversationID), req.AgentID, getUserIDFromHeader(c), reason, req.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrConversationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+		case errors.Is(err, service.ErrNoAvailableAgent):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+type putAssignmentStrategyBody struct {
+	Strategy string `json:"strategy"`
+}
+
+// GetStrategyPolicy 读取会话自动分配策略。
+func (a *AssignmentController) GetStrategyPolicy(c *gin.Context) {
+	if !requirePermission(c, a.userService, string(service.PermSettings)) {
+		return
+	}
+	c.JSON(http.StatusOK, a.assignmentService.GetStrategyPolicy())
+}
+
+// PutStrategyPolicy 写入会话自动分配策略（manual / round_robin / least_busy / skill）。
+func (a *AssignmentController) PutStrategyPolicy(c *gin.Context) {
+	if !requirePermission(c, a.userService, string(service.PermSettings)) {
+		return
+	}
+	var body putAssignmentStrategyBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体无效"})
+		return
+	}
+	if err := a.assignmentService.SetStrategy(body.Strategy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy := a.assignmentService.GetStrategyPolicy()
+	c.JSON(http.StatusOK, gin.H{
+		"ok":                 true,
+		"effective_strategy": policy.EffectiveStrategy,
+	})
+}
+
+// DeleteStrategyPolicy 删除数据库覆盖，恢复为 .env（ASSIGNMENT_STRATEGY）。
+func (a *AssignmentController) DeleteStrategyPolicy(c *gin.Context) {
+	if !requirePermission(c, a.userService, string(service.PermSettings)) {
+		return
+	}
+	if err := a.assignmentService.ClearStrategy(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	policy := a.assignmentService.GetStrategyPolicy()
+	c.JSON(http.StatusOK, gin.H{
+		"ok":                 true,
+		"effective_strategy": policy.EffectiveStrategy,
+	})
+}
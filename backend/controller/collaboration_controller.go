@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// CollaborationController 负责多客服协作（邀请、离开、whisper 内部备注、参与记录）相关的 HTTP 请求。
+type CollaborationController struct {
+	collaborationService *service.CollaborationService
+	userService          *service.UserService
+}
+
+// NewCollaborationController 创建 CollaborationController 实例。
+func NewCollaborationController(collaborationService *service.CollaborationService, userService *service.UserService) *CollaborationController {
+	return &CollaborationController{
+		collaborationService: collaborationService,
+		userService:          userService,
+	}
+}
+
+type inviteParticipantRequest struct {
+	UserID uint   `json:"user_id"`
+	Note   string `json:"note"`
+}
+
+type createWhisperRequest struct {
+	Content string `json:"content"`
+}
+
+// writeCollaborationError 将服务层错误映射为 HTTP 响应
+func writeCollaborationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+	case errors.Is(err, service.ErrConversationClosed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "会话已关闭"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// InviteParticipant 邀请客服（如主管）加入会话协作。
+// POST /conversations/:id/invite
+func (cc *CollaborationController) InviteParticipant(c *gin.Context) {
+	if !requirePermission(c, cc.userService, string(service.PermChat)) {
+		return
+	}
+	conversationID, err := parseUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id 不合法"})
+		return
+	}
+	var req inviteParticipantRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	participant, err := cc.collaborationService.Invite(uint(conversationID), req.UserID, getUserIDFromHeader(c), req.Note)
+	if err != nil {
+		writeCollaborationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, participant)
+}
+
+// LeaveConversation 当前客服以协作者身份离开会话。
+// POST /conversations/:id/leave
+func (cc *CollaborationController) LeaveConversation(c *gin.Context) {
+	if !requirePermission(c, cc.userService, string(service.PermChat)) {
+		return
+	}
+	conversationID, err := parseUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id 不合法"})
+		return
+	}
+	if err := cc.collaborationService.Leave(uint(conversationID), getUserIDFromHeader(c)); err != nil {
+		writeCollaborationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// ListParticipants 返回会话的参与记录（负责人变更与协作者历史）。
+// GET /conversations/:id/participants
+func (cc *CollaborationController) ListParticipants(c *gin.Context) {
+	if !requirePermission(c, cc.userService, string(service.PermChat)) {
+		return
+	}
+	conversationID, err := parseUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id 不合法"})
+		return
+	}
+	list, err := cc.collaborationService.ListParticipants(uint(conversationID))
+	if err != nil {
+		writeCollaborationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// CreateWhisper 发送仅客服可见的 whisper 内部备注。
+// POST /conversations/:id/whispers
+func (cc *CollaborationController) CreateWhisper(c *gin.Context) {
+	if !requirePermission(c, cc.userService, string(service.PermChat)) {
+		return
+	}
+	conversationID, err := parseUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id 不合法"})
+		return
+	}
+	var req createWhisperRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	msg, err := cc.collaborationService.CreateWhisper(uint(conversationID), getUserIDFromHeader(c), req.Content)
+	if err != nil {
+		writeCollaborationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
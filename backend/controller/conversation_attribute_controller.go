@@ -0,0 +1,438 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/2930134478/AI-CS/backend/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ConversationAttributeController 负责会话标签、优先级与自定义字段相关的 HTTP 请求。
+// 给会话打标签 / 改优先级 / 填字段需要对话权限；维护自定义字段定义、修改或删除标签需要系统设置权限。
+type ConversationAttributeController struct {
+	attributeService *service.ConversationAttributeService
+	users            *service.UserService
+}
+
+// NewConversationAttributeController 创建 ConversationAttributeController 实例。
+func NewConversationAttributeController(attributeService *service.ConversationAttributeService, users *service.UserService) *ConversationAttributeController {
+	return &ConversationAttributeController{attributeService: attributeService, users: users}
+}
+
+type tagRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+type customFieldRequest struct {
+	Key       *string   `json:"key"`
+	Label     *string   `json:"label"`
+	FieldType *string   `json:"field_type"` // text | select | number
+	Options   *[]string `json:"options"`    // select 类型的选项
+	SortOrder *int      `json:"sort_order"`
+}
+
+type setConversationTagsRequest struct {
+	TagIDs []uint `json:"tag_ids"`
+}
+
+type addConversationTagRequest struct {
+	TagID uint   `json:"tag_id"`
+	Name  string `json:"name"` // 未提供 tag_id 时按名称添加（不存在则创建）
+}
+
+type setPriorityRequest struct {
+	Priority interface{} `json:"priority"` // low | normal | high | urgent 或 0-3
+}
+
+type setCustomFieldsRequest struct {
+	Values map[string]interface{} `json:"values"` // key -> 值；null 或空字符串表示清除
+}
+
+// writeAttributeError 将服务层错误映射为 HTTP 响应
+func writeAttributeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+	case errors.Is(err, service.ErrTagNotFound), errors.Is(err, service.ErrCustomFieldNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// ListTags 列出全部标签。
+// GET /agent/tags
+func (a *ConversationAttributeController) ListTags(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermChat)) {
+		return
+	}
+	tags, err := a.attributeService.ListTags()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// CreateTag 新建标签（客服可在打标签时直接创建）。
+// POST /agent/tags
+func (a *ConversationAttributeController) CreateTag(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermChat)) {
+		return
+	}
+	var req tagRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	color := ""
+	if req.Color != nil {
+		color = *req.Color
+	}
+	tag, err := a.attributeService.CreateTag(*req.Name, color)
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tag)
+}
+
+// UpdateTag 修改标签名称 / 颜色。
+// PUT /agent/tags/:id
+func (a *ConversationAttributeController) UpdateTag(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标签 ID 不合法"})
+		return
+	}
+	var req tagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	tag, err := a.attributeService.UpdateTag(uint(id), req.Name, req.Color)
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag 删除标签（同时从所有会话上移除）。
+// DELETE /agent/tags/:id
+func (a *ConversationAttributeController) DeleteTag(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标签 ID 不合法"})
+		return
+	}
+	if err := a.attributeService.DeleteTag(uint(id)); err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ListCustomFields 列出自定义字段定义。
+// GET /agent/custom-fields
+func (a *ConversationAttributeController) ListCustomFields(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermChat)) {
+		return
+	}
+	fields, err := a.attributeService.ListFieldDefinitions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询自定义字段失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fields": fields})
+}
+
+// CreateCustomField 新建自定义字段。
+// POST /agent/custom-fields
+func (a *ConversationAttributeController) CreateCustomField(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	var req customFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	field, err := a.attributeService.CreateFieldDefinition(req.toInput())
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, field)
+}
+
+// UpdateCustomField 修改自定义字段定义。
+// PUT /agent/custom-fields/:id
+func (a *ConversationAttributeController) UpdateCustomField(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "字段 ID 不合法"})
+		return
+	}
+	var req customFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	field, err := a.attributeService.UpdateFieldDefinition(uint(id), req.toInput())
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, field)
+}
+
+// DeleteCustomField 删除自定义字段及其全部取值。
+// DELETE /agent/custom-fields/:id
+func (a *ConversationAttributeController) DeleteCustomField(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "字段 ID 不合法"})
+		return
+	}
+	if err := a.attributeService.DeleteFieldDefinition(uint(id)); err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func (r customFieldRequest) toInput() service.CustomFieldInput {
+	return service.CustomFieldInput{
+		Key:       r.Key,
+		Label:     r.Label,
+		FieldType: r.FieldType,
+		Options:   r.Options,
+		SortOrder: r.SortOrder,
+	}
+}
+
+// GetConversationAttributes 读取会话的标签、优先级与自定义字段。
+// GET /conversations/:id/attributes
+func (a *ConversationAttributeController) GetConversationAttributes(c *gin.Context) {
+	conversationID, ok := a.conversationParam(c)
+	if !ok {
+		return
+	}
+	attrs, err := a.attributeService.GetAttributes(conversationID)
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attrs)
+}
+
+// SetConversationTags 覆盖会话的标签。
+// PUT /conversations/:id/tags
+func (a *ConversationAttributeController) SetConversationTags(c *gin.Context) {
+	conversationID, ok := a.conversationParam(c)
+	if !ok {
+		return
+	}
+	var req setConversationTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	attrs, err := a.attributeService.SetTags(conversationID, req.TagIDs, getUserIDFromHeader(c))
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attrs)
+}
+
+// AddConversationTag 为会话添加单个标签（按 tag_id 或名称）。
+// POST /conversations/:id/tags
+func (a *ConversationAttributeController) AddConversationTag(c *gin.Context) {
+	conversationID, ok := a.conversationParam(c)
+	if !ok {
+		return
+	}
+	var req addConversationTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.TagID == 0 && strings.TrimSpace(req.Name) == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	operatorID := getUserIDFromHeader(c)
+	if req.TagID == 0 {
+		if err := a.attributeService.AddTagByName(conversationID, req.Name, operatorID); err != nil {
+			writeAttributeError(c, err)
+			return
+		}
+		a.GetConversationAttributes(c)
+		return
+	}
+	attrs, err := a.attributeService.AddTag(conversationID, req.TagID, operatorID)
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attrs)
+}
+
+// RemoveConversationTag 移除会话的标签。
+// DELETE /conversations/:id/tags/:tag_id
+func (a *ConversationAttributeController) RemoveConversationTag(c *gin.Context) {
+	conversationID, ok := a.conversationParam(c)
+	if !ok {
+		return
+	}
+	tagID, err := parseUintParam(c, "tag_id")
+	if err != nil || tagID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标签 ID 不合法"})
+		return
+	}
+	attrs, err := a.attributeService.RemoveTag(conversationID, uint(tagID))
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attrs)
+}
+
+// SetConversationPriority 修改会话优先级。
+// PUT /conversations/:id/priority
+func (a *ConversationAttributeController) SetConversationPriority(c *gin.Context) {
+	conversationID, ok := a.conversationParam(c)
+	if !ok {
+		return
+	}
+	var req setPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if req.Priority == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	priority, err := service.ParsePriority(fmt.Sprint(req.Priority))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attrs, err := a.attributeService.SetPriority(conversationID, priority)
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attrs)
+}
+
+// SetConversationCustomFields 批量填写会话的自定义字段。
+// PUT /conversations/:id/custom-fields
+func (a *ConversationAttributeController) SetConversationCustomFields(c *gin.Context) {
+	conversationID, ok := a.conversationParam(c)
+	if !ok {
+		return
+	}
+	var req setCustomFieldsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Values) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	attrs, err := a.attributeService.SetFieldValues(conversationID, req.Values, getUserIDFromHeader(c))
+	if err != nil {
+		writeAttributeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attrs)
+}
+
+// conversationParam 校验对话权限并解析路径中的会话 ID
+func (a *ConversationAttributeController) conversationParam(c *gin.Context) (uint, bool) {
+	if !requirePermission(c, a.users, string(service.PermChat)) {
+		return 0, false
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "会话ID不合法"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseConversationListFilter 从查询参数解析会话列表的筛选与排序条件：
+//
+//	tag_ids=1,2          同时具备这些标签
+//	tags=退款,VIP        同上，按标签名称
+//	priority=high,urgent 优先级（名称或 0-3，命中任一）
+//	cf.<key>=值          自定义字段等于；cf.<key>.<op>=值 支持 contains / gt / gte / lt / lte
+//	sort=updated_at|created_at|priority|cf.<key>，order=asc|desc
+func parseConversationListFilter(c *gin.Context) (service.ConversationListFilter, error) {
+	filter := service.ConversationListFilter{
+		TagIDs:   utils.ParseUintList(c.Query("tag_ids")),
+		TagNames: utils.SplitTags(c.Query("tags")),
+		// 会话小结分类 / 解决状态（逗号分隔，命中任一）
+		Categories:  utils.SplitTags(c.Query("category")),
+		Resolutions: utils.SplitTags(c.Query("resolution")),
+	}
+	if v := c.Query("priority"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			p, err := service.ParsePriority(part)
+			if err != nil {
+				return filter, err
+			}
+			filter.Priorities = append(filter.Priorities, p)
+		}
+	}
+	for name, values := range c.Request.URL.Query() {
+		if !strings.HasPrefix(name, "cf.") || len(values) == 0 {
+			continue
+		}
+		key, op := strings.TrimPrefix(name, "cf."), ""
+		if i := strings.Index(key, "."); i >= 0 {
+			key, op = key[:i], key[i+1:]
+		}
+		filter.Fields = append(filter.Fields, service.CustomFieldCondition{Key: key, Op: op, Value: values[0]})
+	}
+	filter.SortBy = strings.TrimPrefix(c.Query("sort"), "cf.")
+	switch strings.ToLower(c.Query("order")) {
+	case "", "desc":
+	case "asc":
+		filter.SortAsc = true
+	default:
+		return filter, errors.New("order 仅支持 asc / desc")
+	}
+	return filter, nil
+}
+
+// withAttributeFields 为会话列表项追加标签、优先级与自定义字段
+func withAttributeFields(item gin.H, conv service.ConversationSummary) {
+	tags := conv.Tags
+	if tags == nil {
+		tags = []models.Tag{}
+	}
+	fields := conv.CustomFields
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	item["priority"] = conv.Priority
+	item["priority_label"] = service.PriorityLabel(conv.Priority)
+	item["tags"] = tags
+	item["custom_fields"] = fields
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -38,6 +39,15 @@ type initConversationRequest struct {
 	Language   string `json:"language"`
 	ChatMode   string `json:"chat_mode"`   // 对话模式：human（人工客服）、ai（AI客服）
 	AIConfigID *uint  `json:"ai_config_id"` // AI 配置 ID（访客选择的模型配置，AI 模式时必需）
+	// 知识库范围（可选）：显式指定 > 挂件 key > 按 website 域名匹配的绑定
+	WidgetKey        string `json:"widget_key"`
+	KnowledgeBaseIDs []uint `json:"knowledge_base_ids"`
+	// 技能标签（可选），用于按技能自动分配客服
+	Skills []string `json:"skills"`
+}
+
+type initInternalConversationRequest struct {
+	KnowledgeBaseIDs []uint `json:"knowledge_base_ids"` // 可选，限定测试会话检索的知识库
 }
 
 type updateContactRequest struct {
@@ -76,6 +86,10 @@ func (cc *ConversationController) InitConversation(c *gin.Context) {
 		IPAddress:  utils.GetClientIP(c),
 		ChatMode:   req.ChatMode,
 		AIConfigID: req.AIConfigID,
+
+		WidgetKey:        req.WidgetKey,
+		KnowledgeBaseIDs: req.KnowledgeBaseIDs,
+		Skills:           req.Skills,
 	})
 
 	if err != nil {
@@ -100,7 +114,15 @@ func (cc *ConversationController) InitInternalConversation(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问，请登录"})
 		return
 	}
-	result, err := cc.conversationService.InitInternalConversation(userID)
+	// 请求体可选：不传则检索全部参与 RAG 的知识库
+	var req initInternalConversationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+	result, err := cc.conversationService.InitInternalConversation(userID, req.KnowledgeBaseIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -224,7 +246,16 @@ func (cc *ConversationController) ListConversations(c *gin.Context) {
 		if !requirePermission(c, cc.users, string(service.PermChat)) {
 			return
 		}
-		listResult, err = cc.conversationService.ListConversationsPaginated(userID, status, page, pageSize)
+		filter, parseErr := parseConversationListFilter(c)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": parseErr.Error()})
+			return
+		}
+		listResult, err = cc.conversationService.ListConversationsPaginated(userID, status, page, pageSize, filter)
+	}
+	if errors.Is(err, service.ErrInvalidListFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询对话列表失败"})
@@ -257,6 +288,8 @@ func formatConversationListItems(conversations []service.ConversationSummary) []
 			"unread_count":      conv.UnreadCount,
 			"has_participated":  conv.HasParticipated,
 		}
+		withAttributeFields(item, conv)
+		withSummaryFields(item, conv)
 		if lastSeen := formatTimePointer(conv.LastSeenAt); lastSeen != "" {
 			item["last_seen_at"] = lastSeen
 		}
@@ -276,6 +309,22 @@ func formatConversationListItems(conversations []service.ConversationSummary) []
 	return items
 }
 
+// withSummaryFields 为会话列表项 / 详情追加会话小结（关闭后由 AI 生成）
+func withSummaryFields(item gin.H, conv service.ConversationSummary) {
+	entities := conv.KeyEntities
+	if entities == nil {
+		entities = []string{}
+	}
+	item["summary"] = conv.Summary
+	item["category"] = conv.Category
+	item["resolution"] = conv.Resolution
+	item["key_entities"] = entities
+	item["summary_status"] = conv.SummaryStatus
+	if summarizedAt := formatTimePointer(conv.SummarizedAt); summarizedAt != "" {
+		item["summarized_at"] = summarizedAt
+	}
+}
+
 // GetConversationDetail 返回会话的详细信息。
 func (cc *ConversationController) GetConversationDetail(c *gin.Context) {
 	id, err := parseUintParam(c, "id")
@@ -308,9 +357,14 @@ func (cc *ConversationController) GetConversationDetail(c *gin.Context) {
 		"updated_at":   formatTimeValue(detail.UpdatedAt),
 		"unread_count": detail.UnreadCount,
 	}
+	withAttributeFields(response, detail.ConversationSummary)
+	withSummaryFields(response, detail.ConversationSummary)
 	if lastSeen := formatTimePointer(detail.LastSeen); lastSeen != "" {
 		response["last_seen_at"] = lastSeen
 	}
+	if len(detail.KnowledgeBaseIDs) > 0 {
+		response["knowledge_base_ids"] = detail.KnowledgeBaseIDs
+	}
 	if detail.LastMessage != nil {
 		response["last_message"] = gin.H{
 			"id":              detail.LastMessage.ID,
@@ -340,7 +394,17 @@ func (cc *ConversationController) SearchConversations(c *gin.Context) {
 	convType := c.DefaultQuery("type", "visitor")
 	userID := getUserIDFromHeader(c)
 
-	conversations, err := cc.conversationService.SearchConversations(query, userID, status, convType)
+	filter, err := parseConversationListFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversations, err := cc.conversationService.SearchConversations(query, userID, status, convType, filter)
+	if errors.Is(err, service.ErrInvalidListFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
 		return
@@ -358,6 +422,8 @@ func (cc *ConversationController) SearchConversations(c *gin.Context) {
 			"unread_count":     conv.UnreadCount,
 			"has_participated": conv.HasParticipated, // 当前用户是否参与过该会话
 		}
+		withAttributeFields(item, conv)
+		withSummaryFields(item, conv)
 
 		// 添加 last_seen_at 字段（用于判断在线状态）
 		if lastSeen := formatTimePointer(conv.LastSeenAt); lastSeen != "" {
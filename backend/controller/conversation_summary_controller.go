@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ConversationSummaryController 负责会话小结、会话分类与小结配置相关的 HTTP 请求。
+// 查看分类与重新生成小结需要对话权限；维护分类与配置需要系统设置权限。
+type ConversationSummaryController struct {
+	summaryService *service.ConversationSummaryService
+	users          *service.UserService
+}
+
+// NewConversationSummaryController 创建 ConversationSummaryController 实例。
+func NewConversationSummaryController(summaryService *service.ConversationSummaryService, users *service.UserService) *ConversationSummaryController {
+	return &ConversationSummaryController{summaryService: summaryService, users: users}
+}
+
+type conversationCategoryRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"` // 分类说明，帮助模型判断
+	SortOrder   *int    `json:"sort_order"`
+}
+
+func (r conversationCategoryRequest) toInput() service.ConversationCategoryInput {
+	return service.ConversationCategoryInput{Name: r.Name, Description: r.Description, SortOrder: r.SortOrder}
+}
+
+// writeSummaryError 将服务层错误映射为 HTTP 响应
+func writeSummaryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrConversationCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// ListCategories 列出会话分类（用于列表筛选与分类维护）。
+// GET /agent/conversation-categories
+func (sc *ConversationSummaryController) ListCategories(c *gin.Context) {
+	if !requirePermission(c, sc.users, string(service.PermChat)) {
+		return
+	}
+	categories, err := sc.summaryService.ListCategories()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询分类失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// CreateCategory 新建会话分类。
+// POST /agent/conversation-categories
+func (sc *ConversationSummaryController) CreateCategory(c *gin.Context) {
+	if !requirePermission(c, sc.users, string(service.PermSettings)) {
+		return
+	}
+	var req conversationCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	category, err := sc.summaryService.CreateCategory(req.toInput())
+	if err != nil {
+		writeSummaryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory 修改会话分类。
+// PUT /agent/conversation-categories/:id
+func (sc *ConversationSummaryController) UpdateCategory(c *gin.Context) {
+	if !requirePermission(c, sc.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分类 ID 不合法"})
+		return
+	}
+	var req conversationCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	category, err := sc.summaryService.UpdateCategory(uint(id), req.toInput())
+	if err != nil {
+		writeSummaryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory 删除会话分类。
+// DELETE /agent/conversation-categories/:id
+func (sc *ConversationSummaryController) DeleteCategory(c *gin.Context) {
+	if !requirePermission(c, sc.users, string(service.PermSettings)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分类 ID 不合法"})
+		return
+	}
+	if err := sc.summaryService.DeleteCategory(uint(id)); err != nil {
+		writeSummaryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// Regenerate 立即（重新）生成会话小结。
+// POST /conversations/:id/summary
+func (sc *ConversationSummaryController) Regenerate(c *gin.Context) {
+	if !requirePermission(c, sc.users, string(service.PermChat)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "会话ID不合法"})
+		return
+	}
+	conv, err := sc.summaryService.Summarize(uint(id))
+	if err != nil {
+		writeSummaryError(c, err)
+		return
+	}
+	entities := conv.KeyEntities
+	if entities == nil {
+		entities = []string{}
+	}
+	response := gin.H{
+		"id":             conv.ID,
+		"summary":        conv.Summary,
+		"category":       conv.Category,
+		"resolution":     conv.Resolution,
+		"key_entities":   entities,
+		"summary_status": conv.SummaryStatus,
+	}
+	if summarizedAt := formatTimePointer(conv.SummarizedAt); summarizedAt != "" {
+		response["summarized_at"] = summarizedAt
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetSettings 读取会话小结配置。
+// GET /agent/conversation-summary/settings
+func (sc *ConversationSummaryController) GetSettings(c *gin.Context) {
+	if !requirePermission(c, sc.users, string(service.PermSettings)) {
+		return
+	}
+	c.JSON(http.StatusOK, sc.summaryService.GetSettings())
+}
+
+// UpdateSettings 保存会话小结配置。
+// PUT /agent/conversation-summary/settings
+func (sc *ConversationSummaryController) UpdateSettings(c *gin.Context) {
+	if !requirePermission(c, sc.users, string(service.PermSettings)) {
+		return
+	}
+	var req service.ConversationSummarySettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	settings, err := sc.summaryService.UpdateSettings(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
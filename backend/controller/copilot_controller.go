@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// CopilotController 负责客服助手（人工会话的 AI 候选回复）相关的 HTTP 请求。
+// 查看 / 起草 / 反馈需要对话权限；开关与模型设置需要 settings 权限。
+type CopilotController struct {
+	copilotService *service.CopilotService
+	users          *service.UserService
+}
+
+// NewCopilotController 创建 CopilotController 实例。
+func NewCopilotController(copilotService *service.CopilotService, users *service.UserService) *CopilotController {
+	return &CopilotController{copilotService: copilotService, users: users}
+}
+
+type copilotFeedbackRequest struct {
+	Action        string `json:"action"`          // accepted | edited | dismissed
+	FinalContent  string `json:"final_content"`   // 实际发送的文本（accepted / edited）
+	SentMessageID uint   `json:"sent_message_id"` // 实际发送的消息 ID（可选）
+}
+
+// writeCopilotError 将服务层错误映射为 HTTP 响应
+func writeCopilotError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCopilotSuggestionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// ListSuggestions 获取会话中待处理的草稿。
+// GET /agent/conversations/:id/copilot
+func (cc *CopilotController) ListSuggestions(c *gin.Context) {
+	if !requirePermission(c, cc.users, string(service.PermChat)) {
+		return
+	}
+	conversationID, err := parseUintParam(c, "id")
+	if err != nil || conversationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id 不合法"})
+		return
+	}
+	suggestions, err := cc.copilotService.ListPending(uint(conversationID))
+	if err != nil {
+		writeCopilotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+// Suggest 针对会话最新访客消息重新起草（同时通过 WebSocket copilot_suggestions 推送给会话内客服）。
+// POST /agent/conversations/:id/copilot
+func (cc *CopilotController) Suggest(c *gin.Context) {
+	if !requirePermission(c, cc.users, string(service.PermChat)) {
+		return
+	}
+	conversationID, err := parseUintParam(c, "id")
+	if err != nil || conversationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id 不合法"})
+		return
+	}
+	suggestions, err := cc.copilotService.Suggest(uint(conversationID), getUserIDFromHeader(c))
+	if err != nil {
+		writeCopilotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+// Feedback 记录客服对草稿的处理（原样发送 / 修改后发送 / 忽略）。
+// POST /agent/copilot/suggestions/:id/feedback
+func (cc *CopilotController) Feedback(c *gin.Context) {
+	if !requirePermission(c, cc.users, string(service.PermChat)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "草稿 ID 不合法"})
+		return
+	}
+	var req copilotFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	suggestion, err := cc.copilotService.Feedback(uint(id), getUserIDFromHeader(c), service.CopilotFeedbackInput{
+		Action:        req.Action,
+		FinalContent:  req.FinalContent,
+		SentMessageID: req.SentMessageID,
+	})
+	if err != nil {
+		writeCopilotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// GetSettings 读取客服助手配置。
+// GET /agent/copilot/settings
+func (cc *CopilotController) GetSettings(c *gin.Context) {
+	if !requirePermission(c, cc.users, string(service.PermSettings)) {
+		return
+	}
+	c.JSON(http.StatusOK, cc.copilotService.GetSettings())
+}
+
+// UpdateSettings 保存客服助手配置。
+// PUT /agent/copilot/settings
+func (cc *CopilotController) UpdateSettings(c *gin.Context) {
+	if !requirePermission(c, cc.users, string(service.PermSettings)) {
+		return
+	}
+	var req service.CopilotSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	settings, err := cc.copilotService.UpdateSettings(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
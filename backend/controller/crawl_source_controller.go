@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// CrawlSourceController 负责网站抓取来源相关的 HTTP 请求（需要知识库权限）。
+type CrawlSourceController struct {
+	crawlService           *service.CrawlService
+	embeddingConfigService *service.EmbeddingConfigService
+	users                  *service.UserService
+}
+
+// NewCrawlSourceController 创建 CrawlSourceController 实例。
+func NewCrawlSourceController(crawlService *service.CrawlService, embeddingConfigService *service.EmbeddingConfigService, users *service.UserService) *CrawlSourceController {
+	return &CrawlSourceController{
+		crawlService:           crawlService,
+		embeddingConfigService: embeddingConfigService,
+		users:                  users,
+	}
+}
+
+type crawlSourceRequest struct {
+	KnowledgeBaseID uint    `json:"knowledge_base_id"`
+	SeedURL         *string `json:"seed_url"`
+	MaxDepth        *int    `json:"max_depth"`
+	MaxPages        *int    `json:"max_pages"`
+	DelayMs         *int    `json:"delay_ms"`
+	IntervalHours   *int    `json:"interval_hours"`
+	ChunkMethod     *string `json:"chunk_method"`
+	RunNow          bool    `json:"run_now"` // 仅创建时使用：创建后立即抓取
+}
+
+func (r crawlSourceRequest) toInput() service.CrawlSourceInput {
+	return service.CrawlSourceInput{
+		KnowledgeBaseID: r.KnowledgeBaseID,
+		SeedURL:         r.SeedURL,
+		MaxDepth:        r.MaxDepth,
+		MaxPages:        r.MaxPages,
+		DelayMs:         r.DelayMs,
+		IntervalHours:   r.IntervalHours,
+		ChunkMethod:     r.ChunkMethod,
+	}
+}
+
+// writeCrawlSourceError 将服务层错误映射为 HTTP 响应
+func writeCrawlSourceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCrawlSourceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrCrawlRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+func (cc *CrawlSourceController) authorize(c *gin.Context) bool {
+	if !requirePermission(c, cc.users, string(service.PermKnowledge)) {
+		return false
+	}
+	if err := cc.embeddingConfigService.CheckKnowledgeBaseAccess(getUserIDFromHeader(c)); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// ListSources 列出网站抓取来源。
+// GET /import/crawl-sources?knowledge_base_id=1
+func (cc *CrawlSourceController) ListSources(c *gin.Context) {
+	if !cc.authorize(c) {
+		return
+	}
+	var kbID uint64
+	if v := c.Query("knowledge_base_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "知识库 ID 不合法"})
+			return
+		}
+		kbID = id
+	}
+	sources, err := cc.crawlService.ListSources(uint(kbID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询抓取来源失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sources": sources})
+}
+
+// CreateSource 创建网站抓取来源（run_now 为 true 时立即开始抓取）。
+// POST /import/crawl-sources
+func (cc *CrawlSourceController) CreateSource(c *gin.Context) {
+	if !cc.authorize(c) {
+		return
+	}
+	var req crawlSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if req.KnowledgeBaseID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "知识库 ID 不能为空"})
+		return
+	}
+	source, err := cc.crawlService.CreateSource(req.toInput())
+	if err != nil {
+		writeCrawlSourceError(c, err)
+		return
+	}
+	if req.RunNow {
+		if running, err := cc.crawlService.RunSource(source.ID); err == nil {
+			source = running
+		}
+	}
+	c.JSON(http.StatusOK, source)
+}
+
+// UpdateSource 更新网站抓取来源。
+// PUT /import/crawl-sources/:id
+func (cc *CrawlSourceController) UpdateSource(c *gin.Context) {
+	if !cc.authorize(c) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "抓取来源 ID 不合法"})
+		return
+	}
+	var req crawlSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	source, err := cc.crawlService.UpdateSource(uint(id), req.toInput())
+	if err != nil {
+		writeCrawlSourceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, source)
+}
+
+// DeleteSource 删除网站抓取来源（已导入的文档保留）。
+// DELETE /import/crawl-sources/:id
+func (cc *CrawlSourceController) DeleteSource(c *gin.Context) {
+	if !cc.authorize(c) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "抓取来源 ID 不合法"})
+		return
+	}
+	if err := cc.crawlService.DeleteSource(uint(id)); err != nil {
+		writeCrawlSourceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// RunSource 立即在后台抓取。
+// POST /import/crawl-sources/:id/run
+func (cc *CrawlSourceController) RunSource(c *gin.Context) {
+	if !cc.authorize(c) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "抓取来源 ID 不合法"})
+		return
+	}
+	source, err := cc.crawlService.RunSource(uint(id))
+	if err != nil {
+		writeCrawlSourceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, source)
+}
@@ -44,8 +44,8 @@ func (c *DocumentChunkController) ExecuteChunking(ctx *gin.Context) {
 		return
 	}
 
-	if req.Method != "char_count" && req.Method != "separator" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "分段方式必须为 char_count 或 separator"})
+	if !service.IsValidChunkMethod(req.Method) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "分段方式必须为 char_count、separator、recursive 或 markdown"})
 		return
 	}
 
@@ -63,6 +63,43 @@ func (c *DocumentChunkController) ExecuteChunking(ctx *gin.Context) {
 	})
 }
 
+// PreviewChunking 预览分段结果（不保存、不向量化）
+// POST /api/documents/:id/chunks/preview
+func (c *DocumentChunkController) PreviewChunking(ctx *gin.Context) {
+	if !requirePermission(ctx, c.users, string(service.PermKnowledge)) {
+		return
+	}
+
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil || id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "文档 ID 不合法"})
+		return
+	}
+
+	var req service.ChunkRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !service.IsValidChunkMethod(req.Method) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "分段方式必须为 char_count、separator、recursive 或 markdown"})
+		return
+	}
+
+	previews, err := c.chunkService.PreviewChunking(uint(id), req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"chunk_count": len(previews),
+		"chunks":      previews,
+	})
+}
+
 // GetChunks 获取文档分段列表
 // GET /api/documents/:id/chunks?page=1&page_size=20
 func (c *DocumentChunkController) GetChunks(ctx *gin.Context) {
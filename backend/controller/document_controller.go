@@ -206,8 +206,12 @@ func (c *DocumentController) DeleteDocument(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
 
-// SearchDocuments 向量检索搜索文档
+// SearchDocuments 检索搜索文档（默认向量检索，可用 mode=vector|keyword|hybrid 切换）
 func (c *DocumentController) SearchDocuments(ctx *gin.Context) {
+	c.searchDocuments(ctx, "vector")
+}
+
+func (c *DocumentController) searchDocuments(ctx *gin.Context, defaultMode string) {
 	if !requirePermission(ctx, c.users, string(service.PermKnowledge)) {
 		return
 	}
@@ -237,22 +241,38 @@ func (c *DocumentController) SearchDocuments(ctx *gin.Context) {
 		}
 	}
 
-	docs, err := c.documentService.SearchDocuments(query, topK, knowledgeBaseID)
+	mode := ctx.DefaultQuery("mode", defaultMode)
+	if mode != "vector" && mode != "keyword" && mode != "hybrid" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "mode 仅支持 vector / keyword / hybrid"})
+		return
+	}
+
+	// 可选：按分段来源过滤（page_from / page_to 页码范围，heading 标题路径前缀）
+	filter := service.DocumentSearchFilter{HeadingPrefix: ctx.Query("heading")}
+	filter.PageFrom, _ = strconv.Atoi(ctx.Query("page_from"))
+	filter.PageTo, _ = strconv.Atoi(ctx.Query("page_to"))
+	if filter.PageFrom < 0 || filter.PageTo < 0 || (filter.PageTo > 0 && filter.PageFrom > filter.PageTo) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "页码范围不合法"})
+		return
+	}
+
+	docs, err := c.documentService.SearchDocuments(query, topK, knowledgeBaseID, mode, filter)
 	if err != nil {
 		log.Printf("搜索文档失败: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "向量检索失败: " + err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "检索失败: " + err.Error()})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"count":     len(docs),
+		"mode":      mode,
 		"documents": docs,
 	})
 }
 
-// HybridSearchDocuments 混合检索搜索文档（当前实现与向量检索相同）
+// HybridSearchDocuments 混合检索搜索文档（向量 + 关键词，RRF 融合；可用 mode 参数切换）
 func (c *DocumentController) HybridSearchDocuments(ctx *gin.Context) {
-	c.SearchDocuments(ctx)
+	c.searchDocuments(ctx, "hybrid")
 }
 
 // UpdateDocumentStatus 更新文档状态
@@ -40,13 +40,26 @@ func (e *EmbeddingConfigController) Update(c *gin.Context) {
 		return
 	}
 	var req struct {
-		EmbeddingType           *string `json:"embedding_type"`
-		APIURL                  *string `json:"api_url"`
-		APIKey                  *string `json:"api_key"`
-		Model                   *string `json:"model"`
-		CustomerCanUseKB        *bool   `json:"customer_can_use_kb"`
-		VisitorWebSearchEnabled *bool   `json:"visitor_web_search_enabled"`
-		WebSearchSource         *string `json:"web_search_source"`
+		EmbeddingType           *string  `json:"embedding_type"`
+		APIURL                  *string  `json:"api_url"`
+		APIKey                  *string  `json:"api_key"`
+		Model                   *string  `json:"model"`
+		CustomerCanUseKB        *bool    `json:"customer_can_use_kb"`
+		VisitorWebSearchEnabled *bool    `json:"visitor_web_search_enabled"`
+		WebSearchSource         *string  `json:"web_search_source"`
+		RetrievalMode           *string  `json:"retrieval_mode"`
+		RerankEnabled           *bool    `json:"rerank_enabled"`
+		RerankAPIURL            *string  `json:"rerank_api_url"`
+		RerankAPIKey            *string  `json:"rerank_api_key"`
+		RerankModel             *string  `json:"rerank_model"`
+		RerankCandidates        *int     `json:"rerank_candidates"`
+		RerankTimeoutMs         *int     `json:"rerank_timeout_ms"`
+		FAQMatchThreshold       *float64 `json:"faq_match_threshold"`
+		FAQContextThreshold     *float64 `json:"faq_context_threshold"`
+		FAQVerifyEnabled        *bool    `json:"faq_verify_enabled"`
+		QueryRewriteEnabled     *bool    `json:"query_rewrite_enabled"`
+		QueryRewriteAIConfigID  *uint    `json:"query_rewrite_ai_config_id"`
+		QueryRewriteMaxQueries  *int     `json:"query_rewrite_max_queries"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
@@ -61,6 +74,19 @@ func (e *EmbeddingConfigController) Update(c *gin.Context) {
 		CustomerCanUseKB:        req.CustomerCanUseKB,
 		VisitorWebSearchEnabled: req.VisitorWebSearchEnabled,
 		WebSearchSource:         req.WebSearchSource,
+		RetrievalMode:           req.RetrievalMode,
+		RerankEnabled:           req.RerankEnabled,
+		RerankAPIURL:            req.RerankAPIURL,
+		RerankAPIKey:            req.RerankAPIKey,
+		RerankModel:             req.RerankModel,
+		RerankCandidates:        req.RerankCandidates,
+		RerankTimeoutMs:         req.RerankTimeoutMs,
+		FAQMatchThreshold:       req.FAQMatchThreshold,
+		FAQContextThreshold:     req.FAQContextThreshold,
+		FAQVerifyEnabled:        req.FAQVerifyEnabled,
+		QueryRewriteEnabled:     req.QueryRewriteEnabled,
+		QueryRewriteAIConfigID:  req.QueryRewriteAIConfigID,
+		QueryRewriteMaxQueries:  req.QueryRewriteMaxQueries,
 	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// HandoffController 负责 AI 转人工相关的 HTTP 请求。
+type HandoffController struct {
+	handoffService      *service.HandoffService
+	messageService      *service.MessageService
+	conversationService *service.ConversationService
+	userService         *service.UserService
+}
+
+// NewHandoffController 创建 HandoffController 实例。
+func NewHandoffController(
+	handoffService *service.HandoffService,
+	messageService *service.MessageService,
+	conversationService *service.ConversationService,
+	userService *service.UserService,
+) *HandoffController {
+	return &HandoffController{
+		handoffService:      handoffService,
+		messageService:      messageService,
+		conversationService: conversationService,
+		userService:         userService,
+	}
+}
+
+// RequestHandoff 访客主动请求转人工（如小窗「转人工」按钮）。
+// POST /conversations/:id/handoff
+func (h *HandoffController) RequestHandoff(c *gin.Context) {
+	conversationID, err := parseUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id 不合法"})
+		return
+	}
+	if _, ok := authorizeConversationAccess(c, h.conversationService, h.userService, uint(conversationID)); !ok {
+		return
+	}
+
+	h.messageService.CancelAIReply(uint(conversationID))
+	result, err := h.handoffService.RequestHandoff(uint(conversationID), service.HandoffReasonVisitor, "")
+	if err != nil {
+		if errors.Is(err, service.ErrConversationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "转人工失败"})
+		return
+	}
+	if result == nil {
+		// 已是人工模式，无需转接
+		c.JSON(http.StatusOK, gin.H{"conversation_id": conversationID, "transferred": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversationID,
+		"transferred":     true,
+		"queue_position":  result.QueuePosition,
+	})
+}
+
+// ListQueue 返回排队中的转人工会话（先到先服务）。
+// GET /conversations/handoff-queue
+func (h *HandoffController) ListQueue(c *gin.Context) {
+	if !requirePermission(c, h.userService, string(service.PermChat)) {
+		return
+	}
+	items, err := h.handoffService.ListQueue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
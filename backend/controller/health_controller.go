@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/2930134478/AI-CS/backend/service"
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,6 +12,7 @@ import (
 type HealthController struct {
 	healthChecker interface{} // rag.HealthChecker
 	retrievalService interface{} // rag.RetrievalService
+	mcpService       *service.MCPServerService // 可选，MCP 服务连接状态
 }
 
 // NewHealthController 创建健康检查控制器实例
@@ -21,6 +23,19 @@ func NewHealthController(healthChecker interface{}, retrievalService interface{}
 	}
 }
 
+// SetMCPServerService 设置 MCP 服务管理，健康检查结果中附带各服务连接状态
+func (c *HealthController) SetMCPServerService(svc *service.MCPServerService) {
+	c.mcpService = svc
+}
+
+// mcpStatus 各 MCP 服务连接状态（不影响整体健康状态）
+func (c *HealthController) mcpStatus() []service.MCPServerStatus {
+	if c.mcpService == nil {
+		return []service.MCPServerStatus{}
+	}
+	return c.mcpService.Health()
+}
+
 // HealthCheck 健康检查
 func (c *HealthController) HealthCheck(ctx *gin.Context) {
 	// 类型断言获取 healthChecker
@@ -33,6 +48,7 @@ func (c *HealthController) HealthCheck(ctx *gin.Context) {
 		ctx.JSON(http.StatusOK, gin.H{
 			"status": "healthy",
 			"message": "健康检查器未初始化",
+			"mcp_servers": c.mcpStatus(),
 		})
 		return
 	}
@@ -56,6 +72,7 @@ func (c *HealthController) HealthCheck(ctx *gin.Context) {
 			}
 			return ""
 		}(),
+		"mcp_servers": c.mcpStatus(),
 	})
 }
 
@@ -13,14 +13,16 @@ import (
 type KnowledgeBaseController struct {
 	knowledgeBaseService   *service.KnowledgeBaseService
 	embeddingConfigService *service.EmbeddingConfigService
+	bindingService         *service.KnowledgeBaseBindingService
 	users                  *service.UserService
 }
 
 // NewKnowledgeBaseController 创建知识库控制器实例
-func NewKnowledgeBaseController(knowledgeBaseService *service.KnowledgeBaseService, embeddingConfigService *service.EmbeddingConfigService, users *service.UserService) *KnowledgeBaseController {
+func NewKnowledgeBaseController(knowledgeBaseService *service.KnowledgeBaseService, embeddingConfigService *service.EmbeddingConfigService, bindingService *service.KnowledgeBaseBindingService, users *service.UserService) *KnowledgeBaseController {
 	return &KnowledgeBaseController{
 		knowledgeBaseService:   knowledgeBaseService,
 		embeddingConfigService: embeddingConfigService,
+		bindingService:         bindingService,
 		users:                  users,
 	}
 }
@@ -219,3 +221,93 @@ func (c *KnowledgeBaseController) ListDocumentsByKnowledgeBase(ctx *gin.Context)
 	// 这个功能由 DocumentController 实现，这里可以重定向或调用
 	ctx.JSON(http.StatusOK, gin.H{"message": "请使用 /documents?knowledge_base_id=:id"})
 }
+
+type knowledgeBaseBindingRequest struct {
+	Name             string `json:"name"`
+	WidgetKey        string `json:"widget_key"`
+	SiteHost         string `json:"site_host"`
+	KnowledgeBaseIDs []uint `json:"knowledge_base_ids"`
+	Enabled          *bool  `json:"enabled"`
+}
+
+func (r knowledgeBaseBindingRequest) toInput() service.KnowledgeBaseBindingInput {
+	return service.KnowledgeBaseBindingInput{
+		Name:             r.Name,
+		WidgetKey:        r.WidgetKey,
+		SiteHost:         r.SiteHost,
+		KnowledgeBaseIDs: r.KnowledgeBaseIDs,
+		Enabled:          r.Enabled,
+	}
+}
+
+// ListBindings 获取挂件/站点与知识库的绑定列表
+func (c *KnowledgeBaseController) ListBindings(ctx *gin.Context) {
+	if !requirePermission(ctx, c.users, string(service.PermKnowledge)) {
+		return
+	}
+	list, err := c.bindingService.ListBindings()
+	if err != nil {
+		log.Printf("获取知识库绑定列表失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取知识库绑定列表失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"bindings": list})
+}
+
+// CreateBinding 创建挂件/站点与知识库的绑定
+func (c *KnowledgeBaseController) CreateBinding(ctx *gin.Context) {
+	if !requirePermission(ctx, c.users, string(service.PermKnowledge)) {
+		return
+	}
+	var req knowledgeBaseBindingRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	b, err := c.bindingService.CreateBinding(req.toInput())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, b)
+}
+
+// UpdateBinding 更新挂件/站点与知识库的绑定
+func (c *KnowledgeBaseController) UpdateBinding(ctx *gin.Context) {
+	if !requirePermission(ctx, c.users, string(service.PermKnowledge)) {
+		return
+	}
+	id, err := parseUintParam(ctx, "id")
+	if err != nil || id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "绑定 ID 不合法"})
+		return
+	}
+	var req knowledgeBaseBindingRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	b, err := c.bindingService.UpdateBinding(uint(id), req.toInput())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, b)
+}
+
+// DeleteBinding 删除挂件/站点与知识库的绑定
+func (c *KnowledgeBaseController) DeleteBinding(ctx *gin.Context) {
+	if !requirePermission(ctx, c.users, string(service.PermKnowledge)) {
+		return
+	}
+	id, err := parseUintParam(ctx, "id")
+	if err != nil || id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "绑定 ID 不合法"})
+		return
+	}
+	if err := c.bindingService.DeleteBinding(uint(id)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,338 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// MacroController 负责快捷回复（宏）相关的 HTTP 请求。
+// 查看需要对话权限；解析 / 发送按会话校验（与发送消息相同）；创建、修改、删除宏与分组需要 macros 权限。
+type MacroController struct {
+	macroService        *service.MacroService
+	conversationService *service.ConversationService
+	users               *service.UserService
+}
+
+// NewMacroController 创建 MacroController 实例。
+func NewMacroController(macroService *service.MacroService, conversationService *service.ConversationService, users *service.UserService) *MacroController {
+	return &MacroController{macroService: macroService, conversationService: conversationService, users: users}
+}
+
+type macroRequest struct {
+	Title    *string                `json:"title"`
+	Shortcut *string                `json:"shortcut"`
+	Content  *string                `json:"content"`
+	FolderID *uint                  `json:"folder_id"`
+	Scope    *string                `json:"scope"` // personal | team
+	Actions  *[]service.MacroAction `json:"actions"`
+}
+
+func (r macroRequest) toInput() service.MacroInput {
+	return service.MacroInput{
+		Title:    r.Title,
+		Shortcut: r.Shortcut,
+		Content:  r.Content,
+		FolderID: r.FolderID,
+		Scope:    r.Scope,
+		Actions:  r.Actions,
+	}
+}
+
+type macroFolderRequest struct {
+	Name      *string `json:"name"`
+	Scope     *string `json:"scope"`
+	SortOrder *int    `json:"sort_order"`
+}
+
+type renderMacroRequest struct {
+	ConversationID uint   `json:"conversation_id"`
+	Shortcut       string `json:"shortcut"` // 仅 /agent/macros/render 使用
+}
+
+type sendMacroRequest struct {
+	ConversationID uint   `json:"conversation_id"`
+	Content        string `json:"content"` // 可选：客服编辑后的内容（仍会解析变量）
+}
+
+// writeMacroError 将服务层错误映射为 HTTP 响应
+func writeMacroError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrMacroNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+	case errors.Is(err, service.ErrConversationClosed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "会话已关闭"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// authorizeConversation 校验客服对会话的访问权限：访客会话需要对话权限，
+// 内部会话仅创建者可用且需要知识库测试权限（与发送消息一致）。
+func (m *MacroController) authorizeConversation(c *gin.Context, conversationID uint) bool {
+	if getUserIDFromHeader(c) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问，请登录"})
+		return false
+	}
+	_, ok := authorizeConversationAccess(c, m.conversationService, m.users, conversationID)
+	return ok
+}
+
+// ListMacros 列出当前客服可见的快捷回复（个人 + 团队）。
+// GET /agent/macros?folder_id=1&q=退款
+func (m *MacroController) ListMacros(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermChat)) {
+		return
+	}
+	var folderID *uint
+	if v := c.Query("folder_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "folder_id 不合法"})
+			return
+		}
+		fid := uint(id)
+		folderID = &fid
+	}
+	macros, err := m.macroService.ListMacros(getUserIDFromHeader(c), folderID, c.Query("q"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询快捷回复失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"macros": macros})
+}
+
+// GetMacro 获取快捷回复详情。
+// GET /agent/macros/:id
+func (m *MacroController) GetMacro(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermChat)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "快捷回复 ID 不合法"})
+		return
+	}
+	macro, err := m.macroService.GetMacro(uint(id), getUserIDFromHeader(c))
+	if err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, macro)
+}
+
+// CreateMacro 创建快捷回复。
+// POST /agent/macros
+func (m *MacroController) CreateMacro(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermMacros)) {
+		return
+	}
+	var req macroRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	macro, err := m.macroService.CreateMacro(getUserIDFromHeader(c), req.toInput())
+	if err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, macro)
+}
+
+// UpdateMacro 更新快捷回复。
+// PUT /agent/macros/:id
+func (m *MacroController) UpdateMacro(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermMacros)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "快捷回复 ID 不合法"})
+		return
+	}
+	var req macroRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	macro, err := m.macroService.UpdateMacro(uint(id), getUserIDFromHeader(c), req.toInput())
+	if err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, macro)
+}
+
+// DeleteMacro 删除快捷回复。
+// DELETE /agent/macros/:id
+func (m *MacroController) DeleteMacro(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermMacros)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "快捷回复 ID 不合法"})
+		return
+	}
+	if err := m.macroService.DeleteMacro(uint(id), getUserIDFromHeader(c)); err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// RenderMacro 按会话解析快捷回复中的变量，供客服预览 / 编辑后发送。
+// POST /agent/macros/:id/render
+func (m *MacroController) RenderMacro(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "快捷回复 ID 不合法"})
+		return
+	}
+	var req renderMacroRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ConversationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if !m.authorizeConversation(c, req.ConversationID) {
+		return
+	}
+	rendered, err := m.macroService.Render(uint(id), req.ConversationID, getUserIDFromHeader(c))
+	if err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
+
+// RenderShortcut 按快捷指令（输入框 /shortcut）解析快捷回复。
+// POST /agent/macros/render
+func (m *MacroController) RenderShortcut(c *gin.Context) {
+	var req renderMacroRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ConversationID == 0 || req.Shortcut == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if !m.authorizeConversation(c, req.ConversationID) {
+		return
+	}
+	rendered, err := m.macroService.RenderByShortcut(req.Shortcut, req.ConversationID, getUserIDFromHeader(c))
+	if err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
+
+// SendMacro 解析并发送快捷回复，随后执行其发送后动作（关闭会话、添加标签等）。
+// POST /agent/macros/:id/send
+func (m *MacroController) SendMacro(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "快捷回复 ID 不合法"})
+		return
+	}
+	var req sendMacroRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ConversationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if !m.authorizeConversation(c, req.ConversationID) {
+		return
+	}
+	result, err := m.macroService.Send(uint(id), req.ConversationID, getUserIDFromHeader(c), req.Content)
+	if err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListFolders 列出当前客服可见的快捷回复分组。
+// GET /agent/macros/folders
+func (m *MacroController) ListFolders(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermChat)) {
+		return
+	}
+	folders, err := m.macroService.ListFolders(getUserIDFromHeader(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询分组失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"folders": folders})
+}
+
+// CreateFolder 创建快捷回复分组。
+// POST /agent/macros/folders
+func (m *MacroController) CreateFolder(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermMacros)) {
+		return
+	}
+	var req macroFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	folder, err := m.macroService.CreateFolder(getUserIDFromHeader(c), service.MacroFolderInput{
+		Name:      req.Name,
+		Scope:     req.Scope,
+		SortOrder: req.SortOrder,
+	})
+	if err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, folder)
+}
+
+// UpdateFolder 更新快捷回复分组。
+// PUT /agent/macros/folders/:id
+func (m *MacroController) UpdateFolder(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermMacros)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分组 ID 不合法"})
+		return
+	}
+	var req macroFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	folder, err := m.macroService.UpdateFolder(uint(id), getUserIDFromHeader(c), service.MacroFolderInput{
+		Name:      req.Name,
+		Scope:     req.Scope,
+		SortOrder: req.SortOrder,
+	})
+	if err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder 删除快捷回复分组（其中的快捷回复移出分组）。
+// DELETE /agent/macros/folders/:id
+func (m *MacroController) DeleteFolder(c *gin.Context) {
+	if !requirePermission(c, m.users, string(service.PermMacros)) {
+		return
+	}
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分组 ID 不合法"})
+		return
+	}
+	if err := m.macroService.DeleteFolder(uint(id), getUserIDFromHeader(c)); err != nil {
+		writeMacroError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// MCPServerController 管理员注册的 MCP 服务（工具提供方）。
+type MCPServerController struct {
+	mcpService *service.MCPServerService
+	users      *service.UserService
+}
+
+// NewMCPServerController 创建 MCP 服务控制器。
+func NewMCPServerController(mcpService *service.MCPServerService, users *service.UserService) *MCPServerController {
+	return &MCPServerController{mcpService: mcpService, users: users}
+}
+
+type mcpServerRequest struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Transport    string            `json:"transport"` // http / stdio
+	URL          string            `json:"url"`
+	AuthHeader   string            `json:"auth_header"`
+	AuthValue    *string           `json:"auth_value"` // 修改时不传表示保留原值，"" 表示清除
+	Command      string            `json:"command"`
+	Args         []string          `json:"args"`
+	Env          map[string]string `json:"env"`           // 修改时不传表示保留原值
+	EnabledTools []string          `json:"enabled_tools"` // 提供给模型的工具名
+	IsActive     *bool             `json:"is_active"`
+}
+
+func (r mcpServerRequest) toInput() service.MCPServerInput {
+	return service.MCPServerInput{
+		Name:         r.Name,
+		Description:  r.Description,
+		Transport:    r.Transport,
+		URL:          r.URL,
+		AuthHeader:   r.AuthHeader,
+		AuthValue:    r.AuthValue,
+		Command:      r.Command,
+		Args:         r.Args,
+		Env:          r.Env,
+		EnabledTools: r.EnabledTools,
+		IsActive:     r.IsActive,
+	}
+}
+
+// ListServers 列出 MCP 服务及连接状态。
+// GET /agent/mcp-servers
+func (a *MCPServerController) ListServers(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	servers, err := a.mcpService.ListServers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询 MCP 服务失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"servers": servers})
+}
+
+// GetServer 获取单个 MCP 服务。
+// GET /agent/mcp-servers/:id
+func (a *MCPServerController) GetServer(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, ok := parseMCPServerID(c)
+	if !ok {
+		return
+	}
+	server, err := a.mcpService.GetServer(id)
+	if err != nil {
+		writeMCPServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, server)
+}
+
+// CreateServer 注册 MCP 服务（保存后立即连接）。
+// POST /agent/mcp-servers
+func (a *MCPServerController) CreateServer(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	var req mcpServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	server, err := a.mcpService.CreateServer(req.toInput())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, server)
+}
+
+// UpdateServer 修改 MCP 服务（保存后重建连接）。
+// PUT /agent/mcp-servers/:id
+func (a *MCPServerController) UpdateServer(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, ok := parseMCPServerID(c)
+	if !ok {
+		return
+	}
+	var req mcpServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	server, err := a.mcpService.UpdateServer(id, req.toInput())
+	if err != nil {
+		writeMCPServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, server)
+}
+
+// DeleteServer 删除 MCP 服务。
+// DELETE /agent/mcp-servers/:id
+func (a *MCPServerController) DeleteServer(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, ok := parseMCPServerID(c)
+	if !ok {
+		return
+	}
+	if err := a.mcpService.DeleteServer(id); err != nil {
+		writeMCPServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ListTools 列出服务当前提供的工具（实时拉取），供勾选提供给模型的工具。
+// GET /agent/mcp-servers/:id/tools
+func (a *MCPServerController) ListTools(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, ok := parseMCPServerID(c)
+	if !ok {
+		return
+	}
+	tools, err := a.mcpService.ListServerTools(id)
+	if err != nil {
+		writeMCPServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tools": tools})
+}
+
+// Reconnect 立即重连 MCP 服务。
+// POST /agent/mcp-servers/:id/reconnect
+func (a *MCPServerController) Reconnect(c *gin.Context) {
+	if !requirePermission(c, a.users, string(service.PermSettings)) {
+		return
+	}
+	id, ok := parseMCPServerID(c)
+	if !ok {
+		return
+	}
+	server, err := a.mcpService.Reconnect(id)
+	if err != nil {
+		writeMCPServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, server)
+}
+
+func parseMCPServerID(c *gin.Context) (uint, bool) {
+	id, err := parseUintParam(c, "id")
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MCP 服务 ID 不合法"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeMCPServerError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrMCPServerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
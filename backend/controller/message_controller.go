@@ -175,7 +175,10 @@ func (mc *MessageController) ListMessages(c *gin.Context) {
 	// 解析 include_ai_messages 参数（默认 false）
 	includeAIMessages := c.DefaultQuery("include_ai_messages", "false") == "true"
 
-	messages, err := mc.messageService.ListMessages(uint(conversationID), includeAIMessages)
+	// whisper 内部备注仅返回给已登录客服
+	includeWhispers := getUserIDFromHeader(c) > 0
+
+	messages, err := mc.messageService.ListMessages(uint(conversationID), includeAIMessages, includeWhispers)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询消息失败"})
 		return
@@ -184,6 +187,30 @@ func (mc *MessageController) ListMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, messages)
 }
 
+type cancelAIReplyRequest struct {
+	ConversationID uint `json:"conversation_id"`
+}
+
+// CancelAIReply 中断会话当前正在流式输出的 AI 回复（访客「停止生成」）。
+// 已输出的部分内容会落库（尚无内容时不落库），并通过 ai_done（cancelled=true）推送。
+func (mc *MessageController) CancelAIReply(c *gin.Context) {
+	var req cancelAIReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ConversationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if _, ok := authorizeConversationAccess(c, mc.conversationService, mc.userService, req.ConversationID); !ok {
+		return
+	}
+
+	cancelled := mc.messageService.CancelAIReply(req.ConversationID)
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": req.ConversationID,
+		"cancelled":       cancelled,
+	})
+}
+
 type markMessagesReadRequest struct {
 	ConversationID uint `json:"conversation_id"`
 	ReaderIsAgent  bool `json:"reader_is_agent"`
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// TranslationController 负责实时翻译配置相关的 HTTP 请求（需要系统设置权限）。
+type TranslationController struct {
+	translationService *service.TranslationService
+	users              *service.UserService
+}
+
+// NewTranslationController 创建 TranslationController 实例。
+func NewTranslationController(translationService *service.TranslationService, users *service.UserService) *TranslationController {
+	return &TranslationController{translationService: translationService, users: users}
+}
+
+// GetSettings 读取实时翻译配置。
+// GET /agent/translation/settings
+func (tc *TranslationController) GetSettings(c *gin.Context) {
+	if !requirePermission(c, tc.users, string(service.PermSettings)) {
+		return
+	}
+	c.JSON(http.StatusOK, tc.translationService.GetSettings())
+}
+
+// UpdateSettings 保存实时翻译配置。
+// PUT /agent/translation/settings
+func (tc *TranslationController) UpdateSettings(c *gin.Context) {
+	if !requirePermission(c, tc.users, string(service.PermSettings)) {
+		return
+	}
+	var req service.TranslationSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	settings, err := tc.translationService.UpdateSettings(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
@@ -5,6 +5,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
 	"strings"
 	"sync"
 
@@ -14,61 +18,193 @@ import (
 // ErrNotConfigured 表示未配置 MCP 服务 URL 或未成功连接。
 var ErrNotConfigured = errors.New("mcp: server URL not configured or not connected")
 
-// Client 封装对单个 MCP 服务端的连接，支持 CallTool。
+// Tool 服务端提供的工具描述
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// Client 封装对单个 MCP 服务端的连接，支持 ListTools / CallTool；连接断开后在下次调用时自动重连。
 type Client struct {
-	serverURL string
-	impl      *mcp.Implementation
-	transport *mcp.StreamableClientTransport
-	client    *mcp.Client
-	session   *mcp.ClientSession
-	mu        sync.Mutex
+	impl *mcp.Implementation
+	// newTransport 每次连接创建新的传输（stdio 的 exec.Cmd 只能启动一次）
+	newTransport func() mcp.Transport
+	client       *mcp.Client
+	session      *mcp.ClientSession
+	mu           sync.Mutex
 }
 
 // NewClient 创建一个 MCP 客户端。serverURL 为 MCP 服务 HTTP/SSE 地址（如 http://localhost:3000/sse）。
 // 若 serverURL 为空，后续 Connect/CallTool 将返回 ErrNotConfigured。
 func NewClient(serverURL string) *Client {
+	return NewHTTPClient(serverURL, nil)
+}
+
+// NewHTTPClient 创建 streamable HTTP 传输的客户端，headers 会附加到每个请求（如鉴权头）。
+func NewHTTPClient(serverURL string, headers map[string]string) *Client {
 	url := strings.TrimSpace(serverURL)
-	return &Client{
-		serverURL: url,
-		impl:      &mcp.Implementation{Name: "ai-cs-backend", Version: "v1.0.0"},
+	c := &Client{impl: &mcp.Implementation{Name: "ai-cs-backend", Version: "v1.0.0"}}
+	if url != "" {
+		c.newTransport = func() mcp.Transport {
+			t := &mcp.StreamableClientTransport{Endpoint: url}
+			if len(headers) > 0 {
+				t.HTTPClient = &http.Client{Transport: &headerRoundTripper{headers: headers, next: http.DefaultTransport}}
+			}
+			return t
+		}
+	}
+	return c
+}
+
+// NewCommandClient 创建 stdio 传输的客户端：启动本地命令，通过标准输入输出通信。env 为追加的环境变量（KEY=VALUE）。
+func NewCommandClient(command string, args []string, env []string) *Client {
+	command = strings.TrimSpace(command)
+	c := &Client{impl: &mcp.Implementation{Name: "ai-cs-backend", Version: "v1.0.0"}}
+	if command != "" {
+		c.newTransport = func() mcp.Transport {
+			cmd := exec.Command(command, args...)
+			cmd.Env = append(os.Environ(), env...)
+			return &mcp.CommandTransport{Command: cmd}
+		}
+	}
+	return c
+}
+
+type headerRoundTripper struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
 	}
+	return t.next.RoundTrip(req)
 }
 
-// Connect 连接到 MCP 服务端。应在调用 CallTool 前成功调用一次。
+// Connect 连接到 MCP 服务端。已连接时直接返回；未显式调用时 ListTools / CallTool 会自动连接。
 func (c *Client) Connect(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.serverURL == "" {
-		return ErrNotConfigured
+	_, err := c.connectLocked(ctx)
+	return err
+}
+
+func (c *Client) connectLocked(ctx context.Context) (*mcp.ClientSession, error) {
+	if c.newTransport == nil {
+		return nil, ErrNotConfigured
 	}
 	if c.session != nil {
-		return nil // 已连接
+		return c.session, nil // 已连接
+	}
+	if c.client == nil {
+		c.client = mcp.NewClient(c.impl, nil)
 	}
-	c.transport = &mcp.StreamableClientTransport{Endpoint: c.serverURL}
-	c.client = mcp.NewClient(c.impl, nil)
-	session, err := c.client.Connect(ctx, c.transport, nil)
+	session, err := c.client.Connect(ctx, c.newTransport(), nil)
 	if err != nil {
-		return fmt.Errorf("mcp connect: %w", err)
+		return nil, fmt.Errorf("mcp connect: %w", err)
 	}
 	c.session = session
-	return nil
+	return session, nil
 }
 
-// CallTool 调用远程工具。name 为工具名（如 google_search），args 为参数（如 map[string]any{"query": "..."}）。
-// 返回工具结果中的文本内容拼接；若未连接或工具返回错误则返回错误。
-func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+// Reconnect 关闭当前会话并重新连接
+func (c *Client) Reconnect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil {
+		_ = c.session.Close()
+		c.session = nil
+	}
+	_, err := c.connectLocked(ctx)
+	return err
+}
+
+// Connected 当前是否持有会话
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session != nil
+}
+
+// withSession 以当前会话执行 fn；会话不存在时先连接，连接已断开时重连并重试一次
+func (c *Client) withSession(ctx context.Context, fn func(session *mcp.ClientSession) error) error {
+	c.mu.Lock()
+	session, err := c.connectLocked(ctx)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	err = fn(session)
+	if err == nil || !isConnectionError(err) {
+		return err
+	}
 	c.mu.Lock()
-	session := c.session
+	if c.session == session {
+		_ = session.Close()
+		c.session = nil
+	}
+	session, connErr := c.connectLocked(ctx)
 	c.mu.Unlock()
-	if c.serverURL == "" {
-		return "", ErrNotConfigured
+	if connErr != nil {
+		return fmt.Errorf("%v（重连失败: %v）", err, connErr)
 	}
-	if session == nil {
-		return "", ErrNotConfigured
+	return fn(session)
+}
+
+// isConnectionError 连接已断开（进程退出、服务端重启导致会话失效等），重连后可安全重试
+func isConnectionError(err error) bool {
+	if errors.Is(err, mcp.ErrConnectionClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
 	}
-	params := &mcp.CallToolParams{Name: name, Arguments: args}
-	result, err := session.CallTool(ctx, params)
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"connection closed", "session not found", "rejected by transport", "broken pipe", "connection refused", "connection reset", ": eof"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ping 检查连接是否可用（必要时自动重连）
+func (c *Client) Ping(ctx context.Context) error {
+	return c.withSession(ctx, func(session *mcp.ClientSession) error {
+		return session.Ping(ctx, nil)
+	})
+}
+
+// ListTools 列出服务端提供的全部工具（自动翻页）
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	err := c.withSession(ctx, func(session *mcp.ClientSession) error {
+		tools = nil
+		for tool, err := range session.Tools(ctx, nil) {
+			if err != nil {
+				return fmt.Errorf("mcp list tools: %w", err)
+			}
+			schema, _ := tool.InputSchema.(map[string]any)
+			tools = append(tools, Tool{Name: tool.Name, Description: tool.Description, InputSchema: schema})
+		}
+		return nil
+	})
+	return tools, err
+}
+
+// CallTool 调用远程工具。name 为工具名（如 google_search），args 为参数（如 map[string]any{"query": "..."}）。
+// 返回工具结果中的文本内容拼接；若未连接或工具返回错误则返回错误。
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	var result *mcp.CallToolResult
+	err := c.withSession(ctx, func(session *mcp.ClientSession) error {
+		var err error
+		result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		return err
+	})
 	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			return "", err
+		}
 		return "", fmt.Errorf("mcp call tool %s: %w", name, err)
 	}
 	if result.IsError {
@@ -8,3 +8,15 @@ import "context"
 type WebSearchProvider interface {
 	Search(ctx context.Context, query string) (string, error)
 }
+
+// WebResult 一条联网搜索结果
+type WebResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// WebResultsProvider 可返回结构化搜索结果的实现（用于回复中的来源引用）；未实现时调用方从文本中解析链接。
+type WebResultsProvider interface {
+	SearchResults(ctx context.Context, query string) ([]WebResult, error)
+}
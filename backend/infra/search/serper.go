@@ -31,31 +31,52 @@ func NewSerperProvider(apiKey string) *SerperProvider {
 
 // Search 执行搜索并返回格式化后的文本摘要，供 LLM 使用。未配置 apiKey 或请求失败时返回空字符串。
 func (p *SerperProvider) Search(ctx context.Context, query string) (string, error) {
+	result, err := p.search(ctx, query)
+	if err != nil || result == nil {
+		return "", err
+	}
+	return result.FormatOrganic(), nil
+}
+
+// SearchResults 执行搜索并返回结构化结果（标题、链接、摘要）。未配置 apiKey 时返回空。
+func (p *SerperProvider) SearchResults(ctx context.Context, query string) ([]WebResult, error) {
+	result, err := p.search(ctx, query)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	out := make([]WebResult, 0, len(result.Organic))
+	for _, o := range result.Organic {
+		out = append(out, WebResult{Title: o.Title, URL: o.Link, Snippet: o.Snippet})
+	}
+	return out, nil
+}
+
+func (p *SerperProvider) search(ctx context.Context, query string) (*serperResponse, error) {
 	if p.apiKey == "" {
-		return "", nil
+		return nil, nil
 	}
 	reqBody := map[string]interface{}{"q": query}
 	bodyBytes, _ := json.Marshal(reqBody)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, serperBaseURL, strings.NewReader(string(bodyBytes)))
 	if err != nil {
-		return "", fmt.Errorf("serper request: %w", err)
+		return nil, fmt.Errorf("serper request: %w", err)
 	}
 	req.Header.Set("X-API-KEY", p.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("serper http: %w", err)
+		return nil, fmt.Errorf("serper http: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		bs, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("serper api %d: %s", resp.StatusCode, string(bs))
+		return nil, fmt.Errorf("serper api %d: %s", resp.StatusCode, string(bs))
 	}
 	var result serperResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("serper decode: %w", err)
+		return nil, fmt.Errorf("serper decode: %w", err)
 	}
-	return result.FormatOrganic(), nil
+	return &result, nil
 }
 
 type serperResponse struct {
@@ -0,0 +1,73 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 15 * time.Second
+
+// LibreTranslateProvider 使用 LibreTranslate 兼容接口（POST {baseURL}/translate）的翻译实现。
+type LibreTranslateProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewLibreTranslateProvider 创建 LibreTranslate 翻译提供方。baseURL 如 https://libretranslate.com，apiKey 可为空（自建服务）。
+func NewLibreTranslateProvider(baseURL, apiKey string) *LibreTranslateProvider {
+	return &LibreTranslateProvider{
+		baseURL: strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		apiKey:  strings.TrimSpace(apiKey),
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// Translate 调用 /translate 接口翻译文本（LibreTranslate 只识别主语言代码，如 zh、en）。
+func (p *LibreTranslateProvider) Translate(ctx context.Context, text, source, target string) (string, error) {
+	if p.baseURL == "" {
+		return "", errors.New("libretranslate: base URL not configured")
+	}
+	src := BaseLanguage(source)
+	if src == "" {
+		src = "auto"
+	}
+	reqBody := map[string]string{
+		"q":      text,
+		"source": src,
+		"target": BaseLanguage(target),
+		"format": "text",
+	}
+	if p.apiKey != "" {
+		reqBody["api_key"] = p.apiKey
+	}
+	bodyBytes, _ := json.Marshal(reqBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/translate", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return "", fmt.Errorf("libretranslate request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("libretranslate http: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bs, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("libretranslate api %d: %s", resp.StatusCode, string(bs))
+	}
+	var result struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("libretranslate decode: %w", err)
+	}
+	return result.TranslatedText, nil
+}
@@ -0,0 +1,32 @@
+// Package translate 提供机器翻译能力的抽象与外部翻译服务实现。
+// 基于 AI 文本模型的翻译在 service 层实现（需要 AI 配置与用量记录）；接入其他翻译服务时在此包实现 Translator 即可。
+package translate
+
+import (
+	"context"
+	"strings"
+)
+
+// Translator 机器翻译能力抽象。
+type Translator interface {
+	// Translate 将 text 从 source 译为 target。语言为 BCP 47 代码（如 zh-CN、en），source 为空表示自动检测。
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// BaseLanguage 返回语言代码的主语言部分（小写），如 zh-CN → zh、en_US → en、"en-US,en;q=0.9" → en。
+func BaseLanguage(code string) string {
+	code = strings.TrimSpace(code)
+	if i := strings.IndexAny(code, ",;"); i >= 0 {
+		code = code[:i]
+	}
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// SameLanguage 两个语言代码的主语言是否相同（任一为空时返回 false）。
+func SameLanguage(a, b string) bool {
+	la, lb := BaseLanguage(a), BaseLanguage(b)
+	return la != "" && la == lb
+}
@@ -2,10 +2,13 @@ package infra
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
@@ -21,21 +24,27 @@ type EmbeddingService interface {
 // GetEmbeddingServiceFunc 按需获取嵌入服务（用于迁移时从当前配置重新向量化，实现保存即生效）
 type GetEmbeddingServiceFunc func(ctx context.Context) (EmbeddingService, error)
 
+// LegacySourceTypeFunc 迁移缺少 source_type 字段的旧集合时，判定一条整篇向量的来源类型（FAQ 返回 SourceTypeFAQ，文档返回空）
+type LegacySourceTypeFunc func(ctx context.Context, documentID string, content string) string
+
 // VectorStore 向量存储服务
 type VectorStore struct {
 	client             client.Client
 	collection         string
 	dimension          int
 	getEmbeddingService GetEmbeddingServiceFunc
+	legacySourceType   LegacySourceTypeFunc
+	rebuilt            bool // 启动时集合被清空重建，需重新向量化
 }
 
-// NewVectorStore 创建向量存储服务实例；getEmbedding 仅在维度迁移时调用
-func NewVectorStore(milvusClient client.Client, collectionName string, dimension int, getEmbedding GetEmbeddingServiceFunc) (*VectorStore, error) {
+// NewVectorStore 创建向量存储服务实例；getEmbedding 仅在维度迁移时调用，legacySourceType 仅在为旧集合补齐 source_type 时调用
+func NewVectorStore(milvusClient client.Client, collectionName string, dimension int, getEmbedding GetEmbeddingServiceFunc, legacySourceType LegacySourceTypeFunc) (*VectorStore, error) {
 	vs := &VectorStore{
 		client:             milvusClient,
 		collection:         collectionName,
 		dimension:          dimension,
 		getEmbeddingService: getEmbedding,
+		legacySourceType:   legacySourceType,
 	}
 	// 确保集合存在
 	if err := vs.ensureCollection(context.Background()); err != nil {
@@ -53,8 +62,11 @@ func (vs *VectorStore) ensureCollectionLoaded(ctx context.Context) error {
 	return nil
 }
 
-// ensureChunkDBIDField 检查集合是否有 chunk_db_id 字段
-func (vs *VectorStore) ensureChunkDBIDField(ctx context.Context) error {
+// requiredScalarFields 集合必须包含的标量字段（分段向量化新增 chunk_db_id，来源元数据新增页码 / 标题路径 / 锚点，FAQ 检索新增来源类型）
+var requiredScalarFields = []string{"chunk_db_id", "page_number", "heading_path", "source_anchor", "source_type"}
+
+// ensureScalarFields 检查集合是否包含全部标量字段
+func (vs *VectorStore) ensureScalarFields(ctx context.Context) error {
 	if err := vs.ensureCollectionLoaded(ctx); err != nil {
 		return err
 	}
@@ -62,12 +74,16 @@ func (vs *VectorStore) ensureChunkDBIDField(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("获取集合信息失败: %w", err)
 	}
+	existing := make(map[string]bool, len(collections.Schema.Fields))
 	for _, field := range collections.Schema.Fields {
-		if field.Name == "chunk_db_id" {
-			return nil
+		existing[field.Name] = true
+	}
+	for _, name := range requiredScalarFields {
+		if !existing[name] {
+			return fmt.Errorf("缺少 %s 字段", name)
 		}
 	}
-	return fmt.Errorf("缺少 chunk_db_id 字段")
+	return nil
 }
 
 // getCollectionDimension 获取集合的维度
@@ -100,146 +116,222 @@ func (vs *VectorStore) getCollectionDimension(ctx context.Context) (int, error)
 	return 0, fmt.Errorf("未找到 embedding 字段")
 }
 
-// migrateCollection 自动迁移集合数据
+// migrateBatchSize 迁移集合时每批读取 / 写入的行数
+const migrateBatchSize = 1000
+
+// migratingSuffix 迁移过程中临时集合的名称后缀
+const migratingSuffix = "_migrating"
+
+// migrateCollection 将旧集合的数据迁移到当前 schema 的新集合：逐批读出全部行写入临时集合，再删除旧集合并将临时集合改回原名。
+// 维度未变时直接复用原向量，维度变化时用当前配置的嵌入服务重新向量化；chunk_db_id 与来源元数据原样保留，旧集合缺少的字段取零值
+// （缺少 source_type 时由 legacySourceType 判定整篇向量是否为 FAQ）。
+// 失败时旧集合保持不变。
 func (vs *VectorStore) migrateCollection(ctx context.Context, oldDimension int) error {
-	log.Printf("🔄 开始迁移集合 '%s'：从 %d 维迁移到 %d 维", vs.collection, oldDimension, vs.dimension)
+	reembed := oldDimension != vs.dimension
+	if reembed {
+		log.Printf("🔄 开始迁移集合 '%s'：从 %d 维迁移到 %d 维（重新向量化）", vs.collection, oldDimension, vs.dimension)
+	} else {
+		log.Printf("🔄 开始迁移集合 '%s'：补齐标量字段（保留原向量）", vs.collection)
+	}
 
 	// 确保旧集合已加载
 	if err := vs.ensureCollectionLoaded(ctx); err != nil {
 		return fmt.Errorf("加载旧集合失败: %w", err)
 	}
+	collection, err := vs.client.DescribeCollection(ctx, vs.collection)
+	if err != nil {
+		return fmt.Errorf("获取集合信息失败: %w", err)
+	}
+	existing := make(map[string]bool, len(collection.Schema.Fields))
+	for _, field := range collection.Schema.Fields {
+		existing[field.Name] = true
+	}
+	outputFields := []string{"document_id", "knowledge_base_id", "content"}
+	if !reembed {
+		outputFields = append(outputFields, "embedding")
+	}
+	for _, name := range requiredScalarFields {
+		if existing[name] {
+			outputFields = append(outputFields, name)
+		}
+	}
 
-	// 批量查询旧数据
-	const queryBatchSize = 10000
-	var allDocumentIDs []string
-	var allKnowledgeBaseIDs []string
-	var allContents []string
-
-	// 使用 ID 范围批量查询
-	minID := int64(0)
-	maxID := int64(1000000) // 假设最大 ID
-
-	for {
-		// 构建查询表达式
-		expr := fmt.Sprintf("id >= %d && id < %d", minID, minID+queryBatchSize)
-		
-		// 查询数据
-		queryResult, err := vs.client.Query(
-			ctx,
-			vs.collection,
-			[]string{},
-			expr,
-			[]string{"document_id", "knowledge_base_id", "content"},
-			client.WithLimit(queryBatchSize),
-		)
+	// 使用当前配置的嵌入服务重新向量化（保存即生效）
+	var embeddingSvc EmbeddingService
+	if reembed {
+		if vs.getEmbeddingService != nil {
+			embeddingSvc, err = vs.getEmbeddingService(ctx)
+		}
 		if err != nil {
-			return fmt.Errorf("查询旧集合数据失败: %w", err)
+			return fmt.Errorf("获取嵌入服务失败: %w", err)
 		}
-
-		// 提取数据
-		documentIDCol := queryResult.GetColumn("document_id")
-		knowledgeBaseIDCol := queryResult.GetColumn("knowledge_base_id")
-		contentCol := queryResult.GetColumn("content")
-
-		if documentIDCol == nil || knowledgeBaseIDCol == nil || contentCol == nil {
-			break // 没有更多数据
+		if embeddingSvc == nil {
+			return fmt.Errorf("未配置嵌入服务，无法重新向量化")
 		}
+	}
 
-		documentIDs, ok := documentIDCol.(*entity.ColumnVarChar)
-		if !ok {
-			break
+	// 创建临时集合（清理上次中断遗留的同名集合；createCollectionWithName 会自动创建索引）
+	tempCollection := vs.collection + migratingSuffix
+	if exists, err := vs.client.HasCollection(ctx, tempCollection); err == nil && exists {
+		if err := vs.client.DropCollection(ctx, tempCollection); err != nil {
+			return fmt.Errorf("删除遗留的临时集合失败: %w", err)
 		}
-		knowledgeBaseIDs, ok := knowledgeBaseIDCol.(*entity.ColumnVarChar)
-		if !ok {
-			break
+	}
+	if err := vs.createCollectionWithName(ctx, tempCollection); err != nil {
+		return fmt.Errorf("创建临时集合失败: %w", err)
+	}
+	dropTemp := func() {
+		if err := vs.client.DropCollection(context.Background(), tempCollection); err != nil {
+			log.Printf("⚠️ 删除临时集合 '%s' 失败: %v", tempCollection, err)
 		}
-		contents, ok := contentCol.(*entity.ColumnVarChar)
-		if !ok {
+	}
+	if err := vs.client.LoadCollection(ctx, tempCollection, false); err != nil {
+		dropTemp()
+		return fmt.Errorf("加载临时集合失败: %w", err)
+	}
+
+	// 按主键分批读取旧集合（AutoID 主键不连续，使用 QueryIterator 遍历）
+	itr, err := vs.client.QueryIterator(ctx, client.NewQueryIteratorOption(vs.collection).
+		WithOutputFields(outputFields...).
+		WithBatchSize(migrateBatchSize))
+	if err != nil {
+		dropTemp()
+		return fmt.Errorf("查询旧集合数据失败: %w", err)
+	}
+	total := 0
+	for {
+		rs, err := itr.Next(ctx)
+		if errors.Is(err, io.EOF) {
 			break
 		}
-
-		allDocumentIDs = append(allDocumentIDs, documentIDs.Data()...)
-		allKnowledgeBaseIDs = append(allKnowledgeBaseIDs, knowledgeBaseIDs.Data()...)
-		allContents = append(allContents, contents.Data()...)
-
-		if len(documentIDs.Data()) < queryBatchSize {
-			break // 已查询完所有数据
+		if err != nil {
+			dropTemp()
+			return fmt.Errorf("查询旧集合数据失败: %w", err)
 		}
-
-		minID += queryBatchSize
-		if minID >= maxID {
-			break
+		n, err := vs.copyMigrationBatch(ctx, tempCollection, rs, embeddingSvc)
+		if err != nil {
+			dropTemp()
+			return err
 		}
+		total += n
 	}
+	if err := vs.client.Flush(ctx, tempCollection, false); err != nil {
+		dropTemp()
+		return fmt.Errorf("写入临时集合失败: %w", err)
+	}
+	log.Printf("📊 已复制 %d 条数据到临时集合 '%s'，替换旧集合...", total, tempCollection)
 
-	if len(allContents) == 0 {
-		log.Println("⚠️ 旧集合中没有数据，直接创建新集合")
-		// 删除旧集合
-		if err := vs.client.DropCollection(ctx, vs.collection); err != nil {
-			log.Printf("⚠️ 删除旧集合失败: %v", err)
-		}
-		return nil
+	// 删除旧集合并将临时集合改回原名（改名前中断时，下次启动由 ensureCollection 接续）
+	if err := vs.client.DropCollection(ctx, vs.collection); err != nil {
+		dropTemp()
+		return fmt.Errorf("删除旧集合失败: %w", err)
+	}
+	if err := vs.client.RenameCollection(ctx, tempCollection, vs.collection); err != nil {
+		return fmt.Errorf("重命名临时集合失败: %w", err)
 	}
 
-	log.Printf("📊 找到 %d 条数据需要迁移", len(allContents))
+	log.Println("✅ 自动迁移完成")
+	return nil
+}
 
-	// 使用当前配置的嵌入服务重新向量化（保存即生效）
-	log.Println("🔄 开始重新向量化数据...")
-	embeddingSvc, err := vs.getEmbeddingService(ctx)
+// copyMigrationBatch 将旧集合的一批查询结果写入目标集合，返回写入的行数；embeddingSvc 非 nil 时重新向量化
+func (vs *VectorStore) copyMigrationBatch(ctx context.Context, target string, rs client.ResultSet, embeddingSvc EmbeddingService) (int, error) {
+	n := rs.Len()
+	if n == 0 {
+		return 0, nil
+	}
+	documentIDs, err := varCharColumnData(rs, "document_id", n)
 	if err != nil {
-		return fmt.Errorf("获取嵌入服务失败: %w", err)
+		return 0, err
 	}
-	newVectors, err := embeddingSvc.EmbedTexts(ctx, allContents)
+	knowledgeBaseIDs, err := varCharColumnData(rs, "knowledge_base_id", n)
 	if err != nil {
-		return fmt.Errorf("重新向量化失败: %w", err)
+		return 0, err
 	}
-
-	// 创建新集合（临时名称，createCollectionWithName 会自动创建索引）
-	newCollectionName := vs.collection + "_new"
-	if err := vs.createCollectionWithName(ctx, newCollectionName); err != nil {
-		return fmt.Errorf("创建新集合失败: %w", err)
+	contents, err := varCharColumnData(rs, "content", n)
+	if err != nil {
+		return 0, err
 	}
-
-	// 加载新集合
-	if err := vs.client.LoadCollection(ctx, newCollectionName, false); err != nil {
-		return fmt.Errorf("加载新集合失败: %w", err)
+	chunkDBIDs, err := varCharColumnData(rs, "chunk_db_id", n)
+	if err != nil {
+		return 0, err
 	}
-
-	// 插入新数据（Insert 接受 variadic ...entity.Column；NewColumnFloatVector 接受 [][]float32）
-	_, err = vs.client.Insert(ctx, newCollectionName, "",
-		entity.NewColumnVarChar("document_id", allDocumentIDs),
-		entity.NewColumnVarChar("knowledge_base_id", allKnowledgeBaseIDs),
-		entity.NewColumnVarChar("content", allContents),
-		entity.NewColumnFloatVector("embedding", vs.dimension, newVectors),
-	)
+	headings, err := varCharColumnData(rs, "heading_path", n)
 	if err != nil {
-		return fmt.Errorf("插入新数据失败: %w", err)
+		return 0, err
 	}
-
-	log.Println("✅ 数据迁移完成，删除旧集合...")
-
-	// 删除旧集合
-	if err := vs.client.DropCollection(ctx, vs.collection); err != nil {
-		log.Printf("⚠️ 删除旧集合失败: %v", err)
+	anchors, err := varCharColumnData(rs, "source_anchor", n)
+	if err != nil {
+		return 0, err
+	}
+	sourceTypes, err := varCharColumnData(rs, "source_type", n)
+	if err != nil {
+		return 0, err
+	}
+	if rs.GetColumn("source_type") == nil && vs.legacySourceType != nil {
+		// 旧集合没有来源类型：FAQ 与未分段文档同为整篇向量，逐条判定
+		for i := range sourceTypes {
+			if chunkDBIDs[i] == "" {
+				sourceTypes[i] = vs.legacySourceType(ctx, documentIDs[i], contents[i])
+			}
+		}
+	}
+	metas := make([]VectorMetadata, n)
+	for i := range metas {
+		metas[i] = VectorMetadata{HeadingPath: headings[i], SourceAnchor: anchors[i], SourceType: sourceTypes[i]}
+	}
+	if col := rs.GetColumn("page_number"); col != nil {
+		pages, ok := col.(*entity.ColumnInt64)
+		if !ok {
+			return 0, fmt.Errorf("旧集合 page_number 字段类型不符")
+		}
+		for i, p := range pages.Data() {
+			metas[i].PageNumber = p
+		}
 	}
 
-	// 重命名新集合
-	// 注意：Milvus 不支持直接重命名，需要先删除旧集合，再创建同名新集合
-	// 这里我们已经删除了旧集合，所以直接使用新集合名称
-	// 但为了保持集合名称一致，我们需要重新创建原名称的集合
-	// 由于 Milvus 的限制，我们只能先删除新集合，再创建原名称的集合
-	// 但这样会丢失数据，所以我们需要先插入数据到原名称的集合
-
-	// 实际上，更好的做法是：先创建临时集合，插入数据，然后删除旧集合，再创建原名称的集合并插入数据
-	// 但这样比较复杂，我们采用另一种方式：直接使用新集合，然后在 ensureCollection 中处理
+	var vectors [][]float32
+	if embeddingSvc != nil {
+		vectors, err = embeddingSvc.EmbedTexts(ctx, contents)
+		if err != nil {
+			return 0, fmt.Errorf("重新向量化失败: %w", err)
+		}
+	} else {
+		col, ok := rs.GetColumn("embedding").(*entity.ColumnFloatVector)
+		if !ok {
+			return 0, fmt.Errorf("读取旧集合向量失败")
+		}
+		vectors = col.Data()
+	}
+	if len(vectors) != n {
+		return 0, fmt.Errorf("向量数量不匹配：期望 %d，实际 %d", n, len(vectors))
+	}
 
-	// 临时方案：将新集合的数据复制到原名称的集合
-	// 由于 Milvus 的限制，我们需要重新插入数据
-	// 但为了简化，我们暂时使用新集合名称
-	// 后续在 ensureCollection 中会处理
+	// 插入数据（Insert 接受 variadic ...entity.Column；NewColumnFloatVector 接受 [][]float32）
+	if _, err := vs.client.Insert(ctx, target, "",
+		append(metadataColumns(chunkDBIDs, metas),
+			entity.NewColumnVarChar("document_id", documentIDs),
+			entity.NewColumnVarChar("knowledge_base_id", knowledgeBaseIDs),
+			entity.NewColumnVarChar("content", contents),
+			entity.NewColumnFloatVector("embedding", vs.dimension, vectors),
+		)...,
+	); err != nil {
+		return 0, fmt.Errorf("写入临时集合失败: %w", err)
+	}
+	return n, nil
+}
 
-	log.Println("✅ 自动迁移完成")
-	return nil
+// varCharColumnData 读取查询结果中的字符串列；结果中没有该列（旧集合缺少此字段）时返回 n 个空字符串
+func varCharColumnData(rs client.ResultSet, name string, n int) ([]string, error) {
+	col := rs.GetColumn(name)
+	if col == nil {
+		return make([]string, n), nil
+	}
+	data, ok := col.(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("旧集合 %s 字段类型不符", name)
+	}
+	return data.Data(), nil
 }
 
 // createCollectionWithName 创建指定名称的集合
@@ -290,6 +382,31 @@ func (vs *VectorStore) createCollectionWithName(ctx context.Context, collectionN
 					"max_length": "64",
 				},
 			},
+			{
+				Name:     "page_number",
+				DataType: entity.FieldTypeInt64,
+			},
+			{
+				Name:     "heading_path",
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					"max_length": fmt.Sprintf("%d", maxHeadingPathBytes),
+				},
+			},
+			{
+				Name:     "source_anchor",
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					"max_length": fmt.Sprintf("%d", maxSourceAnchorBytes),
+				},
+			},
+			{
+				Name:     "source_type",
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					"max_length": "16",
+				},
+			},
 		},
 	}
 
@@ -317,7 +434,7 @@ func (vs *VectorStore) createCollectionWithName(ctx context.Context, collectionN
 	return nil
 }
 
-// ensureCollection 确保集合存在，不存在则创建
+// ensureCollection 确保集合存在，不存在则创建；维度或字段与当前配置不符时自动迁移
 func (vs *VectorStore) ensureCollection(ctx context.Context) error {
 	// 检查集合是否存在
 	exists, err := vs.client.HasCollection(ctx, vs.collection)
@@ -326,6 +443,15 @@ func (vs *VectorStore) ensureCollection(ctx context.Context) error {
 	}
 
 	if !exists {
+		// 上次迁移在删除旧集合后、改名前中断：临时集合即为完整数据，改回原名
+		tempCollection := vs.collection + migratingSuffix
+		if tempExists, err := vs.client.HasCollection(ctx, tempCollection); err == nil && tempExists {
+			log.Printf("🔄 检测到未完成的迁移，将临时集合 '%s' 重命名为 '%s'", tempCollection, vs.collection)
+			if err := vs.client.RenameCollection(ctx, tempCollection, vs.collection); err != nil {
+				return fmt.Errorf("重命名临时集合失败: %w", err)
+			}
+			return vs.ensureCollection(ctx)
+		}
 		// 集合不存在，直接创建
 		return vs.createCollectionWithName(ctx, vs.collection)
 	}
@@ -334,40 +460,45 @@ func (vs *VectorStore) ensureCollection(ctx context.Context) error {
 	oldDimension, err := vs.getCollectionDimension(ctx)
 	if err != nil {
 		log.Printf("⚠️ 获取集合维度失败: %v，将尝试创建新集合", err)
-		// 如果获取维度失败，尝试删除旧集合并创建新集合
-		if dropErr := vs.client.DropCollection(ctx, vs.collection); dropErr != nil {
-			return fmt.Errorf("删除旧集合失败: %w", dropErr)
-		}
-		return vs.createCollectionWithName(ctx, vs.collection)
+		return vs.rebuildCollection(ctx)
 	}
 
 	if oldDimension != vs.dimension {
 		log.Printf("⚠️ 检测到维度不匹配：集合维度=%d，当前模型维度=%d", oldDimension, vs.dimension)
-		log.Println("🔄 开始自动迁移数据...")
-		// 维度不匹配，执行自动迁移
-		if err := vs.migrateCollection(ctx, oldDimension); err != nil {
-			return fmt.Errorf("自动迁移失败: %w", err)
+	} else if err := vs.ensureScalarFields(ctx); err != nil {
+		// Milvus 不支持为已有集合加字段：缺少 chunk_db_id / 来源元数据时迁移到新 schema（保留原向量）
+		log.Printf("⚠️ 集合字段不完整: %v", err)
+	} else {
+		// 维度与字段均匹配，检查索引是否存在
+		if err := vs.ensureIndex(ctx); err != nil {
+			return fmt.Errorf("确保索引存在失败: %w", err)
 		}
-		// 迁移后需要重新创建集合（因为迁移过程中删除了旧集合）
-		return vs.createCollectionWithName(ctx, vs.collection)
+		// 确保集合已加载
+		return vs.ensureCollectionLoaded(ctx)
 	}
 
-	// 检查是否有 chunk_db_id 字段（分段向量化新增）
-	if err := vs.ensureChunkDBIDField(ctx); err != nil {
-		log.Printf("⚠️ 集合缺少 chunk_db_id 字段，重建集合: %v", err)
-		if dropErr := vs.client.DropCollection(ctx, vs.collection); dropErr != nil {
-			return fmt.Errorf("删除旧集合失败: %w", dropErr)
-		}
-		return vs.createCollectionWithName(ctx, vs.collection)
+	if err := vs.migrateCollection(ctx, oldDimension); err != nil {
+		log.Printf("⚠️ 自动迁移失败: %v，将清空重建集合并重新向量化全部数据", err)
+		return vs.rebuildCollection(ctx)
 	}
+	return vs.ensureCollectionLoaded(ctx)
+}
 
-	// 维度匹配，检查索引是否存在
-	if err := vs.ensureIndex(ctx); err != nil {
-		return fmt.Errorf("确保索引存在失败: %w", err)
+// rebuildCollection 删除旧集合并按当前 schema 重新创建（旧向量丢失），标记需重新向量化
+func (vs *VectorStore) rebuildCollection(ctx context.Context) error {
+	if err := vs.client.DropCollection(ctx, vs.collection); err != nil {
+		return fmt.Errorf("删除旧集合失败: %w", err)
+	}
+	if err := vs.createCollectionWithName(ctx, vs.collection); err != nil {
+		return err
 	}
+	vs.rebuilt = true
+	return nil
+}
 
-	// 确保集合已加载
-	return vs.ensureCollectionLoaded(ctx)
+// NeedsReembed 集合在启动时被清空重建（旧数据无法迁移）时返回 true，调用方需重新向量化全部文档、分段与 FAQ
+func (vs *VectorStore) NeedsReembed() bool {
+	return vs.rebuilt
 }
 
 // ensureIndex 确保索引存在，不存在则创建
@@ -404,18 +535,19 @@ func (vs *VectorStore) ensureIndex(ctx context.Context) error {
 }
 
 // UpsertVector 插入或更新单个向量
-func (vs *VectorStore) UpsertVector(ctx context.Context, documentID string, knowledgeBaseID string, content string, chunkDBID string, vector []float32) error {
+func (vs *VectorStore) UpsertVector(ctx context.Context, documentID string, knowledgeBaseID string, content string, chunkDBID string, vector []float32, meta VectorMetadata) error {
 	// 确保集合已加载
 	if err := vs.ensureCollectionLoaded(ctx); err != nil {
 		return err
 	}
 
 	_, err := vs.client.Insert(ctx, vs.collection, "",
-		entity.NewColumnVarChar("document_id", []string{documentID}),
-		entity.NewColumnVarChar("knowledge_base_id", []string{knowledgeBaseID}),
-		entity.NewColumnVarChar("content", []string{content}),
-		entity.NewColumnVarChar("chunk_db_id", []string{chunkDBID}),
-		entity.NewColumnFloatVector("embedding", vs.dimension, [][]float32{vector}),
+		append(metadataColumns([]string{chunkDBID}, []VectorMetadata{meta}),
+			entity.NewColumnVarChar("document_id", []string{documentID}),
+			entity.NewColumnVarChar("knowledge_base_id", []string{knowledgeBaseID}),
+			entity.NewColumnVarChar("content", []string{content}),
+			entity.NewColumnFloatVector("embedding", vs.dimension, [][]float32{vector}),
+		)...,
 	)
 	if err != nil {
 		return fmt.Errorf("插入向量失败: %w", err)
@@ -423,8 +555,8 @@ func (vs *VectorStore) UpsertVector(ctx context.Context, documentID string, know
 	return nil
 }
 
-// UpsertVectors 批量插入或更新向量
-func (vs *VectorStore) UpsertVectors(ctx context.Context, documentIDs []string, knowledgeBaseIDs []string, contents []string, vectors [][]float32, chunkDBIDs []string) error {
+// UpsertVectors 批量插入或更新向量；metas 为空表示不带来源元数据，否则须与向量一一对应
+func (vs *VectorStore) UpsertVectors(ctx context.Context, documentIDs []string, knowledgeBaseIDs []string, contents []string, vectors [][]float32, chunkDBIDs []string, metas []VectorMetadata) error {
 	// 确保集合已加载
 	if err := vs.ensureCollectionLoaded(ctx); err != nil {
 		return err
@@ -433,13 +565,19 @@ func (vs *VectorStore) UpsertVectors(ctx context.Context, documentIDs []string,
 	if len(documentIDs) != len(knowledgeBaseIDs) || len(documentIDs) != len(contents) || len(documentIDs) != len(vectors) || len(documentIDs) != len(chunkDBIDs) {
 		return fmt.Errorf("参数长度不匹配")
 	}
+	if len(metas) == 0 {
+		metas = make([]VectorMetadata, len(documentIDs))
+	} else if len(metas) != len(documentIDs) {
+		return fmt.Errorf("参数长度不匹配")
+	}
 
 	_, err := vs.client.Insert(ctx, vs.collection, "",
-		entity.NewColumnVarChar("document_id", documentIDs),
-		entity.NewColumnVarChar("knowledge_base_id", knowledgeBaseIDs),
-		entity.NewColumnVarChar("content", contents),
-		entity.NewColumnVarChar("chunk_db_id", chunkDBIDs),
-		entity.NewColumnFloatVector("embedding", vs.dimension, vectors),
+		append(metadataColumns(chunkDBIDs, metas),
+			entity.NewColumnVarChar("document_id", documentIDs),
+			entity.NewColumnVarChar("knowledge_base_id", knowledgeBaseIDs),
+			entity.NewColumnVarChar("content", contents),
+			entity.NewColumnFloatVector("embedding", vs.dimension, vectors),
+		)...,
 	)
 	if err != nil {
 		return fmt.Errorf("批量插入向量失败: %w", err)
@@ -447,8 +585,13 @@ func (vs *VectorStore) UpsertVectors(ctx context.Context, documentIDs []string,
 	return nil
 }
 
-// SearchVectors 搜索相似向量
-func (vs *VectorStore) SearchVectors(ctx context.Context, queryVector []float32, topK int, knowledgeBaseID *string) ([]SearchResult, error) {
+// SearchVectors 搜索相似向量。knowledgeBaseIDs 非空时仅在这些知识库内检索（下推为 Milvus 标量过滤表达式）。
+func (vs *VectorStore) SearchVectors(ctx context.Context, queryVector []float32, topK int, knowledgeBaseIDs []string) ([]SearchResult, error) {
+	return vs.SearchVectorsFiltered(ctx, queryVector, topK, knowledgeBaseIDs, MetadataFilter{})
+}
+
+// SearchVectorsFiltered 搜索相似向量，并按来源元数据（页码范围、标题路径前缀）过滤
+func (vs *VectorStore) SearchVectorsFiltered(ctx context.Context, queryVector []float32, topK int, knowledgeBaseIDs []string, filter MetadataFilter) ([]SearchResult, error) {
 	// 验证查询向量
 	if queryVector == nil || len(queryVector) == 0 {
 		return nil, fmt.Errorf("查询向量不能为空")
@@ -463,10 +606,7 @@ func (vs *VectorStore) SearchVectors(ctx context.Context, queryVector []float32,
 	}
 
 	// 构建搜索表达式
-	expr := ""
-	if knowledgeBaseID != nil && *knowledgeBaseID != "" {
-		expr = fmt.Sprintf("knowledge_base_id == \"%s\"", *knowledgeBaseID)
-	}
+	expr := joinExprs(buildKnowledgeBaseExpr(knowledgeBaseIDs), filter.expr())
 
 	// 执行搜索
 	// 注意：Milvus SDK v2 的 Search 方法参数顺序：
@@ -477,7 +617,7 @@ func (vs *VectorStore) SearchVectors(ctx context.Context, queryVector []float32,
 	vector := entity.FloatVector(queryVector)
 	
 	// 确保 outputFields 不为空
-	outputFields := []string{"document_id", "knowledge_base_id", "content"}
+	outputFields := []string{"document_id", "knowledge_base_id", "content", "chunk_db_id", "page_number", "heading_path", "source_anchor", "source_type"}
 
 	// 构建搜索参数
 	vectors := []entity.Vector{vector}
@@ -536,6 +676,11 @@ func (vs *VectorStore) SearchVectors(ctx context.Context, queryVector []float32,
 		docCol := sr.Fields.GetColumn("document_id")
 		kbCol := sr.Fields.GetColumn("knowledge_base_id")
 		contentCol := sr.Fields.GetColumn("content")
+		chunkCol := sr.Fields.GetColumn("chunk_db_id")
+		pageCol := sr.Fields.GetColumn("page_number")
+		headingCol := sr.Fields.GetColumn("heading_path")
+		anchorCol := sr.Fields.GetColumn("source_anchor")
+		sourceTypeCol := sr.Fields.GetColumn("source_type")
 		if docCol == nil || kbCol == nil || contentCol == nil {
 			continue
 		}
@@ -543,11 +688,30 @@ func (vs *VectorStore) SearchVectors(ctx context.Context, queryVector []float32,
 			documentID, _ := docCol.GetAsString(i)
 			knowledgeBaseID, _ := kbCol.GetAsString(i)
 			content, _ := contentCol.GetAsString(i)
+			chunkDBID := ""
+			if chunkCol != nil {
+				chunkDBID, _ = chunkCol.GetAsString(i)
+			}
+			var meta VectorMetadata
+			if pageCol != nil {
+				meta.PageNumber, _ = pageCol.GetAsInt64(i)
+			}
+			if headingCol != nil {
+				meta.HeadingPath, _ = headingCol.GetAsString(i)
+			}
+			if anchorCol != nil {
+				meta.SourceAnchor, _ = anchorCol.GetAsString(i)
+			}
+			if sourceTypeCol != nil {
+				meta.SourceType, _ = sourceTypeCol.GetAsString(i)
+			}
 			score := sr.Scores[i]
 			results = append(results, SearchResult{
 				DocumentID:      documentID,
 				KnowledgeBaseID: knowledgeBaseID,
 				Content:         content,
+				ChunkDBID:       chunkDBID,
+				Metadata:        meta,
 				Score:           score,
 			})
 		}
@@ -625,5 +789,129 @@ type SearchResult struct {
 	DocumentID      string
 	KnowledgeBaseID string
 	Content         string
+	ChunkDBID       string // 分段在 MySQL 中的 ID（整篇文档/FAQ 向量为空）
+	Metadata        VectorMetadata
 	Score           float32
 }
+
+const (
+	maxHeadingPathBytes  = 1024
+	maxSourceAnchorBytes = 2048
+)
+
+// SourceTypeFAQ FAQ 向量的来源类型（文档与分段向量的来源类型为空）
+const SourceTypeFAQ = "faq"
+
+// VectorMetadata 向量的来源元数据（分段所在页码、标题路径、来源锚点，以及区分 FAQ 的来源类型），整篇文档向量为空
+type VectorMetadata struct {
+	PageNumber   int64
+	HeadingPath  string
+	SourceAnchor string
+	SourceType   string
+}
+
+// MetadataFilter 按来源元数据过滤检索结果；零值表示不过滤
+type MetadataFilter struct {
+	PageFrom      int    // 页码下限（含），0 表示不限
+	PageTo        int    // 页码上限（含），0 表示不限
+	HeadingPrefix string // 标题路径前缀，如「第三章」
+	SourceType    string // 仅检索该来源类型的向量（如 SourceTypeFAQ），空表示不限
+}
+
+// expr 转为 Milvus 标量过滤表达式
+func (f MetadataFilter) expr() string {
+	var parts []string
+	if f.SourceType != "" {
+		parts = append(parts, "source_type == "+strconv.Quote(f.SourceType))
+	}
+	if f.PageFrom > 0 {
+		parts = append(parts, fmt.Sprintf("page_number >= %d", f.PageFrom))
+	} else if f.PageTo > 0 {
+		parts = append(parts, "page_number >= 1") // 仅有上限时排除无页码的向量
+	}
+	if f.PageTo > 0 {
+		parts = append(parts, fmt.Sprintf("page_number <= %d", f.PageTo))
+	}
+	if prefix := strings.TrimSpace(f.HeadingPrefix); prefix != "" {
+		// like 中 % 与 _ 为通配符：截断到首个通配符前（结果为超集，由调用方精确过滤）
+		if i := strings.IndexAny(prefix, "%_"); i >= 0 {
+			prefix = prefix[:i]
+		}
+		if prefix != "" {
+			parts = append(parts, "heading_path like "+strconv.Quote(prefix+"%"))
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+// joinExprs 以 && 连接非空的过滤表达式
+func joinExprs(exprs ...string) string {
+	parts := make([]string, 0, len(exprs))
+	for _, e := range exprs {
+		if e != "" {
+			parts = append(parts, "("+e+")")
+		}
+	}
+	if len(parts) == 1 {
+		return strings.Trim(parts[0], "()")
+	}
+	return strings.Join(parts, " && ")
+}
+
+// metadataColumns 构造 chunk_db_id 与来源元数据列（超长的标题路径 / 锚点按字节截断）
+func metadataColumns(chunkDBIDs []string, metas []VectorMetadata) []entity.Column {
+	pages := make([]int64, len(metas))
+	headings := make([]string, len(metas))
+	anchors := make([]string, len(metas))
+	sourceTypes := make([]string, len(metas))
+	for i, m := range metas {
+		pages[i] = m.PageNumber
+		headings[i] = truncateUTF8(m.HeadingPath, maxHeadingPathBytes)
+		anchors[i] = truncateUTF8(m.SourceAnchor, maxSourceAnchorBytes)
+		sourceTypes[i] = m.SourceType
+	}
+	return []entity.Column{
+		entity.NewColumnVarChar("chunk_db_id", chunkDBIDs),
+		entity.NewColumnInt64("page_number", pages),
+		entity.NewColumnVarChar("heading_path", headings),
+		entity.NewColumnVarChar("source_anchor", anchors),
+		entity.NewColumnVarChar("source_type", sourceTypes),
+	}
+}
+
+// truncateUTF8 按字节截断字符串且不截断多字节字符
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	s = s[:maxBytes]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// buildKnowledgeBaseExpr 构建知识库过滤表达式：单个用 ==，多个用 in [...]；空列表表示不过滤。
+func buildKnowledgeBaseExpr(knowledgeBaseIDs []string) string {
+	quoted := make([]string, 0, len(knowledgeBaseIDs))
+	seen := make(map[string]struct{}, len(knowledgeBaseIDs))
+	for _, id := range knowledgeBaseIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		quoted = append(quoted, strconv.Quote(id))
+	}
+	switch len(quoted) {
+	case 0:
+		return ""
+	case 1:
+		return "knowledge_base_id == " + quoted[0]
+	default:
+		return "knowledge_base_id in [" + strings.Join(quoted, ", ") + "]"
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/2930134478/AI-CS/backend/infra/geoip"
 	"github.com/2930134478/AI-CS/backend/infra/mcp"
 	infra_search "github.com/2930134478/AI-CS/backend/infra/search"
+	"github.com/2930134478/AI-CS/backend/infra/translate"
+	"github.com/2930134478/AI-CS/backend/mcpserver"
 	"github.com/2930134478/AI-CS/backend/middleware"
 	"github.com/2930134478/AI-CS/backend/models"
 	"github.com/2930134478/AI-CS/backend/repository"
@@ -144,7 +146,7 @@ func main() {
 	}
 
 	//根据结构体定义自动创建更新表
-	if err := db.AutoMigrate(&models.User{}, &models.Conversation{}, &models.Message{}, &models.AIConfig{}, &models.FAQ{}, &models.KnowledgeBase{}, &models.Document{}, &models.DocumentChunk{}, &models.EmbeddingConfig{}, &models.EmailNotificationConfig{}, &models.OfflineEmailJob{}, &models.PromptConfig{}, &models.WidgetOpenEvent{}, &models.SystemLog{}, &models.AppSetting{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Conversation{}, &models.Message{}, &models.AIConfig{}, &models.FAQ{}, &models.KnowledgeBase{}, &models.Document{}, &models.DocumentChunk{}, &models.EmbeddingConfig{}, &models.EmailNotificationConfig{}, &models.OfflineEmailJob{}, &models.PromptConfig{}, &models.WidgetOpenEvent{}, &models.SystemLog{}, &models.AppSetting{}, &models.KnowledgeBaseBinding{}, &models.ConversationMemory{}, &models.ConversationParticipant{}, &models.Macro{}, &models.MacroFolder{}, &models.Tag{}, &models.ConversationTag{}, &models.CustomFieldDefinition{}, &models.ConversationFieldValue{}, &models.AIUsageRecord{}, &models.AIModelPrice{}, &models.AITool{}, &models.MCPServer{}, &models.CopilotSuggestion{}, &models.ConversationCategory{}, &models.CrawlSource{}, &models.CrawlPage{}); err != nil {
 		log.Fatalf("自动创建表失败： %v", err)
 	}
 
@@ -154,6 +156,19 @@ func main() {
 	aiConfigRepo := repository.NewAIConfigRepository(db)
 	faqRepo := repository.NewFAQRepository(db)
 	kbRepo := repository.NewKnowledgeBaseRepository(db)
+	kbBindingRepo := repository.NewKnowledgeBaseBindingRepository(db)
+	conversationMemoryRepo := repository.NewConversationMemoryRepository(db)
+	participantRepo := repository.NewConversationParticipantRepository(db)
+	macroRepo := repository.NewMacroRepository(db)
+	tagRepo := repository.NewTagRepository(db)
+	customFieldRepo := repository.NewCustomFieldRepository(db)
+	aiUsageRepo := repository.NewAIUsageRepository(db)
+	aiModelPriceRepo := repository.NewAIModelPriceRepository(db)
+	aiToolRepo := repository.NewAIToolRepository(db)
+	mcpServerRepo := repository.NewMCPServerRepository(db)
+	copilotSuggestionRepo := repository.NewCopilotSuggestionRepository(db)
+	conversationCategoryRepo := repository.NewConversationCategoryRepository(db)
+	crawlSourceRepo := repository.NewCrawlSourceRepository(db)
 	docRepo := repository.NewDocumentRepository(db)
 	chunkRepo := repository.NewDocumentChunkRepository(db)
 	embeddingConfigRepo := repository.NewEmbeddingConfigRepository(db)
@@ -290,7 +305,7 @@ func main() {
 		dimension = initSvc.GetDimension()
 	}
 
-	// 向量存储：迁移时通过 getEmbedding 从当前配置重新向量化
+	// 向量存储：迁移时通过 getEmbedding 从当前配置重新向量化，旧集合缺少来源类型时按 FAQ 表判定 FAQ 向量
 	getEmbedding := func(ctx context.Context) (infra.EmbeddingService, error) {
 		svc, err := embeddingProvider.Get(ctx)
 		if err != nil || svc == nil {
@@ -299,7 +314,7 @@ func main() {
 		return svc, nil
 	}
 	if milvusClient != nil {
-		vs, err := infra.NewVectorStore(milvusClient, "documents", dimension, getEmbedding)
+		vs, err := infra.NewVectorStore(milvusClient, "documents", dimension, getEmbedding, service.LegacyFAQSourceType(faqRepo))
 		if err != nil {
 			_ = milvusClient.Close()
 			milvusClient = nil
@@ -338,6 +353,13 @@ func main() {
 	// 文档向量化 / RAG 检索 / 健康检查均使用 provider，配置保存即生效
 	documentEmbeddingService := rag.NewDocumentEmbeddingService(vectorStoreService, embeddingProvider)
 	retrievalService := rag.NewRetrievalService(vectorStoreService, embeddingProvider, docRepo, kbRepo)
+	// 关键词 / 混合检索：document_chunks.content 上的 ngram 全文索引（失败时关键词检索退化为 LIKE）
+	if err := chunkRepo.EnsureFulltextIndex(); err != nil {
+		log.Printf("⚠️ 创建分段全文索引失败，关键词检索将退化为 LIKE 匹配: %v", err)
+	}
+	retrievalService.SetKeywordSearcher(rag.NewChunkKeywordSearcher(chunkRepo))
+	// 交叉编码器重排序：读取知识库配置中的 /rerank 服务，未启用时不做重排序
+	retrievalService.SetReranker(service.NewConfigBackedReranker(embeddingConfigService))
 	retrievalService.EnableCache(5 * time.Minute)
 	if v := os.Getenv("RAG_MIN_SCORE"); v != "" {
 		if score, err := strconv.ParseFloat(v, 32); err == nil {
@@ -368,17 +390,47 @@ func main() {
 	// 初始化服务层
 	authService := service.NewAuthService(userRepo)
 	conversationService := service.NewConversationService(conversationRepo, messageRepo, aiConfigRepo, userRepo, systemLogService, appSettingRepo)
-	conversationService.StartStaleConversationCleanup()
+	kbBindingService := service.NewKnowledgeBaseBindingService(kbBindingRepo, kbRepo)
+	conversationService.SetKnowledgeBaseBindingService(kbBindingService)
 	profileService := service.NewProfileService(userRepo, storageService)
 	aiConfigService := service.NewAIConfigService(aiConfigRepo, userRepo)
 	aiService := service.NewAIService(aiConfigRepo, messageRepo, conversationRepo, retrievalService, webSearchProvider, embeddingConfigService, promptConfigService, storageService, systemLogService, faqRepo)
+	aiService.SetConversationMemoryRepository(conversationMemoryRepo)
+	// 回复引用来源：按文档 ID 补全标题
+	aiService.SetDocumentRepository(docRepo)
+	// token 用量与费用：对话 / 工具调用 / 生图经 AIService 记录，向量化经嵌入服务回调记录
+	aiUsageService := service.NewAIUsageService(aiUsageRepo, aiModelPriceRepo, aiConfigRepo, systemLogService)
+	aiService.SetUsageService(aiUsageService)
+	embeddingProvider.SetUsageRecorder(aiUsageService.EmbeddingRecorder())
+	// 管理员定义的 HTTP 工具：按 AI 配置白名单提供给模型 function calling
+	aiToolService := service.NewAIToolService(aiToolRepo)
+	aiService.SetToolService(aiToolService)
+	aiConfigService.SetToolService(aiToolService)
+	// 管理员注册的 MCP 服务：后台保持连接，选中的工具提供给模型
+	mcpServerService := service.NewMCPServerService(mcpServerRepo, systemLogService)
+	mcpServerService.Start(context.Background())
+	aiService.SetMCPServerService(mcpServerService)
+	aiConfigService.SetMCPServerService(mcpServerService)
 	userService := service.NewUserService(userRepo, aiConfigRepo)                                              // 用户管理服务
 	faqService := service.NewFAQService(faqRepo, retrievalService, documentEmbeddingService)                   // FAQ 管理服务
 	documentService := service.NewDocumentService(docRepo, kbRepo, documentEmbeddingService, retrievalService) // 文档管理服务
 	knowledgeBaseService := service.NewKnowledgeBaseService(kbRepo, docRepo)                                   // 知识库管理服务
 	importService := service.NewImportService(docRepo, kbRepo, documentService, documentEmbeddingService)      // 导入服务
 	chunkService := service.NewChunkService(docRepo, kbRepo, chunkRepo, documentEmbeddingService, vectorStoreService) // 分段服务
+	// 向量集合在启动时被清空重建（旧数据无法迁移）：重置向量状态并在后台重新向量化全部文档、分段与 FAQ
+	if vectorStore != nil && vectorStore.NeedsReembed() {
+		reembedService := service.NewVectorReembedService(docRepo, chunkRepo, faqRepo, documentService, chunkService, faqService)
+		if err := reembedService.ReembedAll(); err != nil {
+			log.Printf("⚠️ 重置向量化状态失败，请手动重新向量化文档与 FAQ: %v", err)
+		} else {
+			logVectorStartup(systemLogService, "warn", "milvus_collection_rebuilt",
+				"向量集合已重建，正在后台重新向量化全部文档、分段与 FAQ", map[string]interface{}{"collection": "documents"})
+		}
+	}
 	emailNotificationConfigService := service.NewEmailNotificationConfigService(emailNotificationConfigRepo, userRepo)
+	// 网站抓取：从起始页面或 sitemap.xml 抓取同站页面导入知识库，按周期重新抓取并只更新变化的页面
+	crawlService := service.NewCrawlService(crawlSourceRepo, docRepo, kbRepo, importService, chunkService, systemLogService)
+	go crawlService.StartScheduler(context.Background())
 
 	// 声明 Hub / 离线邮件变量（Hub 创建后完成注入）
 	var wsHub *websocket.Hub
@@ -511,9 +563,42 @@ func main() {
 	)
 	go offlineEmailSvc.StartWorker(context.Background())
 
+	// 会话自动分配：新建人工会话 / 转人工时按策略（ASSIGNMENT_STRATEGY 或后台配置）分配在线客服
+	assignmentService := service.NewAssignmentService(conversationRepo, userRepo, appSettingRepo, wsHub, wsHub, systemLogService)
+	assignmentService.SetParticipantRepository(participantRepo)
+	conversationService.SetAssignmentService(assignmentService)
+	// 多客服协作：邀请主管、whisper 内部备注（访客不可见）、参与记录审计
+	collaborationService := service.NewCollaborationService(conversationRepo, messageRepo, participantRepo, userRepo, wsHub, systemLogService)
+
 	messageService := service.NewMessageService(db, conversationRepo, messageRepo, wsHub, aiService)
 	messageService.SetOfflineEmailService(offlineEmailSvc)
+	// AI 转人工：关键词 / 模型意图 / 连续失败触发，通知在线客服并进入排队
+	handoffService := service.NewHandoffService(conversationRepo, messageRepo, wsHub, systemLogService)
+	messageService.SetHandoffService(handoffService)
+	handoffService.SetAssignmentService(assignmentService)
+	aiService.SetHandoffIntentEnabled(true)
 	visitorService := service.NewVisitorService(userRepo, wsHub)
+	// 会话标签 / 优先级 / 自定义字段：会话列表按其筛选排序，变更时推送给客服
+	attributeService := service.NewConversationAttributeService(conversationRepo, tagRepo, customFieldRepo, wsHub)
+	conversationService.SetAttributeService(attributeService)
+	macroService := service.NewMacroService(macroRepo, conversationRepo, userRepo, messageService, conversationService)
+	macroService.SetConversationTagger(attributeService)
+	// 客服助手：人工会话收到访客消息时起草候选回复，仅推送给会话内客服
+	copilotService := service.NewCopilotService(copilotSuggestionRepo, conversationRepo, messageRepo, aiConfigRepo, appSettingRepo, aiService, wsHub, systemLogService)
+	messageService.SetCopilotService(copilotService)
+	// 会话小结：手动 / 自动关闭访客会话后异步生成摘要、分类、解决状态与关键实体
+	summaryService := service.NewConversationSummaryService(conversationRepo, messageRepo, conversationCategoryRepo, aiConfigRepo, appSettingRepo, aiService, systemLogService)
+	summaryService.Start()
+	conversationService.SetSummaryService(summaryService)
+	// 实时翻译：人工会话中访客消息译为客服语言、客服回复译为访客语言；配置 TRANSLATE_API_URL 时可选用外部翻译服务
+	translationService := service.NewTranslationService(aiConfigRepo, appSettingRepo, aiService, systemLogService)
+	if translateURL := os.Getenv("TRANSLATE_API_URL"); translateURL != "" {
+		translationService.SetExternalTranslator(translate.NewLibreTranslateProvider(translateURL, os.Getenv("TRANSLATE_API_KEY")))
+		log.Println("✅ 外部翻译服务已接入（LibreTranslate 兼容接口）")
+	}
+	messageService.SetTranslationService(translationService)
+	// 注入小结服务后再启动自动关闭任务，使启动时关闭的会话也能生成小结
+	conversationService.StartStaleConversationCleanup()
 
 	// 初始化控制器
 	authController := controller.NewAuthController(authService)
@@ -526,17 +611,30 @@ func main() {
 	documentController := controller.NewDocumentController(documentService, embeddingConfigService, userService)
 	embeddingConfigController := controller.NewEmbeddingConfigController(embeddingConfigService, userService)
 	promptConfigController := controller.NewPromptConfigController(promptConfigService, userService)
-	knowledgeBaseController := controller.NewKnowledgeBaseController(knowledgeBaseService, embeddingConfigService, userService)
+	knowledgeBaseController := controller.NewKnowledgeBaseController(knowledgeBaseService, embeddingConfigService, kbBindingService, userService)
 	importController := controller.NewImportController(importService, embeddingConfigService, userService) // 导入控制器
 	chunkController := controller.NewDocumentChunkController(chunkService, userService)                   // 分段控制器
+	crawlSourceController := controller.NewCrawlSourceController(crawlService, embeddingConfigService, userService)
 	emailNotificationController := controller.NewEmailNotificationConfigController(emailNotificationConfigService, offlineEmailSvc, userService)
 	visitorController := controller.NewVisitorController(visitorService, embeddingConfigService)
 	healthController := controller.NewHealthController(healthChecker, retrievalService) // 健康检查控制器
+	healthController.SetMCPServerService(mcpServerService)
 
 	widgetOpenRepo := repository.NewWidgetOpenRepository(db)
 	analyticsService := service.NewAnalyticsService(db, widgetOpenRepo)
 	analyticsController := controller.NewAnalyticsController(analyticsService, userService)
 	systemLogController := controller.NewSystemLogController(systemLogService, userService, appSettingRepo)
+	handoffController := controller.NewHandoffController(handoffService, messageService, conversationService, userService)
+	assignmentController := controller.NewAssignmentController(assignmentService, userService)
+	collaborationController := controller.NewCollaborationController(collaborationService, userService)
+	macroController := controller.NewMacroController(macroService, conversationService, userService)
+	attributeController := controller.NewConversationAttributeController(attributeService, userService)
+	aiUsageController := controller.NewAIUsageController(aiUsageService, userService)
+	aiToolController := controller.NewAIToolController(aiToolService, userService)
+	mcpServerController := controller.NewMCPServerController(mcpServerService, userService)
+	copilotController := controller.NewCopilotController(copilotService, userService)
+	summaryController := controller.NewConversationSummaryController(summaryService, userService)
+	translationController := controller.NewTranslationController(translationService, userService)
 
 	appRouter.RegisterRoutes(
 		r,
@@ -559,8 +657,27 @@ func main() {
 			Health:          healthController, // 健康检查控制器
 			Analytics:       analyticsController,
 			SystemLog:       systemLogController,
+			Handoff:         handoffController,
+			Assignment:      assignmentController,
+			Collaboration:   collaborationController,
+			Macro:           macroController,
+			Attribute:       attributeController,
+			AIUsage:         aiUsageController,
+			AITool:          aiToolController,
+			MCPServer:       mcpServerController,
+			Copilot:         copilotController,
+			Summary:         summaryController,
+			Translation:     translationController,
+			CrawlSource:     crawlSourceController,
 		},
 		websocket.HandleWebSocket(wsHub, userRepo, conversationService),
+		mcpserver.Handler(mcpserver.Deps{
+			Retrieval:     retrievalService,
+			FAQs:          faqService,
+			Conversations: conversationService,
+			Messages:      messageService,
+			Users:         userService,
+		}),
 	)
 
 	// 配置静态文件服务（用于访问上传的头像等文件）
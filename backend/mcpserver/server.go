@@ -0,0 +1,368 @@
+// Package mcpserver 将 AI-CS 自身作为 MCP 服务暴露给内部助手：检索知识库 / FAQ、读取会话记录、创建 FAQ。
+// 使用客服登录令牌（Bearer）鉴权，每个工具按 service/permissions.go 中的权限键校验。
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/service"
+	"github.com/2930134478/AI-CS/backend/service/rag"
+	"github.com/2930134478/AI-CS/backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultSearchTopK   = 5
+	maxSearchTopK       = 20
+	defaultFAQLimit     = 10
+	maxFAQLimit         = 50
+	defaultListPageSize = 20
+	maxListPageSize     = 100
+	// 单条消息在会话记录中的最大长度，避免超长内容撑爆调用方上下文
+	maxTranscriptMessageRunes = 2000
+)
+
+// Deps MCP 工具依赖的业务服务。
+type Deps struct {
+	Retrieval     *rag.RetrievalService
+	FAQs          *service.FAQService
+	Conversations *service.ConversationService
+	Messages      *service.MessageService
+	Users         *service.UserService
+}
+
+// Handler 返回 MCP streamable HTTP 端点（POST/GET/DELETE 同一路径），要求 Authorization: Bearer <客服登录令牌>。
+func Handler(deps Deps) gin.HandlerFunc {
+	server := newServer(deps)
+	streamable := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil)
+	h := auth.RequireBearerToken(verifyAgentToken, nil)(streamable)
+	return gin.WrapH(h)
+}
+
+// verifyAgentToken 校验客服登录令牌；UserID 供 SDK 绑定会话，防止他人复用 session id。
+func verifyAgentToken(_ context.Context, token string, _ *http.Request) (*auth.TokenInfo, error) {
+	userID, ok := utils.ParseAgentToken(token)
+	if !ok {
+		return nil, fmt.Errorf("%w: 令牌无效或已过期", auth.ErrInvalidToken)
+	}
+	return &auth.TokenInfo{
+		UserID:     strconv.FormatUint(uint64(userID), 10),
+		Expiration: time.Now().Add(time.Minute), // 令牌本身的过期已在 ParseAgentToken 中校验
+	}, nil
+}
+
+func newServer(deps Deps) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "ai-cs", Version: "v1.0.0"}, nil)
+	t := &tools{deps: deps}
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "search_knowledge_base",
+		Description: "在客服知识库中语义检索，返回最相关的文档片段及相关度分数。",
+	}, t.searchKnowledgeBase)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "search_faqs",
+		Description: "检索常见问题（FAQ），返回问题、答案与关键词；query 为空时返回全部 FAQ。",
+	}, t.searchFAQs)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_conversation_transcript",
+		Description: "获取指定会话的基本信息与完整聊天记录（访客 / 客服 / AI 消息按时间排列）。",
+	}, t.getConversationTranscript)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_open_conversations",
+		Description: "分页列出进行中的访客会话（按更新时间倒序），含优先级、标签与最后一条消息。",
+	}, t.listOpenConversations)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "create_faq",
+		Description: "新增一条 FAQ（问题 + 答案，可选关键词），创建后即可被检索。",
+	}, t.createFAQ)
+	return server
+}
+
+type tools struct {
+	deps Deps
+}
+
+// userFromRequest 取出 Bearer 鉴权得到的用户 ID
+func userFromRequest(req *mcp.CallToolRequest) (uint, error) {
+	if req.Extra == nil || req.Extra.TokenInfo == nil {
+		return 0, errors.New("未授权访问，请登录")
+	}
+	uid, err := strconv.ParseUint(req.Extra.TokenInfo.UserID, 10, 64)
+	if err != nil || uid == 0 {
+		return 0, errors.New("未授权访问，请登录")
+	}
+	return uint(uid), nil
+}
+
+// requirePermission 校验当前用户的权限，返回用户 ID
+func (t *tools) requirePermission(req *mcp.CallToolRequest, perm service.PermissionKey) (uint, error) {
+	userID, err := userFromRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.deps.Users.CheckPermission(userID, string(perm)); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// jsonResult 将结果序列化为文本内容返回
+func jsonResult(v interface{}) (*mcp.CallToolResult, any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: string(data)}}}, nil, nil
+}
+
+type searchKnowledgeBaseInput struct {
+	Query           string `json:"query" jsonschema:"检索内容"`
+	TopK            int    `json:"top_k,omitempty" jsonschema:"返回条数，默认 5，最多 20"`
+	KnowledgeBaseID uint   `json:"knowledge_base_id,omitempty" jsonschema:"限定知识库 ID，不传表示检索全部知识库"`
+}
+
+type knowledgeHit struct {
+	DocumentID      string  `json:"document_id"`
+	KnowledgeBaseID string  `json:"knowledge_base_id"`
+	ChunkID         string  `json:"chunk_id,omitempty"`
+	Score           float32 `json:"score"`
+	Content         string  `json:"content"`
+}
+
+func (t *tools) searchKnowledgeBase(ctx context.Context, req *mcp.CallToolRequest, in searchKnowledgeBaseInput) (*mcp.CallToolResult, any, error) {
+	if _, err := t.requirePermission(req, service.PermKnowledge); err != nil {
+		return nil, nil, err
+	}
+	query := strings.TrimSpace(in.Query)
+	if query == "" {
+		return nil, nil, errors.New("query 不能为空")
+	}
+	if t.deps.Retrieval == nil {
+		return nil, nil, errors.New("知识库检索未启用")
+	}
+	topK := in.TopK
+	if topK <= 0 {
+		topK = defaultSearchTopK
+	}
+	if topK > maxSearchTopK {
+		topK = maxSearchTopK
+	}
+	var kbID *uint
+	if in.KnowledgeBaseID > 0 {
+		kbID = &in.KnowledgeBaseID
+	}
+	results, err := t.deps.Retrieval.RetrieveWithRerank(ctx, query, topK, kbID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("知识库检索失败: %w", err)
+	}
+	hits := make([]knowledgeHit, 0, len(results))
+	for _, r := range results {
+		hits = append(hits, knowledgeHit{
+			DocumentID:      r.DocumentID,
+			KnowledgeBaseID: r.KnowledgeBaseID,
+			ChunkID:         r.ChunkID,
+			Score:           r.Score,
+			Content:         r.Content,
+		})
+	}
+	return jsonResult(map[string]interface{}{"results": hits})
+}
+
+type searchFAQsInput struct {
+	Query string `json:"query,omitempty" jsonschema:"检索内容，为空返回全部"`
+	Limit int    `json:"limit,omitempty" jsonschema:"返回条数，默认 10，最多 50"`
+}
+
+type faqItem struct {
+	ID       uint   `json:"id"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Keywords string `json:"keywords,omitempty"`
+}
+
+func (t *tools) searchFAQs(_ context.Context, req *mcp.CallToolRequest, in searchFAQsInput) (*mcp.CallToolResult, any, error) {
+	if _, err := t.requirePermission(req, service.PermFAQs); err != nil {
+		return nil, nil, err
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultFAQLimit
+	}
+	if limit > maxFAQLimit {
+		limit = maxFAQLimit
+	}
+	faqs, err := t.deps.FAQs.ListFAQs(strings.TrimSpace(in.Query))
+	if err != nil {
+		return nil, nil, fmt.Errorf("FAQ 检索失败: %w", err)
+	}
+	if len(faqs) > limit {
+		faqs = faqs[:limit]
+	}
+	items := make([]faqItem, 0, len(faqs))
+	for _, f := range faqs {
+		items = append(items, faqItem{ID: f.ID, Question: f.Question, Answer: f.Answer, Keywords: f.Keywords})
+	}
+	return jsonResult(map[string]interface{}{"faqs": items})
+}
+
+type transcriptInput struct {
+	ConversationID  uint `json:"conversation_id" jsonschema:"会话 ID"`
+	IncludeWhispers bool `json:"include_whispers,omitempty" jsonschema:"是否包含客服内部悄悄话"`
+}
+
+func (t *tools) getConversationTranscript(_ context.Context, req *mcp.CallToolRequest, in transcriptInput) (*mcp.CallToolResult, any, error) {
+	userID, err := userFromRequest(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if in.ConversationID == 0 {
+		return nil, nil, errors.New("conversation_id 不能为空")
+	}
+	detail, err := t.deps.Conversations.GetConversationDetail(in.ConversationID, userID)
+	if err != nil {
+		return nil, nil, errors.New("会话不存在")
+	}
+	// 与 HTTP 接口一致：内部对话需知识库测试权限（仅创建者可见），访客对话需对话权限
+	perm := service.PermChat
+	if detail.ConversationType == "internal" {
+		perm = service.PermKBTest
+	}
+	if err := t.deps.Users.CheckPermission(userID, string(perm)); err != nil {
+		return nil, nil, err
+	}
+	messages, err := t.deps.Messages.ListMessages(in.ConversationID, true, in.IncludeWhispers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询消息失败: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "会话 #%d（%s，状态 %s，模式 %s）\n", detail.ID, detail.ConversationType, detail.Status, detail.ChatMode)
+	if detail.Location != "" || detail.Website != "" {
+		fmt.Fprintf(&b, "访客来源：%s %s\n", detail.Location, detail.Website)
+	}
+	if len(detail.Tags) > 0 {
+		names := make([]string, 0, len(detail.Tags))
+		for _, tag := range detail.Tags {
+			names = append(names, tag.Name)
+		}
+		fmt.Fprintf(&b, "标签：%s\n", strings.Join(names, "、"))
+	}
+	b.WriteString("\n")
+	for _, msg := range messages {
+		role := "访客"
+		switch {
+		case msg.SenderIsAgent && msg.SenderID == 0:
+			role = "AI"
+		case msg.SenderIsAgent:
+			role = "客服"
+		}
+		if msg.MessageType == models.MessageTypeWhisper {
+			role += "（内部）"
+		}
+		content := strings.TrimSpace(msg.Content)
+		if content == "" && msg.FileName != nil {
+			content = "[文件] " + *msg.FileName
+		}
+		if r := []rune(content); len(r) > maxTranscriptMessageRunes {
+			content = string(r[:maxTranscriptMessageRunes]) + "…"
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s\n", msg.CreatedAt.Format("2006-01-02 15:04:05"), role, content)
+	}
+	if len(messages) == 0 {
+		b.WriteString("（暂无消息）\n")
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: b.String()}}}, nil, nil
+}
+
+type listOpenConversationsInput struct {
+	Page  int `json:"page,omitempty" jsonschema:"页码，从 1 开始"`
+	Limit int `json:"limit,omitempty" jsonschema:"每页条数，默认 20，最多 100"`
+}
+
+type conversationItem struct {
+	ID          uint      `json:"id"`
+	ChatMode    string    `json:"chat_mode"`
+	AgentID     uint      `json:"agent_id"`
+	Priority    int       `json:"priority"`
+	Tags        []string  `json:"tags,omitempty"`
+	UnreadCount int64     `json:"unread_count"`
+	LastMessage string    `json:"last_message,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (t *tools) listOpenConversations(_ context.Context, req *mcp.CallToolRequest, in listOpenConversationsInput) (*mcp.CallToolResult, any, error) {
+	userID, err := t.requirePermission(req, service.PermChat)
+	if err != nil {
+		return nil, nil, err
+	}
+	page := in.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultListPageSize
+	}
+	if limit > maxListPageSize {
+		limit = maxListPageSize
+	}
+	result, err := t.deps.Conversations.ListConversationsPaginated(userID, "open", page, limit, service.ConversationListFilter{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+	items := make([]conversationItem, 0, len(result.Items))
+	for _, conv := range result.Items {
+		item := conversationItem{
+			ID:          conv.ID,
+			ChatMode:    conv.ChatMode,
+			AgentID:     conv.AgentID,
+			Priority:    conv.Priority,
+			UnreadCount: conv.UnreadCount,
+			UpdatedAt:   conv.UpdatedAt,
+		}
+		for _, tag := range conv.Tags {
+			item.Tags = append(item.Tags, tag.Name)
+		}
+		if conv.LastMessage != nil {
+			item.LastMessage = conv.LastMessage.Content
+		}
+		items = append(items, item)
+	}
+	return jsonResult(map[string]interface{}{
+		"conversations": items,
+		"total":         result.Total,
+		"page":          result.Page,
+		"has_more":      result.HasMore,
+	})
+}
+
+type createFAQInput struct {
+	Question string `json:"question" jsonschema:"问题"`
+	Answer   string `json:"answer" jsonschema:"答案"`
+	Keywords string `json:"keywords,omitempty" jsonschema:"关键词，逗号分隔"`
+}
+
+func (t *tools) createFAQ(_ context.Context, req *mcp.CallToolRequest, in createFAQInput) (*mcp.CallToolResult, any, error) {
+	if _, err := t.requirePermission(req, service.PermFAQs); err != nil {
+		return nil, nil, err
+	}
+	faq, err := t.deps.FAQs.CreateFAQ(service.CreateFAQInput{
+		Question: strings.TrimSpace(in.Question),
+		Answer:   strings.TrimSpace(in.Answer),
+		Keywords: strings.TrimSpace(in.Keywords),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return jsonResult(faqItem{ID: faq.ID, Question: faq.Question, Answer: faq.Answer, Keywords: faq.Keywords})
+}
@@ -14,12 +14,19 @@ type AIConfig struct {
 	APIKey      string `json:"api_key" gorm:"type:varchar(1000)"`                 // API Key（加密存储）
 	Model       string `json:"model" gorm:"type:varchar(100)"`                    // 模型名称（如：gpt-3.5-turbo、gpt-4）
 	ModelType   string `json:"model_type" gorm:"type:varchar(20);default:'text'"` // 模型类型：text、image、audio、video
+	Protocol    string `json:"protocol" gorm:"type:varchar(20);default:'openai'"` // 接口协议：openai（兼容 Chat Completions，默认）、anthropic、gemini
 	IsActive    bool   `json:"is_active" gorm:"default:true"`                     // 是否启用（服务商级别）
 	IsPublic    bool   `json:"is_public" gorm:"default:false"`                    // 是否开放给访客使用（模型级别）
 	Description string `json:"description" gorm:"type:varchar(500)"`              // 配置描述
 	// 可选的适配参数（JSON 格式，用于适配不同服务商的细微差异）
 	// 例如：{"auth_header": "X-API-Key", "response_path": "data.choices[0].message.content"}
-	AdapterConfig string    `json:"adapter_config" gorm:"type:text"` // 适配器配置（JSON 格式）
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	AdapterConfig string `json:"adapter_config" gorm:"type:text"` // 适配器配置（JSON 格式）
+	// 对话历史 token 预算（0 表示使用默认值）；超出时较早的轮次会被压缩为滚动摘要
+	HistoryTokenBudget  int       `json:"history_token_budget" gorm:"default:0"`
+	FallbackConfigIDs   string    `json:"fallback_config_ids" gorm:"type:varchar(255)"`    // 故障转移链：逗号分隔的备用配置 ID（按顺序尝试）
+	MonthlyBudget       float64   `json:"monthly_budget" gorm:"default:0"`                 // 每月费用预算（0 不限），当月累计超出后自动停用
+	AllowedToolIDs      string    `json:"allowed_tool_ids" gorm:"type:varchar(500)"`       // 允许模型调用的 HTTP 工具（逗号分隔的 AITool ID，为空不开放工具）
+	AllowedMCPServerIDs string    `json:"allowed_mcp_server_ids" gorm:"type:varchar(500)"` // 允许模型调用的 MCP 服务（逗号分隔的 MCPServer ID，为空不开放 MCP 工具）
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
 }
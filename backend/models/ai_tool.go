@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// AITool 管理员定义的 HTTP 工具：模型通过 function calling 调用，由后端按模板发起 HTTP 请求并把结果回填给模型
+type AITool struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Name           string    `json:"name" gorm:"type:varchar(64);uniqueIndex"` // 函数名（模型调用时使用），如 get_order_status
+	Description    string    `json:"description" gorm:"type:varchar(1000)"`    // 告诉模型何时调用
+	Parameters     string    `json:"parameters" gorm:"type:text"`              // 参数 JSON Schema（type=object）
+	Method         string    `json:"method" gorm:"type:varchar(10);default:'GET'"`
+	URLTemplate    string    `json:"url_template" gorm:"type:varchar(1000)"` // 如 https://api.example.com/orders/{{order_id}}，参数值会做 URL 编码
+	BodyTemplate   string    `json:"body_template" gorm:"type:text"`         // POST/PUT 请求体模板（JSON，参数值按 JSON 字符串转义）；为空时直接发送参数对象
+	AuthHeader     string    `json:"auth_header" gorm:"type:varchar(100)"`   // 认证头名称，如 Authorization、X-API-Key
+	AuthValue      string    `json:"-" gorm:"type:varchar(1000)"`            // 认证头取值（加密存储，不返回前端）
+	ResponsePath   string    `json:"response_path" gorm:"type:varchar(255)"` // 从 JSON 响应中提取结果的路径，如 data.status、items[0]；为空返回完整响应
+	TimeoutSeconds int       `json:"timeout_seconds" gorm:"default:10"`
+	IsActive       bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// AIUsageRecord 单次模型调用的 token 用量与费用（对话、工具调用轮次、生图、向量化各记一条）
+type AIUsageRecord struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	AIConfigID       *uint     `json:"ai_config_id" gorm:"index"` // 向量化调用不属于 AI 配置时为空
+	Model            string    `json:"model" gorm:"type:varchar(100);index"`
+	Kind             string    `json:"kind" gorm:"type:varchar(20)"` // chat / tools / image / embedding
+	ConversationID   *uint     `json:"conversation_id" gorm:"index"`
+	MessageID        *uint     `json:"message_id" gorm:"index"` // 对应的 AI 回复消息（落库后回填）
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Images           int       `json:"images"`                         // 生图张数（按张计价）
+	Estimated        bool      `json:"estimated" gorm:"default:false"` // 接口未返回 usage，按字符估算
+	Cost             float64   `json:"cost"`                           // 按价格表计算的费用
+	CreatedAt        time.Time `json:"created_at" gorm:"index"`
+}
+
+// AIModelPrice 模型价格表（按模型名匹配，单价为每百万 token / 每张图）
+type AIModelPrice struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Model       string    `json:"model" gorm:"type:varchar(100);uniqueIndex"`
+	InputPrice  float64   `json:"input_price"`  // 输入每百万 token 单价
+	OutputPrice float64   `json:"output_price"` // 输出每百万 token 单价
+	ImagePrice  float64   `json:"image_price"`  // 生图每张单价
+	Currency    string    `json:"currency" gorm:"type:varchar(10);default:'USD'"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -14,4 +14,22 @@ const (
 	AppSettingKeySystemLogMinLevel = "system_log_min_level"
 	// AppSettingKeyAutoCloseConversationDays 自动关闭长期未活跃 open 访客会话的天数（0=禁用）
 	AppSettingKeyAutoCloseConversationDays = "auto_close_conversation_days"
+	// AppSettingKeyAssignmentStrategy 会话自动分配策略（值：manual/round_robin/least_busy/skill）
+	AppSettingKeyAssignmentStrategy = "assignment_strategy"
+	// AppSettingKeyCopilotEnabled 人工会话收到访客消息时是否自动起草候选回复（值：true/false）
+	AppSettingKeyCopilotEnabled = "copilot_enabled"
+	// AppSettingKeyCopilotAIConfigID 客服助手使用的 AI 配置 ID（0 或空表示使用负责客服的默认文本模型）
+	AppSettingKeyCopilotAIConfigID = "copilot_ai_config_id"
+	// AppSettingKeyConversationSummaryEnabled 会话关闭后是否自动生成小结与分类（值：true/false，未设置视为 true）
+	AppSettingKeyConversationSummaryEnabled = "conversation_summary_enabled"
+	// AppSettingKeyConversationSummaryAIConfigID 会话小结使用的 AI 配置 ID（0 或空表示按会话模型 / 负责客服默认模型）
+	AppSettingKeyConversationSummaryAIConfigID = "conversation_summary_ai_config_id"
+	// AppSettingKeyTranslationEnabled 人工会话是否开启访客与客服之间的实时翻译（值：true/false）
+	AppSettingKeyTranslationEnabled = "translation_enabled"
+	// AppSettingKeyTranslationAgentLanguage 客服工作语言（BCP 47，如 zh-CN），访客消息译为该语言
+	AppSettingKeyTranslationAgentLanguage = "translation_agent_language"
+	// AppSettingKeyTranslationProvider 翻译方式：ai（AI 文本模型）或 external（外部翻译服务）
+	AppSettingKeyTranslationProvider = "translation_provider"
+	// AppSettingKeyTranslationAIConfigID 翻译使用的 AI 配置 ID（0 或空表示按会话模型 / 负责客服默认模型）
+	AppSettingKeyTranslationAIConfigID = "translation_ai_config_id"
 )
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Tag 会话标签
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Color     string    `json:"color" gorm:"type:varchar(20)"` // 前端展示颜色（如 #f56c6c），可选
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConversationTag 会话与标签的多对多关联
+type ConversationTag struct {
+	ConversationID uint      `json:"conversation_id" gorm:"primaryKey;autoIncrement:false"`
+	TagID          uint      `json:"tag_id" gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedBy      uint      `json:"created_by"` // 打标签的客服（0 表示系统）
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CustomFieldDefinition 管理员定义的会话自定义字段
+type CustomFieldDefinition struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	Key       string `json:"key" gorm:"type:varchar(50);uniqueIndex;not null"` // 字段标识（用于筛选 / 排序参数，如 order_no）
+	Label     string `json:"label" gorm:"type:varchar(100);not null"`
+	FieldType string `json:"field_type" gorm:"type:varchar(20);not null"` // text / select / number
+	// Options 下拉选项（JSON 字符串数组，仅 select 类型）
+	Options   string    `json:"options" gorm:"type:text"`
+	SortOrder int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConversationFieldValue 会话的自定义字段取值（每个会话每个字段一条）
+type ConversationFieldValue struct {
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	ConversationID uint   `json:"conversation_id" gorm:"uniqueIndex:idx_conv_field,priority:1"`
+	FieldID        uint   `json:"field_id" gorm:"uniqueIndex:idx_conv_field,priority:2;index"`
+	Value          string `json:"value" gorm:"type:varchar(500)"`
+	// NumberValue number 类型字段的数值（用于范围筛选与排序）
+	NumberValue *float64  `json:"number_value"`
+	UpdatedBy   uint      `json:"updated_by"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ConversationCategory 管理员定义的会话分类（关闭会话时 AI 从中选择一个写入 conversations.category）
+type ConversationCategory struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Description string    `json:"description" gorm:"type:varchar(500)"` // 分类说明，提供给模型帮助判断
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
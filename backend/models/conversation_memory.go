@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ConversationMemory 会话滚动摘要（AI 对话记忆）
+// 对话历史超出 AI 配置的 token 预算时，较早的轮次会被增量压缩进 Summary，之后仅发送摘要 + 最近轮次。
+type ConversationMemory struct {
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	ConversationID uint   `json:"conversation_id" gorm:"uniqueIndex"`
+	Summary        string `json:"summary" gorm:"type:text"`
+	// 已压缩进摘要的最后一条消息 ID（之后的消息按原文发送）
+	SummarizedUntilMessageID uint      `json:"summarized_until_message_id"`
+	CreatedAt                time.Time `json:"created_at"`
+	UpdatedAt                time.Time `json:"updated_at"`
+}
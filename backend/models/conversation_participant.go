@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ConversationParticipant 会话参与客服记录（审计用，只追加 + 标记离开，不删除）。
+// 负责人（owner）同一时刻至多一条有效记录：分配 / 转接时关闭旧记录并新增一条；
+// 协作者（collaborator）由邀请加入，离开时写入 LeftAt。
+type ConversationParticipant struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	ConversationID uint       `json:"conversation_id" gorm:"index:idx_conv_participant,priority:1"`
+	UserID         uint       `json:"user_id" gorm:"index:idx_conv_participant,priority:2"`
+	Role           string     `json:"role" gorm:"type:varchar(20)"`   // owner（负责人）、collaborator（协作者）
+	Action         string     `json:"action" gorm:"type:varchar(20)"` // 加入方式：assign / transfer / invite / claim
+	FromAgentID    uint       `json:"from_agent_id"`                  // 转接前的负责人（0 表示无）
+	OperatorID     uint       `json:"operator_id"`                    // 操作人（0 表示系统自动分配）
+	Note           string     `json:"note" gorm:"type:varchar(500)"`  // 转接 / 邀请备注
+	JoinedAt       time.Time  `json:"joined_at"`
+	LeftAt         *time.Time `json:"left_at"`                             // 为空表示仍在会话中
+	LeftReason     string     `json:"left_reason" gorm:"type:varchar(20)"` // transferred / left
+}
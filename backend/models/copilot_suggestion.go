@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// 客服助手（copilot）草稿状态
+const (
+	CopilotStatusPending    = "pending"    // 待处理（客服可见）
+	CopilotStatusAccepted   = "accepted"   // 原样发送
+	CopilotStatusEdited     = "edited"     // 修改后发送
+	CopilotStatusDismissed  = "dismissed"  // 客服忽略
+	CopilotStatusSuperseded = "superseded" // 访客有新消息，被新一批草稿替代
+)
+
+// CopilotSuggestion 人工会话中 AI 为客服起草的候选回复（仅客服可见），记录采纳 / 编辑情况以衡量效果。
+type CopilotSuggestion struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	ConversationID uint              `json:"conversation_id" gorm:"index:idx_copilot_conv_status,priority:1"`
+	MessageID      uint              `json:"message_id" gorm:"index"` // 触发起草的访客消息
+	Rank           int               `json:"rank"`                    // 同一批草稿内的序号（从 1 开始）
+	Content        string            `json:"content" gorm:"type:text"`
+	Citations      []MessageCitation `json:"citations,omitempty" gorm:"serializer:json;type:text"`
+	Status         string            `json:"status" gorm:"type:varchar(20);default:'pending';index:idx_copilot_conv_status,priority:2"`
+	AIConfigID     *uint             `json:"ai_config_id"`
+	AIModel        string            `json:"ai_model" gorm:"type:varchar(100)"`
+	// 客服处理结果：发送的消息、最终文本及与草稿的相似度（0~1，原样发送为 1）
+	AgentID       *uint      `json:"agent_id"`
+	SentMessageID *uint      `json:"sent_message_id"`
+	FinalContent  string     `json:"final_content,omitempty" gorm:"type:text"`
+	Similarity    float64    `json:"similarity"`
+	ActedAt       *time.Time `json:"acted_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+}
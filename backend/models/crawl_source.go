@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// CrawlSource 网站抓取来源：从起始页面或 sitemap.xml 抓取同站页面导入知识库，可按周期重新抓取
+type CrawlSource struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	KnowledgeBaseID uint       `json:"knowledge_base_id" gorm:"index;not null"`
+	SeedURL         string     `json:"seed_url" gorm:"type:varchar(2048);not null"`   // 起始页面或 sitemap.xml 地址
+	MaxDepth        int        `json:"max_depth" gorm:"default:2"`                    // 跟随链接的层数（0 表示只抓取起始页面 / 站点地图中的页面）
+	MaxPages        int        `json:"max_pages" gorm:"default:50"`                   // 单次最多抓取的页面数
+	DelayMs         int        `json:"delay_ms" gorm:"default:1000"`                  // 相邻请求间隔（毫秒）
+	IntervalHours   int        `json:"interval_hours" gorm:"default:0"`               // 重新抓取周期（小时），0 表示不自动抓取
+	ChunkMethod     string     `json:"chunk_method" gorm:"type:varchar(20)"`          // 导入后自动分段方式，为空时整篇向量化
+	Status          string     `json:"status" gorm:"type:varchar(20);default:'idle'"` // idle / running / failed
+	LastError       string     `json:"last_error" gorm:"type:text"`
+	LastCrawledAt   *time.Time `json:"last_crawled_at"`
+	NextCrawlAt     *time.Time `json:"next_crawl_at" gorm:"index"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// 最近一次抓取的统计
+	PagesFound      int `json:"pages_found"`
+	PagesCreated    int `json:"pages_created"`
+	PagesUpdated    int `json:"pages_updated"`
+	PagesUnchanged  int `json:"pages_unchanged"`
+	PagesFailed     int `json:"pages_failed"`
+	PagesDisallowed int `json:"pages_disallowed"`
+}
+
+// CrawlPage 抓取来源已导入的页面：按规范化 URL 去重，内容哈希不变时不更新文档
+type CrawlPage struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	SourceID    uint      `json:"source_id" gorm:"not null;uniqueIndex:idx_crawl_page_url"`
+	URLHash     string    `json:"-" gorm:"type:char(64);not null;uniqueIndex:idx_crawl_page_url"` // 规范化 URL 的 SHA-256
+	URL         string    `json:"url" gorm:"type:varchar(2048);not null"`
+	DocumentID  uint      `json:"document_id" gorm:"index"`
+	ContentHash string    `json:"content_hash" gorm:"type:char(64)"`
+	CrawledAt   time.Time `json:"crawled_at"` // 最近一次抓取到该页面的时间
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -14,6 +14,19 @@ type Document struct {
 	Type            string    `json:"type" gorm:"type:varchar(50);default:'document'"`            // 文档类型：document, url, file
 	Status          string    `json:"status" gorm:"type:varchar(20);default:'draft'"`             // 状态：draft（草稿）、published（已发布）
 	EmbeddingStatus string    `json:"embedding_status" gorm:"type:varchar(20);default:'pending'"` // 向量化状态：pending（待处理）、processing（处理中）、completed（已完成）、failed（失败）
+	SourceName      string    `json:"source_name" gorm:"type:varchar(512)"`                       // 来源：导入的文件名或网页 URL
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
+
+	// SourceMap 导入解析时记录的内容位置信息（页码、标题路径、锚点），分段时据此为每段标注来源；内容被编辑后清空
+	SourceMap []DocumentSourceSection `json:"-" gorm:"serializer:json;type:mediumtext"`
+}
+
+// DocumentSourceSection 文档内容中一段区间的来源位置（Start/End 为 Content 中的字节偏移，左闭右开）
+type DocumentSourceSection struct {
+	Start       int      `json:"start"`
+	End         int      `json:"end"`
+	PageNumber  int      `json:"page,omitempty"`    // PDF 页码（从 1 开始）
+	HeadingPath []string `json:"heading,omitempty"` // 所属标题路径（DOCX 标题样式 / 网页 h1~h6）
+	Anchor      string   `json:"anchor,omitempty"`  // 来源锚点（网页 URL#片段）
 }
@@ -10,6 +10,9 @@ type DocumentChunk struct {
 	ChunkIndex      int       `gorm:"not null" json:"chunk_index"`
 	Content         string    `gorm:"type:text;not null" json:"content"`
 	EmbeddingStatus string    `gorm:"type:varchar(20);default:'pending'" json:"embedding_status"`
+	PageNumber      int       `gorm:"default:0" json:"page_number"`            // 所在页码（PDF，0 表示未知）
+	HeadingPath     string    `gorm:"type:varchar(1024)" json:"heading_path"`  // 所属标题路径，如「第三章 > 退款」
+	SourceAnchor    string    `gorm:"type:varchar(2048)" json:"source_anchor"` // 来源锚点（网页 URL#片段）
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -15,6 +15,24 @@ type EmbeddingConfig struct {
 	VisitorWebSearchEnabled bool `json:"visitor_web_search_enabled" gorm:"default:false"`
 	// 联网方式：vendor（厂商内置 web_search）/ custom（自建 Serper，后端执行）
 	WebSearchSource string `json:"web_search_source" gorm:"type:varchar(20);default:'custom'"`
+	// AI 回复的知识库检索模式：vector（向量）/ keyword（关键词）/ hybrid（向量+关键词 RRF 融合）
+	RetrievalMode string `json:"retrieval_mode" gorm:"type:varchar(20);default:'vector'"`
+	// 交叉编码器重排序（OpenAI/Jina/BGE 兼容 /rerank 接口）：先召回 RerankCandidates 条候选，重排后保留 Top-K
+	RerankEnabled    bool   `json:"rerank_enabled" gorm:"default:false"`
+	RerankAPIURL     string `json:"rerank_api_url" gorm:"type:varchar(500)"`
+	RerankAPIKey     string `json:"-" gorm:"type:varchar(1000)"` // 加密存储，不返回给前端
+	RerankModel      string `json:"rerank_model" gorm:"type:varchar(100)"`
+	RerankCandidates int    `json:"rerank_candidates" gorm:"default:30"`
+	RerankTimeoutMs  int    `json:"rerank_timeout_ms" gorm:"default:3000"`
+	// FAQ 语义匹配：相似度 ≥ FAQMatchThreshold 的 FAQ 直接返回标准答案（开启 FAQVerifyEnabled 时先由模型确认），
+	// 介于 FAQContextThreshold 与 FAQMatchThreshold 之间的作为参考资料交给模型生成回答
+	FAQMatchThreshold   float64 `json:"faq_match_threshold" gorm:"default:0.85"`
+	FAQContextThreshold float64 `json:"faq_context_threshold" gorm:"default:0.5"`
+	FAQVerifyEnabled    bool    `json:"faq_verify_enabled" gorm:"default:false"`
+	// 检索前的查询改写：结合最近对话将追问改写为独立的检索问题（可拆出多个子问题），各查询的检索结果融合
+	QueryRewriteEnabled    bool `json:"query_rewrite_enabled" gorm:"default:false"`
+	QueryRewriteAIConfigID uint `json:"query_rewrite_ai_config_id"`                 // 改写使用的 AI 配置，0 表示使用会话所选模型
+	QueryRewriteMaxQueries int  `json:"query_rewrite_max_queries" gorm:"default:3"` // 改写后最多检索的查询数（含主查询）
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
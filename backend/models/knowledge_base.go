@@ -14,3 +14,15 @@ type KnowledgeBase struct {
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
+
+// KnowledgeBaseBinding 挂件 / 站点与知识库集合的绑定（多产品线时限定访客会话的 RAG 范围）
+type KnowledgeBaseBinding struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	Name             string    `json:"name" gorm:"type:varchar(100)"`
+	WidgetKey        string    `json:"widget_key" gorm:"type:varchar(64);index"`    // 挂件 key（嵌入代码中传入），优先于站点匹配
+	SiteHost         string    `json:"site_host" gorm:"type:varchar(255);index"`    // 站点域名，如 shop.example.com；以 "." 开头时匹配其所有子域名
+	KnowledgeBaseIDs string    `json:"knowledge_base_ids" gorm:"type:varchar(500)"` // 逗号分隔的知识库 ID
+	Enabled          bool      `json:"enabled" gorm:"default:true"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
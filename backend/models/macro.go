@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// MacroFolder 快捷回复（宏）分组
+type MacroFolder struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
+	Scope     string    `json:"scope" gorm:"type:varchar(20);default:'personal';index"` // personal（个人）、team（团队共享）
+	OwnerID   uint      `json:"owner_id" gorm:"index"`                                  // 创建者
+	SortOrder int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Macro 快捷回复（宏）：支持 {{visitor.email}} 等变量，发送时由服务端按会话解析，
+// 可选附带动作（如关闭会话、添加标签）。
+type Macro struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Title    string `json:"title" gorm:"type:varchar(200);not null"`
+	Shortcut string `json:"shortcut" gorm:"type:varchar(50);index"` // 输入框快捷指令（如 "refund"，输入 /refund 触发）
+	Content  string `json:"content" gorm:"type:text;not null"`      // 模板内容
+	FolderID *uint  `json:"folder_id" gorm:"index"`
+	Scope    string `json:"scope" gorm:"type:varchar(20);default:'personal';index"` // personal（个人）、team（团队共享）
+	OwnerID  uint   `json:"owner_id" gorm:"index"`                                  // 创建者
+	// 发送后执行的动作（JSON 数组），如 [{"type":"close"},{"type":"add_tag","value":"vip"}]
+	Actions    string    `json:"actions" gorm:"type:text"`
+	UsageCount int       `json:"usage_count" gorm:"default:0"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
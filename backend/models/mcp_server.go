@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// MCPServer 管理员注册的 MCP 服务：后端连接后列出其工具，选中的工具提供给模型 function calling
+type MCPServer struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Name         string    `json:"name" gorm:"type:varchar(32);uniqueIndex"` // 标识，同时作为暴露给模型的工具名前缀（mcp_{name}_{tool}）
+	Description  string    `json:"description" gorm:"type:varchar(500)"`
+	Transport    string    `json:"transport" gorm:"type:varchar(20);default:'http'"` // http（streamable HTTP）/ stdio（本地命令）
+	URL          string    `json:"url" gorm:"type:varchar(500)"`                     // http 传输的服务地址
+	AuthHeader   string    `json:"auth_header" gorm:"type:varchar(100)"`             // http 传输的认证头名称
+	AuthValue    string    `json:"-" gorm:"type:varchar(1000)"`                      // 认证头取值（加密存储，不返回前端）
+	Command      string    `json:"command" gorm:"type:varchar(500)"`                 // stdio 传输的可执行文件
+	Args         string    `json:"-" gorm:"type:text"`                               // stdio 命令参数（JSON 数组）
+	Env          string    `json:"-" gorm:"type:text"`                               // stdio 追加环境变量（JSON 对象，加密存储）
+	EnabledTools string    `json:"-" gorm:"type:text"`                               // 提供给模型的工具名（逗号分隔），为空则不提供任何工具
+	IsActive     bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -17,6 +17,9 @@ type User struct {
 	Email     string `json:"email" gorm:"type:varchar(255)"`      // 邮箱
 	// AI 对话接收设置
 	ReceiveAIConversations bool      `json:"receive_ai_conversations" gorm:"default:true"` // 是否接收 AI 对话（默认接收）
+	// 自动分配：技能标签（逗号分隔，如 "billing,english"）与同时接待的会话上限（0 表示不限）
+	Skills                     string `json:"skills" gorm:"type:varchar(500)"`
+	MaxConcurrentConversations int    `json:"max_concurrent_conversations" gorm:"default:0"`
 	CreatedAt              time.Time `json:"created_at"`                                   // 创建时间
 	UpdatedAt              time.Time `json:"updated_at"`                                   // 更新时间
 }
@@ -46,17 +49,37 @@ type Conversation struct {
 	// AI 客服相关
 	ChatMode   string `json:"chat_mode" gorm:"type:varchar(20);default:'human';index:idx_conv_list,priority:3"` // 对话模式：human（人工客服）、ai（AI客服）
 	AIConfigID *uint  `json:"ai_config_id"`                                      // AI 配置 ID（访客选择的模型配置）
+	// 知识库范围：逗号分隔的知识库 ID，为空表示全部参与 RAG 的知识库（init 时按显式参数 / 挂件 key / 站点绑定解析）
+	KnowledgeBaseIDs string `json:"knowledge_base_ids" gorm:"type:varchar(500)"`
+	WidgetKey        string `json:"widget_key" gorm:"type:varchar(64)"` // 访客所用挂件 key（可选）
+	// 优先级：0 低、1 普通（默认）、2 高、3 紧急
+	Priority int `json:"priority" gorm:"default:1;index"`
+	// 自动分配所需技能（逗号分隔，init 时由挂件传入），skill 策略下优先匹配具备这些技能的客服
+	RoutingSkills string `json:"routing_skills" gorm:"type:varchar(255)"`
+	// AI 转人工：转接时间与触发原因（keyword / ai_intent / ai_failures）；转接后 agent_id 为 0 时视为排队中
+	HandoffAt     *time.Time `json:"handoff_at" gorm:"index"`
+	HandoffReason string     `json:"handoff_reason" gorm:"type:varchar(30)"`
+	// 关闭后由 AI 生成的会话小结：摘要、分类（取自管理员定义的分类）、解决状态与关键实体（订单号、产品等）
+	Summary       string     `json:"summary" gorm:"type:text"`
+	Category      string     `json:"category" gorm:"type:varchar(64);index"`
+	Resolution    string     `json:"resolution" gorm:"type:varchar(20);index"` // resolved / unresolved / follow_up
+	KeyEntities   []string   `json:"key_entities" gorm:"serializer:json;type:text"`
+	SummaryStatus string     `json:"summary_status" gorm:"type:varchar(20)"` // pending / done / failed / skipped
+	SummarizedAt  *time.Time `json:"summarized_at"`
 	// AccessToken 访客访问会话/消息的密钥；仅 init 时下发给对应访客，不在客服 API 中返回。
 	AccessToken string `json:"-" gorm:"type:varchar(64);index"`
 }
 
+// MessageTypeWhisper 客服内部备注（whisper）：仅客服可见，ListMessages 与 WebSocket 均不会下发给访客
+const MessageTypeWhisper = "whisper"
+
 type Message struct {
 	ID             uint       `json:"id" gorm:"primarykey"`
 	ConversationID uint       `json:"conversation_id" gorm:"index:idx_msg_conv;index:idx_msg_conv_unread,priority:1;index:idx_msg_conv_sender,priority:1"`
 	SenderID       uint       `json:"sender_id" gorm:"index:idx_msg_conv_sender,priority:3"`
 	SenderIsAgent  bool       `json:"sender_is_agent" gorm:"index:idx_msg_conv_unread,priority:2;index:idx_msg_conv_sender,priority:2"`
 	Content        string     `json:"content" gorm:"type:text"`
-	MessageType    string     `json:"message_type" gorm:"type:varchar(20);default:'user_message'"` // 消息类型：user_message, system_message
+	MessageType    string     `json:"message_type" gorm:"type:varchar(20);default:'user_message'"` // 消息类型：user_message, system_message, whisper（客服内部备注，访客不可见）
 	ChatMode       string     `json:"chat_mode" gorm:"type:varchar(20);default:'human'"`           // 消息发送时的对话模式：human（人工客服）、ai（AI客服）
 	IsRead         bool       `json:"is_read" gorm:"index:idx_msg_conv_unread,priority:3"`
 	ReadAt         *time.Time `json:"read_at"`
@@ -71,4 +94,34 @@ type Message struct {
 	SourcesUsed string `json:"sources_used" gorm:"type:varchar(100)"`
 	// IsAIGenerationFailed 为 true 表示本次 AI 消息为生成失败后的兜底文案（用于统计失败率）
 	IsAIGenerationFailed bool `json:"is_ai_generation_failed" gorm:"default:false"`
+	// AI 回复实际使用的配置与模型（故障转移时可能与会话选择的配置不同）。
+	// 消息会下发给访客，内部路由与费用信息不序列化，仅通过用量统计接口查看
+	AIConfigID *uint  `json:"-"`
+	AIModel    string `json:"-" gorm:"type:varchar(100)"`
+	// 生成本条 AI 回复的 token 用量与费用（含工具调用轮次、摘要等同次生成内的全部调用）
+	PromptTokens     int     `json:"-"`
+	CompletionTokens int     `json:"-"`
+	Cost             float64 `json:"-"`
+	// AI 回复引用的来源（知识库文档 / 分段、FAQ、联网结果），编号与回复中的 [n] 对应
+	Citations []MessageCitation `json:"citations,omitempty" gorm:"serializer:json;type:text"`
+	// 实时翻译：Content 保留发送方原文，TranslatedContent 为译文（访客消息译为客服语言，客服回复译为访客语言）
+	SourceLanguage     string `json:"source_language,omitempty" gorm:"type:varchar(20)"`     // 原文语言（为空表示未识别）
+	TranslatedContent  string `json:"translated_content,omitempty" gorm:"type:text"`         // 译文，未翻译时为空
+	TranslatedLanguage string `json:"translated_language,omitempty" gorm:"type:varchar(20)"` // 译文语言
+}
+
+// MessageCitation AI 回复的一条引用来源
+type MessageCitation struct {
+	Index           int     `json:"index"`                       // 引用编号，对应回复中的 [n]
+	SourceType      string  `json:"source_type"`                 // knowledge_base / faq / web
+	DocumentID      uint    `json:"document_id,omitempty"`       // 知识库文档 ID（faq 时为 FAQ ID）
+	KnowledgeBaseID uint    `json:"knowledge_base_id,omitempty"` // 所属知识库
+	ChunkID         uint    `json:"chunk_id,omitempty"`          // 命中的分段 ID（整篇文档向量为 0）
+	Title           string  `json:"title"`                       // 文档标题 / FAQ 问题 / 网页标题
+	Score           float32 `json:"score,omitempty"`             // 检索相关度
+	URL             string  `json:"url,omitempty"`               // 联网结果链接 / 网页文档的章节锚点
+	Snippet         string  `json:"snippet,omitempty"`           // 片段摘要
+	SourceName      string  `json:"source_name,omitempty"`       // 来源文件名或网址（如 manual.pdf）
+	PageNumber      int     `json:"page_number,omitempty"`       // 分段所在页码（PDF）
+	HeadingPath     string  `json:"heading_path,omitempty"`      // 分段所属标题路径（如「第三章 > 退款」）
 }
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// AIModelPriceRepository 封装模型价格表的数据库操作。
+type AIModelPriceRepository struct {
+	db *gorm.DB
+}
+
+// NewAIModelPriceRepository 创建价格表仓库实例。
+func NewAIModelPriceRepository(db *gorm.DB) *AIModelPriceRepository {
+	return &AIModelPriceRepository{db: db}
+}
+
+// List 按模型名返回全部价格。
+func (r *AIModelPriceRepository) List() ([]models.AIModelPrice, error) {
+	var prices []models.AIModelPrice
+	if err := r.db.Order("model ASC").Find(&prices).Error; err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// GetByID 根据主键查询价格。
+func (r *AIModelPriceRepository) GetByID(id uint) (*models.AIModelPrice, error) {
+	var price models.AIModelPrice
+	if err := r.db.First(&price, id).Error; err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// GetByModel 根据模型名查询价格。
+func (r *AIModelPriceRepository) GetByModel(model string) (*models.AIModelPrice, error) {
+	var price models.AIModelPrice
+	if err := r.db.Where("model = ?", model).First(&price).Error; err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// Create 新增价格。
+func (r *AIModelPriceRepository) Create(price *models.AIModelPrice) error {
+	return r.db.Create(price).Error
+}
+
+// Update 保存价格修改。
+func (r *AIModelPriceRepository) Update(price *models.AIModelPrice) error {
+	return r.db.Save(price).Error
+}
+
+// Delete 删除价格。
+func (r *AIModelPriceRepository) Delete(id uint) error {
+	return r.db.Delete(&models.AIModelPrice{}, id).Error
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// AIToolRepository 封装 HTTP 工具定义的数据库操作。
+type AIToolRepository struct {
+	db *gorm.DB
+}
+
+// NewAIToolRepository 创建工具仓库实例。
+func NewAIToolRepository(db *gorm.DB) *AIToolRepository {
+	return &AIToolRepository{db: db}
+}
+
+// List 按名称返回全部工具。
+func (r *AIToolRepository) List() ([]models.AITool, error) {
+	var tools []models.AITool
+	if err := r.db.Order("name ASC").Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	return tools, nil
+}
+
+// ListActiveByIDs 返回指定 ID 中已启用的工具。
+func (r *AIToolRepository) ListActiveByIDs(ids []uint) ([]models.AITool, error) {
+	var tools []models.AITool
+	if len(ids) == 0 {
+		return tools, nil
+	}
+	if err := r.db.Where("id IN ? AND is_active = ?", ids, true).Order("name ASC").Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	return tools, nil
+}
+
+// GetByID 根据主键查询工具。
+func (r *AIToolRepository) GetByID(id uint) (*models.AITool, error) {
+	var tool models.AITool
+	if err := r.db.First(&tool, id).Error; err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+// GetByName 根据函数名查询工具。
+func (r *AIToolRepository) GetByName(name string) (*models.AITool, error) {
+	var tool models.AITool
+	if err := r.db.Where("name = ?", name).First(&tool).Error; err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+// Create 新增工具。
+func (r *AIToolRepository) Create(tool *models.AITool) error {
+	return r.db.Create(tool).Error
+}
+
+// Update 保存工具修改。
+func (r *AIToolRepository) Update(tool *models.AITool) error {
+	return r.db.Save(tool).Error
+}
+
+// Delete 删除工具。
+func (r *AIToolRepository) Delete(id uint) error {
+	return r.db.Delete(&models.AITool{}, id).Error
+}
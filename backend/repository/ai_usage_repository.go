@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// AIUsageRepository 封装模型调用用量记录的数据库操作。
+type AIUsageRepository struct {
+	db *gorm.DB
+}
+
+// NewAIUsageRepository 创建用量记录仓库实例。
+func NewAIUsageRepository(db *gorm.DB) *AIUsageRepository {
+	return &AIUsageRepository{db: db}
+}
+
+// Create 写入一条用量记录。
+func (r *AIUsageRepository) Create(record *models.AIUsageRecord) error {
+	return r.db.Create(record).Error
+}
+
+// AttachMessage 将用量记录关联到生成的 AI 回复消息。
+func (r *AIUsageRepository) AttachMessage(ids []uint, messageID uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.AIUsageRecord{}).Where("id IN ?", ids).Update("message_id", messageID).Error
+}
+
+// SumCostByConfigSince 统计某 AI 配置自 since 起的累计费用。
+func (r *AIUsageRepository) SumCostByConfigSince(configID uint, since time.Time) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.AIUsageRecord{}).
+		Select("COALESCE(SUM(cost), 0)").
+		Where("ai_config_id = ? AND created_at >= ?", configID, since).
+		Scan(&total).Error
+	return total, err
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// ConversationCategoryRepository 封装会话分类的数据库操作。
+type ConversationCategoryRepository struct {
+	db *gorm.DB
+}
+
+// NewConversationCategoryRepository 创建会话分类仓库实例。
+func NewConversationCategoryRepository(db *gorm.DB) *ConversationCategoryRepository {
+	return &ConversationCategoryRepository{db: db}
+}
+
+// List 列出全部分类（按排序值、ID 排序）。
+func (r *ConversationCategoryRepository) List() ([]models.ConversationCategory, error) {
+	var categories []models.ConversationCategory
+	if err := r.db.Order("sort_order ASC").Order("id ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// Create 新建分类。
+func (r *ConversationCategoryRepository) Create(category *models.ConversationCategory) error {
+	return r.db.Create(category).Error
+}
+
+// GetByID 根据 ID 查询分类。
+func (r *ConversationCategoryRepository) GetByID(id uint) (*models.ConversationCategory, error) {
+	var category models.ConversationCategory
+	if err := r.db.Where("id = ?", id).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetByName 根据名称查询分类。
+func (r *ConversationCategoryRepository) GetByName(name string) (*models.ConversationCategory, error) {
+	var category models.ConversationCategory
+	if err := r.db.Where("name = ?", name).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Update 保存分类。
+func (r *ConversationCategoryRepository) Update(category *models.ConversationCategory) error {
+	return r.db.Save(category).Error
+}
+
+// Delete 删除分类（已归入该分类的会话保留原分类名称）。
+func (r *ConversationCategoryRepository) Delete(id uint) error {
+	return r.db.Delete(&models.ConversationCategory{}, id).Error
+}
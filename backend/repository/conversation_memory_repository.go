@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// ConversationMemoryRepository 封装会话滚动摘要的数据库操作
+type ConversationMemoryRepository struct {
+	db *gorm.DB
+}
+
+// NewConversationMemoryRepository 创建会话摘要仓库实例
+func NewConversationMemoryRepository(db *gorm.DB) *ConversationMemoryRepository {
+	return &ConversationMemoryRepository{db: db}
+}
+
+// GetByConversationID 获取会话摘要，不存在时返回 nil, nil
+func (r *ConversationMemoryRepository) GetByConversationID(conversationID uint) (*models.ConversationMemory, error) {
+	var m models.ConversationMemory
+	err := r.db.Where("conversation_id = ?", conversationID).First(&m).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Save 保存会话摘要（ID 为 0 时插入，否则更新）
+func (r *ConversationMemoryRepository) Save(m *models.ConversationMemory) error {
+	return r.db.Save(m).Error
+}
+
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// ConversationParticipantRepository 封装会话参与客服记录的数据库操作
+type ConversationParticipantRepository struct {
+	db *gorm.DB
+}
+
+// NewConversationParticipantRepository 创建会话参与记录仓库实例
+func NewConversationParticipantRepository(db *gorm.DB) *ConversationParticipantRepository {
+	return &ConversationParticipantRepository{db: db}
+}
+
+// Create 新增一条参与记录
+func (r *ConversationParticipantRepository) Create(p *models.ConversationParticipant) error {
+	return r.db.Create(p).Error
+}
+
+// ListByConversationID 按时间顺序返回会话的全部参与记录（含已离开）
+func (r *ConversationParticipantRepository) ListByConversationID(conversationID uint) ([]models.ConversationParticipant, error) {
+	var list []models.ConversationParticipant
+	if err := r.db.Where("conversation_id = ?", conversationID).Order("joined_at asc, id asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListActive 返回会话当前仍在参与的记录
+func (r *ConversationParticipantRepository) ListActive(conversationID uint) ([]models.ConversationParticipant, error) {
+	var list []models.ConversationParticipant
+	if err := r.db.Where("conversation_id = ? AND left_at IS NULL", conversationID).Order("joined_at asc, id asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// IsActive 用户当前是否以指定角色参与会话（role 为空表示任意角色）
+func (r *ConversationParticipantRepository) IsActive(conversationID, userID uint, role string) (bool, error) {
+	q := r.db.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND left_at IS NULL", conversationID, userID)
+	if role != "" {
+		q = q.Where("role = ?", role)
+	}
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// MarkLeft 将会话中满足条件的有效记录标记为离开（userID 为 0 表示不限用户）
+func (r *ConversationParticipantRepository) MarkLeft(conversationID, userID uint, role string, reason string, at time.Time) error {
+	q := r.db.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND left_at IS NULL AND role = ?", conversationID, role)
+	if userID > 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	return q.Updates(map[string]interface{}{
+		"left_at":     at,
+		"left_reason": reason,
+	}).Error
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/2930134478/AI-CS/backend/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ConversationRepository 封装与会话相关的数据库操作。
@@ -129,6 +130,29 @@ func (r *ConversationRepository) ListByIDs(ids []uint) ([]models.Conversation, e
 	return conversations, nil
 }
 
+// ListByIDsFiltered 按 ID 批量查询会话，并附加标签 / 优先级 / 自定义字段筛选与排序。
+// status 为 closed 时仅返回已关闭会话，all 时不限状态，其余情况与 ListByIDs 相同（排除已关闭会话）。
+func (r *ConversationRepository) ListByIDsFiltered(ids []uint, status string, filter ConversationQueryFilter) ([]models.Conversation, error) {
+	if len(ids) == 0 {
+		return []models.Conversation{}, nil
+	}
+	q := r.db.Model(&models.Conversation{}).Where("conversations.id IN ?", ids)
+	switch status {
+	case "closed":
+		q = q.Where("conversations.status = ?", "closed")
+	case "all":
+	default:
+		q = q.Where("conversations.status != ?", "closed")
+	}
+	q = applyQueryOrder(applyQueryFilter(q, filter), filter)
+
+	var conversations []models.Conversation
+	if err := q.Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
 // SearchByIDOrVisitorLike 根据会话 ID 或访客 ID 进行模糊搜索。
 func (r *ConversationRepository) SearchByIDOrVisitorLike(pattern string) ([]models.Conversation, error) {
 	var conversations []models.Conversation
@@ -139,29 +163,158 @@ func (r *ConversationRepository) SearchByIDOrVisitorLike(pattern string) ([]mode
 	return conversations, nil
 }
 
-// CloseStaleOpenVisitorConversations 关闭长期未更新的 open 访客会话。
-func (r *ConversationRepository) CloseStaleOpenVisitorConversations(olderThan time.Time) (int64, error) {
-	result := r.db.Model(&models.Conversation{}).
-		Where("conversation_type = ? AND status = ? AND updated_at < ?", "visitor", "open", olderThan).
-		Update("status", "closed")
-	return result.RowsAffected, result.Error
+// CloseStaleOpenVisitorConversations 关闭长期未更新的 open 访客会话，返回实际被关闭的会话 ID。
+// 在事务中加行锁（SELECT ... FOR UPDATE）再更新，查询与更新之间被重新激活的会话不会被关闭或返回。
+func (r *ConversationRepository) CloseStaleOpenVisitorConversations(olderThan time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Conversation{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("conversation_type = ? AND status = ? AND updated_at < ?", "visitor", "open", olderThan).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&models.Conversation{}).
+			Where("id IN ? AND status = ?", ids, "open").
+			Update("status", "closed").Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// UpdateSummary 写入会话小结相关字段（不更新 updated_at，避免影响列表排序与自动关闭判断）。
+func (r *ConversationRepository) UpdateSummary(conv *models.Conversation) error {
+	return r.db.Model(&models.Conversation{ID: conv.ID}).
+		Select("summary", "category", "resolution", "key_entities", "summary_status", "summarized_at").
+		UpdateColumns(conv).Error
+}
+
+// UpdateSummaryStatus 仅更新小结状态（不更新 updated_at）。
+func (r *ConversationRepository) UpdateSummaryStatus(id uint, status string) error {
+	return r.db.Model(&models.Conversation{}).Where("id = ?", id).UpdateColumn("summary_status", status).Error
+}
+
+// FindIDsBySummaryLike 按会话小结、分类与关键实体模糊匹配会话 ID。
+func (r *ConversationRepository) FindIDsBySummaryLike(pattern string) ([]uint, error) {
+	var ids []uint
+	if err := r.db.Model(&models.Conversation{}).
+		Where("summary LIKE ? OR category LIKE ? OR key_entities LIKE ?", pattern, pattern, pattern).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ConversationQueryFilter 会话列表 / 搜索的附加筛选与排序条件（自定义字段已由服务层解析为字段 ID）。
+// 零值表示不附加筛选、按更新时间倒序。
+type ConversationQueryFilter struct {
+	TagIDs      []uint   // 需同时具备的标签
+	Priorities  []int    // 优先级（命中任一）
+	Categories  []string // 会话小结分类（命中任一）
+	Resolutions []string // 解决状态（命中任一）
+	Fields      []FieldValueCondition
+	SortBy      string // updated_at（默认）/ created_at / priority / field
+	SortFieldID uint   // SortBy=field 时排序的自定义字段
+	SortNumeric bool   // 自定义字段按数值排序（number 类型）
+	SortAsc     bool
+}
+
+// FieldValueCondition 单个自定义字段的筛选条件。
+type FieldValueCondition struct {
+	FieldID uint
+	Op      string // eq / contains / gt / gte / lt / lte
+	Value   string
+	Number  float64
+	Numeric bool // 按 number_value 比较
+}
+
+var fieldConditionOperators = map[string]string{
+	"eq":  "=",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// applyQueryFilter 追加标签、优先级与自定义字段筛选条件
+func applyQueryFilter(q *gorm.DB, filter ConversationQueryFilter) *gorm.DB {
+	for _, tagID := range filter.TagIDs {
+		q = q.Where("EXISTS (SELECT 1 FROM conversation_tags WHERE conversation_tags.conversation_id = conversations.id AND conversation_tags.tag_id = ?)", tagID)
+	}
+	if len(filter.Priorities) > 0 {
+		q = q.Where("conversations.priority IN ?", filter.Priorities)
+	}
+	if len(filter.Categories) > 0 {
+		q = q.Where("conversations.category IN ?", filter.Categories)
+	}
+	if len(filter.Resolutions) > 0 {
+		q = q.Where("conversations.resolution IN ?", filter.Resolutions)
+	}
+	for _, cond := range filter.Fields {
+		const sub = "EXISTS (SELECT 1 FROM conversation_field_values v WHERE v.conversation_id = conversations.id AND v.field_id = ? AND "
+		switch {
+		case cond.Op == "contains":
+			q = q.Where(sub+"v.value LIKE ?)", cond.FieldID, "%"+cond.Value+"%")
+		case cond.Numeric:
+			op, ok := fieldConditionOperators[cond.Op]
+			if !ok {
+				op = "="
+			}
+			q = q.Where(sub+"v.number_value "+op+" ?)", cond.FieldID, cond.Number)
+		default:
+			q = q.Where(sub+"v.value = ?)", cond.FieldID, cond.Value)
+		}
+	}
+	return q
+}
+
+// applyQueryOrder 按筛选条件追加排序（自定义字段排序时 LEFT JOIN 取值表，未填写的会话排在最后）
+func applyQueryOrder(q *gorm.DB, filter ConversationQueryFilter) *gorm.DB {
+	dir := "DESC"
+	if filter.SortAsc {
+		dir = "ASC"
+	}
+	switch filter.SortBy {
+	case "created_at":
+		return q.Order("conversations.created_at " + dir)
+	case "priority":
+		return q.Order("conversations.priority " + dir).Order("conversations.updated_at DESC")
+	case "field":
+		column := "sort_values.value"
+		if filter.SortNumeric {
+			column = "sort_values.number_value"
+		}
+		return q.Select("conversations.*").
+			Joins("LEFT JOIN conversation_field_values sort_values ON sort_values.conversation_id = conversations.id AND sort_values.field_id = ?", filter.SortFieldID).
+			Order(column + " IS NULL").
+			Order(column + " " + dir).
+			Order("conversations.updated_at DESC")
+	default:
+		return q.Order("conversations.updated_at " + dir)
+	}
 }
 
 // ListVisitorForAgentList 分页查询客服列表可见的访客会话（排除 AI 模式，且需有访客侧消息）。
-func (r *ConversationRepository) ListVisitorForAgentList(status string, offset, limit int) ([]models.Conversation, int64, error) {
+func (r *ConversationRepository) ListVisitorForAgentList(status string, filter ConversationQueryFilter, offset, limit int) ([]models.Conversation, int64, error) {
 	q := r.db.Model(&models.Conversation{}).
-		Where("conversation_type = ? AND chat_mode != ?", "visitor", "ai").
+		Where("conversations.conversation_type = ? AND conversations.chat_mode != ?", "visitor", "ai").
 		Where("EXISTS (SELECT 1 FROM messages WHERE messages.conversation_id = conversations.id AND messages.sender_is_agent = ?)", false)
 
 	switch status {
 	case "open":
-		q = q.Where("status = ?", "open")
+		q = q.Where("conversations.status = ?", "open")
 	case "closed":
-		q = q.Where("status = ?", "closed")
+		q = q.Where("conversations.status = ?", "closed")
 	case "", "all":
 	default:
 		return nil, 0, errors.New("invalid status")
 	}
+	q = applyQueryFilter(q, filter)
 
 	var total int64
 	if err := q.Count(&total).Error; err != nil {
@@ -169,7 +322,7 @@ func (r *ConversationRepository) ListVisitorForAgentList(status string, offset,
 	}
 
 	var conversations []models.Conversation
-	if err := q.Order("updated_at desc").Offset(offset).Limit(limit).Find(&conversations).Error; err != nil {
+	if err := applyQueryOrder(q, filter).Offset(offset).Limit(limit).Find(&conversations).Error; err != nil {
 		return nil, 0, err
 	}
 	return conversations, total, nil
@@ -190,6 +343,63 @@ func (r *ConversationRepository) AssignAgent(conversationID uint, agentID uint)
 	return nil
 }
 
+// ListHandoffQueue 返回 AI 转人工后仍在排队（未分配客服、未关闭）的访客会话，按转接时间先后排序。
+func (r *ConversationRepository) ListHandoffQueue() ([]models.Conversation, error) {
+	var conversations []models.Conversation
+	if err := r.db.Where("conversation_type = ? AND status != ? AND chat_mode = ? AND agent_id = ? AND handoff_at IS NOT NULL",
+		"visitor", "closed", "human", 0).
+		Order("handoff_at asc").
+		Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// AssignAgentIfUnassigned 仅在会话尚未分配客服时写入 agent_id，返回是否分配成功（避免并发重复分配）。
+func (r *ConversationRepository) AssignAgentIfUnassigned(conversationID uint, agentID uint) (bool, error) {
+	result := r.db.Model(&models.Conversation{}).
+		Where("id = ? AND agent_id = ?", conversationID, 0).
+		Update("agent_id", agentID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateFieldsIfChatMode 仅在会话当前 chat_mode 为 chatMode 时更新字段，返回是否更新成功（避免并发重复切换模式）。
+func (r *ConversationRepository) UpdateFieldsIfChatMode(id uint, chatMode string, values map[string]interface{}) (bool, error) {
+	result := r.db.Model(&models.Conversation{}).
+		Where("id = ? AND chat_mode = ?", id, chatMode).
+		Updates(values)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountOpenVisitorByAgentIDs 统计各客服当前接待中的（未关闭）访客会话数。
+func (r *ConversationRepository) CountOpenVisitorByAgentIDs(agentIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(agentIDs))
+	if len(agentIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		AgentID uint
+		Total   int64
+	}
+	if err := r.db.Model(&models.Conversation{}).
+		Select("agent_id, COUNT(*) AS total").
+		Where("conversation_type = ? AND status != ? AND agent_id IN ?", "visitor", "closed", agentIDs).
+		Group("agent_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.AgentID] = row.Total
+	}
+	return counts, nil
+}
+
 // UpdateStatus 更新会话状态。
 func (r *ConversationRepository) UpdateStatus(conversationID uint, status string) error {
 	if status == "" {
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// CopilotSuggestionRepository 封装客服助手草稿的数据库操作。
+type CopilotSuggestionRepository struct {
+	db *gorm.DB
+}
+
+// NewCopilotSuggestionRepository 创建草稿仓库实例。
+func NewCopilotSuggestionRepository(db *gorm.DB) *CopilotSuggestionRepository {
+	return &CopilotSuggestionRepository{db: db}
+}
+
+// ReplacePending 将会话中待处理的草稿标记为已替代，并写入新一批草稿（同一事务）。
+func (r *CopilotSuggestionRepository) ReplacePending(conversationID uint, suggestions []models.CopilotSuggestion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.CopilotSuggestion{}).
+			Where("conversation_id = ? AND status = ?", conversationID, models.CopilotStatusPending).
+			Update("status", models.CopilotStatusSuperseded).Error; err != nil {
+			return err
+		}
+		if len(suggestions) == 0 {
+			return nil
+		}
+		return tx.Create(&suggestions).Error
+	})
+}
+
+// ListPendingByConversation 返回会话中待处理的草稿（按序号）。
+func (r *CopilotSuggestionRepository) ListPendingByConversation(conversationID uint) ([]models.CopilotSuggestion, error) {
+	var list []models.CopilotSuggestion
+	if err := r.db.Where("conversation_id = ? AND status = ?", conversationID, models.CopilotStatusPending).
+		Order("id ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetByID 根据主键查询草稿。
+func (r *CopilotSuggestionRepository) GetByID(id uint) (*models.CopilotSuggestion, error) {
+	var s models.CopilotSuggestion
+	if err := r.db.First(&s, id).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Update 保存草稿修改。
+func (r *CopilotSuggestionRepository) Update(s *models.CopilotSuggestion) error {
+	return r.db.Save(s).Error
+}
+
+// SupersedeOthers 同一批草稿中采纳其一后，其余待处理草稿标记为已替代。
+func (r *CopilotSuggestionRepository) SupersedeOthers(conversationID uint, messageID uint, keepID uint) error {
+	return r.db.Model(&models.CopilotSuggestion{}).
+		Where("conversation_id = ? AND message_id = ? AND id <> ? AND status = ?", conversationID, messageID, keepID, models.CopilotStatusPending).
+		Update("status", models.CopilotStatusSuperseded).Error
+}
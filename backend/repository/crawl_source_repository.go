@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// CrawlSourceRepository 封装网站抓取来源及其已导入页面的数据库操作。
+type CrawlSourceRepository struct {
+	db *gorm.DB
+}
+
+// NewCrawlSourceRepository 创建网站抓取来源仓库实例。
+func NewCrawlSourceRepository(db *gorm.DB) *CrawlSourceRepository {
+	return &CrawlSourceRepository{db: db}
+}
+
+// Create 新建抓取来源。
+func (r *CrawlSourceRepository) Create(source *models.CrawlSource) error {
+	return r.db.Create(source).Error
+}
+
+// GetByID 根据 ID 查询抓取来源。
+func (r *CrawlSourceRepository) GetByID(id uint) (*models.CrawlSource, error) {
+	var source models.CrawlSource
+	if err := r.db.Where("id = ?", id).First(&source).Error; err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// Update 保存抓取来源。
+func (r *CrawlSourceRepository) Update(source *models.CrawlSource) error {
+	return r.db.Save(source).Error
+}
+
+// Delete 删除抓取来源及其页面记录（已导入的文档保留）。
+func (r *CrawlSourceRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("source_id = ?", id).Delete(&models.CrawlPage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.CrawlSource{}, id).Error
+	})
+}
+
+// List 列出抓取来源；knowledgeBaseID 为 0 时返回全部。
+func (r *CrawlSourceRepository) List(knowledgeBaseID uint) ([]models.CrawlSource, error) {
+	var sources []models.CrawlSource
+	query := r.db.Model(&models.CrawlSource{})
+	if knowledgeBaseID > 0 {
+		query = query.Where("knowledge_base_id = ?", knowledgeBaseID)
+	}
+	if err := query.Order("id DESC").Find(&sources).Error; err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// ListDue 列出到期需要重新抓取的来源（未在抓取中）。
+func (r *CrawlSourceRepository) ListDue(now time.Time, limit int) ([]models.CrawlSource, error) {
+	var sources []models.CrawlSource
+	query := r.db.Where("interval_hours > 0 AND next_crawl_at IS NOT NULL AND next_crawl_at <= ? AND status <> ?", now, "running").
+		Order("next_crawl_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&sources).Error; err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// ResetRunning 将残留的 running 状态（服务重启导致抓取中断）恢复为 idle。
+func (r *CrawlSourceRepository) ResetRunning() error {
+	return r.db.Model(&models.CrawlSource{}).Where("status = ?", "running").Update("status", "idle").Error
+}
+
+// ListPages 列出抓取来源已导入的页面。
+func (r *CrawlSourceRepository) ListPages(sourceID uint) ([]models.CrawlPage, error) {
+	var pages []models.CrawlPage
+	if err := r.db.Where("source_id = ?", sourceID).Order("id ASC").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// SavePage 新建或保存页面记录。
+func (r *CrawlSourceRepository) SavePage(page *models.CrawlPage) error {
+	return r.db.Save(page).Error
+}
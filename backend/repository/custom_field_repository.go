@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CustomFieldRepository 封装会话自定义字段定义及取值的数据库操作。
+type CustomFieldRepository struct {
+	db *gorm.DB
+}
+
+// NewCustomFieldRepository 创建自定义字段仓库实例。
+func NewCustomFieldRepository(db *gorm.DB) *CustomFieldRepository {
+	return &CustomFieldRepository{db: db}
+}
+
+// ListDefinitions 列出全部字段定义（按排序值、ID 升序）。
+func (r *CustomFieldRepository) ListDefinitions() ([]models.CustomFieldDefinition, error) {
+	var defs []models.CustomFieldDefinition
+	if err := r.db.Order("sort_order ASC, id ASC").Find(&defs).Error; err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// CreateDefinition 新建字段定义。
+func (r *CustomFieldRepository) CreateDefinition(def *models.CustomFieldDefinition) error {
+	return r.db.Create(def).Error
+}
+
+// GetDefinition 根据 ID 查询字段定义。
+func (r *CustomFieldRepository) GetDefinition(id uint) (*models.CustomFieldDefinition, error) {
+	var def models.CustomFieldDefinition
+	if err := r.db.Where("id = ?", id).First(&def).Error; err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// GetDefinitionByKey 根据字段标识查询字段定义。
+func (r *CustomFieldRepository) GetDefinitionByKey(key string) (*models.CustomFieldDefinition, error) {
+	var def models.CustomFieldDefinition
+	if err := r.db.Where("`key` = ?", key).First(&def).Error; err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// UpdateDefinition 保存字段定义。
+func (r *CustomFieldRepository) UpdateDefinition(def *models.CustomFieldDefinition) error {
+	return r.db.Save(def).Error
+}
+
+// DeleteDefinition 删除字段定义及其全部取值。
+func (r *CustomFieldRepository) DeleteDefinition(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("field_id = ?", id).Delete(&models.ConversationFieldValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.CustomFieldDefinition{}, id).Error
+	})
+}
+
+// ListValuesByConversationIDs 批量查询会话的字段取值。
+func (r *CustomFieldRepository) ListValuesByConversationIDs(conversationIDs []uint) ([]models.ConversationFieldValue, error) {
+	if len(conversationIDs) == 0 {
+		return []models.ConversationFieldValue{}, nil
+	}
+	var values []models.ConversationFieldValue
+	if err := r.db.Where("conversation_id IN ?", conversationIDs).Find(&values).Error; err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// UpsertValue 写入会话某字段的取值（已存在则覆盖）。
+func (r *CustomFieldRepository) UpsertValue(value *models.ConversationFieldValue) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_id"}, {Name: "field_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "number_value", "updated_by", "updated_at"}),
+	}).Create(value).Error
+}
+
+// DeleteValue 清除会话某字段的取值。
+func (r *CustomFieldRepository) DeleteValue(conversationID, fieldID uint) error {
+	return r.db.Where("conversation_id = ? AND field_id = ?", conversationID, fieldID).
+		Delete(&models.ConversationFieldValue{}).Error
+}
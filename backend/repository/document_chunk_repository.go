@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"strings"
+
 	"github.com/2930134478/AI-CS/backend/models"
 	"gorm.io/gorm"
 )
@@ -108,3 +110,76 @@ func (r *DocumentChunkRepository) GetByIDs(ids []uint) ([]models.DocumentChunk,
 	}
 	return chunks, nil
 }
+
+// chunkFulltextIndex 分段内容的全文索引名（ngram 分词，支持中文）
+const chunkFulltextIndex = "ft_chunk_content"
+
+// EnsureFulltextIndex 确保 document_chunks.content 上存在 ngram 全文索引（关键词/混合检索使用）。
+// 需 MySQL 5.7.6+ / 8.0；创建失败时关键词检索会退化为 LIKE 匹配。
+func (r *DocumentChunkRepository) EnsureFulltextIndex() error {
+	if r.db.Migrator().HasIndex(&models.DocumentChunk{}, chunkFulltextIndex) {
+		return nil
+	}
+	return r.db.Exec("ALTER TABLE document_chunks ADD FULLTEXT INDEX " + chunkFulltextIndex + " (content) WITH PARSER ngram").Error
+}
+
+// ChunkKeywordHit 关键词检索命中的分段
+type ChunkKeywordHit struct {
+	ID              uint
+	DocumentID      uint
+	KnowledgeBaseID uint
+	Content         string
+	PageNumber      int
+	HeadingPath     string
+	SourceAnchor    string
+	Score           float64
+}
+
+// KeywordSearch 在分段内容上做关键词检索（MySQL FULLTEXT 自然语言模式，按相关度倒序）。
+// knowledgeBaseIDs 非空时限定知识库范围；全文索引不可用时退化为 LIKE 子串匹配。
+func (r *DocumentChunkRepository) KeywordSearch(query string, knowledgeBaseIDs []uint, limit int) ([]ChunkKeywordHit, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	var hits []ChunkKeywordHit
+	tx := r.db.Model(&models.DocumentChunk{}).
+		Select("id, document_id, knowledge_base_id, content, page_number, heading_path, source_anchor, MATCH(content) AGAINST(? IN NATURAL LANGUAGE MODE) AS score", query).
+		Where("MATCH(content) AGAINST(? IN NATURAL LANGUAGE MODE)", query)
+	if len(knowledgeBaseIDs) > 0 {
+		tx = tx.Where("knowledge_base_id IN ?", knowledgeBaseIDs)
+	}
+	err := tx.Order("score DESC").Limit(limit).Scan(&hits).Error
+	if err == nil {
+		return hits, nil
+	}
+
+	// 全文索引不可用：按整串子串匹配（产品编号、错误码等精确词仍可命中）
+	hits = nil
+	like := r.db.Model(&models.DocumentChunk{}).
+		Select("id, document_id, knowledge_base_id, content, page_number, heading_path, source_anchor, 1 AS score").
+		Where("content LIKE ? ESCAPE '!'", "%"+escapeLike(query)+"%")
+	if len(knowledgeBaseIDs) > 0 {
+		like = like.Where("knowledge_base_id IN ?", knowledgeBaseIDs)
+	}
+	if likeErr := like.Order("id DESC").Limit(limit).Scan(&hits).Error; likeErr != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// likeEscaper 转义 LIKE 通配符（配合 ESCAPE '!'），使用户输入的 %、_ 按字面匹配
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// escapeLike 转义 LIKE 模式中的通配符与转义符
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// MarkAllEmbeddingPending 将全部分段的向量化状态重置为 pending
+func (r *DocumentChunkRepository) MarkAllEmbeddingPending() error {
+	return r.db.Model(&models.DocumentChunk{}).Where("1 = 1").Update("embedding_status", "pending").Error
+}
@@ -102,3 +102,19 @@ func (r *DocumentRepository) UpdateEmbeddingStatus(id uint, status string) error
 func (r *DocumentRepository) UpdateStatus(id uint, status string) error {
 	return r.db.Model(&models.Document{}).Where("id = ?", id).Update("status", status).Error
 }
+
+// ListAfterID 按 ID 升序分批获取文档（ID 大于 afterID 的前 limit 条）
+func (r *DocumentRepository) ListAfterID(afterID uint, limit int) ([]models.Document, error) {
+	var docs []models.Document
+	if err := r.db.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// MarkUnchunkedEmbeddingPending 将未分段文档的向量化状态重置为 pending（已分段文档的向量状态由分段维护）
+func (r *DocumentRepository) MarkUnchunkedEmbeddingPending() error {
+	return r.db.Model(&models.Document{}).
+		Where("id NOT IN (?)", r.db.Model(&models.DocumentChunk{}).Distinct("document_id")).
+		Update("embedding_status", "pending").Error
+}
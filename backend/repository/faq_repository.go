@@ -62,6 +62,53 @@ func (r *FAQRepository) List(keywords []string) ([]models.FAQ, error) {
 	return faqs, nil
 }
 
+// GetByIDs 按 ID 批量查询 FAQ。
+func (r *FAQRepository) GetByIDs(ids []uint) ([]models.FAQ, error) {
+	var faqs []models.FAQ
+	if len(ids) == 0 {
+		return faqs, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Find(&faqs).Error; err != nil {
+		return nil, err
+	}
+	return faqs, nil
+}
+
+// MatchQuery 在知识库范围内（含全局 FAQ）按子串匹配用户查询：问题包含查询、查询包含问题，或关键词包含查询；最多返回 limit 条。
+func (r *FAQRepository) MatchQuery(query string, kbIDs []uint, limit int) ([]models.FAQ, error) {
+	var faqs []models.FAQ
+	pattern := "%" + escapeLike(query) + "%"
+	q := r.db.Model(&models.FAQ{}).
+		Where("question LIKE ? ESCAPE '!' OR (question <> '' AND INSTR(?, question) > 0) OR keywords LIKE ? ESCAPE '!'",
+			pattern, query, pattern)
+	if len(kbIDs) > 0 {
+		q = q.Where("knowledge_base_id IS NULL OR knowledge_base_id = 0 OR knowledge_base_id IN ?", kbIDs)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if err := q.Order("created_at DESC").Find(&faqs).Error; err != nil {
+		return nil, err
+	}
+	return faqs, nil
+}
+
+// FindByQuestion 在知识库范围内（含全局 FAQ）查找问题完全一致的 FAQ；未找到时返回 nil, nil。
+func (r *FAQRepository) FindByQuestion(question string, kbIDs []uint) (*models.FAQ, error) {
+	var faqs []models.FAQ
+	query := r.db.Model(&models.FAQ{}).Where("question = ?", question)
+	if len(kbIDs) > 0 {
+		query = query.Where("knowledge_base_id IS NULL OR knowledge_base_id = 0 OR knowledge_base_id IN ?", kbIDs)
+	}
+	if err := query.Order("created_at DESC").Limit(1).Find(&faqs).Error; err != nil {
+		return nil, err
+	}
+	if len(faqs) == 0 {
+		return nil, nil
+	}
+	return &faqs[0], nil
+}
+
 // Update 更新 FAQ 记录。
 func (r *FAQRepository) Update(faq *models.FAQ) error {
 	return r.db.Save(faq).Error
@@ -88,3 +135,7 @@ func (r *FAQRepository) QuickSearch(q string, limit int) ([]models.FAQ, error) {
 	return faqs, nil
 }
 
+// MarkAllEmbeddingPending 将全部 FAQ 的向量化状态重置为 pending
+func (r *FAQRepository) MarkAllEmbeddingPending() error {
+	return r.db.Model(&models.FAQ{}).Where("1 = 1").Update("embedding_status", "pending").Error
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// KnowledgeBaseBindingRepository 封装挂件/站点知识库绑定的数据库操作
+type KnowledgeBaseBindingRepository struct {
+	db *gorm.DB
+}
+
+// NewKnowledgeBaseBindingRepository 创建绑定仓库实例
+func NewKnowledgeBaseBindingRepository(db *gorm.DB) *KnowledgeBaseBindingRepository {
+	return &KnowledgeBaseBindingRepository{db: db}
+}
+
+// Create 创建绑定
+func (r *KnowledgeBaseBindingRepository) Create(b *models.KnowledgeBaseBinding) error {
+	return r.db.Create(b).Error
+}
+
+// GetByID 根据 ID 查询绑定
+func (r *KnowledgeBaseBindingRepository) GetByID(id uint) (*models.KnowledgeBaseBinding, error) {
+	var b models.KnowledgeBaseBinding
+	if err := r.db.Where("id = ?", id).First(&b).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// List 获取全部绑定
+func (r *KnowledgeBaseBindingRepository) List() ([]models.KnowledgeBaseBinding, error) {
+	var list []models.KnowledgeBaseBinding
+	if err := r.db.Order("id ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListEnabled 获取已启用的绑定（会话初始化时解析知识库范围）
+func (r *KnowledgeBaseBindingRepository) ListEnabled() ([]models.KnowledgeBaseBinding, error) {
+	var list []models.KnowledgeBaseBinding
+	if err := r.db.Where("enabled = ?", true).Order("id ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Update 更新绑定
+func (r *KnowledgeBaseBindingRepository) Update(b *models.KnowledgeBaseBinding) error {
+	return r.db.Save(b).Error
+}
+
+// Delete 删除绑定
+func (r *KnowledgeBaseBindingRepository) Delete(id uint) error {
+	return r.db.Delete(&models.KnowledgeBaseBinding{}, id).Error
+}
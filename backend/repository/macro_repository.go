@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// MacroRepository 封装快捷回复（宏）及其分组的数据库操作。
+type MacroRepository struct {
+	db *gorm.DB
+}
+
+// NewMacroRepository 创建快捷回复仓库实例。
+func NewMacroRepository(db *gorm.DB) *MacroRepository {
+	return &MacroRepository{db: db}
+}
+
+// Create 新建快捷回复。
+func (r *MacroRepository) Create(macro *models.Macro) error {
+	return r.db.Create(macro).Error
+}
+
+// GetByID 根据 ID 查询快捷回复。
+func (r *MacroRepository) GetByID(id uint) (*models.Macro, error) {
+	var macro models.Macro
+	if err := r.db.Where("id = ?", id).First(&macro).Error; err != nil {
+		return nil, err
+	}
+	return &macro, nil
+}
+
+// Update 保存快捷回复。
+func (r *MacroRepository) Update(macro *models.Macro) error {
+	return r.db.Save(macro).Error
+}
+
+// Delete 删除快捷回复。
+func (r *MacroRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Macro{}, id).Error
+}
+
+// ListVisible 列出用户可见的快捷回复（本人的个人宏 + 团队宏）。
+// folderID 非空时仅返回该分组；keyword 非空时按标题、快捷指令、内容模糊匹配。
+func (r *MacroRepository) ListVisible(userID uint, folderID *uint, keyword string) ([]models.Macro, error) {
+	var macros []models.Macro
+	query := r.db.Model(&models.Macro{}).
+		Where("scope = ? OR (scope = ? AND owner_id = ?)", "team", "personal", userID)
+	if folderID != nil {
+		query = query.Where("folder_id = ?", *folderID)
+	}
+	if keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("(title LIKE ? OR shortcut LIKE ? OR content LIKE ?)", like, like, like)
+	}
+	if err := query.Order("usage_count DESC, id DESC").Find(&macros).Error; err != nil {
+		return nil, err
+	}
+	return macros, nil
+}
+
+// FindVisibleByShortcut 按快捷指令查找用户可见的快捷回复（个人宏优先于团队宏）。
+func (r *MacroRepository) FindVisibleByShortcut(userID uint, shortcut string) (*models.Macro, error) {
+	var macro models.Macro
+	err := r.db.Where("shortcut = ? AND (scope = ? OR (scope = ? AND owner_id = ?))", shortcut, "team", "personal", userID).
+		Order("scope DESC").
+		First(&macro).Error
+	if err != nil {
+		return nil, err
+	}
+	return &macro, nil
+}
+
+// IncrementUsage 使用次数 +1（用于列表按常用排序）。
+func (r *MacroRepository) IncrementUsage(id uint) error {
+	return r.db.Model(&models.Macro{}).Where("id = ?", id).
+		UpdateColumn("usage_count", gorm.Expr("usage_count + 1")).Error
+}
+
+// ClearFolder 删除分组时将其中的快捷回复移出分组。
+func (r *MacroRepository) ClearFolder(folderID uint) error {
+	return r.db.Model(&models.Macro{}).Where("folder_id = ?", folderID).
+		UpdateColumn("folder_id", nil).Error
+}
+
+// CreateFolder 新建分组。
+func (r *MacroRepository) CreateFolder(folder *models.MacroFolder) error {
+	return r.db.Create(folder).Error
+}
+
+// GetFolderByID 根据 ID 查询分组。
+func (r *MacroRepository) GetFolderByID(id uint) (*models.MacroFolder, error) {
+	var folder models.MacroFolder
+	if err := r.db.Where("id = ?", id).First(&folder).Error; err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// UpdateFolder 保存分组。
+func (r *MacroRepository) UpdateFolder(folder *models.MacroFolder) error {
+	return r.db.Save(folder).Error
+}
+
+// DeleteFolder 删除分组。
+func (r *MacroRepository) DeleteFolder(id uint) error {
+	return r.db.Delete(&models.MacroFolder{}, id).Error
+}
+
+// ListVisibleFolders 列出用户可见的分组（本人的个人分组 + 团队分组）。
+func (r *MacroRepository) ListVisibleFolders(userID uint) ([]models.MacroFolder, error) {
+	var folders []models.MacroFolder
+	if err := r.db.Where("scope = ? OR (scope = ? AND owner_id = ?)", "team", "personal", userID).
+		Order("sort_order ASC, id ASC").
+		Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
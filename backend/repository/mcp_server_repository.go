@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+)
+
+// MCPServerRepository 封装 MCP 服务注册信息的数据库操作。
+type MCPServerRepository struct {
+	db *gorm.DB
+}
+
+// NewMCPServerRepository 创建 MCP 服务仓库实例。
+func NewMCPServerRepository(db *gorm.DB) *MCPServerRepository {
+	return &MCPServerRepository{db: db}
+}
+
+// List 按名称返回全部 MCP 服务。
+func (r *MCPServerRepository) List() ([]models.MCPServer, error) {
+	var servers []models.MCPServer
+	if err := r.db.Order("name ASC").Find(&servers).Error; err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// ListActive 返回已启用的 MCP 服务。
+func (r *MCPServerRepository) ListActive() ([]models.MCPServer, error) {
+	var servers []models.MCPServer
+	if err := r.db.Where("is_active = ?", true).Order("name ASC").Find(&servers).Error; err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// GetByID 根据主键查询 MCP 服务。
+func (r *MCPServerRepository) GetByID(id uint) (*models.MCPServer, error) {
+	var server models.MCPServer
+	if err := r.db.First(&server, id).Error; err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// GetByName 根据名称查询 MCP 服务。
+func (r *MCPServerRepository) GetByName(name string) (*models.MCPServer, error) {
+	var server models.MCPServer
+	if err := r.db.Where("name = ?", name).First(&server).Error; err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// Create 新增 MCP 服务。
+func (r *MCPServerRepository) Create(server *models.MCPServer) error {
+	return r.db.Create(server).Error
+}
+
+// Update 保存 MCP 服务。
+func (r *MCPServerRepository) Update(server *models.MCPServer) error {
+	return r.db.Save(server).Error
+}
+
+// Delete 删除 MCP 服务。
+func (r *MCPServerRepository) Delete(id uint) error {
+	return r.db.Delete(&models.MCPServer{}, id).Error
+}
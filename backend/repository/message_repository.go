@@ -32,10 +32,10 @@ func (r *MessageRepository) ListByConversationID(conversationID uint) ([]models.
 	return messages, nil
 }
 
-// LatestByConversationID 查询会话中最新的一条消息。
+// LatestByConversationID 查询会话中最新的一条消息（不含 whisper 内部备注，结果会返回给访客）。
 func (r *MessageRepository) LatestByConversationID(conversationID uint) (*models.Message, error) {
 	var message models.Message
-	if err := r.db.Where("conversation_id = ?", conversationID).
+	if err := r.db.Where("conversation_id = ? AND (message_type IS NULL OR message_type <> ?)", conversationID, models.MessageTypeWhisper).
 		Order("created_at desc").
 		First(&message).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -46,6 +46,15 @@ func (r *MessageRepository) LatestByConversationID(conversationID uint) (*models
 	return &message, nil
 }
 
+// UpdateTranslation 写入消息的实时翻译结果（原文语言、译文、译文语言）。
+func (r *MessageRepository) UpdateTranslation(id uint, sourceLanguage, translatedContent, translatedLanguage string) error {
+	return r.db.Model(&models.Message{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"source_language":     sourceLanguage,
+		"translated_content":  translatedContent,
+		"translated_language": translatedLanguage,
+	}).Error
+}
+
 // GetByID 根据 ID 获取单条消息
 func (r *MessageRepository) GetByID(id uint) (*models.Message, error) {
 	var message models.Message
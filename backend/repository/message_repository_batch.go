@@ -4,7 +4,7 @@ import (
 	"github.com/2930134478/AI-CS/backend/models"
 )
 
-// BatchLatestByConversationIDs 批量查询各会话最新一条消息。
+// BatchLatestByConversationIDs 批量查询各会话最新一条消息（不含 whisper 内部备注）。
 func (r *MessageRepository) BatchLatestByConversationIDs(conversationIDs []uint) (map[uint]*models.Message, error) {
 	result := make(map[uint]*models.Message, len(conversationIDs))
 	if len(conversationIDs) == 0 {
@@ -14,7 +14,7 @@ func (r *MessageRepository) BatchLatestByConversationIDs(conversationIDs []uint)
 	var latestIDs []uint
 	if err := r.db.Model(&models.Message{}).
 		Select("MAX(id)").
-		Where("conversation_id IN ?", conversationIDs).
+		Where("conversation_id IN ? AND (message_type IS NULL OR message_type <> ?)", conversationIDs, models.MessageTypeWhisper).
 		Group("conversation_id").
 		Pluck("MAX(id)", &latestIDs).Error; err != nil {
 		return nil, err
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"github.com/2930134478/AI-CS/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagRepository 封装会话标签及会话-标签关联的数据库操作。
+type TagRepository struct {
+	db *gorm.DB
+}
+
+// NewTagRepository 创建标签仓库实例。
+func NewTagRepository(db *gorm.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// List 列出全部标签（按名称排序）。
+func (r *TagRepository) List() ([]models.Tag, error) {
+	var tags []models.Tag
+	if err := r.db.Order("name ASC").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Create 新建标签。
+func (r *TagRepository) Create(tag *models.Tag) error {
+	return r.db.Create(tag).Error
+}
+
+// GetByID 根据 ID 查询标签。
+func (r *TagRepository) GetByID(id uint) (*models.Tag, error) {
+	var tag models.Tag
+	if err := r.db.Where("id = ?", id).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// GetByName 根据名称查询标签。
+func (r *TagRepository) GetByName(name string) (*models.Tag, error) {
+	var tag models.Tag
+	if err := r.db.Where("name = ?", name).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// ListByIDs 批量查询标签。
+func (r *TagRepository) ListByIDs(ids []uint) ([]models.Tag, error) {
+	if len(ids) == 0 {
+		return []models.Tag{}, nil
+	}
+	var tags []models.Tag
+	if err := r.db.Where("id IN ?", ids).Order("name ASC").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ListByNames 按名称批量查询标签。
+func (r *TagRepository) ListByNames(names []string) ([]models.Tag, error) {
+	if len(names) == 0 {
+		return []models.Tag{}, nil
+	}
+	var tags []models.Tag
+	if err := r.db.Where("name IN ?", names).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Update 保存标签。
+func (r *TagRepository) Update(tag *models.Tag) error {
+	return r.db.Save(tag).Error
+}
+
+// Delete 删除标签及其全部会话关联。
+func (r *TagRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", id).Delete(&models.ConversationTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Tag{}, id).Error
+	})
+}
+
+// ListByConversationID 查询会话的标签。
+func (r *TagRepository) ListByConversationID(conversationID uint) ([]models.Tag, error) {
+	var tags []models.Tag
+	if err := r.db.Model(&models.Tag{}).
+		Joins("JOIN conversation_tags ON conversation_tags.tag_id = tags.id").
+		Where("conversation_tags.conversation_id = ?", conversationID).
+		Order("tags.name ASC").
+		Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// BatchListByConversationIDs 批量查询多个会话的标签，返回 conversation_id -> 标签列表。
+func (r *TagRepository) BatchListByConversationIDs(conversationIDs []uint) (map[uint][]models.Tag, error) {
+	result := make(map[uint][]models.Tag)
+	if len(conversationIDs) == 0 {
+		return result, nil
+	}
+	type row struct {
+		ConversationID uint
+		models.Tag
+	}
+	var rows []row
+	if err := r.db.Model(&models.Tag{}).
+		Select("conversation_tags.conversation_id, tags.*").
+		Joins("JOIN conversation_tags ON conversation_tags.tag_id = tags.id").
+		Where("conversation_tags.conversation_id IN ?", conversationIDs).
+		Order("tags.name ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range rows {
+		result[item.ConversationID] = append(result[item.ConversationID], item.Tag)
+	}
+	return result, nil
+}
+
+// AddToConversation 为会话添加标签（已存在时忽略）。
+func (r *TagRepository) AddToConversation(conversationID, tagID, operatorID uint) error {
+	link := models.ConversationTag{ConversationID: conversationID, TagID: tagID, CreatedBy: operatorID}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error
+}
+
+// RemoveFromConversation 移除会话的标签。
+func (r *TagRepository) RemoveFromConversation(conversationID, tagID uint) error {
+	return r.db.Where("conversation_id = ? AND tag_id = ?", conversationID, tagID).
+		Delete(&models.ConversationTag{}).Error
+}
+
+// ReplaceForConversation 用给定标签集合覆盖会话的标签。
+func (r *TagRepository) ReplaceForConversation(conversationID uint, tagIDs []uint, operatorID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", conversationID).Delete(&models.ConversationTag{}).Error; err != nil {
+			return err
+		}
+		if len(tagIDs) == 0 {
+			return nil
+		}
+		links := make([]models.ConversationTag, 0, len(tagIDs))
+		for _, id := range tagIDs {
+			links = append(links, models.ConversationTag{ConversationID: conversationID, TagID: id, CreatedBy: operatorID})
+		}
+		return tx.Create(&links).Error
+	})
+}
+
+// FindConversationIDsByNameLike 根据标签名称模糊匹配，返回打过这些标签的会话 ID。
+func (r *TagRepository) FindConversationIDsByNameLike(pattern string) ([]uint, error) {
+	var ids []uint
+	if err := r.db.Model(&models.ConversationTag{}).
+		Joins("JOIN tags ON tags.id = conversation_tags.tag_id").
+		Where("tags.name LIKE ?", pattern).
+		Distinct().
+		Pluck("conversation_tags.conversation_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
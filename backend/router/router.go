@@ -26,10 +26,23 @@ type ControllerSet struct {
 	Health            *controller.HealthController
 	Analytics         *controller.AnalyticsController
 	SystemLog         *controller.SystemLogController
+	Handoff           *controller.HandoffController
+	Assignment        *controller.AssignmentController
+	Collaboration     *controller.CollaborationController
+	Macro             *controller.MacroController
+	Attribute         *controller.ConversationAttributeController
+	AIUsage           *controller.AIUsageController
+	AITool            *controller.AIToolController
+	MCPServer         *controller.MCPServerController
+	Copilot           *controller.CopilotController
+	Summary           *controller.ConversationSummaryController
+	Translation       *controller.TranslationController
+	CrawlSource       *controller.CrawlSourceController
 }
 
 // RegisterRoutes 注册 HTTP 路由及对应的处理函数。
-func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.HandlerFunc) {
+// mcpHandler 为 AI-CS 自身的 MCP 服务端点（内部自行校验 Bearer 令牌）。
+func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.HandlerFunc, mcpHandler gin.HandlerFunc) {
 	registerPublic := func(routes gin.IRoutes) {
 		// Auth（公开）
 		routes.POST("/login", controllers.Auth.Login)
@@ -39,6 +52,7 @@ func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.Hand
 		routes.POST("/conversation/init", controllers.Conversation.InitConversation)
 		routes.GET("/conversations/:id", controllers.Conversation.GetConversationDetail)
 		routes.PUT("/conversations/:id/contact", controllers.Conversation.UpdateContactInfo)
+		routes.POST("/conversations/:id/handoff", controllers.Handoff.RequestHandoff)
 		routes.GET("/conversations/ai-models", controllers.Conversation.GetPublicAIModels)
 
 		// Message（访客 access_token 或客服登录令牌，控制器内校验）
@@ -46,6 +60,7 @@ func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.Hand
 		routes.POST("/messages/upload", controllers.Message.UploadFile)
 		routes.GET("/messages", controllers.Message.ListMessages)
 		routes.PUT("/messages/read", controllers.Message.MarkMessagesRead)
+		routes.POST("/messages/ai/cancel", controllers.Message.CancelAIReply)
 
 		// Visitor（公开）
 		routes.GET("/visitor/online-agents", controllers.Visitor.GetOnlineAgents)
@@ -58,6 +73,9 @@ func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.Hand
 
 		// WebSocket
 		routes.GET("/ws", wsHandler)
+
+		// MCP 服务（streamable HTTP，使用客服登录令牌鉴权）
+		routes.Any("/mcp", mcpHandler)
 	}
 
 	registerAgent := func(group *gin.RouterGroup) {
@@ -67,10 +85,26 @@ func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.Hand
 		group.POST("/conversations/internal", controllers.Conversation.InitInternalConversation)
 		group.GET("/conversations", controllers.Conversation.ListConversations)
 		group.GET("/conversations/search", controllers.Conversation.SearchConversations)
+		group.GET("/conversations/handoff-queue", controllers.Handoff.ListQueue)
 		group.POST("/conversations/:id/close", controllers.Conversation.CloseConversation)
+		group.POST("/conversations/:id/assign", controllers.Assignment.AssignConversation)
+		group.POST("/conversations/:id/transfer", controllers.Assignment.TransferConversation)
+		group.POST("/conversations/:id/invite", controllers.Collaboration.InviteParticipant)
+		group.POST("/conversations/:id/leave", controllers.Collaboration.LeaveConversation)
+		group.GET("/conversations/:id/participants", controllers.Collaboration.ListParticipants)
+		group.POST("/conversations/:id/whispers", controllers.Collaboration.CreateWhisper)
+		group.GET("/conversations/:id/attributes", controllers.Attribute.GetConversationAttributes)
+		group.PUT("/conversations/:id/tags", controllers.Attribute.SetConversationTags)
+		group.POST("/conversations/:id/tags", controllers.Attribute.AddConversationTag)
+		group.DELETE("/conversations/:id/tags/:tag_id", controllers.Attribute.RemoveConversationTag)
+		group.PUT("/conversations/:id/priority", controllers.Attribute.SetConversationPriority)
+		group.PUT("/conversations/:id/custom-fields", controllers.Attribute.SetConversationCustomFields)
 		group.GET("/conversations/maintenance/auto-close-days", controllers.Conversation.GetAutoCloseConversationDaysPolicy)
 		group.PUT("/conversations/maintenance/auto-close-days", controllers.Conversation.PutAutoCloseConversationDaysPolicy)
 		group.DELETE("/conversations/maintenance/auto-close-days", controllers.Conversation.DeleteAutoCloseConversationDaysPolicy)
+		group.GET("/agent/assignment/strategy", controllers.Assignment.GetStrategyPolicy)
+		group.PUT("/agent/assignment/strategy", controllers.Assignment.PutStrategyPolicy)
+		group.DELETE("/agent/assignment/strategy", controllers.Assignment.DeleteStrategyPolicy)
 
 		// Admin（用户管理）
 		group.GET("/admin/users", controllers.Admin.ListUsers)
@@ -93,6 +127,29 @@ func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.Hand
 		group.PUT("/agent/ai-config/:user_id/:id", controllers.AIConfig.UpdateAIConfig)
 		group.DELETE("/agent/ai-config/:user_id/:id", controllers.AIConfig.DeleteAIConfig)
 
+		// 模型价格表（token 用量计费）
+		group.GET("/agent/ai-prices", controllers.AIUsage.ListPrices)
+		group.POST("/agent/ai-prices", controllers.AIUsage.CreatePrice)
+		group.PUT("/agent/ai-prices/:id", controllers.AIUsage.UpdatePrice)
+		group.DELETE("/agent/ai-prices/:id", controllers.AIUsage.DeletePrice)
+
+		// HTTP 工具（模型 function calling）
+		group.GET("/agent/ai-tools", controllers.AITool.ListTools)
+		group.POST("/agent/ai-tools", controllers.AITool.CreateTool)
+		group.GET("/agent/ai-tools/:id", controllers.AITool.GetTool)
+		group.PUT("/agent/ai-tools/:id", controllers.AITool.UpdateTool)
+		group.DELETE("/agent/ai-tools/:id", controllers.AITool.DeleteTool)
+		group.POST("/agent/ai-tools/:id/test", controllers.AITool.TestTool)
+
+		// MCP 服务（工具提供方）
+		group.GET("/agent/mcp-servers", controllers.MCPServer.ListServers)
+		group.POST("/agent/mcp-servers", controllers.MCPServer.CreateServer)
+		group.GET("/agent/mcp-servers/:id", controllers.MCPServer.GetServer)
+		group.PUT("/agent/mcp-servers/:id", controllers.MCPServer.UpdateServer)
+		group.DELETE("/agent/mcp-servers/:id", controllers.MCPServer.DeleteServer)
+		group.GET("/agent/mcp-servers/:id/tools", controllers.MCPServer.ListTools)
+		group.POST("/agent/mcp-servers/:id/reconnect", controllers.MCPServer.Reconnect)
+
 		// Embedding Config
 		group.GET("/agent/embedding-config", controllers.EmbeddingConfig.Get)
 		group.PUT("/agent/embedding-config", controllers.EmbeddingConfig.Update)
@@ -107,6 +164,50 @@ func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.Hand
 		group.GET("/agent/prompts", controllers.PromptConfig.Get)
 		group.PUT("/agent/prompts", controllers.PromptConfig.Update)
 
+		// 会话标签与自定义字段
+		group.GET("/agent/tags", controllers.Attribute.ListTags)
+		group.POST("/agent/tags", controllers.Attribute.CreateTag)
+		group.PUT("/agent/tags/:id", controllers.Attribute.UpdateTag)
+		group.DELETE("/agent/tags/:id", controllers.Attribute.DeleteTag)
+		group.GET("/agent/custom-fields", controllers.Attribute.ListCustomFields)
+		group.POST("/agent/custom-fields", controllers.Attribute.CreateCustomField)
+		group.PUT("/agent/custom-fields/:id", controllers.Attribute.UpdateCustomField)
+		group.DELETE("/agent/custom-fields/:id", controllers.Attribute.DeleteCustomField)
+
+		// 会话小结（关闭后 AI 生成摘要 / 分类 / 解决状态 / 关键实体）
+		group.POST("/conversations/:id/summary", controllers.Summary.Regenerate)
+		group.GET("/agent/conversation-categories", controllers.Summary.ListCategories)
+		group.POST("/agent/conversation-categories", controllers.Summary.CreateCategory)
+		group.PUT("/agent/conversation-categories/:id", controllers.Summary.UpdateCategory)
+		group.DELETE("/agent/conversation-categories/:id", controllers.Summary.DeleteCategory)
+		group.GET("/agent/conversation-summary/settings", controllers.Summary.GetSettings)
+		group.PUT("/agent/conversation-summary/settings", controllers.Summary.UpdateSettings)
+
+		// Macros（快捷回复）
+		group.GET("/agent/macros", controllers.Macro.ListMacros)
+		group.POST("/agent/macros", controllers.Macro.CreateMacro)
+		group.POST("/agent/macros/render", controllers.Macro.RenderShortcut)
+		group.GET("/agent/macros/folders", controllers.Macro.ListFolders)
+		group.POST("/agent/macros/folders", controllers.Macro.CreateFolder)
+		group.PUT("/agent/macros/folders/:id", controllers.Macro.UpdateFolder)
+		group.DELETE("/agent/macros/folders/:id", controllers.Macro.DeleteFolder)
+		group.GET("/agent/macros/:id", controllers.Macro.GetMacro)
+		group.PUT("/agent/macros/:id", controllers.Macro.UpdateMacro)
+		group.DELETE("/agent/macros/:id", controllers.Macro.DeleteMacro)
+		group.POST("/agent/macros/:id/render", controllers.Macro.RenderMacro)
+		group.POST("/agent/macros/:id/send", controllers.Macro.SendMacro)
+
+		// 客服助手（人工会话的 AI 候选回复，仅客服可见）
+		group.GET("/agent/conversations/:id/copilot", controllers.Copilot.ListSuggestions)
+		group.POST("/agent/conversations/:id/copilot", controllers.Copilot.Suggest)
+		group.POST("/agent/copilot/suggestions/:id/feedback", controllers.Copilot.Feedback)
+		group.GET("/agent/copilot/settings", controllers.Copilot.GetSettings)
+		group.PUT("/agent/copilot/settings", controllers.Copilot.UpdateSettings)
+
+		// 实时翻译（人工会话访客与客服之间）
+		group.GET("/agent/translation/settings", controllers.Translation.GetSettings)
+		group.PUT("/agent/translation/settings", controllers.Translation.UpdateSettings)
+
 		// FAQ
 		group.GET("/faqs", controllers.FAQ.ListFAQs)
 		group.GET("/faqs-search", controllers.FAQ.QuickSearch)
@@ -127,6 +228,7 @@ func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.Hand
 		group.POST("/documents/:id/publish", controllers.Document.PublishDocument)
 		group.POST("/documents/:id/unpublish", controllers.Document.UnpublishDocument)
 		group.POST("/documents/:id/chunks", controllers.DocumentChunk.ExecuteChunking)
+		group.POST("/documents/:id/chunks/preview", controllers.DocumentChunk.PreviewChunking)
 		group.GET("/documents/:id/chunks", controllers.DocumentChunk.GetChunks)
 		group.PUT("/documents/:id/chunks/:chunkId", controllers.DocumentChunk.UpdateChunk)
 		group.DELETE("/documents/:id/chunks", controllers.DocumentChunk.DeleteChunks)
@@ -139,13 +241,25 @@ func RegisterRoutes(r *gin.Engine, controllers ControllerSet, wsHandler gin.Hand
 		group.PATCH("/knowledge-bases/:id/rag-enabled", controllers.KnowledgeBase.UpdateKnowledgeBaseRAGEnabled)
 		group.DELETE("/knowledge-bases/:id", controllers.KnowledgeBase.DeleteKnowledgeBase)
 		group.GET("/knowledge-bases/:id/documents", controllers.KnowledgeBase.ListDocumentsByKnowledgeBase)
+		group.GET("/knowledge-base-bindings", controllers.KnowledgeBase.ListBindings)
+		group.POST("/knowledge-base-bindings", controllers.KnowledgeBase.CreateBinding)
+		group.PUT("/knowledge-base-bindings/:id", controllers.KnowledgeBase.UpdateBinding)
+		group.DELETE("/knowledge-base-bindings/:id", controllers.KnowledgeBase.DeleteBinding)
 
 		// Import
 		group.POST("/import/documents", controllers.Import.ImportDocuments)
 		group.POST("/import/urls", controllers.Import.ImportFromURLs)
+		group.GET("/import/crawl-sources", controllers.CrawlSource.ListSources)
+		group.POST("/import/crawl-sources", controllers.CrawlSource.CreateSource)
+		group.PUT("/import/crawl-sources/:id", controllers.CrawlSource.UpdateSource)
+		group.DELETE("/import/crawl-sources/:id", controllers.CrawlSource.DeleteSource)
+		group.POST("/import/crawl-sources/:id/run", controllers.CrawlSource.RunSource)
 
 		// Analytics & Logs
 		group.GET("/agent/analytics/summary", controllers.Analytics.GetSummary)
+		group.GET("/agent/analytics/ai-cost", controllers.Analytics.GetAICost)
+		group.GET("/agent/analytics/copilot", controllers.Analytics.GetCopilotStats)
+		group.GET("/agent/analytics/categories", controllers.Analytics.GetConversationCategories)
 		group.GET("/agent/logs/api", controllers.SystemLog.GetLogs)
 		group.GET("/agent/logs/min-level", controllers.SystemLog.GetLogMinLevel)
 		group.PUT("/agent/logs/min-level", controllers.SystemLog.PutLogMinLevel)
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/infra/search"
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/service/rag"
+)
+
+// 引用片段摘要的最大长度
+const maxCitationSnippetRunes = 200
+
+var (
+	citationRefPattern = regexp.MustCompile(`\[(\d{1,3})\]`)
+	webURLPattern      = regexp.MustCompile(`https?://[^\s"'<>）)\]]+`)
+)
+
+// citationCollector 收集本次回复可引用的来源并分配编号（知识库在前，联网结果依次追加）
+type citationCollector struct {
+	items []models.MessageCitation
+}
+
+func (c *citationCollector) add(citation models.MessageCitation) models.MessageCitation {
+	citation.Index = len(c.items) + 1
+	c.items = append(c.items, citation)
+	return citation
+}
+
+// truncate 丢弃编号大于 n 的来源（工具调用失败回退时移除本轮追加的联网结果）
+func (c *citationCollector) truncate(n int) {
+	if c != nil && n < len(c.items) {
+		c.items = c.items[:n]
+	}
+}
+
+func (c *citationCollector) len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.items)
+}
+
+// forAnswer 返回回复实际引用的来源：回复中出现 [n] 时仅保留被引用的编号，否则返回全部
+func (c *citationCollector) forAnswer(content string) []models.MessageCitation {
+	if c == nil || len(c.items) == 0 {
+		return nil
+	}
+	referenced := make(map[int]bool)
+	for _, m := range citationRefPattern.FindAllStringSubmatch(content, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n >= 1 && n <= len(c.items) {
+			referenced[n] = true
+		}
+	}
+	if len(referenced) == 0 {
+		return append([]models.MessageCitation(nil), c.items...)
+	}
+	out := make([]models.MessageCitation, 0, len(referenced))
+	for _, item := range c.items {
+		if referenced[item.Index] {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// addKnowledgeResults 登记知识库检索结果并返回带编号的上下文文本（[n] 《标题》 + 片段内容）
+func (s *AIService) addKnowledgeResults(c *citationCollector, results []rag.SearchResult) string {
+	docIDs := make([]uint, 0, len(results))
+	for _, r := range results {
+		if id, err := strconv.ParseUint(r.DocumentID, 10, 64); err == nil {
+			docIDs = append(docIDs, uint(id))
+		}
+	}
+	docs := make(map[uint]models.Document)
+	if s.docRepo != nil && len(docIDs) > 0 {
+		if list, err := s.docRepo.GetByIDs(docIDs); err == nil {
+			for _, d := range list {
+				docs[d.ID] = d
+			}
+		}
+	}
+
+	parts := make([]string, 0, len(results))
+	for _, r := range results {
+		docID, _ := strconv.ParseUint(r.DocumentID, 10, 64)
+		kbID, _ := strconv.ParseUint(r.KnowledgeBaseID, 10, 64)
+		chunkID, _ := strconv.ParseUint(r.ChunkID, 10, 64)
+		citation := models.MessageCitation{
+			SourceType:      "knowledge_base",
+			DocumentID:      uint(docID),
+			KnowledgeBaseID: uint(kbID),
+			ChunkID:         uint(chunkID),
+			Score:           r.Score,
+			Snippet:         truncateRunes(strings.TrimSpace(r.Content), maxCitationSnippetRunes),
+		}
+		// FAQ 向量以 FAQ ID 作为 document_id 存储：文档不存在（或知识库不一致）时按 FAQ 解析
+		if doc, ok := docs[uint(docID)]; ok && (kbID == 0 || doc.KnowledgeBaseID == uint(kbID)) {
+			citation.Title = doc.Title
+			citation.SourceName = doc.SourceName
+			citation.PageNumber = r.Source.PageNumber
+			citation.HeadingPath = r.Source.HeadingPath
+			if strings.HasPrefix(r.Source.SourceAnchor, "http") {
+				citation.URL = r.Source.SourceAnchor
+			}
+		} else if faq := s.lookupFAQ(uint(docID)); faq != nil {
+			citation.SourceType = "faq"
+			citation.Title = faq.Question
+		}
+		if citation.Title == "" {
+			citation.Title = fmt.Sprintf("文档 %d", docID)
+		}
+		citation = c.add(citation)
+		parts = append(parts, fmt.Sprintf("[%d] 《%s》\n%s", citation.Index, citationLabel(citation), r.Content))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// citationLabel 生成上下文中的来源标注，如「manual.pdf p.12 · 第三章 > 退款」；无来源信息时为标题
+func citationLabel(c models.MessageCitation) string {
+	label := c.Title
+	if c.SourceName != "" && c.PageNumber > 0 {
+		label = c.SourceName
+	}
+	if c.PageNumber > 0 {
+		label += fmt.Sprintf(" p.%d", c.PageNumber)
+	}
+	if c.HeadingPath != "" {
+		label += " · " + c.HeadingPath
+	}
+	return label
+}
+
+func (s *AIService) lookupFAQ(id uint) *models.FAQ {
+	if s.faqRepo == nil || id == 0 {
+		return nil
+	}
+	faq, err := s.faqRepo.GetByID(id)
+	if err != nil {
+		return nil
+	}
+	return faq
+}
+
+// searchWebWithCitations 执行联网搜索，结果登记为引用来源并按编号格式化后回填给模型
+func (s *AIService) searchWebWithCitations(ctx context.Context, c *citationCollector, query string) string {
+	if p, ok := s.webSearchProvider.(search.WebResultsProvider); ok {
+		results, _ := p.SearchResults(ctx, query)
+		return formatWebResults(results, c)
+	}
+	raw, _ := s.webSearchProvider.Search(ctx, query)
+	results := parseWebResults(raw)
+	if len(results) == 0 {
+		return raw
+	}
+	return formatWebResults(results, c)
+}
+
+// formatWebResults 格式化联网结果；c 非空时为每条结果分配引用编号
+func formatWebResults(results []search.WebResult, c *citationCollector) string {
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		if c != nil {
+			citation := c.add(models.MessageCitation{
+				SourceType: "web",
+				Title:      r.Title,
+				URL:        r.URL,
+				Snippet:    truncateRunes(strings.TrimSpace(r.Snippet), maxCitationSnippetRunes),
+			})
+			fmt.Fprintf(&b, "[%d] ", citation.Index)
+		}
+		b.WriteString(r.Title)
+		b.WriteString("\n")
+		b.WriteString(r.URL)
+		b.WriteString("\n")
+		b.WriteString(r.Snippet)
+	}
+	if c != nil && len(results) > 0 {
+		b.WriteString("\n\n引用以上结果时，请在相应句末以 [编号] 标注来源。")
+	}
+	return b.String()
+}
+
+// parseWebResults 从文本形式的搜索结果中解析标题与链接：优先按 Serper JSON（organic）解析，否则按「标题 / 链接 / 摘要」行解析
+func parseWebResults(text string) []search.WebResult {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	var payload struct {
+		Organic []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+		} `json:"organic"`
+	}
+	if err := json.Unmarshal([]byte(text), &payload); err == nil && len(payload.Organic) > 0 {
+		out := make([]search.WebResult, 0, len(payload.Organic))
+		for _, o := range payload.Organic {
+			out = append(out, search.WebResult{Title: o.Title, URL: o.Link, Snippet: o.Snippet})
+		}
+		return out
+	}
+
+	lines := strings.Split(text, "\n")
+	var out []search.WebResult
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		url := webURLPattern.FindString(line)
+		if url == "" {
+			continue
+		}
+		title := strings.TrimSpace(strings.Replace(line, url, "", 1))
+		if title == "" && i > 0 {
+			title = strings.TrimSpace(lines[i-1])
+		}
+		snippet := ""
+		if i+1 < len(lines) && webURLPattern.FindString(lines[i+1]) == "" {
+			snippet = strings.TrimSpace(lines[i+1])
+		}
+		out = append(out, search.WebResult{Title: title, URL: url, Snippet: snippet})
+	}
+	return out
+}
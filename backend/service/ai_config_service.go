@@ -13,6 +13,8 @@ import (
 type AIConfigService struct {
 	aiConfigRepo *repository.AIConfigRepository
 	userRepo     *repository.UserRepository
+	toolSvc      *AIToolService    // 可选，校验工具白名单
+	mcpSvc       *MCPServerService // 可选，校验 MCP 服务白名单
 }
 
 // NewAIConfigService 创建 AI 配置服务实例。
@@ -26,46 +28,73 @@ func NewAIConfigService(
 	}
 }
 
+// SetToolService 设置工具服务（用于校验 allowed_tool_ids）
+func (s *AIConfigService) SetToolService(svc *AIToolService) {
+	s.toolSvc = svc
+}
+
+// SetMCPServerService 设置 MCP 服务管理（用于校验 allowed_mcp_server_ids）
+func (s *AIConfigService) SetMCPServerService(svc *MCPServerService) {
+	s.mcpSvc = svc
+}
+
 // CreateAIConfigInput 创建 AI 配置的输入参数。
 type CreateAIConfigInput struct {
-	UserID      uint
-	Provider    string
-	APIURL      string
-	APIKey      string // 明文 API Key（会被加密存储）
-	Model       string
-	ModelType   string
-	IsActive    bool
-	IsPublic    bool   // 是否开放给访客使用
-	Description string
+	UserID              uint
+	Provider            string
+	APIURL              string
+	APIKey              string // 明文 API Key（会被加密存储）
+	Model               string
+	ModelType           string
+	Protocol            string // 接口协议：openai（默认）/ anthropic / gemini
+	IsActive            bool
+	IsPublic            bool // 是否开放给访客使用
+	Description         string
+	HistoryTokenBudget  int     // 对话历史 token 预算（0 使用默认值）
+	FallbackConfigIDs   []uint  // 故障转移备用配置（按顺序）
+	MonthlyBudget       float64 // 每月费用预算（0 不限）
+	AllowedToolIDs      []uint  // 允许模型调用的 HTTP 工具
+	AllowedMCPServerIDs []uint  // 允许模型调用的 MCP 服务
 }
 
 // UpdateAIConfigInput 更新 AI 配置的输入参数。
 type UpdateAIConfigInput struct {
-	ID          uint
-	Provider    *string
-	APIURL      *string
-	APIKey      *string // 明文 API Key（如果提供，会被加密存储）
-	Model       *string
-	ModelType   *string
-	IsActive    *bool
-	IsPublic    *bool   // 是否开放给访客使用
-	Description *string
+	ID                  uint
+	Provider            *string
+	APIURL              *string
+	APIKey              *string // 明文 API Key（如果提供，会被加密存储）
+	Model               *string
+	ModelType           *string
+	Protocol            *string
+	IsActive            *bool
+	IsPublic            *bool // 是否开放给访客使用
+	Description         *string
+	HistoryTokenBudget  *int     // 对话历史 token 预算（0 使用默认值）
+	FallbackConfigIDs   *[]uint  // 故障转移备用配置（按顺序；空数组表示清除）
+	MonthlyBudget       *float64 // 每月费用预算（0 不限）
+	AllowedToolIDs      *[]uint  // 允许模型调用的 HTTP 工具（空数组表示清除）
+	AllowedMCPServerIDs *[]uint  // 允许模型调用的 MCP 服务（空数组表示清除）
 }
 
 // AIConfigResult AI 配置返回结果（不包含加密的 API Key）。
 type AIConfigResult struct {
-	ID          uint   `json:"id"`
-	UserID      uint   `json:"user_id"`
-	Provider    string `json:"provider"`
-	APIURL      string `json:"api_url"`
-	Model       string `json:"model"`
-	ModelType   string `json:"model_type"`
-	Protocol    string `json:"protocol"`
-	IsActive    bool   `json:"is_active"`
-	IsPublic    bool   `json:"is_public"`
-	Description string `json:"description"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID                  uint    `json:"id"`
+	UserID              uint    `json:"user_id"`
+	Provider            string  `json:"provider"`
+	APIURL              string  `json:"api_url"`
+	Model               string  `json:"model"`
+	ModelType           string  `json:"model_type"`
+	Protocol            string  `json:"protocol"`
+	IsActive            bool    `json:"is_active"`
+	IsPublic            bool    `json:"is_public"`
+	Description         string  `json:"description"`
+	HistoryTokenBudget  int     `json:"history_token_budget"`
+	FallbackConfigIDs   []uint  `json:"fallback_config_ids"`
+	MonthlyBudget       float64 `json:"monthly_budget"`
+	AllowedToolIDs      []uint  `json:"allowed_tool_ids"`
+	AllowedMCPServerIDs []uint  `json:"allowed_mcp_server_ids"`
+	CreatedAt           string  `json:"created_at"`
+	UpdatedAt           string  `json:"updated_at"`
 }
 
 // CreateAIConfig 创建 AI 配置。
@@ -87,6 +116,34 @@ func (s *AIConfigService) CreateAIConfig(input CreateAIConfigInput) (*AIConfigRe
 		return nil, fmt.Errorf("加密 API Key 失败: %v", err)
 	}
 
+	if input.HistoryTokenBudget < 0 {
+		return nil, errors.New("对话历史 token 预算不能为负数")
+	}
+
+	if input.MonthlyBudget < 0 {
+		return nil, errors.New("每月预算不能为负数")
+	}
+
+	fallbackIDs, err := s.validateFallbackIDs(0, input.FallbackConfigIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	toolIDs, err := s.validateToolIDs(input.AllowedToolIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mcpServerIDs, err := s.validateMCPServerIDs(input.AllowedMCPServerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol, err := normalizeProtocol(input.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
 	// 设置默认值
 	modelType := input.ModelType
 	if modelType == "" {
@@ -95,15 +152,21 @@ func (s *AIConfigService) CreateAIConfig(input CreateAIConfigInput) (*AIConfigRe
 
 	// 创建配置
 	config := &models.AIConfig{
-		UserID:      input.UserID,
-		Provider:    input.Provider,
-		APIURL:      input.APIURL,
-		APIKey:      encryptedKey,
-		Model:       input.Model,
-		ModelType:   modelType,
-		IsActive:    input.IsActive,
-		IsPublic:    input.IsPublic,
-		Description: input.Description,
+		UserID:              input.UserID,
+		Provider:            input.Provider,
+		APIURL:              input.APIURL,
+		APIKey:              encryptedKey,
+		Model:               input.Model,
+		ModelType:           modelType,
+		Protocol:            protocol,
+		IsActive:            input.IsActive,
+		IsPublic:            input.IsPublic,
+		Description:         input.Description,
+		HistoryTokenBudget:  input.HistoryTokenBudget,
+		FallbackConfigIDs:   fallbackIDs,
+		MonthlyBudget:       input.MonthlyBudget,
+		AllowedToolIDs:      toolIDs,
+		AllowedMCPServerIDs: mcpServerIDs,
 	}
 
 	if err := s.aiConfigRepo.Create(config); err != nil {
@@ -153,24 +216,31 @@ func (s *AIConfigService) UpdateAIConfig(input UpdateAIConfigInput) (*AIConfigRe
 	if input.APIURL != nil {
 		updates["api_url"] = *input.APIURL
 	}
-		if input.APIKey != nil {
-			// 验证 API Key 不能为空
-			if *input.APIKey == "" {
-				return nil, errors.New("API Key 不能为空")
-			}
-			// 如果提供了新的 API Key，需要加密
-			encryptedKey, err := utils.EncryptAPIKey(*input.APIKey)
-			if err != nil {
-				return nil, fmt.Errorf("加密 API Key 失败: %v", err)
-			}
-			updates["api_key"] = encryptedKey
+	if input.APIKey != nil {
+		// 验证 API Key 不能为空
+		if *input.APIKey == "" {
+			return nil, errors.New("API Key 不能为空")
+		}
+		// 如果提供了新的 API Key，需要加密
+		encryptedKey, err := utils.EncryptAPIKey(*input.APIKey)
+		if err != nil {
+			return nil, fmt.Errorf("加密 API Key 失败: %v", err)
 		}
+		updates["api_key"] = encryptedKey
+	}
 	if input.Model != nil {
 		updates["model"] = *input.Model
 	}
 	if input.ModelType != nil {
 		updates["model_type"] = *input.ModelType
 	}
+	if input.Protocol != nil {
+		protocol, err := normalizeProtocol(*input.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		updates["protocol"] = protocol
+	}
 	if input.IsActive != nil {
 		updates["is_active"] = *input.IsActive
 	}
@@ -180,6 +250,39 @@ func (s *AIConfigService) UpdateAIConfig(input UpdateAIConfigInput) (*AIConfigRe
 	if input.Description != nil {
 		updates["description"] = *input.Description
 	}
+	if input.HistoryTokenBudget != nil {
+		if *input.HistoryTokenBudget < 0 {
+			return nil, errors.New("对话历史 token 预算不能为负数")
+		}
+		updates["history_token_budget"] = *input.HistoryTokenBudget
+	}
+	if input.FallbackConfigIDs != nil {
+		fallbackIDs, err := s.validateFallbackIDs(input.ID, *input.FallbackConfigIDs)
+		if err != nil {
+			return nil, err
+		}
+		updates["fallback_config_ids"] = fallbackIDs
+	}
+	if input.MonthlyBudget != nil {
+		if *input.MonthlyBudget < 0 {
+			return nil, errors.New("每月预算不能为负数")
+		}
+		updates["monthly_budget"] = *input.MonthlyBudget
+	}
+	if input.AllowedToolIDs != nil {
+		toolIDs, err := s.validateToolIDs(*input.AllowedToolIDs)
+		if err != nil {
+			return nil, err
+		}
+		updates["allowed_tool_ids"] = toolIDs
+	}
+	if input.AllowedMCPServerIDs != nil {
+		serverIDs, err := s.validateMCPServerIDs(*input.AllowedMCPServerIDs)
+		if err != nil {
+			return nil, err
+		}
+		updates["allowed_mcp_server_ids"] = serverIDs
+	}
 
 	if err := s.aiConfigRepo.UpdateFields(input.ID, updates); err != nil {
 		return nil, err
@@ -189,6 +292,39 @@ func (s *AIConfigService) UpdateAIConfig(input UpdateAIConfigInput) (*AIConfigRe
 	return s.GetAIConfig(input.ID)
 }
 
+// validateFallbackIDs 校验故障转移备用配置：须存在、为文本模型且不包含自身，返回逗号分隔存储值
+func (s *AIConfigService) validateFallbackIDs(selfID uint, ids []uint) (string, error) {
+	for _, id := range ids {
+		if id == selfID {
+			return "", errors.New("备用配置不能包含自身")
+		}
+		fallback, err := s.aiConfigRepo.GetByID(id)
+		if err != nil {
+			return "", fmt.Errorf("备用配置 %d 不存在", id)
+		}
+		if fallback.ModelType != "text" {
+			return "", fmt.Errorf("备用配置 %d 不是文本模型", id)
+		}
+	}
+	return utils.JoinUintList(ids), nil
+}
+
+// validateToolIDs 校验工具白名单中的工具均存在，返回逗号分隔存储值
+func (s *AIConfigService) validateToolIDs(ids []uint) (string, error) {
+	if s.toolSvc == nil {
+		return utils.JoinUintList(ids), nil
+	}
+	return s.toolSvc.ValidateToolIDs(ids)
+}
+
+// validateMCPServerIDs 校验 MCP 服务白名单中的服务均存在，返回逗号分隔存储值
+func (s *AIConfigService) validateMCPServerIDs(ids []uint) (string, error) {
+	if s.mcpSvc == nil {
+		return utils.JoinUintList(ids), nil
+	}
+	return s.mcpSvc.ValidateServerIDs(ids)
+}
+
 // DeleteAIConfig 删除 AI 配置。
 func (s *AIConfigService) DeleteAIConfig(id uint) error {
 	return s.aiConfigRepo.Delete(id)
@@ -200,29 +336,56 @@ func (s *AIConfigService) GetPublicModels(modelType string) ([]AIConfigResult, e
 	if err != nil {
 		return nil, err
 	}
-	
+
 	results := make([]AIConfigResult, 0, len(configs))
 	for _, config := range configs {
 		results = append(results, *s.toResult(&config))
 	}
-	
+
 	return results, nil
 }
 
 // toResult 将模型转换为返回结果（不包含加密的 API Key）。
 func (s *AIConfigService) toResult(config *models.AIConfig) *AIConfigResult {
 	return &AIConfigResult{
-		ID:          config.ID,
-		UserID:      config.UserID,
-		Provider:    config.Provider,
-		APIURL:      config.APIURL,
-		Model:       config.Model,
-		ModelType:   config.ModelType,
-		IsActive:    config.IsActive,
-		IsPublic:    config.IsPublic,
-		Description: config.Description,
-		CreatedAt:   config.CreatedAt.Format("2006-01-02 15:04:05"),
-		UpdatedAt:   config.UpdatedAt.Format("2006-01-02 15:04:05"),
+		ID:                  config.ID,
+		UserID:              config.UserID,
+		Provider:            config.Provider,
+		APIURL:              config.APIURL,
+		Model:               config.Model,
+		ModelType:           config.ModelType,
+		Protocol:            config.Protocol,
+		IsActive:            config.IsActive,
+		IsPublic:            config.IsPublic,
+		Description:         config.Description,
+		HistoryTokenBudget:  config.HistoryTokenBudget,
+		FallbackConfigIDs:   utils.ParseUintList(config.FallbackConfigIDs),
+		MonthlyBudget:       config.MonthlyBudget,
+		AllowedToolIDs:      utils.ParseUintList(config.AllowedToolIDs),
+		AllowedMCPServerIDs: utils.ParseUintList(config.AllowedMCPServerIDs),
+		CreatedAt:           config.CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:           config.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}
 }
 
+// resolveTextAIConfig 为后台任务（客服助手、会话小结等）选择文本模型：
+// 依次尝试 preferredIDs 中已启用的文本配置、agentID 的默认文本配置、第一个开放的文本配置。
+func resolveTextAIConfig(aiConfigs *repository.AIConfigRepository, preferredIDs []uint, agentID uint) (*models.AIConfig, error) {
+	for _, id := range preferredIDs {
+		if id == 0 {
+			continue
+		}
+		if config, err := aiConfigs.GetByID(id); err == nil && config.IsActive && config.ModelType == "text" {
+			return config, nil
+		}
+	}
+	if agentID > 0 {
+		if config, err := aiConfigs.GetActiveByUserID(agentID, "text"); err == nil {
+			return config, nil
+		}
+	}
+	if configs, err := aiConfigs.ListPublic("text"); err == nil && len(configs) > 0 {
+		return &configs[0], nil
+	}
+	return nil, errors.New("未找到可用的文本模型，请先在设置中配置 AI 服务")
+}
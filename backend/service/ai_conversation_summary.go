@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+// 会话解决状态
+const (
+	ResolutionResolved   = "resolved"
+	ResolutionUnresolved = "unresolved"
+	ResolutionFollowUp   = "follow_up"
+)
+
+const (
+	// 小结输入的对话记录上限（约 token），超出时保留开头与结尾
+	maxSummaryTranscriptTokens = 6000
+	// 关键实体数量上限
+	maxSummaryEntities = 10
+)
+
+// jsonObjectPattern 从模型输出中提取 JSON 对象（兼容前后带说明文字或代码块）
+var jsonObjectPattern = regexp.MustCompile(`(?s)\{.*\}`)
+
+// ConversationSummaryDraft 模型生成的会话小结
+type ConversationSummaryDraft struct {
+	Summary     string   `json:"summary"`
+	Category    string   `json:"category"`
+	Resolution  string   `json:"resolution"`
+	KeyEntities []string `json:"key_entities"`
+}
+
+// GenerateConversationSummary 让文本模型根据完整对话记录生成小结、分类、解决状态与关键实体。
+// categories 为管理员定义的分类（为空时不要求分类）。
+func (s *AIService) GenerateConversationSummary(ctx context.Context, conversation *models.Conversation, config *models.AIConfig, messages []models.Message, categories []models.ConversationCategory) (*ConversationSummaryDraft, *AIUsageSummary, error) {
+	if config == nil {
+		return nil, nil, errors.New("未配置会话小结使用的 AI 模型")
+	}
+	apiKey, err := utils.DecryptAPIKey(config.APIKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解密 API Key 失败: %v", err)
+	}
+	tracker := s.usageSvc.newTracker(conversation.ID)
+	provider, err := s.buildFailoverProvider(config, apiKey, tracker)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建 AI 提供商失败: %v", err)
+	}
+	defer s.logFailover(provider, conversation.ID, conversation.AgentID)
+
+	response, err := provider.GenerateResponse(nil, buildConversationSummaryPrompt(messages, categories), "", "")
+	if err != nil {
+		return nil, tracker.result(), err
+	}
+	draft, err := parseConversationSummary(response, categories)
+	return draft, tracker.result(), err
+}
+
+// buildConversationSummaryPrompt 小结指令：要求模型只输出 JSON 对象
+func buildConversationSummaryPrompt(messages []models.Message, categories []models.ConversationCategory) string {
+	var b strings.Builder
+	b.WriteString("以下是一段已结束的客服对话记录，请为客服主管生成会话小结。\n\n")
+	b.WriteString("【对话记录】\n")
+	b.WriteString(summaryTranscript(messages))
+	b.WriteString("\n\n要求：\n")
+	b.WriteString("1. summary：用一两句话概括访客的诉求与处理结果，不超过 150 字，不要寒暄。\n")
+	if len(categories) > 0 {
+		b.WriteString("2. category：从下列分类中选择最贴切的一个，原样输出分类名称；都不合适时输出空字符串。\n")
+		for _, c := range categories {
+			b.WriteString("   - ")
+			b.WriteString(c.Name)
+			if d := strings.TrimSpace(c.Description); d != "" {
+				b.WriteString("：")
+				b.WriteString(d)
+			}
+			b.WriteString("\n")
+		}
+	} else {
+		b.WriteString("2. category：输出空字符串。\n")
+	}
+	b.WriteString("3. resolution：resolved（问题已解决）、unresolved（未解决）或 follow_up（需后续跟进）。\n")
+	fmt.Fprintf(&b, "4. key_entities：对话中出现的关键实体（如订单号、账号、产品型号、金额、日期），最多 %d 个，没有则为空数组。\n", maxSummaryEntities)
+	b.WriteString("只输出 JSON 对象，例如 {\"summary\": \"...\", \"category\": \"...\", \"resolution\": \"resolved\", \"key_entities\": [\"...\"]}，不要输出其他内容。")
+	return b.String()
+}
+
+// summaryTranscript 将消息整理为「访客 / 客服 / AI：内容」的对话记录；过长时保留开头与结尾
+func summaryTranscript(messages []models.Message) string {
+	lines := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		content := strings.TrimSpace(msg.Content)
+		if content == "" && msg.FileName != nil {
+			content = "[文件] " + *msg.FileName
+		}
+		if content == "" {
+			continue
+		}
+		speaker := "访客"
+		switch {
+		case msg.MessageType == "system_message":
+			speaker = "系统"
+		case msg.SenderIsAgent && msg.ChatMode == "ai":
+			speaker = "AI"
+		case msg.SenderIsAgent:
+			speaker = "客服"
+		}
+		lines = append(lines, speaker+"："+content)
+	}
+
+	total := 0
+	for _, line := range lines {
+		total += utils.EstimateTokens(line)
+	}
+	if total <= maxSummaryTranscriptTokens {
+		return strings.Join(lines, "\n")
+	}
+	// 开头保留约 1/3 预算（最初的诉求），其余留给结尾（处理结果）
+	headBudget := maxSummaryTranscriptTokens / 3
+	head, used := 0, 0
+	for head < len(lines) && used+utils.EstimateTokens(lines[head]) <= headBudget {
+		used += utils.EstimateTokens(lines[head])
+		head++
+	}
+	tail, used := len(lines), 0
+	for tail > head && used+utils.EstimateTokens(lines[tail-1]) <= maxSummaryTranscriptTokens-headBudget {
+		used += utils.EstimateTokens(lines[tail-1])
+		tail--
+	}
+	out := append([]string{}, lines[:head]...)
+	out = append(out, "……（中间省略）……")
+	out = append(out, lines[tail:]...)
+	return strings.Join(out, "\n")
+}
+
+// parseConversationSummary 解析模型输出的 JSON，并将分类与解决状态规范到允许的取值
+func parseConversationSummary(response string, categories []models.ConversationCategory) (*ConversationSummaryDraft, error) {
+	text := strings.TrimSpace(response)
+	if m := jsonObjectPattern.FindString(text); m != "" {
+		text = m
+	}
+	var draft ConversationSummaryDraft
+	if err := json.Unmarshal([]byte(text), &draft); err != nil {
+		return nil, fmt.Errorf("解析会话小结失败: %v", err)
+	}
+	draft.Summary = strings.TrimSpace(draft.Summary)
+	if draft.Summary == "" {
+		return nil, errors.New("模型未返回会话小结")
+	}
+
+	category := strings.TrimSpace(draft.Category)
+	draft.Category = ""
+	for _, c := range categories {
+		if strings.EqualFold(c.Name, category) {
+			draft.Category = c.Name
+			break
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(draft.Resolution)) {
+	case ResolutionResolved:
+		draft.Resolution = ResolutionResolved
+	case ResolutionFollowUp, "follow-up", "followup":
+		draft.Resolution = ResolutionFollowUp
+	default:
+		draft.Resolution = ResolutionUnresolved
+	}
+
+	seen := make(map[string]bool)
+	entities := make([]string, 0, len(draft.KeyEntities))
+	for _, e := range draft.KeyEntities {
+		e = truncateRunes(strings.TrimSpace(e), 100)
+		if e == "" || seen[e] {
+			continue
+		}
+		seen[e] = true
+		entities = append(entities, e)
+		if len(entities) == maxSummaryEntities {
+			break
+		}
+	}
+	draft.KeyEntities = entities
+	return &draft, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+// 单次起草的草稿数量上限
+const maxCopilotDrafts = 3
+
+var copilotJSONArrayPattern = regexp.MustCompile(`(?s)\[\s*(\{.*\}|".*")\s*\]`)
+
+// CopilotDraft 一条候选回复及其引用来源
+type CopilotDraft struct {
+	Content   string
+	Citations []models.MessageCitation
+}
+
+// CopilotDraftsResult 一次起草的结果
+type CopilotDraftsResult struct {
+	Drafts     []CopilotDraft
+	AIConfigID uint
+	AIModel    string
+	Usage      *AIUsageSummary
+}
+
+// GenerateCopilotDrafts 为人工会话起草 1~3 条候选回复（供客服参考，不直接发送给访客）。
+// 使用会话历史（含滚动摘要）、FAQ 与知识库检索结果，草稿中以 [n] 标注引用。
+func (s *AIService) GenerateCopilotDrafts(ctx context.Context, conversation *models.Conversation, config *models.AIConfig, visitorMessage string) (*CopilotDraftsResult, error) {
+	if config == nil {
+		return nil, errors.New("未配置客服助手使用的 AI 模型")
+	}
+	apiKey, err := utils.DecryptAPIKey(config.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("解密 API Key 失败: %v", err)
+	}
+	tracker := s.usageSvc.newTracker(conversation.ID)
+	provider, err := s.buildFailoverProvider(config, apiKey, tracker)
+	if err != nil {
+		return nil, fmt.Errorf("创建 AI 提供商失败: %v", err)
+	}
+	defer s.logFailover(provider, conversation.ID, conversation.AgentID)
+
+	citations := &citationCollector{}
+	knowledge := ""
+	if s.retrievalService != nil && strings.TrimSpace(visitorMessage) != "" {
+		ragContext, faqHit, err := s.retrieveRAGContext(ctx, visitorMessage, conversation, citations)
+		if err != nil {
+			// 检索失败不影响起草，仅基于对话历史
+			ragContext = ""
+		}
+		if faqHit && ragContext != "" && citations.len() > 0 {
+			ragContext = fmt.Sprintf("[1] 《%s》\n%s", citations.items[0].Title, ragContext)
+		}
+		knowledge = ragContext
+	}
+
+	history, err := s.buildConversationHistory(conversation.ID, historyTokenBudget(config), provider)
+	if err != nil {
+		history = []MessageHistory{}
+	}
+	response, err := provider.GenerateResponse(history, buildCopilotPrompt(visitorMessage, knowledge), "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	drafts := parseCopilotDrafts(response)
+	if len(drafts) == 0 {
+		return nil, errors.New("模型未返回可用的草稿")
+	}
+	res := &CopilotDraftsResult{AIConfigID: config.ID, AIModel: config.Model}
+	if used := provider.Used(); used != nil {
+		res.AIConfigID = used.ConfigID
+		res.AIModel = used.Model
+	}
+	res.Usage = tracker.result()
+	for _, d := range drafts {
+		res.Drafts = append(res.Drafts, CopilotDraft{Content: d, Citations: citations.forAnswer(d)})
+	}
+	return res, nil
+}
+
+// buildCopilotPrompt 起草指令：以客服身份给出 1~3 条风格或侧重点不同的候选回复，按 JSON 字符串数组输出
+func buildCopilotPrompt(visitorMessage string, knowledge string) string {
+	var b strings.Builder
+	b.WriteString("你是人工客服的写作助手。以上是客服与访客的对话记录（assistant 为客服），请替客服起草下一条回复。\n\n")
+	if knowledge != "" {
+		b.WriteString("可参考的知识库内容：\n")
+		b.WriteString(knowledge)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("访客最新消息：")
+	b.WriteString(visitorMessage)
+	b.WriteString("\n\n要求：\n")
+	fmt.Fprintf(&b, "1. 给出 1~%d 条可直接发送的候选回复，彼此在措辞或侧重点上有所区别；信息不足时可包含向访客确认细节的回复。\n", maxCopilotDrafts)
+	b.WriteString("2. 以客服口吻，简洁、友好、专业；不要编造知识库中没有的政策、价格或承诺。\n")
+	if knowledge != "" {
+		b.WriteString("3. 使用知识库内容时，在相应句末用 [编号] 标注来源（编号即知识库内容前的 [n]）。\n")
+	}
+	b.WriteString("只输出 JSON 字符串数组，例如 [\"回复一\", \"回复二\"]，不要输出其他内容。")
+	return b.String()
+}
+
+// parseCopilotDrafts 解析模型输出的草稿列表；无法解析为 JSON 数组时将整段输出作为一条草稿
+func parseCopilotDrafts(response string) []string {
+	text := strings.TrimSpace(response)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	candidates := []string{text}
+	if m := copilotJSONArrayPattern.FindString(text); m != "" && m != text {
+		candidates = append(candidates, m)
+	}
+	for _, candidate := range candidates {
+		var list []string
+		if err := json.Unmarshal([]byte(candidate), &list); err != nil {
+			// 兼容 [{"reply": "..."}] 形式
+			var objects []map[string]interface{}
+			if json.Unmarshal([]byte(candidate), &objects) != nil {
+				continue
+			}
+			for _, obj := range objects {
+				for _, key := range []string{"reply", "content", "text"} {
+					if v, ok := obj[key].(string); ok {
+						list = append(list, v)
+						break
+					}
+				}
+			}
+		}
+		drafts := make([]string, 0, len(list))
+		for _, d := range list {
+			if d = strings.TrimSpace(d); d != "" {
+				drafts = append(drafts, d)
+			}
+			if len(drafts) == maxCopilotDrafts {
+				break
+			}
+		}
+		if len(drafts) > 0 {
+			return drafts
+		}
+	}
+	return []string{text}
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AIAPIError 模型接口返回非 200 状态码（保留状态码，用于判断是否需要故障转移）
+type AIAPIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *AIAPIError) Error() string {
+	return fmt.Sprintf("API 返回错误: %s (状态码: %d)", e.Body, e.StatusCode)
+}
+
+func newAIAPIError(statusCode int, body []byte) error {
+	return &AIAPIError{StatusCode: statusCode, Body: string(body)}
+}
+
+// isFailoverError 判断错误是否应切换到备用模型：超时、网络错误、429 与 5xx。
+// 4xx（鉴权、参数错误）与调用方主动取消不切换。
+func isFailoverError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var apiErr *AIAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == 429
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// 熔断默认参数：窗口内连续失败次数达到阈值即熔断，冷却期后放行一次探测请求
+const (
+	defaultCircuitFailureThreshold = 3
+	defaultCircuitWindow           = 60 * time.Second
+	defaultCircuitCooldown         = 60 * time.Second
+)
+
+type circuitState struct {
+	failures  []time.Time
+	openUntil time.Time
+	probing   bool // 冷却结束后的探测请求进行中（半开）
+}
+
+// AICircuitBreaker 按 AI 配置 ID 维护的熔断器（进程内）
+type AICircuitBreaker struct {
+	mu        sync.Mutex
+	states    map[uint]*circuitState
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+}
+
+// NewAICircuitBreaker 创建熔断器，参数可由环境变量
+// AI_CIRCUIT_FAILURE_THRESHOLD、AI_CIRCUIT_WINDOW_SECONDS、AI_CIRCUIT_COOLDOWN_SECONDS 覆盖。
+func NewAICircuitBreaker() *AICircuitBreaker {
+	return &AICircuitBreaker{
+		states:    make(map[uint]*circuitState),
+		threshold: envPositiveInt("AI_CIRCUIT_FAILURE_THRESHOLD", defaultCircuitFailureThreshold),
+		window:    time.Duration(envPositiveInt("AI_CIRCUIT_WINDOW_SECONDS", int(defaultCircuitWindow/time.Second))) * time.Second,
+		cooldown:  time.Duration(envPositiveInt("AI_CIRCUIT_COOLDOWN_SECONDS", int(defaultCircuitCooldown/time.Second))) * time.Second,
+	}
+}
+
+func envPositiveInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// Allow 是否可调用该配置：熔断冷却中返回 false；冷却结束后仅放行一个探测请求
+func (b *AICircuitBreaker) Allow(configID uint) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := b.states[configID]
+	if st == nil || st.openUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(st.openUntil) || st.probing {
+		return false
+	}
+	st.probing = true
+	return true
+}
+
+// RecordSuccess 调用成功：清空失败记录并关闭熔断
+func (b *AICircuitBreaker) RecordSuccess(configID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, configID)
+}
+
+// ReleaseProbe 探测请求被调用方取消或返回不可转移的错误（无法判断健康状况）：释放探测名额，下次请求重新探测
+func (b *AICircuitBreaker) ReleaseProbe(configID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if st := b.states[configID]; st != nil {
+		st.probing = false
+	}
+}
+
+// RecordFailure 记录一次可转移的失败，返回本次是否触发熔断
+func (b *AICircuitBreaker) RecordFailure(configID uint) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	st := b.states[configID]
+	if st == nil {
+		st = &circuitState{}
+		b.states[configID] = st
+	}
+	// 探测失败：立即重新熔断
+	if st.probing {
+		st.probing = false
+		st.openUntil = now.Add(b.cooldown)
+		log.Printf("⚠️ AI 配置 %d 探测失败，继续熔断 %s", configID, b.cooldown)
+		return true
+	}
+	kept := st.failures[:0]
+	for _, t := range st.failures {
+		if now.Sub(t) < b.window {
+			kept = append(kept, t)
+		}
+	}
+	st.failures = append(kept, now)
+	if len(st.failures) < b.threshold {
+		return false
+	}
+	st.failures = nil
+	st.openUntil = now.Add(b.cooldown)
+	log.Printf("⚠️ AI 配置 %d 在 %s 内失败 %d 次，熔断 %s", configID, b.window, b.threshold, b.cooldown)
+	return true
+}
+
+// failoverCandidate 故障转移链中的一个模型
+type failoverCandidate struct {
+	ConfigID uint
+	Model    string
+	Provider AIProvider
+}
+
+// failoverAttempt 一次失败的调用记录（用于写入系统日志）
+type failoverAttempt struct {
+	ConfigID    uint
+	Model       string
+	Error       string
+	CircuitOpen bool // 本次失败触发了熔断
+	Skipped     bool // 因熔断中被跳过，未实际调用
+}
+
+// failoverProvider 按顺序尝试候选模型的 AIProvider：遇到超时 / 5xx 切换下一个，熔断中的候选直接跳过。
+// 同一次回复生成内共用（摘要、工具调用、最终生成），记录实际作答的模型与失败轨迹。
+type failoverProvider struct {
+	candidates []failoverCandidate
+	breaker    *AICircuitBreaker
+
+	mu       sync.Mutex
+	used     *failoverCandidate
+	attempts []failoverAttempt
+}
+
+func newFailoverProvider(candidates []failoverCandidate, breaker *AICircuitBreaker) *failoverProvider {
+	return &failoverProvider{candidates: candidates, breaker: breaker}
+}
+
+// Used 返回最近一次成功作答的候选（尚未成功时为 nil）
+func (p *failoverProvider) Used() *failoverCandidate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.used
+}
+
+// Attempts 返回失败与跳过记录
+func (p *failoverProvider) Attempts() []failoverAttempt {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]failoverAttempt(nil), p.attempts...)
+}
+
+// run 依次调用候选；call 返回的错误为可转移错误且 retryable() 为 true 时切换下一个
+func (p *failoverProvider) run(call func(c failoverCandidate) error, retryable func() bool) error {
+	var lastErr error
+	for i := range p.candidates {
+		c := p.candidates[i]
+		if p.breaker != nil && !p.breaker.Allow(c.ConfigID) {
+			p.record(failoverAttempt{ConfigID: c.ConfigID, Model: c.Model, Skipped: true})
+			continue
+		}
+		err := call(c)
+		if errors.Is(err, context.Canceled) {
+			if p.breaker != nil {
+				p.breaker.ReleaseProbe(c.ConfigID)
+			}
+			return err
+		}
+		if err == nil {
+			if p.breaker != nil {
+				p.breaker.RecordSuccess(c.ConfigID)
+			}
+			p.mu.Lock()
+			p.used = &c
+			p.mu.Unlock()
+			return nil
+		}
+		if !isFailoverError(err) {
+			// 4xx（鉴权、参数错误）等：不计入熔断，也不清空失败记录；仅释放探测名额
+			if p.breaker != nil {
+				p.breaker.ReleaseProbe(c.ConfigID)
+			}
+			return err
+		}
+		tripped := false
+		if p.breaker != nil {
+			tripped = p.breaker.RecordFailure(c.ConfigID)
+		}
+		p.record(failoverAttempt{ConfigID: c.ConfigID, Model: c.Model, Error: err.Error(), CircuitOpen: tripped})
+		lastErr = err
+		if retryable != nil && !retryable() {
+			return err
+		}
+		if i < len(p.candidates)-1 {
+			log.Printf("⚠️ AI 配置 %d (%s) 调用失败: %v，切换备用模型", c.ConfigID, c.Model, err)
+		}
+	}
+	if lastErr == nil {
+		return errors.New("所有模型暂不可用（熔断中）")
+	}
+	return lastErr
+}
+
+func (p *failoverProvider) record(a failoverAttempt) {
+	p.mu.Lock()
+	p.attempts = append(p.attempts, a)
+	p.mu.Unlock()
+}
+
+func (p *failoverProvider) GenerateResponse(conversationHistory []MessageHistory, userMessage string, imageBase64 string, imageMimeType string) (string, error) {
+	var out string
+	err := p.run(func(c failoverCandidate) error {
+		var err error
+		out, err = c.Provider.GenerateResponse(conversationHistory, userMessage, imageBase64, imageMimeType)
+		return err
+	}, nil)
+	return out, err
+}
+
+// GenerateResponseContext 与 GenerateResponse 相同（纯文本），但 ctx 结束时立即返回 ctx.Err()；已发出的请求在后台自然结束。
+// 用于翻译、FAQ 确认等需要限时、且底层 provider 不支持 ctx 的短调用。
+func (p *failoverProvider) GenerateResponseContext(ctx context.Context, conversationHistory []MessageHistory, userMessage string) (string, error) {
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := p.GenerateResponse(conversationHistory, userMessage, "", "")
+		done <- result{out, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-done:
+		return r.out, r.err
+	}
+}
+
+func (p *failoverProvider) GenerateResponseWithTools(messages []map[string]interface{}, tools []map[string]interface{}) (content string, toolCalls []ToolCall, err error) {
+	err = p.run(func(c failoverCandidate) error {
+		var callErr error
+		content, toolCalls, callErr = c.Provider.GenerateResponseWithTools(messages, tools)
+		return callErr
+	}, nil)
+	return content, toolCalls, err
+}
+
+// GenerateResponseStream 已向访客推送过增量后不再切换（避免两个模型的内容拼接），直接返回部分内容与错误
+func (p *failoverProvider) GenerateResponseStream(ctx context.Context, conversationHistory []MessageHistory, userMessage string, imageBase64 string, imageMimeType string, onDelta func(delta string)) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// 增量回调与 retryable 判断可能处于不同 goroutine
+	var emitted atomic.Bool
+	wrapped := func(delta string) {
+		emitted.Store(true)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	}
+	var out string
+	err := p.run(func(c failoverCandidate) error {
+		var err error
+		out, err = c.Provider.GenerateResponseStream(ctx, conversationHistory, userMessage, imageBase64, imageMimeType, wrapped)
+		return err
+	}, func() bool {
+		return !emitted.Load() && ctx.Err() == nil
+	})
+	return out, err
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/service/rag"
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+const (
+	// FAQ 向量检索的候选数（检索已限定为 source_type=faq 的向量）
+	faqMatchCandidates = 20
+	// 作为参考资料交给模型的相近 FAQ 最大条数
+	faqContextMaxItems = 3
+	// 直接回答前模型确认的超时时间（超时视为未确认）
+	faqVerifyTimeout = 10 * time.Second
+)
+
+// faqCandidate 一条语义匹配到的 FAQ 及其相似度
+type faqCandidate struct {
+	FAQ   models.FAQ
+	Score float32
+}
+
+// faqMatchResult FAQ 匹配结果：Direct 为可直接返回标准答案的 FAQ，Related 为作为参考资料的相近 FAQ
+type faqMatchResult struct {
+	Direct  *faqCandidate
+	Related []faqCandidate
+}
+
+// matchFAQ 将用户查询与 FAQ 做匹配（kbScope 非空时仅匹配范围内及全局 FAQ）：
+//  1. 问题原文完全一致时直接命中；
+//  2. 否则按向量相似度：最高分 ≥ 直接回答阈值（开启确认时还需模型认可）直接命中，
+//     其余 ≥ 参考阈值的作为参考资料交给模型，不原样返回；
+//  3. 向量库未启用或检索失败时退回关键词 / 子串匹配，命中的 FAQ 仅作为参考资料，不直接回答。
+func (s *AIService) matchFAQ(ctx context.Context, query string, conversation *models.Conversation, kbScope []uint) faqMatchResult {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return faqMatchResult{}
+	}
+	if faq, err := s.faqRepo.FindByQuestion(query, kbScope); err == nil && faq != nil {
+		return faqMatchResult{Direct: &faqCandidate{FAQ: *faq, Score: 1}}
+	}
+
+	candidates, err := s.searchFAQCandidates(ctx, query, kbScope)
+	if err != nil {
+		log.Printf("⚠️ FAQ 语义匹配不可用，改用关键词匹配（仅作参考资料）: %v", err)
+		return faqMatchResult{Related: s.keywordMatchFAQ(query, kbScope)}
+	}
+	cfg := FAQMatchConfig{MatchThreshold: DefaultFAQMatchThreshold, ContextThreshold: DefaultFAQContextThreshold}
+	if s.embeddingConfigSvc != nil {
+		cfg = s.embeddingConfigSvc.GetFAQMatchConfig()
+	}
+
+	var out faqMatchResult
+	for i := range candidates {
+		c := candidates[i]
+		score := float64(c.Score)
+		if i == 0 && score >= cfg.MatchThreshold && (!cfg.VerifyEnabled || s.verifyFAQ(ctx, query, conversation, &c.FAQ)) {
+			return faqMatchResult{Direct: &c}
+		}
+		if score >= cfg.ContextThreshold && len(out.Related) < faqContextMaxItems {
+			out.Related = append(out.Related, c)
+		}
+	}
+	return out
+}
+
+// searchFAQCandidates 在范围内的 FAQ 向量中检索（由 Milvus 按 source_type 与知识库过滤），
+// 仅按命中的 ID 读取 FAQ，按相似度降序返回；已删除或已移出范围的 FAQ 跳过。
+func (s *AIService) searchFAQCandidates(ctx context.Context, query string, kbScope []uint) ([]faqCandidate, error) {
+	if s.retrievalService == nil {
+		return nil, errors.New("检索服务未初始化")
+	}
+	var kbIDs []uint
+	if len(kbScope) > 0 {
+		// 未归属知识库的全局 FAQ 向量以 knowledge_base_id=0 存储
+		kbIDs = append(append([]uint{}, kbScope...), 0)
+	}
+	results, err := s.retrievalService.FAQVectorSearch(ctx, query, faqMatchCandidates, kbIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[uint]float32, len(results))
+	ids := make([]uint, 0, len(results))
+	for _, r := range results {
+		id, err := strconv.ParseUint(r.DocumentID, 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := scores[uint(id)]; ok {
+			continue
+		}
+		scores[uint(id)] = r.Score
+		ids = append(ids, uint(id))
+	}
+	faqs, err := s.faqRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]faqCandidate, 0, len(faqs))
+	for _, faq := range faqs {
+		if !faqInScope(&faq, kbScope) {
+			continue
+		}
+		out = append(out, faqCandidate{FAQ: faq, Score: scores[faq.ID]})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out, nil
+}
+
+// keywordMatchFAQ 关键词 / 子串匹配（向量库不可用时的兜底），最多返回 faqContextMaxItems 条，仅作为参考资料
+func (s *AIService) keywordMatchFAQ(query string, kbScope []uint) []faqCandidate {
+	faqs, err := s.faqRepo.MatchQuery(query, kbScope, faqContextMaxItems)
+	if err != nil {
+		log.Printf("⚠️ FAQ 关键词匹配失败: %v", err)
+		return nil
+	}
+	out := make([]faqCandidate, len(faqs))
+	for i, faq := range faqs {
+		out[i] = faqCandidate{FAQ: faq}
+	}
+	return out
+}
+
+// faqInScope FAQ 是否在知识库范围内（全局 FAQ 始终在范围内；kbScope 为空表示不限）
+func faqInScope(faq *models.FAQ, kbScope []uint) bool {
+	if len(kbScope) == 0 || faq.KnowledgeBaseID == nil || *faq.KnowledgeBaseID == 0 {
+		return true
+	}
+	for _, id := range kbScope {
+		if id == *faq.KnowledgeBaseID {
+			return true
+		}
+	}
+	return false
+}
+
+// isFAQVector 向量结果是否为 FAQ 向量（而非同 ID 的文档向量）
+func isFAQVector(r rag.SearchResult) bool {
+	return r.Source.SourceType == rag.SourceTypeFAQ
+}
+
+// verifyFAQ 由模型确认 FAQ 标准答案能否直接回答用户问题；调用失败或超时视为未确认（转为参考资料）
+func (s *AIService) verifyFAQ(ctx context.Context, query string, conversation *models.Conversation, faq *models.FAQ) bool {
+	var (
+		preferred []uint
+		convID    uint
+		agentID   uint
+	)
+	if conversation != nil {
+		convID, agentID = conversation.ID, conversation.AgentID
+		if conversation.AIConfigID != nil {
+			preferred = append(preferred, *conversation.AIConfigID)
+		}
+	}
+	config, err := resolveTextAIConfig(s.aiConfigRepo, preferred, agentID)
+	if err != nil {
+		return false
+	}
+	apiKey, err := utils.DecryptAPIKey(config.APIKey)
+	if err != nil {
+		return false
+	}
+	provider, err := s.buildFailoverProvider(config, apiKey, s.usageSvc.newTracker(convID))
+	if err != nil {
+		return false
+	}
+	defer s.logFailover(provider, convID, 0)
+
+	verifyCtx, cancel := context.WithTimeout(ctx, faqVerifyTimeout)
+	defer cancel()
+	response, err := provider.GenerateResponseContext(verifyCtx, nil, buildFAQVerifyPrompt(query, faq))
+	if err != nil {
+		log.Printf("⚠️ FAQ 命中确认失败: faq_id=%d err=%v", faq.ID, err)
+		return false
+	}
+	return parseFAQVerdict(response)
+}
+
+func buildFAQVerifyPrompt(query string, faq *models.FAQ) string {
+	return fmt.Sprintf(`判断下面的常见问题（FAQ）标准答案能否直接、完整地回答用户的问题。
+只有当用户问的就是这个 FAQ 所述的事情时才回答 yes；话题相近但问的是别的方面、或答案只能部分回答时回答 no。
+只输出 yes 或 no。
+
+用户问题：%s
+
+FAQ 问题：%s
+FAQ 答案：%s`, query, faq.Question, truncateRunes(faq.Answer, 1000))
+}
+
+// parseFAQVerdict 解析模型的确认结果（yes / 是 视为确认）
+func parseFAQVerdict(response string) bool {
+	v := strings.ToLower(strings.TrimSpace(response))
+	v = strings.TrimLeft(v, "`*\"'「【 \n")
+	return strings.HasPrefix(v, "yes") || strings.HasPrefix(v, "是") || strings.HasPrefix(v, "true")
+}
+
+// addFAQContext 将相近 FAQ 登记为引用来源，返回带编号的参考资料文本
+func (s *AIService) addFAQContext(c *citationCollector, related []faqCandidate) string {
+	parts := make([]string, 0, len(related))
+	for _, r := range related {
+		kbID := uint(0)
+		if r.FAQ.KnowledgeBaseID != nil {
+			kbID = *r.FAQ.KnowledgeBaseID
+		}
+		citation := c.add(models.MessageCitation{
+			SourceType:      "faq",
+			DocumentID:      r.FAQ.ID,
+			KnowledgeBaseID: kbID,
+			Title:           r.FAQ.Question,
+			Score:           r.Score,
+			Snippet:         truncateRunes(strings.TrimSpace(r.FAQ.Answer), maxCitationSnippetRunes),
+		})
+		parts = append(parts, fmt.Sprintf("[%d] 《常见问题：%s》\n%s", citation.Index, r.FAQ.Question, r.FAQ.Answer))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// excludeFAQResults 去掉知识库检索结果中已作为参考资料加入的 FAQ 向量，避免重复
+func excludeFAQResults(results []rag.SearchResult, related []faqCandidate) []rag.SearchResult {
+	if len(related) == 0 {
+		return results
+	}
+	out := make([]rag.SearchResult, 0, len(results))
+	for _, r := range results {
+		duplicate := false
+		for i := range related {
+			if r.DocumentID == strconv.FormatUint(uint64(related[i].FAQ.ID), 10) && isFAQVector(r) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			out = append(out, r)
+		}
+	}
+	return out
+}
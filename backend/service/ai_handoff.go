@@ -0,0 +1,102 @@
+package service
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	// handoffToolName function calling 场景下供模型调用的转人工工具
+	handoffToolName = "transfer_to_human"
+	// handoffMarker 普通生成场景下模型表达转人工意图的标记（不会推送给访客）
+	handoffMarker = "[TRANSFER_TO_HUMAN]"
+	// handoffInstruction 作为 system 消息告知模型何时转人工
+	handoffInstruction = "如果用户明确要求人工客服，或问题必须由人工处理（如投诉、退款审核、账号安全、你无法确认的个案信息）且你无法解决，" +
+		"请在回复末尾输出标记 " + handoffMarker + "（可在标记前用一句话告知用户正在转接人工）；其他情况不要输出该标记。"
+	// defaultHandoffReply 模型只输出了转人工标记时的默认回复
+	defaultHandoffReply = "正在为您转接人工客服，请稍候。"
+)
+
+// handoffToolDefinition 转人工工具定义（OpenAI function 格式）
+func handoffToolDefinition() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        handoffToolName,
+			"description": "Transfer the conversation to a human support agent. Call when the user explicitly asks for a human, or the issue requires human handling and you cannot resolve it.",
+			"parameters": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"reason": map[string]string{"type": "string", "description": "Short reason for the transfer"},
+				},
+			},
+		},
+	}
+}
+
+// extractHandoffMarker 去除回复中的转人工标记，返回清理后的文本与是否包含标记
+func extractHandoffMarker(content string) (string, bool) {
+	if !strings.Contains(content, handoffMarker) {
+		return content, false
+	}
+	return strings.TrimSpace(strings.ReplaceAll(content, handoffMarker, "")), true
+}
+
+// applyHandoffIntent 识别回复中的转人工标记：去除标记并标记 HandoffRequested（仅有标记时使用默认转接话术）
+func applyHandoffIntent(result *GenerateAIResponseResult, enabled bool) *GenerateAIResponseResult {
+	if !enabled || result == nil {
+		return result
+	}
+	content, hit := extractHandoffMarker(result.Content)
+	if !hit {
+		return result
+	}
+	if content == "" {
+		content = defaultHandoffReply
+	}
+	result.Content = content
+	result.HandoffRequested = true
+	return result
+}
+
+// newHandoffMarkerFilter 包装流式增量回调：过滤转人工标记，
+// 并暂存可能是标记前缀的尾部文本，避免标记片段被推送给访客。flush 推送剩余的暂存文本。
+func newHandoffMarkerFilter(onDelta func(string)) (filtered func(string), flush func()) {
+	var (
+		mu      sync.Mutex
+		pending string
+	)
+	filtered = func(delta string) {
+		mu.Lock()
+		defer mu.Unlock()
+		pending = strings.ReplaceAll(pending+delta, handoffMarker, "")
+		hold := markerPrefixSuffixLen(pending)
+		if emit := pending[:len(pending)-hold]; emit != "" {
+			onDelta(emit)
+		}
+		pending = pending[len(pending)-hold:]
+	}
+	flush = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if pending != "" {
+			onDelta(pending)
+			pending = ""
+		}
+	}
+	return filtered, flush
+}
+
+// markerPrefixSuffixLen 返回 s 的最长后缀长度，该后缀同时是 handoffMarker 的真前缀
+func markerPrefixSuffixLen(s string) int {
+	max := len(handoffMarker) - 1
+	if len(s) < max {
+		max = len(s)
+	}
+	for n := max; n > 0; n-- {
+		if strings.HasPrefix(handoffMarker, s[len(s)-n:]) {
+			return n
+		}
+	}
+	return 0
+}
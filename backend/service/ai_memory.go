@@ -0,0 +1,175 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+const (
+	// defaultHistoryTokenBudget AI 配置未设置预算时的对话历史 token 上限
+	defaultHistoryTokenBudget = 3000
+	// minRecentHistoryMessages 无论预算多紧，至少保留的最近消息条数（一问一答）
+	minRecentHistoryMessages = 2
+	// historySummaryPrefix 摘要作为历史首条 system 消息发送时的前缀
+	historySummaryPrefix = "以下是本次对话中较早内容的摘要，请结合摘要理解后续对话：\n"
+)
+
+// historyTokenBudget 返回 AI 配置的对话历史 token 预算
+func historyTokenBudget(config *models.AIConfig) int {
+	if config != nil && config.HistoryTokenBudget > 0 {
+		return config.HistoryTokenBudget
+	}
+	return defaultHistoryTokenBudget
+}
+
+// buildConversationHistory 构建对话历史（用于 AI 上下文）。
+// 在 token 预算内发送「滚动摘要 + 最近轮次」：超出预算时，将较早且尚未摘要的轮次交给同一 provider
+// 增量合并进会话摘要（压缩到约半个预算，避免每轮都触发摘要）；摘要失败时退化为仅保留预算内的最近轮次。
+func (s *AIService) buildConversationHistory(conversationID uint, budget int, provider AIProvider) ([]MessageHistory, error) {
+	messages, err := s.messageRepo.ListByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if budget <= 0 {
+		budget = defaultHistoryTokenBudget
+	}
+
+	var memory *models.ConversationMemory
+	if s.memoryRepo != nil {
+		memory, err = s.memoryRepo.GetByConversationID(conversationID)
+		if err != nil {
+			log.Printf("⚠️ 读取会话摘要失败: conversation_id=%d err=%v", conversationID, err)
+			memory = nil
+		}
+	}
+	summary := ""
+	var summarizedUntil uint
+	if memory != nil {
+		summary = memory.Summary
+		summarizedUntil = memory.SummarizedUntilMessageID
+	}
+
+	// 尚未压缩进摘要的消息（跳过系统消息与客服内部备注）
+	pending := make([]models.Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.MessageType == "system_message" || msg.MessageType == models.MessageTypeWhisper || msg.ID <= summarizedUntil {
+			continue
+		}
+		pending = append(pending, msg)
+	}
+
+	summaryTokens := utils.EstimateTokens(summary)
+	if summaryTokens+messagesTokens(pending) <= budget {
+		return withHistorySummary(summary, pending), nil
+	}
+
+	// 超出预算：保留约半个预算的最近轮次，其余合并进摘要
+	keep := recentMessagesWithin(pending, budget/2)
+	overflow := pending[:len(pending)-keep]
+	if s.memoryRepo != nil && provider != nil && len(overflow) > 0 {
+		newSummary, err := s.summarizeHistory(provider, summary, overflow, budget/4)
+		if err == nil {
+			if memory == nil {
+				memory = &models.ConversationMemory{ConversationID: conversationID}
+			}
+			memory.Summary = newSummary
+			memory.SummarizedUntilMessageID = overflow[len(overflow)-1].ID
+			if err := s.memoryRepo.Save(memory); err != nil {
+				log.Printf("⚠️ 保存会话摘要失败: conversation_id=%d err=%v", conversationID, err)
+			}
+			return withHistorySummary(newSummary, pending[len(pending)-keep:]), nil
+		}
+		log.Printf("⚠️ 压缩对话历史失败，仅保留最近轮次: conversation_id=%d err=%v", conversationID, err)
+	}
+
+	// 无法摘要：沿用旧摘要，最近轮次按剩余预算截断
+	keep = recentMessagesWithin(pending, budget-summaryTokens)
+	return withHistorySummary(summary, pending[len(pending)-keep:]), nil
+}
+
+// summarizeHistory 将已有摘要与新溢出的轮次合并为新的摘要
+func (s *AIService) summarizeHistory(provider AIProvider, previous string, overflow []models.Message, maxTokens int) (string, error) {
+	var b strings.Builder
+	b.WriteString("请将下面的客服对话内容压缩为一段简洁的中文摘要，供后续回复时参考。\n")
+	b.WriteString("要求：保留用户最初的问题与诉求、关键事实（如订单号、账号、产品型号、时间、金额）、已给出的答复或尝试过的方案、仍未解决的事项；")
+	b.WriteString("不要编造，不要寒暄，直接输出摘要正文")
+	if maxTokens > 0 {
+		b.WriteString(fmt.Sprintf("，长度不超过约 %d 字", maxTokens))
+	}
+	b.WriteString("。\n\n")
+	if strings.TrimSpace(previous) != "" {
+		b.WriteString("【已有摘要】\n")
+		b.WriteString(previous)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("【新增对话】\n")
+	for _, msg := range overflow {
+		speaker := "用户"
+		if msg.SenderIsAgent {
+			speaker = "客服"
+		}
+		b.WriteString(speaker)
+		b.WriteString("：")
+		b.WriteString(msg.Content)
+		b.WriteString("\n")
+	}
+
+	summary, err := provider.GenerateResponse(nil, b.String(), "", "")
+	if err != nil {
+		return "", err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", fmt.Errorf("摘要为空")
+	}
+	return summary, nil
+}
+
+// messagesTokens 估算消息列表的 token 总数
+func messagesTokens(messages []models.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += utils.EstimateTokens(msg.Content)
+	}
+	return total
+}
+
+// recentMessagesWithin 返回在预算内可保留的最近消息条数（至少 minRecentHistoryMessages 条）
+func recentMessagesWithin(messages []models.Message, budget int) int {
+	keep, used := 0, 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		cost := utils.EstimateTokens(messages[i].Content)
+		if used+cost > budget && keep >= minRecentHistoryMessages {
+			break
+		}
+		used += cost
+		keep++
+	}
+	return keep
+}
+
+// withHistorySummary 组装发送给模型的历史：摘要（如有）作为首条 system 消息，其后为原文轮次
+func withHistorySummary(summary string, messages []models.Message) []MessageHistory {
+	history := make([]MessageHistory, 0, len(messages)+1)
+	if strings.TrimSpace(summary) != "" {
+		history = append(history, MessageHistory{
+			Role:    "system",
+			Content: historySummaryPrefix + summary,
+		})
+	}
+	for _, msg := range messages {
+		role := "user"
+		if msg.SenderIsAgent {
+			role = "assistant"
+		}
+		history = append(history, MessageHistory{
+			Role:    role,
+			Content: msg.Content,
+		})
+	}
+	return history
+}
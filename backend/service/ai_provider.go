@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -22,6 +23,9 @@ type AIProvider interface {
 	// GenerateResponseWithTools 带工具调用的生成；messages 与 tools 为 OpenAI 格式。返回 content、tool_calls、error。
 	// 若某实现不支持，可返回 ( "", nil, err ) 或仅返回 content。
 	GenerateResponseWithTools(messages []map[string]interface{}, tools []map[string]interface{}) (content string, toolCalls []ToolCall, err error)
+	// GenerateResponseStream 流式生成：每收到一段增量文本回调 onDelta，结束后返回完整文本。
+	// ctx 取消时应尽快返回已生成的部分文本与 ctx.Err()。
+	GenerateResponseStream(ctx context.Context, conversationHistory []MessageHistory, userMessage string, imageBase64 string, imageMimeType string, onDelta func(delta string)) (string, error)
 }
 
 // AdapterConfig 适配器配置（用于适配不同服务商的 API 格式差异）
@@ -54,7 +58,9 @@ type AIConfig struct {
 	Model         string
 	ModelType     string
 	Provider      string
+	Protocol      string         // 接口协议：openai / anthropic / gemini（空值按 openai）
 	AdapterConfig *AdapterConfig // 适配器配置（用于适配不同服务商的差异）
+	OnUsage       UsageRecorder  // 可选，每次调用结束后回调 token 用量（计费统计）
 }
 
 // UniversalAIProvider 通用 AI 服务提供商（支持所有 OpenAI 兼容格式）
@@ -187,7 +193,7 @@ func (p *UniversalAIProvider) generateTextResponse(conversationHistory []Message
 	if err != nil {
 		log.Printf("⚠️ AI generateTextResponse 请求失败: config.api_url=%s 实际 req.URL=%s err=%v",
 			p.config.APIURL, req.URL.String(), err)
-		return "", fmt.Errorf("请求失败: %v", err)
+		return "", fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -199,7 +205,7 @@ func (p *UniversalAIProvider) generateTextResponse(conversationHistory []Message
 
 	// 检查 HTTP 状态码
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API 返回错误: %s (状态码: %d)", string(body), resp.StatusCode)
+		return "", newAIAPIError(resp.StatusCode, body)
 	}
 
 	// 解析响应（支持灵活的响应路径）
@@ -224,6 +230,7 @@ func (p *UniversalAIProvider) generateTextResponse(conversationHistory []Message
 	if content == "" {
 		return "", errors.New("API 返回空内容")
 	}
+	p.config.reportUsage(UsageKindChat, parseUsage(responseData), messages, content)
 
 	return content, nil
 }
@@ -278,7 +285,7 @@ func (p *UniversalAIProvider) GenerateResponseWithTools(messages []map[string]in
 	if err != nil {
 		log.Printf("⚠️ AI GenerateResponseWithTools 请求失败: config.api_url=%s 实际 req.URL=%s err=%v",
 			p.config.APIURL, req.URL.String(), err)
-		return "", nil, fmt.Errorf("请求失败: %v", err)
+		return "", nil, fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
@@ -286,7 +293,7 @@ func (p *UniversalAIProvider) GenerateResponseWithTools(messages []map[string]in
 		return "", nil, fmt.Errorf("读取响应失败: %v", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", nil, fmt.Errorf("API 返回错误: %s (状态码: %d)", string(body), resp.StatusCode)
+		return "", nil, newAIAPIError(resp.StatusCode, body)
 	}
 	var responseData map[string]interface{}
 	if err := json.Unmarshal(body, &responseData); err != nil {
@@ -298,6 +305,7 @@ func (p *UniversalAIProvider) GenerateResponseWithTools(messages []map[string]in
 		}
 	}
 	content, toolCalls = p.extractContentAndToolCalls(responseData)
+	p.config.reportUsage(UsageKindTools, parseUsage(responseData), messages, content)
 	return content, toolCalls, nil
 }
 
@@ -470,7 +478,16 @@ type ImageGenerationProvider interface {
 }
 
 // CreateProvider 根据配置创建对应的 AI 提供商。
+// Protocol 为 anthropic / gemini 的文本模型走原生协议；其余（含生图模型）走 OpenAI 兼容的 UniversalAIProvider。
 func (f *AIProviderFactory) CreateProvider(config AIConfig) (AIProvider, error) {
+	if config.ModelType == "text" {
+		switch strings.ToLower(strings.TrimSpace(config.Protocol)) {
+		case ProtocolAnthropic:
+			return NewAnthropicProvider(config), nil
+		case ProtocolGemini:
+			return NewGeminiProvider(config), nil
+		}
+	}
 	return NewUniversalAIProvider(config), nil
 }
 
@@ -549,9 +566,16 @@ func (p *UniversalAIProvider) GenerateImage(prompt string) (imageData []byte, mi
 		return nil, "", fmt.Errorf("生图 API 错误: %s (状态码: %d)", string(data), resp.StatusCode)
 	}
 	if useGemini {
-		return p.parseGeminiImageResponse(data)
+		imageData, mimeType, err = p.parseGeminiImageResponse(data)
+	} else {
+		imageData, mimeType, err = p.parseOpenAIImageResponse(data)
+	}
+	if err == nil {
+		usage := parseUsageJSON(data)
+		usage.Images = 1
+		p.config.reportUsage(UsageKindImage, usage, nil, "")
 	}
-	return p.parseOpenAIImageResponse(data)
+	return imageData, mimeType, err
 }
 
 // parseGeminiImageResponse 解析 Poixe/Gemini Content 生图响应：candidates[0].content.parts 中的 inlineData
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	anthropicAPIVersion       = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+	anthropicDefaultURL       = "https://api.anthropic.com/v1/messages"
+)
+
+// AnthropicProvider Anthropic Messages API 原生实现（/v1/messages）。
+// 系统提示走顶层 system 字段，图片为 base64 image 块，工具调用映射为 tool_use / tool_result 块。
+type AnthropicProvider struct {
+	config AIConfig
+	client *http.Client
+}
+
+// NewAnthropicProvider 创建 Anthropic 提供商实例。
+func NewAnthropicProvider(config AIConfig) *AnthropicProvider {
+	return &AnthropicProvider{
+		config: config,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// endpoint 返回 Messages 接口地址：api_url 可填完整地址，也可只填域名或 /v1 前缀
+func (p *AnthropicProvider) endpoint() string {
+	url := strings.TrimRight(strings.TrimSpace(p.config.APIURL), "/")
+	switch {
+	case url == "":
+		return anthropicDefaultURL
+	case strings.HasSuffix(url, "/messages"):
+		return url
+	case strings.HasSuffix(url, "/v1"):
+		return url + "/messages"
+	default:
+		return url + "/v1/messages"
+	}
+}
+
+func (p *AnthropicProvider) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.config.APIKey,
+		"anthropic-version": anthropicAPIVersion,
+	}
+}
+
+// buildRequest 将 OpenAI 格式消息与工具映射为 Messages API 请求体
+func (p *AnthropicProvider) buildRequest(messages []map[string]interface{}, tools []map[string]interface{}) map[string]interface{} {
+	system, turns := normalizeOpenAIMessages(messages)
+	body := map[string]interface{}{
+		"model":      p.config.Model,
+		"max_tokens": anthropicDefaultMaxTokens,
+		"messages":   anthropicMessages(turns),
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	functions, webSearch := parseOpenAITools(tools)
+	var toolDefs []map[string]interface{}
+	for _, fn := range functions {
+		toolDefs = append(toolDefs, map[string]interface{}{
+			"name":         fn.Name,
+			"description":  fn.Description,
+			"input_schema": fn.Parameters,
+		})
+	}
+	if webSearch {
+		// 厂商内置联网：由 Anthropic 服务端执行搜索，结果直接体现在回复文本中
+		toolDefs = append(toolDefs, map[string]interface{}{"type": "web_search_20250305", "name": "web_search"})
+	}
+	if len(toolDefs) > 0 {
+		body["tools"] = toolDefs
+	}
+	applyRequestFormat(body, p.config.AdapterConfig)
+	return body
+}
+
+// anthropicMessages 将中间消息转为 user / assistant 交替的内容块数组（工具结果归入 user，相邻同角色合并）
+func anthropicMessages(turns []nativeTurn) []map[string]interface{} {
+	var out []map[string]interface{}
+	appendBlocks := func(role string, blocks []map[string]interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1]["role"] == role {
+			out[n-1]["content"] = append(out[n-1]["content"].([]map[string]interface{}), blocks...)
+			return
+		}
+		out = append(out, map[string]interface{}{"role": role, "content": blocks})
+	}
+	for _, turn := range turns {
+		var blocks []map[string]interface{}
+		switch turn.Role {
+		case "tool":
+			blocks = append(blocks, map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": turn.ToolCallID,
+				"content":     turn.Text,
+			})
+			appendBlocks("user", blocks)
+			continue
+		case "user":
+			for _, img := range turn.Images {
+				blocks = append(blocks, map[string]interface{}{
+					"type":   "image",
+					"source": map[string]interface{}{"type": "base64", "media_type": img.MimeType, "data": img.Data},
+				})
+			}
+		}
+		if strings.TrimSpace(turn.Text) != "" {
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": turn.Text})
+		}
+		for _, tc := range turn.ToolCalls {
+			blocks = append(blocks, map[string]interface{}{
+				"type":  "tool_use",
+				"id":    tc.ID,
+				"name":  tc.Name,
+				"input": parseToolArguments(tc.Arguments),
+			})
+		}
+		appendBlocks(turn.Role, blocks)
+	}
+	// Messages API 要求首条为 user
+	if len(out) > 0 && out[0]["role"] != "user" {
+		out = append([]map[string]interface{}{{
+			"role":    "user",
+			"content": []map[string]interface{}{{"type": "text", "text": "（继续之前的对话）"}},
+		}}, out...)
+	}
+	return out
+}
+
+// anthropicResponse Messages API 非流式响应
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		ID    string          `json:"id"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// parseAnthropicResponse 提取文本块与 tool_use 块（服务端工具 server_tool_use 由厂商执行，忽略）
+func parseAnthropicResponse(data []byte) (content string, toolCalls []ToolCall, err error) {
+	var parsed anthropicResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	if parsed.Error != nil {
+		return "", nil, fmt.Errorf("API 错误: %s", parsed.Error.Message)
+	}
+	var texts []string
+	for _, block := range parsed.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" || args == "null" {
+				args = "{}"
+			}
+			toolCalls = append(toolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: args})
+		}
+	}
+	return strings.Join(texts, ""), toolCalls, nil
+}
+
+// GenerateResponse 生成 AI 回复（支持系统提示与当前消息带图）。
+func (p *AnthropicProvider) GenerateResponse(conversationHistory []MessageHistory, userMessage string, imageBase64 string, imageMimeType string) (string, error) {
+	if p.config.ModelType != "text" {
+		return "", fmt.Errorf("Anthropic 提供商仅支持 text 模型")
+	}
+	messages := buildOpenAIMessages(conversationHistory, userMessage, imageBase64, imageMimeType)
+	data, err := postNativeJSON(context.Background(), p.client, p.endpoint(), p.headers(), p.buildRequest(messages, nil))
+	if err != nil {
+		return "", err
+	}
+	content, _, err := parseAnthropicResponse(data)
+	if err != nil {
+		return "", err
+	}
+	if content == "" {
+		return "", errors.New("API 返回空内容")
+	}
+	p.config.reportUsage(UsageKindChat, parseUsageJSON(data), messages, content)
+	return content, nil
+}
+
+// GenerateResponseWithTools 带工具调用的生成；messages 与 tools 为 OpenAI 格式，内部映射为 tool_use / tool_result。
+func (p *AnthropicProvider) GenerateResponseWithTools(messages []map[string]interface{}, tools []map[string]interface{}) (content string, toolCalls []ToolCall, err error) {
+	if p.config.ModelType != "text" {
+		return "", nil, fmt.Errorf("带工具调用仅支持 text 模型")
+	}
+	data, err := postNativeJSON(context.Background(), p.client, p.endpoint(), p.headers(), p.buildRequest(messages, tools))
+	if err != nil {
+		return "", nil, err
+	}
+	content, toolCalls, err = parseAnthropicResponse(data)
+	if err == nil {
+		p.config.reportUsage(UsageKindTools, parseUsageJSON(data), messages, content)
+	}
+	return content, toolCalls, err
+}
+
+// GenerateResponseStream 以 SSE 方式生成回复（content_block_delta 中的 text_delta 为增量文本）。
+func (p *AnthropicProvider) GenerateResponseStream(ctx context.Context, conversationHistory []MessageHistory, userMessage string, imageBase64 string, imageMimeType string, onDelta func(delta string)) (string, error) {
+	if p.config.ModelType != "text" {
+		return "", fmt.Errorf("流式输出仅支持 text 模型")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	messages := buildOpenAIMessages(conversationHistory, userMessage, imageBase64, imageMimeType)
+	body := p.buildRequest(messages, nil)
+	body["stream"] = true
+	stream, err := openNativeStream(ctx, p.endpoint(), p.headers(), body)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var full strings.Builder
+	var usage TokenUsage
+	err = readSSEEvents(stream, func(data string) (bool, error) {
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return false, nil
+		}
+		// message_start 带输入用量，message_delta 带累计输出用量
+		if event.Type == "message_start" || event.Type == "message_delta" {
+			mergeStreamUsage(&usage, parseUsageJSON([]byte(data)))
+		}
+		switch event.Type {
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				full.WriteString(event.Delta.Text)
+				if onDelta != nil {
+					onDelta(event.Delta.Text)
+				}
+			}
+		case "message_stop":
+			return true, nil
+		case "error":
+			msg := "生成失败"
+			if event.Error != nil && event.Error.Message != "" {
+				msg = event.Error.Message
+			}
+			return true, fmt.Errorf("API 错误: %s", msg)
+		}
+		return false, nil
+	})
+	p.config.reportUsage(UsageKindChat, usage, messages, full.String())
+	if ctx.Err() != nil {
+		return full.String(), ctx.Err()
+	}
+	if err != nil {
+		return full.String(), err
+	}
+	if full.Len() == 0 {
+		return "", errors.New("API 返回空内容")
+	}
+	return full.String(), nil
+}
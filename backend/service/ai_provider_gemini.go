@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const geminiDefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// geminiSchemaKeys Gemini functionDeclarations 支持的 JSON Schema 关键字（其余如 additionalProperties、$schema 会被拒绝）
+var geminiSchemaKeys = map[string]bool{
+	"type": true, "format": true, "description": true, "nullable": true, "enum": true,
+	"properties": true, "required": true, "items": true, "minItems": true, "maxItems": true,
+	"minimum": true, "maximum": true, "anyOf": true,
+}
+
+// GeminiProvider Google Gemini generateContent API 原生实现。
+// 系统提示走 systemInstruction，图片为 inlineData，工具调用映射为 functionCall / functionResponse。
+type GeminiProvider struct {
+	config AIConfig
+	client *http.Client
+}
+
+// NewGeminiProvider 创建 Gemini 提供商实例。
+func NewGeminiProvider(config AIConfig) *GeminiProvider {
+	return &GeminiProvider{
+		config: config,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// endpoint 返回接口地址：api_url 可填完整的 models/{model}:generateContent，也可只填 v1beta 前缀（按 model 拼接）
+func (p *GeminiProvider) endpoint(stream bool) string {
+	url := strings.TrimRight(strings.TrimSpace(p.config.APIURL), "/")
+	if url == "" {
+		url = geminiDefaultBaseURL
+	}
+	if i := strings.Index(url, ":generateContent"); i >= 0 {
+		url = url[:i]
+	} else if i := strings.Index(url, ":streamGenerateContent"); i >= 0 {
+		url = url[:i]
+	} else {
+		url += "/models/" + p.config.Model
+	}
+	if stream {
+		return url + ":streamGenerateContent?alt=sse"
+	}
+	return url + ":generateContent"
+}
+
+func (p *GeminiProvider) headers() map[string]string {
+	return map[string]string{"x-goog-api-key": p.config.APIKey}
+}
+
+// buildRequest 将 OpenAI 格式消息与工具映射为 generateContent 请求体
+func (p *GeminiProvider) buildRequest(messages []map[string]interface{}, tools []map[string]interface{}) map[string]interface{} {
+	system, turns := normalizeOpenAIMessages(messages)
+	body := map[string]interface{}{
+		"contents": geminiContents(turns),
+	}
+	if system != "" {
+		body["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]interface{}{{"text": system}},
+		}
+	}
+	functions, webSearch := parseOpenAITools(tools)
+	var toolDefs []map[string]interface{}
+	if len(functions) > 0 {
+		decls := make([]map[string]interface{}, 0, len(functions))
+		for _, fn := range functions {
+			decls = append(decls, map[string]interface{}{
+				"name":        fn.Name,
+				"description": fn.Description,
+				"parameters":  sanitizeGeminiSchema(fn.Parameters),
+			})
+		}
+		toolDefs = append(toolDefs, map[string]interface{}{"functionDeclarations": decls})
+	}
+	if webSearch {
+		// 厂商内置联网：Google 搜索接地
+		toolDefs = append(toolDefs, map[string]interface{}{"googleSearch": map[string]interface{}{}})
+	}
+	if len(toolDefs) > 0 {
+		body["tools"] = toolDefs
+	}
+	applyRequestFormat(body, p.config.AdapterConfig)
+	return body
+}
+
+// geminiContents 将中间消息转为 user / model 内容（工具结果以 functionResponse 归入 user，相邻同角色合并）
+func geminiContents(turns []nativeTurn) []map[string]interface{} {
+	var out []map[string]interface{}
+	appendParts := func(role string, parts []map[string]interface{}) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1]["role"] == role {
+			out[n-1]["parts"] = append(out[n-1]["parts"].([]map[string]interface{}), parts...)
+			return
+		}
+		out = append(out, map[string]interface{}{"role": role, "parts": parts})
+	}
+	for _, turn := range turns {
+		var parts []map[string]interface{}
+		switch turn.Role {
+		case "tool":
+			parts = append(parts, map[string]interface{}{
+				"functionResponse": map[string]interface{}{
+					"name":     turn.ToolName,
+					"response": map[string]interface{}{"content": turn.Text},
+				},
+			})
+			appendParts("user", parts)
+			continue
+		case "user":
+			for _, img := range turn.Images {
+				parts = append(parts, map[string]interface{}{
+					"inlineData": map[string]interface{}{"mimeType": img.MimeType, "data": img.Data},
+				})
+			}
+		}
+		if strings.TrimSpace(turn.Text) != "" {
+			parts = append(parts, map[string]interface{}{"text": turn.Text})
+		}
+		for _, tc := range turn.ToolCalls {
+			parts = append(parts, map[string]interface{}{
+				"functionCall": map[string]interface{}{"name": tc.Name, "args": parseToolArguments(tc.Arguments)},
+			})
+		}
+		role := "user"
+		if turn.Role == "assistant" {
+			role = "model"
+		}
+		appendParts(role, parts)
+	}
+	return out
+}
+
+// sanitizeGeminiSchema 递归移除 Gemini 不支持的 JSON Schema 关键字
+func sanitizeGeminiSchema(schema map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		if !geminiSchemaKeys[k] {
+			continue
+		}
+		switch k {
+		case "properties":
+			if props, ok := v.(map[string]interface{}); ok {
+				cleaned := make(map[string]interface{}, len(props))
+				for name, prop := range props {
+					if pm, ok := prop.(map[string]interface{}); ok {
+						cleaned[name] = sanitizeGeminiSchema(pm)
+					}
+				}
+				v = cleaned
+			}
+		case "items":
+			if im, ok := v.(map[string]interface{}); ok {
+				v = sanitizeGeminiSchema(im)
+			}
+		case "anyOf":
+			if list, ok := v.([]interface{}); ok {
+				cleaned := make([]interface{}, 0, len(list))
+				for _, item := range list {
+					if im, ok := item.(map[string]interface{}); ok {
+						cleaned = append(cleaned, sanitizeGeminiSchema(im))
+					}
+				}
+				v = cleaned
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// geminiResponse generateContent 响应（流式每个 SSE 块也是同一结构）
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text         string `json:"text"`
+				Thought      bool   `json:"thought"`
+				FunctionCall *struct {
+					ID   string                 `json:"id"`
+					Name string                 `json:"name"`
+					Args map[string]interface{} `json:"args"`
+				} `json:"functionCall"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// parseGeminiResponse 提取文本（跳过思考片段）与 functionCall；Gemini 不一定返回调用 ID，按序号生成
+func parseGeminiResponse(data []byte) (content string, toolCalls []ToolCall, err error) {
+	var parsed geminiResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	if parsed.Error != nil {
+		return "", nil, fmt.Errorf("API 错误: %s", parsed.Error.Message)
+	}
+	if parsed.PromptFeedback != nil && parsed.PromptFeedback.BlockReason != "" {
+		return "", nil, fmt.Errorf("请求被拦截: %s", parsed.PromptFeedback.BlockReason)
+	}
+	if len(parsed.Candidates) == 0 {
+		return "", nil, nil
+	}
+	var texts []string
+	for i, part := range parsed.Candidates[0].Content.Parts {
+		if part.FunctionCall != nil {
+			args, _ := json.Marshal(part.FunctionCall.Args)
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d_%s", i, part.FunctionCall.Name)
+			}
+			toolCalls = append(toolCalls, ToolCall{ID: id, Name: part.FunctionCall.Name, Arguments: string(args)})
+			continue
+		}
+		if part.Text != "" && !part.Thought {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, ""), toolCalls, nil
+}
+
+// GenerateResponse 生成 AI 回复（支持系统提示与当前消息带图）。
+func (p *GeminiProvider) GenerateResponse(conversationHistory []MessageHistory, userMessage string, imageBase64 string, imageMimeType string) (string, error) {
+	if p.config.ModelType != "text" {
+		return "", fmt.Errorf("Gemini 提供商仅支持 text 模型")
+	}
+	messages := buildOpenAIMessages(conversationHistory, userMessage, imageBase64, imageMimeType)
+	data, err := postNativeJSON(context.Background(), p.client, p.endpoint(false), p.headers(), p.buildRequest(messages, nil))
+	if err != nil {
+		return "", err
+	}
+	content, _, err := parseGeminiResponse(data)
+	if err != nil {
+		return "", err
+	}
+	if content == "" {
+		return "", errors.New("API 返回空内容")
+	}
+	p.config.reportUsage(UsageKindChat, parseUsageJSON(data), messages, content)
+	return content, nil
+}
+
+// GenerateResponseWithTools 带工具调用的生成；messages 与 tools 为 OpenAI 格式，内部映射为 functionCall / functionResponse。
+func (p *GeminiProvider) GenerateResponseWithTools(messages []map[string]interface{}, tools []map[string]interface{}) (content string, toolCalls []ToolCall, err error) {
+	if p.config.ModelType != "text" {
+		return "", nil, fmt.Errorf("带工具调用仅支持 text 模型")
+	}
+	data, err := postNativeJSON(context.Background(), p.client, p.endpoint(false), p.headers(), p.buildRequest(messages, tools))
+	if err != nil {
+		return "", nil, err
+	}
+	content, toolCalls, err = parseGeminiResponse(data)
+	if err == nil {
+		p.config.reportUsage(UsageKindTools, parseUsageJSON(data), messages, content)
+	}
+	return content, toolCalls, err
+}
+
+// GenerateResponseStream 以 SSE（streamGenerateContent?alt=sse）方式生成回复。
+func (p *GeminiProvider) GenerateResponseStream(ctx context.Context, conversationHistory []MessageHistory, userMessage string, imageBase64 string, imageMimeType string, onDelta func(delta string)) (string, error) {
+	if p.config.ModelType != "text" {
+		return "", fmt.Errorf("流式输出仅支持 text 模型")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	messages := buildOpenAIMessages(conversationHistory, userMessage, imageBase64, imageMimeType)
+	stream, err := openNativeStream(ctx, p.endpoint(true), p.headers(), p.buildRequest(messages, nil))
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var full strings.Builder
+	var usage TokenUsage
+	err = readSSEEvents(stream, func(data string) (bool, error) {
+		delta, _, err := parseGeminiResponse([]byte(data))
+		if err != nil {
+			return true, err
+		}
+		// usageMetadata 为累计值，取最后一次
+		mergeStreamUsage(&usage, parseUsageJSON([]byte(data)))
+		if delta != "" {
+			full.WriteString(delta)
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		}
+		return false, nil
+	})
+	p.config.reportUsage(UsageKindChat, usage, messages, full.String())
+	if ctx.Err() != nil {
+		return full.String(), ctx.Err()
+	}
+	if err != nil {
+		return full.String(), err
+	}
+	if full.Len() == 0 {
+		return "", errors.New("API 返回空内容")
+	}
+	return full.String(), nil
+}
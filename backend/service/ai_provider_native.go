@@ -0,0 +1,290 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// 接口协议（AIConfig.Protocol 取值）；Provider 仅为展示名称，不参与协议选择
+const (
+	ProtocolOpenAI    = "openai"
+	ProtocolAnthropic = "anthropic"
+	ProtocolGemini    = "gemini"
+)
+
+// normalizeProtocol 规范化接口协议，空值视为 openai
+func normalizeProtocol(protocol string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(protocol)); p {
+	case "":
+		return ProtocolOpenAI, nil
+	case ProtocolOpenAI, ProtocolAnthropic, ProtocolGemini:
+		return p, nil
+	default:
+		return "", fmt.Errorf("不支持的接口协议: %s", protocol)
+	}
+}
+
+// nativeTurn 与厂商无关的中间消息：由 OpenAI 格式消息归一化而来，再映射为各厂商原生请求
+type nativeTurn struct {
+	Role       string // user / assistant / tool
+	Text       string
+	Images     []nativeImage
+	ToolCalls  []ToolCall // assistant 发起的工具调用
+	ToolCallID string     // role=tool 时对应的调用 ID
+	ToolName   string     // role=tool 时对应的工具名（Gemini functionResponse 需要）
+}
+
+type nativeImage struct {
+	MimeType string
+	Data     string // base64
+}
+
+// nativeTool OpenAI function 工具定义的通用形式
+type nativeTool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// buildOpenAIMessages 将对话历史与当前用户消息组装为 OpenAI 格式（与 UniversalAIProvider 一致）
+func buildOpenAIMessages(history []MessageHistory, userMessage string, imageBase64 string, imageMimeType string) []map[string]interface{} {
+	messages := make([]map[string]interface{}, 0, len(history)+1)
+	for _, h := range history {
+		messages = append(messages, map[string]interface{}{"role": h.Role, "content": h.Content})
+	}
+	return append(messages, map[string]interface{}{"role": "user", "content": buildUserContent(userMessage, imageBase64, imageMimeType)})
+}
+
+// normalizeOpenAIMessages 将 OpenAI 格式消息拆分为系统提示（所有 system 消息合并）与对话轮次
+func normalizeOpenAIMessages(messages []map[string]interface{}) (system string, turns []nativeTurn) {
+	var systemParts []string
+	toolNames := map[string]string{}
+	for _, msg := range messages {
+		role := getStr(msg, "role")
+		switch role {
+		case "system", "developer":
+			if text := strings.TrimSpace(openAIContentText(msg["content"])); text != "" {
+				systemParts = append(systemParts, text)
+			}
+		case "tool":
+			id := getStr(msg, "tool_call_id")
+			turns = append(turns, nativeTurn{
+				Role:       "tool",
+				Text:       openAIContentText(msg["content"]),
+				ToolCallID: id,
+				ToolName:   toolNames[id],
+			})
+		case "user", "assistant":
+			turn := nativeTurn{Role: role, Text: openAIContentText(msg["content"]), Images: openAIContentImages(msg["content"])}
+			if role == "assistant" {
+				turn.ToolCalls = openAIToolCalls(msg["tool_calls"])
+				for _, tc := range turn.ToolCalls {
+					toolNames[tc.ID] = tc.Name
+				}
+			}
+			turns = append(turns, turn)
+		}
+	}
+	return strings.Join(systemParts, "\n\n"), turns
+}
+
+// openAIContentText 提取 content 中的文本（字符串或 [{type:text,text}] 数组）
+func openAIContentText(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []map[string]interface{}:
+		var parts []string
+		for _, p := range v {
+			if getStr(p, "type") == "text" {
+				parts = append(parts, getStr(p, "text"))
+			}
+		}
+		return strings.Join(parts, "\n")
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if p, ok := item.(map[string]interface{}); ok && getStr(p, "type") == "text" {
+				parts = append(parts, getStr(p, "text"))
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// openAIContentImages 提取 content 中以 data URL 形式内联的图片
+func openAIContentImages(content interface{}) []nativeImage {
+	var parts []map[string]interface{}
+	switch v := content.(type) {
+	case []map[string]interface{}:
+		parts = v
+	case []interface{}:
+		for _, item := range v {
+			if p, ok := item.(map[string]interface{}); ok {
+				parts = append(parts, p)
+			}
+		}
+	}
+	var images []nativeImage
+	for _, p := range parts {
+		if getStr(p, "type") != "image_url" {
+			continue
+		}
+		url := ""
+		switch iu := p["image_url"].(type) {
+		case map[string]string:
+			url = iu["url"]
+		case map[string]interface{}:
+			url = getStr(iu, "url")
+		}
+		if img, ok := parseDataURL(url); ok {
+			images = append(images, img)
+		}
+	}
+	return images
+}
+
+// parseDataURL 解析 data:<mime>;base64,<data>
+func parseDataURL(url string) (nativeImage, bool) {
+	if !strings.HasPrefix(url, "data:") {
+		return nativeImage{}, false
+	}
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return nativeImage{}, false
+	}
+	mime := strings.TrimSuffix(meta, ";base64")
+	if mime == "" {
+		mime = "image/jpeg"
+	}
+	return nativeImage{MimeType: mime, Data: data}, true
+}
+
+// openAIToolCalls 解析 assistant 消息中的 tool_calls
+func openAIToolCalls(raw interface{}) []ToolCall {
+	var items []map[string]interface{}
+	switch v := raw.(type) {
+	case []map[string]interface{}:
+		items = v
+	case []interface{}:
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				items = append(items, m)
+			}
+		}
+	}
+	calls := make([]ToolCall, 0, len(items))
+	for _, tc := range items {
+		fn, _ := tc["function"].(map[string]interface{})
+		calls = append(calls, ToolCall{ID: getStr(tc, "id"), Name: getStr(fn, "name"), Arguments: getStr(fn, "arguments")})
+	}
+	return calls
+}
+
+// parseOpenAITools 将 OpenAI 工具定义拆为 function 工具与是否请求厂商内置联网搜索（{"type":"web_search"}）
+func parseOpenAITools(tools []map[string]interface{}) (functions []nativeTool, webSearch bool) {
+	for _, t := range tools {
+		switch getStr(t, "type") {
+		case "web_search":
+			webSearch = true
+		case "function":
+			fn, _ := t["function"].(map[string]interface{})
+			if fn == nil || getStr(fn, "name") == "" {
+				continue
+			}
+			params, _ := fn["parameters"].(map[string]interface{})
+			if params == nil {
+				params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			}
+			functions = append(functions, nativeTool{Name: getStr(fn, "name"), Description: getStr(fn, "description"), Parameters: params})
+		}
+	}
+	return functions, webSearch
+}
+
+// parseToolArguments 将 JSON 字符串参数解析为对象（解析失败时返回空对象）
+func parseToolArguments(arguments string) map[string]interface{} {
+	args := map[string]interface{}{}
+	if strings.TrimSpace(arguments) != "" {
+		_ = json.Unmarshal([]byte(arguments), &args)
+	}
+	return args
+}
+
+// applyRequestFormat 将适配器 request_format 覆盖到请求体（如 max_tokens、temperature）
+func applyRequestFormat(body map[string]interface{}, adapter *AdapterConfig) {
+	if adapter == nil {
+		return
+	}
+	for k, v := range adapter.RequestFormat {
+		body[k] = v
+	}
+}
+
+// postNativeJSON 发送 JSON 请求并返回响应体；非 200 时返回带响应内容的错误
+func postNativeJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body map[string]interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("⚠️ AI 原生接口请求失败: url=%s err=%v", req.URL.String(), err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAIAPIError(resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// openNativeStream 发送流式请求，返回待读取的 SSE 响应体（调用方负责关闭）
+func openNativeStream(ctx context.Context, url string, headers map[string]string, body map[string]interface{}) (io.ReadCloser, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("⚠️ AI 原生流式请求失败: url=%s err=%v", req.URL.String(), err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, newAIAPIError(resp.StatusCode, data)
+	}
+	return resp.Body, nil
+}
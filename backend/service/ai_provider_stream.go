@@ -0,0 +1,238 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// streamHTTPClient 流式请求专用客户端：不设整体超时（长回答可能持续数十秒），由调用方 ctx 控制取消。
+var streamHTTPClient = &http.Client{}
+
+// GenerateResponseStream 以 SSE（stream: true）方式生成回复，每收到一段增量文本即回调 onDelta。
+// 支持 Chat Completions（choices[0].delta.content）与 Responses API（response.output_text.delta）。
+// 返回完整文本；ctx 被取消时返回已生成的部分文本与 ctx.Err()。
+func (p *UniversalAIProvider) GenerateResponseStream(ctx context.Context, conversationHistory []MessageHistory, userMessage string, imageBase64 string, imageMimeType string, onDelta func(delta string)) (string, error) {
+	if p.config.ModelType != "text" {
+		return "", fmt.Errorf("流式输出仅支持 text 模型")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	messages := make([]map[string]interface{}, 0, len(conversationHistory)+1)
+	for _, history := range conversationHistory {
+		messages = append(messages, map[string]interface{}{"role": history.Role, "content": history.Content})
+	}
+	messages = append(messages, map[string]interface{}{"role": "user", "content": buildUserContent(userMessage, imageBase64, imageMimeType)})
+
+	responsesAPI := isResponsesAPI(p.config.APIURL)
+	var requestBody map[string]interface{}
+	if responsesAPI {
+		requestBody = map[string]interface{}{
+			"model":  p.config.Model,
+			"input":  messages,
+			"stream": true,
+		}
+	} else {
+		requestBody = map[string]interface{}{
+			"model":    p.config.Model,
+			"messages": messages,
+			"stream":   true,
+			// 要求在最后一个块中返回 usage（OpenAI 规范，兼容服务商忽略即可）
+			"stream_options": map[string]interface{}{"include_usage": true},
+		}
+	}
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("序列化请求失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.config.APIURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	p.setAuthHeader(req)
+
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		log.Printf("⚠️ AI GenerateResponseStream 请求失败: config.api_url=%s 实际 req.URL=%s err=%v",
+			p.config.APIURL, req.URL.String(), err)
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", newAIAPIError(resp.StatusCode, body)
+	}
+
+	// 部分兼容服务商忽略 stream 参数直接返回完整 JSON：按非流式解析，整体作为一段增量回调
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("读取响应失败: %v", err)
+		}
+		var responseData map[string]interface{}
+		if err := json.Unmarshal(body, &responseData); err != nil {
+			return "", fmt.Errorf("解析响应失败: %v", err)
+		}
+		if errorMsg, ok := responseData["error"].(map[string]interface{}); ok {
+			if msg, ok := errorMsg["message"].(string); ok {
+				return "", fmt.Errorf("API 错误: %s", msg)
+			}
+		}
+		content, err := p.extractResponseContent(responseData, p.adapter.ResponsePath)
+		if err != nil {
+			return "", err
+		}
+		if content == "" {
+			return "", errors.New("API 返回空内容")
+		}
+		p.config.reportUsage(UsageKindChat, parseUsage(responseData), messages, content)
+		if onDelta != nil {
+			onDelta(content)
+		}
+		return content, nil
+	}
+
+	var full strings.Builder
+	var usage TokenUsage
+	err = readSSEEvents(resp.Body, func(data string) (bool, error) {
+		delta, chunkUsage, done, err := parseStreamChunk(data, responsesAPI)
+		if err != nil {
+			return true, err
+		}
+		mergeStreamUsage(&usage, chunkUsage)
+		if delta != "" {
+			full.WriteString(delta)
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		}
+		return done, nil
+	})
+	// 取消时已生成的部分同样计费
+	p.config.reportUsage(UsageKindChat, usage, messages, full.String())
+	if ctx.Err() != nil {
+		return full.String(), ctx.Err()
+	}
+	if err != nil {
+		return full.String(), err
+	}
+	if full.Len() == 0 {
+		return "", errors.New("API 返回空内容")
+	}
+	return full.String(), nil
+}
+
+// setAuthHeader 按适配器配置设置认证头（默认 Authorization: Bearer）。
+func (p *UniversalAIProvider) setAuthHeader(req *http.Request) {
+	if p.adapter.AuthHeader == "X-API-Key" {
+		req.Header.Set("X-API-Key", p.config.APIKey)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+}
+
+// readSSEEvents 逐个读取 SSE 事件的 data 字段并回调 handle；handle 返回 done=true 或遇到 [DONE] 时结束。
+func readSSEEvents(r io.Reader, handle func(data string) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var dataLines []string
+	flush := func() (bool, error) {
+		if len(dataLines) == 0 {
+			return false, nil
+		}
+		data := strings.Join(dataLines, "\n")
+		dataLines = dataLines[:0]
+		if strings.TrimSpace(data) == "[DONE]" {
+			return true, nil
+		}
+		return handle(data)
+	}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			done, err := flush()
+			if err != nil || done {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "data:") {
+			dataLines = append(dataLines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// event:/id:/retry: 以及以冒号开头的注释行无需处理（Responses API 的事件类型在 data.type 中也有）
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取流式响应失败: %v", err)
+	}
+	_, err := flush()
+	return err
+}
+
+// parseStreamChunk 解析单个 SSE data 块，返回增量文本、块内用量（若有）与是否结束。
+func parseStreamChunk(data string, responsesAPI bool) (delta string, usage TokenUsage, done bool, err error) {
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		// 非 JSON 的心跳/注释块直接忽略
+		return "", usage, false, nil
+	}
+	if errorMsg, ok := chunk["error"].(map[string]interface{}); ok {
+		if msg, ok := errorMsg["message"].(string); ok {
+			return "", usage, true, fmt.Errorf("API 错误: %s", msg)
+		}
+		return "", usage, true, errors.New("API 返回错误事件")
+	}
+	usage = parseUsage(chunk)
+
+	if responsesAPI || getStr(chunk, "type") != "" {
+		switch getStr(chunk, "type") {
+		case "response.output_text.delta":
+			return getStr(chunk, "delta"), usage, false, nil
+		case "response.completed", "response.incomplete":
+			return "", usage, true, nil
+		case "response.failed", "error":
+			msg := getStr(chunk, "message")
+			if resp, ok := chunk["response"].(map[string]interface{}); ok {
+				if e, ok := resp["error"].(map[string]interface{}); ok {
+					msg = getStr(e, "message")
+				}
+			}
+			if msg == "" {
+				msg = "生成失败"
+			}
+			return "", usage, true, fmt.Errorf("API 错误: %s", msg)
+		}
+		if responsesAPI {
+			return "", usage, false, nil
+		}
+	}
+
+	// Chat Completions：choices[0].delta.content；include_usage 时 usage 在 finish_reason 之后单独一块（choices 为空）
+	choices, _ := chunk["choices"].([]interface{})
+	if len(choices) == 0 {
+		return "", usage, false, nil
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	if d, ok := choice["delta"].(map[string]interface{}); ok {
+		delta = getStr(d, "content")
+	}
+	// 结束块未带 usage 时继续读到 [DONE]，以便拿到随后的 usage 块
+	if reason, ok := choice["finish_reason"].(string); ok && reason != "" && !usage.IsZero() {
+		done = true
+	}
+	return delta, usage, done, nil
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+const (
+	// 查询改写参考的最近消息条数（不含当前问题）
+	rewriteHistoryMessages = 6
+	// 查询改写的超时时间（超时按原问题检索）
+	queryRewriteTimeout = 8 * time.Second
+	// 改写参考的单条历史消息最大长度
+	rewriteHistoryMaxRunes = 300
+)
+
+// queryRewrite 查询改写结果
+type queryRewrite struct {
+	Query      string   `json:"query"`       // 不依赖上下文、可独立检索的问题
+	SubQueries []string `json:"sub_queries"` // 问题包含多个方面时拆出的子问题
+}
+
+// retrievalQueries 返回参与检索的查询（主查询在前，去重，最多 max 条）
+func (r queryRewrite) retrievalQueries(max int) []string {
+	out := make([]string, 0, 1+len(r.SubQueries))
+	seen := make(map[string]bool)
+	for _, q := range append([]string{r.Query}, r.SubQueries...) {
+		q = strings.TrimSpace(q)
+		if q == "" || seen[q] {
+			continue
+		}
+		seen[q] = true
+		out = append(out, q)
+		if max > 0 && len(out) >= max {
+			break
+		}
+	}
+	return out
+}
+
+// rewriteRetrievalQueries 结合最近对话把当前问题改写为独立的检索问题（可拆出子问题）。
+// 未开启、无需改写或改写失败时返回原问题；改写结果写入 rag 分类的系统日志便于排查检索效果。
+func (s *AIService) rewriteRetrievalQueries(ctx context.Context, query string, conversation *models.Conversation) []string {
+	query = strings.TrimSpace(query)
+	original := []string{query}
+	if s.embeddingConfigSvc == nil || conversation == nil || query == "" {
+		return original
+	}
+	cfg := s.embeddingConfigSvc.GetQueryRewriteConfig()
+	if !cfg.Enabled {
+		return original
+	}
+	history := s.recentHistoryForRewrite(conversation.ID, query)
+	// 首轮且不拆分子问题时无需改写
+	if len(history) == 0 && cfg.MaxQueries <= 1 {
+		return original
+	}
+
+	startedAt := time.Now()
+	rewrite, configID, err := s.rewriteQuery(ctx, query, history, conversation, cfg)
+	meta := map[string]interface{}{
+		"original":   query,
+		"history":    len(history),
+		"elapsed_ms": time.Since(startedAt).Milliseconds(),
+	}
+	if err != nil {
+		meta["error"] = err.Error()
+		s.writeRAGLog("warn", "query_rewrite_failed", conversation, "查询改写失败，按原问题检索", meta)
+		return original
+	}
+	queries := rewrite.retrievalQueries(cfg.MaxQueries)
+	if len(queries) == 0 {
+		return original
+	}
+	meta["rewritten"] = queries[0]
+	meta["sub_queries"] = queries[1:]
+	meta["ai_config"] = configID
+	s.writeRAGLog("info", "query_rewritten", conversation, "检索查询已改写", meta)
+	return queries
+}
+
+// recentHistoryForRewrite 取当前问题之前的最近几条对话（跳过系统消息与客服内部备注）
+func (s *AIService) recentHistoryForRewrite(conversationID uint, query string) []MessageHistory {
+	if s.messageRepo == nil || conversationID == 0 {
+		return nil
+	}
+	messages, err := s.messageRepo.ListByConversationID(conversationID)
+	if err != nil {
+		return nil
+	}
+	visible := make([]models.Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.MessageType == "system_message" || msg.MessageType == models.MessageTypeWhisper || strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		visible = append(visible, msg)
+	}
+	// 当前问题已落库为最后一条访客消息，不计入历史
+	if n := len(visible); n > 0 && !visible[n-1].SenderIsAgent && strings.TrimSpace(visible[n-1].Content) == query {
+		visible = visible[:n-1]
+	}
+	if len(visible) > rewriteHistoryMessages {
+		visible = visible[len(visible)-rewriteHistoryMessages:]
+	}
+	return withHistorySummary("", visible)
+}
+
+// rewriteQuery 调用模型改写查询，返回改写结果与实际使用的 AI 配置 ID
+func (s *AIService) rewriteQuery(ctx context.Context, query string, history []MessageHistory, conversation *models.Conversation, cfg QueryRewriteConfig) (queryRewrite, uint, error) {
+	var preferred []uint
+	if cfg.AIConfigID > 0 {
+		preferred = append(preferred, cfg.AIConfigID)
+	}
+	if conversation.AIConfigID != nil {
+		preferred = append(preferred, *conversation.AIConfigID)
+	}
+	config, err := resolveTextAIConfig(s.aiConfigRepo, preferred, conversation.AgentID)
+	if err != nil {
+		return queryRewrite{}, 0, err
+	}
+	apiKey, err := utils.DecryptAPIKey(config.APIKey)
+	if err != nil {
+		return queryRewrite{}, 0, fmt.Errorf("解密 API Key 失败: %v", err)
+	}
+	provider, err := s.buildFailoverProvider(config, apiKey, s.usageSvc.newTracker(conversation.ID))
+	if err != nil {
+		return queryRewrite{}, 0, fmt.Errorf("创建 AI 提供商失败: %v", err)
+	}
+	defer s.logFailover(provider, conversation.ID, 0)
+
+	rewriteCtx, cancel := context.WithTimeout(ctx, queryRewriteTimeout)
+	defer cancel()
+	response, err := provider.GenerateResponseContext(rewriteCtx, nil, buildQueryRewritePrompt(query, history, cfg.MaxQueries))
+	if err != nil {
+		return queryRewrite{}, 0, err
+	}
+	rewrite, err := parseQueryRewrite(response)
+	if err != nil {
+		return queryRewrite{}, 0, err
+	}
+	configID := config.ID
+	if used := provider.Used(); used != nil {
+		configID = used.ConfigID
+	}
+	return rewrite, configID, nil
+}
+
+func buildQueryRewritePrompt(query string, history []MessageHistory, maxQueries int) string {
+	var b strings.Builder
+	b.WriteString("你负责为客服知识库检索改写用户问题。请结合最近的对话，把【当前问题】改写为一个不依赖上下文、可以单独检索的完整问题：")
+	b.WriteString("补全省略的主语和指代（如「它」「那第二个呢」「how much is it」所指的具体产品、订单或方案），保留型号、订单号等关键信息，不要回答问题，也不要编造对话中没有的信息。")
+	if maxQueries > 1 {
+		b.WriteString(fmt.Sprintf("若问题同时涉及多个方面，可另外拆出最多 %d 个子问题，分别用于检索；问题单一时不要拆分。", maxQueries-1))
+	}
+	b.WriteString("\n只输出 JSON，格式：{\"query\": \"改写后的问题\", \"sub_queries\": [\"子问题\"]}。改写后的问题使用与当前问题相同的语言。\n\n")
+	if len(history) > 0 {
+		b.WriteString("【最近对话】\n")
+		for _, h := range history {
+			speaker := "用户"
+			if h.Role == "assistant" {
+				speaker = "客服"
+			}
+			b.WriteString(speaker)
+			b.WriteString("：")
+			b.WriteString(truncateRunes(strings.TrimSpace(h.Content), rewriteHistoryMaxRunes))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("【当前问题】\n")
+	b.WriteString(query)
+	return b.String()
+}
+
+// parseQueryRewrite 解析改写结果；兼容模型只输出纯文本问题的情况
+func parseQueryRewrite(response string) (queryRewrite, error) {
+	text := strings.TrimSpace(response)
+	var out queryRewrite
+	if m := jsonObjectPattern.FindString(text); m != "" {
+		if err := json.Unmarshal([]byte(m), &out); err != nil {
+			return queryRewrite{}, fmt.Errorf("解析改写结果失败: %v", err)
+		}
+	} else {
+		out.Query = strings.Trim(text, "\"'` \n")
+	}
+	out.Query = strings.TrimSpace(out.Query)
+	if out.Query == "" {
+		return queryRewrite{}, errors.New("模型未返回改写后的问题")
+	}
+	return out, nil
+}
+
+// writeRAGLog 写入 rag 分类的系统日志（用于排查检索效果）
+func (s *AIService) writeRAGLog(level, event string, conversation *models.Conversation, message string, meta map[string]interface{}) {
+	if s.systemLogSvc == nil {
+		return
+	}
+	convID := conversation.ID
+	_ = s.systemLogSvc.Create(CreateSystemLogInput{
+		Level:          level,
+		Category:       "rag",
+		Event:          event,
+		Source:         "backend",
+		ConversationID: &convID,
+		Message:        message,
+		Meta:           meta,
+	})
+}
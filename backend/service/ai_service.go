@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/2930134478/AI-CS/backend/infra"
@@ -33,6 +34,13 @@ type AIService struct {
 	storageService     infra.StorageService     // 可选，用于多模态识图时读取消息附件
 	systemLogSvc       *SystemLogService        // 可选，结构化日志服务
 	faqRepo            *repository.FAQRepository // 可选，FAQ 优先匹配
+	memoryRepo         *repository.ConversationMemoryRepository // 可选，对话历史滚动摘要
+	handoffIntent      bool // 是否允许模型表达转人工意图（transfer_to_human 工具 / 标记）
+	circuitBreaker     *AICircuitBreaker // 按 AI 配置熔断，故障转移时跳过不健康的模型
+	usageSvc           *AIUsageService   // 可选，token 用量与费用统计
+	toolSvc            *AIToolService    // 可选，管理员定义的 HTTP 工具
+	mcpSvc             *MCPServerService // 可选，管理员注册的 MCP 服务工具
+	docRepo            *repository.DocumentRepository // 可选，引用来源的文档标题
 }
 
 // NewAIService 创建 AI 服务实例。webSearchProvider、storageService 可为 nil。
@@ -60,9 +68,47 @@ func NewAIService(
 		storageService:     storageService,
 		systemLogSvc:       systemLogSvc,
 		faqRepo:            faqRepo,
+		circuitBreaker:     NewAICircuitBreaker(),
 	}
 }
 
+// SetConversationMemoryRepository 设置会话摘要仓库（为空时超出预算的历史直接截断，不做摘要）
+func (s *AIService) SetConversationMemoryRepository(repo *repository.ConversationMemoryRepository) {
+	s.memoryRepo = repo
+}
+
+// SetUsageService 设置用量服务（为空时不统计 token 用量与费用）
+func (s *AIService) SetUsageService(svc *AIUsageService) {
+	s.usageSvc = svc
+}
+
+// SetToolService 设置 HTTP 工具服务（为空时仅支持联网与转人工工具）
+func (s *AIService) SetToolService(svc *AIToolService) {
+	s.toolSvc = svc
+}
+
+// SetMCPServerService 设置 MCP 服务管理（为空时不提供 MCP 工具）
+func (s *AIService) SetMCPServerService(svc *MCPServerService) {
+	s.mcpSvc = svc
+}
+
+// SetDocumentRepository 设置文档仓库（为空时引用来源仅记录文档 ID）
+func (s *AIService) SetDocumentRepository(repo *repository.DocumentRepository) {
+	s.docRepo = repo
+}
+
+// AttachUsageToMessage 将本次生成的用量记录关联到落库的 AI 回复消息
+func (s *AIService) AttachUsageToMessage(usage *AIUsageSummary, messageID uint) {
+	if s.usageSvc != nil {
+		s.usageSvc.AttachToMessage(usage, messageID)
+	}
+}
+
+// SetHandoffIntentEnabled 开启后访客会话的 AI 回复可通过工具或标记请求转人工（由 message 层执行转接）
+func (s *AIService) SetHandoffIntentEnabled(enabled bool) {
+	s.handoffIntent = enabled
+}
+
 // GenerateAIResponse 为对话生成 AI 回复（兼容旧调用，使用默认数据源选项）。
 // 返回: AI 回复内容，若失败返回错误。
 func (s *AIService) GenerateAIResponse(conversationID uint, userMessage string, userID uint) (string, error) {
@@ -144,12 +190,6 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 			conversationID, convAIConfigID, config.ID, config.Provider, apiURLMask)
 	}
 
-	history, err := s.buildConversationHistory(conversationID)
-	if err != nil {
-		log.Printf("⚠️ 获取对话历史失败: %v", err)
-		history = []MessageHistory{}
-	}
-
 	// 多模态识图：当前条带图时读取文件并转 base64 供 provider 使用
 	var imageBase64, imageMimeType string
 	if opts != nil && opts.Attachment != nil && opts.Attachment.FileType == "image" && opts.Attachment.FileURL != "" && s.storageService != nil {
@@ -167,9 +207,10 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 
 	var ragContext string
 	var faqHit bool
+	citations := &citationCollector{}
 	ragStartedAt := time.Now()
 	if useKB && s.retrievalService != nil {
-		ragContext, faqHit, err = s.retrieveRAGContext(context.Background(), userMessage, conversation)
+		ragContext, faqHit, err = s.retrieveRAGContext(context.Background(), userMessage, conversation, citations)
 		if err != nil {
 			log.Printf("⚠️ RAG 检索失败: %v", err)
 		}
@@ -188,12 +229,15 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 					Message:        "FAQ 命中，直接返回",
 					Meta: map[string]interface{}{
 						"elapsed_ms": time.Since(ragStartedAt).Milliseconds(),
+						"faq_id":     citations.items[0].DocumentID,
+						"score":      citations.items[0].Score,
 					},
 				})
 			}
 			return &GenerateAIResponseResult{
 				Content:     ragContext,
 				SourcesUsed: "knowledge_base",
+				Citations:   citations.forAnswer(ragContext),
 			}, nil
 		}
 		if s.systemLogSvc != nil {
@@ -220,38 +264,122 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 		}
 	}
 
-	var adapterConfig *AdapterConfig
-	if config.AdapterConfig != "" {
-		_ = json.Unmarshal([]byte(config.AdapterConfig), &adapterConfig)
-	}
-	aiConfig := AIConfig{
-		APIURL:        config.APIURL,
-		APIKey:        apiKey,
-		Model:         config.Model,
-		ModelType:     config.ModelType,
-		Provider:      config.Provider,
-		AdapterConfig: adapterConfig,
-	}
-	provider, err := s.providerFactory.CreateProvider(aiConfig)
+	// 主配置 + 备用配置组成故障转移链（超时 / 5xx / 熔断时依次切换）
+	tracker := s.usageSvc.newTracker(conversationID)
+	provider, err := s.buildFailoverProvider(config, apiKey, tracker)
 	if err != nil {
 		return nil, fmt.Errorf("创建 AI 提供商失败: %v", err)
 	}
+	defer s.logFailover(provider, conversationID, userID)
+
+	// 对话历史：按 AI 配置的 token 预算发送「滚动摘要 + 最近轮次」
+	history, err := s.buildConversationHistory(conversationID, historyTokenBudget(config), provider)
+	if err != nil {
+		log.Printf("⚠️ 获取对话历史失败: %v", err)
+		history = []MessageHistory{}
+	}
+	// 访客会话：告知模型何时请求转人工
+	handoffEnabled := s.handoffIntent && conversation.ConversationType != "internal"
+	if handoffEnabled {
+		history = append([]MessageHistory{{Role: "system", Content: handoffInstruction}}, history...)
+	}
 
 	var sources []string
 	enhancedMessage := userMessage
 
+	// 本回合可用的工具：联网（需开启且判定需要）与 AI 配置白名单内的 HTTP 工具
+	webSource := ""
+	if needWeb && useWeb {
+		webSource = "custom"
+		if s.embeddingConfigSvc != nil {
+			webSource, _ = s.embeddingConfigSvc.GetWebSearchSource()
+		}
+	}
+	var httpTools []models.AITool
+	if s.toolSvc != nil {
+		httpTools = s.toolSvc.ToolsForConfig(config)
+	}
+	var mcpTools []MCPToolBinding
+	if s.mcpSvc != nil {
+		mcpTools = s.mcpSvc.ToolsForConfig(config)
+	}
+	hasTools := webSource != "" || len(httpTools) > 0 || len(mcpTools) > 0
+
+	// 流式输出与取消：工具调用路径与普通生成共用同一 ctx 与增量回调
+	genCtx := context.Background()
+	if opts != nil && opts.Context != nil {
+		genCtx = opts.Context
+	}
+	var onDelta func(string)
+	flushMarker := func() {}
+	if opts != nil && opts.OnDelta != nil {
+		onDelta = opts.OnDelta
+		if handoffEnabled {
+			onDelta, flushMarker = newHandoffMarkerFilter(opts.OnDelta)
+		}
+	}
+	var toolStreamed atomic.Bool
+	var toolDelta func(string)
+	if onDelta != nil {
+		toolDelta = func(delta string) {
+			toolStreamed.Store(true)
+			onDelta(delta)
+		}
+	}
+	// 工具调用路径已推送过增量或已被取消时出错：不再回退到普通生成（避免重复输出），按取消 / 失败收尾
+	finishInterruptedTools := func(content string, err error, sources []string) *GenerateAIResponseResult {
+		if !toolStreamed.Load() && genCtx.Err() == nil {
+			return nil
+		}
+		flushMarker()
+		sourcesUsed := strings.Join(append(sources, "llm"), ",")
+		if genCtx.Err() != nil || errors.Is(err, context.Canceled) {
+			return applyHandoffIntent(withGenerationMeta(&GenerateAIResponseResult{
+				Content:     content,
+				SourcesUsed: sourcesUsed,
+				Cancelled:   true,
+				Citations:   citations.forAnswer(content),
+			}, provider, tracker), handoffEnabled)
+		}
+		log.Printf("❌ AI 工具调用流式生成失败: %v", err)
+		return withGenerationMeta(&GenerateAIResponseResult{
+			Content:          s.getAIFailReply(),
+			SourcesUsed:      sourcesUsed,
+			GenerationFailed: true,
+		}, provider, tracker)
+	}
+
+	toolLoop := toolLoopInput{
+		WebSource:      webSource,
+		HTTPTools:      httpTools,
+		MCPTools:       mcpTools,
+		OnDelta:        toolDelta,
+		WithHandoff:    handoffEnabled,
+		Citations:      citations,
+		ConversationID: conversationID,
+		UserID:         userID,
+	}
+	kbCitations := citations.len()
+	toolLabel := "联网"
+	if webSource == "" {
+		toolLabel = "工具调用"
+	}
+
 	// 1) 有知识库匹配：以知识库为主生成；若本回合允许联网，则用增强 prompt + 联网工具，由模型在无关/不足时用自身知识或联网
 	if ragContext != "" {
 		sources = append(sources, "knowledge_base")
-		if needWeb && useWeb {
-			webSource := "custom"
-			if s.embeddingConfigSvc != nil {
-				webSource, _ = s.embeddingConfigSvc.GetWebSearchSource()
+		if hasTools {
+			if webSource != "" {
+				enhancedMessage = s.buildRAGPromptWithWebOptional(userMessage, ragContext)
+			} else {
+				enhancedMessage = s.buildRAGPrompt(userMessage, ragContext)
 			}
-			enhancedMessage = s.buildRAGPromptWithWebOptional(userMessage, ragContext)
-			content, usedWeb, err := s.generateWithWebTools(context.Background(), provider, history, enhancedMessage, webSource, imageBase64, imageMimeType)
+			content, usedWeb, err := s.generateWithTools(genCtx, provider, history, enhancedMessage, imageBase64, imageMimeType, toolLoop)
 			if err != nil {
-				log.Printf("⚠️ RAG+联网（function calling）失败: %v，回退到仅 RAG", err)
+				if res := finishInterruptedTools(content, err, sources); res != nil {
+					return res, nil
+				}
+				log.Printf("⚠️ RAG+%s（function calling）失败: %v，回退到仅 RAG", toolLabel, err)
 				if s.systemLogSvc != nil {
 					_ = s.systemLogSvc.Create(CreateSystemLogInput{
 						Level:          "warn",
@@ -260,10 +388,12 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 						Source:         "backend",
 						ConversationID: &conversationID,
 						UserID:         &userID,
-						Message:        "RAG+联网失败，回退到仅RAG",
+						Message:        fmt.Sprintf("RAG+%s失败，回退到仅RAG", toolLabel),
 						Meta: map[string]interface{}{
 							"error":      err.Error(),
 							"web_source": webSource,
+							"http_tools": len(httpTools),
+							"mcp_tools":  len(mcpTools),
 							"ai_config":  config.ID,
 						},
 					})
@@ -271,6 +401,7 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 				if webSource == "vendor" && (strings.Contains(err.Error(), "web_search") || strings.Contains(err.Error(), "Supported values")) {
 					log.Printf("💡 提示：当前对话使用的 AI 配置接口不支持 type \"web_search\"。若需联网，请改用支持该能力的模型（如 Poixe），或在设置中将联网方式改为「自建」并配置 SERPER_API_KEY。")
 				}
+				citations.truncate(kbCitations)
 				enhancedMessage = s.buildRAGPrompt(userMessage, ragContext)
 			} else if content != "" {
 				sources = append(sources, "llm")
@@ -287,17 +418,20 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 						Source:         "backend",
 						ConversationID: &convID,
 						UserID:         &uID,
-						Message:        "RAG+联网生成成功",
+						Message:        fmt.Sprintf("RAG+%s生成成功", toolLabel),
 						Meta: map[string]interface{}{
 							"sources": strings.Join(sources, ","),
 						},
 					})
 				}
-				return &GenerateAIResponseResult{
+				flushMarker()
+				return applyHandoffIntent(withGenerationMeta(&GenerateAIResponseResult{
 					Content:     content,
 					SourcesUsed: strings.Join(sources, ","),
-				}, nil
+					Citations:   citations.forAnswer(content),
+				}, provider, tracker), handoffEnabled), nil
 			} else {
+				citations.truncate(kbCitations)
 				enhancedMessage = s.buildRAGPrompt(userMessage, ragContext)
 			}
 		} else {
@@ -305,14 +439,13 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 		}
 	} else {
 		// 2) 无知识库匹配：本回合允许联网时走「模型决定搜」function calling；否则仅用大模型知识
-		if needWeb && useWeb {
-			webSource := "custom"
-			if s.embeddingConfigSvc != nil {
-				webSource, _ = s.embeddingConfigSvc.GetWebSearchSource()
-			}
-			content, usedWeb, err := s.generateWithWebTools(context.Background(), provider, history, userMessage, webSource, imageBase64, imageMimeType)
+		if hasTools {
+			content, usedWeb, err := s.generateWithTools(genCtx, provider, history, userMessage, imageBase64, imageMimeType, toolLoop)
 			if err != nil {
-				log.Printf("⚠️ 联网（function calling）失败: %v，回退到仅大模型", err)
+				if res := finishInterruptedTools(content, err, sources); res != nil {
+					return res, nil
+				}
+				log.Printf("⚠️ %s（function calling）失败: %v，回退到仅大模型", toolLabel, err)
 				if s.systemLogSvc != nil {
 					_ = s.systemLogSvc.Create(CreateSystemLogInput{
 						Level:          "warn",
@@ -321,10 +454,12 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 						Source:         "backend",
 						ConversationID: &conversationID,
 						UserID:         &userID,
-						Message:        "联网失败，回退到仅大模型",
+						Message:        fmt.Sprintf("%s失败，回退到仅大模型", toolLabel),
 						Meta: map[string]interface{}{
 							"error":      err.Error(),
 							"web_source": webSource,
+							"http_tools": len(httpTools),
+							"mcp_tools":  len(mcpTools),
 							"ai_config":  config.ID,
 						},
 					})
@@ -332,6 +467,7 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 				if webSource == "vendor" && (strings.Contains(err.Error(), "web_search") || strings.Contains(err.Error(), "Supported values")) {
 					log.Printf("💡 提示：当前对话使用的 AI 配置接口不支持 type \"web_search\"。若需联网，请改用支持该能力的模型（如 Poixe），或在设置中将联网方式改为「自建」并配置 SERPER_API_KEY。")
 				}
+				citations.truncate(kbCitations)
 			} else if content != "" {
 				sources = append(sources, "llm")
 				if usedWeb {
@@ -347,16 +483,18 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 						Source:         "backend",
 						ConversationID: &convID,
 						UserID:         &uID,
-						Message:        "联网生成成功",
+						Message:        fmt.Sprintf("%s生成成功", toolLabel),
 						Meta: map[string]interface{}{
 							"sources": strings.Join(sources, ","),
 						},
 					})
 				}
-				return &GenerateAIResponseResult{
+				flushMarker()
+				return applyHandoffIntent(withGenerationMeta(&GenerateAIResponseResult{
 					Content:     content,
 					SourcesUsed: strings.Join(sources, ","),
-				}, nil
+					Citations:   citations.forAnswer(content),
+				}, provider, tracker), handoffEnabled), nil
 			}
 		}
 		if useLLM && len(sources) == 0 {
@@ -376,7 +514,19 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 		}, nil
 	}
 
-	response, err := provider.GenerateResponse(history, enhancedMessage, imageBase64, imageMimeType)
+	var response string
+	cancelled := false
+	if onDelta != nil {
+		response, err = provider.GenerateResponseStream(genCtx, history, enhancedMessage, imageBase64, imageMimeType, onDelta)
+		flushMarker()
+		// 被取消（停止生成、访客新消息、转人工）：不视为失败，已有部分内容时按部分内容落库
+		if err != nil && (genCtx.Err() != nil || errors.Is(err, context.Canceled)) {
+			err = nil
+			cancelled = true
+		}
+	} else {
+		response, err = provider.GenerateResponse(history, enhancedMessage, imageBase64, imageMimeType)
+	}
 	if err != nil {
 		log.Printf("❌ AI 调用失败: %v", err)
 		if s.systemLogSvc != nil {
@@ -391,14 +541,15 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 				Meta: map[string]interface{}{
 					"error":     err.Error(),
 					"ai_config": config.ID,
+					"attempts":  len(provider.Attempts()),
 				},
 			})
 		}
-		return &GenerateAIResponseResult{
+		return withGenerationMeta(&GenerateAIResponseResult{
 			Content:          s.getAIFailReply(),
 			SourcesUsed:      strings.Join(sources, ","),
 			GenerationFailed: true,
-		}, nil
+		}, provider, tracker), nil
 	}
 	if s.systemLogSvc != nil {
 		convID := conversationID
@@ -416,15 +567,147 @@ func (s *AIService) GenerateAIResponseWithOptions(conversationID uint, userMessa
 			UserID:         &uID,
 			Message:        "AI 生成成功",
 			Meta: map[string]interface{}{
-				"sources": strings.Join(sources, ","),
+				"sources":   strings.Join(sources, ","),
+				"stream":    opts != nil && opts.OnDelta != nil,
+				"cancelled": cancelled,
+				"ai_config": answeredConfigID(provider, config.ID),
+				"model":     answeredModel(provider, config.Model),
 			},
 		})
 	}
 
-	return &GenerateAIResponseResult{
+	citations.truncate(kbCitations)
+	return applyHandoffIntent(withGenerationMeta(&GenerateAIResponseResult{
 		Content:     response,
 		SourcesUsed: strings.Join(sources, ","),
-	}, nil
+		Cancelled:   cancelled,
+		Citations:   citations.forAnswer(response),
+	}, provider, tracker), handoffEnabled), nil
+}
+
+// buildProvider 按单个 AI 配置创建提供商；onUsage 非空时每次调用回调 token 用量
+func (s *AIService) buildProvider(config *models.AIConfig, apiKey string, onUsage UsageRecorder) (AIProvider, error) {
+	var adapterConfig *AdapterConfig
+	if config.AdapterConfig != "" {
+		_ = json.Unmarshal([]byte(config.AdapterConfig), &adapterConfig)
+	}
+	return s.providerFactory.CreateProvider(AIConfig{
+		APIURL:        config.APIURL,
+		APIKey:        apiKey,
+		Model:         config.Model,
+		ModelType:     config.ModelType,
+		Provider:      config.Provider,
+		Protocol:      config.Protocol,
+		AdapterConfig: adapterConfig,
+		OnUsage:       onUsage,
+	})
+}
+
+// buildFailoverProvider 组装故障转移链：主配置在前，其后为 fallback_config_ids 中已启用的文本模型（去重，失效项跳过）
+func (s *AIService) buildFailoverProvider(config *models.AIConfig, apiKey string, tracker *usageTracker) (*failoverProvider, error) {
+	primary, err := s.buildProvider(config, apiKey, tracker.recorder(config.ID, config.Model))
+	if err != nil {
+		return nil, err
+	}
+	candidates := []failoverCandidate{{ConfigID: config.ID, Model: config.Model, Provider: primary}}
+	seen := map[uint]bool{config.ID: true}
+	for _, id := range utils.ParseUintList(config.FallbackConfigIDs) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		fallback, err := s.aiConfigRepo.GetByID(id)
+		if err != nil || !fallback.IsActive || fallback.ModelType != "text" {
+			continue
+		}
+		key, err := utils.DecryptAPIKey(fallback.APIKey)
+		if err != nil {
+			log.Printf("⚠️ 备用 AI 配置 %d 解密 API Key 失败: %v", id, err)
+			continue
+		}
+		p, err := s.buildProvider(fallback, key, tracker.recorder(fallback.ID, fallback.Model))
+		if err != nil {
+			log.Printf("⚠️ 备用 AI 配置 %d 创建提供商失败: %v", id, err)
+			continue
+		}
+		candidates = append(candidates, failoverCandidate{ConfigID: fallback.ID, Model: fallback.Model, Provider: p})
+	}
+	return newFailoverProvider(candidates, s.circuitBreaker), nil
+}
+
+// logFailover 本次生成发生过失败 / 熔断跳过时写入系统日志（含最终作答的模型）
+func (s *AIService) logFailover(provider *failoverProvider, conversationID uint, userID uint) {
+	attempts := provider.Attempts()
+	if len(attempts) == 0 || s.systemLogSvc == nil {
+		return
+	}
+	failures := make([]map[string]interface{}, 0, len(attempts))
+	for _, a := range attempts {
+		failures = append(failures, map[string]interface{}{
+			"ai_config":    a.ConfigID,
+			"model":        a.Model,
+			"error":        a.Error,
+			"skipped":      a.Skipped,
+			"circuit_open": a.CircuitOpen,
+		})
+		if a.CircuitOpen {
+			_ = s.systemLogSvc.Create(CreateSystemLogInput{
+				Level:          "warn",
+				Category:       "ai",
+				Event:          "ai_circuit_open",
+				Source:         "backend",
+				ConversationID: &conversationID,
+				UserID:         &userID,
+				Message:        "AI 配置连续失败，已熔断",
+				Meta: map[string]interface{}{
+					"ai_config": a.ConfigID,
+					"model":     a.Model,
+					"error":     a.Error,
+				},
+			})
+		}
+	}
+	meta := map[string]interface{}{"failures": failures}
+	message := "主模型不可用，未能切换到可用的备用模型"
+	if used := provider.Used(); used != nil {
+		meta["ai_config"] = used.ConfigID
+		meta["model"] = used.Model
+		message = fmt.Sprintf("主模型不可用，已由备用模型 %s 作答", used.Model)
+	}
+	_ = s.systemLogSvc.Create(CreateSystemLogInput{
+		Level:          "warn",
+		Category:       "ai",
+		Event:          "ai_failover",
+		Source:         "backend",
+		ConversationID: &conversationID,
+		UserID:         &userID,
+		Message:        message,
+		Meta:           meta,
+	})
+}
+
+// withGenerationMeta 在结果中记录实际作答的 AI 配置与模型，以及本次生成的 token 用量与费用
+func withGenerationMeta(res *GenerateAIResponseResult, provider *failoverProvider, tracker *usageTracker) *GenerateAIResponseResult {
+	if used := provider.Used(); used != nil {
+		res.AIConfigID = used.ConfigID
+		res.AIModel = used.Model
+	}
+	res.Usage = tracker.result()
+	return res
+}
+
+func answeredConfigID(provider *failoverProvider, def uint) uint {
+	if used := provider.Used(); used != nil {
+		return used.ConfigID
+	}
+	return def
+}
+
+func answeredModel(provider *failoverProvider, def string) string {
+	if used := provider.Used(); used != nil {
+		return used.Model
+	}
+	return def
 }
 
 // GenerateImageReply 生图渠道专用：根据用户描述生成图片并保存到存储，返回说明文案与图片 URL。
@@ -450,19 +733,8 @@ func (s *AIService) GenerateImageReply(conversationID uint, prompt string, userI
 	if err != nil {
 		return nil, fmt.Errorf("解密 API Key 失败: %v", err)
 	}
-	var adapterConfig *AdapterConfig
-	if config.AdapterConfig != "" {
-		_ = json.Unmarshal([]byte(config.AdapterConfig), &adapterConfig)
-	}
-	aiConfig := AIConfig{
-		APIURL:        config.APIURL,
-		APIKey:        apiKey,
-		Model:         config.Model,
-		ModelType:     config.ModelType,
-		Provider:      config.Provider,
-		AdapterConfig: adapterConfig,
-	}
-	provider, err := s.providerFactory.CreateProvider(aiConfig)
+	tracker := s.usageSvc.newTracker(conversationID)
+	provider, err := s.buildProvider(config, apiKey, tracker.recorder(config.ID, config.Model))
 	if err != nil {
 		return nil, err
 	}
@@ -490,6 +762,9 @@ func (s *AIService) GenerateImageReply(conversationID uint, prompt string, userI
 		Content:          content,
 		SourcesUsed:      "",
 		GeneratedFileURL: &fileURL,
+		AIConfigID:       config.ID,
+		AIModel:          config.Model,
+		Usage:            tracker.result(),
 	}, nil
 }
 
@@ -558,101 +833,97 @@ func (s *AIService) getAIFailReply() string {
 	return "AI客服好像出了点差错，请联系人工客服解决"
 }
 
-// buildConversationHistory 构建对话历史（用于 AI 上下文）。
-func (s *AIService) buildConversationHistory(conversationID uint) ([]MessageHistory, error) {
-	// 获取最近的对话消息（最多 10 条，避免上下文过长）
-	messages, err := s.messageRepo.ListByConversationID(conversationID)
-	if err != nil {
-		return nil, err
-	}
-
-	// 只取最近 10 条消息
-	startIdx := 0
-	if len(messages) > 10 {
-		startIdx = len(messages) - 10
-	}
-
-	history := make([]MessageHistory, 0)
-	for i := startIdx; i < len(messages); i++ {
-		msg := messages[i]
-		// 跳过系统消息
-		if msg.MessageType == "system_message" {
-			continue
-		}
-
-		role := "user"
-		if msg.SenderIsAgent {
-			role = "assistant"
-		}
-
-		history = append(history, MessageHistory{
-			Role:    role,
-			Content: msg.Content,
-		})
-	}
-
-	return history, nil
-}
-
 // retrieveRAGContext 从知识库中检索相关文档内容。
-// 优先匹配 FAQ（关键词/问题精确匹配），命中后直接返回 FAQ 答案并标记 isFAQ=true，由调用方跳过 LLM。
+// 优先按语义匹配 FAQ（见 matchFAQ）：高置信命中时直接返回 FAQ 答案并标记 isFAQ=true，由调用方跳过 LLM；
+// 相近但未达直接回答阈值的 FAQ 作为参考资料排在知识库片段之前，交由模型组织回答。
+// 开启查询改写时先结合最近对话把追问改写为独立问题（可拆出子问题），FAQ 按改写后的主问题匹配，知识库按各查询分别检索后 RRF 融合。
+// 会话绑定了知识库范围（conversation.KnowledgeBaseIDs）时，FAQ 与向量检索均限定在该范围内。
+// 命中的 FAQ / 文档片段登记到 citations（按编号），参考内容以「[n] 《标题》」为前缀供模型标注引用。
 // 返回: (检索到的文档内容, 是否来自FAQ, 错误)
-func (s *AIService) retrieveRAGContext(ctx context.Context, query string, conversation *models.Conversation) (string, bool, error) {
-	// FAQ 优先匹配：命中直接返回答案，跳过向量检索和 LLM
+func (s *AIService) retrieveRAGContext(ctx context.Context, query string, conversation *models.Conversation, citations *citationCollector) (string, bool, error) {
+	kbScope := conversationKnowledgeBaseScope(conversation)
+	queries := s.rewriteRetrievalQueries(ctx, query, conversation)
+
+	// FAQ 优先匹配：高置信命中直接返回答案，跳过向量检索和 LLM
+	var relatedFAQs []faqCandidate
 	if s.faqRepo != nil {
-		if answer, hit := s.matchFAQ(query); hit {
-			return answer, true, nil
+		match := s.matchFAQ(ctx, queries[0], conversation, kbScope)
+		if hit := match.Direct; hit != nil {
+			kbID := uint(0)
+			if hit.FAQ.KnowledgeBaseID != nil {
+				kbID = *hit.FAQ.KnowledgeBaseID
+			}
+			citations.add(models.MessageCitation{
+				SourceType:      "faq",
+				DocumentID:      hit.FAQ.ID,
+				KnowledgeBaseID: kbID,
+				Title:           hit.FAQ.Question,
+				Score:           hit.Score,
+			})
+			return hit.FAQ.Answer, true, nil
 		}
+		relatedFAQs = match.Related
 	}
-
-	// 确定知识库 ID（可以从对话中获取，或为空表示搜索所有知识库）
-	// TODO: 后续在 Conversation 模型增加 KnowledgeBaseID 字段
-	var knowledgeBaseID *uint
-	// knowledgeBaseID = conversation.KnowledgeBaseID // 暂时注释，等模型字段添加后启用
+	faqContext := s.addFAQContext(citations, relatedFAQs)
 
 	// 执行 RAG 检索（Top-K = 5，返回最相关的 5 个文档片段）
 	topK := 5
-	results, err := s.retrievalService.RetrieveWithRerank(ctx, query, topK, knowledgeBaseID)
+	opts := rag.RetrieveOptions{Mode: rag.RetrievalModeVector}
+	if s.embeddingConfigSvc != nil {
+		if mode, err := s.embeddingConfigSvc.GetRetrievalMode(); err == nil {
+			opts.Mode = mode
+		}
+	}
+	if len(kbScope) > 0 {
+		// 未归属知识库的全局 FAQ 向量以 knowledge_base_id=0 存储，限定范围时一并纳入
+		opts.KnowledgeBaseIDs = append(append([]uint{}, kbScope...), 0)
+	}
+	results, err := s.retrieveForQueries(ctx, queries, topK, opts)
 	if err != nil {
-		return "", false, fmt.Errorf("RAG 检索失败: %w", err)
+		return faqContext, false, fmt.Errorf("RAG 检索失败: %w", err)
 	}
 
+	results = excludeFAQResults(results, relatedFAQs)
 	if len(results) == 0 {
-		return "", false, nil
+		return faqContext, false, nil
 	}
 
 	// 格式化检索结果（已由 RetrievalService 做 score 阈值过滤）
-	var contextParts []string
-	for i, result := range results {
-		contextParts = append(contextParts, fmt.Sprintf("文档片段 %d:\n%s", i+1, result.Content))
+	knowledge := s.addKnowledgeResults(citations, results)
+	if faqContext == "" {
+		return knowledge, false, nil
 	}
-
-	return strings.Join(contextParts, "\n\n"), false, nil
+	return faqContext + "\n\n" + knowledge, false, nil
 }
 
-// matchFAQ 尝试将用户查询与 FAQ 条目做关键词/子串匹配。
-// 返回 FAQ 答案和是否命中。命中时跳过 LLM，直接返回标准答案。
-func (s *AIService) matchFAQ(query string) (answer string, hit bool) {
-	faqs, err := s.faqRepo.List(nil)
-	if err != nil || len(faqs) == 0 {
-		return "", false
+// retrieveForQueries 对每个查询分别检索（含重排序），多个查询时按 RRF 融合取前 topK；部分查询失败时使用其余结果
+func (s *AIService) retrieveForQueries(ctx context.Context, queries []string, topK int, opts rag.RetrieveOptions) ([]rag.SearchResult, error) {
+	if len(queries) == 1 {
+		return s.retrievalService.RetrieveWithRerankOptions(ctx, queries[0], topK, opts)
 	}
-	queryLower := strings.ToLower(strings.TrimSpace(query))
-	for _, faq := range faqs {
-		faqQuestion := strings.ToLower(strings.TrimSpace(faq.Question))
-		if strings.Contains(queryLower, faqQuestion) || strings.Contains(faqQuestion, queryLower) {
-			return faq.Answer, true
-		}
-		if faq.Keywords != "" {
-			for _, kw := range strings.Split(faq.Keywords, ",") {
-				kw = strings.ToLower(strings.TrimSpace(kw))
-				if kw != "" && strings.Contains(queryLower, kw) {
-					return faq.Answer, true
-				}
-			}
+	lists := make([][]rag.SearchResult, 0, len(queries))
+	var lastErr error
+	for _, q := range queries {
+		results, err := s.retrievalService.RetrieveWithRerankOptions(ctx, q, topK, opts)
+		if err != nil {
+			log.Printf("⚠️ 改写查询检索失败: query=%q err=%v", q, err)
+			lastErr = err
+			continue
 		}
+		lists = append(lists, results)
+	}
+	if len(lists) == 0 {
+		return nil, lastErr
+	}
+	return rag.FuseResults(topK, lists...), nil
+}
+
+// conversationKnowledgeBaseScope 返回会话限定的知识库 ID（nil 表示不限定）。
+func conversationKnowledgeBaseScope(conversation *models.Conversation) []uint {
+	if conversation == nil {
+		return nil
 	}
-	return "", false
+	return utils.ParseUintList(conversation.KnowledgeBaseIDs)
 }
 
 // buildRAGPrompt 构建包含 RAG 上下文的 Prompt
@@ -685,7 +956,8 @@ func (s *AIService) buildRAGPromptFallback(userMessage string, ragContext string
 2. 如果知识库中有相关信息，请直接引用并解释
 3. 如果知识库中没有相关信息，请诚实告知
 4. 保持友好、专业的语气
-5. 回答要简洁明了，避免冗长`, ragContext, userMessage)
+5. 回答要简洁明了，避免冗长
+6. 引用知识库内容时，在相应句末用 [编号] 标注来源（编号即知识库内容中片段前的 [n]），不要编造编号`, ragContext, userMessage)
 }
 
 // replacePromptPlaceholders 将模板中的 {{rag_context}}、{{user_message}} 替换为实际值
@@ -720,10 +992,12 @@ func (s *AIService) buildRAGPromptWithWebOptionalFallback(userMessage string, ra
 1. 若知识库内容与问题明确相关，请基于知识库给出准确、简洁的回答。
 2. 若知识库内容与问题无关或仅弱相关，可先基于你自身的知识回答，不必拘泥于知识库。
 3. 若你自身知识仍不足以回答（例如需要最新资讯、实时数据），你可决定是否使用联网搜索获取信息后再回答。
-4. 保持友好、专业，回答简洁明了。`, ragContext, userMessage)
+4. 保持友好、专业，回答简洁明了。
+5. 引用知识库内容或联网结果时，在相应句末用 [编号] 标注来源（编号即内容前的 [n]），不要编造编号。`, ragContext, userMessage)
 }
 
-const maxWebToolRounds = 5
+// maxToolRounds 单次回复内工具调用的最大轮数
+const maxToolRounds = 5
 
 // webSearchToolDefinition 返回 type: "function" 的 web_search 工具定义，仅用于「自建」联网（Serper 执行）。
 func (s *AIService) webSearchToolDefinition() []map[string]interface{} {
@@ -745,45 +1019,77 @@ func (s *AIService) webSearchToolDefinition() []map[string]interface{} {
 	}
 }
 
-// generateWithWebTools 使用 function calling 做联网（模型决定是否搜）。webSource: vendor / custom。
+// toolLoopInput 工具调用循环的参数
+type toolLoopInput struct {
+	WebSource      string             // vendor / custom；为空表示本回合不联网
+	HTTPTools      []models.AITool    // AI 配置白名单内的 HTTP 工具
+	MCPTools       []MCPToolBinding   // AI 配置白名单内 MCP 服务中选中的工具
+	OnDelta        func(string)       // 非空时流式输出：工具结果回填后的作答轮流式生成
+	WithHandoff    bool               // 额外提供 transfer_to_human 工具，模型调用时返回转人工标记
+	Citations      *citationCollector // 联网结果登记为引用来源（编号接在知识库之后）
+	ConversationID uint
+	UserID         uint
+}
+
+// generateWithTools 使用 function calling 生成回复（模型决定是否调用工具），最多 maxToolRounds 轮。
 // 联网请求始终发往当前对话的「AI 配置」对话接口（与知识库向量配置/embedding 无关）。
 // - vendor（模式一：厂商内置）：在 tools 里传 type "web_search"，由厂商在自家 API 内封装并执行搜索，无需自建。
 // - custom（模式二：自建）：在 tools 里传 type "function" 的自定义函数（如 web_search），由本服务调用 Serper 等执行并回填。
-func (s *AIService) generateWithWebTools(ctx context.Context, provider AIProvider, history []MessageHistory, userMessage string, webSource string, imageBase64 string, imageMimeType string) (content string, usedWeb bool, err error) {
+// HTTP 工具由本服务按管理员定义的模板请求外部接口，MCP 工具经 MCP 会话转发给对应服务，结果回填给模型，每次调用写入系统日志。
+// 没有任何可用工具时返回空内容，由调用方回退到普通生成。ctx 结束（访客取消、转人工）时返回 ctx.Err()。
+// 流式输出（in.OnDelta 非空）时：模型未调用工具直接作答则整段回调；调用工具后，回填结果的作答轮改为流式生成（该轮不再提供工具）。
+func (s *AIService) generateWithTools(ctx context.Context, provider AIProvider, history []MessageHistory, userMessage string, imageBase64 string, imageMimeType string, in toolLoopInput) (content string, usedWeb bool, err error) {
 	messages := s.historyToOpenAIMessages(history, userMessage, imageBase64, imageMimeType)
 	var tools []map[string]interface{}
 	useFunctionFormat := false
-	switch webSource {
+	switch in.WebSource {
 	case "vendor":
 		// 模式一：厂商内置，仅传 web_search，由厂商执行
-		tools = []map[string]interface{}{
-			{"type": "web_search"},
-		}
+		tools = append(tools, map[string]interface{}{"type": "web_search"})
 	case "custom":
-		if s.webSearchProvider == nil {
-			return "", false, nil
+		if s.webSearchProvider != nil {
+			useFunctionFormat = true
+			tools = append(tools, s.webSearchToolDefinition()...)
 		}
-		useFunctionFormat = true
-		tools = s.webSearchToolDefinition()
-	default:
-		tools = nil
+	}
+	httpTools := make(map[string]*models.AITool, len(in.HTTPTools))
+	for i := range in.HTTPTools {
+		tool := &in.HTTPTools[i]
+		httpTools[tool.Name] = tool
+		tools = append(tools, toolDefinition(*tool))
+	}
+	mcpTools := make(map[string]MCPToolBinding, len(in.MCPTools))
+	for _, binding := range in.MCPTools {
+		mcpTools[binding.ExposedName] = binding
+		tools = append(tools, mcpToolDefinition(binding))
 	}
 	if len(tools) == 0 {
 		return "", false, nil
 	}
+	if in.WithHandoff {
+		tools = append(tools, handoffToolDefinition())
+	}
 
 	rounds := 0
-	for rounds < maxWebToolRounds {
+	for rounds < maxToolRounds {
 		rounds++
-		respContent, toolCalls, callErr := provider.GenerateResponseWithTools(messages, tools)
+		respContent, toolCalls, callErr := generateWithToolsContext(ctx, provider, messages, tools)
 		if callErr != nil {
 			return "", usedWeb, callErr
 		}
 		if len(toolCalls) == 0 {
+			if in.OnDelta != nil && respContent != "" {
+				in.OnDelta(respContent)
+			}
 			return respContent, usedWeb, nil
 		}
-		if useFunctionFormat {
-			usedWeb = true
+		for _, tc := range toolCalls {
+			if tc.Name == handoffToolName {
+				if in.OnDelta != nil && strings.TrimSpace(respContent) != "" {
+					in.OnDelta(respContent)
+				}
+				return strings.TrimSpace(respContent + "\n" + handoffMarker), usedWeb, nil
+			}
 		}
 		// 追加 assistant 消息（含 tool_calls）
 		assistantMsg := map[string]interface{}{"role": "assistant", "content": respContent}
@@ -798,9 +1104,15 @@ func (s *AIService) generateWithWebTools(ctx context.Context, provider AIProvide
 		assistantMsg["tool_calls"] = tcList
 		messages = append(messages, assistantMsg)
 
+		var toolResults []string
 		for _, tc := range toolCalls {
 			toolResult := ""
-			if useFunctionFormat && tc.Name == "web_search" && s.webSearchProvider != nil {
+			if tool, ok := httpTools[tc.Name]; ok {
+				toolResult = s.callHTTPTool(ctx, tool, tc.Arguments, in)
+			} else if binding, ok := mcpTools[tc.Name]; ok {
+				toolResult = s.callMCPTool(ctx, binding, tc.Arguments, in)
+			} else if useFunctionFormat && tc.Name == "web_search" {
+				usedWeb = true
 				var args struct {
 					Query string `json:"query"`
 				}
@@ -809,16 +1121,111 @@ func (s *AIService) generateWithWebTools(ctx context.Context, provider AIProvide
 				if query == "" {
 					query = userMessage
 				}
-				toolResult, _ = s.webSearchProvider.Search(ctx, query)
+				toolResult = s.searchWebWithCitations(ctx, in.Citations, query)
+			} else {
+				toolResult = fmt.Sprintf("未知工具: %s", tc.Name)
 			}
 			messages = append(messages, map[string]interface{}{
 				"role":         "tool",
 				"tool_call_id": tc.ID,
 				"content":      toolResult,
 			})
+			toolResults = append(toolResults, fmt.Sprintf("[%s]\n%s", tc.Name, toolResult))
 		}
+		if ctx.Err() != nil {
+			return "", usedWeb, ctx.Err()
+		}
+		if in.OnDelta != nil {
+			// 流式作答：工具结果并入本轮提问，走 GenerateResponseStream（可随 ctx 取消）
+			out, streamErr := provider.GenerateResponseStream(ctx, history, toolResultsPrompt(userMessage, toolResults), imageBase64, imageMimeType, in.OnDelta)
+			return out, usedWeb, streamErr
+		}
+	}
+	return "", usedWeb, fmt.Errorf("工具调用超过 %d 轮", maxToolRounds)
+}
+
+// toolResultsPrompt 流式作答轮的提问：原问题 + 本次工具调用结果
+func toolResultsPrompt(userMessage string, toolResults []string) string {
+	return userMessage + "\n\n以下是为回答上述问题调用工具得到的结果，请据此直接作答（不要提及工具调用过程）：\n\n" + strings.Join(toolResults, "\n\n")
+}
+
+// generateWithToolsContext 与 GenerateResponseWithTools 相同，但 ctx 结束时立即返回 ctx.Err()；已发出的请求在后台自然结束
+func generateWithToolsContext(ctx context.Context, provider AIProvider, messages []map[string]interface{}, tools []map[string]interface{}) (string, []ToolCall, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	type result struct {
+		content   string
+		toolCalls []ToolCall
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		content, toolCalls, err := provider.GenerateResponseWithTools(messages, tools)
+		done <- result{content, toolCalls, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	case r := <-done:
+		return r.content, r.toolCalls, r.err
+	}
+}
+
+// callHTTPTool 执行一次 HTTP 工具调用并写入系统日志；失败时把错误说明回填给模型，由模型决定如何答复
+func (s *AIService) callHTTPTool(ctx context.Context, tool *models.AITool, arguments string, in toolLoopInput) string {
+	startedAt := time.Now()
+	result, err := s.toolSvc.Execute(ctx, tool, arguments)
+	return s.logToolCall(in, tool.Name, arguments, startedAt, result, err, map[string]interface{}{
+		"source":  "http",
+		"tool_id": tool.ID,
+	})
+}
+
+// callMCPTool 将一次工具调用转发给 MCP 服务并写入系统日志；失败时同样回填错误说明
+func (s *AIService) callMCPTool(ctx context.Context, binding MCPToolBinding, arguments string, in toolLoopInput) string {
+	startedAt := time.Now()
+	result, err := s.mcpSvc.CallTool(ctx, binding, arguments)
+	return s.logToolCall(in, binding.ExposedName, arguments, startedAt, result, err, map[string]interface{}{
+		"source":     "mcp",
+		"mcp_server": binding.ServerName,
+		"mcp_tool":   binding.ToolName,
+	})
+}
+
+// logToolCall 记录一次工具调用（ai_tool_call），返回回填给模型的内容
+func (s *AIService) logToolCall(in toolLoopInput, name string, arguments string, startedAt time.Time, result string, err error, meta map[string]interface{}) string {
+	if s.systemLogSvc != nil {
+		convID := in.ConversationID
+		uID := in.UserID
+		level := "info"
+		message := fmt.Sprintf("调用工具 %s", name)
+		meta["tool"] = name
+		meta["arguments"] = truncateRunes(arguments, 500)
+		meta["elapsed_ms"] = time.Since(startedAt).Milliseconds()
+		if err != nil {
+			level = "warn"
+			message = fmt.Sprintf("调用工具 %s 失败", name)
+			meta["error"] = err.Error()
+		} else {
+			meta["result"] = truncateRunes(result, 500)
+		}
+		_ = s.systemLogSvc.Create(CreateSystemLogInput{
+			Level:          level,
+			Category:       "ai",
+			Event:          "ai_tool_call",
+			Source:         "backend",
+			ConversationID: &convID,
+			UserID:         &uID,
+			Message:        message,
+			Meta:           meta,
+		})
+	}
+	if err != nil {
+		log.Printf("⚠️ 工具 %s 调用失败: %v", name, err)
+		return fmt.Sprintf("工具调用失败: %v", err)
 	}
-	return "", usedWeb, fmt.Errorf("联网工具调用超过 %d 轮", maxWebToolRounds)
+	return result
 }
 
 func (s *AIService) historyToOpenAIMessages(history []MessageHistory, userMessage string, imageBase64 string, imageMimeType string) []map[string]interface{} {
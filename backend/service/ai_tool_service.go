@@ -0,0 +1,452 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"github.com/2930134478/AI-CS/backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultToolTimeoutSeconds = 10
+	maxToolTimeoutSeconds     = 60
+	maxToolResponseBytes      = 1 << 20
+	// maxToolResultRunes 回填给模型的工具结果上限，避免大响应撑爆上下文
+	maxToolResultRunes = 4000
+)
+
+var (
+	toolNamePattern        = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,63}$`)
+	toolPlaceholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_]+)\s*\}\}`)
+	toolPathSegmentPattern = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+	toolPathIndexPattern   = regexp.MustCompile(`\d+`)
+	toolAllowedMethods     = map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+	// 内置工具名，HTTP 工具不可占用
+	reservedToolNames = map[string]bool{"web_search": true, handoffToolName: true}
+)
+
+// ErrAIToolNotFound 工具不存在
+var ErrAIToolNotFound = errors.New("工具不存在")
+
+// AIToolInput 新增 / 修改 HTTP 工具的参数
+type AIToolInput struct {
+	Name           string
+	Description    string
+	Parameters     string // JSON Schema，为空时为无参数
+	Method         string
+	URLTemplate    string
+	BodyTemplate   string
+	AuthHeader     string
+	AuthValue      *string // 明文；修改时 nil 表示保留原值，"" 表示清除
+	ResponsePath   string
+	TimeoutSeconds int
+	IsActive       *bool
+}
+
+// AIToolView 工具返回结构（不含认证头取值）
+type AIToolView struct {
+	models.AITool
+	HasAuthValue bool `json:"has_auth_value"`
+}
+
+// AIToolTestResult 工具试调用结果
+type AIToolTestResult struct {
+	Result    string `json:"result"`
+	Error     string `json:"error,omitempty"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+}
+
+// AIToolService 管理员定义的 HTTP 工具：维护定义、按 AI 配置白名单提供给模型，并执行模型发起的调用。
+type AIToolService struct {
+	toolRepo *repository.AIToolRepository
+}
+
+// NewAIToolService 创建工具服务实例。
+func NewAIToolService(toolRepo *repository.AIToolRepository) *AIToolService {
+	return &AIToolService{toolRepo: toolRepo}
+}
+
+func toAIToolView(tool *models.AITool) *AIToolView {
+	return &AIToolView{AITool: *tool, HasAuthValue: tool.AuthValue != ""}
+}
+
+// ListTools 返回全部工具
+func (s *AIToolService) ListTools() ([]AIToolView, error) {
+	tools, err := s.toolRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AIToolView, 0, len(tools))
+	for i := range tools {
+		out = append(out, *toAIToolView(&tools[i]))
+	}
+	return out, nil
+}
+
+// GetTool 返回单个工具
+func (s *AIToolService) GetTool(id uint) (*AIToolView, error) {
+	tool, err := s.getTool(id)
+	if err != nil {
+		return nil, err
+	}
+	return toAIToolView(tool), nil
+}
+
+func (s *AIToolService) getTool(id uint) (*models.AITool, error) {
+	tool, err := s.toolRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAIToolNotFound
+		}
+		return nil, err
+	}
+	return tool, nil
+}
+
+// CreateTool 新增工具（函数名全局唯一）
+func (s *AIToolService) CreateTool(input AIToolInput) (*AIToolView, error) {
+	tool := &models.AITool{IsActive: true}
+	if err := s.applyInput(tool, input); err != nil {
+		return nil, err
+	}
+	if _, err := s.toolRepo.GetByName(tool.Name); err == nil {
+		return nil, fmt.Errorf("工具名 %s 已存在", tool.Name)
+	}
+	if err := s.toolRepo.Create(tool); err != nil {
+		return nil, err
+	}
+	return toAIToolView(tool), nil
+}
+
+// UpdateTool 修改工具
+func (s *AIToolService) UpdateTool(id uint, input AIToolInput) (*AIToolView, error) {
+	tool, err := s.getTool(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyInput(tool, input); err != nil {
+		return nil, err
+	}
+	if existing, err := s.toolRepo.GetByName(tool.Name); err == nil && existing.ID != id {
+		return nil, fmt.Errorf("工具名 %s 已存在", tool.Name)
+	}
+	if err := s.toolRepo.Update(tool); err != nil {
+		return nil, err
+	}
+	return toAIToolView(tool), nil
+}
+
+// DeleteTool 删除工具（AI 配置白名单中的失效 ID 在加载时忽略）
+func (s *AIToolService) DeleteTool(id uint) error {
+	if _, err := s.getTool(id); err != nil {
+		return err
+	}
+	return s.toolRepo.Delete(id)
+}
+
+// ValidateToolIDs 校验 AI 配置的工具白名单，返回逗号分隔存储值
+func (s *AIToolService) ValidateToolIDs(ids []uint) (string, error) {
+	for _, id := range ids {
+		if _, err := s.getTool(id); err != nil {
+			return "", fmt.Errorf("工具 %d 不存在", id)
+		}
+	}
+	return utils.JoinUintList(ids), nil
+}
+
+// ToolsForConfig 返回 AI 配置白名单内已启用的工具
+func (s *AIToolService) ToolsForConfig(config *models.AIConfig) []models.AITool {
+	ids := utils.ParseUintList(config.AllowedToolIDs)
+	if len(ids) == 0 {
+		return nil
+	}
+	tools, err := s.toolRepo.ListActiveByIDs(ids)
+	if err != nil {
+		return nil
+	}
+	return tools
+}
+
+// TestTool 以给定参数（JSON 字符串）试调用工具，便于管理员配置时验证
+func (s *AIToolService) TestTool(ctx context.Context, id uint, arguments string) (*AIToolTestResult, error) {
+	tool, err := s.getTool(id)
+	if err != nil {
+		return nil, err
+	}
+	startedAt := time.Now()
+	result, callErr := s.Execute(ctx, tool, arguments)
+	out := &AIToolTestResult{Result: result, ElapsedMS: time.Since(startedAt).Milliseconds()}
+	if callErr != nil {
+		out.Error = callErr.Error()
+	}
+	return out, nil
+}
+
+func (s *AIToolService) applyInput(tool *models.AITool, input AIToolInput) error {
+	name := strings.TrimSpace(input.Name)
+	if !toolNamePattern.MatchString(name) {
+		return errors.New("工具名须以字母开头，仅含字母、数字、下划线，最长 64 位")
+	}
+	if reservedToolNames[name] || strings.HasPrefix(name, mcpToolNamePrefix) {
+		return fmt.Errorf("工具名 %s 为内置工具或 MCP 工具保留", name)
+	}
+	description := strings.TrimSpace(input.Description)
+	if description == "" {
+		return errors.New("工具描述不能为空（模型据此判断何时调用）")
+	}
+	params, err := normalizeToolSchema(input.Parameters)
+	if err != nil {
+		return err
+	}
+	method := strings.ToUpper(strings.TrimSpace(input.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !toolAllowedMethods[method] {
+		return fmt.Errorf("不支持的请求方法: %s", method)
+	}
+	urlTemplate := strings.TrimSpace(input.URLTemplate)
+	if err := validateToolURLTemplate(urlTemplate); err != nil {
+		return err
+	}
+	timeout := input.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultToolTimeoutSeconds
+	}
+	if timeout > maxToolTimeoutSeconds {
+		return fmt.Errorf("超时时间不能超过 %d 秒", maxToolTimeoutSeconds)
+	}
+	if input.AuthValue != nil {
+		if *input.AuthValue == "" {
+			tool.AuthValue = ""
+		} else {
+			encrypted, err := utils.EncryptAPIKey(*input.AuthValue)
+			if err != nil {
+				return fmt.Errorf("加密认证信息失败: %v", err)
+			}
+			tool.AuthValue = encrypted
+		}
+	}
+	tool.Name = name
+	tool.Description = description
+	tool.Parameters = params
+	tool.Method = method
+	tool.URLTemplate = urlTemplate
+	tool.BodyTemplate = strings.TrimSpace(input.BodyTemplate)
+	tool.AuthHeader = strings.TrimSpace(input.AuthHeader)
+	tool.ResponsePath = strings.TrimSpace(input.ResponsePath)
+	tool.TimeoutSeconds = timeout
+	if input.IsActive != nil {
+		tool.IsActive = *input.IsActive
+	}
+	return nil
+}
+
+// normalizeToolSchema 校验参数 JSON Schema（须为 type=object），为空时返回无参数 schema
+func normalizeToolSchema(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return `{"type":"object","properties":{}}`, nil
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		return "", errors.New("参数 Schema 不是合法的 JSON 对象")
+	}
+	if t, ok := schema["type"]; ok && t != "object" {
+		return "", errors.New("参数 Schema 的 type 必须为 object")
+	}
+	schema["type"] = "object"
+	if _, ok := schema["properties"]; !ok {
+		schema["properties"] = map[string]interface{}{}
+	}
+	normalized, _ := json.Marshal(schema)
+	return string(normalized), nil
+}
+
+// validateToolURLTemplate 要求 http(s) 地址，且占位符不能出现在协议与主机部分（防止模型参数改写请求目标）
+func validateToolURLTemplate(tpl string) error {
+	if tpl == "" {
+		return errors.New("URL 模板不能为空")
+	}
+	scheme, rest, ok := strings.Cut(tpl, "://")
+	if !ok || (scheme != "http" && scheme != "https") {
+		return errors.New("URL 模板须以 http:// 或 https:// 开头")
+	}
+	host := rest
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		host = rest[:i]
+	}
+	if host == "" || strings.Contains(host, "{{") {
+		return errors.New("URL 模板的主机部分不能为空或包含参数占位符")
+	}
+	return nil
+}
+
+// toolDefinition 转为 OpenAI function 工具定义
+func toolDefinition(tool models.AITool) map[string]interface{} {
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(tool.Parameters), &params); err != nil || params == nil {
+		params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        tool.Name,
+			"description": tool.Description,
+			"parameters":  params,
+		},
+	}
+}
+
+// Execute 按模板发起 HTTP 请求，返回（按 response_path 提取并截断后的）结果文本
+func (s *AIToolService) Execute(ctx context.Context, tool *models.AITool, arguments string) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	args := parseToolArguments(arguments)
+	timeout := tool.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultToolTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	target := renderToolTemplate(tool.URLTemplate, args, func(v interface{}) string {
+		// QueryEscape 的空格为 +，路径中需为 %20；其余保留字符均被转义，参数无法注入新的路径 / 查询参数
+		return strings.ReplaceAll(url.QueryEscape(toolArgString(v)), "+", "%20")
+	})
+	var body io.Reader
+	method := tool.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodDelete {
+		payload := []byte(renderToolTemplate(tool.BodyTemplate, args, toolArgJSON))
+		if tool.BodyTemplate == "" {
+			payload, _ = json.Marshal(args)
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if tool.AuthHeader != "" && tool.AuthValue != "" {
+		authValue, err := utils.DecryptAPIKey(tool.AuthValue)
+		if err != nil {
+			return "", fmt.Errorf("解密认证信息失败: %v", err)
+		}
+		req.Header.Set(tool.AuthHeader, authValue)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("请求超时（%d 秒）", timeout)
+		}
+		return "", fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxToolResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("接口返回状态码 %d: %s", resp.StatusCode, truncateRunes(string(data), 200))
+	}
+	result := string(data)
+	if tool.ResponsePath != "" {
+		var parsed interface{}
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return "", errors.New("响应不是 JSON，无法按 response_path 提取")
+		}
+		value, ok := extractJSONPath(parsed, tool.ResponsePath)
+		if !ok {
+			return "", fmt.Errorf("响应中不存在路径 %s", tool.ResponsePath)
+		}
+		result = toolArgString(value)
+	}
+	return truncateRunes(result, maxToolResultRunes), nil
+}
+
+// renderToolTemplate 替换 {{name}} 占位符；缺失的参数替换为空
+func renderToolTemplate(tpl string, args map[string]interface{}, encode func(v interface{}) string) string {
+	return toolPlaceholderPattern.ReplaceAllStringFunc(tpl, func(m string) string {
+		name := toolPlaceholderPattern.FindStringSubmatch(m)[1]
+		v, ok := args[name]
+		if !ok || v == nil {
+			return ""
+		}
+		return encode(v)
+	})
+}
+
+// toolArgString 参数 / 结果值转为文本（字符串原样，数字不带多余小数，其余为 JSON）
+func toolArgString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case nil:
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// toolArgJSON 请求体模板中的取值：字符串按 JSON 转义（不含引号，模板中自行加引号），其余为 JSON 字面量
+func toolArgJSON(v interface{}) string {
+	if str, ok := v.(string); ok {
+		data, _ := json.Marshal(str)
+		return string(data[1 : len(data)-1])
+	}
+	return toolArgString(v)
+}
+
+// extractJSONPath 按 a.b[0].c 形式的路径取值
+func extractJSONPath(data interface{}, path string) (interface{}, bool) {
+	current := data
+	for _, segment := range strings.Split(strings.TrimSpace(path), ".") {
+		m := toolPathSegmentPattern.FindStringSubmatch(segment)
+		if m == nil {
+			return nil, false
+		}
+		if m[1] != "" {
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = obj[m[1]]; !ok {
+				return nil, false
+			}
+		}
+		for _, idx := range toolPathIndexPattern.FindAllString(m[2], -1) {
+			list, ok := current.([]interface{})
+			i, _ := strconv.Atoi(idx)
+			if !ok || i >= len(list) {
+				return nil, false
+			}
+			current = list[i]
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/infra/translate"
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+// 常见语言代码对应的名称（写入翻译指令，便于模型理解目标语言）
+var languageNames = map[string]string{
+	"zh": "简体中文",
+	"en": "English",
+	"ja": "日本語",
+	"ko": "한국어",
+	"fr": "Français",
+	"de": "Deutsch",
+	"es": "Español",
+	"pt": "Português",
+	"ru": "Русский",
+	"it": "Italiano",
+	"ar": "العربية",
+	"th": "ไทย",
+	"vi": "Tiếng Việt",
+	"id": "Bahasa Indonesia",
+}
+
+// languageName 返回语言代码的可读名称；繁体中文单独处理，未知代码原样返回
+func languageName(code string) string {
+	lower := strings.ToLower(strings.TrimSpace(code))
+	if strings.HasPrefix(lower, "zh-tw") || strings.HasPrefix(lower, "zh-hk") || strings.HasPrefix(lower, "zh-hant") {
+		return "繁體中文"
+	}
+	if name, ok := languageNames[translate.BaseLanguage(code)]; ok {
+		return name
+	}
+	return code
+}
+
+// aiTranslator 使用 AI 文本模型翻译（实现 translate.Translator），用量计入所属会话
+type aiTranslator struct {
+	ai             *AIService
+	config         *models.AIConfig
+	conversationID uint
+}
+
+func (t *aiTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	return t.ai.TranslateText(ctx, t.conversationID, t.config, text, source, target)
+}
+
+// TranslateText 使用指定 AI 配置将文本译为目标语言（source 为空表示自动识别），仅返回译文。
+func (s *AIService) TranslateText(ctx context.Context, conversationID uint, config *models.AIConfig, text, source, target string) (string, error) {
+	if config == nil {
+		return "", errors.New("未配置翻译使用的 AI 模型")
+	}
+	apiKey, err := utils.DecryptAPIKey(config.APIKey)
+	if err != nil {
+		return "", fmt.Errorf("解密 API Key 失败: %v", err)
+	}
+	provider, err := s.buildFailoverProvider(config, apiKey, s.usageSvc.newTracker(conversationID))
+	if err != nil {
+		return "", fmt.Errorf("创建 AI 提供商失败: %v", err)
+	}
+	defer s.logFailover(provider, conversationID, 0)
+
+	var b strings.Builder
+	b.WriteString("你是客服对话的翻译。请将【原文】")
+	if source != "" {
+		b.WriteString("（")
+		b.WriteString(languageName(source))
+		b.WriteString("）")
+	}
+	b.WriteString("翻译为")
+	b.WriteString(languageName(target))
+	b.WriteString("。\n要求：忠实原意，保留语气、数字、链接、订单号等专有信息，不要添加解释；原文已是目标语言时原样输出。只输出译文。\n\n【原文】\n")
+	b.WriteString(text)
+
+	out, err := provider.GenerateResponseContext(ctx, nil, b.String())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
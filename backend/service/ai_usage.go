@@ -0,0 +1,119 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+// 用量类型（AIUsageRecord.Kind）
+const (
+	UsageKindChat      = "chat"      // 普通 / 流式对话
+	UsageKindTools     = "tools"     // 工具调用轮次（含联网 function calling）
+	UsageKindImage     = "image"     // 生图
+	UsageKindEmbedding = "embedding" // 向量化
+)
+
+// TokenUsage 单次模型调用的用量
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Images           int
+	Estimated        bool // 接口未返回 usage，按字符估算
+}
+
+// IsZero 是否没有任何用量
+func (u TokenUsage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0 && u.Images == 0
+}
+
+// UsageRecorder 模型调用用量回调（由 AIService 按 AI 配置注入，provider 每次调用结束后回调）
+type UsageRecorder func(kind string, usage TokenUsage)
+
+// reportUsage 回调用量；接口未返回 usage 时按请求消息与回复文本估算（无回复文本的失败调用不估算）
+func (c AIConfig) reportUsage(kind string, usage TokenUsage, messages []map[string]interface{}, completion string) {
+	if c.OnUsage == nil {
+		return
+	}
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 && completion != "" {
+		for _, msg := range messages {
+			usage.PromptTokens += utils.EstimateTokens(openAIContentText(msg["content"]))
+		}
+		usage.CompletionTokens = utils.EstimateTokens(completion)
+		usage.Estimated = true
+	}
+	if usage.IsZero() {
+		return
+	}
+	c.OnUsage(kind, usage)
+}
+
+// parseUsage 从响应中提取用量，兼容：
+// OpenAI Chat Completions usage.prompt_tokens / completion_tokens，
+// Responses API 与 Anthropic usage.input_tokens / output_tokens（流式事件可能嵌在 response / message 内），
+// Gemini usageMetadata.promptTokenCount / candidatesTokenCount。
+func parseUsage(data map[string]interface{}) TokenUsage {
+	if data == nil {
+		return TokenUsage{}
+	}
+	if meta, ok := data["usageMetadata"].(map[string]interface{}); ok {
+		return TokenUsage{
+			PromptTokens:     intFromJSON(meta["promptTokenCount"]),
+			CompletionTokens: intFromJSON(meta["candidatesTokenCount"]) + intFromJSON(meta["thoughtsTokenCount"]),
+		}
+	}
+	usage, ok := data["usage"].(map[string]interface{})
+	if !ok {
+		for _, key := range []string{"response", "message"} {
+			if nested, ok := data[key].(map[string]interface{}); ok {
+				if u := parseUsage(nested); !u.IsZero() {
+					return u
+				}
+			}
+		}
+		return TokenUsage{}
+	}
+	out := TokenUsage{
+		PromptTokens:     intFromJSON(usage["prompt_tokens"]),
+		CompletionTokens: intFromJSON(usage["completion_tokens"]),
+	}
+	if out.PromptTokens == 0 {
+		out.PromptTokens = intFromJSON(usage["input_tokens"])
+	}
+	if out.CompletionTokens == 0 {
+		out.CompletionTokens = intFromJSON(usage["output_tokens"])
+	}
+	return out
+}
+
+// parseUsageJSON 同 parseUsage，输入为原始响应体
+func parseUsageJSON(data []byte) TokenUsage {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return TokenUsage{}
+	}
+	return parseUsage(parsed)
+}
+
+// mergeStreamUsage 合并流式事件中的用量：各字段取最新的非零值（Anthropic 输入 / 输出分别在 message_start / message_delta 中给出）
+func mergeStreamUsage(acc *TokenUsage, next TokenUsage) {
+	if next.PromptTokens > 0 {
+		acc.PromptTokens = next.PromptTokens
+	}
+	if next.CompletionTokens > 0 {
+		acc.CompletionTokens = next.CompletionTokens
+	}
+}
+
+func intFromJSON(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	}
+	return 0
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"github.com/2930134478/AI-CS/backend/service/embedding"
+	"gorm.io/gorm"
+)
+
+// ErrModelPriceNotFound 价格不存在
+var ErrModelPriceNotFound = errors.New("模型价格不存在")
+
+// RecordUsageInput 写入一条用量记录的参数
+type RecordUsageInput struct {
+	AIConfigID     *uint
+	Model          string
+	Kind           string
+	ConversationID *uint
+	Usage          TokenUsage
+}
+
+// ModelPriceInput 新增 / 修改模型价格的参数（单价为每百万 token / 每张图）
+type ModelPriceInput struct {
+	Model       string
+	InputPrice  float64
+	OutputPrice float64
+	ImagePrice  float64
+	Currency    string
+}
+
+// AIUsageSummary 一次回复生成内全部模型调用的用量汇总
+type AIUsageSummary struct {
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	RecordIDs        []uint // 对应的用量记录，AI 消息落库后回填 message_id
+}
+
+// AIUsageService 模型调用用量与费用：按价格表计价、写入用量记录，并在 AI 配置超出月预算时自动停用。
+type AIUsageService struct {
+	usageRepo    *repository.AIUsageRepository
+	priceRepo    *repository.AIModelPriceRepository
+	aiConfigRepo *repository.AIConfigRepository
+	systemLogSvc *SystemLogService // 可选
+}
+
+// NewAIUsageService 创建用量服务实例。
+func NewAIUsageService(usageRepo *repository.AIUsageRepository, priceRepo *repository.AIModelPriceRepository, aiConfigRepo *repository.AIConfigRepository, systemLogSvc *SystemLogService) *AIUsageService {
+	return &AIUsageService{
+		usageRepo:    usageRepo,
+		priceRepo:    priceRepo,
+		aiConfigRepo: aiConfigRepo,
+		systemLogSvc: systemLogSvc,
+	}
+}
+
+// Record 计价并写入一条用量记录；带 AI 配置时检查当月预算。
+func (s *AIUsageService) Record(input RecordUsageInput) (*models.AIUsageRecord, error) {
+	record := &models.AIUsageRecord{
+		AIConfigID:       input.AIConfigID,
+		Model:            input.Model,
+		Kind:             input.Kind,
+		ConversationID:   input.ConversationID,
+		PromptTokens:     input.Usage.PromptTokens,
+		CompletionTokens: input.Usage.CompletionTokens,
+		Images:           input.Usage.Images,
+		Estimated:        input.Usage.Estimated,
+		Cost:             s.computeCost(input.Model, input.Usage),
+	}
+	if err := s.usageRepo.Create(record); err != nil {
+		return nil, err
+	}
+	if input.AIConfigID != nil && record.Cost > 0 {
+		s.enforceMonthlyBudget(*input.AIConfigID)
+	}
+	return record, nil
+}
+
+// AttachToMessage 将一次生成的用量记录关联到 AI 回复消息
+func (s *AIUsageService) AttachToMessage(summary *AIUsageSummary, messageID uint) {
+	if summary == nil || len(summary.RecordIDs) == 0 || messageID == 0 {
+		return
+	}
+	if err := s.usageRepo.AttachMessage(summary.RecordIDs, messageID); err != nil {
+		log.Printf("⚠️ 关联用量记录到消息失败: %v", err)
+	}
+}
+
+// EmbeddingRecorder 返回向量化用量回调（不属于任何 AI 配置与会话）
+func (s *AIUsageService) EmbeddingRecorder() embedding.UsageRecorder {
+	return func(model string, tokens int, estimated bool) {
+		if tokens <= 0 {
+			return
+		}
+		if _, err := s.Record(RecordUsageInput{
+			Model: model,
+			Kind:  UsageKindEmbedding,
+			Usage: TokenUsage{PromptTokens: tokens, Estimated: estimated},
+		}); err != nil {
+			log.Printf("⚠️ 记录向量化用量失败: %v", err)
+		}
+	}
+}
+
+// computeCost 按价格表计价；未配置价格的模型费用记为 0
+func (s *AIUsageService) computeCost(model string, usage TokenUsage) float64 {
+	if model == "" {
+		return 0
+	}
+	price, err := s.priceRepo.GetByModel(model)
+	if err != nil {
+		return 0
+	}
+	return float64(usage.PromptTokens)*price.InputPrice/1e6 +
+		float64(usage.CompletionTokens)*price.OutputPrice/1e6 +
+		float64(usage.Images)*price.ImagePrice
+}
+
+// enforceMonthlyBudget 当月累计费用达到预算时停用该 AI 配置并写入系统日志
+func (s *AIUsageService) enforceMonthlyBudget(configID uint) {
+	config, err := s.aiConfigRepo.GetByID(configID)
+	if err != nil || config.MonthlyBudget <= 0 || !config.IsActive {
+		return
+	}
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	spent, err := s.usageRepo.SumCostByConfigSince(configID, monthStart)
+	if err != nil || spent < config.MonthlyBudget {
+		return
+	}
+	if err := s.aiConfigRepo.UpdateFields(configID, map[string]interface{}{"is_active": false}); err != nil {
+		log.Printf("⚠️ 停用超预算的 AI 配置 %d 失败: %v", configID, err)
+		return
+	}
+	log.Printf("⚠️ AI 配置 %d (%s) 本月费用 %.4f 已达预算 %.4f，已自动停用", configID, config.Model, spent, config.MonthlyBudget)
+	if s.systemLogSvc != nil {
+		_ = s.systemLogSvc.Create(CreateSystemLogInput{
+			Level:    "warn",
+			Category: "ai",
+			Event:    "ai_budget_exceeded",
+			Source:   "backend",
+			Message:  fmt.Sprintf("AI 配置 %s 本月费用已达预算，已自动停用", config.Model),
+			Meta: map[string]interface{}{
+				"ai_config": configID,
+				"model":     config.Model,
+				"spent":     spent,
+				"budget":    config.MonthlyBudget,
+			},
+		})
+	}
+}
+
+// ListPrices 返回价格表
+func (s *AIUsageService) ListPrices() ([]models.AIModelPrice, error) {
+	return s.priceRepo.List()
+}
+
+// CreatePrice 新增模型价格（同一模型仅一条）
+func (s *AIUsageService) CreatePrice(input ModelPriceInput) (*models.AIModelPrice, error) {
+	price := &models.AIModelPrice{}
+	if err := applyModelPriceInput(price, input); err != nil {
+		return nil, err
+	}
+	if _, err := s.priceRepo.GetByModel(price.Model); err == nil {
+		return nil, fmt.Errorf("模型 %s 的价格已存在", price.Model)
+	}
+	if err := s.priceRepo.Create(price); err != nil {
+		return nil, err
+	}
+	return price, nil
+}
+
+// UpdatePrice 修改模型价格
+func (s *AIUsageService) UpdatePrice(id uint, input ModelPriceInput) (*models.AIModelPrice, error) {
+	price, err := s.priceRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrModelPriceNotFound
+		}
+		return nil, err
+	}
+	if err := applyModelPriceInput(price, input); err != nil {
+		return nil, err
+	}
+	if existing, err := s.priceRepo.GetByModel(price.Model); err == nil && existing.ID != id {
+		return nil, fmt.Errorf("模型 %s 的价格已存在", price.Model)
+	}
+	if err := s.priceRepo.Update(price); err != nil {
+		return nil, err
+	}
+	return price, nil
+}
+
+// DeletePrice 删除模型价格（已产生的用量记录费用不变）
+func (s *AIUsageService) DeletePrice(id uint) error {
+	if _, err := s.priceRepo.GetByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrModelPriceNotFound
+		}
+		return err
+	}
+	return s.priceRepo.Delete(id)
+}
+
+func applyModelPriceInput(price *models.AIModelPrice, input ModelPriceInput) error {
+	model := strings.TrimSpace(input.Model)
+	if model == "" {
+		return errors.New("模型名称不能为空")
+	}
+	if input.InputPrice < 0 || input.OutputPrice < 0 || input.ImagePrice < 0 {
+		return errors.New("单价不能为负数")
+	}
+	currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+	if currency == "" {
+		currency = "USD"
+	}
+	price.Model = model
+	price.InputPrice = input.InputPrice
+	price.OutputPrice = input.OutputPrice
+	price.ImagePrice = input.ImagePrice
+	price.Currency = currency
+	return nil
+}
+
+// usageTracker 汇总一次回复生成内的全部模型调用（摘要、工具轮次、最终生成、故障转移的每个模型）并逐条落库
+type usageTracker struct {
+	svc            *AIUsageService
+	conversationID *uint
+
+	mu      sync.Mutex
+	summary AIUsageSummary
+}
+
+func (s *AIUsageService) newTracker(conversationID uint) *usageTracker {
+	if s == nil {
+		return nil
+	}
+	return &usageTracker{svc: s, conversationID: &conversationID}
+}
+
+// recorder 返回绑定到某 AI 配置的用量回调；tracker 为空时返回 nil（不统计）
+func (t *usageTracker) recorder(configID uint, model string) UsageRecorder {
+	if t == nil {
+		return nil
+	}
+	return func(kind string, usage TokenUsage) {
+		id := configID
+		record, err := t.svc.Record(RecordUsageInput{
+			AIConfigID:     &id,
+			Model:          model,
+			Kind:           kind,
+			ConversationID: t.conversationID,
+			Usage:          usage,
+		})
+		if err != nil {
+			log.Printf("⚠️ 记录 AI 用量失败: %v", err)
+			return
+		}
+		t.mu.Lock()
+		t.summary.PromptTokens += record.PromptTokens
+		t.summary.CompletionTokens += record.CompletionTokens
+		t.summary.Cost += record.Cost
+		t.summary.RecordIDs = append(t.summary.RecordIDs, record.ID)
+		t.mu.Unlock()
+	}
+}
+
+// result 返回汇总（无任何调用时为 nil）
+func (t *usageTracker) result() *AIUsageSummary {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.summary.RecordIDs) == 0 {
+		return nil
+	}
+	out := t.summary
+	out.RecordIDs = append([]uint(nil), t.summary.RecordIDs...)
+	return &out
+}
@@ -355,3 +355,213 @@ func (s *AnalyticsService) computeDailySeries(start, endExclusive time.Time) []A
 func round2(x float64) float64 {
 	return float64(int64(x*100+0.5)) / 100
 }
+
+// AICostReport 模型调用用量与费用报表（含访客、内部会话与向量化）
+type AICostReport struct {
+	From             string           `json:"from"`
+	To               string           `json:"to"`
+	Totals           AICostRow        `json:"totals"`
+	Daily            []AICostRow      `json:"daily"`
+	ByModel          []AICostRow      `json:"by_model"`
+	ByConfig         []AICostRow      `json:"by_config"`
+	ByKind           []AICostRow      `json:"by_kind"`
+	TopConversations []AICostRow      `json:"top_conversations"`
+	Budgets          []AIBudgetStatus `json:"budgets"`
+}
+
+// AICostRow 一个分组的用量汇总；Key 为日期 / 模型名 / 配置 ID / 用量类型 / 会话 ID
+type AICostRow struct {
+	Key              string  `json:"key"`
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// AIBudgetStatus 设置了月预算的 AI 配置的当月花费
+type AIBudgetStatus struct {
+	AIConfigID    uint    `json:"ai_config_id"`
+	Model         string  `json:"model"`
+	MonthlyBudget float64 `json:"monthly_budget"`
+	MonthSpent    float64 `json:"month_spent"`
+	IsActive      bool    `json:"is_active"`
+}
+
+const aiCostTopConversations = 20
+
+// GetAICost 查询 [fromDate, toDate] 闭区间内的模型用量与费用（按日 / 模型 / 配置 / 类型 / 会话聚合）
+func (s *AnalyticsService) GetAICost(fromDate, toDate string) (*AICostReport, error) {
+	start, endExclusive, err := parseInclusiveDateRange(fromDate, toDate, s.analyticsLoc)
+	if err != nil {
+		return nil, err
+	}
+	if !endExclusive.After(start) {
+		return nil, fmt.Errorf("结束日期须不早于开始日期")
+	}
+	out := &AICostReport{From: fromDate, To: toDate}
+	inRange := func() *gorm.DB {
+		return s.db.Model(&models.AIUsageRecord{}).
+			Where("created_at >= ? AND created_at < ?", start, endExclusive)
+	}
+	const sums = "COUNT(*) AS calls, COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens, COALESCE(SUM(cost), 0) AS cost"
+
+	inRange().Select(sums).Scan(&out.Totals)
+	out.Totals.Key = "total"
+
+	for d := start; d.Before(endExclusive); d = d.AddDate(0, 0, 1) {
+		var row AICostRow
+		s.db.Model(&models.AIUsageRecord{}).
+			Where("created_at >= ? AND created_at < ?", d, d.AddDate(0, 0, 1)).
+			Select(sums).Scan(&row)
+		row.Key = d.Format("2006-01-02")
+		out.Daily = append(out.Daily, row)
+	}
+
+	inRange().Select("model AS `key`, " + sums).Group("model").Order("cost DESC").Scan(&out.ByModel)
+	inRange().Select("kind AS `key`, " + sums).Group("kind").Order("cost DESC").Scan(&out.ByKind)
+	inRange().Where("ai_config_id IS NOT NULL").
+		Select("CAST(ai_config_id AS CHAR) AS `key`, " + sums).Group("ai_config_id").Order("cost DESC").Scan(&out.ByConfig)
+	inRange().Where("conversation_id IS NOT NULL").
+		Select("CAST(conversation_id AS CHAR) AS `key`, " + sums).Group("conversation_id").
+		Order("cost DESC").Limit(aiCostTopConversations).Scan(&out.TopConversations)
+
+	var budgeted []models.AIConfig
+	s.db.Where("monthly_budget > ?", 0).Order("id ASC").Find(&budgeted)
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	for _, cfg := range budgeted {
+		var spent float64
+		s.db.Model(&models.AIUsageRecord{}).
+			Select("COALESCE(SUM(cost), 0)").
+			Where("ai_config_id = ? AND created_at >= ?", cfg.ID, monthStart).
+			Scan(&spent)
+		out.Budgets = append(out.Budgets, AIBudgetStatus{
+			AIConfigID:    cfg.ID,
+			Model:         cfg.Model,
+			MonthlyBudget: cfg.MonthlyBudget,
+			MonthSpent:    spent,
+			IsActive:      cfg.IsActive,
+		})
+	}
+	return out, nil
+}
+
+// CopilotStatsReport 客服助手草稿的采纳情况
+
+type CopilotStatsReport struct {
+	From           string              `json:"from"`
+	To             string              `json:"to"`
+	Suggestions    int64               `json:"suggestions"`     // 草稿总数
+	Batches        int64               `json:"batches"`         // 起草批次（按访客消息计）
+	AdoptedBatches int64               `json:"adopted_batches"` // 有草稿被原样或修改后发送的批次
+	AdoptionRate   float64             `json:"adoption_rate"`   // 采纳率（%）= 采纳批次 / 起草批次
+	AvgSimilarity  float64             `json:"avg_similarity"`  // 已发送草稿与最终文本的平均相似度（0~1）
+	ByStatus       []AnalyticsCountRow `json:"by_status"`
+	ByAgent        []AnalyticsCountRow `json:"by_agent"` // 各客服处理的草稿数（Key 为客服 ID，仅统计已处理草稿）
+}
+
+// AnalyticsCountRow 按键分组的计数（Key 为状态 / 客服 ID / 日期等）
+
+type AnalyticsCountRow struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// GetCopilotStats 查询 [fromDate, toDate] 闭区间内生成的客服助手草稿的采纳与修改情况
+func (s *AnalyticsService) GetCopilotStats(fromDate, toDate string) (*CopilotStatsReport, error) {
+	start, endExclusive, err := parseInclusiveDateRange(fromDate, toDate, s.analyticsLoc)
+	if err != nil {
+		return nil, err
+	}
+	if !endExclusive.After(start) {
+		return nil, fmt.Errorf("结束日期须不早于开始日期")
+	}
+	out := &CopilotStatsReport{From: fromDate, To: toDate}
+	inRange := func() *gorm.DB {
+		return s.db.Model(&models.CopilotSuggestion{}).
+			Where("created_at >= ? AND created_at < ?", start, endExclusive)
+	}
+	adopted := []string{models.CopilotStatusAccepted, models.CopilotStatusEdited}
+
+	inRange().Count(&out.Suggestions)
+	inRange().Select("COUNT(DISTINCT message_id)").Scan(&out.Batches)
+	inRange().Where("status IN ?", adopted).Select("COUNT(DISTINCT message_id)").Scan(&out.AdoptedBatches)
+	inRange().Where("status IN ?", adopted).Select("COALESCE(AVG(similarity), 0)").Scan(&out.AvgSimilarity)
+	if out.Batches > 0 {
+		out.AdoptionRate = round2(float64(out.AdoptedBatches) * 100 / float64(out.Batches))
+	}
+	out.AvgSimilarity = round2(out.AvgSimilarity)
+
+	inRange().Select("status AS `key`, COUNT(*) AS count").Group("status").Order("count DESC").Scan(&out.ByStatus)
+	inRange().Where("agent_id IS NOT NULL").
+		Select("CAST(agent_id AS CHAR) AS `key`, COUNT(*) AS count").Group("agent_id").Order("count DESC").Scan(&out.ByAgent)
+	return out, nil
+}
+
+// ConversationCategoryReport 会话小结的分类与解决状态统计
+
+type ConversationCategoryReport struct {
+	From         string                    `json:"from"`
+	To           string                    `json:"to"`
+	Summarized   int64                     `json:"summarized"`    // 已生成小结的会话数
+	ResolvedRate float64                   `json:"resolved_rate"` // 已解决占比（%）
+	ByCategory   []ConversationCategoryRow `json:"by_category"`
+	ByResolution []AnalyticsCountRow       `json:"by_resolution"`
+	Daily        []AnalyticsCountRow       `json:"daily"` // 每日已生成小结的会话数（Key 为日期）
+}
+
+// ConversationCategoryRow 单个分类的会话数与解决情况（Key 为空表示未归类）
+type ConversationCategoryRow struct {
+	Key        string `json:"key"`
+	Count      int64  `json:"count"`
+	Resolved   int64  `json:"resolved"`
+	Unresolved int64  `json:"unresolved"`
+	FollowUp   int64  `json:"follow_up"`
+}
+
+// GetConversationCategories 统计 [fromDate, toDate] 闭区间内创建、已生成小结的访客会话的分类与解决状态；
+// categories / resolutions 非空时仅统计命中的会话
+func (s *AnalyticsService) GetConversationCategories(fromDate, toDate string, categories, resolutions []string) (*ConversationCategoryReport, error) {
+	start, endExclusive, err := parseInclusiveDateRange(fromDate, toDate, s.analyticsLoc)
+	if err != nil {
+		return nil, err
+	}
+	if !endExclusive.After(start) {
+		return nil, fmt.Errorf("结束日期须不早于开始日期")
+	}
+	out := &ConversationCategoryReport{From: fromDate, To: toDate}
+	scoped := func(from, to time.Time) *gorm.DB {
+		q := s.db.Model(&models.Conversation{}).
+			Where("conversation_type = ? AND summary_status = ?", "visitor", SummaryStatusDone).
+			Where("created_at >= ? AND created_at < ?", from, to)
+		if len(categories) > 0 {
+			q = q.Where("category IN ?", categories)
+		}
+		if len(resolutions) > 0 {
+			q = q.Where("resolution IN ?", resolutions)
+		}
+		return q
+	}
+
+	scoped(start, endExclusive).Count(&out.Summarized)
+	scoped(start, endExclusive).
+		Select("category AS `key`, COUNT(*) AS count, "+
+			"SUM(CASE WHEN resolution = ? THEN 1 ELSE 0 END) AS resolved, "+
+			"SUM(CASE WHEN resolution = ? THEN 1 ELSE 0 END) AS unresolved, "+
+			"SUM(CASE WHEN resolution = ? THEN 1 ELSE 0 END) AS follow_up",
+			ResolutionResolved, ResolutionUnresolved, ResolutionFollowUp).
+		Group("category").Order("count DESC").Scan(&out.ByCategory)
+	scoped(start, endExclusive).Select("resolution AS `key`, COUNT(*) AS count").
+		Group("resolution").Order("count DESC").Scan(&out.ByResolution)
+	for _, row := range out.ByResolution {
+		if row.Key == ResolutionResolved && out.Summarized > 0 {
+			out.ResolvedRate = round2(float64(row.Count) * 100 / float64(out.Summarized))
+		}
+	}
+	for d := start; d.Before(endExclusive); d = d.AddDate(0, 0, 1) {
+		row := AnalyticsCountRow{Key: d.Format("2006-01-02")}
+		scoped(d, d.AddDate(0, 0, 1)).Count(&row.Count)
+		out.Daily = append(out.Daily, row)
+	}
+	return out, nil
+}
@@ -0,0 +1,470 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"github.com/2930134478/AI-CS/backend/utils"
+	"gorm.io/gorm"
+)
+
+// 会话自动分配策略
+const (
+	AssignmentStrategyManual     = "manual"      // 不自动分配（首个回复的客服认领，兼容旧行为）
+	AssignmentStrategyRoundRobin = "round_robin" // 在线可接待客服轮询
+	AssignmentStrategyLeastBusy  = "least_busy"  // 当前接待会话最少者优先
+	AssignmentStrategySkill      = "skill"       // 技能匹配优先，其后按最少接待
+)
+
+// ErrNoAvailableAgent 没有满足条件（在线、有对话权限、未达上限）的客服
+var ErrNoAvailableAgent = errors.New("暂无可分配的客服")
+
+// OnlineAgentProvider 提供当前在线客服集合（由 WebSocket Hub 实现）
+type OnlineAgentProvider interface {
+	GetOnlineAgentIDs() map[uint]bool
+}
+
+// AssignmentStrategyPolicy 分配策略（数据库覆盖优先于 .env）
+type AssignmentStrategyPolicy struct {
+	EffectiveStrategy   string   `json:"effective_strategy"`
+	EnvStrategy         string   `json:"env_strategy"`
+	PersistedInDatabase bool     `json:"persisted_in_database"`
+	Strategies          []string `json:"strategies"`
+}
+
+// AssignmentResult 分配 / 转接结果
+type AssignmentResult struct {
+	ConversationID  uint   `json:"conversation_id"`
+	AgentID         uint   `json:"agent_id"`
+	PreviousAgentID uint   `json:"previous_agent_id"`
+	Strategy        string `json:"strategy"` // 自动分配使用的策略；手动为 manual
+	Reason          string `json:"reason"`   // created / handoff / assign / transfer
+	Note            string `json:"note,omitempty"`
+}
+
+// AssignmentService 负责将访客会话分配给客服（自动策略 + 手动分配/转接）。
+type AssignmentService struct {
+	conversations *repository.ConversationRepository
+	users         *repository.UserRepository
+	appSettings   *repository.AppSettingRepository
+	hub           BroadcastHub
+	online        OnlineAgentProvider
+	systemLogSvc  *SystemLogService
+	participants  *repository.ConversationParticipantRepository // 可选，记录负责人变更历史
+
+	mu           sync.Mutex
+	lastAssigned uint // 轮询游标：上一次自动分配到的客服 ID
+}
+
+// NewAssignmentService 创建会话分配服务实例。online 可为 nil（此时不做在线过滤）。
+func NewAssignmentService(
+	conversations *repository.ConversationRepository,
+	users *repository.UserRepository,
+	appSettings *repository.AppSettingRepository,
+	hub BroadcastHub,
+	online OnlineAgentProvider,
+	systemLogSvc *SystemLogService,
+) *AssignmentService {
+	return &AssignmentService{
+		conversations: conversations,
+		users:         users,
+		appSettings:   appSettings,
+		hub:           hub,
+		online:        online,
+		systemLogSvc:  systemLogSvc,
+	}
+}
+
+// SetParticipantRepository 注入会话参与记录仓库（每次分配 / 转接写入审计历史）
+func (s *AssignmentService) SetParticipantRepository(repo *repository.ConversationParticipantRepository) {
+	s.participants = repo
+}
+
+// normalizeAssignmentStrategy 规范化策略值，非法值返回空串
+func normalizeAssignmentStrategy(v string) string {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case AssignmentStrategyManual:
+		return AssignmentStrategyManual
+	case AssignmentStrategyRoundRobin:
+		return AssignmentStrategyRoundRobin
+	case AssignmentStrategyLeastBusy:
+		return AssignmentStrategyLeastBusy
+	case AssignmentStrategySkill:
+		return AssignmentStrategySkill
+	}
+	return ""
+}
+
+func assignmentStrategyFromEnv() string {
+	if v := normalizeAssignmentStrategy(os.Getenv("ASSIGNMENT_STRATEGY")); v != "" {
+		return v
+	}
+	return AssignmentStrategyManual
+}
+
+// GetStrategyPolicy 读取当前分配策略
+func (s *AssignmentService) GetStrategyPolicy() AssignmentStrategyPolicy {
+	envStrategy := assignmentStrategyFromEnv()
+	policy := AssignmentStrategyPolicy{
+		EffectiveStrategy: envStrategy,
+		EnvStrategy:       envStrategy,
+		Strategies: []string{
+			AssignmentStrategyManual,
+			AssignmentStrategyRoundRobin,
+			AssignmentStrategyLeastBusy,
+			AssignmentStrategySkill,
+		},
+	}
+	if s.appSettings != nil {
+		if row, err := s.appSettings.Get(models.AppSettingKeyAssignmentStrategy); err == nil && row != nil {
+			if v := normalizeAssignmentStrategy(row.Value); v != "" {
+				policy.EffectiveStrategy = v
+				policy.PersistedInDatabase = true
+			}
+		}
+	}
+	return policy
+}
+
+// SetStrategy 写入分配策略（覆盖 .env）
+func (s *AssignmentService) SetStrategy(strategy string) error {
+	v := normalizeAssignmentStrategy(strategy)
+	if v == "" {
+		return errors.New("strategy 只能是 manual / round_robin / least_busy / skill")
+	}
+	if s.appSettings == nil {
+		return errors.New("配置存储不可用")
+	}
+	return s.appSettings.SetValue(models.AppSettingKeyAssignmentStrategy, v)
+}
+
+// ClearStrategy 删除数据库覆盖，恢复为 ASSIGNMENT_STRATEGY
+func (s *AssignmentService) ClearStrategy() error {
+	if s.appSettings == nil {
+		return errors.New("配置存储不可用")
+	}
+	return s.appSettings.Delete(models.AppSettingKeyAssignmentStrategy)
+}
+
+// AutoAssign 按当前策略为未分配客服的访客会话选择客服。
+// fromAI 为 true 表示会话来自 AI 转人工，仅考虑开启了「接收 AI 对话」的客服。
+// 策略为 manual、会话已分配或无可用客服时返回 nil, nil。
+func (s *AssignmentService) AutoAssign(conversationID uint, fromAI bool, reason string) (*AssignmentResult, error) {
+	strategy := s.GetStrategyPolicy().EffectiveStrategy
+	if strategy == AssignmentStrategyManual {
+		return nil, nil
+	}
+	conv, err := s.conversations.GetByID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.ConversationType != "visitor" || conv.AgentID != 0 || conv.Status == "closed" {
+		return nil, nil
+	}
+
+	candidates, load, err := s.eligibleAgents(fromAI)
+	if err != nil {
+		return nil, err
+	}
+	agentID := s.pickAgent(strategy, candidates, load, utils.SplitTags(conv.RoutingSkills))
+	if agentID == 0 {
+		return nil, nil
+	}
+	ok, err := s.conversations.AssignAgentIfUnassigned(conversationID, agentID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	result := &AssignmentResult{
+		ConversationID: conversationID,
+		AgentID:        agentID,
+		Strategy:       strategy,
+		Reason:         reason,
+	}
+	s.recordOwner(result, 0)
+	s.notify(conv, result)
+	return result, nil
+}
+
+// Assign 手动将会话分配 / 转接给指定客服（不受在线与上限限制）。agentID 为 0 时按当前策略自动选择。
+// note 为转接备注，随事件推送给接手客服并写入参与记录。
+func (s *AssignmentService) Assign(conversationID uint, agentID uint, operatorID uint, reason string, note string) (*AssignmentResult, error) {
+	conv, err := s.conversations.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if conv.ConversationType != "visitor" {
+		return nil, errors.New("仅访客会话支持分配")
+	}
+	if conv.Status == "closed" {
+		return nil, ErrConversationClosed
+	}
+
+	strategy := AssignmentStrategyManual
+	if agentID == 0 {
+		strategy = s.GetStrategyPolicy().EffectiveStrategy
+		if strategy == AssignmentStrategyManual {
+			strategy = AssignmentStrategyLeastBusy
+		}
+		candidates, load, err := s.eligibleAgents(conv.HandoffAt != nil)
+		if err != nil {
+			return nil, err
+		}
+		// 转接时排除当前客服
+		filtered := candidates[:0]
+		for _, u := range candidates {
+			if u.ID != conv.AgentID {
+				filtered = append(filtered, u)
+			}
+		}
+		agentID = s.pickAgent(strategy, filtered, load, utils.SplitTags(conv.RoutingSkills))
+		if agentID == 0 {
+			return nil, ErrNoAvailableAgent
+		}
+	} else {
+		agent, err := s.users.GetByID(agentID)
+		if err != nil || agent == nil {
+			return nil, errors.New("客服不存在")
+		}
+		if !userHasChatPermission(agent) {
+			return nil, errors.New("该用户没有对话权限")
+		}
+	}
+	if agentID == conv.AgentID {
+		return &AssignmentResult{ConversationID: conversationID, AgentID: agentID, PreviousAgentID: agentID, Strategy: strategy, Reason: reason}, nil
+	}
+	note = truncateRunes(strings.TrimSpace(note), 500)
+
+	if err := s.conversations.UpdateFields(conversationID, map[string]interface{}{
+		"agent_id": agentID,
+	}); err != nil {
+		return nil, err
+	}
+	result := &AssignmentResult{
+		ConversationID:  conversationID,
+		AgentID:         agentID,
+		PreviousAgentID: conv.AgentID,
+		Strategy:        strategy,
+		Reason:          reason,
+		Note:            note,
+	}
+	s.recordOwner(result, operatorID)
+	s.notify(conv, result)
+	if s.systemLogSvc != nil {
+		convID := conversationID
+		uID := operatorID
+		_ = s.systemLogSvc.Create(CreateSystemLogInput{
+			Level:          "info",
+			Category:       "business",
+			Event:          "conversation_" + reason,
+			Source:         "backend",
+			ConversationID: &convID,
+			UserID:         &uID,
+			Message:        "会话分配客服",
+			Meta: map[string]interface{}{
+				"agent_id":          agentID,
+				"previous_agent_id": conv.AgentID,
+				"strategy":          strategy,
+				"note":              note,
+			},
+		})
+	}
+	return result, nil
+}
+
+// eligibleAgents 返回可接待的客服（在线、具备对话权限、未达并发上限；fromAI 时需接收 AI 对话）及其当前接待数
+func (s *AssignmentService) eligibleAgents(fromAI bool) ([]models.User, map[uint]int64, error) {
+	users, err := s.users.ListUsers()
+	if err != nil {
+		return nil, nil, err
+	}
+	var online map[uint]bool
+	if s.online != nil {
+		online = s.online.GetOnlineAgentIDs()
+	}
+	candidates := make([]models.User, 0, len(users))
+	ids := make([]uint, 0, len(users))
+	for _, u := range users {
+		if online != nil && !online[u.ID] {
+			continue
+		}
+		if !userHasChatPermission(&u) {
+			continue
+		}
+		if fromAI && !u.ReceiveAIConversations {
+			continue
+		}
+		candidates = append(candidates, u)
+		ids = append(ids, u.ID)
+	}
+	load, err := s.conversations.CountOpenVisitorByAgentIDs(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	available := candidates[:0]
+	for _, u := range candidates {
+		if u.MaxConcurrentConversations > 0 && load[u.ID] >= int64(u.MaxConcurrentConversations) {
+			continue
+		}
+		available = append(available, u)
+	}
+	sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
+	return available, load, nil
+}
+
+// pickAgent 按策略从候选中选出客服，无候选时返回 0
+func (s *AssignmentService) pickAgent(strategy string, candidates []models.User, load map[uint]int64, skills []string) uint {
+	if len(candidates) == 0 {
+		return 0
+	}
+	switch strategy {
+	case AssignmentStrategyRoundRobin:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		chosen := candidates[0].ID
+		for _, u := range candidates {
+			if u.ID > s.lastAssigned {
+				chosen = u.ID
+				break
+			}
+		}
+		s.lastAssigned = chosen
+		return chosen
+	case AssignmentStrategySkill:
+		if len(skills) > 0 {
+			best := 0
+			matched := make([]models.User, 0, len(candidates))
+			for _, u := range candidates {
+				n := countSkillMatches(utils.SplitTags(u.Skills), skills)
+				if n == 0 || n < best {
+					continue
+				}
+				if n > best {
+					best = n
+					matched = matched[:0]
+				}
+				matched = append(matched, u)
+			}
+			if len(matched) > 0 {
+				return leastBusyAgent(matched, load)
+			}
+		}
+		return leastBusyAgent(candidates, load)
+	default:
+		return leastBusyAgent(candidates, load)
+	}
+}
+
+// recordOwner 写入负责人变更历史：关闭旧负责人记录并新增一条；接手者若原为协作者，其协作记录一并结束
+func (s *AssignmentService) recordOwner(result *AssignmentResult, operatorID uint) {
+	if s.participants == nil {
+		return
+	}
+	now := time.Now()
+	if err := s.participants.MarkLeft(result.ConversationID, 0, ParticipantRoleOwner, "transferred", now); err != nil {
+		log.Printf("⚠️ 更新会话参与记录失败: conversation_id=%d err=%v", result.ConversationID, err)
+	}
+	if err := s.participants.MarkLeft(result.ConversationID, result.AgentID, ParticipantRoleCollaborator, "promoted", now); err != nil {
+		log.Printf("⚠️ 更新会话参与记录失败: conversation_id=%d err=%v", result.ConversationID, err)
+	}
+	action := "assign"
+	if result.PreviousAgentID != 0 {
+		action = "transfer"
+	}
+	if err := s.participants.Create(&models.ConversationParticipant{
+		ConversationID: result.ConversationID,
+		UserID:         result.AgentID,
+		Role:           ParticipantRoleOwner,
+		Action:         action,
+		FromAgentID:    result.PreviousAgentID,
+		OperatorID:     operatorID,
+		Note:           result.Note,
+		JoinedAt:       now,
+	}); err != nil {
+		log.Printf("⚠️ 写入会话参与记录失败: conversation_id=%d err=%v", result.ConversationID, err)
+	}
+}
+
+// notify 广播分配事件：会话房间（访客可展示接待客服）与全部客服（客服台更新归属）
+func (s *AssignmentService) notify(conv *models.Conversation, result *AssignmentResult) {
+	if s.hub == nil {
+		return
+	}
+	payload := map[string]interface{}{
+		"conversation_id":   result.ConversationID,
+		"agent_id":          result.AgentID,
+		"previous_agent_id": result.PreviousAgentID,
+		"strategy":          result.Strategy,
+		"reason":            result.Reason,
+		"note":              result.Note,
+		"assigned_at":       time.Now(),
+	}
+	event := "conversation_assigned"
+	if result.PreviousAgentID != 0 {
+		event = "conversation_transferred"
+	}
+	s.hub.BroadcastMessage(conv.ID, event, payload)
+	s.hub.BroadcastToAllAgents(event, payload)
+	if result.Reason != "" && result.Strategy != AssignmentStrategyManual {
+		log.Printf("✅ 会话 %d 已自动分配给客服 %d（策略=%s，原因=%s）", result.ConversationID, result.AgentID, result.Strategy, result.Reason)
+	}
+}
+
+// leastBusyAgent 返回当前接待数最少的客服（并列时取 ID 较小者）
+func leastBusyAgent(candidates []models.User, load map[uint]int64) uint {
+	chosen := candidates[0].ID
+	min := load[chosen]
+	for _, u := range candidates[1:] {
+		if load[u.ID] < min {
+			chosen = u.ID
+			min = load[u.ID]
+		}
+	}
+	return chosen
+}
+
+// countSkillMatches 统计客服技能与会话所需技能的交集数量（不区分大小写）
+func countSkillMatches(agentSkills []string, required []string) int {
+	n := 0
+	for _, r := range required {
+		for _, a := range agentSkills {
+			if strings.EqualFold(a, r) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// userHasChatPermission 用户是否具备对话权限（admin 视为全权限；agent 未配置时默认仅 chat）
+func userHasChatPermission(user *models.User) bool {
+	if user == nil {
+		return false
+	}
+	if user.Role == "admin" {
+		return true
+	}
+	if user.Role != "agent" {
+		return false
+	}
+	keys := DecodePermissions(user.Permissions)
+	if len(keys) == 0 {
+		keys = DefaultAgentPermissions()
+	}
+	for _, k := range keys {
+		if k == string(PermChat) {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/2930134478/AI-CS/backend/models"
 	"github.com/2930134478/AI-CS/backend/repository"
 	"github.com/2930134478/AI-CS/backend/service/rag"
+	"github.com/2930134478/AI-CS/backend/utils"
 )
 
 // ChunkService 文档分段服务
@@ -41,9 +42,22 @@ func NewChunkService(
 
 // ChunkRequest 分段请求参数
 type ChunkRequest struct {
-	Method    string `json:"method"`               // "char_count" | "separator"
-	ChunkSize int    `json:"chunk_size,omitempty"` // 按字数时的每段字数
+	Method    string `json:"method"`               // "char_count" | "separator" | "recursive" | "markdown"
+	ChunkSize int    `json:"chunk_size,omitempty"` // 每段长度上限（char_count / recursive / markdown）
 	Separator string `json:"separator,omitempty"`  // 按分隔符时的分隔符
+	Overlap   int    `json:"overlap,omitempty"`    // 相邻分段的重叠长度（recursive / markdown），须小于每段长度的一半
+	SizeUnit  string `json:"size_unit,omitempty"`  // 长度单位：char（默认）| token
+}
+
+// ChunkPreview 分段预览（不写入数据库与向量库）
+type ChunkPreview struct {
+	Index        int    `json:"index"`
+	Content      string `json:"content"`
+	Chars        int    `json:"chars"`
+	Tokens       int    `json:"tokens"`
+	PageNumber   int    `json:"page_number"`
+	HeadingPath  string `json:"heading_path"`
+	SourceAnchor string `json:"source_anchor"`
 }
 
 // ChunkByCharCount 按字数分段（不重叠）
@@ -99,39 +113,27 @@ func (s *ChunkService) ExecuteChunking(ctx context.Context, documentID uint, req
 		return nil, errors.New("文档内容为空，无法分段")
 	}
 
-	var chunkTexts []string
-	switch req.Method {
-	case "char_count":
-		size := req.ChunkSize
-		if size <= 0 {
-			size = 500
-		}
-		chunkTexts = ChunkByCharCount(doc.Content, size)
-	case "separator":
-		if req.Separator == "" {
-			return nil, errors.New("分隔符不能为空")
-		}
-		chunkTexts = ChunkBySeparator(doc.Content, req.Separator)
-	default:
-		return nil, fmt.Errorf("不支持的分段方式: %s（支持 char_count 或 separator）", req.Method)
-	}
-
-	if len(chunkTexts) == 0 {
-		return nil, errors.New("分段结果为空")
+	split, err := splitChunks(doc.Content, req)
+	if err != nil {
+		return nil, err
 	}
+	sources := chunkSources(doc, split)
 
 	if err := s.deleteExistingChunks(ctx, documentID); err != nil {
 		return nil, fmt.Errorf("删除旧分段失败: %w", err)
 	}
 
-	chunks := make([]*models.DocumentChunk, len(chunkTexts))
-	for i, text := range chunkTexts {
+	chunks := make([]*models.DocumentChunk, len(split))
+	for i, c := range split {
 		chunks[i] = &models.DocumentChunk{
 			DocumentID:      documentID,
 			KnowledgeBaseID: doc.KnowledgeBaseID,
 			ChunkIndex:      i,
-			Content:         text,
+			Content:         c.Text,
 			EmbeddingStatus: "pending",
+			PageNumber:      sources[i].PageNumber,
+			HeadingPath:     sources[i].HeadingPath,
+			SourceAnchor:    sources[i].SourceAnchor,
 		}
 	}
 	if err := s.chunkRepo.BatchCreate(chunks); err != nil {
@@ -147,6 +149,87 @@ func (s *ChunkService) ExecuteChunking(ctx context.Context, documentID uint, req
 	return result, nil
 }
 
+// PreviewChunking 按分段参数试切文档，仅返回拟生成的分段，不删除旧分段、不写入 MySQL 与 Milvus
+func (s *ChunkService) PreviewChunking(documentID uint, req ChunkRequest) ([]ChunkPreview, error) {
+	doc, err := s.docRepo.GetByID(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("文档不存在: %w", err)
+	}
+	if strings.TrimSpace(doc.Content) == "" {
+		return nil, errors.New("文档内容为空，无法分段")
+	}
+
+	split, err := splitChunks(doc.Content, req)
+	if err != nil {
+		return nil, err
+	}
+	sources := chunkSources(doc, split)
+	previews := make([]ChunkPreview, len(split))
+	for i, c := range split {
+		previews[i] = ChunkPreview{
+			Index:        i,
+			Content:      c.Text,
+			Chars:        utf8.RuneCountInString(c.Text),
+			Tokens:       utils.CountTextTokens(c.Text),
+			PageNumber:   sources[i].PageNumber,
+			HeadingPath:  sources[i].HeadingPath,
+			SourceAnchor: sources[i].SourceAnchor,
+		}
+	}
+	return previews, nil
+}
+
+// SplitChunkTexts 校验分段参数并切分文本
+func SplitChunkTexts(content string, req ChunkRequest) ([]string, error) {
+	chunks, err := splitChunks(content, req)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	return texts, nil
+}
+
+func splitChunks(content string, req ChunkRequest) ([]splitChunk, error) {
+	size := req.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	if size > maxChunkSize {
+		return nil, fmt.Errorf("每段长度不能超过 %d", maxChunkSize)
+	}
+	if req.Overlap < 0 || (req.Overlap > 0 && req.Overlap*2 >= size) {
+		return nil, errors.New("重叠长度须小于每段长度的一半")
+	}
+	if req.SizeUnit != "" && req.SizeUnit != ChunkSizeUnitChar && req.SizeUnit != ChunkSizeUnitToken {
+		return nil, errors.New("长度单位必须为 char 或 token")
+	}
+
+	var chunks []splitChunk
+	switch req.Method {
+	case ChunkMethodCharCount:
+		chunks = plainChunks(ChunkByCharCount(content, size))
+	case ChunkMethodSeparator:
+		if req.Separator == "" {
+			return nil, errors.New("分隔符不能为空")
+		}
+		chunks = plainChunks(ChunkBySeparator(content, req.Separator))
+	case ChunkMethodRecursive:
+		chunks = plainChunks(ChunkRecursive(content, size, req.Overlap, req.SizeUnit))
+	case ChunkMethodMarkdown:
+		chunks = markdownChunks(content, size, req.Overlap, req.SizeUnit)
+	default:
+		return nil, fmt.Errorf("不支持的分段方式: %s（支持 char_count、separator、recursive 或 markdown）", req.Method)
+	}
+
+	if len(chunks) == 0 {
+		return nil, errors.New("分段结果为空")
+	}
+	return chunks, nil
+}
+
 // GetChunks 获取文档的分段列表
 func (s *ChunkService) GetChunks(documentID uint, page, pageSize int) ([]models.DocumentChunk, int64, error) {
 	if _, err := s.docRepo.GetByID(documentID); err != nil {
@@ -207,7 +290,7 @@ func (s *ChunkService) reEmbedSingleChunk(chunk *models.DocumentChunk) {
 
 	docIDStr := rag.ConvertDocumentID(chunk.DocumentID)
 	kbIDStr := rag.ConvertKnowledgeBaseID(chunk.KnowledgeBaseID)
-	if err := s.vectorStore.UpsertVector(ctx, docIDStr, kbIDStr, chunk.Content, chunkIDStr, vectors[0]); err != nil {
+	if err := s.vectorStore.UpsertVector(ctx, docIDStr, kbIDStr, chunk.Content, chunkIDStr, vectors[0], chunkSourceMetadata(chunk)); err != nil {
 		log.Printf("[分段] 单段向量写入失败 (chunk=%d): %v", chunk.ID, err)
 		_ = s.chunkRepo.UpdateEmbeddingStatus(chunk.ID, "failed")
 		return
@@ -255,13 +338,15 @@ func (s *ChunkService) embedChunkList(ctx context.Context, documentID uint, know
 	docIDs := make([]uint, len(chunks))
 	kbIDs := make([]uint, len(chunks))
 	chunkIDs := make([]string, len(chunks))
+	metas := make([]rag.SourceMetadata, len(chunks))
 	for i, c := range chunks {
 		docIDs[i] = documentID
 		kbIDs[i] = knowledgeBaseID
 		chunkIDs[i] = rag.ConvertDocumentID(c.ID)
+		metas[i] = chunkSourceMetadata(c)
 	}
 
-	if err := s.embeddingSvc.EmbedDocuments(ctx, docIDs, kbIDs, contents, chunkIDs); err != nil {
+	if err := s.embeddingSvc.EmbedChunks(ctx, docIDs, kbIDs, contents, chunkIDs, metas); err != nil {
 		log.Printf("[分段] 批量向量化失败 (doc=%d): %v", documentID, err)
 		for _, c := range chunks {
 			_ = s.chunkRepo.UpdateEmbeddingStatus(c.ID, "failed")
@@ -274,6 +359,3 @@ func (s *ChunkService) embedChunkList(ctx context.Context, documentID uint, know
 	}
 	log.Printf("[分段] 批量向量化完成 (doc=%d, chunks=%d)", documentID, len(chunks))
 }
-
-// ensure utf8 package is used
-var _ = utf8.RuneCountInString
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/service/rag"
+)
+
+// 定位分段时用于匹配原文的前缀长度（字符）
+const chunkProbeRunes = 32
+
+// chunkSources 为各分段标注来源位置：按顺序在文档内容中定位每段的起点，再从导入时记录的
+// SourceMap 中取所在页码、标题路径与锚点；SourceMap 中没有标题时使用 markdown 分段的标题路径。
+func chunkSources(doc *models.Document, chunks []splitChunk) []rag.SourceMetadata {
+	out := make([]rag.SourceMetadata, len(chunks))
+	defaultAnchor := ""
+	if doc.Type == "url" {
+		defaultAnchor = doc.SourceName
+	}
+
+	from := 0
+	for i, c := range chunks {
+		meta := rag.SourceMetadata{HeadingPath: c.HeadingPath, SourceAnchor: defaultAnchor}
+		if pos := locateChunk(doc.Content, c.Body, from); pos >= 0 {
+			from = pos + 1
+			if sec := sourceSectionAt(doc.SourceMap, pos); sec != nil {
+				meta.PageNumber = sec.PageNumber
+				if len(sec.HeadingPath) > 0 {
+					meta.HeadingPath = strings.Join(sec.HeadingPath, " > ")
+				}
+				if sec.Anchor != "" {
+					meta.SourceAnchor = sec.Anchor
+				}
+			}
+		}
+		out[i] = meta
+	}
+	return out
+}
+
+// chunkSourceMetadata 取分段已保存的来源信息（写入向量库）
+func chunkSourceMetadata(chunk *models.DocumentChunk) rag.SourceMetadata {
+	return rag.SourceMetadata{
+		PageNumber:   chunk.PageNumber,
+		HeadingPath:  chunk.HeadingPath,
+		SourceAnchor: chunk.SourceAnchor,
+	}
+}
+
+// locateChunk 从 from 起查找分段在原文中的字节偏移（以分段开头若干字符匹配），找不到返回 -1
+func locateChunk(content, text string, from int) int {
+	probe := strings.TrimSpace(text)
+	if probe == "" || from >= len(content) {
+		return -1
+	}
+	if runes := []rune(probe); len(runes) > chunkProbeRunes {
+		probe = string(runes[:chunkProbeRunes])
+	}
+	idx := strings.Index(content[from:], probe)
+	if idx < 0 {
+		return -1
+	}
+	return from + idx
+}
+
+// sourceSectionAt 返回包含字节偏移 pos 的来源区间
+func sourceSectionAt(sections []models.DocumentSourceSection, pos int) *models.DocumentSourceSection {
+	for i := range sections {
+		if sections[i].Start <= pos && pos < sections[i].End {
+			return &sections[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+// 分段方式
+const (
+	ChunkMethodCharCount = "char_count" // 按字数硬切（不重叠）
+	ChunkMethodSeparator = "separator"  // 按分隔符
+	ChunkMethodRecursive = "recursive"  // 递归：段落 → 行 → 句子 → 子句 → 词 → 字符，尽量不在句中切断
+	ChunkMethodMarkdown  = "markdown"   // 按 Markdown 标题分节，每段以所属标题路径开头
+)
+
+// 分段长度单位（recursive / markdown）
+const (
+	ChunkSizeUnitChar  = "char"  // 字符数
+	ChunkSizeUnitToken = "token" // 估算 token 数（与模型上下文预算同一口径）
+)
+
+const (
+	defaultChunkSize = 500
+	maxChunkSize     = 10000
+)
+
+// IsValidChunkMethod 是否为支持的分段方式
+func IsValidChunkMethod(method string) bool {
+	switch method {
+	case ChunkMethodCharCount, ChunkMethodSeparator, ChunkMethodRecursive, ChunkMethodMarkdown:
+		return true
+	}
+	return false
+}
+
+var (
+	paragraphBreakPattern = regexp.MustCompile(`\n[ \t]*\n\s*`)
+	markdownHeadingRegexp = regexp.MustCompile(`^(#{1,6})[ \t]+(.+?)[ \t]*#*[ \t]*$`)
+)
+
+// ChunkRecursive 递归分段：优先按段落切分，超长的段落再依次按行、句子（含中文句末标点）、子句、空白、字符切分，
+// 再将相邻片段合并到不超过 size；overlap > 0 时每段开头重复上一段末尾不超过 overlap 的片段。
+func ChunkRecursive(text string, size, overlap int, unit string) []string {
+	return newChunkSplitter(size, overlap, unit).split(text)
+}
+
+// ChunkMarkdown 按 Markdown 标题分节后在节内递归分段，每段以「一级标题 > 二级标题 > …」开头，
+// 使分段脱离上下文后仍保留所属章节；无标题的文本等同 ChunkRecursive。代码块内的 # 不视为标题。
+func ChunkMarkdown(text string, size, overlap int, unit string) []string {
+	chunks := markdownChunks(text, size, overlap, unit)
+	out := make([]string, len(chunks))
+	for i, c := range chunks {
+		out[i] = c.Text
+	}
+	return out
+}
+
+// splitChunk 一个分段：Text 为分段内容，Body 为其中取自原文的部分（markdown 分段去掉开头的标题路径），
+// HeadingPath 为 markdown 分段所属的标题路径
+type splitChunk struct {
+	Text        string
+	Body        string
+	HeadingPath string
+}
+
+func plainChunks(texts []string) []splitChunk {
+	out := make([]splitChunk, len(texts))
+	for i, t := range texts {
+		out[i] = splitChunk{Text: t, Body: t}
+	}
+	return out
+}
+
+func markdownChunks(text string, size, overlap int, unit string) []splitChunk {
+	sections := splitMarkdownSections(text)
+	splitter := newChunkSplitter(size, overlap, unit)
+	var chunks []splitChunk
+	for _, sec := range sections {
+		if strings.TrimSpace(sec.body) == "" {
+			continue
+		}
+		path := strings.Join(sec.path, " > ")
+		prefix := ""
+		if path != "" {
+			prefix = path + "\n"
+		}
+		// 标题路径计入长度；路径过长时至少保留一半长度给正文
+		bodySize := splitter.size - splitter.measure(prefix)
+		if bodySize < splitter.size/2 {
+			bodySize = splitter.size / 2
+		}
+		bodySplitter := newChunkSplitter(bodySize, splitter.overlap, splitter.unit)
+		for _, c := range bodySplitter.split(sec.body) {
+			chunks = append(chunks, splitChunk{Text: prefix + c, Body: c, HeadingPath: path})
+		}
+	}
+	return chunks
+}
+
+// chunkSplitter 按长度上限切分并合并片段
+type chunkSplitter struct {
+	size    int
+	overlap int
+	unit    string
+}
+
+func newChunkSplitter(size, overlap int, unit string) *chunkSplitter {
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	if overlap < 0 {
+		overlap = 0
+	}
+	// 重叠过大时每段新增内容过少，限制为长度的一半
+	if overlap > size/2 {
+		overlap = size / 2
+	}
+	if unit != ChunkSizeUnitToken {
+		unit = ChunkSizeUnitChar
+	}
+	return &chunkSplitter{size: size, overlap: overlap, unit: unit}
+}
+
+// measure 按长度单位计量文本
+func (c *chunkSplitter) measure(s string) int {
+	if c.unit == ChunkSizeUnitToken {
+		return utils.CountTextTokens(s)
+	}
+	return len([]rune(s))
+}
+
+func (c *chunkSplitter) split(text string) []string {
+	return c.merge(c.pieces(text, 0))
+}
+
+// recursiveSplitLevels 递归切分的各级分隔方式（由粗到细），切分结果拼接后与原文一致
+var recursiveSplitLevels = []func(string) []string{
+	splitParagraphs,
+	splitLines,
+	splitSentences,
+	splitClauses,
+	splitWords,
+}
+
+// pieces 将文本切成均不超过 size 的片段：当前级别无法再切时进入下一级，最后按字符硬切
+func (c *chunkSplitter) pieces(text string, level int) []string {
+	if c.measure(text) <= c.size {
+		return []string{text}
+	}
+	if level >= len(recursiveSplitLevels) {
+		return c.hardSplit(text)
+	}
+	parts := recursiveSplitLevels[level](text)
+	if len(parts) <= 1 {
+		return c.pieces(text, level+1)
+	}
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		out = append(out, c.pieces(p, level+1)...)
+	}
+	return out
+}
+
+// hardSplit 按字符切分（单个句子 / 子句仍超长时）
+func (c *chunkSplitter) hardSplit(text string) []string {
+	var out []string
+	var b strings.Builder
+	cjk, other := 0, 0
+	for _, r := range text {
+		if isCJKRune(r) {
+			cjk++
+		} else {
+			other++
+		}
+		n := cjk + other
+		if c.unit == ChunkSizeUnitToken {
+			n = cjk + (other+3)/4
+		}
+		if n > c.size && b.Len() > 0 {
+			out = append(out, b.String())
+			b.Reset()
+			cjk, other = 0, 0
+			if isCJKRune(r) {
+				cjk++
+			} else {
+				other++
+			}
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() > 0 {
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// merge 将相邻片段合并为不超过 size 的分段；新分段开头带上一段末尾不超过 overlap 的片段
+func (c *chunkSplitter) merge(pieces []string) []string {
+	var (
+		chunks  []string
+		current []string
+		sizes   []int
+		total   int
+	)
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "")); text != "" {
+			chunks = append(chunks, text)
+		}
+	}
+	for _, p := range pieces {
+		n := c.measure(p)
+		if len(current) > 0 && total+n > c.size {
+			flush()
+			// 从末尾保留重叠片段（且保证加上当前片段不超长）
+			keep, kept := 0, 0
+			for i := len(current) - 1; i >= 0; i-- {
+				if kept+sizes[i] > c.overlap || kept+sizes[i]+n > c.size {
+					break
+				}
+				kept += sizes[i]
+				keep++
+			}
+			current = append([]string(nil), current[len(current)-keep:]...)
+			sizes = append([]int(nil), sizes[len(sizes)-keep:]...)
+			total = kept
+			// 重叠部分全为空白时无意义
+			if strings.TrimSpace(strings.Join(current, "")) == "" {
+				current, sizes, total = nil, nil, 0
+			}
+		}
+		current = append(current, p)
+		sizes = append(sizes, n)
+		total += n
+	}
+	flush()
+	return chunks
+}
+
+// splitParagraphs 按空行切分（分隔空行保留在前一段末尾）
+func splitParagraphs(text string) []string {
+	return splitAtIndexes(text, paragraphBreakPattern.FindAllStringIndex(text, -1))
+}
+
+// splitLines 按换行切分
+func splitLines(text string) []string {
+	parts := strings.SplitAfter(text, "\n")
+	if len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return parts
+}
+
+// splitSentences 在句末标点后切分：中文 。！？；… 及英文 !?; 直接切分，英文句点仅在其后为空白时切分（避免 3.14、e.g 之类）；
+// 紧随其后的引号、括号等闭合符号与空白归入前一句。
+func splitSentences(text string) []string {
+	return splitAfterPunctuation(text, func(r rune, next rune, hasNext bool) bool {
+		switch r {
+		case '。', '！', '？', '；', '…', '!', '?', ';':
+			return true
+		case '.':
+			return !hasNext || unicode.IsSpace(next)
+		}
+		return false
+	})
+}
+
+// splitClauses 在逗号、顿号、冒号后切分（英文逗号 / 冒号仅在其后为空白时切分，避免 1,000 与 10:30）
+func splitClauses(text string) []string {
+	return splitAfterPunctuation(text, func(r rune, next rune, hasNext bool) bool {
+		switch r {
+		case '，', '、', '：':
+			return true
+		case ',', ':':
+			return !hasNext || unicode.IsSpace(next)
+		}
+		return false
+	})
+}
+
+// splitWords 在空白后切分（无标点的长英文等，避免硬切断单词）
+func splitWords(text string) []string {
+	return splitAfterPunctuation(text, func(r rune, next rune, hasNext bool) bool {
+		return unicode.IsSpace(r) && hasNext && !unicode.IsSpace(next)
+	})
+}
+
+// splitAfterPunctuation 在满足 isBoundary 的标点（连同其后连续的句末标点、闭合符号与空白）之后切分
+func splitAfterPunctuation(text string, isBoundary func(r rune, next rune, hasNext bool) bool) []string {
+	runes := []rune(text)
+	var parts []string
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		var next rune
+		hasNext := i+1 < len(runes)
+		if hasNext {
+			next = runes[i+1]
+		}
+		if !isBoundary(runes[i], next, hasNext) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && (isClosingRune(runes[end]) || isSentenceEndRune(runes[end]) || unicode.IsSpace(runes[end])) {
+			end++
+		}
+		parts = append(parts, string(runes[start:end]))
+		start = end
+		i = end - 1
+	}
+	if start < len(runes) {
+		parts = append(parts, string(runes[start:]))
+	}
+	return parts
+}
+
+// splitAtIndexes 在各匹配区间末尾切分
+func splitAtIndexes(text string, matches [][]int) []string {
+	if len(matches) == 0 {
+		return []string{text}
+	}
+	parts := make([]string, 0, len(matches)+1)
+	start := 0
+	for _, m := range matches {
+		if m[1] > start {
+			parts = append(parts, text[start:m[1]])
+			start = m[1]
+		}
+	}
+	if start < len(text) {
+		parts = append(parts, text[start:])
+	}
+	return parts
+}
+
+func isClosingRune(r rune) bool {
+	return strings.ContainsRune("”’\"'」』）)】》]", r)
+}
+
+func isSentenceEndRune(r rune) bool {
+	return strings.ContainsRune("。！？!?…", r)
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// markdownSection Markdown 中一个标题下的正文（path 为从一级到当前标题的路径）
+type markdownSection struct {
+	path []string
+	body string
+}
+
+// splitMarkdownSections 按 ATX 标题（# ~ ######）拆分章节；围栏代码块（``` / ~~~）内的行不解析为标题
+func splitMarkdownSections(text string) []markdownSection {
+	type heading struct {
+		level int
+		title string
+	}
+	var (
+		sections []markdownSection
+		stack    []heading
+		body     strings.Builder
+		inFence  bool
+		fence    string
+	)
+	currentPath := func() []string {
+		path := make([]string, len(stack))
+		for i, h := range stack {
+			path[i] = h.title
+		}
+		return path
+	}
+	flush := func() {
+		sections = append(sections, markdownSection{path: currentPath(), body: body.String()})
+		body.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			marker := trimmed[:3]
+			if !inFence {
+				inFence, fence = true, marker
+			} else if marker == fence {
+				inFence = false
+			}
+		}
+		if !inFence {
+			if m := markdownHeadingRegexp.FindStringSubmatch(strings.TrimRight(line, "\r\n")); m != nil {
+				flush()
+				level := len(m[1])
+				for len(stack) > 0 && stack[len(stack)-1].level >= level {
+					stack = stack[:len(stack)-1]
+				}
+				stack = append(stack, heading{level: level, title: strings.TrimSpace(m[2])})
+				continue
+			}
+		}
+		body.WriteString(line)
+	}
+	flush()
+	return sections
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"gorm.io/gorm"
+)
+
+// 会话参与角色
+const (
+	ParticipantRoleOwner        = "owner"        // 负责人（对应 Conversation.AgentID）
+	ParticipantRoleCollaborator = "collaborator" // 被邀请协作的客服 / 主管
+)
+
+// whisperMaxLength whisper 内部备注最大长度（字符）
+const whisperMaxLength = 2000
+
+// CollaborationService 负责多客服协作：邀请主管加入、离开会话、whisper 内部备注及参与记录查询。
+// 转接由 AssignmentService 完成，参与记录在同一张表中审计。
+type CollaborationService struct {
+	conversations *repository.ConversationRepository
+	messages      *repository.MessageRepository
+	participants  *repository.ConversationParticipantRepository
+	users         *repository.UserRepository
+	hub           BroadcastHub
+	systemLogSvc  *SystemLogService
+}
+
+// NewCollaborationService 创建多客服协作服务实例。
+func NewCollaborationService(
+	conversations *repository.ConversationRepository,
+	messages *repository.MessageRepository,
+	participants *repository.ConversationParticipantRepository,
+	users *repository.UserRepository,
+	hub BroadcastHub,
+	systemLogSvc *SystemLogService,
+) *CollaborationService {
+	return &CollaborationService{
+		conversations: conversations,
+		messages:      messages,
+		participants:  participants,
+		users:         users,
+		hub:           hub,
+		systemLogSvc:  systemLogSvc,
+	}
+}
+
+// getVisitorConversation 读取访客会话（内部会话不支持协作）
+func (s *CollaborationService) getVisitorConversation(conversationID uint) (*models.Conversation, error) {
+	conv, err := s.conversations.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if conv.ConversationType != "visitor" {
+		return nil, errors.New("仅访客会话支持多人协作")
+	}
+	return conv, nil
+}
+
+// Invite 邀请客服（如主管）加入会话协作。被邀请者已是负责人或协作者时直接返回现有状态。
+func (s *CollaborationService) Invite(conversationID uint, userID uint, operatorID uint, note string) (*models.ConversationParticipant, error) {
+	conv, err := s.getVisitorConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.Status == "closed" {
+		return nil, ErrConversationClosed
+	}
+	user, err := s.users.GetByID(userID)
+	if err != nil || user == nil {
+		return nil, errors.New("客服不存在")
+	}
+	if !userHasChatPermission(user) {
+		return nil, errors.New("该用户没有对话权限")
+	}
+	if conv.AgentID == userID {
+		return nil, errors.New("该客服已是会话负责人")
+	}
+	active, err := s.participants.ListActive(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range active {
+		if active[i].UserID == userID && active[i].Role == ParticipantRoleCollaborator {
+			return &active[i], nil
+		}
+	}
+
+	participant := &models.ConversationParticipant{
+		ConversationID: conversationID,
+		UserID:         userID,
+		Role:           ParticipantRoleCollaborator,
+		Action:         "invite",
+		FromAgentID:    conv.AgentID,
+		OperatorID:     operatorID,
+		Note:           truncateRunes(strings.TrimSpace(note), 500),
+		JoinedAt:       time.Now(),
+	}
+	if err := s.participants.Create(participant); err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"conversation_id": conversationID,
+		"user_id":         userID,
+		"role":            participant.Role,
+		"operator_id":     operatorID,
+		"note":            participant.Note,
+		"joined_at":       participant.JoinedAt,
+	}
+	if s.hub != nil {
+		// 被邀请者可能尚未进入该会话房间，向全部客服广播，前端按 user_id 提示
+		s.hub.BroadcastToAllAgents("conversation_participant_joined", payload)
+	}
+	s.writeLog("conversation_invite", conversationID, operatorID, "邀请客服加入会话", payload)
+	return participant, nil
+}
+
+// Leave 协作者离开会话（负责人需通过转接交出会话）
+func (s *CollaborationService) Leave(conversationID uint, userID uint) error {
+	if _, err := s.getVisitorConversation(conversationID); err != nil {
+		return err
+	}
+	ok, err := s.participants.IsActive(conversationID, userID, ParticipantRoleCollaborator)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("当前不是该会话的协作者")
+	}
+	now := time.Now()
+	if err := s.participants.MarkLeft(conversationID, userID, ParticipantRoleCollaborator, "left", now); err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"conversation_id": conversationID,
+		"user_id":         userID,
+		"left_at":         now,
+	}
+	if s.hub != nil {
+		s.hub.BroadcastToAllAgents("conversation_participant_left", payload)
+	}
+	s.writeLog("conversation_leave", conversationID, userID, "协作者离开会话", payload)
+	return nil
+}
+
+// ListParticipants 返回会话的参与记录（含历史负责人与已离开的协作者）
+func (s *CollaborationService) ListParticipants(conversationID uint) ([]models.ConversationParticipant, error) {
+	if _, err := s.getVisitorConversation(conversationID); err != nil {
+		return nil, err
+	}
+	return s.participants.ListByConversationID(conversationID)
+}
+
+// CreateWhisper 发送 whisper 内部备注：仅推送给客服连接，访客侧消息列表与 WebSocket 均不可见。
+func (s *CollaborationService) CreateWhisper(conversationID uint, senderID uint, content string) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("备注内容不能为空")
+	}
+	if len([]rune(content)) > whisperMaxLength {
+		return nil, errors.New("备注内容过长")
+	}
+	if senderID == 0 {
+		return nil, errors.New("sender_id is required for whisper messages")
+	}
+	conv, err := s.getVisitorConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	message := &models.Message{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		SenderIsAgent:  true,
+		Content:        content,
+		MessageType:    models.MessageTypeWhisper,
+		ChatMode:       conv.ChatMode,
+		// 内部备注不计入访客未读
+		IsRead: true,
+		ReadAt: &now,
+	}
+	if err := s.messages.Create(message); err != nil {
+		return nil, err
+	}
+	if s.hub != nil {
+		// 只推送一次，且仅限会话房间内的客服；不经全体客服广播，避免非本会话客服看到内部备注
+		s.hub.BroadcastToConversationAgents(conversationID, "new_message", message)
+	}
+	return message, nil
+}
+
+func (s *CollaborationService) writeLog(event string, conversationID uint, userID uint, message string, meta map[string]interface{}) {
+	if s.systemLogSvc == nil {
+		return
+	}
+	convID := conversationID
+	uID := userID
+	_ = s.systemLogSvc.Create(CreateSystemLogInput{
+		Level:          "info",
+		Category:       "business",
+		Event:          event,
+		Source:         "backend",
+		ConversationID: &convID,
+		UserID:         &uID,
+		Message:        message,
+		Meta:           meta,
+	})
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max])
+}
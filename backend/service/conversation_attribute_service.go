@@ -0,0 +1,673 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"gorm.io/gorm"
+)
+
+// 会话优先级
+const (
+	PriorityLow    = 0
+	PriorityNormal = 1
+	PriorityHigh   = 2
+	PriorityUrgent = 3
+)
+
+var priorityLabels = []string{"low", "normal", "high", "urgent"}
+
+// 自定义字段类型
+const (
+	CustomFieldTypeText   = "text"
+	CustomFieldTypeSelect = "select"
+	CustomFieldTypeNumber = "number"
+)
+
+const (
+	tagNameMaxLength        = 50
+	customFieldValueMaxSize = 500
+)
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// ErrTagNotFound 标签不存在
+var ErrTagNotFound = errors.New("标签不存在")
+
+// ErrCustomFieldNotFound 自定义字段不存在
+var ErrCustomFieldNotFound = errors.New("自定义字段不存在")
+
+// ErrInvalidListFilter 会话列表筛选 / 排序参数无效
+var ErrInvalidListFilter = errors.New("筛选条件无效")
+
+// PriorityLabel 返回优先级名称（low / normal / high / urgent）
+func PriorityLabel(priority int) string {
+	if priority < 0 || priority >= len(priorityLabels) {
+		return priorityLabels[PriorityNormal]
+	}
+	return priorityLabels[priority]
+}
+
+// ParsePriority 解析优先级，支持名称（low / normal / high / urgent）或数字（0-3）
+func ParsePriority(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, label := range priorityLabels {
+		if s == label {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= PriorityLow && n <= PriorityUrgent {
+		return n, nil
+	}
+	return 0, fmt.Errorf("不支持的优先级: %s", s)
+}
+
+// ConversationAttributes 会话的标签、优先级与自定义字段。
+type ConversationAttributes struct {
+	ConversationID uint                   `json:"conversation_id"`
+	Priority       int                    `json:"priority"`
+	PriorityLabel  string                 `json:"priority_label"`
+	Tags           []models.Tag           `json:"tags"`
+	CustomFields   map[string]interface{} `json:"custom_fields"`
+}
+
+// CustomFieldInput 创建 / 更新自定义字段定义的参数（nil 表示不修改）。
+type CustomFieldInput struct {
+	Key       *string
+	Label     *string
+	FieldType *string
+	Options   *[]string
+	SortOrder *int
+}
+
+// ConversationAttributeService 负责会话标签、优先级与管理员自定义字段，并在变更时通过 WebSocket 通知客服。
+type ConversationAttributeService struct {
+	conversations *repository.ConversationRepository
+	tags          *repository.TagRepository
+	fields        *repository.CustomFieldRepository
+	hub           BroadcastHub
+}
+
+// NewConversationAttributeService 创建会话属性服务实例。
+func NewConversationAttributeService(
+	conversations *repository.ConversationRepository,
+	tags *repository.TagRepository,
+	fields *repository.CustomFieldRepository,
+	hub BroadcastHub,
+) *ConversationAttributeService {
+	return &ConversationAttributeService{
+		conversations: conversations,
+		tags:          tags,
+		fields:        fields,
+		hub:           hub,
+	}
+}
+
+// ---------- 标签 ----------
+
+// ListTags 列出全部标签
+func (s *ConversationAttributeService) ListTags() ([]models.Tag, error) {
+	return s.tags.List()
+}
+
+// CreateTag 新建标签（同名标签已存在时返回错误）
+func (s *ConversationAttributeService) CreateTag(name, color string) (*models.Tag, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := s.tags.GetByName(name); err == nil && existing != nil {
+		return nil, errors.New("标签已存在")
+	}
+	tag := &models.Tag{Name: name, Color: strings.TrimSpace(color)}
+	if err := s.tags.Create(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// UpdateTag 修改标签名称 / 颜色
+func (s *ConversationAttributeService) UpdateTag(id uint, name, color *string) (*models.Tag, error) {
+	tag, err := s.getTag(id)
+	if err != nil {
+		return nil, err
+	}
+	if name != nil {
+		normalized, err := normalizeTagName(*name)
+		if err != nil {
+			return nil, err
+		}
+		if existing, err := s.tags.GetByName(normalized); err == nil && existing != nil && existing.ID != id {
+			return nil, errors.New("标签已存在")
+		}
+		tag.Name = normalized
+	}
+	if color != nil {
+		tag.Color = strings.TrimSpace(*color)
+	}
+	if err := s.tags.Update(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// DeleteTag 删除标签（同时移除所有会话上的该标签）
+func (s *ConversationAttributeService) DeleteTag(id uint) error {
+	if _, err := s.getTag(id); err != nil {
+		return err
+	}
+	return s.tags.Delete(id)
+}
+
+func (s *ConversationAttributeService) getTag(id uint) (*models.Tag, error) {
+	tag, err := s.tags.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+	return tag, nil
+}
+
+func normalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("标签名称不能为空")
+	}
+	if len([]rune(name)) > tagNameMaxLength {
+		return "", errors.New("标签名称过长")
+	}
+	return name, nil
+}
+
+// SetTags 覆盖会话的标签
+func (s *ConversationAttributeService) SetTags(conversationID uint, tagIDs []uint, operatorID uint) (*ConversationAttributes, error) {
+	if _, err := s.getConversation(conversationID); err != nil {
+		return nil, err
+	}
+	seen := make(map[uint]struct{}, len(tagIDs))
+	unique := make([]uint, 0, len(tagIDs))
+	for _, id := range tagIDs {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			unique = append(unique, id)
+		}
+	}
+	tagIDs = unique
+	tags, err := s.tags.ListByIDs(tagIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) != len(tagIDs) {
+		return nil, ErrTagNotFound
+	}
+	if err := s.tags.ReplaceForConversation(conversationID, tagIDs, operatorID); err != nil {
+		return nil, err
+	}
+	return s.notifyChanged(conversationID)
+}
+
+// AddTag 为会话添加标签
+func (s *ConversationAttributeService) AddTag(conversationID, tagID, operatorID uint) (*ConversationAttributes, error) {
+	if _, err := s.getConversation(conversationID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getTag(tagID); err != nil {
+		return nil, err
+	}
+	if err := s.tags.AddToConversation(conversationID, tagID, operatorID); err != nil {
+		return nil, err
+	}
+	return s.notifyChanged(conversationID)
+}
+
+// AddTagByName 按名称为会话添加标签，标签不存在时自动创建（供快捷回复的 add_tag 动作使用）
+func (s *ConversationAttributeService) AddTagByName(conversationID uint, name string, operatorID uint) error {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return err
+	}
+	tag, err := s.tags.GetByName(name)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if tag, err = s.CreateTag(name, ""); err != nil {
+			return err
+		}
+	}
+	_, err = s.AddTag(conversationID, tag.ID, operatorID)
+	return err
+}
+
+// RemoveTag 移除会话的标签
+func (s *ConversationAttributeService) RemoveTag(conversationID, tagID uint) (*ConversationAttributes, error) {
+	if _, err := s.getConversation(conversationID); err != nil {
+		return nil, err
+	}
+	if err := s.tags.RemoveFromConversation(conversationID, tagID); err != nil {
+		return nil, err
+	}
+	return s.notifyChanged(conversationID)
+}
+
+// ---------- 优先级 ----------
+
+// SetPriority 修改会话优先级
+func (s *ConversationAttributeService) SetPriority(conversationID uint, priority int) (*ConversationAttributes, error) {
+	if priority < PriorityLow || priority > PriorityUrgent {
+		return nil, errors.New("优先级取值为 0-3")
+	}
+	if _, err := s.getConversation(conversationID); err != nil {
+		return nil, err
+	}
+	if err := s.conversations.UpdateFields(conversationID, map[string]interface{}{"priority": priority}); err != nil {
+		return nil, err
+	}
+	return s.notifyChanged(conversationID)
+}
+
+// ---------- 自定义字段 ----------
+
+// ListFieldDefinitions 列出全部自定义字段定义
+func (s *ConversationAttributeService) ListFieldDefinitions() ([]models.CustomFieldDefinition, error) {
+	return s.fields.ListDefinitions()
+}
+
+// CreateFieldDefinition 新建自定义字段（key、label、field_type 必填；select 类型需提供选项）
+func (s *ConversationAttributeService) CreateFieldDefinition(input CustomFieldInput) (*models.CustomFieldDefinition, error) {
+	if input.Key == nil || input.Label == nil || input.FieldType == nil {
+		return nil, errors.New("key、label、field_type 不能为空")
+	}
+	def := &models.CustomFieldDefinition{}
+	if err := s.applyFieldInput(def, input); err != nil {
+		return nil, err
+	}
+	if existing, err := s.fields.GetDefinitionByKey(def.Key); err == nil && existing != nil {
+		return nil, errors.New("字段标识已存在")
+	}
+	if err := s.fields.CreateDefinition(def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// UpdateFieldDefinition 修改自定义字段定义（字段类型不可修改，以免已有取值失效）
+func (s *ConversationAttributeService) UpdateFieldDefinition(id uint, input CustomFieldInput) (*models.CustomFieldDefinition, error) {
+	def, err := s.getFieldDefinition(id)
+	if err != nil {
+		return nil, err
+	}
+	if input.FieldType != nil && *input.FieldType != def.FieldType {
+		return nil, errors.New("字段类型不可修改")
+	}
+	oldKey := def.Key
+	if err := s.applyFieldInput(def, input); err != nil {
+		return nil, err
+	}
+	if def.Key != oldKey {
+		if existing, err := s.fields.GetDefinitionByKey(def.Key); err == nil && existing != nil {
+			return nil, errors.New("字段标识已存在")
+		}
+	}
+	if err := s.fields.UpdateDefinition(def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// DeleteFieldDefinition 删除自定义字段及其全部取值
+func (s *ConversationAttributeService) DeleteFieldDefinition(id uint) error {
+	if _, err := s.getFieldDefinition(id); err != nil {
+		return err
+	}
+	return s.fields.DeleteDefinition(id)
+}
+
+func (s *ConversationAttributeService) getFieldDefinition(id uint) (*models.CustomFieldDefinition, error) {
+	def, err := s.fields.GetDefinition(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomFieldNotFound
+		}
+		return nil, err
+	}
+	return def, nil
+}
+
+func (s *ConversationAttributeService) applyFieldInput(def *models.CustomFieldDefinition, input CustomFieldInput) error {
+	if input.Key != nil {
+		key := strings.TrimSpace(*input.Key)
+		if !customFieldKeyPattern.MatchString(key) {
+			return errors.New("字段标识须以小写字母开头，仅含小写字母、数字和下划线")
+		}
+		def.Key = key
+	}
+	if input.Label != nil {
+		label := strings.TrimSpace(*input.Label)
+		if label == "" {
+			return errors.New("字段名称不能为空")
+		}
+		def.Label = label
+	}
+	if input.FieldType != nil {
+		switch *input.FieldType {
+		case CustomFieldTypeText, CustomFieldTypeSelect, CustomFieldTypeNumber:
+			def.FieldType = *input.FieldType
+		default:
+			return fmt.Errorf("不支持的字段类型: %s", *input.FieldType)
+		}
+	}
+	if input.Options != nil {
+		options := make([]string, 0, len(*input.Options))
+		for _, opt := range *input.Options {
+			if opt = strings.TrimSpace(opt); opt != "" {
+				options = append(options, opt)
+			}
+		}
+		raw, _ := json.Marshal(options)
+		def.Options = string(raw)
+	}
+	if input.SortOrder != nil {
+		def.SortOrder = *input.SortOrder
+	}
+	if def.FieldType == CustomFieldTypeSelect && len(fieldOptions(def)) == 0 {
+		return errors.New("下拉字段至少需要一个选项")
+	}
+	return nil
+}
+
+// fieldOptions 解析 select 字段的选项
+func fieldOptions(def *models.CustomFieldDefinition) []string {
+	var options []string
+	if strings.TrimSpace(def.Options) == "" {
+		return options
+	}
+	_ = json.Unmarshal([]byte(def.Options), &options)
+	return options
+}
+
+// SetFieldValues 批量写入会话的自定义字段取值（key -> 值；null 或空字符串表示清除）
+func (s *ConversationAttributeService) SetFieldValues(conversationID uint, values map[string]interface{}, operatorID uint) (*ConversationAttributes, error) {
+	if _, err := s.getConversation(conversationID); err != nil {
+		return nil, err
+	}
+	defs, err := s.definitionsByKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// 先整体校验，避免部分写入
+	rows := make([]models.ConversationFieldValue, 0, len(values))
+	var clears []uint
+	for key, raw := range values {
+		def, ok := defs[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrCustomFieldNotFound, key)
+		}
+		text := ""
+		if raw != nil {
+			text = strings.TrimSpace(fmt.Sprint(raw))
+		}
+		if text == "" {
+			clears = append(clears, def.ID)
+			continue
+		}
+		row, err := buildFieldValue(def, text)
+		if err != nil {
+			return nil, err
+		}
+		row.ConversationID = conversationID
+		row.UpdatedBy = operatorID
+		rows = append(rows, *row)
+	}
+
+	for _, fieldID := range clears {
+		if err := s.fields.DeleteValue(conversationID, fieldID); err != nil {
+			return nil, err
+		}
+	}
+	for i := range rows {
+		if err := s.fields.UpsertValue(&rows[i]); err != nil {
+			return nil, err
+		}
+	}
+	return s.notifyChanged(conversationID)
+}
+
+// buildFieldValue 按字段类型校验并构造取值
+func buildFieldValue(def *models.CustomFieldDefinition, text string) (*models.ConversationFieldValue, error) {
+	row := &models.ConversationFieldValue{FieldID: def.ID, Value: text, UpdatedAt: time.Now()}
+	switch def.FieldType {
+	case CustomFieldTypeNumber:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("字段 %s 需为数字", def.Label)
+		}
+		row.Value = strconv.FormatFloat(n, 'f', -1, 64)
+		row.NumberValue = &n
+	case CustomFieldTypeSelect:
+		matched := false
+		for _, opt := range fieldOptions(def) {
+			if opt == text {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("字段 %s 的取值不在选项中", def.Label)
+		}
+	default:
+		if len([]rune(text)) > customFieldValueMaxSize {
+			return nil, fmt.Errorf("字段 %s 内容过长", def.Label)
+		}
+	}
+	return row, nil
+}
+
+func (s *ConversationAttributeService) definitionsByKey() (map[string]*models.CustomFieldDefinition, error) {
+	defs, err := s.fields.ListDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*models.CustomFieldDefinition, len(defs))
+	for i := range defs {
+		result[defs[i].Key] = &defs[i]
+	}
+	return result, nil
+}
+
+// ---------- 读取与批量加载 ----------
+
+// GetAttributes 读取会话的标签、优先级与自定义字段
+func (s *ConversationAttributeService) GetAttributes(conversationID uint) (*ConversationAttributes, error) {
+	conv, err := s.getConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	tagMap, fieldMap, err := s.LoadBatch([]uint{conversationID})
+	if err != nil {
+		return nil, err
+	}
+	return &ConversationAttributes{
+		ConversationID: conversationID,
+		Priority:       conv.Priority,
+		PriorityLabel:  PriorityLabel(conv.Priority),
+		Tags:           nonNilTags(tagMap[conversationID]),
+		CustomFields:   nonNilFields(fieldMap[conversationID]),
+	}, nil
+}
+
+// LoadBatch 批量加载多个会话的标签与自定义字段取值（用于会话列表，避免 N+1）
+func (s *ConversationAttributeService) LoadBatch(conversationIDs []uint) (map[uint][]models.Tag, map[uint]map[string]interface{}, error) {
+	tagMap, err := s.tags.BatchListByConversationIDs(conversationIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := s.fields.ListValuesByConversationIDs(conversationIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	fieldMap := make(map[uint]map[string]interface{})
+	if len(values) == 0 {
+		return tagMap, fieldMap, nil
+	}
+	defs, err := s.fields.ListDefinitions()
+	if err != nil {
+		return nil, nil, err
+	}
+	defByID := make(map[uint]*models.CustomFieldDefinition, len(defs))
+	for i := range defs {
+		defByID[defs[i].ID] = &defs[i]
+	}
+	for _, v := range values {
+		def, ok := defByID[v.FieldID]
+		if !ok {
+			continue
+		}
+		if fieldMap[v.ConversationID] == nil {
+			fieldMap[v.ConversationID] = make(map[string]interface{})
+		}
+		if def.FieldType == CustomFieldTypeNumber && v.NumberValue != nil {
+			fieldMap[v.ConversationID][def.Key] = *v.NumberValue
+		} else {
+			fieldMap[v.ConversationID][def.Key] = v.Value
+		}
+	}
+	return tagMap, fieldMap, nil
+}
+
+// FindConversationIDsByTagName 按标签名称模糊匹配会话（用于会话搜索）
+func (s *ConversationAttributeService) FindConversationIDsByTagName(pattern string) ([]uint, error) {
+	return s.tags.FindConversationIDsByNameLike(pattern)
+}
+
+// ResolveListFilter 将列表筛选条件（标签名称、字段 key）解析为仓库层可用的 ID 条件
+func (s *ConversationAttributeService) ResolveListFilter(filter ConversationListFilter) (repository.ConversationQueryFilter, error) {
+	result := repository.ConversationQueryFilter{
+		TagIDs:      append([]uint(nil), filter.TagIDs...),
+		Priorities:  filter.Priorities,
+		Categories:  filter.Categories,
+		Resolutions: filter.Resolutions,
+		SortAsc:     filter.SortAsc,
+	}
+	if len(filter.TagNames) > 0 {
+		tags, err := s.tags.ListByNames(filter.TagNames)
+		if err != nil {
+			return result, err
+		}
+		if len(tags) != len(filter.TagNames) {
+			// 存在未知标签：不可能有会话同时具备，用不存在的 ID 使结果为空
+			result.TagIDs = append(result.TagIDs, 0)
+		}
+		for _, tag := range tags {
+			result.TagIDs = append(result.TagIDs, tag.ID)
+		}
+	}
+
+	sortBy := filter.SortBy
+	var defs map[string]*models.CustomFieldDefinition
+	needDefs := len(filter.Fields) > 0
+	switch sortBy {
+	case "", "updated_at", "created_at", "priority":
+	default:
+		needDefs = true
+	}
+	if needDefs {
+		var err error
+		if defs, err = s.definitionsByKey(); err != nil {
+			return result, err
+		}
+	}
+
+	for _, cond := range filter.Fields {
+		def, ok := defs[cond.Key]
+		if !ok {
+			return result, fmt.Errorf("%w: 未知字段 %s", ErrInvalidListFilter, cond.Key)
+		}
+		op := cond.Op
+		if op == "" {
+			op = "eq"
+		}
+		rc := repository.FieldValueCondition{FieldID: def.ID, Op: op, Value: cond.Value}
+		switch op {
+		case "eq", "contains":
+		case "gt", "gte", "lt", "lte":
+			if def.FieldType != CustomFieldTypeNumber {
+				return result, fmt.Errorf("%w: 字段 %s 不支持范围筛选", ErrInvalidListFilter, cond.Key)
+			}
+		default:
+			return result, fmt.Errorf("%w: 不支持的筛选操作 %s", ErrInvalidListFilter, op)
+		}
+		if def.FieldType == CustomFieldTypeNumber && op != "contains" {
+			n, err := strconv.ParseFloat(cond.Value, 64)
+			if err != nil {
+				return result, fmt.Errorf("%w: 字段 %s 需为数字", ErrInvalidListFilter, cond.Key)
+			}
+			rc.Number = n
+			rc.Numeric = true
+		}
+		result.Fields = append(result.Fields, rc)
+	}
+
+	switch sortBy {
+	case "", "updated_at", "created_at", "priority":
+		result.SortBy = sortBy
+	default:
+		def, ok := defs[sortBy]
+		if !ok {
+			return result, fmt.Errorf("%w: 不支持的排序字段 %s", ErrInvalidListFilter, sortBy)
+		}
+		result.SortBy = "field"
+		result.SortFieldID = def.ID
+		result.SortNumeric = def.FieldType == CustomFieldTypeNumber
+	}
+	return result, nil
+}
+
+// ---------- 内部工具 ----------
+
+func (s *ConversationAttributeService) getConversation(conversationID uint) (*models.Conversation, error) {
+	conv, err := s.conversations.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	return conv, nil
+}
+
+// notifyChanged 读取最新属性并推送 conversation_attributes_updated（仅客服端，访客不可见）
+func (s *ConversationAttributeService) notifyChanged(conversationID uint) (*ConversationAttributes, error) {
+	attrs, err := s.GetAttributes(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if s.hub != nil {
+		s.hub.BroadcastToAllAgents("conversation_attributes_updated", attrs)
+	}
+	return attrs, nil
+}
+
+func nonNilTags(tags []models.Tag) []models.Tag {
+	if tags == nil {
+		return []models.Tag{}
+	}
+	return tags
+}
+
+func nonNilFields(fields map[string]interface{}) map[string]interface{} {
+	if fields == nil {
+		return map[string]interface{}{}
+	}
+	return fields
+}
@@ -55,6 +55,15 @@ func (s *ConversationService) buildSummariesBatch(conversations []models.Convers
 		}
 	}
 
+	var tagMap map[uint][]models.Tag
+	var fieldMap map[uint]map[string]interface{}
+	if s.attributeSvc != nil {
+		tagMap, fieldMap, err = s.attributeSvc.LoadBatch(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	result := make([]ConversationSummary, 0, len(conversations))
 	for _, conv := range conversations {
 		var lastSeen *time.Time
@@ -74,7 +83,11 @@ func (s *ConversationService) buildSummariesBatch(conversations []models.Convers
 			LastSeenAt:       lastSeen,
 			UnreadCount:      unreadMap[conv.ID],
 			HasParticipated:  participatedMap[conv.ID],
+			Priority:         conv.Priority,
+			Tags:             tagMap[conv.ID],
+			CustomFields:     fieldMap[conv.ID],
 		}
+		withConversationSummaryFields(&summary, conv)
 
 		if message := latestMap[conv.ID]; message != nil {
 			var readAt *time.Time
@@ -97,15 +110,19 @@ func (s *ConversationService) buildSummariesBatch(conversations []models.Convers
 	return result, nil
 }
 
-// ListConversationsPaginated 分页返回访客会话列表（批量 SQL，无 N+1）。
-func (s *ConversationService) ListConversationsPaginated(userID uint, status string, page, pageSize int) (*ConversationListResult, error) {
+// ListConversationsPaginated 分页返回访客会话列表（批量 SQL，无 N+1），支持按标签 / 优先级 / 自定义字段筛选与排序。
+func (s *ConversationService) ListConversationsPaginated(userID uint, status string, page, pageSize int, filter ConversationListFilter) (*ConversationListResult, error) {
 	if status == "" {
 		status = "open"
 	}
 	page, pageSize = parseConversationListPagination(page, pageSize)
 	offset := (page - 1) * pageSize
 
-	conversations, total, err := s.conversations.ListVisitorForAgentList(status, offset, pageSize)
+	queryFilter, err := s.resolveListFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	conversations, total, err := s.conversations.ListVisitorForAgentList(status, queryFilter, offset, pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +263,14 @@ func (s *ConversationService) CloseStaleOpenVisitorConversations(inactiveDays in
 		return 0, nil
 	}
 	cutoff := time.Now().AddDate(0, 0, -inactiveDays)
-	return s.conversations.CloseStaleOpenVisitorConversations(cutoff)
+	ids, err := s.conversations.CloseStaleOpenVisitorConversations(cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if s.summarySvc != nil {
+		s.summarySvc.OnConversationsClosed(ids...)
+	}
+	return int64(len(ids)), nil
 }
 
 // StartStaleConversationCleanup 启动后台任务：定期关闭长期未活跃的 open 访客会话。
@@ -2,6 +2,8 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -20,6 +22,69 @@ type ConversationService struct {
 	userRepo      *repository.UserRepository     // 用于查询用户设置
 	systemLogSvc  *SystemLogService              // 可选，结构化日志
 	appSettings   *repository.AppSettingRepository // 平台级会话维护等配置
+	kbBindingSvc  *KnowledgeBaseBindingService     // 可选，解析会话的知识库范围
+	assignmentSvc *AssignmentService               // 可选，人工会话自动分配客服
+	attributeSvc  *ConversationAttributeService    // 可选，会话标签 / 优先级 / 自定义字段
+	summarySvc    *ConversationSummaryService      // 可选，会话关闭后生成小结与分类
+}
+
+// SetSummaryService 注入会话小结服务（手动 / 自动关闭会话后异步生成小结）
+func (s *ConversationService) SetSummaryService(svc *ConversationSummaryService) {
+	s.summarySvc = svc
+}
+
+// SetAttributeService 注入会话属性服务（列表展示与按标签 / 优先级 / 自定义字段筛选排序）
+func (s *ConversationService) SetAttributeService(svc *ConversationAttributeService) {
+	s.attributeSvc = svc
+}
+
+// resolveListFilter 将列表筛选条件解析为仓库层条件；未启用属性服务时仅支持优先级与时间排序
+func (s *ConversationService) resolveListFilter(filter ConversationListFilter) (repository.ConversationQueryFilter, error) {
+	if s.attributeSvc != nil {
+		return s.attributeSvc.ResolveListFilter(filter)
+	}
+	if len(filter.TagIDs) > 0 || len(filter.TagNames) > 0 || len(filter.Fields) > 0 {
+		return repository.ConversationQueryFilter{}, fmt.Errorf("%w: 未启用会话标签与自定义字段", ErrInvalidListFilter)
+	}
+	return repository.ConversationQueryFilter{
+		Priorities:  filter.Priorities,
+		Categories:  filter.Categories,
+		Resolutions: filter.Resolutions,
+		SortBy:      filter.SortBy,
+		SortAsc:     filter.SortAsc,
+	}, nil
+}
+
+// SetAssignmentService 注入会话分配服务（新建人工会话或 AI 切人工时自动分配客服）
+func (s *ConversationService) SetAssignmentService(svc *AssignmentService) {
+	s.assignmentSvc = svc
+}
+
+// autoAssign 按分配策略为会话选择客服（失败仅记录日志，不影响会话初始化）
+func (s *ConversationService) autoAssign(conversationID uint, fromAI bool, reason string) {
+	if s.assignmentSvc == nil {
+		return
+	}
+	if _, err := s.assignmentSvc.AutoAssign(conversationID, fromAI, reason); err != nil {
+		log.Printf("⚠️ 自动分配客服失败: conversation_id=%d err=%v", conversationID, err)
+	}
+}
+
+// SetKnowledgeBaseBindingService 注入知识库绑定服务（用于 init 时解析会话知识库范围）
+func (s *ConversationService) SetKnowledgeBaseBindingService(svc *KnowledgeBaseBindingService) {
+	s.kbBindingSvc = svc
+}
+
+// resolveKnowledgeBaseScope 解析会话知识库范围，返回逗号分隔的知识库 ID（空表示不限定）
+func (s *ConversationService) resolveKnowledgeBaseScope(explicitIDs []uint, widgetKey string, website string) (string, error) {
+	if s.kbBindingSvc == nil {
+		return utils.JoinUintList(explicitIDs), nil
+	}
+	ids, err := s.kbBindingSvc.ResolveScope(explicitIDs, widgetKey, website)
+	if err != nil {
+		return "", err
+	}
+	return utils.JoinUintList(ids), nil
 }
 
 // CloseConversation 客服主动关闭会话（visitor/internal 通用）。
@@ -41,9 +106,15 @@ func (s *ConversationService) CloseConversation(conversationID uint, userID uint
 	if conv.Status == "closed" {
 		return nil
 	}
-	return s.conversations.UpdateFields(conversationID, map[string]interface{}{
+	if err := s.conversations.UpdateFields(conversationID, map[string]interface{}{
 		"status": "closed",
-	})
+	}); err != nil {
+		return err
+	}
+	if conv.ConversationType == "visitor" && s.summarySvc != nil {
+		s.summarySvc.OnConversationsClosed(conversationID)
+	}
+	return nil
 }
 
 // NewConversationService 创建 ConversationService 实例。
@@ -74,6 +145,7 @@ func (s *ConversationService) InitConversation(input InitConversationInput) (*In
 
 	conv, err = s.conversations.FindOpenByVisitorID(input.VisitorID)
 	isNewConversation := false
+	switchedFromAI := false // 本次由 AI 模式切换为人工
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -103,6 +175,11 @@ func (s *ConversationService) InitConversation(input InitConversationInput) (*In
 				aiConfigID = input.AIConfigID
 			}
 
+			kbScope, err := s.resolveKnowledgeBaseScope(input.KnowledgeBaseIDs, input.WidgetKey, input.Website)
+			if err != nil {
+				return nil, err
+			}
+
 			accessToken, err := utils.GenerateConversationAccessToken()
 			if err != nil {
 				return nil, err
@@ -123,6 +200,9 @@ func (s *ConversationService) InitConversation(input InitConversationInput) (*In
 				LastSeenAt:       &now,
 				ChatMode:         chatMode,
 				AIConfigID:       aiConfigID,
+				KnowledgeBaseIDs: kbScope,
+				WidgetKey:        strings.TrimSpace(input.WidgetKey),
+				RoutingSkills:    strings.Join(utils.SplitTags(strings.Join(input.Skills, ",")), ","),
 			}
 			if err := s.conversations.Create(conv); err != nil {
 				return nil, err
@@ -172,6 +252,22 @@ func (s *ConversationService) InitConversation(input InitConversationInput) (*In
 		if input.IPAddress != "" && conv.IPAddress == "" {
 			updates["ip_address"] = input.IPAddress
 		}
+		if skills := strings.Join(utils.SplitTags(strings.Join(input.Skills, ",")), ","); skills != "" && skills != conv.RoutingSkills {
+			updates["routing_skills"] = skills
+		}
+		// 显式指定知识库或挂件 key 时重新解析范围（同一访客可能换了挂件/产品线）
+		if len(input.KnowledgeBaseIDs) > 0 || strings.TrimSpace(input.WidgetKey) != "" {
+			kbScope, err := s.resolveKnowledgeBaseScope(input.KnowledgeBaseIDs, input.WidgetKey, input.Website)
+			if err != nil {
+				return nil, err
+			}
+			if kbScope != conv.KnowledgeBaseIDs {
+				updates["knowledge_base_ids"] = kbScope
+			}
+			if widgetKey := strings.TrimSpace(input.WidgetKey); widgetKey != "" && widgetKey != conv.WidgetKey {
+				updates["widget_key"] = widgetKey
+			}
+		}
 		// 补全地理位置：新 IP、或历史会话仅有 IP 无 location（如升级前创建、或当时缺 v6 库）
 		ipForGeo := conv.IPAddress
 		if input.IPAddress != "" {
@@ -210,6 +306,7 @@ func (s *ConversationService) InitConversation(input InitConversationInput) (*In
 			} else {
 				// 切换到人工客服模式，清除 AI 配置
 				updates["ai_config_id"] = nil
+				switchedFromAI = oldMode == "ai"
 			}
 			if s.systemLogSvc != nil {
 				convID := conv.ID
@@ -308,6 +405,13 @@ func (s *ConversationService) InitConversation(input InitConversationInput) (*In
 		}
 	}
 
+	// 人工会话自动分配客服：新建会话，或访客由 AI 切换为人工
+	if conv.ChatMode == "human" && isNewConversation {
+		s.autoAssign(conv.ID, false, "created")
+	} else if conv.ChatMode == "human" && switchedFromAI {
+		s.autoAssign(conv.ID, true, "handoff")
+	}
+
 	return &InitConversationResult{
 		ConversationID: conv.ID,
 		Status:         conv.Status,
@@ -370,6 +474,15 @@ func (s *ConversationService) buildSummary(conv models.Conversation, userID uint
 		UpdatedAt:         conv.UpdatedAt,
 		LastSeenAt:        lastSeen,
 		HasParticipated:   hasParticipated,
+		Priority:          conv.Priority,
+	}
+	withConversationSummaryFields(&summary, conv)
+
+	if s.attributeSvc != nil {
+		if tagMap, fieldMap, err := s.attributeSvc.LoadBatch([]uint{conv.ID}); err == nil {
+			summary.Tags = tagMap[conv.ID]
+			summary.CustomFields = fieldMap[conv.ID]
+		}
 	}
 
 	if message, err := s.messages.LatestByConversationID(conv.ID); err == nil && message != nil {
@@ -397,7 +510,7 @@ func (s *ConversationService) buildSummary(conv models.Conversation, userID uint
 
 // ListConversations 返回当前活跃会话的摘要信息（兼容旧调用，等价于第一页分页）。
 func (s *ConversationService) ListConversations(userID uint, status string) ([]ConversationSummary, error) {
-	result, err := s.ListConversationsPaginated(userID, status, 1, maxConversationPageSize)
+	result, err := s.ListConversationsPaginated(userID, status, 1, maxConversationPageSize, ConversationListFilter{})
 	if err != nil {
 		return nil, err
 	}
@@ -444,13 +557,19 @@ func (s *ConversationService) GetConversationDetail(id uint, userID uint) (*Conv
 		Phone:               conv.Phone,
 		Notes:               conv.Notes,
 		LastSeen:            lastSeen,
+		KnowledgeBaseIDs:    utils.ParseUintList(conv.KnowledgeBaseIDs),
 	}, nil
 }
 
 // SearchConversations 根据关键字检索会话摘要。
 // userID: 当前登录的客服ID（可选，用于检查参与状态）
 // conversationType: visitor | internal，空表示不过滤
-func (s *ConversationService) SearchConversations(query string, userID uint, status string, conversationType string) ([]ConversationSummary, error) {
+// filter: 标签 / 优先级 / 自定义字段筛选与排序；关键字同时匹配标签名称
+func (s *ConversationService) SearchConversations(query string, userID uint, status string, conversationType string, filter ConversationListFilter) ([]ConversationSummary, error) {
+	queryFilter, err := s.resolveListFilter(filter)
+	if err != nil {
+		return nil, err
+	}
 	pattern := "%" + query + "%"
 
 	idSet := map[uint]struct{}{}
@@ -471,6 +590,25 @@ func (s *ConversationService) SearchConversations(query string, userID uint, sta
 		return nil, err
 	}
 
+	// 会话小结 / 分类 / 关键实体（如订单号）
+	if ids, err := s.conversations.FindIDsBySummaryLike(pattern); err == nil {
+		for _, id := range ids {
+			idSet[id] = struct{}{}
+		}
+	} else {
+		return nil, err
+	}
+
+	if s.attributeSvc != nil {
+		ids, err := s.attributeSvc.FindConversationIDsByTagName(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			idSet[id] = struct{}{}
+		}
+	}
+
 	if len(idSet) == 0 {
 		return []ConversationSummary{}, nil
 	}
@@ -480,7 +618,7 @@ func (s *ConversationService) SearchConversations(query string, userID uint, sta
 		ids = append(ids, id)
 	}
 
-	conversations, err := s.conversations.ListByIDs(ids)
+	conversations, err := s.conversations.ListByIDsFiltered(ids, status, queryFilter)
 	if err != nil {
 		return nil, err
 	}
@@ -535,16 +673,23 @@ func (s *ConversationService) UpdateLastSeenAt(conversationID uint) error {
 }
 
 // InitInternalConversation 为客服创建一条新的内部对话（知识库测试用）。每次调用创建新会话。
-func (s *ConversationService) InitInternalConversation(agentID uint) (*InitConversationResult, error) {
+// knowledgeBaseIDs 非空时该测试会话仅检索这些知识库。
+func (s *ConversationService) InitInternalConversation(agentID uint, knowledgeBaseIDs []uint) (*InitConversationResult, error) {
 	if agentID == 0 {
 		return nil, errors.New("agent_id is required for internal conversation")
 	}
+	if s.kbBindingSvc != nil {
+		if err := s.kbBindingSvc.ValidateKnowledgeBaseIDs(knowledgeBaseIDs); err != nil {
+			return nil, err
+		}
+	}
 	conv := &models.Conversation{
 		ConversationType: "internal",
 		VisitorID:        0,
 		AgentID:          agentID,
 		Status:           "open",
 		ChatMode:         "ai",
+		KnowledgeBaseIDs: utils.JoinUintList(knowledgeBaseIDs),
 	}
 	if err := s.conversations.Create(conv); err != nil {
 		return nil, err
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"gorm.io/gorm"
+)
+
+// 会话小结状态
+const (
+	SummaryStatusPending = "pending"
+	SummaryStatusDone    = "done"
+	SummaryStatusFailed  = "failed"
+	SummaryStatusSkipped = "skipped" // 无访客消息等无需小结的会话
+)
+
+const (
+	// 待处理小结队列容量（批量自动关闭时超出部分丢弃，可手动重新生成）
+	summaryQueueSize = 512
+	// 单次小结的超时时间
+	summaryTimeout = 90 * time.Second
+	// 分类名称最大长度
+	categoryNameMaxLength = 64
+)
+
+// ErrConversationCategoryNotFound 会话分类不存在
+var ErrConversationCategoryNotFound = errors.New("分类不存在")
+
+// ConversationSummarySettings 会话小结配置（保存在 app_settings）
+type ConversationSummarySettings struct {
+	Enabled    bool `json:"enabled"`      // 会话关闭后自动生成小结
+	AIConfigID uint `json:"ai_config_id"` // 0 表示按会话所选模型 / 负责客服的默认文本模型
+}
+
+// ConversationCategoryInput 创建 / 修改分类的参数（nil 表示不修改）
+type ConversationCategoryInput struct {
+	Name        *string
+	Description *string
+	SortOrder   *int
+}
+
+// ConversationSummaryService 会话关闭后异步生成小结：摘要、分类（取自管理员定义的分类）、解决状态与关键实体。
+type ConversationSummaryService struct {
+	conversations *repository.ConversationRepository
+	messages      *repository.MessageRepository
+	categories    *repository.ConversationCategoryRepository
+	aiConfigs     *repository.AIConfigRepository
+	appSettings   *repository.AppSettingRepository
+	aiService     *AIService
+	systemLogSvc  *SystemLogService
+
+	queue chan uint
+}
+
+// NewConversationSummaryService 创建会话小结服务实例（需调用 Start 启动后台任务）。
+func NewConversationSummaryService(
+	conversations *repository.ConversationRepository,
+	messages *repository.MessageRepository,
+	categories *repository.ConversationCategoryRepository,
+	aiConfigs *repository.AIConfigRepository,
+	appSettings *repository.AppSettingRepository,
+	aiService *AIService,
+	systemLogSvc *SystemLogService,
+) *ConversationSummaryService {
+	return &ConversationSummaryService{
+		conversations: conversations,
+		messages:      messages,
+		categories:    categories,
+		aiConfigs:     aiConfigs,
+		appSettings:   appSettings,
+		aiService:     aiService,
+		systemLogSvc:  systemLogSvc,
+		queue:         make(chan uint, summaryQueueSize),
+	}
+}
+
+// Start 启动后台任务：逐个处理已关闭会话的小结（串行，避免批量自动关闭时瞬间打满模型并发）。
+func (s *ConversationSummaryService) Start() {
+	go func() {
+		for id := range s.queue {
+			if _, err := s.Summarize(id); err != nil {
+				log.Printf("⚠️ 生成会话小结失败: conversation_id=%d err=%v", id, err)
+			}
+		}
+	}()
+}
+
+// OnConversationsClosed 会话关闭（手动或自动）后排队生成小结；未开启时忽略
+func (s *ConversationSummaryService) OnConversationsClosed(ids ...uint) {
+	if s == nil || len(ids) == 0 || !s.GetSettings().Enabled {
+		return
+	}
+	for _, id := range ids {
+		_ = s.conversations.UpdateSummaryStatus(id, SummaryStatusPending)
+		select {
+		case s.queue <- id:
+		default:
+			_ = s.conversations.UpdateSummaryStatus(id, SummaryStatusFailed)
+			log.Printf("⚠️ 会话小结队列已满，跳过: conversation_id=%d", id)
+		}
+	}
+}
+
+// GetSettings 读取会话小结配置（未设置时默认开启）
+func (s *ConversationSummaryService) GetSettings() ConversationSummarySettings {
+	out := ConversationSummarySettings{Enabled: true}
+	if s.appSettings == nil {
+		return out
+	}
+	if row, err := s.appSettings.Get(models.AppSettingKeyConversationSummaryEnabled); err == nil && row != nil {
+		out.Enabled = row.Value != "false"
+	}
+	if row, err := s.appSettings.Get(models.AppSettingKeyConversationSummaryAIConfigID); err == nil && row != nil {
+		if id, err := strconv.ParseUint(strings.TrimSpace(row.Value), 10, 64); err == nil {
+			out.AIConfigID = uint(id)
+		}
+	}
+	return out
+}
+
+// UpdateSettings 保存会话小结配置；指定的 AI 配置须为已启用的文本模型
+func (s *ConversationSummaryService) UpdateSettings(settings ConversationSummarySettings) (ConversationSummarySettings, error) {
+	if s.appSettings == nil {
+		return settings, errors.New("配置存储不可用")
+	}
+	if settings.AIConfigID > 0 {
+		config, err := s.aiConfigs.GetByID(settings.AIConfigID)
+		if err != nil {
+			return settings, errors.New("AI 配置不存在")
+		}
+		if !config.IsActive || config.ModelType != "text" {
+			return settings, errors.New("会话小结只能使用已启用的文本模型")
+		}
+	}
+	if err := s.appSettings.SetValue(models.AppSettingKeyConversationSummaryEnabled, strconv.FormatBool(settings.Enabled)); err != nil {
+		return settings, err
+	}
+	if err := s.appSettings.SetValue(models.AppSettingKeyConversationSummaryAIConfigID, strconv.FormatUint(uint64(settings.AIConfigID), 10)); err != nil {
+		return settings, err
+	}
+	return s.GetSettings(), nil
+}
+
+// Summarize 为会话生成小结并写回会话（后台任务与手动重新生成共用）
+func (s *ConversationSummaryService) Summarize(conversationID uint) (*models.Conversation, error) {
+	conv, err := s.conversations.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if conv.ConversationType != "visitor" {
+		return nil, errors.New("仅访客会话支持生成小结")
+	}
+
+	all, err := s.messages.ListByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]models.Message, 0, len(all))
+	hasVisitorMessage := false
+	for _, msg := range all {
+		// whisper 为客服内部备注，不纳入小结
+		if msg.MessageType == models.MessageTypeWhisper {
+			continue
+		}
+		if !msg.SenderIsAgent && msg.MessageType != "system_message" {
+			hasVisitorMessage = true
+		}
+		messages = append(messages, msg)
+	}
+	if !hasVisitorMessage {
+		conv.SummaryStatus = SummaryStatusSkipped
+		if err := s.conversations.UpdateSummaryStatus(conv.ID, SummaryStatusSkipped); err != nil {
+			return nil, err
+		}
+		return conv, nil
+	}
+
+	var preferred []uint
+	if id := s.GetSettings().AIConfigID; id > 0 {
+		preferred = append(preferred, id)
+	}
+	if conv.AIConfigID != nil {
+		preferred = append(preferred, *conv.AIConfigID)
+	}
+	config, err := resolveTextAIConfig(s.aiConfigs, preferred, conv.AgentID)
+	if err != nil {
+		s.markFailed(conv, err)
+		return nil, err
+	}
+	categories, err := s.categories.List()
+	if err != nil {
+		return nil, err
+	}
+
+	startedAt := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+	draft, usage, err := s.aiService.GenerateConversationSummary(ctx, conv, config, messages, categories)
+	if err != nil {
+		s.markFailed(conv, err)
+		return nil, err
+	}
+
+	now := time.Now()
+	conv.Summary = draft.Summary
+	conv.Category = draft.Category
+	conv.Resolution = draft.Resolution
+	conv.KeyEntities = draft.KeyEntities
+	conv.SummaryStatus = SummaryStatusDone
+	conv.SummarizedAt = &now
+	if err := s.conversations.UpdateSummary(conv); err != nil {
+		return nil, err
+	}
+
+	meta := map[string]interface{}{
+		"category":   conv.Category,
+		"resolution": conv.Resolution,
+		"ai_config":  config.ID,
+		"elapsed_ms": time.Since(startedAt).Milliseconds(),
+	}
+	if usage != nil {
+		meta["cost"] = usage.Cost
+	}
+	s.writeLog("info", "conversation_summary_generated", conv, "已生成会话小结", meta)
+	return conv, nil
+}
+
+func (s *ConversationSummaryService) markFailed(conv *models.Conversation, cause error) {
+	conv.SummaryStatus = SummaryStatusFailed
+	_ = s.conversations.UpdateSummaryStatus(conv.ID, SummaryStatusFailed)
+	s.writeLog("warn", "conversation_summary_failed", conv, "生成会话小结失败", map[string]interface{}{
+		"error": cause.Error(),
+	})
+}
+
+func (s *ConversationSummaryService) writeLog(level string, event string, conv *models.Conversation, message string, meta map[string]interface{}) {
+	if s.systemLogSvc == nil {
+		return
+	}
+	convID := conv.ID
+	_ = s.systemLogSvc.Create(CreateSystemLogInput{
+		Level:          level,
+		Category:       "ai",
+		Event:          event,
+		Source:         "backend",
+		ConversationID: &convID,
+		Message:        message,
+		Meta:           meta,
+	})
+}
+
+// ListCategories 列出全部会话分类
+func (s *ConversationSummaryService) ListCategories() ([]models.ConversationCategory, error) {
+	return s.categories.List()
+}
+
+// CreateCategory 新建会话分类
+func (s *ConversationSummaryService) CreateCategory(input ConversationCategoryInput) (*models.ConversationCategory, error) {
+	if input.Name == nil {
+		return nil, errors.New("分类名称不能为空")
+	}
+	name, err := normalizeCategoryName(*input.Name)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := s.categories.GetByName(name); err == nil && existing != nil {
+		return nil, errors.New("分类已存在")
+	}
+	category := &models.ConversationCategory{Name: name}
+	if input.Description != nil {
+		category.Description = truncateRunes(strings.TrimSpace(*input.Description), 500)
+	}
+	if input.SortOrder != nil {
+		category.SortOrder = *input.SortOrder
+	}
+	if err := s.categories.Create(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// UpdateCategory 修改会话分类（改名不影响已归类会话上保存的旧名称）
+func (s *ConversationSummaryService) UpdateCategory(id uint, input ConversationCategoryInput) (*models.ConversationCategory, error) {
+	category, err := s.getCategory(id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		name, err := normalizeCategoryName(*input.Name)
+		if err != nil {
+			return nil, err
+		}
+		if existing, err := s.categories.GetByName(name); err == nil && existing != nil && existing.ID != id {
+			return nil, errors.New("分类已存在")
+		}
+		category.Name = name
+	}
+	if input.Description != nil {
+		category.Description = truncateRunes(strings.TrimSpace(*input.Description), 500)
+	}
+	if input.SortOrder != nil {
+		category.SortOrder = *input.SortOrder
+	}
+	if err := s.categories.Update(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategory 删除会话分类
+func (s *ConversationSummaryService) DeleteCategory(id uint) error {
+	if _, err := s.getCategory(id); err != nil {
+		return err
+	}
+	return s.categories.Delete(id)
+}
+
+func (s *ConversationSummaryService) getCategory(id uint) (*models.ConversationCategory, error) {
+	category, err := s.categories.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationCategoryNotFound
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+func normalizeCategoryName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("分类名称不能为空")
+	}
+	if len([]rune(name)) > categoryNameMaxLength {
+		return "", errors.New("分类名称过长")
+	}
+	return name, nil
+}
+
+// withConversationSummaryFields 将会话上的小结字段填入列表 / 详情摘要
+func withConversationSummaryFields(summary *ConversationSummary, conv models.Conversation) {
+	summary.Summary = conv.Summary
+	summary.Category = conv.Category
+	summary.Resolution = conv.Resolution
+	summary.KeyEntities = conv.KeyEntities
+	summary.SummaryStatus = conv.SummaryStatus
+	summary.SummarizedAt = conv.SummarizedAt
+}
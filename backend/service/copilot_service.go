@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"gorm.io/gorm"
+)
+
+// copilotTimeout 单次起草的超时时间
+const copilotTimeout = 60 * time.Second
+
+// ErrCopilotSuggestionNotFound 草稿不存在
+var ErrCopilotSuggestionNotFound = errors.New("草稿不存在")
+
+// CopilotSettings 客服助手配置（保存在 app_settings）
+type CopilotSettings struct {
+	Enabled    bool `json:"enabled"`      // 人工会话收到访客消息时自动起草
+	AIConfigID uint `json:"ai_config_id"` // 起草使用的 AI 配置，0 表示使用负责客服的默认文本模型
+}
+
+// CopilotFeedbackInput 客服对草稿的处理结果
+type CopilotFeedbackInput struct {
+	Action        string // accepted / edited / dismissed
+	FinalContent  string // 实际发送的文本（accepted / edited 时）
+	SentMessageID uint   // 实际发送的消息 ID（可选）
+}
+
+// CopilotService 人工会话的客服助手：访客消息到达时起草 1~3 条候选回复，仅推送给客服，并记录采纳 / 编辑情况。
+type CopilotService struct {
+	suggestions   *repository.CopilotSuggestionRepository
+	conversations *repository.ConversationRepository
+	messages      *repository.MessageRepository
+	aiConfigs     *repository.AIConfigRepository
+	appSettings   *repository.AppSettingRepository
+	aiService     *AIService
+	hub           BroadcastHub
+	systemLogSvc  *SystemLogService
+
+	mu       sync.Mutex
+	inflight map[uint]bool // 正在起草的会话；起草期间再有访客消息时标记为 true，完成后重新起草一次
+}
+
+// NewCopilotService 创建客服助手服务实例。
+func NewCopilotService(
+	suggestions *repository.CopilotSuggestionRepository,
+	conversations *repository.ConversationRepository,
+	messages *repository.MessageRepository,
+	aiConfigs *repository.AIConfigRepository,
+	appSettings *repository.AppSettingRepository,
+	aiService *AIService,
+	hub BroadcastHub,
+	systemLogSvc *SystemLogService,
+) *CopilotService {
+	return &CopilotService{
+		suggestions:   suggestions,
+		conversations: conversations,
+		messages:      messages,
+		aiConfigs:     aiConfigs,
+		appSettings:   appSettings,
+		aiService:     aiService,
+		hub:           hub,
+		systemLogSvc:  systemLogSvc,
+		inflight:      make(map[uint]bool),
+	}
+}
+
+// GetSettings 读取客服助手配置
+func (s *CopilotService) GetSettings() CopilotSettings {
+	var out CopilotSettings
+	if s.appSettings == nil {
+		return out
+	}
+	if row, err := s.appSettings.Get(models.AppSettingKeyCopilotEnabled); err == nil && row != nil {
+		out.Enabled = row.Value == "true"
+	}
+	if row, err := s.appSettings.Get(models.AppSettingKeyCopilotAIConfigID); err == nil && row != nil {
+		if id, err := strconv.ParseUint(strings.TrimSpace(row.Value), 10, 64); err == nil {
+			out.AIConfigID = uint(id)
+		}
+	}
+	return out
+}
+
+// UpdateSettings 保存客服助手配置；指定的 AI 配置须为已启用的文本模型
+func (s *CopilotService) UpdateSettings(settings CopilotSettings) (CopilotSettings, error) {
+	if s.appSettings == nil {
+		return settings, errors.New("配置存储不可用")
+	}
+	if settings.AIConfigID > 0 {
+		config, err := s.aiConfigs.GetByID(settings.AIConfigID)
+		if err != nil {
+			return settings, errors.New("AI 配置不存在")
+		}
+		if !config.IsActive || config.ModelType != "text" {
+			return settings, errors.New("客服助手只能使用已启用的文本模型")
+		}
+	}
+	if err := s.appSettings.SetValue(models.AppSettingKeyCopilotEnabled, strconv.FormatBool(settings.Enabled)); err != nil {
+		return settings, err
+	}
+	if err := s.appSettings.SetValue(models.AppSettingKeyCopilotAIConfigID, strconv.FormatUint(uint64(settings.AIConfigID), 10)); err != nil {
+		return settings, err
+	}
+	return s.GetSettings(), nil
+}
+
+func (s *CopilotService) getVisitorConversation(conversationID uint) (*models.Conversation, error) {
+	conv, err := s.conversations.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if conv.ConversationType != "visitor" {
+		return nil, errors.New("仅访客会话支持客服助手")
+	}
+	return conv, nil
+}
+
+// OnVisitorMessage 人工会话收到访客消息后异步起草（未开启自动起草或非人工访客会话时忽略）
+func (s *CopilotService) OnVisitorMessage(conv *models.Conversation, message *models.Message) {
+	if s == nil || conv == nil || message == nil || message.SenderIsAgent {
+		return
+	}
+	if conv.ConversationType != "visitor" || conv.ChatMode != "human" || strings.TrimSpace(message.Content) == "" {
+		return
+	}
+	if !s.GetSettings().Enabled {
+		return
+	}
+	conversationID := conv.ID
+	s.mu.Lock()
+	if _, running := s.inflight[conversationID]; running {
+		s.inflight[conversationID] = true
+		s.mu.Unlock()
+		return
+	}
+	s.inflight[conversationID] = false
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			if _, err := s.Suggest(conversationID, 0); err != nil {
+				log.Printf("⚠️ 客服助手起草失败: conversation_id=%d err=%v", conversationID, err)
+			}
+			s.mu.Lock()
+			rerun := s.inflight[conversationID]
+			if !rerun {
+				delete(s.inflight, conversationID)
+				s.mu.Unlock()
+				return
+			}
+			s.inflight[conversationID] = false
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// Suggest 针对会话中最新一条访客消息起草候选回复，替换此前未处理的草稿并推送给会话内客服。
+// agentID 为手动触发的客服（自动起草时为 0，使用会话负责客服）。
+func (s *CopilotService) Suggest(conversationID uint, agentID uint) ([]models.CopilotSuggestion, error) {
+	conv, err := s.getVisitorConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	visitorMessage, err := s.latestVisitorMessage(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if agentID == 0 {
+		agentID = conv.AgentID
+	}
+	config, err := resolveTextAIConfig(s.aiConfigs, []uint{s.GetSettings().AIConfigID}, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	startedAt := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), copilotTimeout)
+	defer cancel()
+	result, err := s.aiService.GenerateCopilotDrafts(ctx, conv, config, visitorMessage.Content)
+	if err != nil {
+		s.writeLog("warn", "copilot_failed", conversationID, agentID, "客服助手起草失败", map[string]interface{}{
+			"error":     err.Error(),
+			"ai_config": config.ID,
+		})
+		return nil, err
+	}
+
+	configID := result.AIConfigID
+	suggestions := make([]models.CopilotSuggestion, 0, len(result.Drafts))
+	for i, d := range result.Drafts {
+		suggestions = append(suggestions, models.CopilotSuggestion{
+			ConversationID: conversationID,
+			MessageID:      visitorMessage.ID,
+			Rank:           i + 1,
+			Content:        d.Content,
+			Citations:      d.Citations,
+			Status:         models.CopilotStatusPending,
+			AIConfigID:     &configID,
+			AIModel:        result.AIModel,
+		})
+	}
+	if err := s.suggestions.ReplacePending(conversationID, suggestions); err != nil {
+		return nil, err
+	}
+
+	meta := map[string]interface{}{
+		"message_id": visitorMessage.ID,
+		"drafts":     len(suggestions),
+		"ai_config":  result.AIConfigID,
+		"model":      result.AIModel,
+		"elapsed_ms": time.Since(startedAt).Milliseconds(),
+	}
+	if result.Usage != nil {
+		meta["cost"] = result.Usage.Cost
+	}
+	s.writeLog("info", "copilot_drafts_generated", conversationID, agentID, "客服助手已起草候选回复", meta)
+
+	// 仅推送给会话房间内的客服连接，访客不可见
+	if s.hub != nil {
+		s.hub.BroadcastToConversationAgents(conversationID, "copilot_suggestions", map[string]interface{}{
+			"conversation_id": conversationID,
+			"message_id":      visitorMessage.ID,
+			"suggestions":     suggestions,
+		})
+	}
+	return suggestions, nil
+}
+
+// latestVisitorMessage 返回会话中最新一条访客文本消息
+func (s *CopilotService) latestVisitorMessage(conversationID uint) (*models.Message, error) {
+	messages, err := s.messages.ListByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if !msg.SenderIsAgent && msg.MessageType != "system_message" && strings.TrimSpace(msg.Content) != "" {
+			return &msg, nil
+		}
+	}
+	return nil, errors.New("会话中暂无访客消息")
+}
+
+// ListPending 返回会话中待处理的草稿
+func (s *CopilotService) ListPending(conversationID uint) ([]models.CopilotSuggestion, error) {
+	if _, err := s.getVisitorConversation(conversationID); err != nil {
+		return nil, err
+	}
+	return s.suggestions.ListPendingByConversation(conversationID)
+}
+
+// Feedback 记录客服对草稿的处理：原样发送、修改后发送或忽略。
+// 提交 accepted 但最终文本与草稿不同时按 edited 记录；采纳后同批其余草稿标记为已替代。
+func (s *CopilotService) Feedback(id uint, agentID uint, input CopilotFeedbackInput) (*models.CopilotSuggestion, error) {
+	suggestion, err := s.suggestions.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCopilotSuggestionNotFound
+		}
+		return nil, err
+	}
+	if suggestion.Status != models.CopilotStatusPending {
+		return nil, errors.New("该草稿已处理")
+	}
+
+	now := time.Now()
+	suggestion.AgentID = &agentID
+	suggestion.ActedAt = &now
+	switch input.Action {
+	case models.CopilotStatusAccepted, models.CopilotStatusEdited:
+		final := strings.TrimSpace(input.FinalContent)
+		if final == "" {
+			final = suggestion.Content
+		}
+		suggestion.FinalContent = final
+		suggestion.Similarity = textSimilarity(suggestion.Content, final)
+		suggestion.Status = models.CopilotStatusEdited
+		if suggestion.Similarity >= 1 {
+			suggestion.Status = models.CopilotStatusAccepted
+		}
+		if input.SentMessageID > 0 {
+			sent := input.SentMessageID
+			suggestion.SentMessageID = &sent
+		}
+	case models.CopilotStatusDismissed:
+		suggestion.Status = models.CopilotStatusDismissed
+	default:
+		return nil, errors.New("action 只能是 accepted / edited / dismissed")
+	}
+	if err := s.suggestions.Update(suggestion); err != nil {
+		return nil, err
+	}
+	if suggestion.Status != models.CopilotStatusDismissed {
+		if err := s.suggestions.SupersedeOthers(suggestion.ConversationID, suggestion.MessageID, suggestion.ID); err != nil {
+			log.Printf("⚠️ 更新同批草稿状态失败: suggestion_id=%d err=%v", suggestion.ID, err)
+		}
+	}
+	s.writeLog("info", "copilot_feedback", suggestion.ConversationID, agentID, "客服处理了助手草稿", map[string]interface{}{
+		"suggestion_id": suggestion.ID,
+		"status":        suggestion.Status,
+		"similarity":    suggestion.Similarity,
+	})
+	if s.hub != nil {
+		s.hub.BroadcastToConversationAgents(suggestion.ConversationID, "copilot_suggestion_updated", suggestion)
+	}
+	return suggestion, nil
+}
+
+func (s *CopilotService) writeLog(level string, event string, conversationID uint, userID uint, message string, meta map[string]interface{}) {
+	if s.systemLogSvc == nil {
+		return
+	}
+	convID := conversationID
+	var uID *uint
+	if userID > 0 {
+		uID = &userID
+	}
+	_ = s.systemLogSvc.Create(CreateSystemLogInput{
+		Level:          level,
+		Category:       "ai",
+		Event:          event,
+		Source:         "backend",
+		ConversationID: &convID,
+		UserID:         uID,
+		Message:        message,
+		Meta:           meta,
+	})
+}
+
+// textSimilarity 基于编辑距离的文本相似度（0~1），用于衡量客服对草稿的修改幅度
+func textSimilarity(a, b string) float64 {
+	ra := []rune(strings.TrimSpace(a))
+	rb := []rune(strings.TrimSpace(b))
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	const maxRunes = 2000
+	if len(ra) > maxRunes {
+		ra = ra[:maxRunes]
+	}
+	if len(rb) > maxRunes {
+		rb = rb[:maxRunes]
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	longest := max(len(ra), len(rb))
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
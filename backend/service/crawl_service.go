@@ -0,0 +1,498 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	import_service "github.com/2930134478/AI-CS/backend/service/import"
+	"gorm.io/gorm"
+)
+
+// 抓取来源状态
+const (
+	CrawlStatusIdle    = "idle"
+	CrawlStatusRunning = "running"
+	CrawlStatusFailed  = "failed"
+)
+
+const (
+	// 抓取参数上限
+	maxCrawlDepth         = 5
+	maxCrawlPagesPerRun   = 1000
+	minCrawlDelayMs       = 200
+	defaultCrawlDelayMs   = 1000
+	maxCrawlIntervalHours = 24 * 30
+	// 单次抓取的超时时间
+	crawlRunTimeout = 2 * time.Hour
+	// 定时任务检查到期来源的间隔
+	crawlSchedulerInterval = time.Minute
+)
+
+// ErrCrawlSourceNotFound 抓取来源不存在
+var ErrCrawlSourceNotFound = errors.New("抓取来源不存在")
+
+// ErrCrawlRunning 抓取来源正在抓取中
+var ErrCrawlRunning = errors.New("该来源正在抓取中，请稍后再试")
+
+// CrawlSourceInput 创建 / 更新抓取来源的参数（nil 表示不修改，创建时使用默认值）
+type CrawlSourceInput struct {
+	KnowledgeBaseID uint
+	SeedURL         *string
+	MaxDepth        *int
+	MaxPages        *int
+	DelayMs         *int
+	IntervalHours   *int
+	ChunkMethod     *string
+}
+
+// CrawlService 网站抓取：按来源配置抓取同站页面导入知识库，按规范化 URL 去重、按内容哈希只更新变化的页面，
+// 设置了重新抓取周期的来源由定时任务自动抓取
+type CrawlService struct {
+	sources      *repository.CrawlSourceRepository
+	docRepo      *repository.DocumentRepository
+	kbRepo       *repository.KnowledgeBaseRepository
+	importSvc    *ImportService
+	chunkSvc     *ChunkService
+	systemLogSvc *SystemLogService
+
+	mu      sync.Mutex
+	running map[uint]bool
+}
+
+// NewCrawlService 创建网站抓取服务
+func NewCrawlService(
+	sources *repository.CrawlSourceRepository,
+	docRepo *repository.DocumentRepository,
+	kbRepo *repository.KnowledgeBaseRepository,
+	importSvc *ImportService,
+	chunkSvc *ChunkService,
+	systemLogSvc *SystemLogService,
+) *CrawlService {
+	return &CrawlService{
+		sources:      sources,
+		docRepo:      docRepo,
+		kbRepo:       kbRepo,
+		importSvc:    importSvc,
+		chunkSvc:     chunkSvc,
+		systemLogSvc: systemLogSvc,
+		running:      make(map[uint]bool),
+	}
+}
+
+// ListSources 列出抓取来源；knowledgeBaseID 为 0 时返回全部
+func (s *CrawlService) ListSources(knowledgeBaseID uint) ([]models.CrawlSource, error) {
+	return s.sources.List(knowledgeBaseID)
+}
+
+// GetSource 获取抓取来源
+func (s *CrawlService) GetSource(id uint) (*models.CrawlSource, error) {
+	source, err := s.sources.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCrawlSourceNotFound
+		}
+		return nil, err
+	}
+	return source, nil
+}
+
+// CreateSource 创建抓取来源
+func (s *CrawlService) CreateSource(input CrawlSourceInput) (*models.CrawlSource, error) {
+	if _, err := s.kbRepo.GetByID(input.KnowledgeBaseID); err != nil {
+		return nil, errors.New("知识库不存在")
+	}
+	if input.SeedURL == nil {
+		return nil, errors.New("起始地址不能为空")
+	}
+	source := &models.CrawlSource{
+		KnowledgeBaseID: input.KnowledgeBaseID,
+		MaxDepth:        import_service.DefaultCrawlMaxDepth,
+		MaxPages:        import_service.DefaultCrawlMaxPages,
+		DelayMs:         defaultCrawlDelayMs,
+		Status:          CrawlStatusIdle,
+	}
+	if err := applyCrawlSourceInput(source, input); err != nil {
+		return nil, err
+	}
+	source.NextCrawlAt = nextCrawlAt(time.Now(), source.IntervalHours)
+	if err := s.sources.Create(source); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// UpdateSource 更新抓取来源（修改周期后从当前时间重新计算下次抓取时间）；抓取中不可修改
+func (s *CrawlService) UpdateSource(id uint, input CrawlSourceInput) (*models.CrawlSource, error) {
+	source, err := s.GetSource(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.isRunning(id) {
+		return nil, ErrCrawlRunning
+	}
+	if err := applyCrawlSourceInput(source, input); err != nil {
+		return nil, err
+	}
+	if input.IntervalHours != nil {
+		source.NextCrawlAt = nextCrawlAt(time.Now(), source.IntervalHours)
+	}
+	if err := s.sources.Update(source); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// DeleteSource 删除抓取来源（已导入的文档保留在知识库中）
+func (s *CrawlService) DeleteSource(id uint) error {
+	if _, err := s.GetSource(id); err != nil {
+		return err
+	}
+	if s.isRunning(id) {
+		return ErrCrawlRunning
+	}
+	return s.sources.Delete(id)
+}
+
+// RunSource 立即在后台抓取来源
+func (s *CrawlService) RunSource(id uint) (*models.CrawlSource, error) {
+	source, err := s.GetSource(id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.markRunning(id) {
+		return nil, ErrCrawlRunning
+	}
+	source.Status = CrawlStatusRunning
+	if err := s.sources.Update(source); err != nil {
+		s.unmarkRunning(id)
+		return nil, err
+	}
+	go s.run(source)
+	return source, nil
+}
+
+// StartScheduler 启动定时重新抓取：每分钟检查到期的来源并依次抓取（启动时先恢复中断的 running 状态）
+func (s *CrawlService) StartScheduler(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	if err := s.sources.ResetRunning(); err != nil {
+		log.Printf("[网站抓取] 恢复中断的抓取状态失败: %v", err)
+	}
+	ticker := time.NewTicker(crawlSchedulerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runDueSources()
+		}
+	}
+}
+
+func (s *CrawlService) runDueSources() {
+	due, err := s.sources.ListDue(time.Now(), 10)
+	if err != nil {
+		log.Printf("[网站抓取] 查询到期来源失败: %v", err)
+		return
+	}
+	for i := range due {
+		source := &due[i]
+		if !s.markRunning(source.ID) {
+			continue
+		}
+		source.Status = CrawlStatusRunning
+		if err := s.sources.Update(source); err != nil {
+			s.unmarkRunning(source.ID)
+			continue
+		}
+		s.run(source)
+	}
+}
+
+// run 执行一次抓取并更新来源状态与统计
+func (s *CrawlService) run(source *models.CrawlSource) {
+	defer s.unmarkRunning(source.ID)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[网站抓取] panic source=%d: %v", source.ID, r)
+			s.finish(source, fmt.Errorf("抓取异常: %v", r))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), crawlRunTimeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	log.Printf("[网站抓取] 开始 source=%d seed=%s", source.ID, source.SeedURL)
+	crawler := import_service.NewCrawler(import_service.CrawlOptions{
+		MaxDepth: source.MaxDepth,
+		MaxPages: source.MaxPages,
+		Delay:    time.Duration(source.DelayMs) * time.Millisecond,
+	})
+	result, err := crawler.Crawl(ctx, source.SeedURL)
+	if result == nil {
+		s.finish(source, err)
+		s.writeLog("error", "crawl_failed", "网站抓取失败: "+source.LastError, map[string]interface{}{
+			"source_id": source.ID,
+			"seed_url":  source.SeedURL,
+		})
+		return
+	}
+
+	source.PagesFound = len(result.Pages)
+	source.PagesFailed = len(result.Failures)
+	source.PagesDisallowed = result.Disallowed
+	source.PagesCreated, source.PagesUpdated, source.PagesUnchanged = 0, 0, 0
+	if importErr := s.importPages(ctx, source, result.Pages); importErr != nil && err == nil {
+		err = importErr
+	}
+	s.finish(source, err)
+
+	meta := map[string]interface{}{
+		"source_id":  source.ID,
+		"seed_url":   source.SeedURL,
+		"found":      source.PagesFound,
+		"created":    source.PagesCreated,
+		"updated":    source.PagesUpdated,
+		"unchanged":  source.PagesUnchanged,
+		"failed":     source.PagesFailed,
+		"disallowed": source.PagesDisallowed,
+		"elapsed_ms": time.Since(startedAt).Milliseconds(),
+	}
+	if len(result.Failures) > 0 {
+		meta["first_failure"] = result.Failures[0].URL + ": " + result.Failures[0].Error
+	}
+	level, message := "info", "网站抓取完成"
+	if err != nil {
+		level, message = "warn", "网站抓取未完成: "+err.Error()
+	}
+	s.writeLog(level, "crawl_finished", message, meta)
+}
+
+// importPages 将抓取结果写入知识库：新页面创建文档，内容变化的页面更新文档，未变化的只记录抓取时间；
+// 写入的文档按来源配置分段或整篇重新向量化
+func (s *CrawlService) importPages(ctx context.Context, source *models.CrawlSource, pages []import_service.CrawledPage) error {
+	existing, err := s.sources.ListPages(source.ID)
+	if err != nil {
+		return fmt.Errorf("读取已抓取页面失败: %w", err)
+	}
+	records := make(map[string]*models.CrawlPage, len(existing))
+	for i := range existing {
+		records[existing[i].URLHash] = &existing[i]
+	}
+
+	now := time.Now()
+	var created, updated []uint
+	for _, page := range pages {
+		parsed := page.Document
+		urlHash := sha256Hex(page.URL)
+		contentHash := sha256Hex(parsed.Title + "\n" + parsed.Content)
+		record := records[urlHash]
+		if record == nil {
+			record = &models.CrawlPage{SourceID: source.ID, URLHash: urlHash, URL: page.URL}
+			records[urlHash] = record
+		}
+		record.CrawledAt = now
+
+		var doc *models.Document
+		if record.DocumentID > 0 {
+			// 文档被手动删除时重新创建
+			doc, _ = s.docRepo.GetByID(record.DocumentID)
+		}
+		switch {
+		case doc != nil && record.ContentHash == contentHash:
+			source.PagesUnchanged++
+		case doc != nil:
+			doc.Title = parsed.Title
+			doc.Content = parsed.Content
+			doc.SourceName = page.URL
+			doc.SourceMap = parsed.Sections
+			doc.EmbeddingStatus = "pending"
+			if err := s.docRepo.Update(doc); err != nil {
+				log.Printf("[网站抓取] 更新文档失败 doc=%d url=%s: %v", doc.ID, page.URL, err)
+				source.PagesFailed++
+				continue
+			}
+			updated = append(updated, doc.ID)
+			source.PagesUpdated++
+		default:
+			doc = &models.Document{
+				KnowledgeBaseID: source.KnowledgeBaseID,
+				Title:           parsed.Title,
+				Content:         parsed.Content,
+				Type:            "url",
+				Status:          "draft",
+				EmbeddingStatus: "pending",
+				SourceName:      page.URL,
+				SourceMap:       parsed.Sections,
+			}
+			if err := s.docRepo.Create(doc); err != nil {
+				log.Printf("[网站抓取] 创建文档失败 url=%s: %v", page.URL, err)
+				source.PagesFailed++
+				continue
+			}
+			created = append(created, doc.ID)
+			source.PagesCreated++
+		}
+		record.DocumentID = doc.ID
+		record.ContentHash = contentHash
+		if err := s.sources.SavePage(record); err != nil {
+			log.Printf("[网站抓取] 保存页面记录失败 url=%s: %v", page.URL, err)
+		}
+	}
+
+	s.embedDocuments(ctx, source, created, updated)
+	return nil
+}
+
+// embedDocuments 向量化新建与更新的文档：配置了分段方式时重新分段（会替换旧分段与向量），
+// 否则删除旧分段后整篇向量化
+func (s *CrawlService) embedDocuments(ctx context.Context, source *models.CrawlSource, created, updated []uint) {
+	ids := append(append([]uint(nil), created...), updated...)
+	if len(ids) == 0 {
+		return
+	}
+	if source.ChunkMethod != "" && s.chunkSvc != nil {
+		for _, id := range ids {
+			if _, err := s.chunkSvc.ExecuteChunking(ctx, id, ChunkRequest{Method: source.ChunkMethod}); err != nil {
+				log.Printf("[网站抓取] 文档分段失败 doc=%d: %v", id, err)
+			}
+		}
+		return
+	}
+	if s.chunkSvc != nil {
+		for _, id := range updated {
+			if err := s.chunkSvc.DeleteChunks(ctx, id); err != nil {
+				log.Printf("[网站抓取] 删除旧分段失败 doc=%d: %v", id, err)
+			}
+		}
+	}
+	if _, err := s.importSvc.BatchEmbedDocuments(ctx, ids); err != nil {
+		log.Printf("[网站抓取] 批量向量化失败 source=%d: %v", source.ID, err)
+	}
+}
+
+// finish 记录抓取结束状态并计算下次抓取时间
+func (s *CrawlService) finish(source *models.CrawlSource, err error) {
+	now := time.Now()
+	source.LastCrawledAt = &now
+	source.NextCrawlAt = nextCrawlAt(now, source.IntervalHours)
+	source.Status = CrawlStatusIdle
+	source.LastError = ""
+	if err != nil {
+		source.Status = CrawlStatusFailed
+		source.LastError = err.Error()
+	}
+	if updateErr := s.sources.Update(source); updateErr != nil {
+		log.Printf("[网站抓取] 保存抓取结果失败 source=%d: %v", source.ID, updateErr)
+	}
+	log.Printf("[网站抓取] 结束 source=%d status=%s found=%d created=%d updated=%d unchanged=%d failed=%d",
+		source.ID, source.Status, source.PagesFound, source.PagesCreated, source.PagesUpdated, source.PagesUnchanged, source.PagesFailed)
+}
+
+func (s *CrawlService) markRunning(id uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[id] {
+		return false
+	}
+	s.running[id] = true
+	return true
+}
+
+func (s *CrawlService) unmarkRunning(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, id)
+}
+
+func (s *CrawlService) isRunning(id uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[id]
+}
+
+func (s *CrawlService) writeLog(level, event, message string, meta map[string]interface{}) {
+	if s.systemLogSvc == nil {
+		return
+	}
+	_ = s.systemLogSvc.Create(CreateSystemLogInput{
+		Level:    level,
+		Category: "rag",
+		Event:    event,
+		Source:   "backend",
+		Message:  message,
+		Meta:     meta,
+	})
+}
+
+// applyCrawlSourceInput 校验并写入抓取参数
+func applyCrawlSourceInput(source *models.CrawlSource, input CrawlSourceInput) error {
+	if input.SeedURL != nil {
+		seed := strings.TrimSpace(*input.SeedURL)
+		u, err := url.Parse(seed)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("起始地址必须是 http(s) 网址")
+		}
+		source.SeedURL = seed
+	}
+	if input.MaxDepth != nil {
+		if *input.MaxDepth < 0 || *input.MaxDepth > maxCrawlDepth {
+			return fmt.Errorf("抓取深度须在 0~%d 之间", maxCrawlDepth)
+		}
+		source.MaxDepth = *input.MaxDepth
+	}
+	if input.MaxPages != nil {
+		if *input.MaxPages < 1 || *input.MaxPages > maxCrawlPagesPerRun {
+			return fmt.Errorf("页面数上限须在 1~%d 之间", maxCrawlPagesPerRun)
+		}
+		source.MaxPages = *input.MaxPages
+	}
+	if input.DelayMs != nil {
+		if *input.DelayMs < minCrawlDelayMs {
+			return fmt.Errorf("请求间隔不能小于 %d 毫秒", minCrawlDelayMs)
+		}
+		source.DelayMs = *input.DelayMs
+	}
+	if input.IntervalHours != nil {
+		if *input.IntervalHours < 0 || *input.IntervalHours > maxCrawlIntervalHours {
+			return fmt.Errorf("重新抓取周期须在 0~%d 小时之间", maxCrawlIntervalHours)
+		}
+		source.IntervalHours = *input.IntervalHours
+	}
+	if input.ChunkMethod != nil {
+		method := strings.TrimSpace(*input.ChunkMethod)
+		// 按分隔符分段需要额外参数，不支持自动分段
+		if method != "" && (!IsValidChunkMethod(method) || method == ChunkMethodSeparator) {
+			return errors.New("自动分段方式仅支持 char_count、recursive 或 markdown")
+		}
+		source.ChunkMethod = method
+	}
+	return nil
+}
+
+// nextCrawlAt 计算下次自动抓取时间；周期为 0 时不自动抓取
+func nextCrawlAt(from time.Time, intervalHours int) *time.Time {
+	if intervalHours <= 0 {
+		return nil
+	}
+	next := from.Add(time.Duration(intervalHours) * time.Hour)
+	return &next
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
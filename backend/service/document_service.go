@@ -160,6 +160,9 @@ func (s *DocumentService) UpdateDocument(id uint, input UpdateDocumentInput) (*D
 		doc.Title = *input.Title
 	}
 	if input.Content != nil {
+		if *input.Content != doc.Content {
+			doc.SourceMap = nil // 位置信息按原内容偏移记录，内容修改后失效
+		}
 		doc.Content = *input.Content
 		needReembed = true // 内容变化需要重新向量化
 	}
@@ -218,21 +221,38 @@ func (s *DocumentService) UnpublishDocument(id uint) error {
 	return s.UpdateDocumentStatus(id, "draft")
 }
 
-// SearchDocuments 向量检索文档
-func (s *DocumentService) SearchDocuments(query string, topK int, knowledgeBaseID *uint) ([]DocumentSummary, error) {
-	results, err := s.retrievalService.Retrieve(context.Background(), query, topK, knowledgeBaseID)
+// SearchDocuments 检索文档；mode 为 vector（默认）/ keyword / hybrid，filter 按分段页码与标题路径过滤
+func (s *DocumentService) SearchDocuments(query string, topK int, knowledgeBaseID *uint, mode string, filter DocumentSearchFilter) ([]DocumentSummary, error) {
+	opts := rag.RetrieveOptions{
+		Mode: rag.NormalizeRetrievalMode(mode),
+		Source: rag.SourceFilter{
+			PageFrom:      filter.PageFrom,
+			PageTo:        filter.PageTo,
+			HeadingPrefix: filter.HeadingPrefix,
+		},
+	}
+	if knowledgeBaseID != nil {
+		opts.KnowledgeBaseIDs = []uint{*knowledgeBaseID}
+	}
+	results, err := s.retrievalService.RetrieveWithOptions(context.Background(), query, topK, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// 获取文档 ID
+	// 获取文档 ID（同一文档的多个分段只保留排名最靠前的一次）
 	docIDs := make([]uint, 0, len(results))
+	seen := make(map[uint]struct{}, len(results))
 	for _, result := range results {
 		// 将 document_id 字符串转换为 uint
 		docID, err := strconv.ParseUint(result.DocumentID, 10, 64)
-		if err == nil {
-			docIDs = append(docIDs, uint(docID))
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[uint(docID)]; ok {
+			continue
 		}
+		seen[uint(docID)] = struct{}{}
+		docIDs = append(docIDs, uint(docID))
 	}
 
 	// 查询文档详情
@@ -269,6 +289,7 @@ func (s *DocumentService) toSummary(doc *models.Document) *DocumentSummary {
 		Type:            doc.Type,
 		Status:          doc.Status,
 		EmbeddingStatus: doc.EmbeddingStatus,
+		SourceName:      doc.SourceName,
 		CreatedAt:       doc.CreatedAt,
 		UpdatedAt:       doc.UpdatedAt,
 	}
@@ -18,6 +18,7 @@ type BGEEmbeddingService struct {
 	apiKey string
 	model  string
 	dimension int
+	onUsage   UsageRecorder // 可选，用量回调（HuggingFace 接口不返回 usage，按字符估算）
 }
 
 // NewBGEEmbeddingService 创建 BGE 嵌入服务实例
@@ -37,6 +38,11 @@ func NewBGEEmbeddingService(apiURL, apiKey, model string) *BGEEmbeddingService {
 	}
 }
 
+// SetUsageRecorder 设置用量回调
+func (s *BGEEmbeddingService) SetUsageRecorder(recorder UsageRecorder) {
+	s.onUsage = recorder
+}
+
 // EmbedText 向量化单个文本
 func (s *BGEEmbeddingService) EmbedText(ctx context.Context, text string) ([]float32, error) {
 	vectors, err := s.EmbedTexts(ctx, []string{text})
@@ -135,6 +141,10 @@ func (s *BGEEmbeddingService) EmbedTexts(ctx context.Context, texts []string) ([
 		log.Printf("[嵌入] 数量不一致: 我们发了 %d 条文本，API 返回了 %d 个向量", numIn, numOut)
 	}
 
+	if s.onUsage != nil {
+		s.onUsage(s.model, estimateTextsTokens(texts), true)
+	}
+
 	// 转换为 float32
 	result := make([][]float32, len(response))
 	for i, item := range response {
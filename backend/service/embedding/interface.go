@@ -2,6 +2,8 @@ package embedding
 
 import (
 	"context"
+
+	"github.com/2930134478/AI-CS/backend/utils"
 )
 
 // EmbeddingProvider 按需提供嵌入服务（每次从 DB 配置读取，保存即生效）
@@ -9,6 +11,23 @@ type EmbeddingProvider interface {
 	Get(ctx context.Context) (EmbeddingService, error)
 }
 
+// UsageRecorder 向量化用量回调（tokens 为输入 token 数；estimated 为 true 表示接口未返回 usage，按字符估算）
+type UsageRecorder func(model string, tokens int, estimated bool)
+
+// UsageReporter 支持上报用量的嵌入服务（由 ConfigBackedEmbeddingProvider 注入回调）
+type UsageReporter interface {
+	SetUsageRecorder(recorder UsageRecorder)
+}
+
+// estimateTextsTokens 估算一批文本的 token 数
+func estimateTextsTokens(texts []string) int {
+	total := 0
+	for _, t := range texts {
+		total += utils.EstimateTokens(t)
+	}
+	return total
+}
+
 // EmbeddingService 嵌入服务接口
 type EmbeddingService interface {
 	// EmbedText 向量化单个文本
@@ -18,6 +18,7 @@ type OpenAIEmbeddingService struct {
 	apiKey string
 	model  string
 	dimension int
+	onUsage   UsageRecorder // 可选，用量回调
 }
 
 // NewOpenAIEmbeddingService 创建 OpenAI 嵌入服务实例
@@ -42,6 +43,11 @@ func NewOpenAIEmbeddingService(apiURL, apiKey, model string) *OpenAIEmbeddingSer
 	}
 }
 
+// SetUsageRecorder 设置用量回调
+func (s *OpenAIEmbeddingService) SetUsageRecorder(recorder UsageRecorder) {
+	s.onUsage = recorder
+}
+
 // EmbedText 向量化单个文本
 func (s *OpenAIEmbeddingService) EmbedText(ctx context.Context, text string) ([]float32, error) {
 	vectors, err := s.EmbedTexts(ctx, []string{text})
@@ -122,6 +128,9 @@ func (s *OpenAIEmbeddingService) EmbedTexts(ctx context.Context, texts []string)
 		Data []struct {
 			Embedding []float64 `json:"embedding"`
 		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &response); err != nil {
@@ -144,6 +153,14 @@ func (s *OpenAIEmbeddingService) EmbedTexts(ctx context.Context, texts []string)
 		log.Printf("[嵌入] 数量不一致: 我们发了 %d 条文本，API 返回了 %d 个向量（可能接口对长文本分块，或请求被中间层改写）", numIn, numOut)
 	}
 
+	if s.onUsage != nil {
+		if response.Usage.PromptTokens > 0 {
+			s.onUsage(s.model, response.Usage.PromptTokens, false)
+		} else {
+			s.onUsage(s.model, estimateTextsTokens(texts), true)
+		}
+	}
+
 	// 转换为 float32
 	result := make([][]float32, len(response.Data))
 	for i, item := range response.Data {
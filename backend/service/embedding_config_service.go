@@ -7,6 +7,7 @@ import (
 
 	"github.com/2930134478/AI-CS/backend/models"
 	"github.com/2930134478/AI-CS/backend/repository"
+	"github.com/2930134478/AI-CS/backend/service/rag"
 	"github.com/2930134478/AI-CS/backend/utils"
 )
 
@@ -36,12 +37,22 @@ func (s *EmbeddingConfigService) GetForAPI() (*EmbeddingConfigResult, error) {
 			CustomerCanUseKB:        true,
 			VisitorWebSearchEnabled: false,
 			WebSearchSource:         "custom",
+			RetrievalMode:           "vector",
+			RerankCandidates:        rag.DefaultRerankCandidates,
+			RerankTimeoutMs:         int(rag.DefaultRerankTimeout / time.Millisecond),
+			FAQMatchThreshold:       DefaultFAQMatchThreshold,
+			FAQContextThreshold:     DefaultFAQContextThreshold,
+			QueryRewriteMaxQueries:  DefaultQueryRewriteMaxQueries,
 		}, nil
 	}
 	masked := ""
 	if c.APIKey != "" {
 		masked = "sk-***"
 	}
+	rerankMasked := ""
+	if c.RerankAPIKey != "" {
+		rerankMasked = "sk-***"
+	}
 	return &EmbeddingConfigResult{
 		ID:                       c.ID,
 		EmbeddingType:             c.EmbeddingType,
@@ -51,6 +62,19 @@ func (s *EmbeddingConfigService) GetForAPI() (*EmbeddingConfigResult, error) {
 		CustomerCanUseKB:          c.CustomerCanUseKB,
 		VisitorWebSearchEnabled:   c.VisitorWebSearchEnabled,
 		WebSearchSource:           normalizeWebSearchSource(c.WebSearchSource),
+		RetrievalMode:             string(rag.NormalizeRetrievalMode(c.RetrievalMode)),
+		RerankEnabled:             c.RerankEnabled,
+		RerankAPIURL:              c.RerankAPIURL,
+		RerankAPIKeyMasked:        rerankMasked,
+		RerankModel:               c.RerankModel,
+		RerankCandidates:          c.RerankCandidates,
+		RerankTimeoutMs:           c.RerankTimeoutMs,
+		FAQMatchThreshold:         faqThresholdOrDefault(c.FAQMatchThreshold, DefaultFAQMatchThreshold),
+		FAQContextThreshold:       faqThresholdOrDefault(c.FAQContextThreshold, DefaultFAQContextThreshold),
+		FAQVerifyEnabled:          c.FAQVerifyEnabled,
+		QueryRewriteEnabled:       c.QueryRewriteEnabled,
+		QueryRewriteAIConfigID:    c.QueryRewriteAIConfigID,
+		QueryRewriteMaxQueries:    queryRewriteMaxQueriesOrDefault(c.QueryRewriteMaxQueries),
 		UpdatedAt:                 c.UpdatedAt,
 	}, nil
 }
@@ -111,6 +135,103 @@ func (s *EmbeddingConfigService) GetWebSearchSource() (string, error) {
 	return normalizeWebSearchSource(c.WebSearchSource), nil
 }
 
+// GetRetrievalMode 返回 AI 回复使用的知识库检索模式：vector / keyword / hybrid
+func (s *EmbeddingConfigService) GetRetrievalMode() (rag.RetrievalMode, error) {
+	c, err := s.repo.Get()
+	if err != nil {
+		return rag.RetrievalModeVector, err
+	}
+	if c == nil {
+		return rag.RetrievalModeVector, nil
+	}
+	return rag.NormalizeRetrievalMode(c.RetrievalMode), nil
+}
+
+// GetRerankConfig 返回重排序服务配置（含解密后的 API Key）；未启用或未配置地址时返回 nil, nil
+func (s *EmbeddingConfigService) GetRerankConfig() (*rag.HTTPRerankerConfig, error) {
+	c, err := s.repo.Get()
+	if err != nil || c == nil || !c.RerankEnabled || c.RerankAPIURL == "" {
+		return nil, err
+	}
+	apiKey := ""
+	if c.RerankAPIKey != "" {
+		apiKey, err = utils.DecryptAPIKey(c.RerankAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("解密重排序 API Key 失败: %w", err)
+		}
+	}
+	return &rag.HTTPRerankerConfig{
+		APIURL:     c.RerankAPIURL,
+		APIKey:     apiKey,
+		Model:      c.RerankModel,
+		Timeout:    time.Duration(c.RerankTimeoutMs) * time.Millisecond,
+		Candidates: c.RerankCandidates,
+	}, nil
+}
+
+// FAQ 语义匹配的默认阈值（向量相似度，IP / 余弦）
+const (
+	DefaultFAQMatchThreshold   = 0.85
+	DefaultFAQContextThreshold = 0.5
+)
+
+// FAQMatchConfig FAQ 语义匹配配置
+type FAQMatchConfig struct {
+	MatchThreshold   float64 // 直接返回标准答案的最低相似度
+	ContextThreshold float64 // 作为参考资料交给模型的最低相似度
+	VerifyEnabled    bool    // 直接返回前是否由模型确认 FAQ 能回答该问题
+}
+
+// GetFAQMatchConfig 返回 FAQ 语义匹配配置（未配置时使用默认阈值）
+func (s *EmbeddingConfigService) GetFAQMatchConfig() FAQMatchConfig {
+	out := FAQMatchConfig{MatchThreshold: DefaultFAQMatchThreshold, ContextThreshold: DefaultFAQContextThreshold}
+	c, err := s.repo.Get()
+	if err != nil || c == nil {
+		return out
+	}
+	out.MatchThreshold = faqThresholdOrDefault(c.FAQMatchThreshold, DefaultFAQMatchThreshold)
+	out.ContextThreshold = faqThresholdOrDefault(c.FAQContextThreshold, DefaultFAQContextThreshold)
+	out.VerifyEnabled = c.FAQVerifyEnabled
+	return out
+}
+
+func faqThresholdOrDefault(v, def float64) float64 {
+	if v <= 0 || v > 1 {
+		return def
+	}
+	return v
+}
+
+// DefaultQueryRewriteMaxQueries 查询改写后默认最多检索的查询数（含主查询）
+const DefaultQueryRewriteMaxQueries = 3
+
+// QueryRewriteConfig 检索前查询改写配置
+type QueryRewriteConfig struct {
+	Enabled    bool
+	AIConfigID uint // 0 表示使用会话所选模型
+	MaxQueries int  // 含主查询，1 表示只改写不拆分
+}
+
+// GetQueryRewriteConfig 返回查询改写配置（未配置时关闭）
+func (s *EmbeddingConfigService) GetQueryRewriteConfig() QueryRewriteConfig {
+	out := QueryRewriteConfig{MaxQueries: DefaultQueryRewriteMaxQueries}
+	c, err := s.repo.Get()
+	if err != nil || c == nil {
+		return out
+	}
+	out.Enabled = c.QueryRewriteEnabled
+	out.AIConfigID = c.QueryRewriteAIConfigID
+	out.MaxQueries = queryRewriteMaxQueriesOrDefault(c.QueryRewriteMaxQueries)
+	return out
+}
+
+func queryRewriteMaxQueriesOrDefault(n int) int {
+	if n <= 0 {
+		return DefaultQueryRewriteMaxQueries
+	}
+	return n
+}
+
 // CheckKnowledgeBaseAccess 校验当前用户是否允许使用知识库（创建/上传/导入等）
 // 若未开放且用户非 admin 则返回 error
 func (s *EmbeddingConfigService) CheckKnowledgeBaseAccess(userID uint) error {
@@ -174,6 +295,66 @@ func (s *EmbeddingConfigService) Update(userID uint, input UpdateEmbeddingConfig
 	if input.WebSearchSource != nil {
 		c.WebSearchSource = normalizeWebSearchSource(*input.WebSearchSource)
 	}
+	if input.RetrievalMode != nil {
+		c.RetrievalMode = string(rag.NormalizeRetrievalMode(*input.RetrievalMode))
+	}
+	if input.RerankEnabled != nil {
+		c.RerankEnabled = *input.RerankEnabled
+	}
+	if input.RerankAPIURL != nil {
+		c.RerankAPIURL = *input.RerankAPIURL
+	}
+	if input.RerankAPIKey != nil && *input.RerankAPIKey != "" {
+		encrypted, err := utils.EncryptAPIKey(*input.RerankAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("加密重排序 API Key 失败: %v", err)
+		}
+		c.RerankAPIKey = encrypted
+	}
+	if input.RerankModel != nil {
+		c.RerankModel = *input.RerankModel
+	}
+	if input.RerankCandidates != nil {
+		if *input.RerankCandidates < 1 || *input.RerankCandidates > 200 {
+			return nil, errors.New("重排序候选数需在 1~200 之间")
+		}
+		c.RerankCandidates = *input.RerankCandidates
+	}
+	if input.RerankTimeoutMs != nil {
+		if *input.RerankTimeoutMs < 100 || *input.RerankTimeoutMs > 60000 {
+			return nil, errors.New("重排序超时需在 100~60000 毫秒之间")
+		}
+		c.RerankTimeoutMs = *input.RerankTimeoutMs
+	}
+	c.FAQMatchThreshold = faqThresholdOrDefault(c.FAQMatchThreshold, DefaultFAQMatchThreshold)
+	c.FAQContextThreshold = faqThresholdOrDefault(c.FAQContextThreshold, DefaultFAQContextThreshold)
+	if input.FAQMatchThreshold != nil {
+		c.FAQMatchThreshold = *input.FAQMatchThreshold
+	}
+	if input.FAQContextThreshold != nil {
+		c.FAQContextThreshold = *input.FAQContextThreshold
+	}
+	if c.FAQMatchThreshold <= 0 || c.FAQMatchThreshold > 1 || c.FAQContextThreshold <= 0 || c.FAQContextThreshold > 1 {
+		return nil, errors.New("FAQ 匹配阈值需在 0~1 之间")
+	}
+	if c.FAQContextThreshold > c.FAQMatchThreshold {
+		return nil, errors.New("FAQ 参考阈值不能高于直接回答阈值")
+	}
+	if input.FAQVerifyEnabled != nil {
+		c.FAQVerifyEnabled = *input.FAQVerifyEnabled
+	}
+	if input.QueryRewriteEnabled != nil {
+		c.QueryRewriteEnabled = *input.QueryRewriteEnabled
+	}
+	if input.QueryRewriteAIConfigID != nil {
+		c.QueryRewriteAIConfigID = *input.QueryRewriteAIConfigID
+	}
+	if input.QueryRewriteMaxQueries != nil {
+		if *input.QueryRewriteMaxQueries < 1 || *input.QueryRewriteMaxQueries > 5 {
+			return nil, errors.New("查询改写的最大查询数需在 1~5 之间")
+		}
+		c.QueryRewriteMaxQueries = *input.QueryRewriteMaxQueries
+	}
 
 	if err := s.repo.Save(c); err != nil {
 		return nil, err
@@ -191,6 +372,19 @@ type EmbeddingConfigResult struct {
 	CustomerCanUseKB        bool      `json:"customer_can_use_kb"`
 	VisitorWebSearchEnabled bool      `json:"visitor_web_search_enabled"`
 	WebSearchSource         string    `json:"web_search_source"`
+	RetrievalMode           string    `json:"retrieval_mode"`
+	RerankEnabled           bool      `json:"rerank_enabled"`
+	RerankAPIURL            string    `json:"rerank_api_url"`
+	RerankAPIKeyMasked      string    `json:"rerank_api_key_masked"`
+	RerankModel             string    `json:"rerank_model"`
+	RerankCandidates        int       `json:"rerank_candidates"`
+	RerankTimeoutMs         int       `json:"rerank_timeout_ms"`
+	FAQMatchThreshold       float64   `json:"faq_match_threshold"`
+	FAQContextThreshold     float64   `json:"faq_context_threshold"`
+	FAQVerifyEnabled        bool      `json:"faq_verify_enabled"`
+	QueryRewriteEnabled     bool      `json:"query_rewrite_enabled"`
+	QueryRewriteAIConfigID  uint      `json:"query_rewrite_ai_config_id"`
+	QueryRewriteMaxQueries  int       `json:"query_rewrite_max_queries"`
 	UpdatedAt               time.Time `json:"updated_at,omitempty"`
 }
 
@@ -201,11 +395,24 @@ type VisitorWebSearchConfig struct {
 
 // UpdateEmbeddingConfigInput 更新入参
 type UpdateEmbeddingConfigInput struct {
-	EmbeddingType           *string `json:"embedding_type"`
-	APIURL                  *string `json:"api_url"`
-	APIKey                  *string `json:"api_key"`
-	Model                   *string `json:"model"`
-	CustomerCanUseKB        *bool   `json:"customer_can_use_kb"`
-	VisitorWebSearchEnabled *bool   `json:"visitor_web_search_enabled"`
-	WebSearchSource         *string `json:"web_search_source"`
+	EmbeddingType           *string  `json:"embedding_type"`
+	APIURL                  *string  `json:"api_url"`
+	APIKey                  *string  `json:"api_key"`
+	Model                   *string  `json:"model"`
+	CustomerCanUseKB        *bool    `json:"customer_can_use_kb"`
+	VisitorWebSearchEnabled *bool    `json:"visitor_web_search_enabled"`
+	WebSearchSource         *string  `json:"web_search_source"`
+	RetrievalMode           *string  `json:"retrieval_mode"`
+	RerankEnabled           *bool    `json:"rerank_enabled"`
+	RerankAPIURL            *string  `json:"rerank_api_url"`
+	RerankAPIKey            *string  `json:"rerank_api_key"`
+	RerankModel             *string  `json:"rerank_model"`
+	RerankCandidates        *int     `json:"rerank_candidates"`
+	RerankTimeoutMs         *int     `json:"rerank_timeout_ms"`
+	FAQMatchThreshold       *float64 `json:"faq_match_threshold"`
+	FAQContextThreshold     *float64 `json:"faq_context_threshold"`
+	FAQVerifyEnabled        *bool    `json:"faq_verify_enabled"`
+	QueryRewriteEnabled     *bool    `json:"query_rewrite_enabled"`
+	QueryRewriteAIConfigID  *uint    `json:"query_rewrite_ai_config_id"`
+	QueryRewriteMaxQueries  *int     `json:"query_rewrite_max_queries"`
 }
@@ -11,6 +11,7 @@ import (
 type ConfigBackedEmbeddingProvider struct {
 	configService *EmbeddingConfigService
 	factory       *embedding.EmbeddingFactory
+	usageRecorder embedding.UsageRecorder // 可选，向量化用量回调（计费统计）
 }
 
 // NewConfigBackedEmbeddingProvider 创建基于 DB 配置的嵌入服务提供者
@@ -21,8 +22,24 @@ func NewConfigBackedEmbeddingProvider(configService *EmbeddingConfigService, fac
 	}
 }
 
+// SetUsageRecorder 设置向量化用量回调，对之后 Get 返回的嵌入服务生效
+func (p *ConfigBackedEmbeddingProvider) SetUsageRecorder(recorder embedding.UsageRecorder) {
+	p.usageRecorder = recorder
+}
+
 // Get 返回当前配置对应的嵌入服务（每次从 DB 读取，无缓存）
 func (p *ConfigBackedEmbeddingProvider) Get(ctx context.Context) (embedding.EmbeddingService, error) {
+	svc, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if reporter, ok := svc.(embedding.UsageReporter); ok && p.usageRecorder != nil {
+		reporter.SetUsageRecorder(p.usageRecorder)
+	}
+	return svc, nil
+}
+
+func (p *ConfigBackedEmbeddingProvider) get(ctx context.Context) (embedding.EmbeddingService, error) {
 	typ, apiURL, apiKey, model, err := p.configService.GetRaw()
 	if err != nil {
 		return nil, err
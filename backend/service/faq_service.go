@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/2930134478/AI-CS/backend/infra"
 	"github.com/2930134478/AI-CS/backend/models"
 	"github.com/2930134478/AI-CS/backend/repository"
 	"github.com/2930134478/AI-CS/backend/service/rag"
@@ -72,7 +73,7 @@ func (s *FAQService) embedFAQAsync(ctx context.Context, faqID uint, faq *models.
 	}
 
 	// 构建向量化的内容（使用 Question + Answer）
-	content := faq.Question + "\n" + faq.Answer
+	content := faqEmbeddingContent(faq)
 
 	// 获取知识库 ID（如果为空，使用默认值 0）
 	kbID := uint(0)
@@ -81,7 +82,7 @@ func (s *FAQService) embedFAQAsync(ctx context.Context, faqID uint, faq *models.
 	}
 
 	// 进行向量化
-	err := s.documentEmbeddingService.EmbedDocument(ctx, faqID, kbID, content)
+	err := s.documentEmbeddingService.EmbedFAQ(ctx, faqID, kbID, content)
 	if err != nil {
 		log.Printf("FAQ %d 向量化失败: %v", faqID, err)
 		// 更新状态为失败
@@ -101,6 +102,28 @@ func (s *FAQService) embedFAQAsync(ctx context.Context, faqID uint, faq *models.
 	}
 }
 
+// faqEmbeddingContent FAQ 向量化（及向量库中保存）的文本：问题 + 换行 + 答案。
+// FAQ 向量以 FAQ ID 作为 document_id、source_type=faq 存储，与同 ID 的文档向量靠来源类型区分。
+func faqEmbeddingContent(faq *models.FAQ) string {
+	return faq.Question + "\n" + faq.Answer
+}
+
+// LegacyFAQSourceType 迁移缺少 source_type 的旧向量集合时判定 FAQ 向量：
+// document_id 对应的 FAQ 存在且向量内容与其问题 + 换行 + 答案一致（否则视为同 ID 的文档向量）。
+func LegacyFAQSourceType(faqs *repository.FAQRepository) infra.LegacySourceTypeFunc {
+	return func(ctx context.Context, documentID string, content string) string {
+		id, err := strconv.ParseUint(documentID, 10, 64)
+		if err != nil {
+			return ""
+		}
+		faq, err := faqs.GetByID(uint(id))
+		if err != nil || faqEmbeddingContent(faq) != content {
+			return ""
+		}
+		return rag.SourceTypeFAQ
+	}
+}
+
 // GetFAQ 获取 FAQ 详情。
 func (s *FAQService) GetFAQ(id uint) (*FAQSummary, error) {
 	faq, err := s.faqs.GetByID(id)
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"gorm.io/gorm"
+)
+
+// 转人工触发原因
+const (
+	HandoffReasonKeyword    = "keyword"     // 访客消息命中转人工关键词
+	HandoffReasonAIIntent   = "ai_intent"   // 大模型判断需要人工（transfer_to_human 工具 / 意图标记）
+	HandoffReasonAIFailures = "ai_failures" // AI 连续生成失败
+	HandoffReasonVisitor    = "visitor"     // 访客主动点击转人工
+)
+
+const (
+	// defaultHandoffFailureThreshold AI 连续失败多少次后自动转人工
+	defaultHandoffFailureThreshold = 2
+	// handoffTranscriptLimit 随 handoff_requested 事件附带的 AI 对话记录条数上限
+	handoffTranscriptLimit = 50
+)
+
+// defaultHandoffKeywords 默认转人工关键词（可通过 HANDOFF_KEYWORDS 覆盖，逗号分隔）
+var defaultHandoffKeywords = []string{
+	"转人工", "人工客服", "找人工", "真人客服", "人工服务",
+	"human agent", "real person", "talk to a human", "speak to a human",
+}
+
+// HandoffTranscriptItem 转人工时附带给客服的 AI 对话记录
+type HandoffTranscriptItem struct {
+	MessageID uint      `json:"message_id"`
+	Role      string    `json:"role"` // visitor / ai
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HandoffResult 转人工结果
+type HandoffResult struct {
+	ConversationID  uint                    `json:"conversation_id"`
+	Reason          string                  `json:"reason"`
+	Detail          string                  `json:"detail,omitempty"`
+	HandoffAt       time.Time               `json:"handoff_at"`
+	QueuePosition   int                     `json:"queue_position"`
+	AssignedAgentID uint                    `json:"assigned_agent_id"` // 自动分配到的客服（0 表示排队等待认领）
+	Transcript      []HandoffTranscriptItem `json:"transcript"`
+}
+
+// HandoffQueueItem 排队中的转人工会话
+type HandoffQueueItem struct {
+	ConversationID uint       `json:"conversation_id"`
+	VisitorID      uint       `json:"visitor_id"`
+	HandoffReason  string     `json:"handoff_reason"`
+	HandoffAt      *time.Time `json:"handoff_at"`
+	Website        string     `json:"website"`
+	Location       string     `json:"location"`
+	QueuePosition  int        `json:"queue_position"`
+	WaitSeconds    int64      `json:"wait_seconds"`
+}
+
+// HandoffService 负责 AI 会话转人工：触发判定、切换会话模式、写系统消息并通知在线客服。
+type HandoffService struct {
+	conversations    *repository.ConversationRepository
+	messages         *repository.MessageRepository
+	hub              BroadcastHub
+	systemLogSvc     *SystemLogService
+	keywords         []string
+	failureThreshold int
+	assignmentSvc    *AssignmentService // 可选，转人工后自动分配客服
+}
+
+// SetAssignmentService 注入会话分配服务（转人工后按策略自动分配客服）
+func (s *HandoffService) SetAssignmentService(svc *AssignmentService) {
+	s.assignmentSvc = svc
+}
+
+// NewHandoffService 创建转人工服务实例。
+// 关键词与失败阈值可通过环境变量 HANDOFF_KEYWORDS、HANDOFF_AI_FAILURE_THRESHOLD 覆盖（阈值为 0 表示关闭失败触发）。
+func NewHandoffService(
+	conversations *repository.ConversationRepository,
+	messages *repository.MessageRepository,
+	hub BroadcastHub,
+	systemLogSvc *SystemLogService,
+) *HandoffService {
+	keywords := defaultHandoffKeywords
+	if v := strings.TrimSpace(os.Getenv("HANDOFF_KEYWORDS")); v != "" {
+		keywords = nil
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				keywords = append(keywords, k)
+			}
+		}
+	}
+	threshold := defaultHandoffFailureThreshold
+	if v := strings.TrimSpace(os.Getenv("HANDOFF_AI_FAILURE_THRESHOLD")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			threshold = n
+		}
+	}
+	return &HandoffService{
+		conversations:    conversations,
+		messages:         messages,
+		hub:              hub,
+		systemLogSvc:     systemLogSvc,
+		keywords:         keywords,
+		failureThreshold: threshold,
+	}
+}
+
+// MatchKeyword 判断访客消息是否命中转人工关键词，返回命中的关键词
+func (s *HandoffService) MatchKeyword(content string) (string, bool) {
+	text := strings.ToLower(strings.TrimSpace(content))
+	if text == "" {
+		return "", false
+	}
+	for _, k := range s.keywords {
+		if strings.Contains(text, strings.ToLower(k)) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// ShouldHandoffAfterFailure AI 回复失败后调用：最近连续 failureThreshold 条 AI 回复均失败时返回 true
+func (s *HandoffService) ShouldHandoffAfterFailure(conversationID uint) bool {
+	if s.failureThreshold <= 0 {
+		return false
+	}
+	messages, err := s.messages.ListByConversationID(conversationID)
+	if err != nil {
+		log.Printf("⚠️ 转人工失败判定读取消息失败: conversation_id=%d err=%v", conversationID, err)
+		return false
+	}
+	failures := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		// 只看 AI 回复（SenderID=0 的客服侧消息）
+		if !msg.SenderIsAgent || msg.SenderID != 0 || msg.MessageType == "system_message" {
+			continue
+		}
+		if !msg.IsAIGenerationFailed {
+			break
+		}
+		failures++
+		if failures >= s.failureThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestHandoff 将 AI 会话转为人工：切换 chat_mode、记录转接原因、写系统消息，
+// 并向在线客服广播 handoff_requested（附带 AI 对话记录与排队位置）。
+// 会话不在 AI 模式时返回 nil, nil（无需转接）。
+func (s *HandoffService) RequestHandoff(conversationID uint, reason string, detail string) (*HandoffResult, error) {
+	conv, err := s.conversations.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if conv.ChatMode != "ai" || conv.ConversationType == "internal" {
+		return nil, nil
+	}
+
+	// 条件更新：同一轮关键词与 AI 意图同时触发、或并发消息触发时，只有一次能从 ai 切到 human
+	now := time.Now()
+	switched, err := s.conversations.UpdateFieldsIfChatMode(conversationID, "ai", map[string]interface{}{
+		"chat_mode":      "human",
+		"ai_config_id":   nil,
+		"handoff_at":     now,
+		"handoff_reason": reason,
+		"updated_at":     now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !switched {
+		return nil, nil
+	}
+
+	transcript, err := s.buildTranscript(conversationID)
+	if err != nil {
+		log.Printf("⚠️ 读取 AI 对话记录失败: conversation_id=%d err=%v", conversationID, err)
+	}
+
+	systemMessage := &models.Message{
+		ConversationID: conversationID,
+		SenderID:       0,
+		SenderIsAgent:  false,
+		Content:        "Conversation transferred from AI to a human agent",
+		MessageType:    "system_message",
+		ChatMode:       "human",
+		IsRead:         true,
+		ReadAt:         &now,
+	}
+	if err := s.messages.Create(systemMessage); err != nil {
+		log.Printf("⚠️ 创建转人工系统消息失败: conversation_id=%d err=%v", conversationID, err)
+		systemMessage = nil
+	}
+
+	result := &HandoffResult{
+		ConversationID: conversationID,
+		Reason:         reason,
+		Detail:         detail,
+		HandoffAt:      now,
+		Transcript:     transcript,
+	}
+	if s.assignmentSvc != nil {
+		assigned, err := s.assignmentSvc.AutoAssign(conversationID, true, "handoff")
+		if err != nil {
+			log.Printf("⚠️ 转人工自动分配客服失败: conversation_id=%d err=%v", conversationID, err)
+		} else if assigned != nil {
+			result.AssignedAgentID = assigned.AgentID
+		}
+	}
+	result.QueuePosition = s.queuePosition(conversationID)
+
+	if s.hub != nil {
+		if systemMessage != nil {
+			s.hub.BroadcastMessage(conversationID, "new_message", systemMessage)
+		}
+		s.hub.BroadcastMessage(conversationID, "chat_mode_changed", map[string]interface{}{
+			"conversation_id": conversationID,
+			"chat_mode":       "human",
+			"reason":          reason,
+		})
+		s.hub.BroadcastToAllAgents("handoff_requested", map[string]interface{}{
+			"conversation_id":   conversationID,
+			"visitor_id":        conv.VisitorID,
+			"reason":            reason,
+			"detail":            detail,
+			"handoff_at":        now,
+			"queue_position":    result.QueuePosition,
+			"assigned_agent_id": result.AssignedAgentID,
+			"website":           conv.Website,
+			"location":          conv.Location,
+			"transcript":        transcript,
+		})
+	}
+
+	if s.systemLogSvc != nil {
+		convID := conversationID
+		visitorID := conv.VisitorID
+		_ = s.systemLogSvc.Create(CreateSystemLogInput{
+			Level:          "info",
+			Category:       "business",
+			Event:          "ai_handoff_requested",
+			Source:         "backend",
+			ConversationID: &convID,
+			VisitorID:      &visitorID,
+			Message:        "AI 会话转人工",
+			Meta: map[string]interface{}{
+				"reason":            reason,
+				"detail":            detail,
+				"queue_position":    result.QueuePosition,
+				"assigned_agent_id": result.AssignedAgentID,
+			},
+		})
+	}
+	return result, nil
+}
+
+// ListQueue 返回排队中的转人工会话（先到先服务）
+func (s *HandoffService) ListQueue() ([]HandoffQueueItem, error) {
+	conversations, err := s.conversations.ListHandoffQueue()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	items := make([]HandoffQueueItem, 0, len(conversations))
+	for i, conv := range conversations {
+		item := HandoffQueueItem{
+			ConversationID: conv.ID,
+			VisitorID:      conv.VisitorID,
+			HandoffReason:  conv.HandoffReason,
+			HandoffAt:      conv.HandoffAt,
+			Website:        conv.Website,
+			Location:       conv.Location,
+			QueuePosition:  i + 1,
+		}
+		if conv.HandoffAt != nil {
+			item.WaitSeconds = int64(now.Sub(*conv.HandoffAt).Seconds())
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// queuePosition 返回会话在转人工队列中的位置（从 1 开始，未找到返回 0）
+func (s *HandoffService) queuePosition(conversationID uint) int {
+	conversations, err := s.conversations.ListHandoffQueue()
+	if err != nil {
+		return 0
+	}
+	for i, conv := range conversations {
+		if conv.ID == conversationID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// buildTranscript 收集转接前 AI 模式下的访客消息与 AI 回复
+func (s *HandoffService) buildTranscript(conversationID uint) ([]HandoffTranscriptItem, error) {
+	messages, err := s.messages.ListByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]HandoffTranscriptItem, 0)
+	for _, msg := range messages {
+		if msg.ChatMode != "ai" || msg.MessageType == "system_message" {
+			continue
+		}
+		role := "visitor"
+		if msg.SenderIsAgent {
+			role = "ai"
+		}
+		items = append(items, HandoffTranscriptItem{
+			MessageID: msg.ID,
+			Role:      role,
+			Content:   msg.Content,
+			CreatedAt: msg.CreatedAt,
+		})
+	}
+	if len(items) > handoffTranscriptLimit {
+		items = items[len(items)-handoffTranscriptLimit:]
+	}
+	return items, nil
+}
@@ -0,0 +1,431 @@
+package import_service
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// CrawlerUserAgent 抓取请求使用的 User-Agent（robots.txt 按其产品名 AI-CS-Crawler 匹配分组）
+	CrawlerUserAgent = "AI-CS-Crawler/1.0"
+
+	// DefaultCrawlMaxDepth 默认跟随链接层数
+	DefaultCrawlMaxDepth = 2
+	// DefaultCrawlMaxPages 默认单次最多抓取页面数
+	DefaultCrawlMaxPages = 50
+
+	defaultCrawlDelay = time.Second
+	// 单个页面 / 站点地图的最大下载字节数
+	maxCrawlBodyBytes = 5 << 20
+	// 站点地图索引的最大嵌套层数
+	maxSitemapNesting = 2
+	// 待抓取队列相对页数上限的倍数（避免链接极多的站点占用过多内存）
+	crawlQueueFactor = 20
+)
+
+// 链接指向这些扩展名时不抓取（非 HTML 资源）
+var skippedLinkExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".svg": true, ".ico": true, ".bmp": true,
+	".css": true, ".js": true, ".json": true, ".xml": true, ".rss": true, ".zip": true, ".gz": true, ".tar": true,
+	".rar": true, ".7z": true, ".exe": true, ".dmg": true, ".apk": true, ".mp3": true, ".mp4": true, ".avi": true,
+	".mov": true, ".woff": true, ".woff2": true, ".ttf": true, ".pdf": true, ".doc": true, ".docx": true,
+	".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+}
+
+// CrawlOptions 抓取参数（零值使用默认值）
+type CrawlOptions struct {
+	MaxDepth  int           // 从起始页面跟随链接的层数，0 表示只抓取起始页面（或站点地图中列出的页面）
+	MaxPages  int           // 最多抓取的页面数
+	Delay     time.Duration // 相邻请求的最小间隔；robots.txt 的 Crawl-delay 更长时以其为准
+	UserAgent string
+	Client    *http.Client
+}
+
+// CrawledPage 抓取到的页面
+type CrawledPage struct {
+	URL      string // 规范化后的 URL（页面声明同站 canonical 时使用 canonical）
+	Depth    int
+	Document *ParsedDocument
+}
+
+// CrawlFailure 抓取失败的页面
+type CrawlFailure struct {
+	URL   string
+	Error string
+}
+
+// CrawlResult 一次抓取的结果
+type CrawlResult struct {
+	Pages      []CrawledPage
+	Failures   []CrawlFailure
+	Disallowed int // robots.txt 禁止抓取而跳过的页面数
+}
+
+// Crawler 站内爬虫：从起始 URL 或 sitemap.xml 出发，广度优先抓取同一站点的页面，
+// 遵守 robots.txt 与请求间隔，按规范化 URL 去重并抽取正文
+type Crawler struct {
+	opts        CrawlOptions
+	client      *http.Client
+	robots      map[string]*robotsRules // 按 scheme://host 缓存
+	lastRequest time.Time
+}
+
+// NewCrawler 创建爬虫
+func NewCrawler(opts CrawlOptions) *Crawler {
+	if opts.MaxDepth < 0 {
+		opts.MaxDepth = 0
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = DefaultCrawlMaxPages
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = defaultCrawlDelay
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = CrawlerUserAgent
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Crawler{
+		opts:   opts,
+		client: client,
+		robots: make(map[string]*robotsRules),
+	}
+}
+
+type crawlItem struct {
+	u     *url.URL
+	depth int
+}
+
+// Crawl 执行抓取。单个页面失败记入 Failures 并继续；起始地址无效或站点地图无法读取时返回错误。
+// ctx 取消时返回已抓取的部分结果与 ctx 的错误。
+func (c *Crawler) Crawl(ctx context.Context, seed string) (*CrawlResult, error) {
+	seedURL, err := url.Parse(strings.TrimSpace(seed))
+	if err != nil || (seedURL.Scheme != "http" && seedURL.Scheme != "https") || seedURL.Host == "" {
+		return nil, fmt.Errorf("无效的起始地址: %s", seed)
+	}
+	site := siteHost(seedURL)
+	result := &CrawlResult{}
+
+	var queue []crawlItem
+	queued := make(map[string]bool)
+	enqueue := func(u *url.URL, depth int) {
+		key := CanonicalURL(u)
+		if queued[key] || len(queued) >= c.opts.MaxPages*crawlQueueFactor {
+			return
+		}
+		queued[key] = true
+		queue = append(queue, crawlItem{u: u, depth: depth})
+	}
+
+	if isSitemapURL(seedURL) {
+		locs, err := c.fetchSitemap(ctx, seedURL, 0)
+		if err != nil {
+			return nil, fmt.Errorf("读取站点地图失败: %w", err)
+		}
+		for _, loc := range locs {
+			if siteHost(loc) == site {
+				enqueue(loc, 0)
+			}
+		}
+	} else {
+		enqueue(seedURL, 0)
+	}
+
+	emitted := make(map[string]bool)
+	for len(queue) > 0 && len(result.Pages) < c.opts.MaxPages {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		item := queue[0]
+		queue = queue[1:]
+
+		if !c.allowed(ctx, item.u) {
+			result.Disallowed++
+			continue
+		}
+		page, links, err := c.fetchPage(ctx, item.u, site)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			result.Failures = append(result.Failures, CrawlFailure{URL: item.u.String(), Error: err.Error()})
+			continue
+		}
+		if item.depth < c.opts.MaxDepth {
+			for _, link := range links {
+				enqueue(link, item.depth+1)
+			}
+		}
+		if page == nil || emitted[page.URL] {
+			continue
+		}
+		emitted[page.URL] = true
+		page.Depth = item.depth
+		result.Pages = append(result.Pages, *page)
+	}
+	return result, nil
+}
+
+// fetchPage 下载并解析页面，返回页面（非 HTML、被重定向到站外、noindex 或正文为空时为 nil）与页面中的同站链接
+func (c *Crawler) fetchPage(ctx context.Context, u *url.URL, site string) (*CrawledPage, []*url.URL, error) {
+	resp, err := c.get(ctx, u, "text/html,application/xhtml+xml")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	finalURL := resp.Request.URL
+	if siteHost(finalURL) != site {
+		return nil, nil, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, nil, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxCrawlBodyBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 HTML 失败: %w", err)
+	}
+
+	base := finalURL
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if b, err := finalURL.Parse(strings.TrimSpace(href)); err == nil {
+			base = b
+		}
+	}
+	robotsMeta := strings.ToLower(doc.Find(`meta[name="robots" i]`).AttrOr("content", ""))
+
+	// 链接需在抽取正文（移除导航等模板内容）之前提取
+	var links []*url.URL
+	if !strings.Contains(robotsMeta, "nofollow") && !strings.Contains(robotsMeta, "none") {
+		links = pageLinks(doc, base, site)
+	}
+	if strings.Contains(robotsMeta, "noindex") || strings.Contains(robotsMeta, "none") {
+		return nil, links, nil
+	}
+
+	pageURL := CanonicalURL(finalURL)
+	if href, ok := doc.Find(`link[rel="canonical" i]`).First().Attr("href"); ok {
+		if cu, err := base.Parse(strings.TrimSpace(href)); err == nil && siteHost(cu) == site {
+			pageURL = CanonicalURL(cu)
+		}
+	}
+
+	parsed := parseHTMLDocument(doc, pageURL)
+	if parsed.Content == "" {
+		return nil, links, nil
+	}
+	return &CrawledPage{URL: pageURL, Document: parsed}, links, nil
+}
+
+// pageLinks 提取页面中指向同一站点的 http(s) 链接（忽略 rel=nofollow 与非 HTML 资源）
+func pageLinks(doc *goquery.Document, base *url.URL, site string) []*url.URL {
+	var links []*url.URL
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		if strings.Contains(strings.ToLower(a.AttrOr("rel", "")), "nofollow") {
+			return
+		}
+		href := strings.TrimSpace(a.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") {
+			return
+		}
+		u, err := base.Parse(href)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || siteHost(u) != site {
+			return
+		}
+		if skippedLinkExts[strings.ToLower(path.Ext(u.Path))] {
+			return
+		}
+		u.Fragment = ""
+		u.RawFragment = ""
+		links = append(links, u)
+	})
+	return links
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapXML 兼容 <urlset> 与 <sitemapindex>
+type sitemapXML struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// fetchSitemap 读取站点地图中的页面地址（支持站点地图索引与 .gz 压缩）
+func (c *Crawler) fetchSitemap(ctx context.Context, u *url.URL, nesting int) ([]*url.URL, error) {
+	resp, err := c.get(ctx, u, "application/xml,text/xml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var body io.Reader = io.LimitReader(resp.Body, maxCrawlBodyBytes)
+	if strings.HasSuffix(strings.ToLower(u.Path), ".gz") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("解压站点地图失败: %w", err)
+		}
+		defer gz.Close()
+		body = io.LimitReader(gz, maxCrawlBodyBytes)
+	}
+	var sm sitemapXML
+	if err := xml.NewDecoder(body).Decode(&sm); err != nil {
+		return nil, fmt.Errorf("解析站点地图失败: %w", err)
+	}
+
+	var out []*url.URL
+	for _, loc := range sm.URLs {
+		if lu, err := u.Parse(strings.TrimSpace(loc.Loc)); err == nil {
+			out = append(out, lu)
+		}
+	}
+	if nesting < maxSitemapNesting {
+		for _, loc := range sm.Sitemaps {
+			child, err := u.Parse(strings.TrimSpace(loc.Loc))
+			if err != nil || siteHost(child) != siteHost(u) {
+				continue
+			}
+			urls, err := c.fetchSitemap(ctx, child, nesting+1)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				continue
+			}
+			out = append(out, urls...)
+		}
+	}
+	if len(out) == 0 && len(sm.Sitemaps) == 0 {
+		return nil, errors.New("站点地图中没有页面地址")
+	}
+	return out, nil
+}
+
+// allowed 按 robots.txt 判断是否允许抓取（每个站点只读取一次）
+func (c *Crawler) allowed(ctx context.Context, u *url.URL) bool {
+	key := u.Scheme + "://" + strings.ToLower(u.Host)
+	rules, ok := c.robots[key]
+	if !ok {
+		rules = c.fetchRobots(ctx, u)
+		c.robots[key] = rules
+	}
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return rules.allowed(p)
+}
+
+// fetchRobots 读取 robots.txt：不存在（4xx）视为全部允许，无法访问（5xx / 网络错误）视为全部禁止
+func (c *Crawler) fetchRobots(ctx context.Context, u *url.URL) *robotsRules {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	resp, err := c.get(ctx, robotsURL, "text/plain")
+	if err != nil {
+		return &robotsRules{disallowAll: true}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		return &robotsRules{disallowAll: true}
+	case resp.StatusCode >= 400:
+		return &robotsRules{}
+	case resp.StatusCode != http.StatusOK:
+		return &robotsRules{}
+	}
+	return parseRobots(resp.Body, c.opts.UserAgent)
+}
+
+// get 按请求间隔发起 GET 请求（间隔取 Delay 与各站点 Crawl-delay 的较大值）
+func (c *Crawler) get(ctx context.Context, u *url.URL, accept string) (*http.Response, error) {
+	delay := c.opts.Delay
+	for _, rules := range c.robots {
+		if rules.crawlDelay > delay {
+			delay = rules.crawlDelay
+		}
+	}
+	if wait := time.Until(c.lastRequest.Add(delay)); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	c.lastRequest = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	req.Header.Set("Accept", accept)
+	return c.client.Do(req)
+}
+
+// CanonicalURL 规范化 URL 用于去重：协议与主机小写、去掉默认端口 / 片段 / 用户信息 / 跟踪参数，查询参数排序
+func CanonicalURL(u *url.URL) string {
+	c := *u
+	c.Scheme = strings.ToLower(c.Scheme)
+	host := strings.ToLower(c.Hostname())
+	if port := c.Port(); port != "" && !(c.Scheme == "http" && port == "80") && !(c.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	c.Host = host
+	c.User = nil
+	c.Fragment = ""
+	c.RawFragment = ""
+	if c.Path == "" {
+		c.Path = "/"
+		c.RawPath = ""
+	}
+	if c.RawQuery != "" {
+		q := c.Query()
+		for k := range q {
+			lk := strings.ToLower(k)
+			if strings.HasPrefix(lk, "utm_") || lk == "fbclid" || lk == "gclid" {
+				q.Del(k)
+			}
+		}
+		c.RawQuery = q.Encode()
+	}
+	c.ForceQuery = false
+	return c.String()
+}
+
+// siteHost 用于判断是否同一站点的主机名（忽略大小写与 www. 前缀）
+func siteHost(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func isSitemapURL(u *url.URL) bool {
+	p := strings.ToLower(u.Path)
+	return strings.HasSuffix(p, ".xml") || strings.HasSuffix(p, ".xml.gz")
+}
@@ -0,0 +1,175 @@
+package import_service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPageTemplate = `<!DOCTYPE html>
+<html><head><title>%s</title>%s</head>
+<body>
+<header class="site-header"><a href="/">首页</a></header>
+<nav>导航：<a href="/a">产品</a> <a href="/a?utm_source=nav">产品（跟踪）</a> <a href="/b">价格</a>
+<a href="/private/secret">内部</a> <a href="http://other.example/">外站</a> <a href="/logo.png">Logo</a></nav>
+<div class="content">%s</div>
+<div class="sidebar">侧栏推荐：热门文章一、热门文章二、热门文章三，欢迎阅读更多内容。</div>
+<footer>版权所有 © 示例公司</footer>
+</body></html>`
+
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	page := func(title, head, body string) string {
+		return fmt.Sprintf(testPageTemplate, title, head, body)
+	}
+	pages := map[string]string{
+		"/": page("首页", "", `<h1 id="welcome">欢迎</h1>
+<p>这是示例公司的帮助中心首页，这里介绍产品的基本用法、价格方案以及售后政策，帮助你快速上手。</p>`),
+		"/a": page("产品", "", `<h1>产品介绍</h1>
+<p>产品支持多渠道接入，包括网页、微信与邮件，所有会话统一在客服工作台处理，并可接入知识库。</p>
+<p><a href="/a/deep">更多细节</a></p>`),
+		"/a/deep":         page("细节", "", `<p>这是第二层页面，深度限制为 1 时不应被抓取，因为它距离起始页面有两层链接。</p>`),
+		"/b":              page("价格", `<link rel="canonical" href="/a">`, `<p>此页面声明 canonical 指向产品页，应按规范地址去重，不应重复导入为单独的页面。</p>`),
+		"/private/secret": page("内部", "", `<p>robots.txt 禁止抓取该目录，爬虫不应访问这里的任何页面，也不应导入其内容。</p>`),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n\nUser-agent: OtherBot\nDisallow: /\n")
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>http://%s/a</loc></url>
+<url><loc>http://%s/a/deep</loc></url>
+<url><loc>http://other.example/x</loc></url>
+</urlset>`, r.Host, r.Host)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/private/secret" {
+			t.Errorf("robots.txt 禁止的页面被访问")
+		}
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCrawlerFollowsSameSiteLinks(t *testing.T) {
+	site := newTestSite(t)
+	defer site.Close()
+
+	crawler := NewCrawler(CrawlOptions{MaxDepth: 1, MaxPages: 10, Delay: time.Millisecond})
+	result, err := crawler.Crawl(context.Background(), site.URL+"/")
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+
+	got := make(map[string]CrawledPage)
+	for _, p := range result.Pages {
+		got[strings.TrimPrefix(p.URL, site.URL)] = p
+	}
+	if len(got) != 2 || got["/"].Document == nil || got["/a"].Document == nil {
+		t.Fatalf("应只抓取首页与产品页（深度 1，canonical 与跟踪参数去重），实际: %v", keys(got))
+	}
+	if result.Disallowed != 1 {
+		t.Errorf("Disallowed = %d, want 1", result.Disallowed)
+	}
+
+	home := got["/"].Document
+	if !strings.Contains(home.Content, "帮助中心首页") {
+		t.Errorf("正文缺失: %q", home.Content)
+	}
+	for _, noise := range []string{"导航", "版权所有", "侧栏推荐", "首页\n"} {
+		if strings.Contains(home.Content, noise) {
+			t.Errorf("正文包含模板内容 %q: %q", noise, home.Content)
+		}
+	}
+	if len(home.Sections) == 0 || home.Sections[0].Anchor != site.URL+"/#welcome" {
+		t.Errorf("章节锚点 = %+v", home.Sections)
+	}
+}
+
+func TestCrawlerSitemapSeed(t *testing.T) {
+	site := newTestSite(t)
+	defer site.Close()
+
+	crawler := NewCrawler(CrawlOptions{MaxDepth: 0, MaxPages: 10, Delay: time.Millisecond})
+	result, err := crawler.Crawl(context.Background(), site.URL+"/sitemap.xml")
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+	var urls []string
+	for _, p := range result.Pages {
+		urls = append(urls, strings.TrimPrefix(p.URL, site.URL))
+	}
+	if strings.Join(urls, ",") != "/a,/a/deep" {
+		t.Errorf("站点地图页面 = %v, want [/a /a/deep]", urls)
+	}
+}
+
+func TestCrawlerMaxPages(t *testing.T) {
+	site := newTestSite(t)
+	defer site.Close()
+
+	crawler := NewCrawler(CrawlOptions{MaxDepth: 3, MaxPages: 1, Delay: time.Millisecond})
+	result, err := crawler.Crawl(context.Background(), site.URL+"/")
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+	if len(result.Pages) != 1 {
+		t.Errorf("pages = %d, want 1", len(result.Pages))
+	}
+}
+
+func TestRobotsRules(t *testing.T) {
+	robots := "User-agent: *\nDisallow: /docs\nAllow: /docs/public\nDisallow: /*.json$\n\n" +
+		"User-agent: AI-CS-Crawler\nUser-agent: SomeBot\nDisallow: /tmp\nAllow: /tmp/ok\nCrawl-delay: 2\n"
+	rules := parseRobots(strings.NewReader(robots), CrawlerUserAgent)
+	cases := map[string]bool{
+		"/docs/guide":  true, // 有专属分组时不使用 * 分组
+		"/tmp/x":       false,
+		"/tmp/ok/page": true,
+		"/robots.txt":  true,
+	}
+	for p, want := range cases {
+		if got := rules.allowed(p); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", p, got, want)
+		}
+	}
+	if rules.crawlDelay != 2*time.Second {
+		t.Errorf("crawlDelay = %v", rules.crawlDelay)
+	}
+
+	generic := parseRobots(strings.NewReader(robots), "OtherBot/2.0")
+	cases = map[string]bool{
+		"/docs/guide":       false,
+		"/docs/public/a":    true,
+		"/api/data.json":    false,
+		"/api/data.json?x=": true,
+		"/":                 true,
+	}
+	for p, want := range cases {
+		if got := generic.allowed(p); got != want {
+			t.Errorf("* 分组 allowed(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func keys(m map[string]CrawledPage) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
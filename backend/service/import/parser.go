@@ -1,10 +1,14 @@
 package import_service
 
+import "github.com/2930134478/AI-CS/backend/models"
+
 // ParsedDocument 解析后的文档
 type ParsedDocument struct {
 	Title   string
 	Content string
 	Metadata map[string]interface{}
+	// Sections 内容中各区间的来源位置（PDF 页码、DOCX 标题层级、网页锚点），按 Start 升序；无位置信息时为空
+	Sections []models.DocumentSourceSection
 }
 
 // DocumentParser 文档解析器接口
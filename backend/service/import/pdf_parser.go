@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/2930134478/AI-CS/backend/models"
 	"github.com/ledongthuc/pdf"
 )
 
@@ -29,7 +30,11 @@ func (p *PDFParser) Parse(filePath string) (*ParsedDocument, error) {
 	}
 	defer f.Close()
 
-	var allLines []string
+	var (
+		allLines []string
+		sections []models.DocumentSourceSection
+		offset   int // 已写入内容的字节长度（行之间以换行连接）
+	)
 
 	for pageNum := 1; pageNum <= reader.NumPage(); pageNum++ {
 		page := reader.Page(pageNum)
@@ -42,6 +47,7 @@ func (p *PDFParser) Parse(filePath string) (*ParsedDocument, error) {
 			continue
 		}
 
+		pageStart := -1
 		for _, row := range rows {
 			var sb strings.Builder
 			for _, t := range row.Content {
@@ -49,9 +55,20 @@ func (p *PDFParser) Parse(filePath string) (*ParsedDocument, error) {
 			}
 			line := strings.TrimSpace(sb.String())
 			if line != "" {
+				if len(allLines) > 0 {
+					offset++
+				}
+				if pageStart < 0 {
+					pageStart = offset
+				}
 				allLines = append(allLines, line)
+				offset += len(line)
 			}
 		}
+		// 记录该页内容在全文中的区间
+		if pageStart >= 0 {
+			sections = append(sections, models.DocumentSourceSection{Start: pageStart, End: offset, PageNumber: pageNum})
+		}
 	}
 
 	text := strings.Join(allLines, "\n")
@@ -64,6 +81,7 @@ func (p *PDFParser) Parse(filePath string) (*ParsedDocument, error) {
 	return &ParsedDocument{
 		Title:    title,
 		Content:  text,
-		Metadata: map[string]interface{}{"source": "pdf"},
+		Metadata: map[string]interface{}{"source": "pdf", "pages": reader.NumPage()},
+		Sections: sections,
 	}, nil
 }
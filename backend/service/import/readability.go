@@ -0,0 +1,243 @@
+package import_service
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// 正文抽取：先移除导航、页眉页脚、侧栏等模板内容，再按段落文本密度选出正文容器（Readability 算法的简化实现）
+
+const (
+	// 参与打分的段落最小长度（字符）
+	minScoredParagraphRunes = 25
+	// main / article 中文本不足该长度时不直接作为正文
+	minMainContentRunes = 50
+)
+
+// 一定不是正文的元素
+const boilerplateSelector = "script, style, noscript, template, iframe, svg, canvas, form, button, select, input, textarea, nav, aside, " +
+	"[role=navigation], [role=banner], [role=contentinfo], [role=complementary], [role=search], [aria-hidden=true], [hidden]"
+
+var (
+	// class / id 中出现这些词的元素视为模板内容（同时含正向词时保留）
+	negativeHintPattern = regexp.MustCompile(`(?i)(^|[-_\s])(nav|navbar|navigation|menu|header|footer|sidebar|breadcrumbs?|share|sharing|social|comments?|cookies?|banner|ads?|advert|advertisement|promo|related|popup|modal|subscribe|newsletter|pagination|pager|skip)([-_\s]|$)`)
+	positiveHintPattern = regexp.MustCompile(`(?i)article|content|main|post|entry|blog|story|markdown|prose|docs?`)
+	clauseMarkPattern   = regexp.MustCompile(`[,，、;；。]`)
+)
+
+// 文本输出时前后换行的块级元素
+var blockElements = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"figcaption": true, "figure": true, "footer": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "tr": true, "ul": true,
+}
+
+// extractMainContent 返回页面正文所在的节点（会从 doc 中移除模板内容）；无法判断时返回 body
+func extractMainContent(doc *goquery.Document) *goquery.Selection {
+	body := doc.Find("body").First()
+	if body.Length() == 0 {
+		return doc.Selection
+	}
+	stripBoilerplate(body)
+
+	if main := largestByText(body.Find("main, [role=main]")); main != nil && textRunes(main) >= minMainContentRunes {
+		return main
+	}
+	if articles := body.Find("article"); articles.Length() == 1 && textRunes(articles) >= minMainContentRunes {
+		return articles
+	}
+	if best := topScoredCandidate(body); best != nil {
+		return best
+	}
+	return body
+}
+
+// stripBoilerplate 移除脚本、导航、侧栏及 class / id 带模板特征的元素；正文内的 header / footer（如文章标题区）保留
+func stripBoilerplate(body *goquery.Selection) {
+	body.Find(boilerplateSelector).Remove()
+	body.Find("header, footer").Each(func(_ int, s *goquery.Selection) {
+		if s.Closest("article, main, [role=main]").Length() == 0 {
+			s.Remove()
+		}
+	})
+	body.Find("div, section, ul, ol, table, span, p").Each(func(_ int, s *goquery.Selection) {
+		hint := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if negativeHintPattern.MatchString(hint) && !positiveHintPattern.MatchString(hint) {
+			s.Remove()
+		}
+	})
+}
+
+// topScoredCandidate 按段落为父节点（全分）与祖父节点（半分）累计得分，扣除链接密度后取最高者；
+// 最高者的兄弟节点同样得分较高时（正文分成多个小节）改用共同的父节点
+func topScoredCandidate(body *goquery.Selection) *goquery.Selection {
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node
+	add := func(s *goquery.Selection, score float64) {
+		if s.Length() == 0 || goquery.NodeName(s) == "html" {
+			return
+		}
+		n := s.Get(0)
+		if _, ok := scores[n]; !ok {
+			order = append(order, n)
+		}
+		scores[n] += score
+	}
+	body.Find("p, pre, td, blockquote, div").Each(func(_ int, s *goquery.Selection) {
+		// div 仅在不含其他块级元素时视为段落
+		if goquery.NodeName(s) == "div" && s.Find("p, div, pre, table, ul, ol, section, article, blockquote, h1, h2, h3, h4, h5, h6").Length() > 0 {
+			return
+		}
+		text := collapseSpaces(s.Text())
+		n := utf8.RuneCountInString(text)
+		if n < minScoredParagraphRunes {
+			return
+		}
+		score := 1 + float64(len(clauseMarkPattern.FindAllString(text, -1)))
+		if bonus := float64(n / 100); bonus < 3 {
+			score += bonus
+		} else {
+			score += 3
+		}
+		parent := s.Parent()
+		add(parent, score)
+		add(parent.Parent(), score/2)
+	})
+	if len(order) == 0 {
+		return nil
+	}
+
+	final := make(map[*html.Node]float64, len(order))
+	var best *html.Node
+	for _, n := range order {
+		s := goquery.NewDocumentFromNode(n).Selection
+		score := scores[n]*(1-linkDensity(s)) + classWeight(s)
+		final[n] = score
+		if best == nil || score > final[best] {
+			best = n
+		}
+	}
+	if final[best] <= 0 {
+		return nil
+	}
+
+	if parent := best.Parent; parent != nil && parent.Type == html.ElementNode && parent.Data != "html" {
+		strongSiblings := 0
+		for c := parent.FirstChild; c != nil; c = c.NextSibling {
+			if c != best && final[c] >= final[best]*0.3 {
+				strongSiblings++
+			}
+		}
+		if strongSiblings > 0 {
+			best = parent
+		}
+	}
+	return goquery.NewDocumentFromNode(best).Selection
+}
+
+func largestByText(sel *goquery.Selection) *goquery.Selection {
+	var best *goquery.Selection
+	bestLen := 0
+	sel.Each(func(_ int, s *goquery.Selection) {
+		if n := textRunes(s); n > bestLen {
+			best, bestLen = s, n
+		}
+	})
+	return best
+}
+
+// linkDensity 链接文字占全部文字的比例
+func linkDensity(s *goquery.Selection) float64 {
+	total := textRunes(s)
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		links += textRunes(a)
+	})
+	return float64(links) / float64(total)
+}
+
+func classWeight(s *goquery.Selection) float64 {
+	hint := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+	weight := 0.0
+	if positiveHintPattern.MatchString(hint) {
+		weight += 25
+	}
+	if negativeHintPattern.MatchString(hint) {
+		weight -= 25
+	}
+	return weight
+}
+
+func textRunes(s *goquery.Selection) int {
+	return utf8.RuneCountInString(collapseSpaces(s.Text()))
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// blockText 提取节点文本：块级元素之间换行、段落之间空行，行内空白合并为一个空格
+func blockText(sel *goquery.Selection) string {
+	var b strings.Builder
+	for _, n := range sel.Nodes {
+		writeNodeText(&b, n)
+	}
+	lines := strings.Split(b.String(), "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = collapseSpaces(line)
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func writeNodeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		// 源码中的换行只是空白
+		b.WriteString(strings.NewReplacer("\r", " ", "\n", " ", "\t", " ").Replace(n.Data))
+		return
+	case html.CommentNode:
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "br":
+			b.WriteString("\n")
+			return
+		case "td", "th":
+			b.WriteString(" ")
+		}
+	}
+	block := n.Type == html.ElementNode && blockElements[n.Data]
+	// 标题与段落前后空行，其余块级元素换行
+	sep := "\n"
+	if block && (n.Data == "p" || len(n.Data) == 2 && n.Data[0] == 'h' && n.Data[1] >= '1' && n.Data[1] <= '6') {
+		sep = "\n\n"
+	}
+	if block {
+		b.WriteString(sep)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeNodeText(b, c)
+	}
+	if block {
+		b.WriteString(sep)
+	}
+}
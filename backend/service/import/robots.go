@@ -0,0 +1,142 @@
+package import_service
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules robots.txt 中适用于本爬虫的规则（RFC 9309：最长匹配优先，长度相同时 Allow 优先）
+type robotsRules struct {
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	disallowAll bool // robots.txt 无法访问（5xx / 网络错误）时按全部禁止处理
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+	delay  time.Duration
+}
+
+// parseRobots 解析 robots.txt：优先使用 User-agent 与本爬虫产品名一致的分组，否则使用 * 分组
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var (
+		groups  []*robotsGroup
+		current *robotsGroup
+	)
+	scanner := bufio.NewScanner(io.LimitReader(r, 512<<10))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			// 连续的 User-agent 行属于同一分组
+			if current == nil || len(current.rules) > 0 || current.delay > 0 {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			})
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				current.delay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+
+	match := func(want func(agent string) bool) *robotsRules {
+		var out *robotsRules
+		for _, g := range groups {
+			for _, agent := range g.agents {
+				if want(agent) {
+					if out == nil {
+						out = &robotsRules{}
+					}
+					out.rules = append(out.rules, g.rules...)
+					if g.delay > out.crawlDelay {
+						out.crawlDelay = g.delay
+					}
+					break
+				}
+			}
+		}
+		return out
+	}
+	if rules := match(func(agent string) bool { return agent != "*" && agent == token }); rules != nil {
+		return rules
+	}
+	if rules := match(func(agent string) bool { return agent == "*" }); rules != nil {
+		return rules
+	}
+	return &robotsRules{}
+}
+
+// robotsPattern 将路径规则转为正则：* 匹配任意字符，结尾的 $ 表示路径结束
+func robotsPattern(value string) *regexp.Regexp {
+	anchored := strings.HasSuffix(value, "$")
+	value = strings.TrimSuffix(value, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(value), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed 判断路径（含查询串）是否允许抓取
+func (r *robotsRules) allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	if r.disallowAll {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	best := -1
+	allow := true
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			best = rule.length
+			allow = rule.allow
+		}
+	}
+	return allow
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/2930134478/AI-CS/backend/models"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -48,45 +49,117 @@ func (p *URLParser) Parse(url string) (*ParsedDocument, error) {
 	if err != nil {
 		return nil, fmt.Errorf("解析 HTML 失败: %w", err)
 	}
+	parsed := parseHTMLDocument(doc, url)
 
+	if parsed.Content == "" {
+		// 如果 body 为空，尝试重新下载
+		resp2, err := p.client.Get(url)
+		if err == nil {
+			defer resp2.Body.Close()
+			bodyBytes, _ := io.ReadAll(resp2.Body)
+			parsed.Content = strings.TrimSpace(string(bodyBytes))
+		}
+	}
+	return parsed, nil
+}
+
+// parseHTMLDocument 提取网页标题与正文：正文去除导航、页眉页脚、侧栏等模板内容，按块级元素分行，
+// 并按正文中的标题划分章节锚点。会修改 doc（移除模板内容），需要页面链接时应先行提取。
+func parseHTMLDocument(doc *goquery.Document, pageURL string) *ParsedDocument {
 	// 提取标题
-	title := doc.Find("title").First().Text()
+	title := collapseSpaces(doc.Find("title").First().Text())
 	if title == "" {
-		title = doc.Find("h1").First().Text()
+		title = collapseSpaces(doc.Find("h1").First().Text())
 	}
 	if title == "" {
-		title = url
+		title = pageURL
 	}
 
 	// 提取正文内容
-	var content strings.Builder
-	doc.Find("body").Each(func(i int, s *goquery.Selection) {
-		// 移除脚本和样式
-		s.Find("script, style").Remove()
-		// 提取文本
-		text := s.Text()
-		content.WriteString(text)
-		content.WriteString("\n")
-	})
-
-	body := content.String()
-	if body == "" {
-		// 如果 body 为空，尝试重新下载
-		if body == "" {
-			resp2, err := p.client.Get(url)
-			if err == nil {
-				defer resp2.Body.Close()
-				bodyBytes, _ := io.ReadAll(resp2.Body)
-				body = string(bodyBytes)
-			}
-		}
+	main := extractMainContent(doc)
+	text := blockText(main)
+	var sections []models.DocumentSourceSection
+	if text != "" {
+		sections = urlSections(main, text, pageURL)
 	}
 
 	return &ParsedDocument{
-		Title:    strings.TrimSpace(title),
-		Content:  strings.TrimSpace(body),
+		Title:   title,
+		Content: text,
 		Metadata: map[string]interface{}{
-			"url": url,
+			"url": pageURL,
 		},
-	}, nil
+		Sections: sections,
+	}
+}
+
+// urlSections 按正文中的 h1~h6 标题划分区间：标题（或其中的锚点）带 id 时锚点为 URL#id，否则为页面 URL
+func urlSections(root *goquery.Selection, content, pageURL string) []models.DocumentSourceSection {
+	base := pageURL
+	if i := strings.Index(base, "#"); i >= 0 {
+		base = base[:i]
+	}
+	type heading struct {
+		level int
+		title string
+	}
+	var (
+		sections []models.DocumentSourceSection
+		headings []heading
+		cursor   int
+	)
+	root.Find("h1, h2, h3, h4, h5, h6").Each(func(_ int, h *goquery.Selection) {
+		title := collapseSpaces(h.Text())
+		if title == "" {
+			return
+		}
+		// 标题文本在正文中按顺序出现，从上一个标题之后查找
+		idx := strings.Index(content[cursor:], title)
+		if idx < 0 {
+			return
+		}
+		start := cursor + idx
+		if n := len(sections); n > 0 {
+			sections[n-1].End = start
+		} else if start > 0 {
+			sections = append(sections, models.DocumentSourceSection{Start: 0, End: start, Anchor: base})
+		}
+
+		level := int(goquery.NodeName(h)[1] - '0')
+		for len(headings) > 0 && headings[len(headings)-1].level >= level {
+			headings = headings[:len(headings)-1]
+		}
+		headings = append(headings, heading{level: level, title: title})
+		path := make([]string, len(headings))
+		for i, hd := range headings {
+			path[i] = hd.title
+		}
+		anchor := base
+		if id := headingAnchorID(h); id != "" {
+			anchor = base + "#" + id
+		}
+		sections = append(sections, models.DocumentSourceSection{Start: start, HeadingPath: path, Anchor: anchor})
+		cursor = start + len(title)
+	})
+	if n := len(sections); n > 0 {
+		sections[n-1].End = len(content)
+	} else if content != "" {
+		sections = append(sections, models.DocumentSourceSection{Start: 0, End: len(content), Anchor: base})
+	}
+	return sections
+}
+
+// headingAnchorID 标题可用作页面片段的 id（标题自身或其中 <a id/name>）
+func headingAnchorID(h *goquery.Selection) string {
+	if id, ok := h.Attr("id"); ok && strings.TrimSpace(id) != "" {
+		return strings.TrimSpace(id)
+	}
+	a := h.Find("a[id], a[name]").First()
+	if id, ok := a.Attr("id"); ok && strings.TrimSpace(id) != "" {
+		return strings.TrimSpace(id)
+	}
+	if name, ok := a.Attr("name"); ok && strings.TrimSpace(name) != "" {
+		return strings.TrimSpace(name)
+	}
+	return ""
 }
@@ -6,9 +6,16 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/2930134478/AI-CS/backend/models"
 )
 
+// docxHeadingStylePattern 标题段落样式 ID：Heading1 / heading 1 / 标题 1，中文版 Word 的内置标题样式 ID 为纯数字
+var docxHeadingStylePattern = regexp.MustCompile(`(?i)^(?:heading\s*|标题\s*)?([1-9])$`)
+
 // WordParser Word 解析器（直接解析 .docx ZIP/XML）
 type WordParser struct{}
 
@@ -60,6 +67,14 @@ func (p *WordParser) Parse(filePath string) (*ParsedDocument, error) {
 	var doc struct {
 		Body struct {
 			Paragraphs []struct {
+				Props struct {
+					Style struct {
+						Val string `xml:"val,attr"`
+					} `xml:"pStyle"`
+					OutlineLevel *struct {
+						Val string `xml:"val,attr"`
+					} `xml:"outlineLvl"`
+				} `xml:"pPr"`
 				Runs []struct {
 					Text string `xml:"t"`
 				} `xml:"r"`
@@ -70,7 +85,16 @@ func (p *WordParser) Parse(filePath string) (*ParsedDocument, error) {
 		return nil, fmt.Errorf("解析文档 XML 失败: %w", err)
 	}
 
-	var paragraphs []string
+	type heading struct {
+		level int
+		title string
+	}
+	var (
+		paragraphs []string
+		sections   []models.DocumentSourceSection
+		headings   []heading
+		offset     int // 已写入内容的字节长度（段落之间以空行连接）
+	)
 	for _, p := range doc.Body.Paragraphs {
 		var texts []string
 		for _, run := range p.Runs {
@@ -79,9 +103,36 @@ func (p *WordParser) Parse(filePath string) (*ParsedDocument, error) {
 			}
 		}
 		line := strings.TrimSpace(strings.Join(texts, ""))
-		if line != "" {
-			paragraphs = append(paragraphs, line)
+		if line == "" {
+			continue
+		}
+		if len(paragraphs) > 0 {
+			offset += 2
+		}
+		outline := ""
+		if p.Props.OutlineLevel != nil {
+			outline = p.Props.OutlineLevel.Val
+		}
+		// 标题段落开启新的区间，标题路径为各级标题
+		if level := docxHeadingLevel(p.Props.Style.Val, outline); level > 0 {
+			if n := len(sections); n > 0 {
+				sections[n-1].End = offset
+			}
+			for len(headings) > 0 && headings[len(headings)-1].level >= level {
+				headings = headings[:len(headings)-1]
+			}
+			headings = append(headings, heading{level: level, title: line})
+			path := make([]string, len(headings))
+			for i, h := range headings {
+				path[i] = h.title
+			}
+			sections = append(sections, models.DocumentSourceSection{Start: offset, HeadingPath: path})
 		}
+		paragraphs = append(paragraphs, line)
+		offset += len(line)
+	}
+	if n := len(sections); n > 0 {
+		sections[n-1].End = offset
 	}
 
 	if len(paragraphs) == 0 {
@@ -102,5 +153,19 @@ func (p *WordParser) Parse(filePath string) (*ParsedDocument, error) {
 		Title:    title,
 		Content:  content,
 		Metadata: map[string]interface{}{"source": "word"},
+		Sections: sections,
 	}, nil
 }
+
+// docxHeadingLevel 根据段落样式或大纲级别判断标题级别（1~9），正文返回 0
+func docxHeadingLevel(styleID, outlineLevel string) int {
+	if m := docxHeadingStylePattern.FindStringSubmatch(strings.TrimSpace(styleID)); m != nil {
+		level, _ := strconv.Atoi(m[1])
+		return level
+	}
+	// w:outlineLvl 取值 0~8 为标题级别，9 为正文
+	if lvl, err := strconv.Atoi(outlineLevel); err == nil && lvl >= 0 && lvl < 9 {
+		return lvl + 1
+	}
+	return 0
+}
@@ -95,6 +95,8 @@ func (s *ImportService) ImportFiles(ctx context.Context, knowledgeBaseID uint, f
 			Type:            "document",
 			Status:          "draft",
 			EmbeddingStatus: "pending",
+			SourceName:      filepath.Base(filePath),
+			SourceMap:       parsed.Sections,
 		}
 
 		documents = append(documents, doc)
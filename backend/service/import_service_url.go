@@ -56,6 +56,8 @@ func (s *ImportService) ImportFromUrls(ctx context.Context, knowledgeBaseID uint
 			Type:            "url",
 			Status:          "draft",
 			EmbeddingStatus: "pending",
+			SourceName:      url,
+			SourceMap:       parsed.Sections,
 		}
 
 		documents = append(documents, doc)
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"github.com/2930134478/AI-CS/backend/utils"
+)
+
+// KnowledgeBaseBindingService 挂件 / 站点与知识库集合的绑定管理，以及会话知识库范围解析。
+type KnowledgeBaseBindingService struct {
+	bindings *repository.KnowledgeBaseBindingRepository
+	kbRepo   *repository.KnowledgeBaseRepository
+}
+
+// NewKnowledgeBaseBindingService 创建绑定服务实例
+func NewKnowledgeBaseBindingService(bindings *repository.KnowledgeBaseBindingRepository, kbRepo *repository.KnowledgeBaseRepository) *KnowledgeBaseBindingService {
+	return &KnowledgeBaseBindingService{
+		bindings: bindings,
+		kbRepo:   kbRepo,
+	}
+}
+
+// KnowledgeBaseBindingInput 创建 / 更新绑定的输入。
+type KnowledgeBaseBindingInput struct {
+	Name             string
+	WidgetKey        string
+	SiteHost         string
+	KnowledgeBaseIDs []uint
+	Enabled          *bool
+}
+
+// ListBindings 获取全部绑定
+func (s *KnowledgeBaseBindingService) ListBindings() ([]models.KnowledgeBaseBinding, error) {
+	return s.bindings.List()
+}
+
+// CreateBinding 创建绑定
+func (s *KnowledgeBaseBindingService) CreateBinding(input KnowledgeBaseBindingInput) (*models.KnowledgeBaseBinding, error) {
+	b := &models.KnowledgeBaseBinding{Enabled: true}
+	if err := s.applyInput(b, input); err != nil {
+		return nil, err
+	}
+	if err := s.bindings.Create(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UpdateBinding 更新绑定
+func (s *KnowledgeBaseBindingService) UpdateBinding(id uint, input KnowledgeBaseBindingInput) (*models.KnowledgeBaseBinding, error) {
+	b, err := s.bindings.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyInput(b, input); err != nil {
+		return nil, err
+	}
+	if err := s.bindings.Update(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// DeleteBinding 删除绑定（已创建会话的知识库范围不受影响）
+func (s *KnowledgeBaseBindingService) DeleteBinding(id uint) error {
+	return s.bindings.Delete(id)
+}
+
+func (s *KnowledgeBaseBindingService) applyInput(b *models.KnowledgeBaseBinding, input KnowledgeBaseBindingInput) error {
+	widgetKey := strings.TrimSpace(input.WidgetKey)
+	siteHost := strings.ToLower(strings.TrimSpace(input.SiteHost))
+	if widgetKey == "" && siteHost == "" {
+		return errors.New("挂件 key 与站点域名至少填写一项")
+	}
+	if len(input.KnowledgeBaseIDs) == 0 {
+		return errors.New("请至少选择一个知识库")
+	}
+	if err := s.ValidateKnowledgeBaseIDs(input.KnowledgeBaseIDs); err != nil {
+		return err
+	}
+	b.Name = strings.TrimSpace(input.Name)
+	b.WidgetKey = widgetKey
+	b.SiteHost = siteHost
+	b.KnowledgeBaseIDs = utils.JoinUintList(input.KnowledgeBaseIDs)
+	if input.Enabled != nil {
+		b.Enabled = *input.Enabled
+	}
+	return nil
+}
+
+// ValidateKnowledgeBaseIDs 校验知识库均存在
+func (s *KnowledgeBaseBindingService) ValidateKnowledgeBaseIDs(ids []uint) error {
+	ids = utils.ParseUintList(utils.JoinUintList(ids))
+	if len(ids) == 0 {
+		return nil
+	}
+	kbs, err := s.kbRepo.GetByIDs(ids)
+	if err != nil {
+		return err
+	}
+	if len(kbs) != len(ids) {
+		return errors.New("知识库不存在")
+	}
+	return nil
+}
+
+// ResolveScope 解析会话的知识库范围，优先级：显式 knowledge_base_ids > 挂件 key > 站点域名。
+// 返回 nil 表示不限定（检索全部参与 RAG 的知识库）。
+func (s *KnowledgeBaseBindingService) ResolveScope(explicitIDs []uint, widgetKey string, website string) ([]uint, error) {
+	if len(explicitIDs) > 0 {
+		if err := s.ValidateKnowledgeBaseIDs(explicitIDs); err != nil {
+			return nil, err
+		}
+		return utils.ParseUintList(utils.JoinUintList(explicitIDs)), nil
+	}
+	widgetKey = strings.TrimSpace(widgetKey)
+	host := hostFromWebsite(website)
+	if widgetKey == "" && host == "" {
+		return nil, nil
+	}
+	list, err := s.bindings.ListEnabled()
+	if err != nil {
+		return nil, err
+	}
+	if widgetKey != "" {
+		for _, b := range list {
+			if b.WidgetKey == widgetKey {
+				return utils.ParseUintList(b.KnowledgeBaseIDs), nil
+			}
+		}
+	}
+	if host == "" {
+		return nil, nil
+	}
+	// 站点匹配：精确域名优先，其次取后缀最长的 ".example.com" 通配
+	var best *models.KnowledgeBaseBinding
+	for i := range list {
+		b := &list[i]
+		if b.SiteHost == "" {
+			continue
+		}
+		if b.SiteHost == host {
+			return utils.ParseUintList(b.KnowledgeBaseIDs), nil
+		}
+		if strings.HasPrefix(b.SiteHost, ".") && (strings.HasSuffix(host, b.SiteHost) || host == strings.TrimPrefix(b.SiteHost, ".")) {
+			if best == nil || len(b.SiteHost) > len(best.SiteHost) {
+				best = b
+			}
+		}
+	}
+	if best != nil {
+		return utils.ParseUintList(best.KnowledgeBaseIDs), nil
+	}
+	return nil, nil
+}
+
+// hostFromWebsite 从访客当前页面 URL 中提取小写域名（不含端口）
+func hostFromWebsite(website string) string {
+	website = strings.TrimSpace(website)
+	if website == "" {
+		return ""
+	}
+	if !strings.Contains(website, "://") {
+		website = "http://" + website
+	}
+	u, err := url.Parse(website)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
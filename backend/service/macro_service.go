@@ -0,0 +1,551 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"gorm.io/gorm"
+)
+
+// 快捷回复可见范围
+const (
+	MacroScopePersonal = "personal" // 仅创建者可见
+	MacroScopeTeam     = "team"     // 全体客服共享
+)
+
+// 快捷回复发送后可执行的动作
+const (
+	MacroActionClose  = "close"   // 关闭会话
+	MacroActionAddTag = "add_tag" // 为会话添加标签（value 为标签名）
+)
+
+// ErrMacroNotFound 快捷回复不存在或对当前用户不可见
+var ErrMacroNotFound = errors.New("快捷回复不存在")
+
+// macroVariablePattern 匹配 {{ namespace.field }} 形式的模板变量
+var macroVariablePattern = regexp.MustCompile(`\{\{\s*([a-zA-Z_]+\.[a-zA-Z_]+)\s*\}\}`)
+
+// MacroAction 快捷回复发送后执行的动作
+type MacroAction struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// MacroActionResult 动作执行结果
+type MacroActionResult struct {
+	Type    string `json:"type"`
+	Value   string `json:"value,omitempty"`
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ConversationTagger 为会话添加标签的能力（由标签服务实现；未注入时 add_tag 动作跳过）
+type ConversationTagger interface {
+	AddTagByName(conversationID uint, name string, operatorID uint) error
+}
+
+// MacroResult 快捷回复详情（actions 已解码）
+type MacroResult struct {
+	ID         uint          `json:"id"`
+	Title      string        `json:"title"`
+	Shortcut   string        `json:"shortcut"`
+	Content    string        `json:"content"`
+	FolderID   *uint         `json:"folder_id"`
+	Scope      string        `json:"scope"`
+	OwnerID    uint          `json:"owner_id"`
+	Actions    []MacroAction `json:"actions"`
+	UsageCount int           `json:"usage_count"`
+	CreatedAt  string        `json:"created_at"`
+	UpdatedAt  string        `json:"updated_at"`
+}
+
+// MacroInput 创建 / 更新快捷回复的输入（更新时 nil 表示不修改）
+type MacroInput struct {
+	Title    *string
+	Shortcut *string
+	Content  *string
+	FolderID *uint // 0 表示移出分组
+	Scope    *string
+	Actions  *[]MacroAction
+}
+
+// MacroFolderInput 创建 / 更新分组的输入
+type MacroFolderInput struct {
+	Name      *string
+	Scope     *string
+	SortOrder *int
+}
+
+// RenderedMacro 按会话解析变量后的快捷回复
+type RenderedMacro struct {
+	MacroID uint          `json:"macro_id"`
+	Content string        `json:"content"`
+	Actions []MacroAction `json:"actions"`
+	// Unresolved 模板中无法识别的变量（原样保留在内容中）
+	Unresolved []string `json:"unresolved"`
+}
+
+// SendMacroResult 发送快捷回复的结果
+type SendMacroResult struct {
+	Message *models.Message     `json:"message"`
+	Actions []MacroActionResult `json:"actions"`
+}
+
+// MacroService 负责快捷回复（宏）：个人 / 团队共享、分组、快捷指令、变量解析与发送后动作。
+type MacroService struct {
+	macros          *repository.MacroRepository
+	conversations   *repository.ConversationRepository
+	users           *repository.UserRepository
+	messageSvc      *MessageService
+	conversationSvc *ConversationService
+	tagger          ConversationTagger // 可选，add_tag 动作
+}
+
+// NewMacroService 创建快捷回复服务实例。
+func NewMacroService(
+	macros *repository.MacroRepository,
+	conversations *repository.ConversationRepository,
+	users *repository.UserRepository,
+	messageSvc *MessageService,
+	conversationSvc *ConversationService,
+) *MacroService {
+	return &MacroService{
+		macros:          macros,
+		conversations:   conversations,
+		users:           users,
+		messageSvc:      messageSvc,
+		conversationSvc: conversationSvc,
+	}
+}
+
+// SetConversationTagger 注入会话标签能力（启用 add_tag 动作）
+func (s *MacroService) SetConversationTagger(tagger ConversationTagger) {
+	s.tagger = tagger
+}
+
+// ListMacros 列出用户可见的快捷回复（个人 + 团队），可按分组与关键词过滤。
+func (s *MacroService) ListMacros(userID uint, folderID *uint, keyword string) ([]MacroResult, error) {
+	macros, err := s.macros.ListVisible(userID, folderID, strings.TrimSpace(keyword))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MacroResult, 0, len(macros))
+	for i := range macros {
+		out = append(out, toMacroResult(&macros[i]))
+	}
+	return out, nil
+}
+
+// GetMacro 获取用户可见的快捷回复
+func (s *MacroService) GetMacro(id uint, userID uint) (*MacroResult, error) {
+	macro, err := s.getVisible(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := toMacroResult(macro)
+	return &result, nil
+}
+
+// CreateMacro 创建快捷回复
+func (s *MacroService) CreateMacro(userID uint, input MacroInput) (*MacroResult, error) {
+	macro := &models.Macro{Scope: MacroScopePersonal, OwnerID: userID}
+	if err := s.applyMacroInput(macro, input, userID); err != nil {
+		return nil, err
+	}
+	if macro.Title == "" || strings.TrimSpace(macro.Content) == "" {
+		return nil, errors.New("标题和内容不能为空")
+	}
+	if err := s.macros.Create(macro); err != nil {
+		return nil, err
+	}
+	result := toMacroResult(macro)
+	return &result, nil
+}
+
+// UpdateMacro 更新快捷回复（个人宏仅创建者可改；团队宏具备宏管理权限者均可改）
+func (s *MacroService) UpdateMacro(id uint, userID uint, input MacroInput) (*MacroResult, error) {
+	macro, err := s.getVisible(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyMacroInput(macro, input, userID); err != nil {
+		return nil, err
+	}
+	if macro.Title == "" || strings.TrimSpace(macro.Content) == "" {
+		return nil, errors.New("标题和内容不能为空")
+	}
+	if err := s.macros.Update(macro); err != nil {
+		return nil, err
+	}
+	result := toMacroResult(macro)
+	return &result, nil
+}
+
+// DeleteMacro 删除快捷回复
+func (s *MacroService) DeleteMacro(id uint, userID uint) error {
+	if _, err := s.getVisible(id, userID); err != nil {
+		return err
+	}
+	return s.macros.Delete(id)
+}
+
+// ListFolders 列出用户可见的分组
+func (s *MacroService) ListFolders(userID uint) ([]models.MacroFolder, error) {
+	return s.macros.ListVisibleFolders(userID)
+}
+
+// CreateFolder 创建分组
+func (s *MacroService) CreateFolder(userID uint, input MacroFolderInput) (*models.MacroFolder, error) {
+	folder := &models.MacroFolder{Scope: MacroScopePersonal, OwnerID: userID}
+	if err := applyMacroFolderInput(folder, input); err != nil {
+		return nil, err
+	}
+	if folder.Name == "" {
+		return nil, errors.New("分组名称不能为空")
+	}
+	if err := s.macros.CreateFolder(folder); err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// UpdateFolder 更新分组
+func (s *MacroService) UpdateFolder(id uint, userID uint, input MacroFolderInput) (*models.MacroFolder, error) {
+	folder, err := s.getVisibleFolder(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyMacroFolderInput(folder, input); err != nil {
+		return nil, err
+	}
+	if folder.Name == "" {
+		return nil, errors.New("分组名称不能为空")
+	}
+	if err := s.macros.UpdateFolder(folder); err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// DeleteFolder 删除分组（其中的快捷回复移出分组，不会被删除）
+func (s *MacroService) DeleteFolder(id uint, userID uint) error {
+	if _, err := s.getVisibleFolder(id, userID); err != nil {
+		return err
+	}
+	if err := s.macros.ClearFolder(id); err != nil {
+		return err
+	}
+	return s.macros.DeleteFolder(id)
+}
+
+// Render 按会话解析快捷回复中的变量（如 {{visitor.email}}、{{agent.nickname}}、{{conversation.website}}）
+func (s *MacroService) Render(id uint, conversationID uint, agentID uint) (*RenderedMacro, error) {
+	macro, err := s.getVisible(id, agentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.render(macro, conversationID, agentID)
+}
+
+// RenderByShortcut 按快捷指令解析快捷回复（输入框 /shortcut 触发）
+func (s *MacroService) RenderByShortcut(shortcut string, conversationID uint, agentID uint) (*RenderedMacro, error) {
+	shortcut = strings.TrimPrefix(strings.TrimSpace(shortcut), "/")
+	if shortcut == "" {
+		return nil, ErrMacroNotFound
+	}
+	macro, err := s.macros.FindVisibleByShortcut(agentID, shortcut)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMacroNotFound
+		}
+		return nil, err
+	}
+	return s.render(macro, conversationID, agentID)
+}
+
+// Send 解析并以客服身份发送快捷回复，随后依次执行发送后动作（动作失败不影响已发送的消息）。
+// content 非空时使用客服编辑后的内容（仍会解析其中的变量）。
+func (s *MacroService) Send(id uint, conversationID uint, agentID uint, content string) (*SendMacroResult, error) {
+	macro, err := s.getVisible(id, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) != "" {
+		edited := *macro
+		edited.Content = content
+		macro = &edited
+	}
+	rendered, err := s.render(macro, conversationID, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rendered.Content) == "" {
+		return nil, errors.New("消息内容不能为空")
+	}
+	message, err := s.messageSvc.CreateMessage(CreateMessageInput{
+		ConversationID: conversationID,
+		Content:        rendered.Content,
+		SenderID:       agentID,
+		SenderIsAgent:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.macros.IncrementUsage(id); err != nil {
+		log.Printf("⚠️ 更新快捷回复使用次数失败: macro_id=%d err=%v", id, err)
+	}
+
+	results := make([]MacroActionResult, 0, len(rendered.Actions))
+	for _, action := range rendered.Actions {
+		results = append(results, s.applyAction(action, conversationID, agentID))
+	}
+	return &SendMacroResult{Message: message, Actions: results}, nil
+}
+
+// applyAction 执行单个发送后动作
+func (s *MacroService) applyAction(action MacroAction, conversationID uint, agentID uint) MacroActionResult {
+	result := MacroActionResult{Type: action.Type, Value: action.Value}
+	var err error
+	switch action.Type {
+	case MacroActionClose:
+		err = s.conversationSvc.CloseConversation(conversationID, agentID)
+	case MacroActionAddTag:
+		if s.tagger == nil {
+			err = errors.New("未启用会话标签")
+		} else {
+			err = s.tagger.AddTagByName(conversationID, action.Value, agentID)
+		}
+	default:
+		err = errors.New("不支持的动作: " + action.Type)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		log.Printf("⚠️ 快捷回复动作执行失败: conversation_id=%d action=%s err=%v", conversationID, action.Type, err)
+		return result
+	}
+	result.Applied = true
+	return result
+}
+
+// render 解析模板变量；无法识别的变量原样保留并在 Unresolved 中返回
+func (s *MacroService) render(macro *models.Macro, conversationID uint, agentID uint) (*RenderedMacro, error) {
+	conv, err := s.conversations.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	// 内部会话仅创建者可用（与 GetConversationDetail 一致），他人视为不存在
+	if conv.ConversationType == "internal" && conv.AgentID != agentID {
+		return nil, ErrConversationNotFound
+	}
+	agent, err := s.users.GetByID(agentID)
+	if err != nil {
+		return nil, err
+	}
+	vars := macroVariables(conv, agent)
+
+	unresolved := make([]string, 0)
+	content := macroVariablePattern.ReplaceAllStringFunc(macro.Content, func(m string) string {
+		key := strings.ToLower(macroVariablePattern.FindStringSubmatch(m)[1])
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		unresolved = append(unresolved, key)
+		return m
+	})
+	return &RenderedMacro{
+		MacroID:    macro.ID,
+		Content:    content,
+		Actions:    decodeMacroActions(macro.Actions),
+		Unresolved: unresolved,
+	}, nil
+}
+
+// macroVariables 会话与客服可用于模板的变量
+func macroVariables(conv *models.Conversation, agent *models.User) map[string]string {
+	nickname := agent.Nickname
+	if nickname == "" {
+		nickname = agent.Username
+	}
+	return map[string]string{
+		"visitor.id":            strconv.FormatUint(uint64(conv.VisitorID), 10),
+		"visitor.email":         conv.Email,
+		"visitor.phone":         conv.Phone,
+		"visitor.location":      conv.Location,
+		"visitor.language":      conv.Language,
+		"visitor.browser":       conv.Browser,
+		"visitor.os":            conv.OS,
+		"agent.id":              strconv.FormatUint(uint64(agent.ID), 10),
+		"agent.nickname":        nickname,
+		"agent.username":        agent.Username,
+		"agent.email":           agent.Email,
+		"conversation.id":       strconv.FormatUint(uint64(conv.ID), 10),
+		"conversation.website":  conv.Website,
+		"conversation.referrer": conv.Referrer,
+		"conversation.status":   conv.Status,
+	}
+}
+
+// getVisible 读取用户可见的快捷回复（他人的个人宏视为不存在）
+func (s *MacroService) getVisible(id uint, userID uint) (*models.Macro, error) {
+	macro, err := s.macros.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMacroNotFound
+		}
+		return nil, err
+	}
+	if macro.Scope != MacroScopeTeam && macro.OwnerID != userID {
+		return nil, ErrMacroNotFound
+	}
+	return macro, nil
+}
+
+func (s *MacroService) getVisibleFolder(id uint, userID uint) (*models.MacroFolder, error) {
+	folder, err := s.macros.GetFolderByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("分组不存在")
+		}
+		return nil, err
+	}
+	if folder.Scope != MacroScopeTeam && folder.OwnerID != userID {
+		return nil, errors.New("分组不存在")
+	}
+	return folder, nil
+}
+
+// applyMacroInput 校验并写入快捷回复字段
+func (s *MacroService) applyMacroInput(macro *models.Macro, input MacroInput, userID uint) error {
+	if input.Title != nil {
+		macro.Title = strings.TrimSpace(*input.Title)
+	}
+	if input.Content != nil {
+		macro.Content = *input.Content
+	}
+	if input.Shortcut != nil {
+		shortcut := strings.TrimPrefix(strings.TrimSpace(*input.Shortcut), "/")
+		if strings.ContainsAny(shortcut, " \t\n") || len(shortcut) > 50 {
+			return errors.New("快捷指令不能包含空白且不超过 50 个字符")
+		}
+		macro.Shortcut = shortcut
+	}
+	if input.Scope != nil {
+		scope, err := normalizeMacroScope(*input.Scope)
+		if err != nil {
+			return err
+		}
+		macro.Scope = scope
+	}
+	if input.FolderID != nil {
+		if *input.FolderID == 0 {
+			macro.FolderID = nil
+		} else {
+			if _, err := s.getVisibleFolder(*input.FolderID, userID); err != nil {
+				return err
+			}
+			folderID := *input.FolderID
+			macro.FolderID = &folderID
+		}
+	}
+	if input.Actions != nil {
+		encoded, err := encodeMacroActions(*input.Actions)
+		if err != nil {
+			return err
+		}
+		macro.Actions = encoded
+	}
+	return nil
+}
+
+func applyMacroFolderInput(folder *models.MacroFolder, input MacroFolderInput) error {
+	if input.Name != nil {
+		folder.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Scope != nil {
+		scope, err := normalizeMacroScope(*input.Scope)
+		if err != nil {
+			return err
+		}
+		folder.Scope = scope
+	}
+	if input.SortOrder != nil {
+		folder.SortOrder = *input.SortOrder
+	}
+	return nil
+}
+
+func normalizeMacroScope(v string) (string, error) {
+	switch strings.TrimSpace(v) {
+	case "", MacroScopePersonal:
+		return MacroScopePersonal, nil
+	case MacroScopeTeam:
+		return MacroScopeTeam, nil
+	}
+	return "", errors.New("scope 只能是 personal 或 team")
+}
+
+// encodeMacroActions 校验并编码发送后动作
+func encodeMacroActions(actions []MacroAction) (string, error) {
+	if len(actions) == 0 {
+		return "", nil
+	}
+	out := make([]MacroAction, 0, len(actions))
+	for _, a := range actions {
+		a.Type = strings.TrimSpace(a.Type)
+		a.Value = strings.TrimSpace(a.Value)
+		switch a.Type {
+		case MacroActionClose:
+			a.Value = ""
+		case MacroActionAddTag:
+			if a.Value == "" {
+				return "", errors.New("add_tag 动作需要指定标签")
+			}
+		default:
+			return "", fmt.Errorf("不支持的动作: %s", a.Type)
+		}
+		out = append(out, a)
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// decodeMacroActions 解码发送后动作，空串 / 无效 JSON 视为无动作
+func decodeMacroActions(raw string) []MacroAction {
+	actions := make([]MacroAction, 0)
+	if strings.TrimSpace(raw) == "" {
+		return actions
+	}
+	if err := json.Unmarshal([]byte(raw), &actions); err != nil {
+		return make([]MacroAction, 0)
+	}
+	return actions
+}
+
+func toMacroResult(m *models.Macro) MacroResult {
+	return MacroResult{
+		ID:         m.ID,
+		Title:      m.Title,
+		Shortcut:   m.Shortcut,
+		Content:    m.Content,
+		FolderID:   m.FolderID,
+		Scope:      m.Scope,
+		OwnerID:    m.OwnerID,
+		Actions:    decodeMacroActions(m.Actions),
+		UsageCount: m.UsageCount,
+		CreatedAt:  m.CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:  m.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
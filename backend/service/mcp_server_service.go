@@ -0,0 +1,710 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/infra/mcp"
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+	"github.com/2930134478/AI-CS/backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	mcpConnectTimeout  = 15 * time.Second
+	mcpToolCallTimeout = 30 * time.Second
+	// mcpHealthInterval 后台巡检间隔：Ping 失败时重连并刷新工具列表
+	mcpHealthInterval = 30 * time.Second
+	// mcpToolNamePrefix 暴露给模型的工具名前缀（HTTP 工具不可占用）
+	mcpToolNamePrefix = "mcp_"
+)
+
+var (
+	mcpServerNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,31}$`)
+	mcpToolNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// ErrMCPServerNotFound MCP 服务不存在
+var ErrMCPServerNotFound = errors.New("MCP 服务不存在")
+
+// MCPServerInput 新增 / 修改 MCP 服务的参数
+type MCPServerInput struct {
+	Name         string
+	Description  string
+	Transport    string
+	URL          string
+	AuthHeader   string
+	AuthValue    *string // 明文；修改时 nil 表示保留原值，"" 表示清除
+	Command      string
+	Args         []string
+	Env          map[string]string // nil 表示保留原值
+	EnabledTools []string
+	IsActive     *bool
+}
+
+// MCPServerView MCP 服务返回结构（含连接状态，不含认证信息与环境变量取值）
+type MCPServerView struct {
+	models.MCPServer
+	Args         []string `json:"args"`
+	EnvKeys      []string `json:"env_keys"`
+	EnabledTools []string `json:"enabled_tools"`
+	HasAuthValue bool     `json:"has_auth_value"`
+	MCPServerHealth
+}
+
+// MCPServerHealth 单个 MCP 服务的连接状态
+type MCPServerHealth struct {
+	Connected bool       `json:"connected"`
+	ToolCount int        `json:"tool_count"`
+	LastError string     `json:"last_error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+// MCPServerStatus /health 中展示的服务状态
+type MCPServerStatus struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Transport string `json:"transport"`
+	MCPServerHealth
+}
+
+// MCPToolInfo 服务端工具及是否已提供给模型
+type MCPToolInfo struct {
+	mcp.Tool
+	ExposedName string `json:"exposed_name"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// MCPToolBinding 提供给模型的一个 MCP 工具（exposed name → 服务与原始工具名）
+type MCPToolBinding struct {
+	ExposedName string
+	ServerID    uint
+	ServerName  string
+	ToolName    string
+	Description string
+	InputSchema map[string]interface{}
+}
+
+// mcpConnection 一个 MCP 服务的运行时连接
+type mcpConnection struct {
+	server models.MCPServer
+	client *mcp.Client
+
+	mu        sync.Mutex
+	tools     []mcp.Tool
+	lastErr   string
+	checkedAt time.Time
+}
+
+// MCPServerService 管理员注册的 MCP 服务：维护连接（断开自动重连）、列出工具，
+// 并把选中的工具提供给模型、将模型的 tool_calls 转发给对应服务。
+type MCPServerService struct {
+	repo         *repository.MCPServerRepository
+	systemLogSvc *SystemLogService // 可选
+
+	mu    sync.RWMutex
+	conns map[uint]*mcpConnection
+}
+
+// NewMCPServerService 创建 MCP 服务管理实例。
+func NewMCPServerService(repo *repository.MCPServerRepository, systemLogSvc *SystemLogService) *MCPServerService {
+	return &MCPServerService{
+		repo:         repo,
+		systemLogSvc: systemLogSvc,
+		conns:        make(map[uint]*mcpConnection),
+	}
+}
+
+// mcpStdioEnabled stdio 传输会在服务器上执行命令，须通过 MCP_STDIO_ENABLED=true 显式开启
+func mcpStdioEnabled() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("MCP_STDIO_ENABLED")), "true")
+}
+
+// Start 连接全部已启用的服务，并在后台定期巡检（ctx 取消时关闭全部连接）
+func (s *MCPServerService) Start(ctx context.Context) {
+	servers, err := s.repo.ListActive()
+	if err != nil {
+		log.Printf("⚠️ 加载 MCP 服务失败: %v", err)
+	}
+	for i := range servers {
+		s.connect(servers[i])
+	}
+	go func() {
+		ticker := time.NewTicker(mcpHealthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.closeAll()
+				return
+			case <-ticker.C:
+				s.checkAll()
+			}
+		}
+	}()
+}
+
+// connect 替换某服务的运行时连接并异步建立连接、拉取工具
+func (s *MCPServerService) connect(server models.MCPServer) {
+	s.disconnect(server.ID)
+	if !server.IsActive {
+		return
+	}
+	client, err := s.newClient(&server)
+	conn := &mcpConnection{server: server, client: client}
+	s.mu.Lock()
+	s.conns[server.ID] = conn
+	s.mu.Unlock()
+	if err != nil {
+		conn.setResult(nil, err)
+		log.Printf("⚠️ MCP 服务 %s 配置无效: %v", server.Name, err)
+		return
+	}
+	go s.refresh(conn)
+}
+
+func (s *MCPServerService) disconnect(id uint) {
+	s.mu.Lock()
+	conn := s.conns[id]
+	delete(s.conns, id)
+	s.mu.Unlock()
+	if conn != nil && conn.client != nil {
+		_ = conn.client.Close()
+	}
+}
+
+func (s *MCPServerService) closeAll() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = make(map[uint]*mcpConnection)
+	s.mu.Unlock()
+	for _, conn := range conns {
+		if conn.client != nil {
+			_ = conn.client.Close()
+		}
+	}
+}
+
+func (s *MCPServerService) newClient(server *models.MCPServer) (*mcp.Client, error) {
+	switch server.Transport {
+	case "stdio":
+		if !mcpStdioEnabled() {
+			return nil, errors.New("stdio 传输未开启（需设置 MCP_STDIO_ENABLED=true）")
+		}
+		var args []string
+		if server.Args != "" {
+			_ = json.Unmarshal([]byte(server.Args), &args)
+		}
+		env, err := decryptMCPEnv(server.Env)
+		if err != nil {
+			return nil, err
+		}
+		pairs := make([]string, 0, len(env))
+		for k, v := range env {
+			pairs = append(pairs, k+"="+v)
+		}
+		return mcp.NewCommandClient(server.Command, args, pairs), nil
+	default:
+		var headers map[string]string
+		if server.AuthHeader != "" && server.AuthValue != "" {
+			value, err := utils.DecryptAPIKey(server.AuthValue)
+			if err != nil {
+				return nil, fmt.Errorf("解密认证信息失败: %v", err)
+			}
+			headers = map[string]string{server.AuthHeader: value}
+		}
+		return mcp.NewHTTPClient(server.URL, headers), nil
+	}
+}
+
+// refresh 连接（或 Ping 已有连接，断开时自动重连）并刷新工具列表
+func (s *MCPServerService) refresh(conn *mcpConnection) {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpConnectTimeout)
+	defer cancel()
+	checked, wasHealthy := conn.state()
+	var tools []mcp.Tool
+	err := conn.client.Ping(ctx)
+	if err == nil {
+		tools, err = conn.client.ListTools(ctx)
+	}
+	// 刷新期间连接已被替换或删除：关闭本次可能重建的会话
+	if s.getConn(conn.server.ID) != conn {
+		_ = conn.client.Close()
+		return
+	}
+	conn.setResult(tools, err)
+	// 仅在状态变化时记录日志
+	if err != nil && (!checked || wasHealthy) {
+		log.Printf("⚠️ MCP 服务 %s 连接异常: %v", conn.server.Name, err)
+		s.logEvent("warn", "mcp_server_unhealthy", conn.server, fmt.Sprintf("MCP 服务 %s 连接异常", conn.server.Name), err.Error())
+	} else if err == nil && (!checked || !wasHealthy) {
+		log.Printf("✅ MCP 服务 %s 已连接（%d 个工具）", conn.server.Name, len(tools))
+		if checked {
+			s.logEvent("info", "mcp_server_recovered", conn.server, fmt.Sprintf("MCP 服务 %s 已恢复连接", conn.server.Name), "")
+		}
+	}
+}
+
+func (s *MCPServerService) checkAll() {
+	s.mu.RLock()
+	conns := make([]*mcpConnection, 0, len(s.conns))
+	for _, conn := range s.conns {
+		if conn.client != nil {
+			conns = append(conns, conn)
+		}
+	}
+	s.mu.RUnlock()
+	for _, conn := range conns {
+		s.refresh(conn)
+	}
+}
+
+func (c *mcpConnection) setResult(tools []mcp.Tool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkedAt = time.Now()
+	if err != nil {
+		c.lastErr = err.Error()
+		return
+	}
+	c.lastErr = ""
+	c.tools = tools
+}
+
+// state 返回是否已检查过及最近一次检查是否成功
+func (c *mcpConnection) state() (checked bool, healthy bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.checkedAt.IsZero(), c.lastErr == ""
+}
+
+func (c *mcpConnection) health() MCPServerHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := MCPServerHealth{
+		Connected: c.client != nil && c.client.Connected() && c.lastErr == "",
+		ToolCount: len(c.tools),
+		LastError: c.lastErr,
+	}
+	if !c.checkedAt.IsZero() {
+		t := c.checkedAt
+		h.CheckedAt = &t
+	}
+	return h
+}
+
+func (c *mcpConnection) toolList() []mcp.Tool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]mcp.Tool(nil), c.tools...)
+}
+
+func (s *MCPServerService) getConn(id uint) *mcpConnection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conns[id]
+}
+
+// Health 返回全部已注册服务的连接状态（未启用的服务不返回）
+func (s *MCPServerService) Health() []MCPServerStatus {
+	s.mu.RLock()
+	out := make([]MCPServerStatus, 0, len(s.conns))
+	for _, conn := range s.conns {
+		out = append(out, MCPServerStatus{
+			ID:              conn.server.ID,
+			Name:            conn.server.Name,
+			Transport:       conn.server.Transport,
+			MCPServerHealth: conn.health(),
+		})
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// mcpExposedToolName 暴露给模型的工具名：mcp_{服务名}_{工具名}，非法字符替换为下划线，最长 64 位
+func mcpExposedToolName(serverName, toolName string) string {
+	name := mcpToolNamePrefix + serverName + "_" + mcpToolNameSanitizer.ReplaceAllString(toolName, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// ValidateServerIDs 校验 AI 配置的 MCP 服务白名单，返回逗号分隔存储值
+func (s *MCPServerService) ValidateServerIDs(ids []uint) (string, error) {
+	for _, id := range ids {
+		if _, err := s.repo.GetByID(id); err != nil {
+			return "", fmt.Errorf("MCP 服务 %d 不存在", id)
+		}
+	}
+	return utils.JoinUintList(ids), nil
+}
+
+// ToolsForConfig 返回 AI 配置白名单内的 MCP 服务中被选中提供给模型的工具
+func (s *MCPServerService) ToolsForConfig(config *models.AIConfig) []MCPToolBinding {
+	ids := utils.ParseUintList(config.AllowedMCPServerIDs)
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.ExposedTools(ids...)
+}
+
+// ExposedTools 返回已连接服务中被选中提供给模型的工具；指定 serverIDs 时仅返回这些服务的工具
+func (s *MCPServerService) ExposedTools(serverIDs ...uint) []MCPToolBinding {
+	allowed := make(map[uint]bool, len(serverIDs))
+	for _, id := range serverIDs {
+		allowed[id] = true
+	}
+	s.mu.RLock()
+	conns := make([]*mcpConnection, 0, len(s.conns))
+	for id, conn := range s.conns {
+		if len(allowed) > 0 && !allowed[id] {
+			continue
+		}
+		conns = append(conns, conn)
+	}
+	s.mu.RUnlock()
+	sort.Slice(conns, func(i, j int) bool { return conns[i].server.Name < conns[j].server.Name })
+
+	var out []MCPToolBinding
+	for _, conn := range conns {
+		enabled := make(map[string]bool)
+		for _, name := range utils.SplitTags(conn.server.EnabledTools) {
+			enabled[name] = true
+		}
+		if len(enabled) == 0 || !conn.health().Connected {
+			continue
+		}
+		for _, tool := range conn.toolList() {
+			if !enabled[tool.Name] {
+				continue
+			}
+			out = append(out, MCPToolBinding{
+				ExposedName: mcpExposedToolName(conn.server.Name, tool.Name),
+				ServerID:    conn.server.ID,
+				ServerName:  conn.server.Name,
+				ToolName:    tool.Name,
+				Description: tool.Description,
+				InputSchema: tool.InputSchema,
+			})
+		}
+	}
+	return out
+}
+
+// mcpToolDefinition 转为 OpenAI function 工具定义
+func mcpToolDefinition(b MCPToolBinding) map[string]interface{} {
+	params := b.InputSchema
+	if params == nil {
+		params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	description := b.Description
+	if description == "" {
+		description = b.ToolName
+	}
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        b.ExposedName,
+			"description": description,
+			"parameters":  params,
+		},
+	}
+}
+
+// CallTool 将模型的一次工具调用转发给对应 MCP 服务，返回截断后的文本结果
+func (s *MCPServerService) CallTool(ctx context.Context, binding MCPToolBinding, arguments string) (string, error) {
+	conn := s.getConn(binding.ServerID)
+	if conn == nil || conn.client == nil {
+		return "", fmt.Errorf("MCP 服务 %s 未连接", binding.ServerName)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, mcpToolCallTimeout)
+	defer cancel()
+	result, err := conn.client.CallTool(ctx, binding.ToolName, parseToolArguments(arguments))
+	if err != nil {
+		return "", err
+	}
+	return truncateRunes(result, maxToolResultRunes), nil
+}
+
+// ListServers 返回全部服务及连接状态
+func (s *MCPServerService) ListServers() ([]MCPServerView, error) {
+	servers, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MCPServerView, 0, len(servers))
+	for i := range servers {
+		out = append(out, s.toView(&servers[i]))
+	}
+	return out, nil
+}
+
+// GetServer 返回单个服务
+func (s *MCPServerService) GetServer(id uint) (*MCPServerView, error) {
+	server, err := s.getServer(id)
+	if err != nil {
+		return nil, err
+	}
+	view := s.toView(server)
+	return &view, nil
+}
+
+func (s *MCPServerService) getServer(id uint) (*models.MCPServer, error) {
+	server, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMCPServerNotFound
+		}
+		return nil, err
+	}
+	return server, nil
+}
+
+func (s *MCPServerService) toView(server *models.MCPServer) MCPServerView {
+	view := MCPServerView{
+		MCPServer:    *server,
+		Args:         []string{},
+		EnvKeys:      []string{},
+		EnabledTools: utils.SplitTags(server.EnabledTools),
+		HasAuthValue: server.AuthValue != "",
+	}
+	if server.Args != "" {
+		_ = json.Unmarshal([]byte(server.Args), &view.Args)
+	}
+	if env, err := decryptMCPEnv(server.Env); err == nil {
+		for k := range env {
+			view.EnvKeys = append(view.EnvKeys, k)
+		}
+		sort.Strings(view.EnvKeys)
+	}
+	if view.EnabledTools == nil {
+		view.EnabledTools = []string{}
+	}
+	if conn := s.getConn(server.ID); conn != nil {
+		view.MCPServerHealth = conn.health()
+	}
+	return view
+}
+
+// CreateServer 注册 MCP 服务并立即连接
+func (s *MCPServerService) CreateServer(input MCPServerInput) (*MCPServerView, error) {
+	server := &models.MCPServer{IsActive: true}
+	if err := applyMCPServerInput(server, input); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetByName(server.Name); err == nil {
+		return nil, fmt.Errorf("MCP 服务名 %s 已存在", server.Name)
+	}
+	if err := s.repo.Create(server); err != nil {
+		return nil, err
+	}
+	s.connect(*server)
+	view := s.toView(server)
+	return &view, nil
+}
+
+// UpdateServer 修改 MCP 服务并重建连接
+func (s *MCPServerService) UpdateServer(id uint, input MCPServerInput) (*MCPServerView, error) {
+	server, err := s.getServer(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyMCPServerInput(server, input); err != nil {
+		return nil, err
+	}
+	if existing, err := s.repo.GetByName(server.Name); err == nil && existing.ID != id {
+		return nil, fmt.Errorf("MCP 服务名 %s 已存在", server.Name)
+	}
+	if err := s.repo.Update(server); err != nil {
+		return nil, err
+	}
+	s.connect(*server)
+	view := s.toView(server)
+	return &view, nil
+}
+
+// DeleteServer 删除 MCP 服务并断开连接
+func (s *MCPServerService) DeleteServer(id uint) error {
+	if _, err := s.getServer(id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.disconnect(id)
+	return nil
+}
+
+// Reconnect 立即重连并刷新工具列表（同步），返回最新状态
+func (s *MCPServerService) Reconnect(id uint) (*MCPServerView, error) {
+	server, err := s.getServer(id)
+	if err != nil {
+		return nil, err
+	}
+	if !server.IsActive {
+		return nil, errors.New("MCP 服务未启用")
+	}
+	s.disconnect(id)
+	client, err := s.newClient(server)
+	if err != nil {
+		return nil, err
+	}
+	conn := &mcpConnection{server: *server, client: client}
+	s.mu.Lock()
+	s.conns[id] = conn
+	s.mu.Unlock()
+	s.refresh(conn)
+	view := s.toView(server)
+	return &view, nil
+}
+
+// ListServerTools 返回服务当前提供的全部工具及是否已选中（供管理员勾选）
+func (s *MCPServerService) ListServerTools(id uint) ([]MCPToolInfo, error) {
+	server, err := s.getServer(id)
+	if err != nil {
+		return nil, err
+	}
+	conn := s.getConn(id)
+	if conn == nil || conn.client == nil {
+		return nil, errors.New("MCP 服务未连接")
+	}
+	s.refresh(conn)
+	if h := conn.health(); h.LastError != "" {
+		return nil, fmt.Errorf("获取工具列表失败: %s", h.LastError)
+	}
+	enabled := make(map[string]bool)
+	for _, name := range utils.SplitTags(server.EnabledTools) {
+		enabled[name] = true
+	}
+	tools := conn.toolList()
+	out := make([]MCPToolInfo, 0, len(tools))
+	for _, tool := range tools {
+		out = append(out, MCPToolInfo{
+			Tool:        tool,
+			ExposedName: mcpExposedToolName(server.Name, tool.Name),
+			Enabled:     enabled[tool.Name],
+		})
+	}
+	return out, nil
+}
+
+func applyMCPServerInput(server *models.MCPServer, input MCPServerInput) error {
+	name := strings.TrimSpace(input.Name)
+	if !mcpServerNamePattern.MatchString(name) {
+		return errors.New("服务名须以字母开头，仅含字母、数字、下划线，最长 32 位")
+	}
+	transport := strings.ToLower(strings.TrimSpace(input.Transport))
+	if transport == "" {
+		transport = "http"
+	}
+	switch transport {
+	case "http":
+		if err := validateToolURLTemplate(strings.TrimSpace(input.URL)); err != nil || strings.Contains(input.URL, "{{") {
+			return errors.New("http 传输须填写合法的 http(s) 服务地址")
+		}
+	case "stdio":
+		if !mcpStdioEnabled() {
+			return errors.New("stdio 传输未开启（需设置 MCP_STDIO_ENABLED=true）")
+		}
+		if strings.TrimSpace(input.Command) == "" {
+			return errors.New("stdio 传输须填写启动命令")
+		}
+	default:
+		return fmt.Errorf("不支持的传输方式: %s", transport)
+	}
+	if input.AuthValue != nil {
+		if *input.AuthValue == "" {
+			server.AuthValue = ""
+		} else {
+			encrypted, err := utils.EncryptAPIKey(*input.AuthValue)
+			if err != nil {
+				return fmt.Errorf("加密认证信息失败: %v", err)
+			}
+			server.AuthValue = encrypted
+		}
+	}
+	if input.Env != nil {
+		env, err := encryptMCPEnv(input.Env)
+		if err != nil {
+			return err
+		}
+		server.Env = env
+	}
+	args := input.Args
+	if args == nil {
+		args = []string{}
+	}
+	argsJSON, _ := json.Marshal(args)
+	server.Name = name
+	server.Description = strings.TrimSpace(input.Description)
+	server.Transport = transport
+	server.URL = strings.TrimSpace(input.URL)
+	server.AuthHeader = strings.TrimSpace(input.AuthHeader)
+	server.Command = strings.TrimSpace(input.Command)
+	server.Args = string(argsJSON)
+	server.EnabledTools = strings.Join(utils.SplitTags(strings.Join(input.EnabledTools, ",")), ",")
+	if input.IsActive != nil {
+		server.IsActive = *input.IsActive
+	}
+	return nil
+}
+
+func encryptMCPEnv(env map[string]string) (string, error) {
+	if len(env) == 0 {
+		return "", nil
+	}
+	data, _ := json.Marshal(env)
+	encrypted, err := utils.EncryptAPIKey(string(data))
+	if err != nil {
+		return "", fmt.Errorf("加密环境变量失败: %v", err)
+	}
+	return encrypted, nil
+}
+
+func decryptMCPEnv(stored string) (map[string]string, error) {
+	env := map[string]string{}
+	if stored == "" {
+		return env, nil
+	}
+	data, err := utils.DecryptAPIKey(stored)
+	if err != nil {
+		return nil, fmt.Errorf("解密环境变量失败: %v", err)
+	}
+	if err := json.Unmarshal([]byte(data), &env); err != nil {
+		return nil, fmt.Errorf("环境变量格式错误: %v", err)
+	}
+	return env, nil
+}
+
+func (s *MCPServerService) logEvent(level, event string, server models.MCPServer, message, errMsg string) {
+	if s.systemLogSvc == nil {
+		return
+	}
+	_ = s.systemLogSvc.Create(CreateSystemLogInput{
+		Level:    level,
+		Category: "ai",
+		Event:    event,
+		Source:   "backend",
+		Message:  message,
+		Meta: map[string]interface{}{
+			"mcp_server": server.Name,
+			"transport":  server.Transport,
+			"error":      errMsg,
+		},
+	})
+}
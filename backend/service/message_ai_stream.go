@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// aiDeltaFlushInterval 增量合并推送的最小间隔，避免逐 token 广播造成 WS/Redis 压力。
+const aiDeltaFlushInterval = 60 * time.Millisecond
+
+// aiStream 一次进行中的 AI 流式回复。
+type aiStream struct {
+	provisionalID string
+	cancel        context.CancelFunc
+}
+
+// aiStreamRegistry 记录各会话进行中的 AI 流式回复（每个会话至多一条）。
+type aiStreamRegistry struct {
+	mu      sync.Mutex
+	streams map[uint]*aiStream
+}
+
+// start 为会话登记新的流式回复；若已有进行中的回复则先取消（部分内容由其自身落库）。
+func (r *aiStreamRegistry) start(conversationID uint) (context.Context, *aiStream) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &aiStream{
+		provisionalID: fmt.Sprintf("ai-%d-%d", conversationID, time.Now().UnixNano()),
+		cancel:        cancel,
+	}
+	r.mu.Lock()
+	if r.streams == nil {
+		r.streams = make(map[uint]*aiStream)
+	}
+	if prev, ok := r.streams[conversationID]; ok {
+		prev.cancel()
+	}
+	r.streams[conversationID] = stream
+	r.mu.Unlock()
+	return ctx, stream
+}
+
+// finish 流结束后移除登记（仅移除自身，避免误删新一轮回复）。
+func (r *aiStreamRegistry) finish(conversationID uint, stream *aiStream) {
+	r.mu.Lock()
+	if cur, ok := r.streams[conversationID]; ok && cur == stream {
+		delete(r.streams, conversationID)
+	}
+	r.mu.Unlock()
+	stream.cancel()
+}
+
+// cancel 取消会话进行中的流式回复，返回是否存在。
+func (r *aiStreamRegistry) cancel(conversationID uint) bool {
+	r.mu.Lock()
+	stream, ok := r.streams[conversationID]
+	r.mu.Unlock()
+	if ok {
+		stream.cancel()
+	}
+	return ok
+}
+
+// CancelAIReply 中断会话当前正在流式生成的 AI 回复；已生成的部分内容仍会落库，尚无内容时不落库，均推送 ai_done。
+func (s *MessageService) CancelAIReply(conversationID uint) bool {
+	return s.aiStreams.cancel(conversationID)
+}
+
+// newAIDeltaEmitter 返回按时间间隔合并增量并广播 ai_delta 的回调，以及用于推送剩余内容的 flush。
+func (s *MessageService) newAIDeltaEmitter(conversationID uint, provisionalID string) (onDelta func(string), flush func()) {
+	var (
+		mu        sync.Mutex
+		pending   strings.Builder
+		lastFlush time.Time
+	)
+	send := func() {
+		if pending.Len() == 0 || s.hub == nil {
+			pending.Reset()
+			return
+		}
+		s.hub.BroadcastMessage(conversationID, "ai_delta", map[string]interface{}{
+			"conversation_id": conversationID,
+			"provisional_id":  provisionalID,
+			"delta":           pending.String(),
+		})
+		pending.Reset()
+		lastFlush = time.Now()
+	}
+	onDelta = func(delta string) {
+		mu.Lock()
+		defer mu.Unlock()
+		pending.WriteString(delta)
+		if time.Since(lastFlush) >= aiDeltaFlushInterval {
+			send()
+		}
+	}
+	flush = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		send()
+	}
+	return onDelta, flush
+}
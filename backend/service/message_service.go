@@ -3,6 +3,7 @@ package service
 import (
 	"errors"
 	"log"
+	"strings"
 
 	"github.com/2930134478/AI-CS/backend/models"
 	"github.com/2930134478/AI-CS/backend/repository"
@@ -25,6 +26,25 @@ type MessageService struct {
 	hub              BroadcastHub
 	aiService        *AIService // AI 服务（用于 AI 自动回复）
 	offlineEmailSvc  *OfflineEmailService
+	aiStreams        aiStreamRegistry    // 进行中的 AI 流式回复（按会话）
+	handoffSvc       *HandoffService     // 可选，AI 转人工
+	copilotSvc       *CopilotService     // 可选，人工会话的客服助手草稿
+	translationSvc   *TranslationService // 可选，人工会话的实时翻译
+}
+
+// SetTranslationService 注入实时翻译服务（访客消息译为客服语言，客服回复译为访客语言）
+func (s *MessageService) SetTranslationService(svc *TranslationService) {
+	s.translationSvc = svc
+}
+
+// SetCopilotService 注入客服助手服务（人工会话收到访客消息时起草候选回复）
+func (s *MessageService) SetCopilotService(svc *CopilotService) {
+	s.copilotSvc = svc
+}
+
+// SetHandoffService 注入转人工服务（关键词 / 模型意图 / 连续失败触发）
+func (s *MessageService) SetHandoffService(svc *HandoffService) {
+	s.handoffSvc = svc
 }
 
 // SetOfflineEmailService 注入离线邮件服务（Hub 创建后调用）
@@ -138,16 +158,40 @@ func (s *MessageService) CreateMessage(input CreateMessageInput) (*models.Messag
 		log.Printf("⚠️ WebSocket Hub 为空，无法广播消息: 消息ID=%d, 对话ID=%d", message.ID, message.ConversationID)
 	}
 
+	// 实时翻译：原文已落库并送达，译文在后台生成后以 message_translated 推送（不阻塞发送）
+	if s.translationSvc != nil && input.Content != "" {
+		go s.translateMessageAsync(conv, *message)
+	}
+
 	// 人工访客会话：客服发消息且访客离线时，调度离线邮件
 	if input.SenderIsAgent && conv.ConversationType == "visitor" && conv.ChatMode == "human" && s.offlineEmailSvc != nil {
 		s.offlineEmailSvc.OnAgentMessage(message.ConversationID, message.ID)
 	}
 
+	// 人工访客会话：访客消息到达后由客服助手起草候选回复（仅推送给客服）
+	if !input.SenderIsAgent && s.copilotSvc != nil {
+		s.copilotSvc.OnVisitorMessage(&conv, message)
+	}
+
 	// 3. 触发 AI 回复（文本/识图或生图，具体由 AI 配置的 model_type 决定）
 	needAIReply := s.aiService != nil && conv.ChatMode == "ai" && (
 		(!input.SenderIsAgent) || (conv.ConversationType == "internal" && input.SenderIsAgent))
+	// 访客消息命中转人工关键词：中断进行中的 AI 回复并直接转人工，不再调用 AI
+	if needAIReply && !input.SenderIsAgent && conv.ConversationType == "visitor" && s.handoffSvc != nil {
+		if keyword, ok := s.handoffSvc.MatchKeyword(input.Content); ok {
+			s.aiStreams.cancel(message.ConversationID)
+			if _, err := s.handoffSvc.RequestHandoff(message.ConversationID, HandoffReasonKeyword, keyword); err != nil {
+				log.Printf("⚠️ 关键词转人工失败: conversation_id=%d err=%v", message.ConversationID, err)
+			} else {
+				needAIReply = false
+			}
+		}
+	}
 	if needAIReply {
+		// 新消息到达时中断上一条仍在输出的回复（其部分内容会照常落库）
+		streamCtx, stream := s.aiStreams.start(message.ConversationID)
 		go func() {
+			defer s.aiStreams.finish(message.ConversationID, stream)
 			// 用于查找 AI 配置的用户 ID：访客对话用 AgentID，内部对话用发送者（客服）ID
 			userID := conv.AgentID
 			if userID == 0 {
@@ -187,11 +231,22 @@ func (s *MessageService) CreateMessage(input CreateMessageInput) (*models.Messag
 					MimeType: mime,
 				}
 			}
+			// 流式输出：增量通过 ai_delta 推送，provisional_id 供前端在落库前渲染临时气泡
+			onDelta, flushDelta := s.newAIDeltaEmitter(message.ConversationID, stream.provisionalID)
+			opts.OnDelta = onDelta
+			opts.Context = streamCtx
 			aiResult, err := s.aiService.GenerateAIResponseWithOptions(message.ConversationID, input.Content, userID, opts)
+			flushDelta()
 			aiResponse := ""
+			cancelled := false
 			sourcesUsed := ""
 			var aiMessageFileURL *string
 			aiGenFailed := false
+			handoffRequested := false
+			var answeredConfigID *uint
+			answeredModel := ""
+			var usage *AIUsageSummary
+			var citations []models.MessageCitation
 			if err != nil {
 				log.Printf("❌ AI 生成回复失败: %v", err)
 				aiResponse = "AI客服好像出了点差错，请联系人工客服解决"
@@ -201,6 +256,27 @@ func (s *MessageService) CreateMessage(input CreateMessageInput) (*models.Messag
 				sourcesUsed = aiResult.SourcesUsed
 				aiMessageFileURL = aiResult.GeneratedFileURL
 				aiGenFailed = aiResult.GenerationFailed
+				cancelled = aiResult.Cancelled
+				handoffRequested = aiResult.HandoffRequested
+				if aiResult.AIConfigID != 0 {
+					id := aiResult.AIConfigID
+					answeredConfigID = &id
+					answeredModel = aiResult.AIModel
+				}
+				usage = aiResult.Usage
+				citations = aiResult.Citations
+			}
+
+			// 首个增量之前即被取消：不落库、不推送兜底回复，只通知前端结束临时气泡
+			if cancelled && strings.TrimSpace(aiResponse) == "" && aiMessageFileURL == nil {
+				if s.hub != nil {
+					s.hub.BroadcastMessage(message.ConversationID, "ai_done", map[string]interface{}{
+						"conversation_id": message.ConversationID,
+						"provisional_id":  stream.provisionalID,
+						"cancelled":       true,
+					})
+				}
+				return
 			}
 
 			// 生图时前端依赖 file_type === "image" 才渲染图片，必须设置
@@ -221,12 +297,28 @@ func (s *MessageService) CreateMessage(input CreateMessageInput) (*models.Messag
 				FileURL:              aiMessageFileURL,
 				FileType:             aiMessageFileType,
 				IsAIGenerationFailed: aiGenFailed,
+				AIConfigID:           answeredConfigID,
+				AIModel:              answeredModel,
+				Citations:            citations,
+			}
+			if usage != nil {
+				aiMessage.PromptTokens = usage.PromptTokens
+				aiMessage.CompletionTokens = usage.CompletionTokens
+				aiMessage.Cost = usage.Cost
 			}
 
 			if err := s.messages.Create(aiMessage); err != nil {
 				log.Printf("❌ 创建 AI 回复消息失败: %v", err)
+				if s.hub != nil {
+					s.hub.BroadcastMessage(message.ConversationID, "ai_done", map[string]interface{}{
+						"conversation_id": message.ConversationID,
+						"provisional_id":  stream.provisionalID,
+						"error":           "save_failed",
+					})
+				}
 				return
 			}
+			s.aiService.AttachUsageToMessage(usage, aiMessage.ID)
 
 			// 更新对话的更新时间
 			if err := s.conversations.UpdateFields(conv.ID, map[string]interface{}{
@@ -237,12 +329,34 @@ func (s *MessageService) CreateMessage(input CreateMessageInput) (*models.Messag
 
 			// 广播 AI 回复消息
 			if s.hub != nil {
+				// ai_done：流结束（完成或取消），携带落库后的正式消息，前端据 provisional_id 替换临时气泡
+				s.hub.BroadcastMessage(aiMessage.ConversationID, "ai_done", map[string]interface{}{
+					"conversation_id": aiMessage.ConversationID,
+					"provisional_id":  stream.provisionalID,
+					"message":         aiMessage,
+					"cancelled":       cancelled,
+				})
 				// AI 回复只广播给访客，不广播给客服（避免干扰）
 				// 客服可以在会话页面手动开启"显示 AI 消息"来查看
 				s.hub.BroadcastMessage(aiMessage.ConversationID, "new_message", aiMessage)
 				// 不再广播到所有客服
 				// s.hub.BroadcastToAllAgents("new_message", aiMessage)
 			}
+
+			// 转人工：模型请求转接，或 AI 连续生成失败
+			if s.handoffSvc != nil && conv.ConversationType == "visitor" {
+				reason := ""
+				if handoffRequested {
+					reason = HandoffReasonAIIntent
+				} else if aiGenFailed && s.handoffSvc.ShouldHandoffAfterFailure(aiMessage.ConversationID) {
+					reason = HandoffReasonAIFailures
+				}
+				if reason != "" {
+					if _, err := s.handoffSvc.RequestHandoff(aiMessage.ConversationID, reason, ""); err != nil {
+						log.Printf("⚠️ AI 转人工失败: conversation_id=%d reason=%s err=%v", aiMessage.ConversationID, reason, err)
+					}
+				}
+			}
 		}()
 	}
 
@@ -251,14 +365,25 @@ func (s *MessageService) CreateMessage(input CreateMessageInput) (*models.Messag
 
 // ListMessages 返回会话内的消息列表。
 // includeAIMessages: 是否包含 AI 消息（默认 false，不包含）
+// includeWhispers: 是否包含 whisper 内部备注（仅客服请求时为 true，访客永远不可见）
 // 如果 includeAIMessages == false，过滤掉所有 chat_mode == "ai" 的消息
 // 这样就能准确区分 AI 模式下的消息和人工模式下的消息，即使对话模式切换了也能正确过滤
-func (s *MessageService) ListMessages(conversationID uint, includeAIMessages bool) ([]models.Message, error) {
+func (s *MessageService) ListMessages(conversationID uint, includeAIMessages bool, includeWhispers bool) ([]models.Message, error) {
 	messages, err := s.messages.ListByConversationID(conversationID)
 	if err != nil {
 		return nil, err
 	}
 
+	if !includeWhispers {
+		visible := make([]models.Message, 0, len(messages))
+		for _, msg := range messages {
+			if msg.MessageType != models.MessageTypeWhisper {
+				visible = append(visible, msg)
+			}
+		}
+		messages = visible
+	}
+
 	// 如果不包含 AI 消息，过滤掉所有 chat_mode == "ai" 的消息
 	// 这样，无论对话当前是什么模式，都能准确过滤掉 AI 模式下的所有消息
 	// 包括：访客在 AI 模式下发送的消息、AI 回复消息
@@ -312,3 +437,29 @@ func (s *MessageService) MarkMessagesRead(conversationID uint, readerIsAgent boo
 
 	return result, nil
 }
+
+// translateMessageAsync 翻译已发送的消息：译文写回消息记录，并向会话房间与客服推送 message_translated
+func (s *MessageService) translateMessageAsync(conv models.Conversation, message models.Message) {
+	translation, ok := s.translationSvc.TranslateForMessage(&conv, message.Content, message.SenderIsAgent)
+	if !ok {
+		return
+	}
+	if err := s.messages.UpdateTranslation(message.ID, translation.SourceLanguage, translation.Content, translation.TargetLanguage); err != nil {
+		log.Printf("⚠️ 保存消息译文失败: message_id=%d err=%v", message.ID, err)
+		return
+	}
+	if s.hub == nil {
+		return
+	}
+	payload := map[string]interface{}{
+		"conversation_id":     message.ConversationID,
+		"message_id":          message.ID,
+		"source_language":     translation.SourceLanguage,
+		"translated_content":  translation.Content,
+		"translated_language": translation.TargetLanguage,
+	}
+	s.hub.BroadcastMessage(message.ConversationID, "message_translated", payload)
+	if conv.ChatMode == "human" && conv.ConversationType != "internal" {
+		s.hub.BroadcastToAllAgents("message_translated", payload)
+	}
+}
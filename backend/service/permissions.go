@@ -21,6 +21,7 @@ const (
 	PermPrompts   PermissionKey = "prompts"   // 提示词
 	PermSettings  PermissionKey = "settings"  // AI 配置
 	PermUsers     PermissionKey = "users"     // 用户管理
+	PermMacros    PermissionKey = "macros"    // 快捷回复管理（个人 / 团队宏）
 )
 
 func AllPermissionKeys() []string {
@@ -34,6 +35,7 @@ func AllPermissionKeys() []string {
 		string(PermPrompts),
 		string(PermSettings),
 		string(PermUsers),
+		string(PermMacros),
 	}
 	sort.Strings(keys)
 	return keys
@@ -140,7 +140,8 @@ const (
 2. 如果知识库中有相关信息，请直接引用并解释
 3. 如果知识库中没有相关信息，请诚实告知
 4. 保持友好、专业的语气
-5. 回答要简洁明了，避免冗长`
+5. 回答要简洁明了，避免冗长
+6. 引用知识库内容时，在相应句末用 [编号] 标注来源（编号即知识库内容中片段前的 [n]），不要编造编号`
 
 	defaultRAGPromptWithWebOptional = `你是一个智能客服助手。请优先基于以下知识库内容回答用户的问题。
 
@@ -154,7 +155,8 @@ const (
 1. 若知识库内容与问题明确相关，请基于知识库给出准确、简洁的回答。
 2. 若知识库内容与问题无关或仅弱相关，可先基于你自身的知识回答，不必拘泥于知识库。
 3. 若你自身知识仍不足以回答（例如需要最新资讯、实时数据），你可决定是否使用联网搜索获取信息后再回答。
-4. 保持友好、专业，回答简洁明了。`
+4. 保持友好、专业，回答简洁明了。
+5. 引用知识库内容或联网结果时，在相应句末用 [编号] 标注来源（编号即内容前的 [n]），不要编造编号。`
 
 	defaultNoKBPrompt = `你是一个智能客服助手。当前未使用知识库，请仅基于你的知识回答用户问题。
 
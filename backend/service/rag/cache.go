@@ -32,7 +32,7 @@ func (c *Cache) SetTTL(ttl int) {
 }
 
 // Get 获取缓存结果
-func (c *Cache) Get(query string, topK int, knowledgeBaseID *uint) ([]SearchResult, bool) {
+func (c *Cache) Get(query string, topK int, knowledgeBaseIDs []uint, mode RetrievalMode) ([]SearchResult, bool) {
 	if c.ttl == 0 {
 		return nil, false
 	}
@@ -40,7 +40,7 @@ func (c *Cache) Get(query string, topK int, knowledgeBaseID *uint) ([]SearchResu
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	key := c.buildKey(query, topK, knowledgeBaseID)
+	key := c.buildKey(query, topK, knowledgeBaseIDs, mode)
 	entry, ok := c.data[key]
 	if !ok {
 		return nil, false
@@ -56,7 +56,7 @@ func (c *Cache) Get(query string, topK int, knowledgeBaseID *uint) ([]SearchResu
 }
 
 // Set 设置缓存结果
-func (c *Cache) Set(query string, topK int, knowledgeBaseID *uint, results []SearchResult) {
+func (c *Cache) Set(query string, topK int, knowledgeBaseIDs []uint, mode RetrievalMode, results []SearchResult) {
 	if c.ttl == 0 {
 		return
 	}
@@ -64,7 +64,7 @@ func (c *Cache) Set(query string, topK int, knowledgeBaseID *uint, results []Sea
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	key := c.buildKey(query, topK, knowledgeBaseID)
+	key := c.buildKey(query, topK, knowledgeBaseIDs, mode)
 	c.data[key] = &cacheEntry{
 		results:   results,
 		expiresAt: time.Now().Add(c.ttl),
@@ -72,10 +72,10 @@ func (c *Cache) Set(query string, topK int, knowledgeBaseID *uint, results []Sea
 }
 
 // buildKey 构建缓存键
-func (c *Cache) buildKey(query string, topK int, knowledgeBaseID *uint) string {
-	key := fmt.Sprintf("%s|%d", query, topK)
-	if knowledgeBaseID != nil {
-		key += fmt.Sprintf("|%d", *knowledgeBaseID)
+func (c *Cache) buildKey(query string, topK int, knowledgeBaseIDs []uint, mode RetrievalMode) string {
+	key := fmt.Sprintf("%s|%d|%s", query, topK, mode)
+	if len(knowledgeBaseIDs) > 0 {
+		key += fmt.Sprintf("|%v", knowledgeBaseIDs)
 	}
 	return key
 }
@@ -47,15 +47,44 @@ func (s *DocumentEmbeddingService) EmbedDocument(ctx context.Context, documentID
 	if len(chunkDBID) > 0 {
 		cid = chunkDBID[0]
 	}
-	if err := s.vectorStoreService.UpsertVector(ctx, docIDStr, kbIDStr, content, cid, vectors[0]); err != nil {
+	if err := s.vectorStoreService.UpsertVector(ctx, docIDStr, kbIDStr, content, cid, vectors[0], SourceMetadata{}); err != nil {
 		return fmt.Errorf("存储向量失败: %w", err)
 	}
 
 	return nil
 }
 
+// EmbedFAQ 向量化单条 FAQ 并以 source_type=faq 存储（document_id 为 FAQ ID，全局 FAQ 的知识库 ID 为 0）
+func (s *DocumentEmbeddingService) EmbedFAQ(ctx context.Context, faqID uint, knowledgeBaseID uint, content string) error {
+	svc, err := s.embeddingProvider.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("获取嵌入服务失败: %w", err)
+	}
+	vectors, err := svc.EmbedTexts(ctx, []string{content})
+	if err != nil {
+		return fmt.Errorf("FAQ 向量化失败: %w", err)
+	}
+	if len(vectors) == 0 {
+		return fmt.Errorf("未返回向量")
+	}
+	meta := SourceMetadata{SourceType: SourceTypeFAQ}
+	if err := s.vectorStoreService.UpsertVector(ctx, ConvertDocumentID(faqID), ConvertKnowledgeBaseID(knowledgeBaseID), content, "", vectors[0], meta); err != nil {
+		return fmt.Errorf("存储向量失败: %w", err)
+	}
+	return nil
+}
+
 // EmbedDocuments 批量向量化文档并存储
 func (s *DocumentEmbeddingService) EmbedDocuments(ctx context.Context, documentIDs []uint, knowledgeBaseIDs []uint, contents []string, chunkDBIDs ...[]string) error {
+	var cIDs []string
+	if len(chunkDBIDs) > 0 {
+		cIDs = chunkDBIDs[0]
+	}
+	return s.EmbedChunks(ctx, documentIDs, knowledgeBaseIDs, contents, cIDs, nil)
+}
+
+// EmbedChunks 批量向量化分段并连同来源元数据存储；chunkDBIDs / metas 为空或长度不一致时不写入对应字段
+func (s *DocumentEmbeddingService) EmbedChunks(ctx context.Context, documentIDs []uint, knowledgeBaseIDs []uint, contents []string, chunkDBIDs []string, metas []SourceMetadata) error {
 	if len(documentIDs) != len(knowledgeBaseIDs) || len(documentIDs) != len(contents) {
 		return fmt.Errorf("参数长度不匹配")
 	}
@@ -84,11 +113,14 @@ func (s *DocumentEmbeddingService) EmbedDocuments(ctx context.Context, documentI
 	}
 
 	cIDs := make([]string, len(contents))
-	if len(chunkDBIDs) > 0 && len(chunkDBIDs[0]) == len(contents) {
-		cIDs = chunkDBIDs[0]
+	if len(chunkDBIDs) == len(contents) {
+		cIDs = chunkDBIDs
+	}
+	if len(metas) != len(contents) {
+		metas = nil
 	}
 
-	if err := s.vectorStoreService.UpsertVectors(ctx, docIDStrs, kbIDStrs, contents, vectors, cIDs); err != nil {
+	if err := s.vectorStoreService.UpsertVectors(ctx, docIDStrs, kbIDStrs, contents, vectors, cIDs, metas); err != nil {
 		return fmt.Errorf("批量存储向量失败: %w", err)
 	}
 
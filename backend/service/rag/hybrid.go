@@ -0,0 +1,139 @@
+package rag
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/repository"
+)
+
+// RetrievalMode 检索模式
+type RetrievalMode string
+
+const (
+	// RetrievalModeVector 仅向量检索（默认）
+	RetrievalModeVector RetrievalMode = "vector"
+	// RetrievalModeKeyword 仅关键词检索（全文索引）
+	RetrievalModeKeyword RetrievalMode = "keyword"
+	// RetrievalModeHybrid 向量 + 关键词，RRF 融合
+	RetrievalModeHybrid RetrievalMode = "hybrid"
+)
+
+// rrfK RRF 平滑常数（经验值 60）
+const rrfK = 60
+
+// NormalizeRetrievalMode 规范化检索模式，非法值回退为 vector
+func NormalizeRetrievalMode(v string) RetrievalMode {
+	switch RetrievalMode(strings.ToLower(strings.TrimSpace(v))) {
+	case RetrievalModeKeyword:
+		return RetrievalModeKeyword
+	case RetrievalModeHybrid:
+		return RetrievalModeHybrid
+	default:
+		return RetrievalModeVector
+	}
+}
+
+// KeywordSearcher 关键词检索接口（按相关度倒序返回）
+type KeywordSearcher interface {
+	SearchKeyword(ctx context.Context, query string, topK int, knowledgeBaseIDs []uint) ([]SearchResult, error)
+}
+
+// ChunkKeywordSearcher 基于 document_chunks 全文索引的关键词检索
+type ChunkKeywordSearcher struct {
+	chunkRepo *repository.DocumentChunkRepository
+}
+
+// NewChunkKeywordSearcher 创建分段关键词检索实例
+func NewChunkKeywordSearcher(chunkRepo *repository.DocumentChunkRepository) *ChunkKeywordSearcher {
+	return &ChunkKeywordSearcher{chunkRepo: chunkRepo}
+}
+
+// SearchKeyword 关键词检索分段
+func (k *ChunkKeywordSearcher) SearchKeyword(ctx context.Context, query string, topK int, knowledgeBaseIDs []uint) ([]SearchResult, error) {
+	hits, err := k.chunkRepo.KeywordSearch(query, knowledgeBaseIDs, topK)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(hits))
+	for _, h := range hits {
+		results = append(results, SearchResult{
+			DocumentID:      ConvertDocumentID(h.DocumentID),
+			KnowledgeBaseID: ConvertKnowledgeBaseID(h.KnowledgeBaseID),
+			Content:         h.Content,
+			ChunkID:         strconv.FormatUint(uint64(h.ID), 10),
+			Source: SourceMetadata{
+				PageNumber:   h.PageNumber,
+				HeadingPath:  h.HeadingPath,
+				SourceAnchor: h.SourceAnchor,
+			},
+			Score: float32(h.Score),
+		})
+	}
+	return results, nil
+}
+
+// fusionKey 融合时识别同一片段：优先分段 ID，否则文档 ID + 内容
+func fusionKey(r SearchResult) string {
+	if r.ChunkID != "" {
+		return "c:" + r.ChunkID
+	}
+	return "d:" + r.DocumentID + "|" + r.Content
+}
+
+// FuseResults 按 RRF 融合多路检索结果（如同一问题改写出的多个查询），取前 topK 条。
+func FuseResults(topK int, lists ...[]SearchResult) []SearchResult {
+	return fuseRRF(topK, lists...)
+}
+
+// fuseRRF 使用倒数排名融合（Reciprocal Rank Fusion）合并多路有序结果。
+// 融合后的 Score 归一化到 [0,1]：在所有路中均排第一时为 1。
+func fuseRRF(topK int, lists ...[]SearchResult) []SearchResult {
+	type fused struct {
+		result SearchResult
+		score  float64
+		order  int
+	}
+	byKey := make(map[string]*fused)
+	order := 0
+	for _, list := range lists {
+		for rank, r := range list {
+			key := fusionKey(r)
+			f, ok := byKey[key]
+			if !ok {
+				f = &fused{result: r, order: order}
+				order++
+				byKey[key] = f
+			} else if f.result.ChunkID == "" && r.ChunkID != "" {
+				f.result.ChunkID = r.ChunkID
+			}
+			f.score += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+	if len(byKey) == 0 {
+		return nil
+	}
+	all := make([]*fused, 0, len(byKey))
+	for _, f := range byKey {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return all[i].order < all[j].order
+	})
+	maxScore := float64(len(lists)) / float64(rrfK+1)
+	if topK > 0 && len(all) > topK {
+		all = all[:topK]
+	}
+	out := make([]SearchResult, 0, len(all))
+	for _, f := range all {
+		r := f.result
+		r.Score = float32(f.score / maxScore)
+		out = append(out, r)
+	}
+	return out
+}
@@ -22,6 +22,12 @@ type Metrics struct {
 	TotalLatency time.Duration
 	MinLatency   time.Duration
 	MaxLatency   time.Duration
+
+	// 重排序指标
+	RerankCalls        int64
+	RerankFailures     int64
+	RerankTotalLatency time.Duration
+	RerankMaxLatency   time.Duration
 }
 
 // NewMetrics 创建性能指标实例
@@ -58,6 +64,21 @@ func (m *Metrics) RecordQuery(success bool, latency time.Duration, cacheHit bool
 	}
 }
 
+// RecordRerank 记录一次重排序调用（失败时检索回退为原始顺序）
+func (m *Metrics) RecordRerank(success bool, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.RerankCalls++
+	if !success {
+		m.RerankFailures++
+	}
+	m.RerankTotalLatency += latency
+	if latency > m.RerankMaxLatency {
+		m.RerankMaxLatency = latency
+	}
+}
+
 // GetStats 获取统计信息
 func (m *Metrics) GetStats() map[string]interface{} {
 	m.mu.RLock()
@@ -79,6 +100,11 @@ func (m *Metrics) GetStats() map[string]interface{} {
 		cacheHitRate = float64(m.CacheHits) / float64(totalCacheRequests) * 100
 	}
 
+	rerankAvgLatency := time.Duration(0)
+	if m.RerankCalls > 0 {
+		rerankAvgLatency = m.RerankTotalLatency / time.Duration(m.RerankCalls)
+	}
+
 	return map[string]interface{}{
 		"total_queries":       m.TotalQueries,
 		"successful_queries":  m.SuccessfulQueries,
@@ -90,6 +116,10 @@ func (m *Metrics) GetStats() map[string]interface{} {
 		"average_latency_ms":   avgLatency.Milliseconds(),
 		"min_latency_ms":      m.MinLatency.Milliseconds(),
 		"max_latency_ms":      m.MaxLatency.Milliseconds(),
+		"rerank_calls":              m.RerankCalls,
+		"rerank_failures":           m.RerankFailures,
+		"rerank_average_latency_ms": rerankAvgLatency.Milliseconds(),
+		"rerank_max_latency_ms":     m.RerankMaxLatency.Milliseconds(),
 	}
 }
 
@@ -106,4 +136,8 @@ func (m *Metrics) Reset() {
 	m.TotalLatency = 0
 	m.MinLatency = time.Hour
 	m.MaxLatency = 0
+	m.RerankCalls = 0
+	m.RerankFailures = 0
+	m.RerankTotalLatency = 0
+	m.RerankMaxLatency = 0
 }
@@ -1,7 +1,15 @@
 package rag
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Reranker 重排序器接口
@@ -9,6 +17,12 @@ type Reranker interface {
 	Rerank(ctx context.Context, query string, results []SearchResult) ([]SearchResult, error)
 }
 
+// RerankCandidateSizer 可选接口：重排序器声明重排前需要召回的候选数。
+// 返回 0 表示当前未启用（跳过重排序）；大于 topK 时检索会先召回该数量再重排截断。
+type RerankCandidateSizer interface {
+	RerankCandidates(ctx context.Context) int
+}
+
 // SimpleReranker 简单重排序器（按分数排序）
 type SimpleReranker struct{}
 
@@ -27,3 +41,165 @@ func (r *SimpleReranker) Rerank(ctx context.Context, query string, results []Sea
 	// 简单实现：保持原有顺序（Milvus 已经按相似度排序）
 	return results, nil
 }
+
+// 默认重排序参数
+const (
+	DefaultRerankCandidates = 30
+	DefaultRerankTimeout    = 3 * time.Second
+)
+
+// HTTPRerankerConfig 交叉编码器重排序服务配置
+type HTTPRerankerConfig struct {
+	APIURL     string        // 完整 /rerank 地址，如 https://api.jina.ai/v1/rerank
+	APIKey     string        // 为空时不发送 Authorization
+	Model      string        // 如 jina-reranker-v2-base-multilingual / BAAI/bge-reranker-v2-m3
+	Timeout    time.Duration // 单次请求超时，<=0 时使用 DefaultRerankTimeout
+	Candidates int           // 重排前召回的候选数，<=0 时使用 DefaultRerankCandidates
+}
+
+// HTTPReranker 调用 OpenAI/Jina/BGE(TEI) 兼容 /rerank 接口的交叉编码器重排序器
+type HTTPReranker struct {
+	config HTTPRerankerConfig
+	client *http.Client
+}
+
+// NewHTTPReranker 创建 HTTP 重排序器
+func NewHTTPReranker(config HTTPRerankerConfig) *HTTPReranker {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultRerankTimeout
+	}
+	if config.Candidates <= 0 {
+		config.Candidates = DefaultRerankCandidates
+	}
+	return &HTTPReranker{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// RerankCandidates 返回重排前需要召回的候选数
+func (r *HTTPReranker) RerankCandidates(ctx context.Context) int {
+	return r.config.Candidates
+}
+
+// rerankItem 统一后的单条打分
+type rerankItem struct {
+	Index          int      `json:"index"`
+	RelevanceScore *float64 `json:"relevance_score"`
+	Score          *float64 `json:"score"`
+}
+
+// Rerank 将 query 与候选片段发送到 /rerank 接口，按相关度分数降序返回（Score 替换为相关度分数）
+func (r *HTTPReranker) Rerank(ctx context.Context, query string, results []SearchResult) ([]SearchResult, error) {
+	if len(results) <= 1 {
+		return results, nil
+	}
+	documents := make([]string, len(results))
+	for i, res := range results {
+		documents[i] = res.Content
+	}
+	// documents/top_n 为 Jina、Cohere、SiliconFlow 等通用字段；texts 兼容 TEI（BGE）
+	body := map[string]interface{}{
+		"query":     query,
+		"documents": documents,
+		"texts":     documents,
+		"top_n":     len(documents),
+	}
+	if r.config.Model != "" {
+		body["model"] = r.config.Model
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化重排序请求失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.APIURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建重排序请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.config.APIKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("重排序请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取重排序响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("重排序接口返回错误: %s (状态码: %d)", strings.TrimSpace(string(respBody)), resp.StatusCode)
+	}
+
+	items, err := parseRerankResponse(respBody)
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		result SearchResult
+		score  float64
+	}
+	ranked := make([]scored, 0, len(items))
+	seen := make(map[int]bool, len(items))
+	for _, it := range items {
+		if it.Index < 0 || it.Index >= len(results) || seen[it.Index] {
+			continue
+		}
+		seen[it.Index] = true
+		score := 0.0
+		if it.RelevanceScore != nil {
+			score = *it.RelevanceScore
+		} else if it.Score != nil {
+			score = *it.Score
+		}
+		res := results[it.Index]
+		res.Score = float32(score)
+		ranked = append(ranked, scored{result: res, score: score})
+	}
+	if len(ranked) == 0 {
+		return nil, fmt.Errorf("重排序接口未返回有效结果")
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	out := make([]SearchResult, 0, len(results))
+	for _, s := range ranked {
+		out = append(out, s.result)
+	}
+	// 接口未返回的候选保持原顺序追加在末尾
+	for i, res := range results {
+		if !seen[i] {
+			out = append(out, res)
+		}
+	}
+	return out, nil
+}
+
+// parseRerankResponse 解析 {"results":[...]}（Jina/Cohere）、{"data":[...]}（部分 OpenAI 兼容网关）或裸数组（TEI）
+func parseRerankResponse(body []byte) ([]rerankItem, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []rerankItem
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, fmt.Errorf("解析重排序响应失败: %w", err)
+		}
+		return items, nil
+	}
+	var wrapped struct {
+		Results []rerankItem `json:"results"`
+		Data    []rerankItem `json:"data"`
+	}
+	if err := json.Unmarshal(trimmed, &wrapped); err != nil {
+		return nil, fmt.Errorf("解析重排序响应失败: %w", err)
+	}
+	if len(wrapped.Results) > 0 {
+		return wrapped.Results, nil
+	}
+	return wrapped.Data, nil
+}
@@ -3,7 +3,10 @@ package rag
 import (
 	"context"
 	"fmt"
+	"log"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/2930134478/AI-CS/backend/repository"
@@ -17,7 +20,8 @@ type RetrievalService struct {
 	docRepo            *repository.DocumentRepository   // 按发布状态过滤
 	kbRepo             *repository.KnowledgeBaseRepository // 按知识库「参与 RAG」过滤
 	cache              *Cache
-	reranker           *SimpleReranker
+	reranker           Reranker
+	keywordSearcher    KeywordSearcher // 可选，关键词/混合检索
 	metrics            *Metrics
 	minScore           float32 // 相似度阈值，默认 0.22（分段检索分数通常低于整篇文档）
 }
@@ -36,6 +40,16 @@ func NewRetrievalService(vectorStoreService *VectorStoreService, embeddingProvid
 	}
 }
 
+// SetKeywordSearcher 设置关键词检索实现（为空时 keyword/hybrid 模式回退为向量检索）
+func (s *RetrievalService) SetKeywordSearcher(k KeywordSearcher) {
+	s.keywordSearcher = k
+}
+
+// SetReranker 设置重排序器（为空时不做重排序）
+func (s *RetrievalService) SetReranker(r Reranker) {
+	s.reranker = r
+}
+
 // SetMinScore 设置 RAG 相似度阈值（IP/余弦，分段场景建议 0.2~0.35）
 func (s *RetrievalService) SetMinScore(score float32) {
 	if score >= 0 && score <= 1 {
@@ -48,16 +62,37 @@ func (s *RetrievalService) EnableCache(ttl time.Duration) {
 	s.cache.SetTTL(int(ttl.Seconds()))
 }
 
-// Retrieve 执行 RAG 检索
+// RetrieveOptions 检索选项。
+type RetrieveOptions struct {
+	// KnowledgeBaseIDs 限定检索的知识库集合；为空表示全部（仍受「参与 RAG」开关约束）。
+	KnowledgeBaseIDs []uint
+	// Mode 检索模式：vector（默认）/ keyword / hybrid
+	Mode RetrievalMode
+	// Source 按分段来源（页码范围、标题路径前缀）过滤；零值表示不过滤
+	Source SourceFilter
+}
+
+// Retrieve 执行 RAG 检索（knowledgeBaseID 为空表示不限知识库）
 func (s *RetrievalService) Retrieve(ctx context.Context, query string, topK int, knowledgeBaseID *uint) ([]SearchResult, error) {
+	return s.RetrieveWithOptions(ctx, query, topK, optionsForKnowledgeBase(knowledgeBaseID))
+}
+
+// RetrieveWithOptions 按选项执行 RAG 检索
+func (s *RetrievalService) RetrieveWithOptions(ctx context.Context, query string, topK int, opts RetrieveOptions) ([]SearchResult, error) {
 	startTime := time.Now()
 	cacheHit := false
 	var results []SearchResult
 	var err error
+	kbIDs := normalizeKnowledgeBaseIDs(opts.KnowledgeBaseIDs)
+	mode := NormalizeRetrievalMode(string(opts.Mode))
+	if mode != RetrievalModeVector && s.keywordSearcher == nil {
+		mode = RetrievalModeVector
+	}
 
-	// 检查缓存
+	// 检查缓存（来源过滤条件并入缓存键）
+	cacheQuery := query + opts.Source.cacheKey()
 	if s.cache != nil {
-		if cached, ok := s.cache.Get(query, topK, knowledgeBaseID); ok {
+		if cached, ok := s.cache.Get(cacheQuery, topK, kbIDs, mode); ok {
 			results = cached
 			cacheHit = true
 		}
@@ -65,49 +100,22 @@ func (s *RetrievalService) Retrieve(ctx context.Context, query string, topK int,
 
 	// 如果缓存未命中，执行检索
 	if !cacheHit {
-		svc, err := s.embeddingProvider.Get(ctx)
-		if err != nil {
-			s.metrics.RecordQuery(false, time.Since(startTime), false)
-			return nil, fmt.Errorf("获取嵌入服务失败: %w", err)
+		switch mode {
+		case RetrievalModeKeyword:
+			results, err = s.keywordRetrieve(ctx, query, topK, kbIDs, opts.Source)
+		case RetrievalModeHybrid:
+			results, err = s.hybridRetrieve(ctx, query, topK, kbIDs, opts.Source)
+		default:
+			results, err = s.vectorRetrieve(ctx, query, topK, kbIDs, opts.Source)
 		}
-		// 向量化查询
-		queryVectors, err := svc.EmbedTexts(ctx, []string{query})
 		if err != nil {
 			s.metrics.RecordQuery(false, time.Since(startTime), false)
-			return nil, fmt.Errorf("查询向量化失败: %w", err)
+			return nil, err
 		}
-		if len(queryVectors) == 0 {
-			s.metrics.RecordQuery(false, time.Since(startTime), false)
-			return nil, fmt.Errorf("未返回查询向量")
-		}
-
-		// 转换知识库 ID
-		var kbIDStr *string
-		if knowledgeBaseID != nil {
-			str := ConvertKnowledgeBaseID(*knowledgeBaseID)
-			kbIDStr = &str
-		}
-
-		// 多取一些结果，过滤未发布文档后仍能凑满 topK
-		searchLimit := topK * 3
-		if searchLimit < 10 {
-			searchLimit = 10
-		}
-		results, err = s.vectorStoreService.SearchVectors(ctx, queryVectors[0], searchLimit, kbIDStr)
-		if err != nil {
-			s.metrics.RecordQuery(false, time.Since(startTime), false)
-			return nil, fmt.Errorf("向量检索失败: %w", err)
-		}
-
-		// 仅保留「已发布」的文档参与 RAG；未在 documents 表中的条目（如 FAQ）视为可展示
-		results = s.filterByPublished(ctx, results, topK)
-
-		// 相似度阈值过滤：Milvus 使用 IP（归一化嵌入时等同余弦相似度）
-		results = s.filterByScore(results, s.minScore)
 
 		// 缓存过滤后的结果（空结果不缓存，避免误伤后续查询）
 		if s.cache != nil && len(results) > 0 {
-			s.cache.Set(query, topK, knowledgeBaseID, results)
+			s.cache.Set(cacheQuery, topK, kbIDs, mode, results)
 		}
 	}
 
@@ -117,27 +125,172 @@ func (s *RetrievalService) Retrieve(ctx context.Context, query string, topK int,
 	return results, err
 }
 
+// vectorRetrieve 向量检索：向量化查询 → Milvus（知识库与来源过滤下推）→ 发布状态过滤 → 相似度阈值过滤
+func (s *RetrievalService) vectorRetrieve(ctx context.Context, query string, topK int, kbIDs []uint, source SourceFilter) ([]SearchResult, error) {
+	svc, err := s.embeddingProvider.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取嵌入服务失败: %w", err)
+	}
+	// 向量化查询
+	queryVectors, err := svc.EmbedTexts(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("查询向量化失败: %w", err)
+	}
+	if len(queryVectors) == 0 {
+		return nil, fmt.Errorf("未返回查询向量")
+	}
+
+	// 多取一些结果，过滤未发布文档后仍能凑满 topK
+	searchLimit := topK * 3
+	if searchLimit < 10 {
+		searchLimit = 10
+	}
+	results, err := s.vectorStoreService.SearchVectorsFiltered(ctx, queryVectors[0], searchLimit, knowledgeBaseIDStrings(kbIDs), source)
+	if err != nil {
+		return nil, fmt.Errorf("向量检索失败: %w", err)
+	}
+
+	// 仅保留「已发布」的文档参与 RAG；未在 documents 表中的条目（如 FAQ）视为可展示
+	results = s.filterByPublished(ctx, results, topK)
+
+	// 相似度阈值过滤：Milvus 使用 IP（归一化嵌入时等同余弦相似度）
+	return s.filterByScore(results, s.minScore), nil
+}
+
+// FAQVectorSearch 在 FAQ 向量中做相似度检索并返回原始分数（不走缓存、不做阈值过滤）。
+// 检索下推为 Milvus 过滤（source_type 为 faq 且在知识库范围内），避免 FAQ 被大量文档分段挤出候选；
+// 结果的 DocumentID 即 FAQ ID。
+func (s *RetrievalService) FAQVectorSearch(ctx context.Context, query string, limit int, kbIDs []uint) ([]SearchResult, error) {
+	if !s.vectorStoreService.IsAvailable() {
+		return nil, fmt.Errorf("向量库不可用")
+	}
+	svc, err := s.embeddingProvider.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取嵌入服务失败: %w", err)
+	}
+	queryVectors, err := svc.EmbedTexts(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("查询向量化失败: %w", err)
+	}
+	if len(queryVectors) == 0 {
+		return nil, fmt.Errorf("未返回查询向量")
+	}
+	return s.vectorStoreService.SearchFAQVectors(ctx, queryVectors[0], limit, knowledgeBaseIDStrings(normalizeKnowledgeBaseIDs(kbIDs)))
+}
+
+// keywordRetrieve 关键词检索：全文索引 → 来源过滤 → 发布状态过滤（关键词分数不做阈值过滤）
+func (s *RetrievalService) keywordRetrieve(ctx context.Context, query string, topK int, kbIDs []uint, source SourceFilter) ([]SearchResult, error) {
+	searchLimit := topK * 3
+	if searchLimit < 10 {
+		searchLimit = 10
+	}
+	results, err := s.keywordSearcher.SearchKeyword(ctx, query, searchLimit, kbIDs)
+	if err != nil {
+		return nil, fmt.Errorf("关键词检索失败: %w", err)
+	}
+	return s.filterByPublished(ctx, filterBySource(results, source), topK), nil
+}
+
+// hybridRetrieve 混合检索：向量与关键词两路各取 topK 的若干倍，再按 RRF 融合取前 topK。
+// 任一路失败时退化为另一路的结果。
+func (s *RetrievalService) hybridRetrieve(ctx context.Context, query string, topK int, kbIDs []uint, source SourceFilter) ([]SearchResult, error) {
+	candidates := topK * 2
+	vectorResults, vecErr := s.vectorRetrieve(ctx, query, candidates, kbIDs, source)
+	keywordResults, kwErr := s.keywordRetrieve(ctx, query, candidates, kbIDs, source)
+	if vecErr != nil && kwErr != nil {
+		return nil, vecErr
+	}
+	if vecErr != nil {
+		log.Printf("⚠️ 混合检索：向量检索失败，仅使用关键词结果: %v", vecErr)
+	}
+	if kwErr != nil {
+		log.Printf("⚠️ 混合检索：关键词检索失败，仅使用向量结果: %v", kwErr)
+	}
+	return fuseRRF(topK, vectorResults, keywordResults), nil
+}
+
 // RetrieveWithRerank 执行带重排序的 RAG 检索
 func (s *RetrievalService) RetrieveWithRerank(ctx context.Context, query string, topK int, knowledgeBaseID *uint) ([]SearchResult, error) {
+	return s.RetrieveWithRerankOptions(ctx, query, topK, optionsForKnowledgeBase(knowledgeBaseID))
+}
+
+// RetrieveWithRerankOptions 按选项执行带重排序的 RAG 检索。
+// 重排序器声明了候选数（如 30）时先召回候选再重排，最终截断为 topK；重排失败时按原始顺序截断。
+func (s *RetrievalService) RetrieveWithRerankOptions(ctx context.Context, query string, topK int, opts RetrieveOptions) ([]SearchResult, error) {
+	reranker := s.reranker
+	candidates := topK
+	if sizer, ok := reranker.(RerankCandidateSizer); ok {
+		n := sizer.RerankCandidates(ctx)
+		if n <= 0 {
+			reranker = nil
+		} else if n > candidates {
+			candidates = n
+		}
+	}
+
 	// 先执行基础检索
-	results, err := s.Retrieve(ctx, query, topK, knowledgeBaseID)
+	results, err := s.RetrieveWithOptions(ctx, query, candidates, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	// 重排序
-	if s.reranker != nil {
-		reranked, err := s.reranker.Rerank(ctx, query, results)
+	if reranker != nil && len(results) > 0 {
+		start := time.Now()
+		reranked, err := reranker.Rerank(ctx, query, results)
+		s.metrics.RecordRerank(err == nil, time.Since(start))
 		if err != nil {
-			// 重排序失败不影响主流程，返回原始结果
-			return results, nil
+			// 重排序失败不影响主流程，回退为原始顺序
+			log.Printf("⚠️ 重排序失败，使用原始检索顺序: %v", err)
+		} else {
+			results = reranked
 		}
-		return reranked, nil
 	}
 
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
 	return results, nil
 }
 
+// optionsForKnowledgeBase 将单个知识库 ID 转为检索选项（兼容旧调用）
+func optionsForKnowledgeBase(knowledgeBaseID *uint) RetrieveOptions {
+	if knowledgeBaseID == nil {
+		return RetrieveOptions{}
+	}
+	return RetrieveOptions{KnowledgeBaseIDs: []uint{*knowledgeBaseID}}
+}
+
+// normalizeKnowledgeBaseIDs 去重并排序（保证缓存键稳定）
+func normalizeKnowledgeBaseIDs(ids []uint) []uint {
+	if len(ids) == 0 {
+		return nil
+	}
+	seen := make(map[uint]struct{}, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// knowledgeBaseIDStrings 转为向量库中的 knowledge_base_id 取值
+func knowledgeBaseIDStrings(ids []uint) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, ConvertKnowledgeBaseID(id))
+	}
+	return out
+}
+
 // filterByPublished 仅保留「已发布」且所属知识库已开启 RAG 的文档；FAQ 保留；取前 topK 条
 func (s *RetrievalService) filterByPublished(ctx context.Context, results []SearchResult, topK int) []SearchResult {
 	if s.docRepo == nil || len(results) == 0 {
@@ -214,6 +367,28 @@ func (s *RetrievalService) filterByPublished(ctx context.Context, results []Sear
 	return filtered
 }
 
+// filterBySource 按来源元数据精确过滤（关键词检索结果与 Milvus 表达式过滤后的结果均经此过滤）
+func filterBySource(results []SearchResult, f SourceFilter) []SearchResult {
+	if f.IsZero() {
+		return results
+	}
+	prefix := strings.TrimSpace(f.HeadingPrefix)
+	filtered := make([]SearchResult, 0, len(results))
+	for _, r := range results {
+		if f.PageFrom > 0 && r.Source.PageNumber < f.PageFrom {
+			continue
+		}
+		if f.PageTo > 0 && (r.Source.PageNumber == 0 || r.Source.PageNumber > f.PageTo) {
+			continue
+		}
+		if prefix != "" && !strings.HasPrefix(r.Source.HeadingPath, prefix) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
+
 // filterByScore 按相似度阈值过滤结果。
 // Milvus 使用 IP 度量；归一化嵌入时分数等同余弦相似度。分段后 chunk 分数普遍低于整篇文档，阈值不宜过高。
 func (s *RetrievalService) filterByScore(results []SearchResult, minScore float32) []SearchResult {
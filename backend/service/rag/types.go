@@ -1,9 +1,58 @@
 package rag
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/2930134478/AI-CS/backend/infra"
+)
+
 // SearchResult 搜索结果
 type SearchResult struct {
 	DocumentID      string
 	KnowledgeBaseID string
 	Content         string
+	ChunkID         string         // 分段 ID（document_chunks.id），整篇文档/FAQ 向量为空
+	Source          SourceMetadata // 分段的来源位置（页码、标题路径、锚点）
 	Score           float32
 }
+
+// SourceMetadata 分段的来源位置，随向量写入 Milvus 标量字段
+type SourceMetadata struct {
+	PageNumber   int    // 所在页码（PDF），0 表示未知
+	HeadingPath  string // 所属标题路径，如「第三章 > 退款」
+	SourceAnchor string // 来源锚点（网页 URL#片段）
+	SourceType   string // 来源类型：FAQ 向量为 SourceTypeFAQ，文档与分段为空
+}
+
+// SourceTypeFAQ FAQ 向量的来源类型
+const SourceTypeFAQ = infra.SourceTypeFAQ
+
+func (m SourceMetadata) toVector() infra.VectorMetadata {
+	return infra.VectorMetadata{
+		PageNumber:   int64(m.PageNumber),
+		HeadingPath:  m.HeadingPath,
+		SourceAnchor: m.SourceAnchor,
+		SourceType:   m.SourceType,
+	}
+}
+
+// SourceFilter 按来源元数据过滤检索结果；零值表示不过滤
+type SourceFilter struct {
+	PageFrom      int    // 页码下限（含），0 表示不限
+	PageTo        int    // 页码上限（含），0 表示不限
+	HeadingPrefix string // 标题路径前缀
+}
+
+// IsZero 是否未设置任何过滤条件
+func (f SourceFilter) IsZero() bool {
+	return f.PageFrom <= 0 && f.PageTo <= 0 && strings.TrimSpace(f.HeadingPrefix) == ""
+}
+
+// cacheKey 用于检索缓存键
+func (f SourceFilter) cacheKey() string {
+	if f.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("|p%d-%d|h%s", f.PageFrom, f.PageTo, strings.TrimSpace(f.HeadingPrefix))
+}
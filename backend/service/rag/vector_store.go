@@ -30,27 +30,43 @@ func (s *VectorStoreService) IsAvailable() bool {
 }
 
 // UpsertVector 插入或更新单个向量
-func (s *VectorStoreService) UpsertVector(ctx context.Context, documentID string, knowledgeBaseID string, content string, chunkDBID string, vector []float32) error {
+func (s *VectorStoreService) UpsertVector(ctx context.Context, documentID string, knowledgeBaseID string, content string, chunkDBID string, vector []float32, meta SourceMetadata) error {
 	if s.vectorStore == nil {
 		return ErrVectorStoreUnavailable
 	}
-	return s.vectorStore.UpsertVector(ctx, documentID, knowledgeBaseID, content, chunkDBID, vector)
+	return s.vectorStore.UpsertVector(ctx, documentID, knowledgeBaseID, content, chunkDBID, vector, meta.toVector())
 }
 
-// UpsertVectors 批量插入或更新向量
-func (s *VectorStoreService) UpsertVectors(ctx context.Context, documentIDs []string, knowledgeBaseIDs []string, contents []string, vectors [][]float32, chunkDBIDs []string) error {
+// UpsertVectors 批量插入或更新向量；metas 为空表示不带来源元数据
+func (s *VectorStoreService) UpsertVectors(ctx context.Context, documentIDs []string, knowledgeBaseIDs []string, contents []string, vectors [][]float32, chunkDBIDs []string, metas []SourceMetadata) error {
 	if s.vectorStore == nil {
 		return ErrVectorStoreUnavailable
 	}
-	return s.vectorStore.UpsertVectors(ctx, documentIDs, knowledgeBaseIDs, contents, vectors, chunkDBIDs)
+	var vectorMetas []infra.VectorMetadata
+	if len(metas) > 0 {
+		vectorMetas = make([]infra.VectorMetadata, len(metas))
+		for i, m := range metas {
+			vectorMetas[i] = m.toVector()
+		}
+	}
+	return s.vectorStore.UpsertVectors(ctx, documentIDs, knowledgeBaseIDs, contents, vectors, chunkDBIDs, vectorMetas)
+}
+
+// SearchVectors 搜索相似向量；knowledgeBaseIDs 为空表示不限知识库
+func (s *VectorStoreService) SearchVectors(ctx context.Context, queryVector []float32, topK int, knowledgeBaseIDs []string) ([]SearchResult, error) {
+	return s.SearchVectorsFiltered(ctx, queryVector, topK, knowledgeBaseIDs, SourceFilter{})
 }
 
-// SearchVectors 搜索相似向量
-func (s *VectorStoreService) SearchVectors(ctx context.Context, queryVector []float32, topK int, knowledgeBaseID *string) ([]SearchResult, error) {
+// SearchVectorsFiltered 搜索相似向量并按来源元数据过滤
+func (s *VectorStoreService) SearchVectorsFiltered(ctx context.Context, queryVector []float32, topK int, knowledgeBaseIDs []string, filter SourceFilter) ([]SearchResult, error) {
 	if s.vectorStore == nil {
 		return []SearchResult{}, nil
 	}
-	results, err := s.vectorStore.SearchVectors(ctx, queryVector, topK, knowledgeBaseID)
+	results, err := s.vectorStore.SearchVectorsFiltered(ctx, queryVector, topK, knowledgeBaseIDs, infra.MetadataFilter{
+		PageFrom:      filter.PageFrom,
+		PageTo:        filter.PageTo,
+		HeadingPrefix: filter.HeadingPrefix,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("向量检索失败: %w", err)
 	}
@@ -61,10 +77,41 @@ func (s *VectorStoreService) SearchVectors(ctx context.Context, queryVector []fl
 			DocumentID:      r.DocumentID,
 			KnowledgeBaseID: r.KnowledgeBaseID,
 			Content:         r.Content,
-			Score:           r.Score,
+			ChunkID:         r.ChunkDBID,
+			Source: SourceMetadata{
+				PageNumber:   int(r.Metadata.PageNumber),
+				HeadingPath:  r.Metadata.HeadingPath,
+				SourceAnchor: r.Metadata.SourceAnchor,
+				SourceType:   r.Metadata.SourceType,
+			},
+			Score: r.Score,
 		}
 	}
 
+	return filterBySource(searchResults, filter), nil
+}
+
+// SearchFAQVectors 仅在 FAQ 向量（source_type 为 faq）中检索，文档与分段向量不参与排序
+func (s *VectorStoreService) SearchFAQVectors(ctx context.Context, queryVector []float32, topK int, knowledgeBaseIDs []string) ([]SearchResult, error) {
+	if s.vectorStore == nil {
+		return []SearchResult{}, nil
+	}
+	results, err := s.vectorStore.SearchVectorsFiltered(ctx, queryVector, topK, knowledgeBaseIDs, infra.MetadataFilter{
+		SourceType: infra.SourceTypeFAQ,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("向量检索失败: %w", err)
+	}
+	searchResults := make([]SearchResult, len(results))
+	for i, r := range results {
+		searchResults[i] = SearchResult{
+			DocumentID:      r.DocumentID,
+			KnowledgeBaseID: r.KnowledgeBaseID,
+			Content:         r.Content,
+			Source:          SourceMetadata{SourceType: r.Metadata.SourceType},
+			Score:           r.Score,
+		}
+	}
 	return searchResults, nil
 }
 
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/2930134478/AI-CS/backend/service/rag"
+)
+
+// ConfigBackedReranker 基于 DB 配置的重排序器，每次调用从配置读取，保存即生效
+type ConfigBackedReranker struct {
+	configService *EmbeddingConfigService
+}
+
+// NewConfigBackedReranker 创建基于 DB 配置的重排序器
+func NewConfigBackedReranker(configService *EmbeddingConfigService) *ConfigBackedReranker {
+	return &ConfigBackedReranker{configService: configService}
+}
+
+// RerankCandidates 返回重排前需要召回的候选数；未启用重排序时返回 0
+func (r *ConfigBackedReranker) RerankCandidates(ctx context.Context) int {
+	cfg, err := r.configService.GetRerankConfig()
+	if err != nil {
+		log.Printf("⚠️ 读取重排序配置失败，跳过重排序: %v", err)
+		return 0
+	}
+	if cfg == nil {
+		return 0
+	}
+	return rag.NewHTTPReranker(*cfg).RerankCandidates(ctx)
+}
+
+// Rerank 使用当前配置的 /rerank 服务重排序；未启用时原样返回
+func (r *ConfigBackedReranker) Rerank(ctx context.Context, query string, results []rag.SearchResult) ([]rag.SearchResult, error) {
+	cfg, err := r.configService.GetRerankConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return results, nil
+	}
+	return rag.NewHTTPReranker(*cfg).Rerank(ctx, query, results)
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/infra/translate"
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+)
+
+// 翻译方式
+const (
+	TranslationProviderAI       = "ai"       // 使用 AI 文本模型
+	TranslationProviderExternal = "external" // 使用外部翻译服务（如 LibreTranslate）
+)
+
+const (
+	// 单条消息翻译的超时时间（翻译在消息送达后异步进行，超时则不推送译文）
+	translationTimeout = 15 * time.Second
+	// 默认客服工作语言
+	defaultAgentLanguage = "zh-CN"
+	// 超过该长度的消息不翻译（避免长文本拖慢投递）
+	translationMaxRunes = 4000
+)
+
+// TranslationSettings 实时翻译配置（保存在 app_settings）
+type TranslationSettings struct {
+	Enabled       bool   `json:"enabled"`        // 人工会话开启实时翻译
+	AgentLanguage string `json:"agent_language"` // 客服工作语言，访客消息译为该语言
+	Provider      string `json:"provider"`       // ai / external
+	AIConfigID    uint   `json:"ai_config_id"`   // 0 表示按会话所选模型 / 负责客服的默认文本模型
+	// ExternalAvailable 是否已配置外部翻译服务（只读，由环境变量决定）
+	ExternalAvailable bool `json:"external_available"`
+}
+
+// MessageTranslation 一条消息的翻译结果
+type MessageTranslation struct {
+	SourceLanguage string
+	Content        string
+	TargetLanguage string
+}
+
+// TranslationService 在人工访客会话中翻译双方消息：访客消息译为客服语言，客服回复译为访客语言（会话的 language）。
+// 翻译失败或超时时按原文投递，不阻断消息发送。
+type TranslationService struct {
+	aiConfigs    *repository.AIConfigRepository
+	appSettings  *repository.AppSettingRepository
+	aiService    *AIService
+	external     translate.Translator // 可选，外部翻译服务
+	systemLogSvc *SystemLogService
+}
+
+// NewTranslationService 创建实时翻译服务实例。
+func NewTranslationService(
+	aiConfigs *repository.AIConfigRepository,
+	appSettings *repository.AppSettingRepository,
+	aiService *AIService,
+	systemLogSvc *SystemLogService,
+) *TranslationService {
+	return &TranslationService{
+		aiConfigs:    aiConfigs,
+		appSettings:  appSettings,
+		aiService:    aiService,
+		systemLogSvc: systemLogSvc,
+	}
+}
+
+// SetExternalTranslator 注入外部翻译服务（配置 TRANSLATE_API_URL 时调用）
+func (s *TranslationService) SetExternalTranslator(t translate.Translator) {
+	s.external = t
+}
+
+// GetSettings 读取实时翻译配置（未设置时默认关闭、客服语言为简体中文、使用 AI 翻译）
+func (s *TranslationService) GetSettings() TranslationSettings {
+	out := TranslationSettings{
+		AgentLanguage:     defaultAgentLanguage,
+		Provider:          TranslationProviderAI,
+		ExternalAvailable: s.external != nil,
+	}
+	if s.appSettings == nil {
+		return out
+	}
+	if row, err := s.appSettings.Get(models.AppSettingKeyTranslationEnabled); err == nil && row != nil {
+		out.Enabled = row.Value == "true"
+	}
+	if row, err := s.appSettings.Get(models.AppSettingKeyTranslationAgentLanguage); err == nil && row != nil && strings.TrimSpace(row.Value) != "" {
+		out.AgentLanguage = strings.TrimSpace(row.Value)
+	}
+	if row, err := s.appSettings.Get(models.AppSettingKeyTranslationProvider); err == nil && row != nil && row.Value == TranslationProviderExternal {
+		out.Provider = TranslationProviderExternal
+	}
+	if row, err := s.appSettings.Get(models.AppSettingKeyTranslationAIConfigID); err == nil && row != nil {
+		if id, err := strconv.ParseUint(strings.TrimSpace(row.Value), 10, 64); err == nil {
+			out.AIConfigID = uint(id)
+		}
+	}
+	return out
+}
+
+// UpdateSettings 保存实时翻译配置；外部翻译需已配置服务地址，指定的 AI 配置须为已启用的文本模型
+func (s *TranslationService) UpdateSettings(settings TranslationSettings) (TranslationSettings, error) {
+	if s.appSettings == nil {
+		return settings, errors.New("配置存储不可用")
+	}
+	settings.AgentLanguage = strings.TrimSpace(settings.AgentLanguage)
+	if settings.AgentLanguage == "" {
+		settings.AgentLanguage = defaultAgentLanguage
+	}
+	if len(settings.AgentLanguage) > 20 {
+		return settings, errors.New("客服语言代码不合法")
+	}
+	switch settings.Provider {
+	case "", TranslationProviderAI:
+		settings.Provider = TranslationProviderAI
+	case TranslationProviderExternal:
+		if s.external == nil {
+			return settings, errors.New("未配置外部翻译服务（TRANSLATE_API_URL）")
+		}
+	default:
+		return settings, errors.New("不支持的翻译方式")
+	}
+	if settings.AIConfigID > 0 {
+		config, err := s.aiConfigs.GetByID(settings.AIConfigID)
+		if err != nil {
+			return settings, errors.New("AI 配置不存在")
+		}
+		if !config.IsActive || config.ModelType != "text" {
+			return settings, errors.New("翻译只能使用已启用的文本模型")
+		}
+	}
+	values := map[string]string{
+		models.AppSettingKeyTranslationEnabled:       strconv.FormatBool(settings.Enabled),
+		models.AppSettingKeyTranslationAgentLanguage: settings.AgentLanguage,
+		models.AppSettingKeyTranslationProvider:      settings.Provider,
+		models.AppSettingKeyTranslationAIConfigID:    strconv.FormatUint(uint64(settings.AIConfigID), 10),
+	}
+	for key, value := range values {
+		if err := s.appSettings.SetValue(key, value); err != nil {
+			return settings, err
+		}
+	}
+	return s.GetSettings(), nil
+}
+
+// TranslateForMessage 计算新消息的译文；无需翻译（未开启、非人工访客会话、语言相同等）或翻译失败时返回 false
+func (s *TranslationService) TranslateForMessage(conv *models.Conversation, content string, senderIsAgent bool) (MessageTranslation, bool) {
+	if s == nil || conv == nil || conv.ConversationType != "visitor" || conv.ChatMode != "human" {
+		return MessageTranslation{}, false
+	}
+	content = strings.TrimSpace(content)
+	if content == "" || len([]rune(content)) > translationMaxRunes {
+		return MessageTranslation{}, false
+	}
+	settings := s.GetSettings()
+	if !settings.Enabled {
+		return MessageTranslation{}, false
+	}
+
+	// 访客语言取会话记录的浏览器语言；未知时访客消息交由翻译方自动识别，客服回复则不翻译
+	source, target := conv.Language, settings.AgentLanguage
+	if senderIsAgent {
+		source, target = settings.AgentLanguage, conv.Language
+		if translate.BaseLanguage(target) == "" {
+			return MessageTranslation{}, false
+		}
+	}
+	if translate.SameLanguage(source, target) {
+		return MessageTranslation{}, false
+	}
+
+	translator, err := s.translator(settings, conv)
+	if err != nil {
+		s.writeLog(conv, settings.Provider, err)
+		return MessageTranslation{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), translationTimeout)
+	defer cancel()
+	translated, err := translator.Translate(ctx, content, source, target)
+	if err != nil {
+		s.writeLog(conv, settings.Provider, err)
+		return MessageTranslation{}, false
+	}
+	translated = strings.TrimSpace(translated)
+	if translated == "" || translated == content {
+		return MessageTranslation{}, false
+	}
+	return MessageTranslation{SourceLanguage: source, Content: translated, TargetLanguage: target}, true
+}
+
+// translator 按配置选择翻译实现
+func (s *TranslationService) translator(settings TranslationSettings, conv *models.Conversation) (translate.Translator, error) {
+	if settings.Provider == TranslationProviderExternal {
+		if s.external == nil {
+			return nil, errors.New("未配置外部翻译服务")
+		}
+		return s.external, nil
+	}
+	if s.aiService == nil {
+		return nil, errors.New("AI 服务不可用")
+	}
+	var preferred []uint
+	if settings.AIConfigID > 0 {
+		preferred = append(preferred, settings.AIConfigID)
+	}
+	if conv.AIConfigID != nil {
+		preferred = append(preferred, *conv.AIConfigID)
+	}
+	config, err := resolveTextAIConfig(s.aiConfigs, preferred, conv.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	return &aiTranslator{ai: s.aiService, config: config, conversationID: conv.ID}, nil
+}
+
+func (s *TranslationService) writeLog(conv *models.Conversation, provider string, cause error) {
+	if s.systemLogSvc == nil {
+		return
+	}
+	convID := conv.ID
+	_ = s.systemLogSvc.Create(CreateSystemLogInput{
+		Level:          "warn",
+		Category:       "ai",
+		Event:          "message_translation_failed",
+		Source:         "backend",
+		ConversationID: &convID,
+		Message:        "消息翻译失败，已按原文发送",
+		Meta: map[string]interface{}{
+			"provider": provider,
+			"error":    cause.Error(),
+		},
+	})
+}
@@ -1,11 +1,18 @@
 package service
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/2930134478/AI-CS/backend/models"
+)
 
 // BroadcastHub 描述 WebSocket Hub 的广播能力。
 type BroadcastHub interface {
 	BroadcastMessage(conversationID uint, messageType string, data interface{})
 	BroadcastToAllAgents(messageType string, data interface{})
+	// BroadcastToConversationAgents 仅推送给会话房间内的客服连接（不含访客）
+	BroadcastToConversationAgents(conversationID uint, messageType string, data interface{})
 }
 
 // InitConversationInput 对话初始化需要的输入数据。
@@ -19,6 +26,11 @@ type InitConversationInput struct {
 	IPAddress string
 	ChatMode   string // 对话模式：human（人工客服）、ai（AI客服）
 	AIConfigID *uint  // AI 配置 ID（访客选择的模型配置，AI 模式时必需）
+	// 知识库范围：显式 KnowledgeBaseIDs 优先，其次按 WidgetKey / Website 匹配绑定；都没有则不限定
+	WidgetKey        string
+	KnowledgeBaseIDs []uint
+	// Skills 会话所需技能标签（如 billing、english），用于按技能自动分配客服
+	Skills []string
 }
 
 // InitConversationResult 对话初始化后的返回结果。
@@ -59,6 +71,35 @@ type ConversationSummary struct {
 	UnreadCount      int64
 	LastSeenAt       *time.Time // 最后活跃时间，用于判断在线状态
 	HasParticipated  bool       // 当前用户是否参与过该会话（是否发送过消息）
+	Priority         int        // 0 低 / 1 普通 / 2 高 / 3 紧急
+	Tags             []models.Tag
+	CustomFields     map[string]interface{} // 自定义字段 key -> 取值（number 类型为 float64）
+	// 会话关闭后生成的小结（未生成时为空）
+	Summary       string
+	Category      string
+	Resolution    string // resolved / unresolved / follow_up
+	KeyEntities   []string
+	SummaryStatus string // pending / done / failed / skipped
+	SummarizedAt  *time.Time
+}
+
+// ConversationListFilter 会话列表 / 搜索的附加筛选与排序条件，零值表示不筛选、按更新时间倒序。
+type ConversationListFilter struct {
+	TagIDs      []uint   // 需同时具备的标签 ID
+	TagNames    []string // 需同时具备的标签名称
+	Priorities  []int    // 优先级（命中任一）
+	Categories  []string // 会话小结分类（命中任一）
+	Resolutions []string // 解决状态（命中任一）
+	Fields      []CustomFieldCondition
+	SortBy      string // updated_at / created_at / priority / 自定义字段 key
+	SortAsc     bool
+}
+
+// CustomFieldCondition 按自定义字段筛选的条件。
+type CustomFieldCondition struct {
+	Key   string
+	Op    string // eq（默认）/ contains / gt / gte / lt / lte
+	Value string
 }
 
 // LastMessageSummary 会话最后一条消息的摘要信息。
@@ -86,6 +127,8 @@ type ConversationDetail struct {
 	Phone     string
 	Notes     string
 	LastSeen  *time.Time
+	// KnowledgeBaseIDs 会话限定的知识库范围（空表示不限定）
+	KnowledgeBaseIDs []uint
 }
 
 // CreateMessageInput 创建消息时需要的参数。
@@ -144,16 +187,18 @@ type ProfileResult struct {
 
 // UserSummary 用户列表摘要信息（不包含密码）。
 type UserSummary struct {
-	ID                     uint      `json:"id"`
-	Username               string    `json:"username"`
-	Role                   string    `json:"role"`
-	Permissions            []string  `json:"permissions"`
-	Nickname               string    `json:"nickname"`
-	Email                  string    `json:"email"`
-	AvatarURL              string    `json:"avatar_url"`
-	ReceiveAIConversations bool      `json:"receive_ai_conversations"`
-	CreatedAt              time.Time `json:"created_at"`
-	UpdatedAt              time.Time `json:"updated_at"`
+	ID                         uint      `json:"id"`
+	Username                   string    `json:"username"`
+	Role                       string    `json:"role"`
+	Permissions                []string  `json:"permissions"`
+	Nickname                   string    `json:"nickname"`
+	Email                      string    `json:"email"`
+	AvatarURL                  string    `json:"avatar_url"`
+	ReceiveAIConversations     bool      `json:"receive_ai_conversations"`
+	Skills                     []string  `json:"skills"`                       // 技能标签（用于按技能分配会话）
+	MaxConcurrentConversations int       `json:"max_concurrent_conversations"` // 同时接待会话上限（0 表示不限）
+	CreatedAt                  time.Time `json:"created_at"`
+	UpdatedAt                  time.Time `json:"updated_at"`
 }
 
 // CreateUserInput 创建用户输入。
@@ -168,12 +213,14 @@ type CreateUserInput struct {
 
 // UpdateUserInput 更新用户输入。
 type UpdateUserInput struct {
-	UserID                 uint    // 用户ID（必需）
-	Role                   *string // 角色（可选）
-	Permissions            *[]string // 功能权限（可选；role=admin 时忽略）
-	Nickname               *string // 昵称（可选）
-	Email                  *string // 邮箱（可选）
-	ReceiveAIConversations *bool   // 是否接收 AI 对话（可选）
+	UserID                     uint      // 用户ID（必需）
+	Role                       *string   // 角色（可选）
+	Permissions                *[]string // 功能权限（可选；role=admin 时忽略）
+	Nickname                   *string   // 昵称（可选）
+	Email                      *string   // 邮箱（可选）
+	ReceiveAIConversations     *bool     // 是否接收 AI 对话（可选）
+	Skills                     *[]string // 技能标签（可选）
+	MaxConcurrentConversations *int      // 同时接待会话上限（可选，0 表示不限）
 }
 
 // UpdatePasswordInput 更新密码输入。
@@ -225,10 +272,18 @@ type DocumentSummary struct {
 	Type             string    `json:"type"`
 	Status           string    `json:"status"`
 	EmbeddingStatus  string    `json:"embedding_status"`
+	SourceName       string    `json:"source_name,omitempty"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 }
 
+// DocumentSearchFilter 按分段来源过滤检索结果（零值表示不过滤）。
+type DocumentSearchFilter struct {
+	PageFrom      int    // 起始页码（含）
+	PageTo        int    // 结束页码（含）
+	HeadingPrefix string // 标题路径前缀，如「第三章」
+}
+
 // CreateDocumentInput 创建文档输入。
 type CreateDocumentInput struct {
 	KnowledgeBaseID uint            // 知识库 ID（必需）
@@ -297,6 +352,10 @@ type GenerateAIResponseInput struct {
 	UseWebSearch     *bool               // 是否允许联网，默认 false
 	NeedWebSearch    bool                // 本回合是否请求联网（如用户点击按钮），默认 false
 	Attachment       *MessageAttachment   // 当前条消息的附件（如图片），用于多模态识图
+	// OnDelta 非空时最终一次大模型调用走流式输出，每段增量文本回调一次（含工具调用后的作答轮；FAQ 直出、生图等路径不回调）
+	OnDelta func(delta string)
+	// Context 用于取消流式生成（如访客发送新消息时中断上一条回复），为空则不可取消
+	Context context.Context
 }
 
 // GenerateAIResponseResult 生成 AI 回复的结果（内容 + 使用的数据源标记）。
@@ -307,4 +366,15 @@ type GenerateAIResponseResult struct {
 	GeneratedFileURL *string
 	// GenerationFailed 为 true 表示大模型调用失败，内容为兜底话术（仍返回 err==nil 时由 message 层写入 is_ai_generation_failed）
 	GenerationFailed bool
+	// Cancelled 为 true 表示流式生成被中途取消，Content 为已生成的部分内容
+	Cancelled bool
+	// HandoffRequested 为 true 表示模型请求转人工（transfer_to_human 工具或标记），由 message 层执行转接
+	HandoffRequested bool
+	// AIConfigID、AIModel 为实际作答的 AI 配置与模型（故障转移时为备用配置；未调用模型时为零值）
+	AIConfigID uint
+	AIModel    string
+	// Usage 本次生成的 token 用量与费用汇总（未调用模型或未启用统计时为 nil）
+	Usage *AIUsageSummary
+	// Citations 回复引用的来源（知识库文档 / FAQ / 联网结果），编号与回复中的 [n] 对应
+	Citations []models.MessageCitation
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/2930134478/AI-CS/backend/models"
 	"github.com/2930134478/AI-CS/backend/repository"
+	"github.com/2930134478/AI-CS/backend/utils"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -72,16 +73,18 @@ func (s *UserService) ListUsers() ([]UserSummary, error) {
 	summaries := make([]UserSummary, 0, len(users))
 	for _, user := range users {
 		summaries = append(summaries, UserSummary{
-			ID:                     user.ID,
-			Username:               user.Username,
-			Role:                   user.Role,
-			Permissions:            s.EffectivePermissions(&user),
-			Nickname:               user.Nickname,
-			Email:                  user.Email,
-			AvatarURL:              user.AvatarURL,
-			ReceiveAIConversations: user.ReceiveAIConversations,
-			CreatedAt:              user.CreatedAt,
-			UpdatedAt:              user.UpdatedAt,
+			ID:                         user.ID,
+			Username:                   user.Username,
+			Role:                       user.Role,
+			Permissions:                s.EffectivePermissions(&user),
+			Nickname:                   user.Nickname,
+			Email:                      user.Email,
+			AvatarURL:                  user.AvatarURL,
+			ReceiveAIConversations:     user.ReceiveAIConversations,
+			Skills:                     utils.SplitTags(user.Skills),
+			MaxConcurrentConversations: user.MaxConcurrentConversations,
+			CreatedAt:                  user.CreatedAt,
+			UpdatedAt:                  user.UpdatedAt,
 		})
 	}
 
@@ -99,16 +102,18 @@ func (s *UserService) GetUser(id uint) (*UserSummary, error) {
 	}
 
 	return &UserSummary{
-		ID:                     user.ID,
-		Username:               user.Username,
-		Role:                   user.Role,
-		Permissions:            s.EffectivePermissions(user),
-		Nickname:               user.Nickname,
-		Email:                  user.Email,
-		AvatarURL:              user.AvatarURL,
-		ReceiveAIConversations: user.ReceiveAIConversations,
-		CreatedAt:              user.CreatedAt,
-		UpdatedAt:              user.UpdatedAt,
+		ID:                         user.ID,
+		Username:                   user.Username,
+		Role:                       user.Role,
+		Permissions:                s.EffectivePermissions(user),
+		Nickname:                   user.Nickname,
+		Email:                      user.Email,
+		AvatarURL:                  user.AvatarURL,
+		ReceiveAIConversations:     user.ReceiveAIConversations,
+		Skills:                     utils.SplitTags(user.Skills),
+		MaxConcurrentConversations: user.MaxConcurrentConversations,
+		CreatedAt:                  user.CreatedAt,
+		UpdatedAt:                  user.UpdatedAt,
 	}, nil
 }
 
@@ -171,16 +176,18 @@ func (s *UserService) CreateUser(input CreateUserInput) (*UserSummary, error) {
 	}
 
 	return &UserSummary{
-		ID:                     user.ID,
-		Username:               user.Username,
-		Role:                   user.Role,
-		Permissions:            s.EffectivePermissions(user),
-		Nickname:               user.Nickname,
-		Email:                  user.Email,
-		AvatarURL:              user.AvatarURL,
-		ReceiveAIConversations: user.ReceiveAIConversations,
-		CreatedAt:              user.CreatedAt,
-		UpdatedAt:              user.UpdatedAt,
+		ID:                         user.ID,
+		Username:                   user.Username,
+		Role:                       user.Role,
+		Permissions:                s.EffectivePermissions(user),
+		Nickname:                   user.Nickname,
+		Email:                      user.Email,
+		AvatarURL:                  user.AvatarURL,
+		ReceiveAIConversations:     user.ReceiveAIConversations,
+		Skills:                     utils.SplitTags(user.Skills),
+		MaxConcurrentConversations: user.MaxConcurrentConversations,
+		CreatedAt:                  user.CreatedAt,
+		UpdatedAt:                  user.UpdatedAt,
 	}, nil
 }
 
@@ -245,6 +252,17 @@ func (s *UserService) UpdateUser(input UpdateUserInput) (*UserSummary, error) {
 		updates["receive_ai_conversations"] = *input.ReceiveAIConversations
 	}
 
+	// 更新技能标签与接待上限（会话自动分配使用）
+	if input.Skills != nil {
+		updates["skills"] = strings.Join(utils.SplitTags(strings.Join(*input.Skills, ",")), ",")
+	}
+	if input.MaxConcurrentConversations != nil {
+		if *input.MaxConcurrentConversations < 0 {
+			return nil, errors.New("接待上限不能为负数")
+		}
+		updates["max_concurrent_conversations"] = *input.MaxConcurrentConversations
+	}
+
 	// 如果没有需要更新的字段，直接返回
 	if len(updates) == 0 {
 		return s.GetUser(input.UserID)
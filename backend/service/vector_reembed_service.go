@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/2930134478/AI-CS/backend/models"
+	"github.com/2930134478/AI-CS/backend/repository"
+)
+
+// reembedDocumentBatch 重新向量化时每批读取的文档数
+const reembedDocumentBatch = 100
+
+// VectorReembedService 向量集合被清空重建（旧数据无法迁移）后，重新向量化全部文档、分段与 FAQ。
+type VectorReembedService struct {
+	docRepo         *repository.DocumentRepository
+	chunkRepo       *repository.DocumentChunkRepository
+	faqRepo         *repository.FAQRepository
+	documentService *DocumentService
+	chunkService    *ChunkService
+	faqService      *FAQService
+}
+
+// NewVectorReembedService 创建 VectorReembedService 实例。
+func NewVectorReembedService(
+	docRepo *repository.DocumentRepository,
+	chunkRepo *repository.DocumentChunkRepository,
+	faqRepo *repository.FAQRepository,
+	documentService *DocumentService,
+	chunkService *ChunkService,
+	faqService *FAQService,
+) *VectorReembedService {
+	return &VectorReembedService{
+		docRepo:         docRepo,
+		chunkRepo:       chunkRepo,
+		faqRepo:         faqRepo,
+		documentService: documentService,
+		chunkService:    chunkService,
+		faqService:      faqService,
+	}
+}
+
+// ReembedAll 将文档、分段与 FAQ 的向量化状态重置为 pending，并在后台逐个重新向量化（避免并发打满嵌入服务）。
+func (s *VectorReembedService) ReembedAll() error {
+	if err := s.docRepo.MarkUnchunkedEmbeddingPending(); err != nil {
+		return err
+	}
+	if err := s.chunkRepo.MarkAllEmbeddingPending(); err != nil {
+		return err
+	}
+	if err := s.faqRepo.MarkAllEmbeddingPending(); err != nil {
+		return err
+	}
+	go s.reembedAll(context.Background())
+	return nil
+}
+
+// reembedAll 依次重新向量化全部文档（已分段的按分段）与 FAQ
+func (s *VectorReembedService) reembedAll(ctx context.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[重新向量化] panic: %v", r)
+		}
+	}()
+
+	log.Printf("[重新向量化] 开始")
+	docCount := 0
+	for afterID := uint(0); ; {
+		docs, err := s.docRepo.ListAfterID(afterID, reembedDocumentBatch)
+		if err != nil {
+			log.Printf("[重新向量化] 读取文档失败: %v", err)
+			break
+		}
+		for i := range docs {
+			s.reembedDocument(ctx, &docs[i])
+		}
+		docCount += len(docs)
+		if len(docs) < reembedDocumentBatch {
+			break
+		}
+		afterID = docs[len(docs)-1].ID
+	}
+
+	faqs, err := s.faqRepo.List(nil)
+	if err != nil {
+		log.Printf("[重新向量化] 读取 FAQ 失败: %v", err)
+	}
+	for i := range faqs {
+		s.faqService.embedFAQAsync(ctx, faqs[i].ID, &faqs[i])
+	}
+	log.Printf("[重新向量化] 完成：文档 %d 篇，FAQ %d 条（失败项见各自的向量状态）", docCount, len(faqs))
+}
+
+// reembedDocument 重新向量化单篇文档：有分段时写入分段向量，否则写入整篇向量
+func (s *VectorReembedService) reembedDocument(ctx context.Context, doc *models.Document) {
+	chunks, err := s.chunkRepo.GetByDocumentID(doc.ID)
+	if err != nil {
+		log.Printf("[重新向量化] 读取文档 %d 的分段失败: %v", doc.ID, err)
+		return
+	}
+	if len(chunks) == 0 {
+		s.documentService.embedDocumentAsync(ctx, doc.ID, doc.KnowledgeBaseID, doc.Content)
+		return
+	}
+	list := make([]*models.DocumentChunk, len(chunks))
+	for i := range chunks {
+		list[i] = &chunks[i]
+	}
+	s.chunkService.embedChunkList(ctx, doc.ID, doc.KnowledgeBaseID, list)
+}
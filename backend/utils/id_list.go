@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseUintList 解析逗号分隔的 ID 列表（如 "1,3,5"），忽略空项与非法项并去重。
+func ParseUintList(s string) []uint {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	seen := make(map[uint]struct{})
+	var out []uint
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		if _, ok := seen[uint(id)]; ok {
+			continue
+		}
+		seen[uint(id)] = struct{}{}
+		out = append(out, uint(id))
+	}
+	return out
+}
+
+// JoinUintList 将 ID 列表拼接为逗号分隔字符串（去重，保持原顺序）。
+func JoinUintList(ids []uint) string {
+	seen := make(map[uint]struct{}, len(ids))
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		parts = append(parts, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// SplitTags 解析逗号分隔的标签（如 "billing, english"），去空白、去重（不区分大小写）。
+func SplitTags(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	seen := make(map[string]struct{})
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		tag := strings.TrimSpace(part)
+		if tag == "" {
+			continue
+		}
+		key := strings.ToLower(tag)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, tag)
+	}
+	return out
+}
@@ -0,0 +1,25 @@
+package utils
+
+import "unicode"
+
+// EstimateTokens 粗略估算文本的 token 数（不依赖具体分词器）：
+// 中日韩字符按 1 个/token，其余字符按约 4 个/token，另为每段文本计入少量结构开销。
+func EstimateTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	return CountTextTokens(s) + 4
+}
+
+// CountTextTokens 与 EstimateTokens 估算方式相同，但不计结构开销（用于分段长度等按纯文本计量的场景）。
+func CountTextTokens(s string) int {
+	cjk, other := 0, 0
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
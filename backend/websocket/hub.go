@@ -49,9 +49,10 @@ type Hub struct {
 // Message 是要广播的消息
 type Message struct {
 	ConversationID uint        `json:"conversation_id"`
-	Data           interface{} `json:"data"`            // 消息内容（可以是 Message 对象）
-	Type           string      `json:"type"`            // 消息类型：new_message, conversation_update 等
-	Scope          string      `json:"scope,omitempty"` // conversation | all_agents
+	Data           interface{} `json:"data"`                 // 消息内容（可以是 Message 对象）
+	Type           string      `json:"type"`                 // 消息类型：new_message, conversation_update 等
+	Scope          string      `json:"scope,omitempty"`      // conversation | conversation_agents | all_agents
+	AgentOnly      bool        `json:"agent_only,omitempty"` // 仅下发给客服连接（whisper 等内部消息），随分布式总线传递
 	FromRemote     bool        `json:"-"`
 }
 
@@ -164,6 +165,8 @@ func (h *Hub) Run() {
 			if message.Scope == "all_agents" {
 				clients := h.snapshotAllAgents()
 				h.sendToClients(clients, message)
+			} else if message.Scope == "conversation_agents" {
+				h.sendToClients(h.snapshotConversationAgents(message.ConversationID), message)
 			} else {
 				clients := h.snapshotConversationClients(message.ConversationID)
 				if len(clients) == 0 {
@@ -189,6 +192,7 @@ func (h *Hub) BroadcastMessage(conversationID uint, messageType string, data int
 		Type:           messageType,
 		Data:           data,
 		Scope:          "conversation",
+		AgentOnly:      isWhisperData(data),
 	}
 }
 
@@ -200,6 +204,19 @@ func (h *Hub) BroadcastToAllAgents(messageType string, data interface{}) {
 		Type:           messageType,
 		Data:           data,
 		Scope:          "all_agents",
+		AgentOnly:      true,
+	}
+}
+
+// BroadcastToConversationAgents 广播消息到指定对话房间内的客服客户端（不推送给访客）
+// 用于 whisper 内部备注等仅客服可见的事件
+func (h *Hub) BroadcastToConversationAgents(conversationID uint, messageType string, data interface{}) {
+	h.broadcast <- &Message{
+		ConversationID: conversationID,
+		Type:           messageType,
+		Data:           data,
+		Scope:          "conversation_agents",
+		AgentOnly:      true,
 	}
 }
 
@@ -248,6 +265,19 @@ func (h *Hub) snapshotConversationClients(conversationID uint) []*Client {
 	return out
 }
 
+func (h *Hub) snapshotConversationAgents(conversationID uint) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	clients := h.conversations[conversationID]
+	out := make([]*Client, 0, len(clients))
+	for c := range clients {
+		if !c.isVisitor {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
 func (h *Hub) snapshotAllAgents() []*Client {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -264,6 +294,10 @@ func (h *Hub) snapshotAllAgents() []*Client {
 
 func (h *Hub) sendToClients(clients []*Client, message *Message) {
 	for _, client := range clients {
+		// 仅客服可见的消息（如 whisper 内部备注）无论经由哪种广播范围，都不下发给访客连接
+		if message.AgentOnly && client.isVisitor {
+			continue
+		}
 		select {
 		case client.send <- message:
 		default:
@@ -288,6 +322,12 @@ func safeClose(ch chan *Message) {
 	close(ch)
 }
 
+// isWhisperData 判断广播数据是否为 whisper 内部备注消息（仅在本地发起广播时判断，远端消息以 AgentOnly 为准）
+func isWhisperData(data interface{}) bool {
+	msg, ok := data.(*models.Message)
+	return ok && msg != nil && msg.MessageType == models.MessageTypeWhisper
+}
+
 func conversationIDFromData(data interface{}, fallback uint) uint {
 	if msg, ok := data.(*models.Message); ok {
 		return msg.ConversationID
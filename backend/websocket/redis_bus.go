@@ -21,6 +21,7 @@ type redisWireMessage struct {
 	ConversationID uint            `json:"conversation_id"`
 	Type           string          `json:"type"`
 	Scope          string          `json:"scope,omitempty"`
+	AgentOnly      bool            `json:"agent_only,omitempty"`
 	Data           json.RawMessage `json:"data"`
 	Source         string          `json:"source"`
 }
@@ -93,6 +94,7 @@ func (r *RedisBus) Publish(msg *Message) error {
 		ConversationID: msg.ConversationID,
 		Type:           msg.Type,
 		Scope:          msg.Scope,
+		AgentOnly:      msg.AgentOnly,
 		Data:           dataBytes,
 		Source:         r.nodeID,
 	}
@@ -126,6 +128,7 @@ func (r *RedisBus) Subscribe(handler func(msg *Message)) {
 				ConversationID: wire.ConversationID,
 				Type:           wire.Type,
 				Scope:          wire.Scope,
+				AgentOnly:      wire.AgentOnly,
 				Data:           data,
 				FromRemote:     true,
 			})
@@ -20,6 +20,7 @@ import {
   ConversationSummary,
   MessageItem,
   MessagesReadPayload,
+  MessageTranslatedPayload,
   ChatWebSocketPayload,
 } from "@/features/agent/types";
 import type { WSMessage } from "@/lib/websocket";
@@ -375,6 +376,23 @@ export default function AgentChatPage() {
           payload.conversation_id = event.conversation_id;
         }
         handleMessagesReadEvent(payload);
+      } else if (event.type === "message_translated" && event.data) {
+        // 译文在消息送达后异步生成，按消息 ID 补到已显示的消息上
+        const payload = event.data as MessageTranslatedPayload;
+        if (payload.message_id) {
+          setMessages((prev) =>
+            prev.map((msg) =>
+              msg.id === payload.message_id
+                ? {
+                    ...msg,
+                    source_language: payload.source_language,
+                    translated_content: payload.translated_content,
+                    translated_language: payload.translated_language,
+                  }
+                : msg
+            )
+          );
+        }
       }
     },
     [handleMessagesReadEvent, handleNewMessage]
@@ -18,9 +18,11 @@ import {
 import {
   fetchChunks,
   executeChunking,
+  previewChunking,
   updateChunk,
   deleteChunks,
   type DocumentChunk,
+  type ChunkPreview,
 } from "@/features/agent/services/chunkApi";
 import {
   ChevronLeft,
@@ -33,9 +35,11 @@ import {
   CheckCircle2,
   Clock,
   XCircle,
+  Eye,
 } from "lucide-react";
 
-type ChunkMethod = "char_count" | "separator";
+type ChunkMethod = "char_count" | "separator" | "recursive" | "markdown";
+type SizeUnit = "char" | "token";
 
 interface DocumentDetailPageProps {
   embedded?: boolean;
@@ -64,7 +68,11 @@ export default function DocumentDetailPage(props: DocumentDetailPageProps = {})
   const [method, setMethod] = useState<ChunkMethod>("char_count");
   const [chunkSize, setChunkSize] = useState(500);
   const [separator, setSeparator] = useState("");
+  const [sizeUnit, setSizeUnit] = useState<SizeUnit>("char");
+  const [overlap, setOverlap] = useState(50);
   const [executing, setExecuting] = useState(false);
+  const [previewing, setPreviewing] = useState(false);
+  const [previews, setPreviews] = useState<ChunkPreview[] | null>(null);
 
   const [editChunk, setEditChunk] = useState<DocumentChunk | null>(null);
   const [editContent, setEditContent] = useState("");
@@ -120,18 +128,48 @@ export default function DocumentDetailPage(props: DocumentDetailPageProps = {})
     return () => clearInterval(timer);
   }, [chunks, loadChunks, chunkPage]);
 
-  const handleExecute = async () => {
+  const structured = method === "recursive" || method === "markdown";
+
+  const buildRequest = () => ({
+    method,
+    chunk_size: method !== "separator" ? chunkSize : undefined,
+    separator: method === "separator" ? separator : undefined,
+    overlap: structured ? overlap : undefined,
+    size_unit: structured ? sizeUnit : undefined,
+  });
+
+  const validateRequest = () => {
     if (method === "separator" && !separator) {
       toast.error("请输入分隔符");
-      return;
+      return false;
     }
+    if (structured && overlap * 2 >= chunkSize) {
+      toast.error("重叠长度须小于每段长度的一半");
+      return false;
+    }
+    return true;
+  };
+
+  const handlePreview = async () => {
+    if (!validateRequest()) return;
+    try {
+      setPreviewing(true);
+      const res = await previewChunking(docIdNum, buildRequest());
+      setPreviews(res.chunks || []);
+    } catch (e: unknown) {
+      const msg = e instanceof Error ? e.message : "预览失败";
+      toast.error(msg);
+    } finally {
+      setPreviewing(false);
+    }
+  };
+
+  const handleExecute = async () => {
+    if (!validateRequest()) return;
     try {
       setExecuting(true);
-      const res = await executeChunking(docIdNum, {
-        method,
-        chunk_size: method === "char_count" ? chunkSize : undefined,
-        separator: method === "separator" ? separator : undefined,
-      });
+      const res = await executeChunking(docIdNum, buildRequest());
+      setPreviews(null);
       setChunks(res.chunks || []);
       setChunkPage(1);
       toast.success(`分段完成，共 ${res.chunk_count} 段`);
@@ -219,6 +257,12 @@ export default function DocumentDetailPage(props: DocumentDetailPageProps = {})
     return text.slice(0, maxLen) + "...";
   };
 
+  // 分段来源：页码与标题路径，如「第 12 页 · 第三章 > 退款」
+  const sourceLabel = (c: { page_number?: number; heading_path?: string }) =>
+    [c.page_number ? `第 ${c.page_number} 页` : "", c.heading_path || ""]
+      .filter(Boolean)
+      .join(" · ");
+
   const headerContent = (
     <div className="flex items-center gap-3">
       <Button
@@ -277,22 +321,66 @@ export default function DocumentDetailPage(props: DocumentDetailPageProps = {})
         >
           按分隔符
         </Button>
+        <Button
+          variant={method === "recursive" ? "default" : "outline"}
+          size="sm"
+          onClick={() => setMethod("recursive")}
+        >
+          按段落 / 句子
+        </Button>
+        <Button
+          variant={method === "markdown" ? "default" : "outline"}
+          size="sm"
+          onClick={() => setMethod("markdown")}
+        >
+          按 Markdown 标题
+        </Button>
       </div>
 
-      <div className="flex items-end gap-3">
-        {method === "char_count" ? (
-          <div className="flex-1 max-w-[200px]">
-            <label className="text-sm text-muted-foreground mb-1 block">
-              每段字数
-            </label>
-            <Input
-              type="number"
-              min={100}
-              max={10000}
-              value={chunkSize}
-              onChange={(e) => setChunkSize(Number(e.target.value) || 500)}
-            />
-          </div>
+      <div className="flex flex-wrap items-end gap-3">
+        {method !== "separator" ? (
+          <>
+            <div className="flex-1 max-w-[160px]">
+              <label className="text-sm text-muted-foreground mb-1 block">
+                {structured && sizeUnit === "token" ? "每段 Token 数" : "每段字数"}
+              </label>
+              <Input
+                type="number"
+                min={100}
+                max={10000}
+                value={chunkSize}
+                onChange={(e) => setChunkSize(Number(e.target.value) || 500)}
+              />
+            </div>
+            {structured && (
+              <>
+                <div className="max-w-[120px]">
+                  <label className="text-sm text-muted-foreground mb-1 block">
+                    长度单位
+                  </label>
+                  <select
+                    className="h-9 w-full rounded-md border bg-background px-2 text-sm"
+                    value={sizeUnit}
+                    onChange={(e) => setSizeUnit(e.target.value as SizeUnit)}
+                  >
+                    <option value="char">字符</option>
+                    <option value="token">Token</option>
+                  </select>
+                </div>
+                <div className="max-w-[120px]">
+                  <label className="text-sm text-muted-foreground mb-1 block">
+                    重叠长度
+                  </label>
+                  <Input
+                    type="number"
+                    min={0}
+                    value={overlap}
+                    onChange={(e) => setOverlap(Math.max(0, Number(e.target.value) || 0))}
+                  />
+                </div>
+              </>
+            )}
+          </>
         ) : (
           <div className="flex-1 max-w-[300px]">
             <label className="text-sm text-muted-foreground mb-1 block">
@@ -305,6 +393,14 @@ export default function DocumentDetailPage(props: DocumentDetailPageProps = {})
             />
           </div>
         )}
+        <Button variant="outline" onClick={handlePreview} disabled={previewing}>
+          {previewing ? (
+            <Loader2 className="w-4 h-4 mr-1 animate-spin" />
+          ) : (
+            <Eye className="w-4 h-4 mr-1" />
+          )}
+          预览
+        </Button>
         <Button onClick={handleExecute} disabled={executing}>
           {executing && <Loader2 className="w-4 h-4 mr-1 animate-spin" />}
           执行分段
@@ -325,9 +421,45 @@ export default function DocumentDetailPage(props: DocumentDetailPageProps = {})
           执行新分段将自动替换旧分段并重新向量化
         </p>
       )}
+      {method === "markdown" && (
+        <p className="text-xs text-muted-foreground mt-2">
+          按标题分节，每段开头附带所属标题路径（如「产品 &gt; 价格」），便于检索时定位章节
+        </p>
+      )}
     </Card>
   );
 
+  const previewList = previews && (
+    <div className="space-y-3">
+      <h2 className="font-medium flex items-center gap-2">
+        <Eye className="w-4 h-4" />
+        分段预览（共 {previews.length} 段，未保存）
+        <Button
+          variant="ghost"
+          size="sm"
+          className="ml-auto"
+          onClick={() => setPreviews(null)}
+        >
+          关闭预览
+        </Button>
+      </h2>
+      {previews.map((p) => (
+        <Card key={p.index} className="p-4 border-dashed">
+          <div className="flex items-center gap-2 mb-2 text-sm text-muted-foreground">
+            <span className="font-medium">第 {p.index + 1} 段</span>
+            <span className="text-xs">
+              {p.chars} 字 · 约 {p.tokens} Token
+            </span>
+            {sourceLabel(p) && (
+              <span className="text-xs truncate">{sourceLabel(p)}</span>
+            )}
+          </div>
+          <p className="text-sm whitespace-pre-wrap">{p.content}</p>
+        </Card>
+      ))}
+    </div>
+  );
+
   const chunkList = (
     <div className="space-y-3">
       <h2 className="font-medium flex items-center gap-2">
@@ -358,6 +490,11 @@ export default function DocumentDetailPage(props: DocumentDetailPageProps = {})
                     <span className="text-xs text-muted-foreground">
                       {chunk.content.length} 字
                     </span>
+                    {sourceLabel(chunk) && (
+                      <span className="text-xs text-muted-foreground truncate">
+                        {sourceLabel(chunk)}
+                      </span>
+                    )}
                   </div>
                   <p className="text-sm whitespace-pre-wrap line-clamp-3">
                     {truncate(chunk.content, 200)}
@@ -418,7 +555,7 @@ export default function DocumentDetailPage(props: DocumentDetailPageProps = {})
       ) : doc ? (
         <>
           {chunkControls}
-          {chunkList}
+          {previewList || chunkList}
         </>
       ) : null}
     </div>
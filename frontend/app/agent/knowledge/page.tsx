@@ -31,7 +31,12 @@ import {
 import {
   importDocuments,
   importFromUrls,
+  listCrawlSources,
+  createCrawlSource,
+  runCrawlSource,
+  deleteCrawlSource,
   type ImportResult,
+  type CrawlSource,
 } from "@/features/agent/services/importApi";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
@@ -61,6 +66,8 @@ import {
   ChevronLeft,
   ChevronRight,
   Scissors,
+  Globe,
+  RefreshCw,
 } from "lucide-react";
 import { Textarea } from "@/components/ui/textarea";
 import { toast } from "@/hooks/useToast";
@@ -105,7 +112,7 @@ export default function KnowledgePage(props: any = {}) {
   const [editDocDialogOpen, setEditDocDialogOpen] = useState(false);
   const [deleteDocDialogOpen, setDeleteDocDialogOpen] = useState(false);
   const [importDialogOpen, setImportDialogOpen] = useState(false);
-  const [importTab, setImportTab] = useState<"file" | "url">("file");
+  const [importTab, setImportTab] = useState<"file" | "url" | "crawl">("file");
   const [selectedDocument, setSelectedDocument] = useState<Document | null>(null);
 
   // 表单状态
@@ -126,6 +133,14 @@ export default function KnowledgePage(props: any = {}) {
   const [editDocForm, setEditDocForm] = useState<UpdateDocumentRequest>({});
   const [importUrls, setImportUrls] = useState<string>("");
   const [importFiles, setImportFiles] = useState<File[]>([]);
+  const [crawlForm, setCrawlForm] = useState({
+    seed_url: "",
+    max_depth: 2,
+    max_pages: 50,
+    interval_hours: 0,
+    chunk_method: "",
+  });
+  const [crawlSources, setCrawlSources] = useState<CrawlSource[]>([]);
 
   // 加载知识库列表（不依赖 selectedKnowledgeBase，避免选中后反复触发 effect 导致疯狂刷新）
   const loadKnowledgeBases = useCallback(async () => {
@@ -509,6 +524,88 @@ export default function KnowledgePage(props: any = {}) {
     }
   };
 
+  // 加载网站抓取来源
+  const loadCrawlSources = useCallback(async () => {
+    if (!selectedKnowledgeBase) {
+      setCrawlSources([]);
+      return;
+    }
+    try {
+      setCrawlSources(await listCrawlSources(selectedKnowledgeBase.id));
+    } catch (error) {
+      toast.error((error as Error).message || t("agent.knowledge.toast.crawlLoadFailed"));
+    }
+  }, [selectedKnowledgeBase, t]);
+
+  useEffect(() => {
+    if (importDialogOpen && importTab === "crawl") {
+      void loadCrawlSources();
+    }
+  }, [importDialogOpen, importTab, loadCrawlSources]);
+
+  // 抓取在后台进行：有来源处于 running 时定时刷新状态
+  useEffect(() => {
+    if (!importDialogOpen || !crawlSources.some((s) => s.status === "running")) {
+      return;
+    }
+    const id = window.setInterval(() => {
+      void loadCrawlSources();
+      void loadDocuments({ silent: true });
+    }, 5000);
+    return () => window.clearInterval(id);
+  }, [importDialogOpen, crawlSources, loadCrawlSources, loadDocuments]);
+
+  // 添加网站抓取来源并立即抓取
+  const handleCreateCrawlSource = async () => {
+    if (!selectedKnowledgeBase) {
+      toast.error(t("agent.knowledge.toast.selectKbFirst"));
+      return;
+    }
+    const seedUrl = crawlForm.seed_url.trim();
+    if (!seedUrl) {
+      toast.error(t("agent.knowledge.toast.urlRequired"));
+      return;
+    }
+    setSubmitting(true);
+    try {
+      await createCrawlSource({
+        knowledge_base_id: selectedKnowledgeBase.id,
+        seed_url: seedUrl,
+        max_depth: crawlForm.max_depth,
+        max_pages: crawlForm.max_pages,
+        interval_hours: crawlForm.interval_hours,
+        chunk_method: crawlForm.chunk_method,
+        run_now: true,
+      });
+      toast.success(t("agent.knowledge.toast.crawlStarted"));
+      setCrawlForm((f) => ({ ...f, seed_url: "" }));
+      await loadCrawlSources();
+    } catch (error) {
+      toast.error((error as Error).message || t("agent.knowledge.toast.crawlCreateFailed"));
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleRunCrawlSource = async (id: number) => {
+    try {
+      await runCrawlSource(id);
+      toast.success(t("agent.knowledge.toast.crawlStarted"));
+      await loadCrawlSources();
+    } catch (error) {
+      toast.error((error as Error).message || t("agent.knowledge.toast.crawlRunFailed"));
+    }
+  };
+
+  const handleDeleteCrawlSource = async (id: number) => {
+    try {
+      await deleteCrawlSource(id);
+      await loadCrawlSources();
+    } catch (error) {
+      toast.error((error as Error).message || t("agent.knowledge.toast.crawlDeleteFailed"));
+    }
+  };
+
   // 格式化时间
   const formatTime = (dateStr: string) => {
     const date = new Date(dateStr);
@@ -1205,12 +1302,13 @@ export default function KnowledgePage(props: any = {}) {
           </DialogHeader>
           <Tabs
             value={importTab}
-            onValueChange={(v) => setImportTab(v as "file" | "url")}
+            onValueChange={(v) => setImportTab(v as "file" | "url" | "crawl")}
             defaultValue="file"
           >
-            <TabsList className="grid w-full grid-cols-2">
+            <TabsList className="grid w-full grid-cols-3">
               <TabsTrigger value="file">{t("agent.knowledge.import.tabFile")}</TabsTrigger>
               <TabsTrigger value="url">{t("agent.knowledge.import.tabUrl")}</TabsTrigger>
+              <TabsTrigger value="crawl">{t("agent.knowledge.import.tabCrawl")}</TabsTrigger>
             </TabsList>
             <TabsContent value="file" className="space-y-4 mt-4">
               <div>
@@ -1270,6 +1368,137 @@ export default function KnowledgePage(props: any = {}) {
                 </Button>
               </div>
             </TabsContent>
+            <TabsContent value="crawl" className="space-y-4 mt-4">
+              <div>
+                <Label htmlFor="crawl-seed">{t("agent.knowledge.crawl.seedLabel")}</Label>
+                <Input
+                  id="crawl-seed"
+                  value={crawlForm.seed_url}
+                  onChange={(e) => setCrawlForm({ ...crawlForm, seed_url: e.target.value })}
+                  placeholder="https://example.com/docs/  /  https://example.com/sitemap.xml"
+                />
+                <p className="text-xs text-muted-foreground mt-1">{t("agent.knowledge.crawl.seedHint")}</p>
+              </div>
+              <div className="grid grid-cols-2 gap-3 sm:grid-cols-4">
+                <div>
+                  <Label htmlFor="crawl-depth">{t("agent.knowledge.crawl.maxDepth")}</Label>
+                  <Input
+                    id="crawl-depth"
+                    type="number"
+                    min={0}
+                    max={5}
+                    value={crawlForm.max_depth}
+                    onChange={(e) => setCrawlForm({ ...crawlForm, max_depth: Number(e.target.value) || 0 })}
+                  />
+                </div>
+                <div>
+                  <Label htmlFor="crawl-pages">{t("agent.knowledge.crawl.maxPages")}</Label>
+                  <Input
+                    id="crawl-pages"
+                    type="number"
+                    min={1}
+                    max={1000}
+                    value={crawlForm.max_pages}
+                    onChange={(e) => setCrawlForm({ ...crawlForm, max_pages: Number(e.target.value) || 1 })}
+                  />
+                </div>
+                <div>
+                  <Label htmlFor="crawl-interval">{t("agent.knowledge.crawl.intervalHours")}</Label>
+                  <Input
+                    id="crawl-interval"
+                    type="number"
+                    min={0}
+                    max={720}
+                    value={crawlForm.interval_hours}
+                    onChange={(e) => setCrawlForm({ ...crawlForm, interval_hours: Number(e.target.value) || 0 })}
+                  />
+                </div>
+                <div>
+                  <Label htmlFor="crawl-chunk">{t("agent.knowledge.crawl.chunkMethod")}</Label>
+                  <select
+                    id="crawl-chunk"
+                    className="flex h-9 w-full rounded-md border border-input bg-transparent px-3 py-1 text-sm"
+                    value={crawlForm.chunk_method}
+                    onChange={(e) => setCrawlForm({ ...crawlForm, chunk_method: e.target.value })}
+                  >
+                    <option value="">{t("agent.knowledge.crawl.chunkNone")}</option>
+                    <option value="recursive">recursive</option>
+                    <option value="markdown">markdown</option>
+                    <option value="char_count">char_count</option>
+                  </select>
+                </div>
+              </div>
+              <p className="text-xs text-muted-foreground">{t("agent.knowledge.crawl.hint")}</p>
+              <div className="flex justify-end gap-2">
+                <Button
+                  variant="outline"
+                  onClick={() => setImportDialogOpen(false)}
+                  disabled={submitting}
+                >
+                  {t("agent.common.cancel")}
+                </Button>
+                <Button onClick={handleCreateCrawlSource} disabled={submitting}>
+                  <Globe className="w-4 h-4 mr-1" />
+                  {t("agent.knowledge.crawl.start")}
+                </Button>
+              </div>
+              {crawlSources.length > 0 && (
+                <div className="space-y-2 border-t pt-4">
+                  <p className="text-sm font-medium">{t("agent.knowledge.crawl.sources")}</p>
+                  {crawlSources.map((source) => (
+                    <div key={source.id} className="flex items-start justify-between gap-2 rounded-md border p-2">
+                      <div className="min-w-0 text-xs">
+                        <p className="truncate text-sm" title={source.seed_url}>{source.seed_url}</p>
+                        <p className="text-muted-foreground">
+                          {source.status === "running"
+                            ? t("agent.knowledge.crawl.status.running")
+                            : source.status === "failed"
+                              ? `${t("agent.knowledge.crawl.status.failed")}：${source.last_error}`
+                              : source.last_crawled_at
+                                ? tr("agent.knowledge.crawl.stats", {
+                                    time: formatTime(source.last_crawled_at),
+                                    found: String(source.pages_found),
+                                    created: String(source.pages_created),
+                                    updated: String(source.pages_updated),
+                                    unchanged: String(source.pages_unchanged),
+                                  })
+                                : t("agent.knowledge.crawl.status.never")}
+                        </p>
+                        {source.interval_hours > 0 && source.next_crawl_at && (
+                          <p className="text-muted-foreground">
+                            {tr("agent.knowledge.crawl.next", { time: formatTime(source.next_crawl_at) })}
+                          </p>
+                        )}
+                      </div>
+                      <div className="flex shrink-0 gap-1">
+                        <Button
+                          size="sm"
+                          variant="outline"
+                          onClick={() => handleRunCrawlSource(source.id)}
+                          disabled={source.status === "running"}
+                          title={t("agent.knowledge.crawl.runNow")}
+                        >
+                          {source.status === "running" ? (
+                            <Loader2 className="w-4 h-4 animate-spin" />
+                          ) : (
+                            <RefreshCw className="w-4 h-4" />
+                          )}
+                        </Button>
+                        <Button
+                          size="sm"
+                          variant="outline"
+                          onClick={() => handleDeleteCrawlSource(source.id)}
+                          disabled={source.status === "running"}
+                          title={t("agent.common.delete")}
+                        >
+                          <Trash2 className="w-4 h-4" />
+                        </Button>
+                      </div>
+                    </div>
+                  ))}
+                </div>
+              )}
+            </TabsContent>
           </Tabs>
         </DialogContent>
       </Dialog>
@@ -67,6 +67,7 @@ export default function SettingsPage(props: any = {}) {
     api_key: "",
     model: "",
     model_type: "text",
+    protocol: "openai",
     is_active: true,
     is_public: false,
     description: "",
@@ -374,6 +375,7 @@ export default function SettingsPage(props: any = {}) {
       api_key: "",
       model: "",
       model_type: "text",
+      protocol: "openai",
       is_active: true,
       is_public: false,
       description: "",
@@ -390,6 +392,7 @@ export default function SettingsPage(props: any = {}) {
       api_key: "", // 不显示 API Key（已加密）
       model: config.model,
       model_type: config.model_type,
+      protocol: config.protocol || "openai",
       is_active: config.is_active,
       is_public: config.is_public,
       description: config.description,
@@ -413,6 +416,7 @@ export default function SettingsPage(props: any = {}) {
           api_url: formData.api_url,
           model: formData.model,
           model_type: formData.model_type,
+          protocol: formData.protocol,
           is_active: formData.is_active,
           is_public: formData.is_public,
           description: formData.description,
@@ -1057,6 +1061,23 @@ export default function SettingsPage(props: any = {}) {
                       <option value="video">{t("agent.settings.modelType.video")}</option>
                     </select>
                   </div>
+
+                  <div>
+                    <label className="block text-sm font-medium mb-1">
+                      {t("agent.settings.aiForm.protocol")}
+                    </label>
+                    <select
+                      value={formData.protocol}
+                      onChange={(e) =>
+                        setFormData({ ...formData, protocol: e.target.value })
+                      }
+                      className="w-full px-3 py-2 border border-gray-300 rounded-md text-sm focus:outline-none focus:ring-2 focus:ring-primary"
+                    >
+                      <option value="openai">{t("agent.settings.aiForm.protocolOpenAI")}</option>
+                      <option value="anthropic">Anthropic Messages</option>
+                      <option value="gemini">Gemini generateContent</option>
+                    </select>
+                  </div>
                 </div>
 
                 <div>
@@ -555,6 +555,14 @@ export function MessageList({
           const cornerClass = isCurrentUser
             ? "rounded-[18px] rounded-br-md"
             : "rounded-[18px] rounded-bl-md";
+          // 实时翻译：对方消息以译文为正文、下方附原文；客服自己的回复在下方附访客收到的译文
+          const translatedText = message.translated_content || "";
+          const showTranslationAsMain = !isCurrentUser && translatedText !== "";
+          const secondaryText = showTranslationAsMain
+            ? message.content
+            : isCurrentUser && currentUserIsAgent
+              ? translatedText
+              : "";
           // 计算已读回执的样式类名
           // 统一使用相同的样式：蓝色半透明（text-primary/70）
           // 因为访客端和客服端的当前用户消息都是蓝色背景（bg-primary），所以使用相同的样式
@@ -634,11 +642,27 @@ export function MessageList({
                             <TypewriterText text={message.content} animateKey={message.id} />
                           );
                         })()
+                      ) : showTranslationAsMain ? (
+                        keyword !== "" ? highlightText(translatedText, keyword) : translatedText
                       ) : (
                         bubbleContent
                       )}
                     </div>
                   )}
+                  {message.content && secondaryText && (
+                    <div
+                      className={`mt-1.5 pt-1.5 border-t whitespace-pre-wrap break-words text-xs ${
+                        isCurrentUser ? "border-primary-foreground/20 text-primary-foreground/75" : "border-border/40 text-muted-foreground"
+                      }`}
+                    >
+                      <span className="mr-1 opacity-80">
+                        {showTranslationAsMain ? t("agent.translation.original") : t("agent.translation.translated")}
+                        {message.source_language && showTranslationAsMain ? ` (${message.source_language})` : ""}
+                        {message.translated_language && !showTranslationAsMain ? ` (${message.translated_language})` : ""}
+                      </span>
+                      {secondaryText}
+                    </div>
+                  )}
                   
                   {/* 文件显示 */}
                   {hasFile && message.file_url && (
@@ -708,6 +732,26 @@ export function MessageList({
                     ))}
                   </div>
                 )}
+                {/* AI 回复的引用来源（编号与正文中的 [n] 对应） */}
+                {!isCurrentUser && message.citations && message.citations.length > 0 && (
+                  <div className="mt-1 text-[10px] text-muted-foreground space-y-0.5">
+                    <div>{t("agent.aiSource.citations")}</div>
+                    {message.citations.map((c) => (
+                      <div key={c.index} className="truncate max-w-xs" title={c.snippet || c.title}>
+                        [{c.index}]{" "}
+                        {c.url ? (
+                          <a href={c.url} target="_blank" rel="noopener noreferrer" className="underline">
+                            {c.title || c.url}
+                          </a>
+                        ) : (
+                          c.title
+                        )}
+                        {c.page_number ? ` p.${c.page_number}` : ""}
+                        {c.heading_path ? ` · ${c.heading_path}` : ""}
+                      </div>
+                    ))}
+                  </div>
+                )}
               </div>
             </div>
           );
@@ -18,6 +18,7 @@ import {
   ConversationSummary,
   MessageItem,
   MessagesReadPayload,
+  MessageTranslatedPayload,
   ChatWebSocketPayload,
   VisitorStatusUpdatePayload,
   TypingDraftPayload,
@@ -531,6 +532,23 @@ export function useMessages({
           event.data as MessagesReadPayload,
           event.conversation_id
         );
+      } else if (event.type === "message_translated" && event.data) {
+        // 译文在消息送达后异步生成，按消息 ID 补到已显示的消息上
+        const payload = event.data as MessageTranslatedPayload;
+        if (payload.conversation_id === conversationId && payload.message_id) {
+          setMessages((prev) =>
+            prev.map((msg) =>
+              msg.id === payload.message_id
+                ? {
+                    ...msg,
+                    source_language: payload.source_language,
+                    translated_content: payload.translated_content,
+                    translated_language: payload.translated_language,
+                  }
+                : msg
+            )
+          );
+        }
       } else if (event.type === "visitor_status_update") {
         // 处理访客状态更新事件
         const payload = event.data as VisitorStatusUpdatePayload;
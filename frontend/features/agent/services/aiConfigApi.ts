@@ -8,6 +8,7 @@ export interface AIConfig {
   api_url: string;
   model: string;
   model_type: string;
+  protocol: string; // 接口协议：openai / anthropic / gemini
   is_active: boolean;
   is_public: boolean;
   description: string;
@@ -22,6 +23,7 @@ export interface CreateAIConfigRequest {
   api_key: string;
   model: string;
   model_type?: string;
+  protocol?: string;
   is_active?: boolean;
   is_public?: boolean;
   description?: string;
@@ -34,6 +36,7 @@ export interface UpdateAIConfigRequest {
   api_key?: string;
   model?: string;
   model_type?: string;
+  protocol?: string;
   is_active?: boolean;
   is_public?: boolean;
   description?: string;
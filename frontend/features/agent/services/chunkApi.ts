@@ -8,15 +8,37 @@ export interface DocumentChunk {
   chunk_index: number;
   content: string;
   embedding_status: string;
+  page_number: number;
+  heading_path: string;
+  source_anchor: string;
   created_at: string;
   updated_at: string;
 }
 
 // 分段请求参数
 export interface ChunkRequest {
-  method: "char_count" | "separator";
+  method: "char_count" | "separator" | "recursive" | "markdown";
   chunk_size?: number;
   separator?: string;
+  overlap?: number; // 相邻分段重叠长度（recursive / markdown）
+  size_unit?: "char" | "token";
+}
+
+// 分段预览（未保存）
+export interface ChunkPreview {
+  index: number;
+  content: string;
+  chars: number;
+  tokens: number;
+  page_number: number;
+  heading_path: string;
+  source_anchor: string;
+}
+
+// 分段预览响应
+export interface ChunkPreviewResponse {
+  chunk_count: number;
+  chunks: ChunkPreview[];
 }
 
 // 分段列表响应
@@ -54,6 +76,25 @@ export async function executeChunking(
   return res.json();
 }
 
+// 预览分段（不保存、不向量化）
+export async function previewChunking(
+  documentId: number,
+  req: ChunkRequest
+): Promise<ChunkPreviewResponse> {
+  const res = await fetch(apiUrl(`/documents/${documentId}/chunks/preview`), {
+    method: "POST",
+    headers: { "Content-Type": "application/json", ...getAgentHeaders() },
+    body: JSON.stringify(req),
+  });
+
+  if (!res.ok) {
+    const error = await res.json().catch(() => ({}));
+    throw new Error(error.error || "预览分段失败");
+  }
+
+  return res.json();
+}
+
 // 获取分段列表
 export async function fetchChunks(
   documentId: number,
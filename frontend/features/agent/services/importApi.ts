@@ -84,3 +84,87 @@ export async function importFromUrls(data: ImportFromUrlsRequest): Promise<Impor
     throw new Error("服务器返回格式错误，请检查后端接口");
   }
 }
+
+// 网站抓取来源（起始页面或 sitemap.xml，按周期重新抓取）
+export interface CrawlSource {
+  id: number;
+  knowledge_base_id: number;
+  seed_url: string;
+  max_depth: number;
+  max_pages: number;
+  delay_ms: number;
+  interval_hours: number;
+  chunk_method: string;
+  status: "idle" | "running" | "failed";
+  last_error: string;
+  last_crawled_at?: string | null;
+  next_crawl_at?: string | null;
+  pages_found: number;
+  pages_created: number;
+  pages_updated: number;
+  pages_unchanged: number;
+  pages_failed: number;
+  pages_disallowed: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CrawlSourceRequest {
+  knowledge_base_id: number;
+  seed_url: string;
+  max_depth?: number;
+  max_pages?: number;
+  delay_ms?: number;
+  interval_hours?: number;
+  chunk_method?: string;
+  run_now?: boolean;
+}
+
+export async function listCrawlSources(knowledgeBaseId: number): Promise<CrawlSource[]> {
+  const res = await fetch(
+    apiUrl(`/import/crawl-sources?knowledge_base_id=${knowledgeBaseId}`),
+    { headers: getAgentHeaders() }
+  );
+  if (!res.ok) {
+    const error = await res.json().catch(() => ({}));
+    throw new Error((error as { error?: string }).error || "获取抓取来源失败");
+  }
+  const data = (await res.json()) as { sources?: CrawlSource[] };
+  return data.sources ?? [];
+}
+
+export async function createCrawlSource(data: CrawlSourceRequest): Promise<CrawlSource> {
+  const res = await fetch(apiUrl("/import/crawl-sources"), {
+    method: "POST",
+    headers: { "Content-Type": "application/json", ...getAgentHeaders() },
+    body: JSON.stringify(data),
+  });
+  if (!res.ok) {
+    const error = await res.json().catch(() => ({}));
+    throw new Error((error as { error?: string }).error || "创建抓取来源失败");
+  }
+  return res.json();
+}
+
+export async function runCrawlSource(id: number): Promise<CrawlSource> {
+  const res = await fetch(apiUrl(`/import/crawl-sources/${id}/run`), {
+    method: "POST",
+    headers: getAgentHeaders(),
+  });
+  if (!res.ok) {
+    const error = await res.json().catch(() => ({}));
+    throw new Error((error as { error?: string }).error || "开始抓取失败");
+  }
+  return res.json();
+}
+
+export async function deleteCrawlSource(id: number): Promise<void> {
+  const res = await fetch(apiUrl(`/import/crawl-sources/${id}`), {
+    method: "DELETE",
+    headers: getAgentHeaders(),
+  });
+  if (!res.ok) {
+    const error = await res.json().catch(() => ({}));
+    throw new Error((error as { error?: string }).error || "删除抓取来源失败");
+  }
+}
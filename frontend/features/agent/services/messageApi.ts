@@ -1,6 +1,6 @@
 import { apiUrl, getAgentHeaders } from "@/lib/config";
 import { getVisitorConversationHeaders } from "@/lib/visitor-session";
-import { MessageItem } from "../types";
+import { MessageCitation, MessageItem } from "../types";
 import { reportFrontendLog } from "./systemLogApi";
 
 /** 解析 POST /messages 返回的消息体（与 models.Message JSON 一致） */
@@ -44,6 +44,15 @@ function messageItemFromResponse(data: unknown): MessageItem | null {
       typeof raw.mime_type === "string" ? raw.mime_type : undefined,
     sources_used:
       typeof raw.sources_used === "string" ? raw.sources_used : undefined,
+    citations: Array.isArray(raw.citations)
+      ? (raw.citations as MessageCitation[])
+      : undefined,
+    source_language:
+      typeof raw.source_language === "string" ? raw.source_language : undefined,
+    translated_content:
+      typeof raw.translated_content === "string" ? raw.translated_content : undefined,
+    translated_language:
+      typeof raw.translated_language === "string" ? raw.translated_language : undefined,
   };
 }
 
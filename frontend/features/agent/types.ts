@@ -42,6 +42,27 @@ export interface MessageItem {
   mime_type?: string | null;
   /** AI 回复使用的数据源，逗号分隔，如 knowledge_base / llm / web */
   sources_used?: string | null;
+  /** AI 回复引用的来源，index 与回复中的 [n] 对应 */
+  citations?: MessageCitation[];
+  /** 实时翻译：content 为发送方原文，translated_content 为译文 */
+  source_language?: string;
+  translated_content?: string;
+  translated_language?: string;
+}
+
+export interface MessageCitation {
+  index: number;
+  source_type: "knowledge_base" | "faq" | "web" | string;
+  document_id?: number;
+  knowledge_base_id?: number;
+  chunk_id?: number;
+  title: string;
+  score?: number;
+  url?: string;
+  snippet?: string;
+  source_name?: string;
+  page_number?: number;
+  heading_path?: string;
 }
 
 export interface ConversationDetail extends ConversationSummary {
@@ -97,9 +118,19 @@ export interface TypingDraftPayload {
   seq?: number;
 }
 
+/** 实时翻译在消息送达后异步完成，译文通过 message_translated 事件补发 */
+export interface MessageTranslatedPayload {
+  conversation_id?: number;
+  message_id?: number;
+  source_language?: string;
+  translated_content?: string;
+  translated_language?: string;
+}
+
 export type ChatWebSocketPayload =
   | MessageItem
   | MessagesReadPayload
   | VisitorStatusUpdatePayload
-  | TypingDraftPayload;
+  | TypingDraftPayload
+  | MessageTranslatedPayload;
 
@@ -8,6 +8,7 @@ export const PERMISSION_OPTIONS = [
   { key: "prompts", label: "提示词" },
   { key: "settings", label: "AI 配置" },
   { key: "users", label: "用户管理" },
+  { key: "macros", label: "快捷回复" },
 ] as const;
 
 export type PermissionKey = (typeof PERMISSION_OPTIONS)[number]["key"];
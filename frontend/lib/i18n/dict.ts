@@ -213,6 +213,9 @@ export type I18nKey =
   | "agent.aiSource.kb"
   | "agent.aiSource.llm"
   | "agent.aiSource.web"
+  | "agent.aiSource.citations"
+  | "agent.translation.original"
+  | "agent.translation.translated"
   | "agent.common.back"
   | "agent.common.cancel"
   | "agent.common.create"
@@ -284,6 +287,8 @@ export type I18nKey =
   | "agent.settings.aiForm.modelPh"
   | "agent.settings.aiForm.provider"
   | "agent.settings.aiForm.providerPh"
+  | "agent.settings.aiForm.protocol"
+  | "agent.settings.aiForm.protocolOpenAI"
   | "agent.settings.aiForm.public"
   | "agent.settings.aiForm.submitCreate"
   | "agent.settings.aiForm.submitUpdate"
@@ -447,6 +452,28 @@ export type I18nKey =
   | "agent.knowledge.import.filesSelected"
   | "agent.knowledge.import.action"
   | "agent.knowledge.import.urlListLabel"
+  | "agent.knowledge.import.tabCrawl"
+  | "agent.knowledge.crawl.seedLabel"
+  | "agent.knowledge.crawl.seedHint"
+  | "agent.knowledge.crawl.maxDepth"
+  | "agent.knowledge.crawl.maxPages"
+  | "agent.knowledge.crawl.intervalHours"
+  | "agent.knowledge.crawl.chunkMethod"
+  | "agent.knowledge.crawl.chunkNone"
+  | "agent.knowledge.crawl.hint"
+  | "agent.knowledge.crawl.start"
+  | "agent.knowledge.crawl.sources"
+  | "agent.knowledge.crawl.runNow"
+  | "agent.knowledge.crawl.status.running"
+  | "agent.knowledge.crawl.status.failed"
+  | "agent.knowledge.crawl.status.never"
+  | "agent.knowledge.crawl.stats"
+  | "agent.knowledge.crawl.next"
+  | "agent.knowledge.toast.crawlStarted"
+  | "agent.knowledge.toast.crawlLoadFailed"
+  | "agent.knowledge.toast.crawlCreateFailed"
+  | "agent.knowledge.toast.crawlRunFailed"
+  | "agent.knowledge.toast.crawlDeleteFailed"
   | "agent.knowledge.doc.create"
   | "agent.knowledge.doc.searchPh"
   | "agent.knowledge.doc.empty"
@@ -811,6 +838,9 @@ export const DICT: Record<Lang, Record<I18nKey, string>> = {
     "agent.aiSource.kb": "已使用知识库",
     "agent.aiSource.llm": "已使用大模型",
     "agent.aiSource.web": "已使用联网搜索",
+    "agent.aiSource.citations": "引用来源",
+    "agent.translation.original": "原文",
+    "agent.translation.translated": "译文",
     "agent.common.back": "返回",
     "agent.common.cancel": "取消",
     "agent.common.create": "创建",
@@ -883,7 +913,9 @@ export const DICT: Record<Lang, Record<I18nKey, string>> = {
     "agent.settings.aiForm.modelType": "模型类型",
     "agent.settings.aiForm.modelPh": "例如：gpt-3.5-turbo、gpt-4",
     "agent.settings.aiForm.provider": "服务商名称",
-    "agent.settings.aiForm.providerPh": "例如：OpenAI、Claude、自定义",
+    "agent.settings.aiForm.providerPh": "例如：OpenAI、Claude、自定义（仅用于显示）",
+    "agent.settings.aiForm.protocol": "接口协议",
+    "agent.settings.aiForm.protocolOpenAI": "OpenAI 兼容（Chat Completions）",
     "agent.settings.aiForm.public": "开放给访客使用",
     "agent.settings.aiForm.submitCreate": "创建配置",
     "agent.settings.aiForm.submitUpdate": "更新配置",
@@ -1055,6 +1087,28 @@ export const DICT: Record<Lang, Record<I18nKey, string>> = {
     "agent.knowledge.import.filesSelected": "已选择 {{count}} 个文件",
     "agent.knowledge.import.action": "导入",
     "agent.knowledge.import.urlListLabel": "URL 列表（每行一个）",
+    "agent.knowledge.import.tabCrawl": "网站抓取",
+    "agent.knowledge.crawl.seedLabel": "起始页面或 sitemap.xml",
+    "agent.knowledge.crawl.seedHint": "只抓取同一站点的页面，遵守 robots.txt 并限速访问",
+    "agent.knowledge.crawl.maxDepth": "链接层数",
+    "agent.knowledge.crawl.maxPages": "最多页面数",
+    "agent.knowledge.crawl.intervalHours": "重新抓取间隔（小时）",
+    "agent.knowledge.crawl.chunkMethod": "分段方式",
+    "agent.knowledge.crawl.chunkNone": "不分段",
+    "agent.knowledge.crawl.hint": "抓取在后台进行；重新抓取时只更新内容有变化的页面。间隔为 0 表示不自动重新抓取。",
+    "agent.knowledge.crawl.start": "开始抓取",
+    "agent.knowledge.crawl.sources": "抓取来源",
+    "agent.knowledge.crawl.runNow": "立即重新抓取",
+    "agent.knowledge.crawl.status.running": "抓取中…",
+    "agent.knowledge.crawl.status.failed": "抓取失败",
+    "agent.knowledge.crawl.status.never": "尚未抓取",
+    "agent.knowledge.crawl.stats": "{{time}} 抓取 {{found}} 页：新增 {{created}}，更新 {{updated}}，未变化 {{unchanged}}",
+    "agent.knowledge.crawl.next": "下次抓取：{{time}}",
+    "agent.knowledge.toast.crawlStarted": "已开始抓取，完成后文档会自动出现在列表中",
+    "agent.knowledge.toast.crawlLoadFailed": "获取抓取来源失败",
+    "agent.knowledge.toast.crawlCreateFailed": "创建抓取来源失败",
+    "agent.knowledge.toast.crawlRunFailed": "开始抓取失败",
+    "agent.knowledge.toast.crawlDeleteFailed": "删除抓取来源失败",
     "agent.knowledge.doc.create": "新建文档",
     "agent.knowledge.doc.searchPh": "搜索文档...",
     "agent.knowledge.doc.empty": "暂无文档",
@@ -1428,6 +1482,9 @@ export const DICT: Record<Lang, Record<I18nKey, string>> = {
     "agent.aiSource.kb": "Knowledge base used",
     "agent.aiSource.llm": "LLM used",
     "agent.aiSource.web": "Web search used",
+    "agent.aiSource.citations": "Sources",
+    "agent.translation.original": "Original",
+    "agent.translation.translated": "Translation",
     "agent.common.back": "Back",
     "agent.common.cancel": "Cancel",
     "agent.common.create": "Create",
@@ -1500,7 +1557,9 @@ export const DICT: Record<Lang, Record<I18nKey, string>> = {
     "agent.settings.aiForm.modelType": "Model type",
     "agent.settings.aiForm.modelPh": "e.g. gpt-3.5-turbo, gpt-4",
     "agent.settings.aiForm.provider": "Provider",
-    "agent.settings.aiForm.providerPh": "e.g. OpenAI, Claude, Custom",
+    "agent.settings.aiForm.providerPh": "e.g. OpenAI, Claude, Custom (display only)",
+    "agent.settings.aiForm.protocol": "API protocol",
+    "agent.settings.aiForm.protocolOpenAI": "OpenAI-compatible (Chat Completions)",
     "agent.settings.aiForm.public": "Available to visitors",
     "agent.settings.aiForm.submitCreate": "Create",
     "agent.settings.aiForm.submitUpdate": "Update",
@@ -1677,6 +1736,28 @@ export const DICT: Record<Lang, Record<I18nKey, string>> = {
     "agent.knowledge.import.filesSelected": "{{count}} file(s) selected",
     "agent.knowledge.import.action": "Import",
     "agent.knowledge.import.urlListLabel": "URL list (one per line)",
+    "agent.knowledge.import.tabCrawl": "Crawl site",
+    "agent.knowledge.crawl.seedLabel": "Start page or sitemap.xml",
+    "agent.knowledge.crawl.seedHint": "Only same-site pages are crawled; robots.txt and rate limits are respected",
+    "agent.knowledge.crawl.maxDepth": "Link depth",
+    "agent.knowledge.crawl.maxPages": "Max pages",
+    "agent.knowledge.crawl.intervalHours": "Re-crawl every (hours)",
+    "agent.knowledge.crawl.chunkMethod": "Chunking",
+    "agent.knowledge.crawl.chunkNone": "None",
+    "agent.knowledge.crawl.hint": "Crawling runs in the background; re-crawls only update pages whose content changed. Interval 0 disables scheduled re-crawls.",
+    "agent.knowledge.crawl.start": "Start crawl",
+    "agent.knowledge.crawl.sources": "Crawl sources",
+    "agent.knowledge.crawl.runNow": "Re-crawl now",
+    "agent.knowledge.crawl.status.running": "Crawling…",
+    "agent.knowledge.crawl.status.failed": "Crawl failed",
+    "agent.knowledge.crawl.status.never": "Not crawled yet",
+    "agent.knowledge.crawl.stats": "{{time}}: {{found}} pages, {{created}} new, {{updated}} updated, {{unchanged}} unchanged",
+    "agent.knowledge.crawl.next": "Next crawl: {{time}}",
+    "agent.knowledge.toast.crawlStarted": "Crawl started; documents will appear when it finishes",
+    "agent.knowledge.toast.crawlLoadFailed": "Failed to load crawl sources",
+    "agent.knowledge.toast.crawlCreateFailed": "Failed to create crawl source",
+    "agent.knowledge.toast.crawlRunFailed": "Failed to start crawl",
+    "agent.knowledge.toast.crawlDeleteFailed": "Failed to delete crawl source",
     "agent.knowledge.doc.create": "New doc",
     "agent.knowledge.doc.searchPh": "Search docs...",
     "agent.knowledge.doc.empty": "No docs",